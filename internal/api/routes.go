@@ -1,56 +1,142 @@
 package api
 
 import (
+	"time"
+
 	"gypsum-analysis-api/internal/config"
 	"gypsum-analysis-api/internal/handlers"
 	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/metrics"
+	"gypsum-analysis-api/internal/middleware"
 	"gypsum-analysis-api/internal/services"
+	"gypsum-analysis-api/internal/storage"
 
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-// SetupRoutes configures all API routes
-func SetupRoutes(router *gin.Engine, cfg *config.Config, logger *logger.Logger) {
-	// Configure maximum multipart memory to support large image uploads
-	// Allow configured max file size plus a small overhead buffer
-	router.MaxMultipartMemory = cfg.MaxFileSize + int64(10<<20) // +10MB overhead
-	// Add CORS middleware
-	router.Use(func(c *gin.Context) {
-		c.Header("Access-Control-Allow-Origin", "*")
-		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization")
-
-		// Handle preflight requests
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
-		}
-
-		c.Next()
-	})
+// SetupRoutes configures all API routes. Analysis results are kept in store
+// and uploaded images in blobs, status lookups are cached in cache when it is
+// not nil, and analyses run on pool and are reported to m. Submissions are
+// limited by submitLimiter, which the caller stops on shutdown. The returned
+// service must be closed on shutdown.
+func SetupRoutes(router *gin.Engine, cfg *config.Config, logger *logger.Logger, store services.ResultStore, blobs storage.BlobStore, cache storage.CacheStore, pool *services.WorkerPool, submitLimiter *middleware.RateLimiter, m *metrics.Metrics) *services.AnalysisService {
+	// Tag every request with an ID that error responses and log entries refer
+	// to. Handlers pass the gin context to the logger, which then reads the
+	// request's context.
+	router.ContextWithFallback = true
+	router.Use(middleware.RequestID())
 
 	// Initialize services
-	analysisService := services.NewAnalysisService(cfg, logger)
+	analysisService := services.NewAnalysisService(cfg, logger, store, blobs, cache, pool, m)
+
+	// Prometheus scrapes are served outside /api/v1 and ahead of the CORS
+	// middleware, which only browser clients need
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m,
+	)
+	router.GET("/metrics", gin.WrapH(promhttp.HandlerFor(registry, promhttp.HandlerOpts{})))
+
+	// Keep small uploads in memory and spool larger ones to disk
+	router.MaxMultipartMemory = cfg.UploadMemoryThreshold
+	// Apply the configured cross-origin policy
+	router.Use(middleware.CORS(cfg.CORSAllowedOrigins, cfg.CORSAllowedMethods, cfg.CORSAllowCredentials))
+
+	// Sweep files left behind by analyses that never finished, e.g. before a crash
+	if cfg.TempFileTTL > 0 {
+		go analysisService.RunJanitor(time.Duration(cfg.TempFileTTL)*time.Second, time.Duration(cfg.TempCleanupInterval)*time.Second)
+	}
 
 	// Initialize handlers
-	analysisHandler := handlers.NewAnalysisHandler(analysisService, logger)
+	analysisHandler := handlers.NewAnalysisHandler(analysisService, cfg, logger)
+	reportHandler := handlers.NewReportHandler(analysisService, services.NewReportService(cfg), cfg, logger)
+	healthHandler := handlers.NewHealthHandler(cfg, store, logger)
+
+	// Push status transitions to WebSocket subscribers
+	hub := handlers.NewHub(analysisService, cfg.WSAllowAll, cfg.CORSAllowedOrigins, logger)
+	go hub.Run()
+
+	// Artifact generation is expensive, so exports share their own concurrency budget
+	exportLimit := middleware.ConcurrencyLimit(cfg.MaxConcurrentExports, 10*time.Second)
+
+	// Each client, by token subject or else IP, gets its own budget for starting analyses
+	submitLimit := submitLimiter.Middleware()
 
-	// Health check endpoint
-	router.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{
-			"status":  "healthy",
-			"service": "gypsum-analysis-api",
-		})
-	})
+	// Health checks: /health/live for liveness probes, /health/ready for
+	// readiness probes. /health and /ready are aliases of the two.
+	router.GET("/health", healthHandler.Live)
+	router.GET("/health/live", healthHandler.Live)
+	router.GET("/health/ready", healthHandler.Ready)
+	router.GET("/ready", healthHandler.Ready)
 
-	// API v1 routes
+	// API description generated from the models, and a page to browse it
+	docsHandler := handlers.NewDocsHandler()
+	router.GET("/openapi.json", docsHandler.Spec)
+	router.GET("/docs", docsHandler.UI)
+	router.GET("/api/v1/docs/*any", docsHandler.Browse)
+
+	// API v1 routes; the health checks above stay unauthenticated
 	v1 := router.Group("/api/v1")
+	if cfg.AuthEnabled {
+		v1.Use(middleware.JWTAuth(cfg.JWTSecret, cfg.JWTIssuer, time.Duration(cfg.JWTExpiry)*time.Second))
+	}
 	{
+		// Live status updates
+		v1.GET("/ws", hub.ServeWS)
+
 		// Analysis endpoints
 		analysis := v1.Group("/analysis")
 		{
-			analysis.POST("/gypsum", analysisHandler.AnalyzeGypsum)
+			analysis.GET("", analysisHandler.ListAnalyses)
+			analysis.POST("/gypsum", submitLimit, analysisHandler.AnalyzeGypsum)
+			analysis.POST("/gypsum/batch", submitLimit, analysisHandler.AnalyzeBatch)
+			analysis.POST("/batch", submitLimit, analysisHandler.AnalyzeImages)
+			analysis.POST("/upload/init", submitLimit, analysisHandler.InitUpload)
+			analysis.POST("/upload/:upload_id/chunk", submitLimit, analysisHandler.UploadChunk)
+			analysis.POST("/upload/:upload_id/complete", submitLimit, analysisHandler.CompleteUpload)
+			analysis.POST("/macro/preview", analysisHandler.PreviewMacro)
+			analysis.GET("/batch/:batch_id", analysisHandler.GetBatchStatus)
 			analysis.GET("/status/:id", analysisHandler.GetAnalysisStatus)
+			analysis.GET("/status/:id/stream", analysisHandler.StreamAnalysisStatus)
+			analysis.POST("/status/:id/annotate", analysisHandler.AnnotateAnalysis)
+			analysis.GET("/status/:id/manifest", exportLimit, analysisHandler.GetAnalysisManifest)
+			analysis.GET("/status/:id/export", exportLimit, analysisHandler.ExportAnalysis)
+			analysis.GET("/trend", exportLimit, analysisHandler.GetPurityTrend)
+			analysis.GET("/summary", exportLimit, analysisHandler.GetPuritySummary)
+			analysis.GET("/compare", analysisHandler.CompareAnalyses)
+			analysis.GET("/export", exportLimit, analysisHandler.ExportResults)
+			analysis.GET("/:id/events", analysisHandler.StreamAnalysisEvents)
+			analysis.POST("/:id/cancel", analysisHandler.CancelAnalysis)
+			analysis.GET("/:id/export", exportLimit, analysisHandler.ExportResult)
+			analysis.GET("/:id/report", exportLimit, reportHandler.GetReport)
+			analysis.GET("/:id/overlay", exportLimit, analysisHandler.GetOverlay)
+			analysis.GET("/:id/verify", analysisHandler.VerifyAnalysis)
+			analysis.DELETE("/:id", analysisHandler.DeleteAnalysis)
+		}
+
+		// Webhooks for terminal analysis events
+		webhooks := v1.Group("/webhooks")
+		{
+			webhooks.POST("", analysisHandler.RegisterWebhook)
+			webhooks.GET("/:id/deliveries", analysisHandler.ListWebhookDeliveries)
+			webhooks.DELETE("/:id", analysisHandler.DeleteWebhook)
+		}
+
+		// Analysis profiles: presets of macro settings selected with profile_id
+		profiles := v1.Group("/profiles")
+		{
+			profiles.GET("", analysisHandler.ListProfiles)
+			profiles.POST("", analysisHandler.CreateProfile)
+			profiles.GET("/:id", analysisHandler.GetProfile)
+			profiles.PUT("/:id", analysisHandler.UpdateProfile)
+			profiles.DELETE("/:id", analysisHandler.DeleteProfile)
 		}
 	}
+
+	return analysisService
 }
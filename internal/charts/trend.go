@@ -0,0 +1,68 @@
+package charts
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+
+	chart "github.com/wcharczuk/go-chart/v2"
+)
+
+// ErrNoTrendData is returned when there are no points to plot
+var ErrNoTrendData = errors.New("no analyses to plot")
+
+// RenderPurityTrend draws purity over time as a PNG line chart
+func RenderPurityTrend(w io.Writer, title string, points []models.TrendPoint) error {
+	if len(points) == 0 {
+		return ErrNoTrendData
+	}
+
+	xValues := make([]time.Time, 0, len(points)+1)
+	yValues := make([]float64, 0, len(points)+1)
+	for _, point := range points {
+		xValues = append(xValues, point.Timestamp)
+		yValues = append(yValues, point.PurityPercentage)
+	}
+
+	// A single analysis still needs a non-zero time range to draw an axis
+	if len(points) == 1 {
+		xValues = append(xValues, points[0].Timestamp.Add(time.Minute))
+		yValues = append(yValues, points[0].PurityPercentage)
+	}
+
+	graph := chart.Chart{
+		Title:  title,
+		Width:  1024,
+		Height: 480,
+		XAxis: chart.XAxis{
+			Name:           "Completed at",
+			ValueFormatter: chart.TimeMinuteValueFormatter,
+		},
+		YAxis: chart.YAxis{
+			Name:  "Purity (%)",
+			Range: &chart.ContinuousRange{Min: 0, Max: 100},
+		},
+		Series: []chart.Series{
+			chart.TimeSeries{
+				Name: "Purity",
+				Style: chart.Style{
+					StrokeColor: chart.ColorBlue,
+					StrokeWidth: 2,
+					DotColor:    chart.ColorBlue,
+					DotWidth:    3,
+				},
+				XValues: xValues,
+				YValues: yValues,
+			},
+		},
+	}
+
+	if err := graph.Render(chart.PNG, w); err != nil {
+		return fmt.Errorf("failed to render trend chart: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,37 @@
+package charts
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderPurityTrend(t *testing.T) {
+	start := time.Date(2024, 3, 1, 6, 0, 0, 0, time.UTC)
+
+	for _, count := range []int{1, 5} {
+		points := make([]models.TrendPoint, count)
+		for i := range points {
+			points[i] = models.TrendPoint{Timestamp: start.Add(time.Duration(i) * time.Hour), PurityPercentage: 70 + float64(i)}
+		}
+
+		var buf bytes.Buffer
+		require.NoError(t, RenderPurityTrend(&buf, "Purity trend", points))
+
+		img, err := png.Decode(&buf)
+		require.NoError(t, err)
+		assert.Equal(t, 1024, img.Bounds().Dx())
+	}
+}
+
+func TestRenderPurityTrend_NoData(t *testing.T) {
+	var buf bytes.Buffer
+	assert.ErrorIs(t, RenderPurityTrend(&buf, "Purity trend", nil), ErrNoTrendData)
+	assert.Zero(t, buf.Len())
+}
@@ -1,8 +1,16 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
 	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"syscall"
 
 	"github.com/spf13/viper"
 )
@@ -12,14 +20,217 @@ type Config struct {
 	Environment string `mapstructure:"ENVIRONMENT"`
 	Port        string `mapstructure:"PORT"`
 	LogLevel    string `mapstructure:"LOG_LEVEL"`
-	
+
+	// Serve HTTPS on Port with a Let's Encrypt certificate for TLSDomain,
+	// cached in TLSCertDir. Port 80 then answers ACME challenges and
+	// redirects to HTTPS.
+	TLSEnabled bool   `mapstructure:"TLS_ENABLED"`
+	TLSDomain  string `mapstructure:"TLS_DOMAIN"`
+	TLSCertDir string `mapstructure:"TLS_CERT_DIR"`
+
+	// Port of the gRPC interface; empty disables it
+	GRPCPort string `mapstructure:"GRPC_PORT"`
+
+	// OpenTelemetry trace export over OTLP/gRPC; tracing is disabled while
+	// OTLPEndpoint (host:port) is empty
+	OTLPEndpoint string `mapstructure:"OTLP_ENDPOINT"`
+	OTLPInsecure bool   `mapstructure:"OTLP_INSECURE"`
+
+	// Result persistence; results are kept in memory when DatabaseURL is empty
+	DatabaseURL      string `mapstructure:"DATABASE_URL"`
+	DatabaseMaxConns int    `mapstructure:"DATABASE_MAX_CONNS"`
+
+	// Bearer token authentication for /api/v1 and gRPC. While AuthEnabled is
+	// set JWTSecret is required. JWTExpiry is the longest token lifetime
+	// accepted, in seconds.
+	AuthEnabled bool   `mapstructure:"AUTH_ENABLED"`
+	JWTSecret   string `mapstructure:"JWT_SECRET"`
+	JWTIssuer   string `mapstructure:"JWT_ISSUER"`
+	JWTExpiry   int    `mapstructure:"JWT_EXPIRY"`
+
+	// Cross-origin policy for browser clients. "*" allows any origin but
+	// cannot be combined with CORSAllowCredentials.
+	CORSAllowedOrigins   []string `mapstructure:"CORS_ALLOWED_ORIGINS"`
+	CORSAllowedMethods   []string `mapstructure:"CORS_ALLOWED_METHODS"`
+	CORSAllowCredentials bool     `mapstructure:"CORS_ALLOW_CREDENTIALS"`
+
 	// Fiji/ImageJ settings
-	FijiPath     string `mapstructure:"FIJI_PATH"`
-	TempDir      string `mapstructure:"TEMP_DIR"`
-	MaxFileSize  int64  `mapstructure:"MAX_FILE_SIZE"`
-	
+	FijiPath string `mapstructure:"FIJI_PATH"`
+
+	// AnalysisEngine selects what runs analyses: EngineFiji, which requires
+	// the executable at FijiPath, or EngineNative, the pure-Go analyzer.
+	// EngineAuto is resolved to one of them when the configuration is loaded.
+	AnalysisEngine string `mapstructure:"ANALYSIS_ENGINE"`
+
+	// EngineFallback is set when EngineAuto chose the native engine because
+	// no Fiji executable was found
+	EngineFallback bool `mapstructure:"-"`
+
+	TempDir     string `mapstructure:"TEMP_DIR"`
+	MaxFileSize int64  `mapstructure:"MAX_FILE_SIZE"`
+
+	// Uploads whose header claims more than MaxImagePixels pixels are
+	// rejected before they are decoded (0 disables the check)
+	MaxImagePixels int64 `mapstructure:"MAX_IMAGE_PIXELS"`
+
+	// Where uploaded images are kept: "local" stores them in TempDir, "s3" in
+	// S3Bucket. S3Endpoint and S3ForcePathStyle point the client at an
+	// S3-compatible service such as MinIO.
+	StorageBackend   string `mapstructure:"STORAGE_BACKEND"`
+	S3Bucket         string `mapstructure:"S3_BUCKET"`
+	S3Region         string `mapstructure:"S3_REGION"`
+	S3Endpoint       string `mapstructure:"S3_ENDPOINT"`
+	S3ForcePathStyle bool   `mapstructure:"S3_FORCE_PATH_STYLE"`
+
+	// Analysis status lookups are cached in the Redis server at RedisAddr,
+	// when set. Finished results are cached for RedisTTLSeconds; results that
+	// may still change only for a few seconds.
+	RedisAddr       string `mapstructure:"REDIS_ADDR"`
+	RedisTTLSeconds int    `mapstructure:"REDIS_TTL_SECONDS"`
+
+	// Multipart uploads are held in memory up to this many bytes and spooled
+	// to temporary files beyond it
+	UploadMemoryThreshold int64 `mapstructure:"UPLOAD_MEMORY_THRESHOLD"`
+
 	// Analysis settings
 	AnalysisTimeout int `mapstructure:"ANALYSIS_TIMEOUT"`
+	RequestTimeout  int `mapstructure:"REQUEST_TIMEOUT"`
+
+	// Bounds, in seconds, that a per-request analysis timeout is clamped to
+	MinAnalysisTimeout int `mapstructure:"MIN_ANALYSIS_TIMEOUT"`
+	MaxAnalysisTimeout int `mapstructure:"MAX_ANALYSIS_TIMEOUT"`
+
+	// Number of Fiji analyses run at once and how many more may wait for a worker
+	MaxWorkers int `mapstructure:"MAX_CONCURRENT_ANALYSES"`
+	QueueSize  int `mapstructure:"ANALYSIS_QUEUE_SIZE"`
+
+	// Per client token bucket for analysis submissions: requests per second,
+	// or per minute when RateLimitPerMinute is set, and burst size (a
+	// non-positive rate disables limiting)
+	RateLimitRPS       float64 `mapstructure:"RATE_LIMIT_RPS"`
+	RateLimitPerMinute float64 `mapstructure:"RATE_LIMIT_PER_MINUTE"`
+	RateLimitBurst     int     `mapstructure:"RATE_LIMIT_BURST"`
+
+	// Reverse proxies, as IPs or CIDR ranges, whose X-Forwarded-For header is
+	// believed when identifying clients. With none, the peer address is used.
+	TrustedProxies []string `mapstructure:"TRUSTED_PROXIES"`
+
+	// Maximum number of images accepted from one batch archive
+	MaxBatchSize int `mapstructure:"MAX_BATCH_SIZE"`
+
+	// Kill Fiji when the macro prints no heartbeat within this many seconds (0 disables)
+	HeartbeatTimeout int `mapstructure:"FIJI_HEARTBEAT_TIMEOUT"`
+
+	// Relaunch Fiji up to this many times when it fails before running the macro
+	FijiMaxRetries int `mapstructure:"FIJI_MAX_RETRIES"`
+
+	// Re-enqueue an analysis up to this many times when Fiji crashes or stalls
+	MaxRetries int `mapstructure:"ANALYSIS_MAX_RETRIES"`
+
+	// Run Fiji under resource limits (Linux only): CPU seconds, address space in
+	// MB (0 leaves a limit unset) and an isolated network namespace
+	FijiSandbox           bool `mapstructure:"FIJI_SANDBOX"`
+	FijiSandboxCPUSeconds int  `mapstructure:"FIJI_SANDBOX_CPU_SECONDS"`
+	FijiSandboxMemoryMB   int  `mapstructure:"FIJI_SANDBOX_MEMORY_MB"`
+	FijiSandboxNoNetwork  bool `mapstructure:"FIJI_SANDBOX_NO_NETWORK"`
+
+	// Warn when an upload looks like a document scan rather than a sample
+	DocumentCheck bool `mapstructure:"DOCUMENT_CHECK"`
+
+	// Substitute estimates, flagged as such, when Fiji does not report purity,
+	// particle count or threshold instead of failing the analysis
+	AllowEstimatedResults bool `mapstructure:"ALLOW_ESTIMATED_RESULTS"`
+
+	// Report nearest-neighbor spacing between particle centroids
+	ComputeSpacing bool `mapstructure:"COMPUTE_SPACING"`
+
+	// Reuse the result of an earlier analysis of a byte-identical image
+	DeduplicateImages bool `mapstructure:"DEDUPLICATE_IMAGES"`
+
+	// Allow WebSocket clients to subscribe to every analysis instead of specific IDs
+	WSAllowAll bool `mapstructure:"WS_ALLOW_ALL"`
+
+	// Maximum number of export/artifact requests generated concurrently
+	MaxConcurrentExports int `mapstructure:"MAX_CONCURRENT_EXPORTS"`
+
+	// Finished results kept in memory are deleted RESULT_TTL seconds after they
+	// finish, checked every ResultReapInterval seconds (0 keeps them forever).
+	// Results stored in PostgreSQL are never reaped.
+	ResultTTL          int `mapstructure:"RESULT_TTL"`
+	ResultReapInterval int `mapstructure:"RESULT_REAP_INTERVAL"`
+
+	// An upload retried with the same Idempotency-Key within
+	// IdempotencyKeyTTL seconds returns the original analysis instead of
+	// starting another (0 ignores the header)
+	IdempotencyKeyTTL int `mapstructure:"IDEMPOTENCY_KEY_TTL"`
+
+	// Chunked uploads that receive no chunk for UploadTTL seconds are
+	// abandoned and their chunks deleted
+	UploadTTL int `mapstructure:"UPLOAD_TTL"`
+
+	// At most MaxOpenUploads chunked uploads may be open at once, and
+	// MaxOpenUploadsPerClient for any one client (0 is unbounded)
+	MaxOpenUploads          int `mapstructure:"MAX_OPEN_UPLOADS"`
+	MaxOpenUploadsPerClient int `mapstructure:"MAX_OPEN_UPLOADS_PER_CLIENT"`
+
+	// Keep uploaded images after their analysis finishes, for debugging. Files
+	// in TempDir older than TempFileTTL seconds that no unfinished analysis
+	// needs are swept at startup and every TempCleanupInterval seconds (a zero
+	// TempFileTTL disables the sweep).
+	KeepImages          bool `mapstructure:"KEEP_IMAGES"`
+	TempFileTTL         int  `mapstructure:"TEMP_FILE_TTL"`
+	TempCleanupInterval int  `mapstructure:"TEMP_CLEANUP_INTERVAL"`
+
+	// Branding of PDF lab reports: organization name and an optional PNG or
+	// JPEG logo
+	ReportOrganization string `mapstructure:"REPORT_ORGANIZATION"`
+	ReportLogoPath     string `mapstructure:"REPORT_LOGO_PATH"`
+
+	// Absolute URL clients reach the API at, such as https://gypsum.example.com,
+	// for links that leave the request. Links are relative when it is unset.
+	PublicBaseURL string `mapstructure:"PUBLIC_BASE_URL"`
+
+	// Write a JSON manifest next to the image when an analysis completes
+	ManifestSidecar bool `mapstructure:"MANIFEST_SIDECAR"`
+
+	// Versioned HMAC secrets, as version:secret, that completed results are
+	// signed with. The first signs new results; the others only verify results
+	// signed before a rotation. Results are not signed while this is empty.
+	ResultSigningKeys []string `mapstructure:"RESULT_SIGNING_KEYS"`
+
+	// Significant figures used for SI exports unless a request asks otherwise
+	ExportSignificantFigures int `mapstructure:"EXPORT_SIGNIFICANT_FIGURES"`
+
+	// Maximum number of analyses plotted on a purity trend
+	TrendMaxPoints int `mapstructure:"TREND_MAX_POINTS"`
+
+	// Split images larger than TileThresholdPixels into TileSize-pixel tiles that
+	// overlap by TileOverlap pixels and analyze each tile separately (0 disables)
+	TileThresholdPixels int `mapstructure:"TILE_THRESHOLD_PIXELS"`
+	TileSize            int `mapstructure:"TILE_SIZE"`
+	TileOverlap         int `mapstructure:"TILE_OVERLAP"`
+	// Number of tiles of one image Fiji analyzes at once
+	TileConcurrency int `mapstructure:"TILE_CONCURRENCY"`
+
+	// Preprocessing and threshold defaults of the analysis macro; requests may
+	// override each of them
+	MacroGaussianSigma      float64 `mapstructure:"MACRO_GAUSSIAN_SIGMA"`
+	MacroContrastSaturation float64 `mapstructure:"MACRO_CONTRAST_SATURATION"`
+	MacroThresholdMethod    string  `mapstructure:"MACRO_THRESHOLD_METHOD"`
+
+	// Named analysis profiles selectable per upload
+	Profiles map[string]ProfileConfig `mapstructure:"PROFILES"`
+
+	// Shadow mode runs the native analyzer alongside Fiji and flags discrepancies
+	ShadowMode      bool    `mapstructure:"SHADOW_MODE"`
+	ShadowTolerance float64 `mapstructure:"SHADOW_TOLERANCE"`
+}
+
+// ProfileConfig holds defaults applied to every analysis submitted with the profile.
+// Per-request values take precedence.
+type ProfileConfig struct {
+	CallbackURL string            `mapstructure:"callback_url"`
+	Metadata    map[string]string `mapstructure:"metadata"`
 }
 
 // Load reads configuration from file or environment variables
@@ -28,53 +239,570 @@ func Load() (*Config, error) {
 	viper.SetConfigType("yaml")
 	viper.AddConfigPath(".")
 	viper.AddConfigPath("./config")
-	
+
 	// Set default values
 	setDefaults()
-	
+
 	// Read environment variables
 	viper.AutomaticEnv()
-	
+
 	// Read config file if it exists
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
 			return nil, fmt.Errorf("failed to read config file: %w", err)
 		}
 	}
-	
+
 	var config Config
 	if err := viper.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal config: %w", err)
 	}
-	
+
 	// Validate configuration
 	if err := validateConfig(&config); err != nil {
 		return nil, fmt.Errorf("invalid configuration: %w", err)
 	}
-	
+
 	return &config, nil
 }
 
 func setDefaults() {
 	viper.SetDefault("ENVIRONMENT", "development")
 	viper.SetDefault("PORT", "8080")
+	viper.SetDefault("GRPC_PORT", "9090")
+	viper.SetDefault("TLS_ENABLED", false)
+	viper.SetDefault("TLS_DOMAIN", "")
+	viper.SetDefault("TLS_CERT_DIR", "/var/lib/gypsum-analysis/certs")
 	viper.SetDefault("LOG_LEVEL", "info")
+	viper.SetDefault("OTLP_ENDPOINT", "")
+	viper.SetDefault("OTLP_INSECURE", false)
+	viper.SetDefault("DATABASE_URL", "")
+	viper.SetDefault("DATABASE_MAX_CONNS", 10)
+	viper.SetDefault("AUTH_ENABLED", true)
+	viper.SetDefault("JWT_SECRET", "")
+	viper.SetDefault("JWT_ISSUER", "")
+	viper.SetDefault("JWT_EXPIRY", 86400) // 1 day
+	viper.SetDefault("CORS_ALLOWED_ORIGINS", []string{"*"})
+	viper.SetDefault("CORS_ALLOWED_METHODS", []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"})
+	viper.SetDefault("CORS_ALLOW_CREDENTIALS", false)
 	viper.SetDefault("FIJI_PATH", "/opt/fiji/Fiji.app/ImageJ-linux64")
 	viper.SetDefault("TEMP_DIR", "/tmp/gypsum-analysis")
-	viper.SetDefault("MAX_FILE_SIZE", 50*1024*1024) // 50MB
-	viper.SetDefault("ANALYSIS_TIMEOUT", 300) // 5 minutes
+	viper.SetDefault("STORAGE_BACKEND", "local")
+	viper.SetDefault("S3_BUCKET", "")
+	viper.SetDefault("S3_REGION", "")
+	viper.SetDefault("S3_ENDPOINT", "")
+	viper.SetDefault("S3_FORCE_PATH_STYLE", false)
+	viper.SetDefault("REDIS_ADDR", "")
+	viper.SetDefault("REDIS_TTL_SECONDS", 60)
+	viper.SetDefault("MAX_FILE_SIZE", 50*1024*1024)          // 50MB
+	viper.SetDefault("MAX_IMAGE_PIXELS", 250_000_000)        // 250 megapixels
+	viper.SetDefault("UPLOAD_MEMORY_THRESHOLD", 2*1024*1024) // 2MB
+	viper.SetDefault("ANALYSIS_TIMEOUT", 300)                // 5 minutes
+	viper.SetDefault("REQUEST_TIMEOUT", 60)                  // 1 minute
+	viper.SetDefault("MIN_ANALYSIS_TIMEOUT", 10)
+	viper.SetDefault("MAX_ANALYSIS_TIMEOUT", 600) // 10 minutes
+	viper.SetDefault("MAX_CONCURRENT_ANALYSES", 4)
+	viper.SetDefault("ANALYSIS_QUEUE_SIZE", 50)
+	viper.SetDefault("RATE_LIMIT_RPS", 1.0)
+	viper.SetDefault("RATE_LIMIT_PER_MINUTE", 0) // use RATE_LIMIT_RPS
+	viper.SetDefault("RATE_LIMIT_BURST", 5)
+	viper.SetDefault("TRUSTED_PROXIES", []string{})
+	viper.SetDefault("MAX_BATCH_SIZE", 100)
+	viper.SetDefault("FIJI_HEARTBEAT_TIMEOUT", 0) // disabled
+	viper.SetDefault("ANALYSIS_ENGINE", EngineAuto)
+	viper.SetDefault("FIJI_MAX_RETRIES", 2)
+	viper.SetDefault("ANALYSIS_MAX_RETRIES", 2)
+	viper.SetDefault("FIJI_SANDBOX", false)
+	viper.SetDefault("FIJI_SANDBOX_CPU_SECONDS", 600)
+	viper.SetDefault("FIJI_SANDBOX_MEMORY_MB", 8192)
+	viper.SetDefault("FIJI_SANDBOX_NO_NETWORK", true)
+	viper.SetDefault("DOCUMENT_CHECK", false)
+	viper.SetDefault("ALLOW_ESTIMATED_RESULTS", false)
+	viper.SetDefault("COMPUTE_SPACING", false)
+	viper.SetDefault("DEDUPLICATE_IMAGES", false)
+	viper.SetDefault("WS_ALLOW_ALL", false)
+	viper.SetDefault("MAX_CONCURRENT_EXPORTS", 4)
+	viper.SetDefault("RESULT_TTL", 86400)          // 1 day
+	viper.SetDefault("RESULT_REAP_INTERVAL", 300)  // 5 minutes
+	viper.SetDefault("IDEMPOTENCY_KEY_TTL", 86400) // 1 day
+	viper.SetDefault("UPLOAD_TTL", 3600)           // 1 hour
+	viper.SetDefault("MAX_OPEN_UPLOADS", 100)
+	viper.SetDefault("MAX_OPEN_UPLOADS_PER_CLIENT", 5)
+	viper.SetDefault("KEEP_IMAGES", false)
+	viper.SetDefault("TEMP_FILE_TTL", 86400)        // 1 day
+	viper.SetDefault("TEMP_CLEANUP_INTERVAL", 3600) // 1 hour
+	viper.SetDefault("REPORT_ORGANIZATION", "Gypsum Analysis Laboratory")
+	viper.SetDefault("REPORT_LOGO_PATH", "")
+	viper.SetDefault("PUBLIC_BASE_URL", "")
+	viper.SetDefault("MANIFEST_SIDECAR", false)
+	viper.SetDefault("RESULT_SIGNING_KEYS", []string{})
+	viper.SetDefault("TREND_MAX_POINTS", 1000)
+	viper.SetDefault("EXPORT_SIGNIFICANT_FIGURES", 4)
+	viper.SetDefault("TILE_THRESHOLD_PIXELS", 0) // disabled
+	viper.SetDefault("TILE_SIZE", 2048)
+	viper.SetDefault("TILE_OVERLAP", 64)
+	viper.SetDefault("TILE_CONCURRENCY", 2)
+	viper.SetDefault("MACRO_GAUSSIAN_SIGMA", 1.0)
+	viper.SetDefault("MACRO_CONTRAST_SATURATION", 0.35) // percent of pixels
+	viper.SetDefault("MACRO_THRESHOLD_METHOD", "Otsu")
+	viper.SetDefault("SHADOW_MODE", false)
+	viper.SetDefault("SHADOW_TOLERANCE", 10.0) // percentage points
 }
 
 func validateConfig(config *Config) error {
-	// Check if Fiji executable exists
-	if _, err := os.Stat(config.FijiPath); os.IsNotExist(err) {
-		return fmt.Errorf("Fiji executable not found at %s", config.FijiPath)
+	if err := validateEngine(config); err != nil {
+		return err
 	}
-	
+
 	// Create temp directory if it doesn't exist
 	if err := os.MkdirAll(config.TempDir, 0755); err != nil {
 		return fmt.Errorf("failed to create temp directory: %w", err)
 	}
-	
+
+	if config.GRPCPort != "" && config.GRPCPort == config.Port {
+		return fmt.Errorf("GRPC_PORT must differ from PORT, both are %s", config.Port)
+	}
+
+	if err := validateTLS(config); err != nil {
+		return err
+	}
+
+	if config.OTLPEndpoint != "" && strings.Contains(config.OTLPEndpoint, "://") {
+		return fmt.Errorf("OTLP_ENDPOINT must be host:port without a scheme, got %s", config.OTLPEndpoint)
+	}
+
+	if err := validateAuth(config); err != nil {
+		return err
+	}
+
+	if err := validateStorage(config); err != nil {
+		return err
+	}
+
+	if config.UploadMemoryThreshold < 0 {
+		return fmt.Errorf("UPLOAD_MEMORY_THRESHOLD must not be negative, got %d", config.UploadMemoryThreshold)
+	}
+
+	if err := validateCORS(config); err != nil {
+		return err
+	}
+
+	if config.ResultTTL < 0 {
+		return fmt.Errorf("RESULT_TTL must not be negative, got %d", config.ResultTTL)
+	}
+	if config.ResultTTL > 0 && config.ResultReapInterval < 1 {
+		return fmt.Errorf("RESULT_REAP_INTERVAL must be at least 1 when RESULT_TTL is set, got %d", config.ResultReapInterval)
+	}
+	if config.MaxImagePixels < 0 {
+		return fmt.Errorf("MAX_IMAGE_PIXELS must not be negative, got %d", config.MaxImagePixels)
+	}
+	if config.IdempotencyKeyTTL < 0 {
+		return fmt.Errorf("IDEMPOTENCY_KEY_TTL must not be negative, got %d", config.IdempotencyKeyTTL)
+	}
+	if config.UploadTTL < 1 {
+		return fmt.Errorf("UPLOAD_TTL must be at least 1, got %d", config.UploadTTL)
+	}
+	if config.MaxOpenUploads < 0 || config.MaxOpenUploadsPerClient < 0 {
+		return fmt.Errorf("MAX_OPEN_UPLOADS and MAX_OPEN_UPLOADS_PER_CLIENT must not be negative, got %d and %d", config.MaxOpenUploads, config.MaxOpenUploadsPerClient)
+	}
+	if config.RedisAddr != "" && config.RedisTTLSeconds < 1 {
+		return fmt.Errorf("REDIS_TTL_SECONDS must be at least 1 when REDIS_ADDR is set, got %d", config.RedisTTLSeconds)
+	}
+	if config.TempFileTTL < 0 {
+		return fmt.Errorf("TEMP_FILE_TTL must not be negative, got %d", config.TempFileTTL)
+	}
+	if config.TempFileTTL > 0 && config.TempCleanupInterval < 1 {
+		return fmt.Errorf("TEMP_CLEANUP_INTERVAL must be at least 1 when TEMP_FILE_TTL is set, got %d", config.TempCleanupInterval)
+	}
+
+	if config.MinAnalysisTimeout < 1 {
+		return fmt.Errorf("MIN_ANALYSIS_TIMEOUT must be at least 1, got %d", config.MinAnalysisTimeout)
+	}
+	if config.MaxAnalysisTimeout < config.MinAnalysisTimeout {
+		return fmt.Errorf("MAX_ANALYSIS_TIMEOUT must be at least MIN_ANALYSIS_TIMEOUT (%d), got %d", config.MinAnalysisTimeout, config.MaxAnalysisTimeout)
+	}
+
+	if config.MaxWorkers < 1 {
+		return fmt.Errorf("MAX_CONCURRENT_ANALYSES must be at least 1, got %d", config.MaxWorkers)
+	}
+	if config.QueueSize < 0 {
+		return fmt.Errorf("ANALYSIS_QUEUE_SIZE must not be negative, got %d", config.QueueSize)
+	}
+	if config.RateLimitPerMinute < 0 {
+		return fmt.Errorf("RATE_LIMIT_PER_MINUTE must not be negative, got %g", config.RateLimitPerMinute)
+	}
+	if config.RateLimitPerMinute > 0 {
+		config.RateLimitRPS = config.RateLimitPerMinute / 60
+	}
+	if config.RateLimitRPS > 0 && config.RateLimitBurst < 1 {
+		return fmt.Errorf("RATE_LIMIT_BURST must be at least 1 when rate limiting is enabled, got %d", config.RateLimitBurst)
+	}
+	if err := validateTrustedProxies(config); err != nil {
+		return err
+	}
+	if config.MaxBatchSize < 1 {
+		return fmt.Errorf("MAX_BATCH_SIZE must be at least 1, got %d", config.MaxBatchSize)
+	}
+	if config.FijiMaxRetries < 0 {
+		return fmt.Errorf("FIJI_MAX_RETRIES must not be negative, got %d", config.FijiMaxRetries)
+	}
+	if config.MaxRetries < 0 {
+		return fmt.Errorf("ANALYSIS_MAX_RETRIES must not be negative, got %d", config.MaxRetries)
+	}
+
+	if err := validateReportLogo(config.ReportLogoPath); err != nil {
+		return err
+	}
+	if err := validatePublicBaseURL(config.PublicBaseURL); err != nil {
+		return err
+	}
+
+	if _, err := ParseSigningKeys(config.ResultSigningKeys); err != nil {
+		return err
+	}
+
+	if err := validateSandbox(config); err != nil {
+		return err
+	}
+
+	if err := validateTiling(config); err != nil {
+		return err
+	}
+
+	if err := validateMacro(config); err != nil {
+		return err
+	}
+
+	return validateProfiles(config.Profiles)
+}
+
+// validateTLS checks the automatic certificate settings and creates the
+// certificate cache directory
+func validateTLS(config *Config) error {
+	if !config.TLSEnabled {
+		return nil
+	}
+	if config.TLSDomain == "" {
+		return fmt.Errorf("TLS_DOMAIN must be set when TLS_ENABLED is set")
+	}
+	if strings.ContainsAny(config.TLSDomain, ":/") {
+		return fmt.Errorf("TLS_DOMAIN must be a bare host name, got %s", config.TLSDomain)
+	}
+	if config.Port == "80" || config.GRPCPort == "80" {
+		return fmt.Errorf("port 80 is reserved for ACME challenges and HTTPS redirects when TLS_ENABLED is set")
+	}
+	if config.TLSCertDir == "" {
+		return fmt.Errorf("TLS_CERT_DIR must be set when TLS_ENABLED is set")
+	}
+	if err := os.MkdirAll(config.TLSCertDir, 0700); err != nil {
+		return fmt.Errorf("failed to create certificate directory: %w", err)
+	}
+	return nil
+}
+
+// validateStorage checks that the storage backend is known and that S3
+// storage names a bucket and a well-formed endpoint
+func validateStorage(config *Config) error {
+	switch config.StorageBackend {
+	case "local":
+		return nil
+	case "s3":
+	default:
+		return fmt.Errorf("STORAGE_BACKEND must be local or s3, got %s", config.StorageBackend)
+	}
+	if config.S3Bucket == "" {
+		return fmt.Errorf("S3_BUCKET must be set when STORAGE_BACKEND is s3")
+	}
+	if config.S3Endpoint != "" {
+		parsed, err := url.Parse(config.S3Endpoint)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return fmt.Errorf("S3_ENDPOINT must be an http(s) URL, got %s", config.S3Endpoint)
+		}
+	}
+	return nil
+}
+
+// validateCORS checks that allowed origins are "*" or scheme://host[:port]
+// and that credentials are only allowed for listed origins
+func validateCORS(config *Config) error {
+	if len(config.CORSAllowedOrigins) == 0 {
+		return fmt.Errorf("CORS_ALLOWED_ORIGINS must list at least one origin")
+	}
+	if len(config.CORSAllowedMethods) == 0 {
+		return fmt.Errorf("CORS_ALLOWED_METHODS must list at least one method")
+	}
+	for _, origin := range config.CORSAllowedOrigins {
+		if origin == "*" {
+			if config.CORSAllowCredentials {
+				return fmt.Errorf("CORS_ALLOWED_ORIGINS must not contain * when CORS_ALLOW_CREDENTIALS is set")
+			}
+			continue
+		}
+		parsed, err := url.Parse(origin)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" || parsed.Path != "" || parsed.RawQuery != "" {
+			return fmt.Errorf("CORS_ALLOWED_ORIGINS entries must be * or http(s)://host[:port], got %s", origin)
+		}
+	}
+	return nil
+}
+
+// validateTrustedProxies checks that trusted proxies are IPs or CIDR ranges
+func validateTrustedProxies(config *Config) error {
+	for _, proxy := range config.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return fmt.Errorf("TRUSTED_PROXIES entries must be IPs or CIDR ranges, got %q", proxy)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTiling rejects tile layouts that would leave gaps or never advance
+func validateTiling(config *Config) error {
+	if config.TileThresholdPixels <= 0 {
+		return nil
+	}
+	if config.TileSize <= 0 {
+		return fmt.Errorf("TILE_SIZE must be positive, got %d", config.TileSize)
+	}
+	if config.TileOverlap < 0 || config.TileOverlap*2 >= config.TileSize {
+		return fmt.Errorf("TILE_OVERLAP must be between 0 and half of TILE_SIZE, got %d", config.TileOverlap)
+	}
+	if config.TileConcurrency < 1 {
+		return fmt.Errorf("TILE_CONCURRENCY must be at least 1, got %d", config.TileConcurrency)
+	}
+	return nil
+}
+
+// validateMacro checks the macro defaults and stores the canonical spelling
+// of the threshold method
+func validateMacro(config *Config) error {
+	if config.MacroGaussianSigma < 0 || config.MacroGaussianSigma > MaxGaussianSigma {
+		return fmt.Errorf("MACRO_GAUSSIAN_SIGMA must be between 0 and %g, got %g", MaxGaussianSigma, config.MacroGaussianSigma)
+	}
+	if config.MacroContrastSaturation < 0 || config.MacroContrastSaturation >= 100 {
+		return fmt.Errorf("MACRO_CONTRAST_SATURATION must be a percentage below 100, got %g", config.MacroContrastSaturation)
+	}
+	if config.MacroThresholdMethod != "" {
+		method, ok := ThresholdMethod(config.MacroThresholdMethod)
+		if !ok {
+			return fmt.Errorf("MACRO_THRESHOLD_METHOD must be one of %s, got %s", strings.Join(ThresholdMethods, ", "), config.MacroThresholdMethod)
+		}
+		config.MacroThresholdMethod = method
+	}
+	return nil
+}
+
+// MaxGaussianSigma is the strongest blur accepted, in pixels; stronger blurs
+// merge neighbouring grains
+const MaxGaussianSigma = 20.0
+
+// ThresholdMethods are the ImageJ auto-threshold methods accepted in
+// configuration and requests
+var ThresholdMethods = []string{
+	"Default", "Huang", "Intermodes", "IsoData", "Li", "MaxEntropy", "Mean", "MinError",
+	"Minimum", "Moments", "Otsu", "Percentile", "RenyiEntropy", "Shanbhag", "Triangle", "Yen",
+}
+
+// ThresholdMethod returns the canonical spelling of an ImageJ threshold
+// method, matched case-insensitively
+func ThresholdMethod(name string) (string, bool) {
+	for _, method := range ThresholdMethods {
+		if strings.EqualFold(method, name) {
+			return method, true
+		}
+	}
+	return "", false
+}
+
+// validateReportLogo checks that a configured report logo is a readable PNG or JPEG file
+func validateReportLogo(path string) error {
+	if path == "" {
+		return nil
+	}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg":
+	default:
+		return fmt.Errorf("REPORT_LOGO_PATH must be a PNG or JPEG file, got %s", path)
+	}
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("REPORT_LOGO_PATH is not readable: %w", err)
+	}
+	return nil
+}
+
+// validatePublicBaseURL checks that a configured public base URL is an
+// absolute http(s) URL without a query or fragment
+func validatePublicBaseURL(rawURL string) error {
+	if rawURL == "" {
+		return nil
+	}
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("PUBLIC_BASE_URL is invalid: %w", err)
+	}
+	if (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return fmt.Errorf("PUBLIC_BASE_URL must be an absolute http or https URL, got %q", rawURL)
+	}
+	if parsed.RawQuery != "" || parsed.Fragment != "" {
+		return fmt.Errorf("PUBLIC_BASE_URL must not have a query or fragment, got %q", rawURL)
+	}
 	return nil
 }
+
+// validateAuth refuses to enable authentication without a secret to verify
+// tokens with, which would otherwise reject every request
+func validateAuth(config *Config) error {
+	if config.AuthEnabled && config.JWTSecret == "" {
+		return fmt.Errorf("JWT_SECRET is required while AUTH_ENABLED is true; set AUTH_ENABLED=false to serve the API without authentication")
+	}
+	if config.JWTExpiry < 0 {
+		return fmt.Errorf("JWT_EXPIRY must not be negative, got %d", config.JWTExpiry)
+	}
+	return nil
+}
+
+// Analysis engines accepted by ANALYSIS_ENGINE
+const (
+	EngineAuto   = "auto"
+	EngineFiji   = "fiji"
+	EngineNative = "native"
+)
+
+// validateEngine resolves EngineAuto to Fiji when its executable exists and
+// to the native engine otherwise, and rejects unknown engines and a missing
+// Fiji executable when Fiji was chosen explicitly
+func validateEngine(config *Config) error {
+	switch config.AnalysisEngine {
+	case EngineAuto:
+		config.AnalysisEngine = EngineFiji
+		if _, err := os.Stat(config.FijiPath); os.IsNotExist(err) {
+			config.AnalysisEngine = EngineNative
+			config.EngineFallback = true
+		}
+		return nil
+	case EngineFiji:
+		if _, err := os.Stat(config.FijiPath); os.IsNotExist(err) {
+			return fmt.Errorf("Fiji executable not found at %s; set ANALYSIS_ENGINE=%s to analyze without Fiji", config.FijiPath, EngineNative)
+		}
+		return nil
+	case EngineNative:
+		return nil
+	default:
+		return fmt.Errorf("ANALYSIS_ENGINE must be %s, %s or %s, got %q", EngineAuto, EngineFiji, EngineNative, config.AnalysisEngine)
+	}
+}
+
+// validateSandbox rejects sandbox settings that cannot be applied
+func validateSandbox(config *Config) error {
+	if !config.FijiSandbox {
+		return nil
+	}
+	if runtime.GOOS != "linux" {
+		return fmt.Errorf("FIJI_SANDBOX is only supported on Linux")
+	}
+	if config.FijiSandboxCPUSeconds < 0 || config.FijiSandboxMemoryMB < 0 {
+		return fmt.Errorf("sandbox limits must not be negative")
+	}
+
+	return nil
+}
+
+// validateProfiles checks the default callback URL of every profile
+func validateProfiles(profiles map[string]ProfileConfig) error {
+	for name, profile := range profiles {
+		if profile.CallbackURL == "" {
+			continue
+		}
+		if err := ValidateCallbackURL(profile.CallbackURL); err != nil {
+			return fmt.Errorf("profile %q: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// ErrNonPublicAddress is returned for callback and webhook targets on
+// loopback, private, link-local or other internal addresses
+var ErrNonPublicAddress = errors.New("address is not public")
+
+// ValidateCallbackURL ensures a callback or webhook URL is an absolute http or
+// https URL that does not name localhost or an internal address. Host names
+// are checked again once resolved, when the request is made.
+func ValidateCallbackURL(rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback URL: %w", err)
+	}
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("callback URL must use http or https, got %q", parsed.Scheme)
+	}
+	if parsed.Host == "" {
+		return fmt.Errorf("callback URL must include a host")
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("callback URL host is not allowed: %w: %s", ErrNonPublicAddress, host)
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		if err := CheckPublicAddress(addr); err != nil {
+			return fmt.Errorf("callback URL host is not allowed: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// CheckPublicAddress rejects loopback, private, link-local, unspecified and
+// multicast addresses, which callbacks and webhooks may not be sent to
+func CheckPublicAddress(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsLinkLocalUnicast() || addr.IsUnspecified() || addr.IsMulticast() {
+		return fmt.Errorf("%w: %s", ErrNonPublicAddress, addr)
+	}
+	return nil
+}
+
+// PublicDialControl is a net.Dialer Control function that refuses to connect
+// to the addresses CheckPublicAddress rejects. It runs on the resolved
+// address, so host names resolving to internal addresses are refused too.
+func PublicDialControl(network, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("unexpected dial address %q: %w", address, err)
+	}
+	return CheckPublicAddress(addrPort.Addr())
+}
+
+// ClampAnalysisTimeout limits a requested analysis timeout in seconds to
+// MIN_ANALYSIS_TIMEOUT and MAX_ANALYSIS_TIMEOUT
+func (c *Config) ClampAnalysisTimeout(seconds int) int {
+	return min(max(seconds, c.MinAnalysisTimeout), c.MaxAnalysisTimeout)
+}
+
+// SigningKey is a versioned secret that completed results are signed with
+type SigningKey struct {
+	Version string
+	Secret  string
+}
+
+// ParseSigningKeys parses RESULT_SIGNING_KEYS entries of the form
+// version:secret, keeping their order
+func ParseSigningKeys(entries []string) ([]SigningKey, error) {
+	keys := make([]SigningKey, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		version, secret, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || version == "" || secret == "" {
+			return nil, fmt.Errorf("RESULT_SIGNING_KEYS entries must be version:secret")
+		}
+		if seen[version] {
+			return nil, fmt.Errorf("RESULT_SIGNING_KEYS has more than one key for version %s", version)
+		}
+		seen[version] = true
+		keys = append(keys, SigningKey{Version: version, Secret: secret})
+	}
+	return keys, nil
+}
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateCallbackURL(t *testing.T) {
+	assert.NoError(t, ValidateCallbackURL("https://lims.example.com/hooks/gypsum"))
+	assert.NoError(t, ValidateCallbackURL("http://203.0.113.7:9000/callback"))
+
+	assert.Error(t, ValidateCallbackURL("ftp://example.com/upload"))
+	assert.Error(t, ValidateCallbackURL("/relative/path"))
+	assert.Error(t, ValidateCallbackURL("https://"))
+	assert.Error(t, ValidateCallbackURL("://bad"))
+
+	// Internal addresses are refused
+	for _, target := range []string{
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.5:9000/callback",
+		"http://192.168.1.20/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+	} {
+		assert.ErrorIs(t, ValidateCallbackURL(target), ErrNonPublicAddress, target)
+	}
+}
+
+func TestPublicDialControl(t *testing.T) {
+	assert.NoError(t, PublicDialControl("tcp4", "203.0.113.7:443", nil))
+	assert.ErrorIs(t, PublicDialControl("tcp4", "127.0.0.1:80", nil), ErrNonPublicAddress)
+	assert.ErrorIs(t, PublicDialControl("tcp4", "172.16.3.4:80", nil), ErrNonPublicAddress)
+	assert.ErrorIs(t, PublicDialControl("tcp6", "[fe80::1]:80", nil), ErrNonPublicAddress)
+	assert.Error(t, PublicDialControl("tcp4", "not-an-address", nil))
+}
+
+func TestValidateProfiles(t *testing.T) {
+	valid := map[string]ProfileConfig{
+		"plant-a": {CallbackURL: "https://lims.example.com/plant-a", Metadata: map[string]string{"project": "alpha"}},
+		"no-hook": {Metadata: map[string]string{"project": "beta"}},
+	}
+	assert.NoError(t, validateProfiles(valid))
+
+	invalid := map[string]ProfileConfig{
+		"broken": {CallbackURL: "file:///etc/passwd"},
+	}
+	err := validateProfiles(invalid)
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), `profile "broken"`)
+}
+
+func TestValidateAuth(t *testing.T) {
+	assert.Error(t, validateAuth(&Config{AuthEnabled: true}), "authentication cannot be enabled without a secret")
+	assert.NoError(t, validateAuth(&Config{AuthEnabled: true, JWTSecret: "secret", JWTExpiry: 3600}))
+	assert.NoError(t, validateAuth(&Config{}), "disabling authentication is explicit")
+	assert.Error(t, validateAuth(&Config{JWTExpiry: -1}))
+}
+
+func TestValidateEngine(t *testing.T) {
+	fiji := filepath.Join(t.TempDir(), "ImageJ-linux64")
+	assert.NoError(t, os.WriteFile(fiji, []byte("#!/bin/sh\n"), 0o755))
+	missing := filepath.Join(t.TempDir(), "missing")
+
+	assert.NoError(t, validateEngine(&Config{AnalysisEngine: EngineFiji, FijiPath: fiji}))
+	assert.Error(t, validateEngine(&Config{AnalysisEngine: EngineFiji, FijiPath: missing}), "a missing Fiji does not silently switch engines")
+	assert.NoError(t, validateEngine(&Config{AnalysisEngine: EngineNative, FijiPath: missing}))
+	assert.Error(t, validateEngine(&Config{AnalysisEngine: "imagej"}))
+
+	detected := &Config{AnalysisEngine: EngineAuto, FijiPath: fiji}
+	assert.NoError(t, validateEngine(detected))
+	assert.Equal(t, EngineFiji, detected.AnalysisEngine)
+	assert.False(t, detected.EngineFallback)
+
+	fallback := &Config{AnalysisEngine: EngineAuto, FijiPath: missing}
+	assert.NoError(t, validateEngine(fallback))
+	assert.Equal(t, EngineNative, fallback.AnalysisEngine)
+	assert.True(t, fallback.EngineFallback)
+}
+
+func TestValidateSandbox(t *testing.T) {
+	assert.NoError(t, validateSandbox(&Config{FijiSandboxCPUSeconds: -1}), "limits are ignored while the sandbox is off")
+
+	err := validateSandbox(&Config{FijiSandbox: true, FijiSandboxMemoryMB: -5})
+	assert.Error(t, err)
+
+	if runtime.GOOS == "linux" {
+		assert.NoError(t, validateSandbox(&Config{FijiSandbox: true, FijiSandboxCPUSeconds: 600, FijiSandboxMemoryMB: 8192}))
+	}
+}
+
+func TestValidateTLS(t *testing.T) {
+	assert.NoError(t, validateTLS(&Config{Port: "80"}), "TLS settings are ignored while TLS is off")
+
+	dir := filepath.Join(t.TempDir(), "certs")
+	assert.NoError(t, validateTLS(&Config{TLSEnabled: true, TLSDomain: "lab.example.com", TLSCertDir: dir, Port: "443"}))
+	assert.DirExists(t, dir)
+
+	assert.Error(t, validateTLS(&Config{TLSEnabled: true, TLSCertDir: dir, Port: "443"}), "no domain")
+	assert.Error(t, validateTLS(&Config{TLSEnabled: true, TLSDomain: "https://lab.example.com", TLSCertDir: dir, Port: "443"}))
+	assert.Error(t, validateTLS(&Config{TLSEnabled: true, TLSDomain: "lab.example.com", TLSCertDir: dir, Port: "80"}))
+}
+
+func TestValidateStorage(t *testing.T) {
+	assert.NoError(t, validateStorage(&Config{StorageBackend: "local"}))
+	assert.NoError(t, validateStorage(&Config{StorageBackend: "s3", S3Bucket: "images"}))
+	assert.NoError(t, validateStorage(&Config{StorageBackend: "s3", S3Bucket: "images", S3Endpoint: "http://minio:9000", S3ForcePathStyle: true}))
+
+	assert.Error(t, validateStorage(&Config{StorageBackend: "gcs"}))
+	assert.Error(t, validateStorage(&Config{StorageBackend: "s3"}), "no bucket")
+	assert.Error(t, validateStorage(&Config{StorageBackend: "s3", S3Bucket: "images", S3Endpoint: "minio:9000"}))
+}
+
+func TestValidateCORS(t *testing.T) {
+	methods := []string{"GET", "POST"}
+
+	assert.NoError(t, validateCORS(&Config{CORSAllowedOrigins: []string{"*"}, CORSAllowedMethods: methods}))
+	assert.NoError(t, validateCORS(&Config{CORSAllowedOrigins: []string{"https://lab.example.com", "http://localhost:3000"}, CORSAllowedMethods: methods, CORSAllowCredentials: true}))
+
+	assert.Error(t, validateCORS(&Config{CORSAllowedOrigins: []string{"*"}, CORSAllowedMethods: methods, CORSAllowCredentials: true}), "wildcard with credentials")
+	assert.Error(t, validateCORS(&Config{CORSAllowedMethods: methods}), "no origins")
+	assert.Error(t, validateCORS(&Config{CORSAllowedOrigins: []string{"*"}}), "no methods")
+	assert.Error(t, validateCORS(&Config{CORSAllowedOrigins: []string{"lab.example.com"}, CORSAllowedMethods: methods}))
+	assert.Error(t, validateCORS(&Config{CORSAllowedOrigins: []string{"https://lab.example.com/app"}, CORSAllowedMethods: methods}))
+}
+
+func TestValidateTrustedProxies(t *testing.T) {
+	assert.NoError(t, validateTrustedProxies(&Config{}))
+	assert.NoError(t, validateTrustedProxies(&Config{TrustedProxies: []string{"10.0.0.1", "172.16.0.0/12", "::1"}}))
+	assert.Error(t, validateTrustedProxies(&Config{TrustedProxies: []string{"proxy.internal"}}))
+	assert.Error(t, validateTrustedProxies(&Config{TrustedProxies: []string{"10.0.0.0/33"}}))
+}
+
+func TestValidateTiling(t *testing.T) {
+	assert.NoError(t, validateTiling(&Config{TileSize: 0}), "tile settings are ignored while tiling is off")
+	assert.NoError(t, validateTiling(&Config{TileThresholdPixels: 1 << 24, TileSize: 2048, TileOverlap: 64, TileConcurrency: 2}))
+
+	assert.Error(t, validateTiling(&Config{TileThresholdPixels: 1 << 24, TileSize: 0}))
+	assert.Error(t, validateTiling(&Config{TileThresholdPixels: 1 << 24, TileSize: 128, TileOverlap: 64}))
+	assert.Error(t, validateTiling(&Config{TileThresholdPixels: 1 << 24, TileSize: 128, TileOverlap: -1}))
+	assert.Error(t, validateTiling(&Config{TileThresholdPixels: 1 << 24, TileSize: 2048, TileOverlap: 64, TileConcurrency: 0}))
+}
+
+func TestValidatePublicBaseURL(t *testing.T) {
+	assert.NoError(t, validatePublicBaseURL(""))
+	assert.NoError(t, validatePublicBaseURL("https://gypsum.example.com"))
+	assert.NoError(t, validatePublicBaseURL("https://example.com/gypsum/"))
+
+	assert.Error(t, validatePublicBaseURL("gypsum.example.com"))
+	assert.Error(t, validatePublicBaseURL("ftp://gypsum.example.com"))
+	assert.Error(t, validatePublicBaseURL("https://gypsum.example.com/?x=1"))
+}
+
+func TestValidateMacro(t *testing.T) {
+	config := &Config{MacroGaussianSigma: 1, MacroContrastSaturation: 0.35, MacroThresholdMethod: "triangle"}
+	assert.NoError(t, validateMacro(config))
+	assert.Equal(t, "Triangle", config.MacroThresholdMethod)
+
+	assert.Error(t, validateMacro(&Config{MacroGaussianSigma: -1}))
+	assert.Error(t, validateMacro(&Config{MacroContrastSaturation: 100}))
+	assert.Error(t, validateMacro(&Config{MacroThresholdMethod: "Magic"}))
+}
+
+func TestValidateReportLogo(t *testing.T) {
+	assert.NoError(t, validateReportLogo(""))
+
+	logo := filepath.Join(t.TempDir(), "logo.png")
+	assert.Error(t, validateReportLogo(logo), "missing file")
+	assert.NoError(t, os.WriteFile(logo, []byte("png"), 0644))
+	assert.NoError(t, validateReportLogo(logo))
+
+	assert.Error(t, validateReportLogo(filepath.Join(t.TempDir(), "logo.svg")))
+}
+
+func TestClampAnalysisTimeout(t *testing.T) {
+	cfg := &Config{MinAnalysisTimeout: 10, MaxAnalysisTimeout: 600}
+
+	assert.Equal(t, 10, cfg.ClampAnalysisTimeout(1))
+	assert.Equal(t, 120, cfg.ClampAnalysisTimeout(120))
+	assert.Equal(t, 600, cfg.ClampAnalysisTimeout(3600))
+}
+
+func TestParseSigningKeys(t *testing.T) {
+	keys, err := ParseSigningKeys([]string{"2024-06:new-secret", " 2024-01:old:secret "})
+	assert.NoError(t, err)
+	assert.Equal(t, []SigningKey{{Version: "2024-06", Secret: "new-secret"}, {Version: "2024-01", Secret: "old:secret"}}, keys)
+
+	keys, err = ParseSigningKeys(nil)
+	assert.NoError(t, err)
+	assert.Empty(t, keys, "signing is disabled")
+
+	for _, entries := range [][]string{{"no-version"}, {":secret"}, {"v1:"}, {"v1:a", "v1:b"}} {
+		_, err := ParseSigningKeys(entries)
+		assert.Error(t, err, entries)
+	}
+}
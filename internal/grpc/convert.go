@@ -0,0 +1,180 @@
+package grpc
+
+import (
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/proto/gypsumpb"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// toProto converts an analysis result to its protobuf message. Fields the JSON
+// representation omits stay unset.
+func toProto(result *models.AnalysisResult) *gypsumpb.AnalysisResult {
+	msg := &gypsumpb.AnalysisResult{
+		Id:                        result.ID,
+		Status:                    string(result.Status),
+		CreatedAt:                 timestamp(&result.CreatedAt),
+		CompletedAt:               timestamp(result.CompletedAt),
+		Error:                     result.Error,
+		FailureCategory:           string(result.FailureCategory),
+		Progress:                  int32(result.Progress),
+		RetryCount:                int32(result.RetryCount),
+		PurityPercentage:          result.PurityPercentage,
+		Confidence:                result.Confidence,
+		ImagePath:                 result.ImagePath,
+		ImageSize:                 result.ImageSize,
+		AnalysisTimeMs:            result.AnalysisTime,
+		ImageRemoved:              result.ImageRemoved,
+		ImageSha256:               result.ImageSHA256,
+		ImageFormat:               result.ImageFormat,
+		ImageWidth:                int32(result.ImageWidth),
+		ImageHeight:               int32(result.ImageHeight),
+		PerceptualHash:            result.PerceptualHash,
+		DuplicateOf:               result.DuplicateOf,
+		GypsumContentPercentage:   result.GypsumContent,
+		ImpurityContentPercentage: result.ImpurityContent,
+		CalciteContentPercentage:  result.CalciteContent,
+		QuartzContentPercentage:   result.QuartzContent,
+		OtherMineralsPercentage:   result.OtherMinerals,
+		ThresholdValue:            result.ThresholdValue,
+		ThresholdLower:            result.ThresholdLower,
+		ThresholdUpper:            result.ThresholdUpper,
+		ParticleCount:             int32(result.ParticleCount),
+		AverageParticleSizeUm:     result.AverageParticleSize,
+		TotalArea:                 result.TotalArea,
+		ImageArea:                 result.ImageArea,
+		RoiFile:                   result.ROIFile,
+		Filename:                  result.Filename,
+		BatchId:                   result.BatchID,
+		Profile:                   result.Profile,
+		ProfileId:                 result.ProfileID,
+		AnalysisEngine:            result.AnalysisEngine,
+		SampleGroup:               result.SampleGroup,
+		CallbackUrl:               result.CallbackURL,
+		Metadata:                  result.Metadata,
+		FijiVersion:               result.FijiVersion,
+		ShadowPurityPercentage:    result.ShadowPurityPercentage,
+		Warnings:                  result.Warnings,
+		ReviewNote:                result.ReviewNote,
+		ReviewedBy:                result.ReviewedBy,
+		ReviewedAt:                timestamp(result.ReviewedAt),
+		ManualOverride:            result.ManualOverride,
+		ManualOverridePurity:      result.ManualOverridePurity,
+		TimeoutSeconds:            int32(result.TimeoutSeconds),
+		MeasurementUnit:           result.MeasurementUnit,
+		Estimated:                 result.Estimated,
+		OverlayPath:               result.OverlayPath,
+		ResultSignature:           result.ResultSignature,
+		SignatureKeyVersion:       result.SignatureKeyVersion,
+		SignedAt:                  timestamp(result.SignedAt),
+	}
+
+	if result.Calibration != nil {
+		msg.Calibration = &gypsumpb.Calibration{
+			PixelsPerMicron:  result.Calibration.PixelsPerMicron,
+			ScaleBarLengthUm: result.Calibration.ScaleBarLength,
+			ScaleBarPixels:   result.Calibration.ScaleBarPixels,
+			Source:           result.Calibration.Source,
+		}
+	}
+	if metadata := result.ImageMetadata; metadata != nil {
+		msg.ImageMetadata = &gypsumpb.ImageMetadata{
+			Make:           metadata.Make,
+			Model:          metadata.Model,
+			DateTime:       timestamp(metadata.DateTime),
+			XResolution:    metadata.XResolution,
+			YResolution:    metadata.YResolution,
+			ResolutionUnit: metadata.ResolutionUnit,
+			UserComment:    metadata.UserComment,
+		}
+	}
+	if sample := result.SampleMetadata; sample != nil {
+		msg.SampleMetadata = &gypsumpb.SampleMetadata{
+			SampleId:       sample.SampleID,
+			OperatorName:   sample.OperatorName,
+			LocationLat:    sample.LocationLat,
+			LocationLon:    sample.LocationLon,
+			SamplingDepthM: sample.SamplingDepthM,
+			Notes:          sample.Notes,
+		}
+	}
+	if region := result.ROI; region != nil {
+		msg.Roi = &gypsumpb.Rectangle{
+			X:      int32(region.X),
+			Y:      int32(region.Y),
+			Width:  int32(region.Width),
+			Height: int32(region.Height),
+		}
+	}
+	for _, roi := range result.ROIResults {
+		msg.RoiResults = append(msg.RoiResults, &gypsumpb.ROIResult{
+			Index:            int32(roi.Index),
+			Name:             roi.Name,
+			PurityPercentage: roi.PurityPercentage,
+		})
+	}
+	for _, tile := range result.Tiles {
+		msg.Tiles = append(msg.Tiles, &gypsumpb.TileResult{
+			Index:            int32(tile.Index),
+			X:                int32(tile.X),
+			Y:                int32(tile.Y),
+			Width:            int32(tile.Width),
+			Height:           int32(tile.Height),
+			ParticleCount:    int32(tile.ParticleCount),
+			TotalArea:        tile.TotalArea,
+			PurityPercentage: tile.PurityPercentage,
+		})
+	}
+	for _, slice := range result.PerSlice {
+		msg.PerSlice = append(msg.PerSlice, &gypsumpb.SliceResult{
+			Index:            int32(slice.Index),
+			PurityPercentage: slice.PurityPercentage,
+			ParticleCount:    int32(slice.ParticleCount),
+		})
+	}
+	if stats := result.SpacingStats; stats != nil {
+		msg.SpacingStats = &gypsumpb.SpacingStats{
+			ParticleCount: int32(stats.ParticleCount),
+			Mean:          stats.Mean,
+			Median:        stats.Median,
+			Min:           stats.Min,
+			Max:           stats.Max,
+			Unit:          stats.Unit,
+		}
+	}
+	if factors := result.ConfidenceFactors; factors != nil {
+		msg.ConfidenceFactors = &gypsumpb.ConfidenceFactors{
+			Base:                factors.Base,
+			ParticleCountBucket: factors.ParticleCountBucket,
+		}
+		for _, signal := range factors.Signals {
+			msg.ConfidenceFactors.Signals = append(msg.ConfidenceFactors.Signals, &gypsumpb.ConfidenceSignal{
+				Name:      signal.Name,
+				Weight:    signal.Weight,
+				Satisfied: signal.Satisfied,
+			})
+		}
+	}
+	if params := result.Parameters; params != nil {
+		msg.Parameters = &gypsumpb.MacroParams{
+			ThresholdMethod:    params.ThresholdMethod,
+			MinParticleSize:    params.MinParticleSize,
+			MaxParticleSize:    params.MaxParticleSize,
+			MinCircularity:     params.MinCircularity,
+			MaxCircularity:     params.MaxCircularity,
+			GaussianSigma:      params.GaussianSigma,
+			ContrastSaturation: params.ContrastSaturation,
+		}
+	}
+	return msg
+}
+
+// timestamp converts an optional time, leaving unset times nil
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil || t.IsZero() {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,311 @@
+package grpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+	"gypsum-analysis-api/proto/gypsumpb"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// messageOverhead leaves room for the request fields next to the image
+const messageOverhead = 1 << 20
+
+// Server implements the GypsumAnalysis gRPC service on top of the analysis
+// service used by the HTTP handlers
+type Server struct {
+	gypsumpb.UnimplementedGypsumAnalysisServer
+
+	analysisService services.AnalysisServiceInterface
+	config          *config.Config
+	logger          *logger.Logger
+}
+
+// NewServer creates a gRPC server exposing the GypsumAnalysis service and
+// server reflection. Unary calls require the same bearer tokens as /api/v1
+// while AUTH_ENABLED is set, and submissions share submitLimiter's
+// per-client budget with the REST submission endpoints; a nil submitLimiter
+// leaves them unlimited.
+// With a TLS config, connections are served over TLS like the HTTPS server;
+// otherwise they are plaintext.
+func NewServer(analysisService services.AnalysisServiceInterface, cfg *config.Config, logger *logger.Logger, tlsConfig *tls.Config, submitLimiter *middleware.RateLimiter) *grpc.Server {
+	var interceptors []grpc.UnaryServerInterceptor
+	if cfg.AuthEnabled {
+		interceptors = append(interceptors, authInterceptor(cfg.JWTSecret, cfg.JWTIssuer, time.Duration(cfg.JWTExpiry)*time.Second))
+	}
+	// Runs after authentication so that callers are keyed by token subject
+	if submitLimiter != nil {
+		interceptors = append(interceptors, rateLimitInterceptor(submitLimiter))
+	}
+	options := []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(interceptors...),
+	}
+	if tlsConfig != nil {
+		options = append(options, grpc.Creds(credentials.NewTLS(tlsConfig.Clone())))
+	}
+	if cfg.MaxFileSize > 0 {
+		options = append(options, grpc.MaxRecvMsgSize(int(cfg.MaxFileSize)+messageOverhead))
+	}
+
+	server := grpc.NewServer(options...)
+	gypsumpb.RegisterGypsumAnalysisServer(server, &Server{
+		analysisService: analysisService,
+		config:          cfg,
+		logger:          logger,
+	})
+	reflection.Register(server)
+	return server
+}
+
+// AnalyzeGypsum queues an analysis of the uploaded image
+func (s *Server) AnalyzeGypsum(ctx context.Context, req *gypsumpb.AnalyzeRequest) (*gypsumpb.AnalyzeResponse, error) {
+	if len(req.GetImage()) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "No image provided")
+	}
+	if s.config.MaxFileSize > 0 && int64(len(req.GetImage())) > s.config.MaxFileSize {
+		return nil, status.Errorf(codes.InvalidArgument, "File exceeds the maximum size of %d bytes", s.config.MaxFileSize)
+	}
+	if !services.IsSupportedImage(req.GetFilename()) {
+		return nil, status.Error(codes.InvalidArgument, "Unsupported file type. Please upload JPG, PNG, TIFF, WebP, BMP or DNG images")
+	}
+
+	opts, err := s.analysisOptions(req)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	opts.Owner = clientKey(ctx)
+
+	file, err := fileHeader(req.GetFilename(), req.GetImage())
+	if err != nil {
+		s.logger.WithError(err).Error("Failed to buffer uploaded image")
+		return nil, status.Error(codes.Internal, "Failed to read image")
+	}
+
+	analysisID := uuid.New().String()
+
+	// Queue the analysis; the upload is saved before this returns
+	if err := s.analysisService.SubmitAnalysis(ctx, analysisID, file, opts); err != nil {
+		switch {
+		case errors.Is(err, services.ErrImageTypeMismatch):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, services.ErrUnsupportedMIME):
+			return nil, status.Error(codes.InvalidArgument, "File content is not a JPG, PNG, TIFF, WebP, BMP or DNG image")
+		case errors.Is(err, services.ErrROIOutOfBounds), errors.Is(err, services.ErrImageTooLarge), errors.Is(err, services.ErrDNGPreview), errors.Is(err, services.ErrInvalidParticleFilter):
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		case errors.Is(err, services.ErrProfileNotFound):
+			return nil, status.Error(codes.InvalidArgument, "Unknown profile_id: "+opts.ProfileID)
+		case errors.Is(err, services.ErrQueueFull):
+			return nil, status.Error(codes.ResourceExhausted, "Too many analyses in progress, please retry later")
+		case errors.Is(err, services.ErrShuttingDown):
+			return nil, status.Error(codes.Unavailable, "Server is shutting down, please retry later")
+		}
+		s.logger.WithError(err).WithField("analysis_id", analysisID).Error("Failed to start analysis")
+		return nil, status.Error(codes.Internal, "Failed to start analysis")
+	}
+
+	return &gypsumpb.AnalyzeResponse{
+		AnalysisId: analysisID,
+		Status:     "processing",
+	}, nil
+}
+
+// GetStatus returns the current result of an analysis
+func (s *Server) GetStatus(ctx context.Context, req *gypsumpb.StatusRequest) (*gypsumpb.AnalysisResult, error) {
+	result, err := s.analysisService.GetAnalysisStatus(req.GetAnalysisId())
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		return nil, status.Error(codes.NotFound, "Analysis not found")
+	case err != nil:
+		s.logger.WithError(err).WithField("analysis_id", req.GetAnalysisId()).Error("Failed to get analysis status")
+		return nil, status.Error(codes.Internal, "Failed to retrieve analysis result")
+	}
+	return toProto(result), nil
+}
+
+// analysisOptions validates the optional submission settings like the form
+// fields of the HTTP upload
+func (s *Server) analysisOptions(req *gypsumpb.AnalyzeRequest) (services.AnalysisOptions, error) {
+	opts := services.AnalysisOptions{
+		Profile:         req.GetProfile(),
+		SampleGroup:     req.GetSampleGroup(),
+		CallbackURL:     req.GetCallbackUrl(),
+		Metadata:        req.GetMetadata(),
+		ThresholdMethod: req.GetThresholdMethod(),
+		MinParticleSize: req.MinParticleSize,
+		MaxParticleSize: req.MaxParticleSize,
+		MinCircularity:  req.MinCircularity,
+	}
+	opts.GaussianSigma = req.GaussianSigma
+	opts.ContrastSaturation = req.ContrastSaturation
+	opts.ProfileID = req.GetProfileId()
+	if opts.Profile != "" {
+		if _, ok := s.config.Profiles[opts.Profile]; !ok {
+			return opts, fmt.Errorf("Unknown profile: %s", opts.Profile)
+		}
+	}
+	if opts.CallbackURL != "" {
+		if err := config.ValidateCallbackURL(opts.CallbackURL); err != nil {
+			return opts, err
+		}
+	}
+
+	if ppm := req.GetPixelsPerMicron(); ppm != 0 {
+		if !(ppm > 0) || math.IsInf(ppm, 1) {
+			return opts, errors.New("pixels_per_micron must be a positive number")
+		}
+		opts.PixelsPerMicron = ppm
+	}
+	opts.ScaleBarLength = req.GetScaleBarLength()
+	opts.ScaleBarPixels = req.GetScaleBarPixels()
+	if _, err := opts.Calibration(); err != nil {
+		return opts, err
+	}
+
+	if sample := req.GetSampleMetadata(); sample != nil {
+		opts.Sample = &models.SampleMetadata{
+			SampleID:       sample.GetSampleId(),
+			OperatorName:   sample.GetOperatorName(),
+			LocationLat:    sample.GetLocationLat(),
+			LocationLon:    sample.GetLocationLon(),
+			SamplingDepthM: sample.GetSamplingDepthM(),
+			Notes:          sample.GetNotes(),
+		}
+		if err := services.ValidateSampleMetadata(*opts.Sample); err != nil {
+			return opts, err
+		}
+	}
+
+	if seconds := req.GetTimeoutSeconds(); seconds != 0 {
+		opts.Timeout = time.Duration(s.config.ClampAnalysisTimeout(int(seconds))) * time.Second
+	}
+
+	if _, err := opts.MacroParams(s.config); err != nil {
+		return opts, err
+	}
+
+	if roi := req.GetRoi(); roi != nil {
+		region := models.Rectangle{
+			X:      int(roi.GetX()),
+			Y:      int(roi.GetY()),
+			Width:  int(roi.GetWidth()),
+			Height: int(roi.GetHeight()),
+		}
+		if err := services.ValidateROIRegion(region); err != nil {
+			return opts, err
+		}
+		opts.ROI = &region
+	}
+	return opts, nil
+}
+
+// fileHeader wraps image content in the multipart file header the analysis
+// service accepts for uploads
+func fileHeader(filename string, content []byte) (*multipart.FileHeader, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("image", filename)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := part.Write(content); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(int64(len(content)) + messageOverhead)
+	if err != nil {
+		return nil, err
+	}
+	return form.File["image"][0], nil
+}
+
+// authInterceptor requires a bearer token in the authorization metadata of
+// unary calls. With an empty secret every call is rejected.
+func authInterceptor(secret, issuer string, maxLifetime time.Duration) grpc.UnaryServerInterceptor {
+	if secret == "" {
+		return func(context.Context, any, *grpc.UnaryServerInfo, grpc.UnaryHandler) (any, error) {
+			return nil, status.Error(codes.Unauthenticated, "Authentication is not configured")
+		}
+	}
+
+	parse := middleware.NewTokenParser(secret, issuer, maxLifetime)
+
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		var raw string
+		found := false
+		if values := metadata.ValueFromIncomingContext(ctx, "authorization"); len(values) > 0 {
+			raw, found = strings.CutPrefix(values[0], "Bearer ")
+		}
+		if !found || raw == "" {
+			return nil, status.Error(codes.Unauthenticated, "Missing bearer token")
+		}
+		claims, err := parse(raw)
+		if err != nil {
+			return nil, status.Error(codes.Unauthenticated, "Invalid token")
+		}
+		return handler(context.WithValue(ctx, claimsKey{}, claims), req)
+	}
+}
+
+// rateLimitInterceptor rejects AnalyzeGypsum calls beyond the client's rate
+// with ResourceExhausted and a retry-after header in seconds. Status lookups
+// are not limited, as on the REST API.
+func rateLimitInterceptor(limiter *middleware.RateLimiter) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if info.FullMethod != gypsumpb.GypsumAnalysis_AnalyzeGypsum_FullMethodName {
+			return handler(ctx, req)
+		}
+		retryAfter, ok := limiter.Reserve(clientKey(ctx))
+		switch {
+		case ok:
+			return handler(ctx, req)
+		case retryAfter > 0:
+			grpc.SetHeader(ctx, metadata.Pairs("retry-after", strconv.Itoa(middleware.RetryAfterSeconds(retryAfter))))
+			return nil, status.Error(codes.ResourceExhausted, "Rate limit exceeded, please retry later")
+		default:
+			return nil, status.Error(codes.ResourceExhausted, "Rate limit exceeded")
+		}
+	}
+}
+
+// claimsKey is the context key of the claims of an authenticated call
+type claimsKey struct{}
+
+// clientKey identifies the client of a call like middleware.ClientKey: the
+// token subject of an authenticated call, otherwise the peer IP
+func clientKey(ctx context.Context) string {
+	if claims, ok := ctx.Value(claimsKey{}).(*middleware.Claims); ok && claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return "ip:" + host
+		}
+		return "ip:" + p.Addr.String()
+	}
+	return "ip:"
+}
@@ -0,0 +1,296 @@
+package grpc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"mime/multipart"
+	"net"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+	"gypsum-analysis-api/proto/gypsumpb"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// fakeAnalysisService records submissions and serves results from a map. The
+// embedded interface panics for methods the gRPC server does not use.
+type fakeAnalysisService struct {
+	services.AnalysisServiceInterface
+
+	results   map[string]*models.AnalysisResult
+	submitErr error
+
+	submitted []byte
+	filename  string
+	opts      services.AnalysisOptions
+}
+
+func (f *fakeAnalysisService) SubmitAnalysis(ctx context.Context, analysisID string, file *multipart.FileHeader, opts services.AnalysisOptions) error {
+	if f.submitErr != nil {
+		return f.submitErr
+	}
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	if f.submitted, err = io.ReadAll(src); err != nil {
+		return err
+	}
+	f.filename = file.Filename
+	f.opts = opts
+	return nil
+}
+
+func (f *fakeAnalysisService) GetAnalysisStatus(analysisID string) (*models.AnalysisResult, error) {
+	result, ok := f.results[analysisID]
+	if !ok {
+		return nil, services.ErrAnalysisNotFound
+	}
+	return result, nil
+}
+
+// dial serves a GypsumAnalysis server over an in-memory connection
+func dial(t *testing.T, service services.AnalysisServiceInterface, cfg *config.Config) gypsumpb.GypsumAnalysisClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(service, cfg, logger.New("error"), nil, nil)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return gypsumpb.NewGypsumAnalysisClient(conn)
+}
+
+func TestAnalyzeGypsum_Submits(t *testing.T) {
+	service := &fakeAnalysisService{}
+	client := dial(t, service, &config.Config{MaxFileSize: 1024})
+
+	minSize := 25.0
+	resp, err := client.AnalyzeGypsum(context.Background(), &gypsumpb.AnalyzeRequest{
+		Image:           []byte("image bytes"),
+		Filename:        "sample.png",
+		SampleGroup:     "quarry-a",
+		Metadata:        map[string]string{"operator": "lab-1"},
+		ThresholdMethod: "Li",
+		MinParticleSize: &minSize,
+	})
+	require.NoError(t, err)
+
+	assert.NotEmpty(t, resp.AnalysisId)
+	assert.Equal(t, "processing", resp.Status)
+	assert.Equal(t, []byte("image bytes"), service.submitted)
+	assert.Equal(t, "sample.png", service.filename)
+	assert.Equal(t, "quarry-a", service.opts.SampleGroup)
+	assert.Equal(t, "lab-1", service.opts.Metadata["operator"])
+	assert.Equal(t, "Li", service.opts.ThresholdMethod)
+	require.NotNil(t, service.opts.MinParticleSize)
+	assert.Equal(t, 25.0, *service.opts.MinParticleSize)
+	assert.Nil(t, service.opts.MaxParticleSize)
+}
+
+func TestAnalyzeGypsum_InvalidRequests(t *testing.T) {
+	negative := -1.0
+	tests := []struct {
+		name string
+		req  *gypsumpb.AnalyzeRequest
+	}{
+		{"no image", &gypsumpb.AnalyzeRequest{Filename: "sample.png"}},
+		{"too large", &gypsumpb.AnalyzeRequest{Image: make([]byte, 2048), Filename: "sample.png"}},
+		{"unsupported type", &gypsumpb.AnalyzeRequest{Image: []byte("x"), Filename: "sample.gif"}},
+		{"unknown profile", &gypsumpb.AnalyzeRequest{Image: []byte("x"), Filename: "sample.png", Profile: "nope"}},
+		{"bad threshold method", &gypsumpb.AnalyzeRequest{Image: []byte("x"), Filename: "sample.png", ThresholdMethod: "Magic"}},
+		{"negative particle size", &gypsumpb.AnalyzeRequest{Image: []byte("x"), Filename: "sample.png", MinParticleSize: &negative}},
+		{"negative scale", &gypsumpb.AnalyzeRequest{Image: []byte("x"), Filename: "sample.png", PixelsPerMicron: -2}},
+	}
+
+	client := dial(t, &fakeAnalysisService{}, &config.Config{MaxFileSize: 1024})
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := client.AnalyzeGypsum(context.Background(), tt.req)
+			assert.Equal(t, codes.InvalidArgument, status.Code(err))
+		})
+	}
+}
+
+func TestAnalyzeGypsum_QueueFull(t *testing.T) {
+	client := dial(t, &fakeAnalysisService{submitErr: services.ErrQueueFull}, &config.Config{})
+
+	_, err := client.AnalyzeGypsum(context.Background(), &gypsumpb.AnalyzeRequest{Image: []byte("x"), Filename: "sample.png"})
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err))
+}
+
+func TestGetStatus(t *testing.T) {
+	completedAt := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	override := 91.5
+	service := &fakeAnalysisService{results: map[string]*models.AnalysisResult{
+		"done": {
+			ID:                   "done",
+			Status:               models.StatusCompleted,
+			CreatedAt:            completedAt.Add(-time.Minute),
+			CompletedAt:          &completedAt,
+			Progress:             100,
+			PurityPercentage:     88.2,
+			ParticleCount:        42,
+			Calibration:          &models.Calibration{PixelsPerMicron: 2},
+			Tiles:                []models.TileResult{{Index: 1, Width: 64, Height: 64}},
+			Parameters:           &models.MacroParams{ThresholdMethod: "Otsu", MaxCircularity: 1},
+			ManualOverridePurity: &override,
+		},
+	}}
+	client := dial(t, service, &config.Config{})
+
+	result, err := client.GetStatus(context.Background(), &gypsumpb.StatusRequest{AnalysisId: "done"})
+	require.NoError(t, err)
+	assert.Equal(t, "done", result.Id)
+	assert.Equal(t, "completed", result.Status)
+	assert.Equal(t, completedAt, result.CompletedAt.AsTime())
+	assert.Equal(t, int32(100), result.Progress)
+	assert.Equal(t, 88.2, result.PurityPercentage)
+	assert.Equal(t, int32(42), result.ParticleCount)
+	assert.Equal(t, 2.0, result.Calibration.PixelsPerMicron)
+	require.Len(t, result.Tiles, 1)
+	assert.Equal(t, int32(64), result.Tiles[0].Width)
+	assert.Equal(t, "Otsu", result.Parameters.ThresholdMethod)
+	assert.Equal(t, 91.5, result.GetManualOverridePurity())
+	assert.Nil(t, result.ReviewedAt)
+	assert.Nil(t, result.ShadowPurityPercentage)
+
+	_, err = client.GetStatus(context.Background(), &gypsumpb.StatusRequest{AnalysisId: "missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestAuthInterceptor(t *testing.T) {
+	const secret = "test-secret"
+	service := &fakeAnalysisService{results: map[string]*models.AnalysisResult{"a": {ID: "a"}}}
+	client := dial(t, service, &config.Config{AuthEnabled: true, JWTSecret: secret})
+	req := &gypsumpb.StatusRequest{AnalysisId: "a"}
+
+	_, err := client.GetStatus(context.Background(), req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")
+	_, err = client.GetStatus(ctx, req)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString([]byte(secret))
+	require.NoError(t, err)
+
+	ctx = metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer "+token)
+	_, err = client.GetStatus(ctx, req)
+	assert.NoError(t, err)
+}
+
+func TestRateLimitInterceptor(t *testing.T) {
+	limiter := middleware.NewRateLimiter(0.001, 1, time.Hour)
+	t.Cleanup(limiter.Stop)
+	intercept := rateLimitInterceptor(limiter)
+
+	handler := func(context.Context, any) (any, error) { return "ok", nil }
+	submit := &grpc.UnaryServerInfo{FullMethod: gypsumpb.GypsumAnalysis_AnalyzeGypsum_FullMethodName}
+	fromPeer := func(addr string) context.Context {
+		return peer.NewContext(context.Background(), &peer.Peer{Addr: &net.TCPAddr{IP: net.ParseIP(addr), Port: 5000}})
+	}
+
+	_, err := intercept(fromPeer("192.0.2.1"), nil, submit, handler)
+	require.NoError(t, err)
+	_, err = intercept(fromPeer("192.0.2.1"), nil, submit, handler)
+	assert.Equal(t, codes.ResourceExhausted, status.Code(err), "the peer has used its burst")
+
+	_, err = intercept(fromPeer("192.0.2.2"), nil, submit, handler)
+	assert.NoError(t, err, "other peers have their own budget")
+	authenticated := context.WithValue(fromPeer("192.0.2.1"), claimsKey{}, &middleware.Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: "lab-1"}})
+	_, err = intercept(authenticated, nil, submit, handler)
+	assert.NoError(t, err, "authenticated callers are keyed by subject")
+
+	_, err = intercept(fromPeer("192.0.2.1"), nil, &grpc.UnaryServerInfo{FullMethod: gypsumpb.GypsumAnalysis_GetStatus_FullMethodName}, handler)
+	assert.NoError(t, err, "status lookups are not limited")
+}
+
+// selfSignedTLS returns a server TLS config with a certificate for host and a
+// pool trusting it
+func selfSignedTLS(t *testing.T, host string) (*tls.Config, *x509.CertPool) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}, roots
+}
+
+func TestNewServer_TLS(t *testing.T) {
+	tlsConfig, roots := selfSignedTLS(t, "gypsum.example.com")
+
+	listener := bufconn.Listen(1 << 20)
+	server := NewServer(&fakeAnalysisService{}, &config.Config{}, logger.New("error"), tlsConfig, nil)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	call := func(creds credentials.TransportCredentials) error {
+		conn, err := grpc.Dial("bufnet",
+			grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) { return listener.Dial() }),
+			grpc.WithTransportCredentials(creds),
+		)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+		defer cancel()
+		_, err = gypsumpb.NewGypsumAnalysisClient(conn).GetStatus(ctx, &gypsumpb.StatusRequest{AnalysisId: "missing"})
+		return err
+	}
+
+	// Plaintext clients cannot talk to the TLS server
+	err := call(insecure.NewCredentials())
+	require.Error(t, err)
+	assert.NotEqual(t, codes.NotFound, status.Code(err))
+
+	err = call(credentials.NewTLS(&tls.Config{RootCAs: roots, ServerName: "gypsum.example.com"}))
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
@@ -1,33 +1,90 @@
 package handlers
 
 import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 
+	"gypsum-analysis-api/internal/charts"
+	"gypsum-analysis-api/internal/config"
 	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
 	"gypsum-analysis-api/internal/services"
+	"gypsum-analysis-api/internal/tracing"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracer creates the root spans of traced requests
+var tracer = otel.Tracer("gypsum-analysis-api/internal/handlers")
+
+// queueRetryAfterSeconds is the Retry-After hint sent when the analysis queue is full
+const queueRetryAfterSeconds = 30
+
+// idempotencyKeyHeader names the header that makes retried uploads return
+// the analysis they started; maxIdempotencyKeyLength bounds its value
+const (
+	idempotencyKeyHeader    = "Idempotency-Key"
+	maxIdempotencyKeyLength = 255
+)
+
+// formOverhead is the request body allowance for form fields and the optional
+// ROI file on top of MAX_FILE_SIZE
+const formOverhead = 10 << 20
+
 // AnalysisHandler handles analysis-related HTTP requests
 type AnalysisHandler struct {
 	analysisService services.AnalysisServiceInterface
+	config          *config.Config
 	logger          *logger.Logger
 }
 
 // NewAnalysisHandler creates a new analysis handler
-func NewAnalysisHandler(analysisService services.AnalysisServiceInterface, logger *logger.Logger) *AnalysisHandler {
+func NewAnalysisHandler(analysisService services.AnalysisServiceInterface, cfg *config.Config, logger *logger.Logger) *AnalysisHandler {
 	return &AnalysisHandler{
 		analysisService: analysisService,
+		config:          cfg,
 		logger:          logger,
 	}
 }
 
 // AnalyzeGypsum handles gypsum image analysis requests
 func (h *AnalysisHandler) AnalyzeGypsum(c *gin.Context) {
+	// The request span joins a trace started by the caller, if any, and is
+	// the parent of the spans of the queued analysis
+	ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+	ctx, span := tracer.Start(ctx, "http.POST /api/v1/analysis/gypsum", trace.WithSpanKind(trace.SpanKindServer))
+	defer span.End()
+
+	// Cut off oversized bodies while reading instead of spooling them to disk
+	if h.config.MaxFileSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.config.MaxFileSize+formOverhead)
+	}
+
+	// Uploads above the memory threshold are spooled to disk rather than
+	// buffered on the heap
+	err := c.Request.ParseMultipartForm(h.config.UploadMemoryThreshold)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.fileTooLarge(c)
+		return
+	}
+
 	// Get the uploaded file from multipart/form-data
 	file, err := c.FormFile("image")
 	if err != nil || file == nil {
@@ -35,31 +92,75 @@ func (h *AnalysisHandler) AnalyzeGypsum(c *gin.Context) {
 		file, err = c.FormFile("file")
 	}
 	if err != nil || file == nil {
-		h.logger.WithError(err).Error("Failed to get uploaded file from form-data (expected field 'image' or 'file')")
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "No image file provided. Use form-data with field name 'image'",
-		})
+		h.logger.FromContext(c).WithError(err).Error("Failed to get uploaded file from form-data (expected field 'image' or 'file')")
+		apiErr := middleware.NewAPIError(c, models.ErrCodeNoFile, "No image file provided")
+		apiErr.Details = map[string]interface{}{"fields": []string{"image", "file"}}
+		c.JSON(http.StatusBadRequest, apiErr)
+		return
+	}
+
+	if h.config.MaxFileSize > 0 && file.Size > h.config.MaxFileSize {
+		h.fileTooLarge(c)
 		return
 	}
 
 	// Validate file type
-	ext := strings.ToLower(filepath.Ext(file.Filename))
-	if ext != ".jpg" && ext != ".jpeg" && ext != ".png" && ext != ".tiff" && ext != ".tif" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Unsupported file type. Please upload JPG, PNG, or TIFF images",
-		})
+	if !services.IsSupportedImage(file.Filename) {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeUnsupportedType, "Unsupported file type. Please upload JPG, PNG, TIFF, WebP, BMP or DNG images"))
+		return
+	}
+
+	opts, ok := h.analysisOptions(c)
+	if !ok {
+		return
+	}
+
+	// Resolve the request deadline up front so a bad header fails before work starts
+	wait := waitRequested(c)
+	var requestTimeout time.Duration
+	if wait {
+		requestTimeout, err = h.requestTimeout(c)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+			return
+		}
+	}
+
+	idempotencyKey, ok := h.idempotencyKey(c)
+	if !ok {
 		return
 	}
 
 	// Generate analysis ID
 	analysisID := uuid.New().String()
 
-	// Start analysis in background
-	go func() {
-		if err := h.analysisService.AnalyzeGypsumImage(analysisID, file); err != nil {
-			h.logger.WithError(err).WithField("analysis_id", analysisID).Error("Analysis failed")
+	// A retried upload returns the analysis its key already started, per caller
+	caller := middleware.ClientKey(c)
+	if idempotencyKey != "" {
+		originalID, reserved := h.analysisService.ReserveIdempotencyKey(caller, idempotencyKey, analysisID)
+		if !reserved {
+			h.replayAnalysis(c, originalID)
+			return
 		}
-	}()
+	}
+	span.SetAttributes(tracing.AnalysisID.String(analysisID), tracing.ImageSizeBytes.Int64(file.Size))
+
+	// Queue the analysis; the upload is saved before this returns
+	if err := h.analysisService.SubmitAnalysis(ctx, analysisID, file, opts); err != nil {
+		// Nothing was started, so a retry with the same key may try again
+		if idempotencyKey != "" {
+			h.analysisService.ReleaseIdempotencyKey(caller, idempotencyKey)
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		h.submissionFailed(c, err, analysisID, opts.ProfileID)
+		return
+	}
+
+	if wait {
+		h.waitForResult(c, analysisID, requestTimeout)
+		return
+	}
 
 	// Return immediate response with analysis ID
 	c.JSON(http.StatusAccepted, gin.H{
@@ -69,25 +170,1207 @@ func (h *AnalysisHandler) AnalyzeGypsum(c *gin.Context) {
 	})
 }
 
+// submissionFailed writes the response for an upload whose analysis could
+// not be queued
+func (h *AnalysisHandler) submissionFailed(c *gin.Context, err error, analysisID, profileID string) {
+	switch {
+	case errors.Is(err, services.ErrImageTypeMismatch):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeTypeMismatch, err.Error()))
+	case errors.Is(err, services.ErrUnsupportedMIME):
+		c.JSON(http.StatusUnsupportedMediaType, middleware.NewAPIError(c, models.ErrCodeUnsupportedMedia, "File content is not a JPG, PNG, TIFF, WebP, BMP or DNG image"))
+	case errors.Is(err, services.ErrROIOutOfBounds), errors.Is(err, services.ErrImageTooLarge), errors.Is(err, services.ErrDNGPreview):
+		c.JSON(http.StatusUnprocessableEntity, middleware.NewAPIError(c, models.ErrCodeUnprocessable, err.Error()))
+	case errors.Is(err, services.ErrProfileNotFound):
+		message := "Unknown profile_id"
+		if profileID != "" {
+			message += ": " + profileID
+		}
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, message))
+	case errors.Is(err, services.ErrInvalidParticleFilter):
+		// The overrides conflict with the profile's particle filter
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+	case errors.Is(err, services.ErrQueueFull):
+		c.Header("Retry-After", strconv.Itoa(queueRetryAfterSeconds))
+		c.JSON(http.StatusTooManyRequests, middleware.NewAPIError(c, models.ErrCodeRateLimited, "Too many analyses in progress, please retry later"))
+	case errors.Is(err, services.ErrShuttingDown):
+		c.JSON(http.StatusServiceUnavailable, middleware.NewAPIError(c, models.ErrCodeServiceUnavailable, "Server is shutting down, please retry later"))
+	default:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to start analysis")
+		apiErr := middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to start analysis")
+		apiErr.Details = map[string]interface{}{"analysis_id": analysisID}
+		c.JSON(http.StatusInternalServerError, apiErr)
+	}
+}
+
+// idempotencyKey returns the Idempotency-Key of an upload, or "" when it has
+// none or keys are disabled. On an oversized key it writes a 400 response and
+// returns false.
+func (h *AnalysisHandler) idempotencyKey(c *gin.Context) (string, bool) {
+	key := strings.TrimSpace(c.GetHeader(idempotencyKeyHeader))
+	if h.config.IdempotencyKeyTTL <= 0 {
+		return "", true
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, fmt.Sprintf("%s must be at most %d characters", idempotencyKeyHeader, maxIdempotencyKeyLength)))
+		return "", false
+	}
+	return key, true
+}
+
+// replayAnalysis answers a retried upload with the analysis its
+// Idempotency-Key started, without starting another
+func (h *AnalysisHandler) replayAnalysis(c *gin.Context, analysisID string) {
+	status := string(models.StatusProcessing)
+	if result, err := h.analysisService.GetAnalysisStatus(analysisID); err == nil {
+		status = string(result.Status)
+	}
+
+	c.Header("Idempotent-Replayed", "true")
+	c.JSON(http.StatusAccepted, gin.H{
+		"analysis_id": analysisID,
+		"status":      status,
+		"message":     "Analysis already started for this Idempotency-Key",
+	})
+}
+
+// fileTooLarge responds 413 stating the upload size limit
+func (h *AnalysisHandler) fileTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, middleware.NewAPIError(c, models.ErrCodePayloadTooLarge, fmt.Sprintf("File exceeds the maximum size of %d bytes", h.config.MaxFileSize)))
+}
+
+// batchTooLarge responds 413 to a batch holding more than MAX_BATCH_SIZE images
+func (h *AnalysisHandler) batchTooLarge(c *gin.Context) {
+	c.JSON(http.StatusRequestEntityTooLarge, middleware.NewAPIError(c, models.ErrCodePayloadTooLarge, fmt.Sprintf("Batch exceeds the maximum of %d images", h.config.MaxBatchSize)))
+}
+
+// parseBatchForm parses a batch upload, cutting the body off at MAX_BATCH_SIZE
+// images of MAX_FILE_SIZE plus the form fields. On an oversized body it
+// writes a 413 response and returns false.
+func (h *AnalysisHandler) parseBatchForm(c *gin.Context) bool {
+	if h.config.MaxFileSize <= 0 || h.config.MaxBatchSize <= 0 {
+		return true
+	}
+	limit := int64(h.config.MaxBatchSize)*h.config.MaxFileSize + formOverhead
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limit)
+
+	err := c.Request.ParseMultipartForm(h.config.UploadMemoryThreshold)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.JSON(http.StatusRequestEntityTooLarge, middleware.NewAPIError(c, models.ErrCodePayloadTooLarge, fmt.Sprintf("Batch exceeds the maximum size of %d bytes", limit)))
+		return false
+	}
+	return true
+}
+
+// analysisOptions reads the optional submission settings shared by single and
+// batch uploads. On invalid input it writes a 400 response naming every
+// invalid field, with their reasons in details.fields, and returns false.
+func (h *AnalysisHandler) analysisOptions(c *gin.Context) (services.AnalysisOptions, bool) {
+	var req models.AnalysisRequest
+	invalid, err := bindForm(c, &req)
+	if err != nil {
+		h.logger.FromContext(c).WithError(err).Error("Failed to read analysis settings")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to read analysis settings"))
+		return services.AnalysisOptions{}, false
+	}
+
+	opts := services.AnalysisOptions{
+		Profile:            req.Profile,
+		SampleGroup:        req.SampleGroup,
+		CallbackURL:        req.CallbackURL,
+		Metadata:           c.PostFormMap("metadata"),
+		Owner:              middleware.ClientKey(c),
+		ProfileID:          req.ProfileID,
+		ThresholdMethod:    req.ThresholdMethod,
+		MinParticleSize:    req.MinParticleSize,
+		MaxParticleSize:    req.MaxParticleSize,
+		MinCircularity:     req.MinCircularity,
+		GaussianSigma:      req.GaussianSigma,
+		ContrastSaturation: req.ContrastSaturation,
+	}
+	if opts.Profile != "" {
+		if _, ok := h.config.Profiles[opts.Profile]; !ok {
+			invalid["profile"] = "unknown profile " + strconv.Quote(opts.Profile)
+		}
+	}
+	if opts.CallbackURL != "" {
+		if err := config.ValidateCallbackURL(opts.CallbackURL); err != nil {
+			invalid["callback_url"] = err.Error()
+		}
+	}
+
+	// Optional spatial calibration, as a scale or a scale bar
+	for _, field := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{req.PixelsPerMicron, &opts.PixelsPerMicron},
+		{req.ScaleBarLength, &opts.ScaleBarLength},
+		{req.ScaleBarPixels, &opts.ScaleBarPixels},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+		}
+	}
+
+	// Optional description of the physical sample
+	sample := models.SampleMetadata{
+		SampleID:     req.SampleID,
+		OperatorName: req.OperatorName,
+		Notes:        req.Notes,
+	}
+	sampleGiven := sample != models.SampleMetadata{}
+	for _, field := range []struct {
+		value  *float64
+		target *float64
+	}{
+		{req.LocationLat, &sample.LocationLat},
+		{req.LocationLon, &sample.LocationLon},
+		{req.SamplingDepthM, &sample.SamplingDepthM},
+	} {
+		if field.value != nil {
+			*field.target = *field.value
+			sampleGiven = true
+		}
+	}
+	if sampleGiven {
+		opts.Sample = &sample
+	}
+
+	// Optional analysis timeout, clamped to the configured bounds
+	if req.TimeoutSeconds != nil {
+		opts.Timeout = time.Duration(h.config.ClampAnalysisTimeout(*req.TimeoutSeconds)) * time.Second
+	}
+
+	// Optional macro overrides, defaulting to the configured preprocessing and
+	// threshold method and the standard particle filter. Their combination is
+	// only checked once each of them is valid.
+	if opts.GaussianSigma == nil {
+		opts.GaussianSigma = req.BlurSigma
+	}
+	macroValid := true
+	for _, field := range []string{"threshold_method", "min_particle_size", "max_particle_size", "min_circularity", "blur_sigma", "gaussian_sigma", "contrast_saturation"} {
+		if _, ok := invalid[field]; ok {
+			macroValid = false
+		}
+	}
+	if macroValid {
+		if _, err := opts.MacroParams(h.config); err != nil {
+			invalid[macroField(err)] = err.Error()
+		}
+	}
+
+	// An optional ImageJ ROI file restricts the analysis to its regions
+	roiFile, err := c.FormFile("roi_file")
+	switch {
+	case err == nil:
+		if err := services.ValidateROIFile(roiFile); err != nil {
+			invalid["roi_file"] = err.Error()
+		}
+		opts.ROIFile = roiFile
+	case !errors.Is(err, http.ErrMissingFile):
+		invalid["roi_file"] = "failed to read ROI file: " + err.Error()
+	}
+
+	// An optional rectangle crops the image before analysis
+	if req.ROIX != nil && req.ROIY != nil && req.ROIWidth != nil && req.ROIHeight != nil {
+		if opts.ROIFile != nil {
+			invalid["roi_file"] = "cannot be combined with a rectangular region"
+		}
+		opts.ROI = &models.Rectangle{X: *req.ROIX, Y: *req.ROIY, Width: *req.ROIWidth, Height: *req.ROIHeight}
+	}
+
+	if len(invalid) > 0 {
+		apiErr := middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Invalid analysis settings: "+invalid.String())
+		apiErr.Details = map[string]interface{}{"fields": invalid}
+		c.JSON(http.StatusBadRequest, apiErr)
+		return opts, false
+	}
+	return opts, true
+}
+
+// macroField returns the form field a macro settings error of
+// AnalysisOptions.MacroParams is about
+func macroField(err error) string {
+	switch {
+	case errors.Is(err, services.ErrUnknownThresholdMethod):
+		return "threshold_method"
+	case errors.Is(err, services.ErrInvalidPreprocessing):
+		return "gaussian_sigma"
+	case strings.Contains(err.Error(), "min_circularity"):
+		return "min_circularity"
+	}
+	return "max_particle_size"
+}
+
+// AnalyzeBatch queues one analysis per image in an uploaded ZIP archive. The
+// submission settings of AnalyzeGypsum apply to every image.
+func (h *AnalysisHandler) AnalyzeBatch(c *gin.Context) {
+	if !h.parseBatchForm(c) {
+		return
+	}
+
+	archive, err := c.FormFile("archive")
+	if err != nil || archive == nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeNoFile, "No archive provided. Use form-data with field name 'archive'"))
+		return
+	}
+	if strings.ToLower(filepath.Ext(archive.Filename)) != ".zip" {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeUnsupportedType, "Unsupported archive type. Please upload a ZIP archive"))
+		return
+	}
+
+	opts, ok := h.analysisOptions(c)
+	if !ok {
+		return
+	}
+
+	batch, err := h.analysisService.SubmitBatch(archive, opts)
+	switch {
+	case errors.Is(err, services.ErrInvalidArchive), errors.Is(err, services.ErrEmptyBatch), errors.Is(err, services.ErrInvalidParticleFilter):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	case errors.Is(err, services.ErrBatchTooLarge):
+		h.batchTooLarge(c)
+		return
+	case errors.Is(err, services.ErrProfileNotFound):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Unknown profile_id: "+opts.ProfileID))
+		return
+	case errors.Is(err, services.ErrQueueFull):
+		c.Header("Retry-After", strconv.Itoa(queueRetryAfterSeconds))
+		c.JSON(http.StatusTooManyRequests, middleware.NewAPIError(c, models.ErrCodeRateLimited, "Too many analyses in progress, please retry later"))
+		return
+	case errors.Is(err, services.ErrShuttingDown):
+		c.JSON(http.StatusServiceUnavailable, middleware.NewAPIError(c, models.ErrCodeServiceUnavailable, "Server is shutting down, please retry later"))
+		return
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).Error("Failed to start batch")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to start batch"))
+		return
+	}
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// AnalyzeImages queues one analysis per image uploaded under images[], grouped
+// under a new batch ID. The submission settings of AnalyzeGypsum apply to every
+// image. Rejected images are reported alongside the queued ones; the request
+// only fails when none could be queued.
+func (h *AnalysisHandler) AnalyzeImages(c *gin.Context) {
+	if !h.parseBatchForm(c) {
+		return
+	}
+
+	form, err := c.MultipartForm()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeNoFile, "No images provided. Use form-data with field name 'images[]'"))
+		return
+	}
+	files := form.File["images[]"]
+	if len(files) == 0 {
+		files = form.File["images"]
+	}
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeNoFile, "No images provided. Use form-data with field name 'images[]'"))
+		return
+	}
+	if h.config.MaxBatchSize > 0 && len(files) > h.config.MaxBatchSize {
+		h.batchTooLarge(c)
+		return
+	}
+
+	opts, ok := h.analysisOptions(c)
+	if !ok {
+		return
+	}
+
+	batch := &models.BatchUpload{
+		BatchID: uuid.New().String(),
+		Files:   make([]models.BatchFile, 0, len(files)),
+	}
+	opts.BatchID = batch.BatchID
+
+	queued, queueFull := 0, false
+	for _, file := range files {
+		outcome := models.BatchFile{Filename: file.Filename, Status: models.StatusFailed}
+		switch {
+		case !services.IsSupportedImage(file.Filename):
+			outcome.Error = "unsupported file type"
+		case h.config.MaxFileSize > 0 && file.Size > h.config.MaxFileSize:
+			outcome.Error = fmt.Sprintf("larger than %d bytes", h.config.MaxFileSize)
+		default:
+			analysisID := uuid.New().String()
+			err := h.analysisService.SubmitAnalysis(c.Request.Context(), analysisID, file, opts)
+			switch {
+			case err == nil:
+				queued++
+				outcome.AnalysisID = analysisID
+				outcome.Status = models.StatusPending
+			case errors.Is(err, services.ErrQueueFull):
+				queueFull = true
+				outcome.Error = "analysis queue is full"
+			default:
+				// A failure after the record was created is reported by the analysis itself
+				if result, getErr := h.analysisService.GetAnalysisStatus(analysisID); getErr == nil {
+					queued++
+					outcome.AnalysisID = analysisID
+					outcome.Status = result.Status
+					outcome.Error = result.Error
+				} else {
+					outcome.Error = err.Error()
+				}
+			}
+		}
+		batch.Files = append(batch.Files, outcome)
+	}
+
+	if queued == 0 {
+		if queueFull {
+			c.Header("Retry-After", strconv.Itoa(queueRetryAfterSeconds))
+			c.JSON(http.StatusTooManyRequests, middleware.NewAPIError(c, models.ErrCodeRateLimited, "Too many analyses in progress, please retry later"))
+			return
+		}
+		apiErr := middleware.NewAPIError(c, models.ErrCodeInvalidInput, "None of the uploaded images could be analyzed")
+		apiErr.Details = map[string]interface{}{"files": batch.Files}
+		c.JSON(http.StatusBadRequest, apiErr)
+		return
+	}
+
+	h.logger.FromContext(c).WithField("batch_id", batch.BatchID).
+		WithField("analyses", queued).
+		WithField("rejected", len(batch.Files)-queued).
+		Info("Batch submitted")
+
+	c.JSON(http.StatusAccepted, batch)
+}
+
+// GetBatchStatus returns the aggregated status of a batch
+func (h *AnalysisHandler) GetBatchStatus(c *gin.Context) {
+	summary, err := h.analysisService.GetBatchSummary(c.Param("batch_id"))
+	switch {
+	case errors.Is(err, services.ErrBatchNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Batch not found"))
+		return
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).Error("Failed to load batch")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to load batch"))
+		return
+	}
+
+	c.JSON(http.StatusOK, summary)
+}
+
+// GetPuritySummary returns purity statistics over the analyses of a batch or
+// of a creation time range
+func (h *AnalysisHandler) GetPuritySummary(c *gin.Context) {
+	batchID := c.Query("batch_id")
+	from, err := parseTimeQuery(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	}
+	to, err := parseTimeQuery(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	}
+	if batchID == "" && from.IsZero() && to.IsZero() {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "batch_id or from/to is required"))
+		return
+	}
+
+	summary, err := h.analysisService.SummarizePurity(batchID, from, to)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, summary)
+	case errors.Is(err, services.ErrBatchNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Batch not found"))
+	case errors.Is(err, services.ErrTooFewResults):
+		c.JSON(http.StatusUnprocessableEntity, middleware.NewAPIError(c, models.ErrCodeUnprocessable, "At least two completed analyses are needed for a summary"))
+	default:
+		h.logger.FromContext(c).WithError(err).Error("Failed to summarize analyses")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to summarize analyses"))
+	}
+}
+
+// CompareAnalyses returns the change between the completed analyses a and b
+// of the same sample, or of different samples with allow_cross_sample=true
+func (h *AnalysisHandler) CompareAnalyses(c *gin.Context) {
+	idA, idB := c.Query("a"), c.Query("b")
+	if idA == "" || idB == "" {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "a and b are required"))
+		return
+	}
+	allowCrossSample, _ := strconv.ParseBool(c.Query("allow_cross_sample"))
+
+	comparison, err := h.analysisService.CompareAnalyses(idA, idB, allowCrossSample)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, comparison)
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, err.Error()))
+	case errors.Is(err, services.ErrAnalysisNotCompleted), errors.Is(err, services.ErrSampleMismatch):
+		c.JSON(http.StatusUnprocessableEntity, middleware.NewAPIError(c, models.ErrCodeUnprocessable, err.Error()))
+	default:
+		h.logger.FromContext(c).WithError(err).Error("Failed to compare analyses")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to compare analyses"))
+	}
+}
+
 // GetAnalysisStatus returns the status and results of an analysis
 func (h *AnalysisHandler) GetAnalysisStatus(c *gin.Context) {
 	analysisID := c.Param("id")
 	if analysisID == "" {
-		c.JSON(http.StatusBadRequest, gin.H{
-			"error": "Analysis ID is required",
-		})
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Analysis ID is required"))
 		return
 	}
 
 	// Get analysis status from service
 	status, err := h.analysisService.GetAnalysisStatus(analysisID)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+		return
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to get analysis status")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to retrieve analysis result"))
+		return
+	}
+
+	// Optionally make the response self-describing with the unit of every numeric field
+	if c.Query("include_units") == "true" {
+		withUnits := *status
+		withUnits.Units = services.ResultUnits(status)
+		status = &withUnits
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// Page size limits for the analysis list
+const (
+	defaultPerPage = 20
+	maxPerPage     = 100
+)
+
+// sseKeepAlive is how often a comment is sent on an idle status stream so
+// proxies do not close the connection
+const sseKeepAlive = 15 * time.Second
+
+// StreamAnalysisStatus sends the final result of an analysis as a Server-Sent
+// Event once it completes or fails, then closes the stream
+func (h *AnalysisHandler) StreamAnalysisStatus(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	if _, err := h.analysisService.GetAnalysisStatus(analysisID); err != nil {
+		if errors.Is(err, services.ErrAnalysisNotFound) {
+			c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+			return
+		}
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to get analysis status")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to retrieve analysis result"))
+		return
+	}
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Streaming is not supported"))
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	flusher.Flush()
+
+	// The stream outlives the server's write timeout, so keep pushing the deadline back
+	controller := http.NewResponseController(c.Writer)
+
+	for {
+		controller.SetWriteDeadline(time.Now().Add(2 * sseKeepAlive))
+
+		// Waiting stops when the client disconnects, which also drops the subscription
+		ctx, cancel := context.WithTimeout(c.Request.Context(), sseKeepAlive)
+		result, err := h.analysisService.WaitForAnalysis(ctx, analysisID)
+		cancel()
+
+		if errors.Is(err, services.ErrWaitTimeout) {
+			if c.Request.Context().Err() != nil {
+				return
+			}
+			fmt.Fprint(c.Writer, ": keepalive\n\n")
+			flusher.Flush()
+			continue
+		}
+		if result == nil {
+			h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Warn("Status stream ended without a result")
+			return
+		}
+
+		data, err := json.Marshal(result)
+		if err != nil {
+			h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to encode analysis result")
+			return
+		}
+		fmt.Fprintf(c.Writer, "data: %s\n\n", data)
+		flusher.Flush()
+		return
+	}
+}
+
+// StreamAnalysisEvents sends the analysis result as a Server-Sent Event now
+// and after every status change, closing the stream after the final result
+func (h *AnalysisHandler) StreamAnalysisEvents(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Streaming is not supported"))
+		return
+	}
+
+	updates, unsubscribe, err := h.analysisService.Subscribe(analysisID)
 	if err != nil {
-		h.logger.WithError(err).WithField("analysis_id", analysisID).Error("Failed to get analysis status")
-		c.JSON(http.StatusNotFound, gin.H{
-			"error": "Analysis not found",
+		if errors.Is(err, services.ErrAnalysisNotFound) {
+			c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+			return
+		}
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to subscribe to analysis")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to retrieve analysis result"))
+		return
+	}
+	defer unsubscribe()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	flusher.Flush()
+
+	// The stream outlives the server's write timeout, so keep pushing the deadline back
+	controller := http.NewResponseController(c.Writer)
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		controller.SetWriteDeadline(time.Now().Add(2 * sseKeepAlive))
+
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-keepAlive.C:
+			fmt.Fprint(c.Writer, ": keepalive\n\n")
+			flusher.Flush()
+		case result, ok := <-updates:
+			if !ok {
+				return
+			}
+			data, err := json.Marshal(result)
+			if err != nil {
+				h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to encode analysis result")
+				return
+			}
+			fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", result.Status, data)
+			flusher.Flush()
+		}
+	}
+}
+
+// ListAnalyses returns a page of analyses, optionally filtered by status,
+// purity and creation time
+func (h *AnalysisHandler) ListAnalyses(c *gin.Context) {
+	filter := services.ListFilter{
+		Status:   models.AnalysisStatus(c.Query("status")),
+		SampleID: c.Query("sample_id"),
+		Sort:     c.DefaultQuery("sort", services.SortCreatedAt),
+	}
+
+	switch filter.Status {
+	case "", models.StatusPending, models.StatusProcessing, models.StatusCompleted, models.StatusFailed, models.StatusCancelled:
+	default:
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "status must be one of pending, processing, completed, failed or cancelled"))
+		return
+	}
+
+	page := 1
+	if value := c.Query("page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "page must be a whole number of at least 1"))
+			return
+		}
+		page = parsed
+	}
+
+	perPage := defaultPerPage
+	if value := c.Query("per_page"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "per_page must be a whole number of at least 1"))
+			return
+		}
+		if parsed > maxPerPage {
+			c.JSON(http.StatusUnprocessableEntity, middleware.NewAPIError(c, models.ErrCodeUnprocessable, fmt.Sprintf("per_page must not exceed %d", maxPerPage)))
+			return
+		}
+		perPage = parsed
+	}
+	filter.Limit = perPage
+	filter.Offset = (page - 1) * perPage
+
+	for _, bound := range []struct {
+		name  string
+		value **float64
+	}{
+		{"min_purity", &filter.MinPurity},
+		{"max_purity", &filter.MaxPurity},
+	} {
+		value := c.Query(bound.name)
+		if value == "" {
+			continue
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil || parsed < 0 || parsed > 100 {
+			c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, bound.name+" must be a percentage between 0 and 100"))
+			return
+		}
+		*bound.value = &parsed
+	}
+
+	var err error
+	if filter.From, err = parseTimeQuery(c, "from"); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	}
+	if filter.To, err = parseTimeQuery(c, "to"); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	}
+
+	results, total, err := h.analysisService.ListAnalyses(filter)
+	switch {
+	case errors.Is(err, services.ErrInvalidListFilter):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).Error("Failed to list analyses")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to list analyses"))
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"data":        results,
+			"total":       total,
+			"page":        page,
+			"per_page":    perPage,
+			"total_pages": (total + perPage - 1) / perPage,
 		})
+	}
+}
+
+// GetPurityTrend returns purity over time for a sample group, as JSON points
+// or as a rendered PNG chart
+func (h *AnalysisHandler) GetPurityTrend(c *gin.Context) {
+	sampleGroup := c.Query("sample_group")
+	if sampleGroup == "" {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "sample_group is required"))
 		return
 	}
 
-	c.JSON(http.StatusOK, status)
+	from, err := parseTimeQuery(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	}
+	to, err := parseTimeQuery(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	}
+
+	points, err := h.analysisService.GetPurityTrend(sampleGroup, from, to)
+	if err != nil {
+		h.logger.FromContext(c).WithError(err).WithField("sample_group", sampleGroup).Error("Failed to load purity trend")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to load purity trend"))
+		return
+	}
+
+	switch c.DefaultQuery("format", "json") {
+	case "json":
+		c.JSON(http.StatusOK, gin.H{
+			"sample_group": sampleGroup,
+			"points":       points,
+		})
+	case "png":
+		var buf bytes.Buffer
+		if err := charts.RenderPurityTrend(&buf, "Purity trend: "+sampleGroup, points); err != nil {
+			if errors.Is(err, charts.ErrNoTrendData) {
+				c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "No completed analyses found for sample group "+sampleGroup))
+				return
+			}
+			h.logger.FromContext(c).WithError(err).WithField("sample_group", sampleGroup).Error("Failed to render trend chart")
+			c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to render trend chart"))
+			return
+		}
+		c.Data(http.StatusOK, "image/png", buf.Bytes())
+	default:
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Unsupported format, expected 'json' or 'png'"))
+	}
+}
+
+// parseTimeQuery reads an optional RFC 3339 timestamp from the query string
+func parseTimeQuery(c *gin.Context, name string) (time.Time, error) {
+	value := c.Query(name)
+	if value == "" {
+		return time.Time{}, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%s must be an RFC 3339 timestamp", name)
+	}
+	return parsed, nil
+}
+
+// waitRequested reports whether the client asked for a synchronous response
+// with ?wait=true or an X-Wait: true header
+func waitRequested(c *gin.Context) bool {
+	for _, value := range []string{c.Query("wait"), c.GetHeader("X-Wait")} {
+		if wait, err := strconv.ParseBool(value); err == nil && wait {
+			return true
+		}
+	}
+	return false
+}
+
+// requestTimeout returns how long a synchronous request may wait for its result,
+// taken from the X-Request-Timeout header (seconds) or the configured default
+func (h *AnalysisHandler) requestTimeout(c *gin.Context) (time.Duration, error) {
+	header := c.GetHeader("X-Request-Timeout")
+	if header == "" {
+		return time.Duration(h.config.RequestTimeout) * time.Second, nil
+	}
+
+	seconds, err := strconv.Atoi(header)
+	if err != nil || seconds <= 0 {
+		return 0, errors.New("X-Request-Timeout must be a positive number of seconds")
+	}
+
+	return time.Duration(seconds) * time.Second, nil
+}
+
+// waitForResult blocks until the analysis finishes or the request deadline passes.
+// A request deadline only stops the wait; the analysis keeps running and can be
+// retrieved later by ID.
+func (h *AnalysisHandler) waitForResult(c *gin.Context, analysisID string, timeout time.Duration) {
+	ctx := c.Request.Context()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	result, err := h.analysisService.WaitForAnalysis(ctx, analysisID)
+	switch {
+	case errors.Is(err, services.ErrWaitTimeout):
+		// The partial result shows how far the analysis got
+		status := models.StatusProcessing
+		if result != nil {
+			status = result.Status
+		}
+		apiErr := middleware.NewAPIError(c, models.ErrCodeTimeout, "Request deadline exceeded before analysis completed; retrieve the result later by ID")
+		apiErr.Details = map[string]interface{}{
+			"timeout":     "request",
+			"analysis_id": analysisID,
+			"status":      status,
+			"result":      result,
+		}
+		c.JSON(http.StatusGatewayTimeout, apiErr)
+	case errors.Is(err, services.ErrAnalysisTimeout):
+		apiErr := middleware.NewAPIError(c, models.ErrCodeTimeout, result.Error)
+		apiErr.Details = map[string]interface{}{
+			"timeout":     "analysis",
+			"analysis_id": analysisID,
+			"status":      result.Status,
+			"result":      result,
+		}
+		c.JSON(http.StatusGatewayTimeout, apiErr)
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to wait for analysis")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to retrieve analysis result"))
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// AnnotateAnalysis records a reviewer's note and optional manual purity override
+func (h *AnalysisHandler) AnnotateAnalysis(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	var annotation models.Annotation
+	if err := c.ShouldBindJSON(&annotation); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Invalid annotation: "+err.Error()))
+		return
+	}
+
+	result, err := h.analysisService.AnnotateAnalysis(analysisID, annotation)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+	case errors.Is(err, services.ErrAnalysisInProgress):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis is still processing and cannot be annotated yet"))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to annotate analysis")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to annotate analysis"))
+	default:
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+// CancelAnalysis stops a queued or running analysis, killing its Fiji
+// process. The analysis is reported as cancelled once its worker stops.
+func (h *AnalysisHandler) CancelAnalysis(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	err := h.analysisService.CancelAnalysis(analysisID)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+	case errors.Is(err, services.ErrAnalysisFinished):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis has already finished"))
+	case errors.Is(err, services.ErrAnalysisInProgress):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis is finishing and can no longer be cancelled"))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to cancel analysis")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to cancel analysis"))
+	default:
+		c.JSON(http.StatusAccepted, gin.H{
+			"analysis_id": analysisID,
+			"message":     "Cancellation requested",
+		})
+	}
+}
+
+// DeleteAnalysis cancels an unfinished analysis (202) or removes a finished
+// one and its files (204)
+func (h *AnalysisHandler) DeleteAnalysis(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	cancelled, err := h.analysisService.DeleteAnalysis(analysisID)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+	case errors.Is(err, services.ErrAnalysisInProgress):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis is finishing; retry the request shortly"))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to delete analysis")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to delete analysis"))
+	case cancelled:
+		c.JSON(http.StatusAccepted, gin.H{
+			"analysis_id": analysisID,
+			"message":     "Cancellation requested",
+		})
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// GetAnalysisManifest returns the consolidated archival record of an analysis
+func (h *AnalysisHandler) GetAnalysisManifest(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	manifest, err := h.analysisService.GetAnalysisManifest(analysisID)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+	case errors.Is(err, services.ErrAnalysisInProgress):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis is still processing; the manifest is available once it finishes"))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to build analysis manifest")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to build analysis manifest"))
+	default:
+		c.JSON(http.StatusOK, manifest)
+	}
+}
+
+// GetOverlay serves the PNG outlining the particles counted by an analysis
+func (h *AnalysisHandler) GetOverlay(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	overlay, err := h.analysisService.GetOverlay(c.Request.Context(), analysisID)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+	case errors.Is(err, services.ErrOverlayNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "No overlay is available for this analysis"))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to load overlay")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to load overlay"))
+	default:
+		defer overlay.Close()
+		c.DataFromReader(http.StatusOK, -1, "image/png", overlay, nil)
+	}
+}
+
+// VerifyAnalysis recomputes the signature of a completed result to detect
+// measurements modified after the fact
+func (h *AnalysisHandler) VerifyAnalysis(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	verification, err := h.analysisService.VerifyResult(analysisID)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+	case errors.Is(err, services.ErrAnalysisInProgress):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis is still processing; results are signed once it completes"))
+	case errors.Is(err, services.ErrResultNotSigned):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis result is not signed"))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to verify analysis result")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to verify analysis result"))
+	default:
+		if !verification.Valid {
+			h.logger.FromContext(c).WithField("analysis_id", analysisID).Warn("Analysis result does not match its signature")
+		}
+		c.JSON(http.StatusOK, verification)
+	}
+}
+
+// ExportAnalysis returns a completed analysis with spatial quantities converted
+// to SI units, as JSON or CSV
+func (h *AnalysisHandler) ExportAnalysis(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	if units := c.DefaultQuery("units", "si"); units != "si" {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Unsupported units, expected 'si'"))
+		return
+	}
+
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "csv" {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Unsupported format, expected 'json' or 'csv'"))
+		return
+	}
+
+	sigFigs := h.config.ExportSignificantFigures
+	if value := c.Query("sig_figs"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > services.MaxSignificantFigures {
+			c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, fmt.Sprintf("sig_figs must be a whole number between 1 and %d", services.MaxSignificantFigures)))
+			return
+		}
+		sigFigs = parsed
+	}
+
+	export, err := h.analysisService.ExportSI(analysisID, sigFigs)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+		return
+	case errors.Is(err, services.ErrAnalysisInProgress):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis is still processing; the export is available once it finishes"))
+		return
+	case errors.Is(err, services.ErrAnalysisFailed):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, err.Error()))
+		return
+	case errors.Is(err, services.ErrNotCalibrated):
+		c.JSON(http.StatusUnprocessableEntity, middleware.NewAPIError(c, models.ErrCodeUnprocessable, err.Error()))
+		return
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to export analysis")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to export analysis"))
+		return
+	}
+
+	if format == "json" {
+		c.JSON(http.StatusOK, export)
+		return
+	}
+
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write([]string{"quantity", "value", "unit"})
+	for _, quantity := range export.Quantities {
+		writer.Write([]string{quantity.Name, services.FormatSignificant(quantity.Value, export.SignificantFigures), quantity.Unit})
+	}
+	writer.Flush()
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s_si.csv"`, analysisID))
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+// resultExporter writes a completed analysis in one export format
+type resultExporter func(c *gin.Context, result *models.AnalysisResult)
+
+// resultExporters are the formats ExportResult supports, keyed by ?format
+var resultExporters = map[string]resultExporter{
+	"csv":  exportResultCSV,
+	"json": exportResultJSON,
+}
+
+// ExportResult returns the measurements of a completed analysis as a file in
+// the requested format, CSV by default
+func (h *AnalysisHandler) ExportResult(c *gin.Context) {
+	format := c.DefaultQuery("format", "csv")
+	export, ok := resultExporters[format]
+	if !ok {
+		formats := make([]string, 0, len(resultExporters))
+		for name := range resultExporters {
+			formats = append(formats, "'"+name+"'")
+		}
+		sort.Strings(formats)
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Unsupported format, expected one of "+strings.Join(formats, ", ")))
+		return
+	}
+
+	analysisID := c.Param("id")
+	result, err := h.analysisService.GetAnalysisStatus(analysisID)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+		return
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to export analysis")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to export analysis"))
+		return
+	case result.Status != models.StatusCompleted:
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, fmt.Sprintf("Analysis is %s; only completed analyses can be exported", result.Status)))
+		return
+	}
+
+	export(c, result)
+}
+
+// exportResultCSV writes the result in the layout of the bulk export, as a
+// header and a single row
+func exportResultCSV(c *gin.Context, result *models.AnalysisResult) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	writer.Write(resultExportColumns)
+	writer.Write(resultCSVRow(result))
+	writer.Flush()
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, result.ID))
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+// exportResultJSON writes the full result as a JSON attachment
+func exportResultJSON(c *gin.Context, result *models.AnalysisResult) {
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.json"`, result.ID))
+	c.JSON(http.StatusOK, result)
+}
+
+// resultExportColumns are the columns of the CSV exports of results. Columns
+// are only ever appended, so spreadsheets and LIMS imports keep working.
+var resultExportColumns = []string{
+	"id", "created_at", "completed_at", "purity_percentage", "gypsum_content_percentage",
+	"impurity_content_percentage", "calcite_content_percentage", "quartz_content_percentage",
+	"other_minerals_percentage", "particle_count", "confidence", "analysis_time_ms",
+	"sample_id", "operator_name", "location_lat", "location_lon", "sampling_depth_m", "notes",
+	"threshold_value", "threshold_lower", "threshold_upper",
+}
+
+// csvFlushRows is how many rows are buffered before being sent to the client
+const csvFlushRows = 100
+
+// ExportResults streams the completed analyses created between the optional
+// from and to timestamps as CSV for spreadsheets and LIMS imports
+func (h *AnalysisHandler) ExportResults(c *gin.Context) {
+	if format := c.DefaultQuery("format", "csv"); format != "csv" {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Unsupported format, expected 'csv'"))
+		return
+	}
+
+	from, err := parseTimeQuery(c, "from")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	}
+	to, err := parseTimeQuery(c, "to")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+		return
+	}
+	if !from.IsZero() && !to.IsZero() && to.Before(from) {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "to must not be before from"))
+		return
+	}
+
+	results, err := h.analysisService.ListCompletedBetween(from, to)
+	if err != nil {
+		h.logger.FromContext(c).WithError(err).Error("Failed to load analyses for export")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to export analyses"))
+		return
+	}
+
+	c.Header("Content-Type", "text/csv")
+	c.Header("Content-Disposition", `attachment; filename="gypsum_results.csv"`)
+	c.Status(http.StatusOK)
+
+	writer := csv.NewWriter(c.Writer)
+	writer.Write(resultExportColumns)
+	for i, result := range results {
+		writer.Write(resultCSVRow(result))
+		if (i+1)%csvFlushRows == 0 {
+			writer.Flush()
+			c.Writer.Flush()
+		}
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		h.logger.FromContext(c).WithError(err).Warn("Results export was interrupted")
+	}
+}
+
+// resultCSVRow formats a result in the order of resultExportColumns
+func resultCSVRow(result *models.AnalysisResult) []string {
+	float := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	// Unreported values are left empty
+	optional := func(v *float64) string {
+		if v == nil {
+			return ""
+		}
+		return float(*v)
+	}
+
+	completedAt := ""
+	if result.CompletedAt != nil {
+		completedAt = result.CompletedAt.UTC().Format(time.RFC3339)
+	}
+
+	return append([]string{
+		result.ID,
+		result.CreatedAt.UTC().Format(time.RFC3339),
+		completedAt,
+		float(result.PurityPercentage),
+		float(result.GypsumContent),
+		float(result.ImpurityContent),
+		float(result.CalciteContent),
+		float(result.QuartzContent),
+		float(result.OtherMinerals),
+		strconv.Itoa(result.ParticleCount),
+		float(result.Confidence),
+		strconv.FormatInt(result.AnalysisTime, 10),
+	}, append(sampleCSVFields(result.SampleMetadata),
+		float(result.ThresholdValue),
+		optional(result.ThresholdLower),
+		optional(result.ThresholdUpper),
+	)...)
+}
+
+// sampleCSVFields formats the sample metadata columns of the CSV exports,
+// which are empty when no sample was described
+func sampleCSVFields(sample *models.SampleMetadata) []string {
+	if sample == nil {
+		return make([]string, 6)
+	}
+	float := func(v float64) string { return strconv.FormatFloat(v, 'f', -1, 64) }
+	return []string{
+		sample.SampleID,
+		sample.OperatorName,
+		float(sample.LocationLat),
+		float(sample.LocationLon),
+		float(sample.SamplingDepthM),
+		sample.Notes,
+	}
 }
@@ -2,19 +2,29 @@ package handlers
 
 import (
 	"bytes"
+	"context"
+	"encoding/csv"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
+	"time"
 
+	"gypsum-analysis-api/internal/config"
 	"gypsum-analysis-api/internal/logger"
 	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 )
 
 // MockAnalysisService is a mock implementation of AnalysisService
@@ -22,11 +32,48 @@ type MockAnalysisService struct {
 	mock.Mock
 }
 
-func (m *MockAnalysisService) AnalyzeGypsumImage(analysisID string, file *multipart.FileHeader) error {
-	args := m.Called(analysisID, file)
+func (m *MockAnalysisService) AnalyzeGypsumImage(analysisID string, file *multipart.FileHeader, opts services.AnalysisOptions) error {
+	args := m.Called(analysisID, file, opts)
 	return args.Error(0)
 }
 
+func (m *MockAnalysisService) SubmitAnalysis(ctx context.Context, analysisID string, file *multipart.FileHeader, opts services.AnalysisOptions) error {
+	args := m.Called(ctx, analysisID, file, opts)
+	return args.Error(0)
+}
+
+func (m *MockAnalysisService) SubmitBatch(archive *multipart.FileHeader, opts services.AnalysisOptions) (*models.BatchResult, error) {
+	args := m.Called(archive, opts)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.BatchResult), args.Error(1)
+}
+
+func (m *MockAnalysisService) GetBatchSummary(batchID string) (*models.BatchSummary, error) {
+	args := m.Called(batchID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.BatchSummary), args.Error(1)
+}
+
+func (m *MockAnalysisService) SummarizePurity(batchID string, from, to time.Time) (*models.PuritySummary, error) {
+	args := m.Called(batchID, from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.PuritySummary), args.Error(1)
+}
+
+func (m *MockAnalysisService) CompareAnalyses(idA, idB string, allowCrossSample bool) (*models.ComparisonResult, error) {
+	args := m.Called(idA, idB, allowCrossSample)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ComparisonResult), args.Error(1)
+}
+
 func (m *MockAnalysisService) GetAnalysisStatus(analysisID string) (*models.AnalysisResult, error) {
 	args := m.Called(analysisID)
 	if args.Get(0) == nil {
@@ -35,6 +82,206 @@ func (m *MockAnalysisService) GetAnalysisStatus(analysisID string) (*models.Anal
 	return args.Get(0).(*models.AnalysisResult), args.Error(1)
 }
 
+func (m *MockAnalysisService) WaitForAnalysis(ctx context.Context, analysisID string) (*models.AnalysisResult, error) {
+	args := m.Called(ctx, analysisID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AnalysisResult), args.Error(1)
+}
+
+func (m *MockAnalysisService) AnnotateAnalysis(analysisID string, annotation models.Annotation) (*models.AnalysisResult, error) {
+	args := m.Called(analysisID, annotation)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AnalysisResult), args.Error(1)
+}
+
+func (m *MockAnalysisService) ListAnalyses(filter services.ListFilter) ([]*models.AnalysisResult, int, error) {
+	args := m.Called(filter)
+	if args.Get(0) == nil {
+		return nil, args.Int(1), args.Error(2)
+	}
+	return args.Get(0).([]*models.AnalysisResult), args.Int(1), args.Error(2)
+}
+
+func (m *MockAnalysisService) CancelAnalysis(analysisID string) error {
+	args := m.Called(analysisID)
+	return args.Error(0)
+}
+
+func (m *MockAnalysisService) DeleteAnalysis(analysisID string) (bool, error) {
+	args := m.Called(analysisID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockAnalysisService) GetPurityTrend(sampleGroup string, from, to time.Time) ([]models.TrendPoint, error) {
+	args := m.Called(sampleGroup, from, to)
+	return args.Get(0).([]models.TrendPoint), args.Error(1)
+}
+
+func (m *MockAnalysisService) GetAnalysisManifest(analysisID string) (*models.AnalysisManifest, error) {
+	args := m.Called(analysisID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.AnalysisManifest), args.Error(1)
+}
+
+func (m *MockAnalysisService) GetOverlay(ctx context.Context, analysisID string) (io.ReadCloser, error) {
+	args := m.Called(analysisID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(io.ReadCloser), args.Error(1)
+}
+
+func (m *MockAnalysisService) VerifyResult(analysisID string) (*models.ResultVerification, error) {
+	args := m.Called(analysisID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.ResultVerification), args.Error(1)
+}
+
+func (m *MockAnalysisService) ListCompletedBetween(from, to time.Time) ([]*models.AnalysisResult, error) {
+	args := m.Called(from, to)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.AnalysisResult), args.Error(1)
+}
+
+func (m *MockAnalysisService) ExportSI(analysisID string, sigFigs int) (*models.SIExport, error) {
+	args := m.Called(analysisID, sigFigs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.SIExport), args.Error(1)
+}
+
+func (m *MockAnalysisService) SubscribeEvents() (<-chan models.StatusEvent, func()) {
+	args := m.Called()
+	return args.Get(0).(<-chan models.StatusEvent), args.Get(1).(func())
+}
+
+func (m *MockAnalysisService) RegisterWebhook(owner string, registration models.WebhookRegistration) (*models.Webhook, error) {
+	args := m.Called(owner, registration)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Webhook), args.Error(1)
+}
+
+func (m *MockAnalysisService) ListWebhookDeliveries(owner, webhookID string) ([]*models.WebhookDelivery, error) {
+	args := m.Called(owner, webhookID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.WebhookDelivery), args.Error(1)
+}
+
+func (m *MockAnalysisService) DeleteWebhook(owner, webhookID string) error {
+	args := m.Called(owner, webhookID)
+	return args.Error(0)
+}
+
+func (m *MockAnalysisService) ListProfiles() ([]*models.Profile, error) {
+	args := m.Called()
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]*models.Profile), args.Error(1)
+}
+
+func (m *MockAnalysisService) GetProfile(profileID string) (*models.Profile, error) {
+	args := m.Called(profileID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Profile), args.Error(1)
+}
+
+func (m *MockAnalysisService) CreateProfile(request models.ProfileRequest) (*models.Profile, error) {
+	args := m.Called(request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Profile), args.Error(1)
+}
+
+func (m *MockAnalysisService) UpdateProfile(profileID string, request models.ProfileRequest) (*models.Profile, error) {
+	args := m.Called(profileID, request)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*models.Profile), args.Error(1)
+}
+
+func (m *MockAnalysisService) DeleteProfile(profileID string) error {
+	args := m.Called(profileID)
+	return args.Error(0)
+}
+
+func (m *MockAnalysisService) ReserveIdempotencyKey(caller, key, analysisID string) (string, bool) {
+	args := m.Called(caller, key, analysisID)
+	return args.String(0), args.Bool(1)
+}
+
+func (m *MockAnalysisService) ReleaseIdempotencyKey(caller, key string) {
+	m.Called(caller, key)
+}
+
+func (m *MockAnalysisService) InitUpload(filename string, opts services.AnalysisOptions) (string, error) {
+	args := m.Called(filename, opts)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAnalysisService) WriteChunk(owner, uploadID string, index int, r io.Reader) (int64, error) {
+	args := m.Called(owner, uploadID, index, r)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockAnalysisService) CompleteUpload(ctx context.Context, owner, uploadID, analysisID string, totalChunks int) error {
+	args := m.Called(ctx, owner, uploadID, analysisID, totalChunks)
+	return args.Error(0)
+}
+
+func (m *MockAnalysisService) PreviewMacro(filename string, opts services.AnalysisOptions) (string, error) {
+	args := m.Called(filename, opts)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockAnalysisService) QueueDepth() int {
+	args := m.Called()
+	return args.Int(0)
+}
+
+func (m *MockAnalysisService) Subscribe(analysisID string) (<-chan *models.AnalysisResult, func(), error) {
+	args := m.Called(analysisID)
+	if args.Get(0) == nil {
+		return nil, nil, args.Error(2)
+	}
+	return args.Get(0).(<-chan *models.AnalysisResult), args.Get(1).(func()), args.Error(2)
+}
+
+// newImageUpload builds a multipart request carrying a single image field
+func newImageUpload(t *testing.T, target, filename string, content []byte) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", filename)
+	assert.NoError(t, err)
+	part.Write(content)
+	writer.Close()
+
+	req := httptest.NewRequest("POST", target, body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
 func TestAnalyzeGypsum_NoFile(t *testing.T) {
 	// Setup
 	gin.SetMode(gin.TestMode)
@@ -47,7 +294,7 @@ func TestAnalyzeGypsum_NoFile(t *testing.T) {
 
 	mockService := new(MockAnalysisService)
 	logger := logger.New("info")
-	handler := NewAnalysisHandler(mockService, logger)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
 
 	// Test
 	handler.AnalyzeGypsum(c)
@@ -55,10 +302,27 @@ func TestAnalyzeGypsum_NoFile(t *testing.T) {
 	// Assert
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
-	var response map[string]interface{}
+	var response models.APIError
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "No image file provided", response["error"])
+	assert.Equal(t, "No image file provided", response.Message)
+}
+
+func TestAnalyzeGypsum_NoFileErrorCode(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("POST", "/api/v1/analysis/gypsum", nil)
+
+	handler := NewAnalysisHandler(new(MockAnalysisService), &config.Config{}, logger.New("info"))
+	handler.AnalyzeGypsum(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response models.APIError
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "no_file", response.Code)
+	assert.Equal(t, []interface{}{"image", "file"}, response.Details["fields"])
 }
 
 func TestAnalyzeGypsum_InvalidFileType(t *testing.T) {
@@ -85,7 +349,7 @@ func TestAnalyzeGypsum_InvalidFileType(t *testing.T) {
 
 	mockService := new(MockAnalysisService)
 	logger := logger.New("info")
-	handler := NewAnalysisHandler(mockService, logger)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
 
 	// Test
 	handler.AnalyzeGypsum(c)
@@ -93,10 +357,11 @@ func TestAnalyzeGypsum_InvalidFileType(t *testing.T) {
 	// Assert
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
-	var response map[string]interface{}
+	var response models.APIError
 	err = json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Contains(t, response["error"], "Unsupported file type")
+	assert.Equal(t, "unsupported_type", response.Code)
+	assert.Contains(t, response.Message, "Unsupported file type")
 }
 
 func TestGetAnalysisStatus_NoID(t *testing.T) {
@@ -107,7 +372,7 @@ func TestGetAnalysisStatus_NoID(t *testing.T) {
 
 	mockService := new(MockAnalysisService)
 	logger := logger.New("info")
-	handler := NewAnalysisHandler(mockService, logger)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
 
 	// Test
 	handler.GetAnalysisStatus(c)
@@ -115,10 +380,11 @@ func TestGetAnalysisStatus_NoID(t *testing.T) {
 	// Assert
 	assert.Equal(t, http.StatusBadRequest, w.Code)
 
-	var response map[string]interface{}
+	var response models.APIError
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "Analysis ID is required", response["error"])
+	assert.Equal(t, models.ErrCodeInvalidInput, response.Code)
+	assert.Equal(t, "Analysis ID is required", response.Message)
 }
 
 func TestGetAnalysisStatus_NotFound(t *testing.T) {
@@ -130,10 +396,10 @@ func TestGetAnalysisStatus_NotFound(t *testing.T) {
 	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
 
 	mockService := new(MockAnalysisService)
-	mockService.On("GetAnalysisStatus", "test-id").Return(nil, assert.AnError)
+	mockService.On("GetAnalysisStatus", "test-id").Return(nil, services.ErrAnalysisNotFound)
 
 	logger := logger.New("info")
-	handler := NewAnalysisHandler(mockService, logger)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
 
 	// Test
 	handler.GetAnalysisStatus(c)
@@ -141,10 +407,1889 @@ func TestGetAnalysisStatus_NotFound(t *testing.T) {
 	// Assert
 	assert.Equal(t, http.StatusNotFound, w.Code)
 
-	var response map[string]interface{}
+	var response models.APIError
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "not_found", response.Code)
+	assert.Equal(t, "Analysis not found", response.Message)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetAnalysisStatus_IncludeUnits(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+
+	result := &models.AnalysisResult{
+		ID:               "test-id",
+		Status:           models.StatusCompleted,
+		PurityPercentage: 88,
+		SpacingStats:     &models.SpacingStats{ParticleCount: 12, Mean: 4.5, Unit: "px"},
+	}
+	mockService := new(MockAnalysisService)
+	mockService.On("GetAnalysisStatus", "test-id").Return(result, nil)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	for _, target := range []string{"/status/test-id", "/status/test-id?include_units=true"} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+		c.Request = httptest.NewRequest("GET", target, nil)
+
+		// Test
+		handler.GetAnalysisStatus(c)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, w.Code)
+
+		var response models.AnalysisResult
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		if !strings.Contains(target, "include_units") {
+			assert.Nil(t, response.Units)
+			continue
+		}
+		assert.Equal(t, "%", response.Units["purity_percentage"])
+		assert.Equal(t, "px", response.Units["spacing_stats.mean"])
+		assert.Equal(t, "px^2", response.Units["average_particle_size_um"])
+	}
+
+	// The stored result is not modified
+	assert.Nil(t, result.Units)
+}
+
+func TestAnalyzeGypsum_WaitRequestDeadline(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newImageUpload(t, "/api/v1/analysis/gypsum?wait=true", "sample.png", []byte("png"))
+	c.Request.Header.Set("X-Request-Timeout", "1")
+
+	mockService := new(MockAnalysisService)
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockService.On("WaitForAnalysis", mock.Anything, mock.Anything).Return(
+		&models.AnalysisResult{Status: models.StatusProcessing}, services.ErrWaitTimeout)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{RequestTimeout: 60}, logger)
+
+	// Test
+	handler.AnalyzeGypsum(c)
+
+	// Assert
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	var response models.APIError
 	err := json.Unmarshal(w.Body.Bytes(), &response)
 	assert.NoError(t, err)
-	assert.Equal(t, "Analysis not found", response["error"])
+	assert.Equal(t, models.ErrCodeTimeout, response.Code)
+	assert.Equal(t, "request", response.Details["timeout"])
+	assert.Equal(t, "processing", response.Details["status"])
+	assert.NotEmpty(t, response.Details["analysis_id"])
+	assert.Equal(t, "processing", response.Details["result"].(map[string]interface{})["status"])
+}
+
+func TestAnalyzeGypsum_WaitHeader(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newImageUpload(t, "/api/v1/analysis/gypsum", "sample.png", []byte("png"))
+	c.Request.Header.Set("X-Wait", "true")
+
+	completed := &models.AnalysisResult{ID: "test-id", Status: models.StatusCompleted, PurityPercentage: 88}
+	mockService := new(MockAnalysisService)
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	mockService.On("WaitForAnalysis", mock.Anything, mock.Anything).Return(completed, nil)
+	handler := NewAnalysisHandler(mockService, &config.Config{RequestTimeout: 60}, logger.New("info"))
+
+	handler.AnalyzeGypsum(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var result models.AnalysisResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	assert.Equal(t, 88.0, result.PurityPercentage)
+	mockService.AssertExpectations(t)
+}
+
+func TestAnalyzeGypsum_WaitAnalysisTimeout(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newImageUpload(t, "/api/v1/analysis/gypsum?wait=true", "sample.png", []byte("png"))
+
+	mockService := new(MockAnalysisService)
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil).Maybe()
+	mockService.On("WaitForAnalysis", mock.Anything, mock.Anything).Return(
+		&models.AnalysisResult{Status: models.StatusFailed, Error: "analysis timed out after 300 seconds"}, services.ErrAnalysisTimeout)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{RequestTimeout: 60}, logger)
+
+	// Test
+	handler.AnalyzeGypsum(c)
+
+	// Assert
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+
+	var response models.APIError
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, models.ErrCodeTimeout, response.Code)
+	assert.Equal(t, "analysis timed out after 300 seconds", response.Message)
+	assert.Equal(t, "analysis", response.Details["timeout"])
+	assert.Equal(t, "failed", response.Details["status"])
+}
 
+func TestAnalyzeGypsum_WaitInvalidTimeoutHeader(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newImageUpload(t, "/api/v1/analysis/gypsum?wait=true", "sample.png", []byte("png"))
+	c.Request.Header.Set("X-Request-Timeout", "soon")
+
+	mockService := new(MockAnalysisService)
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.AnalyzeGypsum(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAnnotateAnalysis_Override(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	c.Request = httptest.NewRequest("POST", "/api/v1/analysis/status/test-id/annotate",
+		strings.NewReader(`{"reviewed_by":"supervisor","note":"Dust on lens","override_purity":82.5}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	override := 82.5
+	annotated := &models.AnalysisResult{
+		ID:                   "test-id",
+		Status:               models.StatusCompleted,
+		PurityPercentage:     91.0,
+		ReviewedBy:           "supervisor",
+		ReviewNote:           "Dust on lens",
+		ManualOverride:       true,
+		ManualOverridePurity: &override,
+	}
+
+	mockService := new(MockAnalysisService)
+	mockService.On("AnnotateAnalysis", "test-id", mock.MatchedBy(func(a models.Annotation) bool {
+		return a.ReviewedBy == "supervisor" && a.OverridePurity != nil && *a.OverridePurity == 82.5
+	})).Return(annotated, nil)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.AnnotateAnalysis(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response map[string]interface{}
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, true, response["manual_override"])
+	assert.Equal(t, 82.5, response["manual_override_purity"])
+	assert.Equal(t, 91.0, response["purity_percentage"])
+
+	mockService.AssertExpectations(t)
+}
+
+func TestAnnotateAnalysis_InvalidOverride(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	c.Request = httptest.NewRequest("POST", "/api/v1/analysis/status/test-id/annotate",
+		strings.NewReader(`{"reviewed_by":"supervisor","override_purity":150}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	mockService := new(MockAnalysisService)
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.AnnotateAnalysis(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "AnnotateAnalysis", mock.Anything, mock.Anything)
+}
+
+func TestAnnotateAnalysis_StillProcessing(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	c.Request = httptest.NewRequest("POST", "/api/v1/analysis/status/test-id/annotate",
+		strings.NewReader(`{"reviewed_by":"supervisor","note":"Looks fine"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	mockService := new(MockAnalysisService)
+	mockService.On("AnnotateAnalysis", "test-id", mock.Anything).Return(nil, services.ErrAnalysisInProgress)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.AnnotateAnalysis(c)
+
+	// Assert
+	assert.Equal(t, http.StatusConflict, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestAnalyzeGypsum_FileTooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	const limit = 1024
+	c.Request = newImageUpload(t, "/api/v1/analysis/gypsum", "sample.png", make([]byte, limit+1))
+
+	mockService := new(MockAnalysisService)
+	handler := NewAnalysisHandler(mockService, &config.Config{MaxFileSize: limit}, logger.New("info"))
+
+	handler.AnalyzeGypsum(c)
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+	assert.Contains(t, w.Body.String(), "1024 bytes")
+	mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAnalyzeGypsum_QueueFull(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newImageUpload(t, "/api/v1/analysis/gypsum", "sample.png", []byte("png"))
+
+	mockService := new(MockAnalysisService)
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(services.ErrQueueFull)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.AnalyzeGypsum(c)
+
+	// Assert
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "30", w.Header().Get("Retry-After"))
+	mockService.AssertExpectations(t)
+}
+
+func TestAnalyzeGypsum_IdempotencyKey(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{IdempotencyKeyTTL: 3600}
+
+	t.Run("first upload", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newImageUpload(t, "/api/v1/analysis/gypsum", "sample.png", []byte("png"))
+		c.Request.Header.Set("Idempotency-Key", "upload-42")
+
+		var reservedID string
+		mockService := new(MockAnalysisService)
+		mockService.On("ReserveIdempotencyKey", mock.Anything, "upload-42", mock.Anything).
+			Run(func(args mock.Arguments) { reservedID = args.String(2) }).
+			Return("", true)
+		mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+		handler := NewAnalysisHandler(mockService, cfg, logger.New("info"))
+
+		handler.AnalyzeGypsum(c)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Contains(t, w.Body.String(), reservedID)
+		assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("retry", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newImageUpload(t, "/api/v1/analysis/gypsum", "sample.png", []byte("png"))
+		c.Request.Header.Set("Idempotency-Key", "upload-42")
+
+		mockService := new(MockAnalysisService)
+		mockService.On("ReserveIdempotencyKey", mock.Anything, "upload-42", mock.Anything).Return("original-id", false)
+		mockService.On("GetAnalysisStatus", "original-id").Return(&models.AnalysisResult{ID: "original-id", Status: models.StatusCompleted}, nil)
+		handler := NewAnalysisHandler(mockService, cfg, logger.New("info"))
+
+		handler.AnalyzeGypsum(c)
+
+		assert.Equal(t, http.StatusAccepted, w.Code)
+		assert.Equal(t, "true", w.Header().Get("Idempotent-Replayed"))
+		var body map[string]string
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+		assert.Equal(t, "original-id", body["analysis_id"])
+		assert.Equal(t, "completed", body["status"])
+		mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("failed submission releases the key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newImageUpload(t, "/api/v1/analysis/gypsum", "sample.png", []byte("png"))
+		c.Request.Header.Set("Idempotency-Key", "upload-43")
+
+		mockService := new(MockAnalysisService)
+		mockService.On("ReserveIdempotencyKey", mock.Anything, "upload-43", mock.Anything).Return("", true)
+		mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(services.ErrQueueFull)
+		mockService.On("ReleaseIdempotencyKey", mock.Anything, "upload-43").Return()
+		handler := NewAnalysisHandler(mockService, cfg, logger.New("info"))
+
+		handler.AnalyzeGypsum(c)
+
+		assert.Equal(t, http.StatusTooManyRequests, w.Code)
+		mockService.AssertExpectations(t)
+	})
+
+	t.Run("oversized key", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newImageUpload(t, "/api/v1/analysis/gypsum", "sample.png", []byte("png"))
+		c.Request.Header.Set("Idempotency-Key", strings.Repeat("k", 256))
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, cfg, logger.New("info"))
+
+		handler.AnalyzeGypsum(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestAnalyzeGypsum_UnsupportedContent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"not an image", services.ErrUnsupportedMIME, http.StatusUnsupportedMediaType, "unsupported_media"},
+		{"wrong image type", services.ErrImageTypeMismatch, http.StatusBadRequest, "type_mismatch"},
+		{"too many pixels", services.ErrImageTooLarge, http.StatusUnprocessableEntity, "unprocessable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = newImageUpload(t, "/api/v1/analysis/gypsum", "sample.jpg", []byte("MZ renamed executable"))
+
+			mockService := new(MockAnalysisService)
+			mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.err)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.AnalyzeGypsum(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			var response models.APIError
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.code, response.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestStreamAnalysisStatus_SendsFinalResult(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis/status/test-id/stream", nil)
+
+	completed := &models.AnalysisResult{ID: "test-id", Status: models.StatusCompleted, PurityPercentage: 91.5}
+	mockService := new(MockAnalysisService)
+	mockService.On("GetAnalysisStatus", "test-id").Return(&models.AnalysisResult{ID: "test-id", Status: models.StatusProcessing}, nil)
+	mockService.On("WaitForAnalysis", mock.Anything, "test-id").Return(completed, nil)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.StreamAnalysisStatus(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+	body := w.Body.String()
+	require.True(t, strings.HasPrefix(body, "data: "), body)
+	require.True(t, strings.HasSuffix(body, "\n\n"), body)
+
+	var result models.AnalysisResult
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(strings.TrimPrefix(body, "data: "))), &result))
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, 91.5, result.PurityPercentage)
+	mockService.AssertExpectations(t)
+}
+
+func TestStreamAnalysisEvents(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis/test-id/events", nil)
+
+	updates := make(chan *models.AnalysisResult, 2)
+	updates <- &models.AnalysisResult{ID: "test-id", Status: models.StatusProcessing}
+	updates <- &models.AnalysisResult{ID: "test-id", Status: models.StatusCompleted, PurityPercentage: 91.5}
+	close(updates)
+
+	unsubscribed := false
+	mockService := new(MockAnalysisService)
+	mockService.On("Subscribe", "test-id").Return((<-chan *models.AnalysisResult)(updates), func() { unsubscribed = true }, nil)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.StreamAnalysisEvents(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+	assert.True(t, unsubscribed)
+
+	events := strings.Split(strings.TrimSuffix(w.Body.String(), "\n\n"), "\n\n")
+	require.Len(t, events, 2)
+	assert.True(t, strings.HasPrefix(events[0], "event: processing\ndata: "), events[0])
+	require.True(t, strings.HasPrefix(events[1], "event: completed\ndata: "), events[1])
+
+	var result models.AnalysisResult
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(events[1], "event: completed\ndata: ")), &result))
+	assert.Equal(t, 91.5, result.PurityPercentage)
+}
+
+func TestStreamAnalysisEvents_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "missing"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis/missing/events", nil)
+
+	mockService := new(MockAnalysisService)
+	mockService.On("Subscribe", "missing").Return(nil, nil, services.ErrAnalysisNotFound)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.StreamAnalysisEvents(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestStreamAnalysisStatus_KeepsAliveUntilDone(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis/status/test-id/stream", nil)
+
+	processing := &models.AnalysisResult{ID: "test-id", Status: models.StatusProcessing}
+	mockService := new(MockAnalysisService)
+	mockService.On("GetAnalysisStatus", "test-id").Return(processing, nil)
+	mockService.On("WaitForAnalysis", mock.Anything, "test-id").Return(processing, services.ErrWaitTimeout).Once()
+	mockService.On("WaitForAnalysis", mock.Anything, "test-id").
+		Return(&models.AnalysisResult{ID: "test-id", Status: models.StatusFailed, Error: "boom"}, nil).Once()
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.StreamAnalysisStatus(c)
+
+	body := w.Body.String()
+	assert.True(t, strings.HasPrefix(body, ": keepalive\n\ndata: "), body)
+	assert.Contains(t, body, `"status":"failed"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestStreamAnalysisStatus_NotFound(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "missing"}}
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis/status/missing/stream", nil)
+
+	mockService := new(MockAnalysisService)
+	mockService.On("GetAnalysisStatus", "missing").Return(nil, services.ErrAnalysisNotFound)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.StreamAnalysisStatus(c)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	mockService.AssertNotCalled(t, "WaitForAnalysis", mock.Anything, mock.Anything)
+}
+
+func TestListAnalyses(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis?status=completed&page=3&per_page=2&sort=-created_at&min_purity=80&max_purity=100&from=2024-05-01T00:00:00Z&to=2024-06-01T00:00:00Z", nil)
+
+	minPurity, maxPurity := 80.0, 100.0
+	expected := services.ListFilter{
+		Status:    models.StatusCompleted,
+		MinPurity: &minPurity,
+		MaxPurity: &maxPurity,
+		From:      time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC),
+		To:        time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC),
+		Sort:      "-created_at",
+		Limit:     2,
+		Offset:    4,
+	}
+	page := []*models.AnalysisResult{
+		{ID: "a", Status: models.StatusCompleted},
+		{ID: "b", Status: models.StatusCompleted},
+	}
+	mockService := new(MockAnalysisService)
+	mockService.On("ListAnalyses", expected).Return(page, 9, nil)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.ListAnalyses(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		Data       []models.AnalysisResult `json:"data"`
+		Total      int                     `json:"total"`
+		Page       int                     `json:"page"`
+		PerPage    int                     `json:"per_page"`
+		TotalPages int                     `json:"total_pages"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Len(t, response.Data, 2)
+	assert.Equal(t, 9, response.Total)
+	assert.Equal(t, 3, response.Page)
+	assert.Equal(t, 2, response.PerPage)
+	assert.Equal(t, 5, response.TotalPages)
+	mockService.AssertExpectations(t)
+}
+
+func TestListAnalyses_EmptyPage(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis", nil)
+
+	mockService := new(MockAnalysisService)
+	mockService.On("ListAnalyses", services.ListFilter{Sort: "created_at", Limit: 20}).
+		Return([]*models.AnalysisResult{}, 0, nil)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.ListAnalyses(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"data": [], "total": 0, "page": 1, "per_page": 20, "total_pages": 0}`, w.Body.String())
+}
+
+func TestListAnalyses_InvalidQuery(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		query  string
+		status int
+	}{
+		{"status=done", http.StatusBadRequest},
+		{"page=0", http.StatusBadRequest},
+		{"page=x", http.StatusBadRequest},
+		{"per_page=0", http.StatusBadRequest},
+		{"per_page=x", http.StatusBadRequest},
+		{"per_page=101", http.StatusUnprocessableEntity},
+		{"min_purity=-1", http.StatusBadRequest},
+		{"max_purity=high", http.StatusBadRequest},
+		{"from=yesterday", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/analysis?"+tt.query, nil)
+
+			mockService := new(MockAnalysisService)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.ListAnalyses(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertNotCalled(t, "ListAnalyses", mock.Anything)
+		})
+	}
+}
+
+func TestDeleteAnalysis(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		cancelled bool
+		err       error
+		status    int
+	}{
+		{"deleted", false, nil, http.StatusNoContent},
+		{"cancelled", true, nil, http.StatusAccepted},
+		{"unknown", false, services.ErrAnalysisNotFound, http.StatusNotFound},
+		{"finishing", false, services.ErrAnalysisInProgress, http.StatusConflict},
+		{"store error", false, errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+
+			mockService := new(MockAnalysisService)
+			mockService.On("DeleteAnalysis", "test-id").Return(tt.cancelled, tt.err)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.DeleteAnalysis(c)
+			c.Writer.WriteHeaderNow()
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusNoContent {
+				assert.Empty(t, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCancelAnalysis(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"cancelled", nil, http.StatusAccepted},
+		{"unknown", services.ErrAnalysisNotFound, http.StatusNotFound},
+		{"finished", services.ErrAnalysisFinished, http.StatusConflict},
+		{"finishing", services.ErrAnalysisInProgress, http.StatusConflict},
+		{"store error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+
+			mockService := new(MockAnalysisService)
+			mockService.On("CancelAnalysis", "test-id").Return(tt.err)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.CancelAnalysis(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+// newArchiveUpload builds a multipart request carrying a batch archive field
+func newArchiveUpload(t *testing.T, filename string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("archive", filename)
+	assert.NoError(t, err)
+	part.Write([]byte("PK"))
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/analysis/gypsum/batch", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestAnalyzeBatch(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	batch := &models.BatchResult{BatchID: "batch-1", AnalysisIDs: []string{"a", "b"}}
+	tests := []struct {
+		name     string
+		filename string
+		result   *models.BatchResult
+		err      error
+		status   int
+	}{
+		{"queued", "samples.zip", batch, nil, http.StatusAccepted},
+		{"not a zip", "samples.tar", nil, nil, http.StatusBadRequest},
+		{"invalid archive", "samples.zip", nil, services.ErrInvalidArchive, http.StatusBadRequest},
+		{"no images", "samples.zip", nil, services.ErrEmptyBatch, http.StatusBadRequest},
+		{"queue full", "samples.zip", nil, services.ErrQueueFull, http.StatusTooManyRequests},
+		{"shutting down", "samples.zip", nil, services.ErrShuttingDown, http.StatusServiceUnavailable},
+		{"unknown profile", "samples.zip", nil, services.ErrProfileNotFound, http.StatusBadRequest},
+		{"too many images", "samples.zip", nil, services.ErrBatchTooLarge, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = newArchiveUpload(t, tt.filename)
+
+			mockService := new(MockAnalysisService)
+			if tt.result != nil || tt.err != nil {
+				mockService.On("SubmitBatch", mock.Anything, mock.Anything).Return(tt.result, tt.err)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.AnalyzeBatch(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.result != nil {
+				assert.JSONEq(t, `{"batch_id": "batch-1", "analysis_ids": ["a", "b"]}`, w.Body.String())
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetBatchStatus(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("found", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "batch_id", Value: "batch-1"}}
+
+		mockService := new(MockAnalysisService)
+		mockService.On("GetBatchSummary", "batch-1").Return(&models.BatchSummary{BatchID: "batch-1", Total: 2, Completed: 1, CompletionPercentage: 50}, nil)
+		handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+		handler.GetBatchStatus(c)
+
+		assert.Equal(t, http.StatusOK, w.Code)
+		var summary models.BatchSummary
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &summary))
+		assert.Equal(t, 50.0, summary.CompletionPercentage)
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Params = gin.Params{{Key: "batch_id", Value: "missing"}}
+
+		mockService := new(MockAnalysisService)
+		mockService.On("GetBatchSummary", "missing").Return(nil, services.ErrBatchNotFound)
+		handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+		handler.GetBatchStatus(c)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
+
+func TestGetPuritySummary(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	from := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		query  string
+		setup  func(m *MockAnalysisService)
+		status int
+	}{
+		{"batch", "batch_id=batch-1", func(m *MockAnalysisService) {
+			m.On("SummarizePurity", "batch-1", time.Time{}, time.Time{}).Return(&models.PuritySummary{BatchID: "batch-1", Count: 2, MeanPurity: 85}, nil)
+		}, http.StatusOK},
+		{"time range", "from=2024-05-01T00:00:00Z", func(m *MockAnalysisService) {
+			m.On("SummarizePurity", "", from, time.Time{}).Return(&models.PuritySummary{Count: 3}, nil)
+		}, http.StatusOK},
+		{"no scope", "", nil, http.StatusBadRequest},
+		{"invalid time", "to=yesterday", nil, http.StatusBadRequest},
+		{"unknown batch", "batch_id=missing", func(m *MockAnalysisService) {
+			m.On("SummarizePurity", "missing", time.Time{}, time.Time{}).Return(nil, services.ErrBatchNotFound)
+		}, http.StatusNotFound},
+		{"too few results", "batch_id=batch-2", func(m *MockAnalysisService) {
+			m.On("SummarizePurity", "batch-2", time.Time{}, time.Time{}).Return(nil, services.ErrTooFewResults)
+		}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/analysis/summary?"+tt.query, nil)
+
+			mockService := new(MockAnalysisService)
+			if tt.setup != nil {
+				tt.setup(mockService)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.GetPuritySummary(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+			if tt.setup == nil {
+				mockService.AssertNotCalled(t, "SummarizePurity", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestCompareAnalyses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		query  string
+		setup  func(m *MockAnalysisService)
+		status int
+	}{
+		{"same sample", "a=raw&b=calcined", func(m *MockAnalysisService) {
+			m.On("CompareAnalyses", "raw", "calcined", false).Return(&models.ComparisonResult{PurityDelta: 3.5, Significant: true}, nil)
+		}, http.StatusOK},
+		{"cross sample", "a=raw&b=other&allow_cross_sample=true", func(m *MockAnalysisService) {
+			m.On("CompareAnalyses", "raw", "other", true).Return(&models.ComparisonResult{}, nil)
+		}, http.StatusOK},
+		{"missing b", "a=raw", nil, http.StatusBadRequest},
+		{"unknown analysis", "a=raw&b=missing", func(m *MockAnalysisService) {
+			m.On("CompareAnalyses", "raw", "missing", false).Return(nil, fmt.Errorf("%w: missing", services.ErrAnalysisNotFound))
+		}, http.StatusNotFound},
+		{"not completed", "a=raw&b=running", func(m *MockAnalysisService) {
+			m.On("CompareAnalyses", "raw", "running", false).Return(nil, fmt.Errorf("%w: running is processing", services.ErrAnalysisNotCompleted))
+		}, http.StatusUnprocessableEntity},
+		{"different samples", "a=raw&b=other", func(m *MockAnalysisService) {
+			m.On("CompareAnalyses", "raw", "other", false).Return(nil, services.ErrSampleMismatch)
+		}, http.StatusUnprocessableEntity},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/analysis/compare?"+tt.query, nil)
+
+			mockService := new(MockAnalysisService)
+			if tt.setup != nil {
+				tt.setup(mockService)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.CompareAnalyses(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+			if tt.setup == nil {
+				mockService.AssertNotCalled(t, "CompareAnalyses", mock.Anything, mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+// newProfileUpload builds an image upload carrying extra form fields
+func newProfileUpload(t *testing.T, fields map[string]string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "sample.png")
+	assert.NoError(t, err)
+	part.Write([]byte("png"))
+	for key, value := range fields {
+		assert.NoError(t, writer.WriteField(key, value))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/analysis/gypsum", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestAnalyzeGypsum_UnknownProfile(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newProfileUpload(t, map[string]string{"profile": "missing"})
+
+	mockService := new(MockAnalysisService)
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.AnalyzeGypsum(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, map[string]string{"profile": `unknown profile "missing"`}, invalidFields(t, w))
+	mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+// invalidFields decodes the reasons of a 400 response for invalid settings
+func invalidFields(t *testing.T, w *httptest.ResponseRecorder) map[string]string {
+	t.Helper()
+
+	var response struct {
+		Code    string `json:"code"`
+		Details struct {
+			Fields map[string]string `json:"fields"`
+		} `json:"details"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, models.ErrCodeInvalidInput, response.Code)
+	return response.Details.Fields
+}
+
+func TestAnalyzeGypsum_InvalidSettingsReportedTogether(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		want   map[string]string
+	}{
+		{
+			name: "every bad field",
+			fields: map[string]string{
+				"pixels_per_micron": "fast",
+				"location_lat":      "91",
+				"sampling_depth_m":  "-2",
+				"timeout_seconds":   "1.5",
+				"min_circularity":   "1.2",
+				"gaussian_sigma":    "NaN",
+				"roi_x":             "-1",
+				"roi_y":             "0",
+				"roi_width":         "10",
+				"roi_height":        "10",
+			},
+			want: map[string]string{
+				"pixels_per_micron": "must be a number",
+				"location_lat":      "must be at most 90",
+				"sampling_depth_m":  "must be at least 0",
+				"timeout_seconds":   "must be a whole number",
+				"min_circularity":   "must be at most 1",
+				"gaussian_sigma":    "must be at least 0",
+				"roi_x":             "must be at least 0",
+			},
+		},
+		{
+			name:   "incomplete rectangle",
+			fields: map[string]string{"roi_x": "1", "roi_width": "10"},
+			want: map[string]string{
+				"roi_y":      "must be given together with roi_x, roi_width and roi_height",
+				"roi_height": "must be given together with roi_x, roi_y and roi_width",
+			},
+		},
+		{
+			name:   "scale and scale bar",
+			fields: map[string]string{"pixels_per_micron": "2", "scale_bar_length": "100", "scale_bar_pixels": "Inf"},
+			want: map[string]string{
+				"pixels_per_micron": "cannot be combined with scale_bar_length and scale_bar_pixels",
+				"scale_bar_pixels":  "must be a finite number",
+			},
+		},
+		{
+			name:   "particle filter",
+			fields: map[string]string{"min_particle_size": "50", "max_particle_size": "10", "threshold_method": "Otsu"},
+			want: map[string]string{
+				"max_particle_size": "invalid particle filter: max_particle_size 10 is below min_particle_size 50",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = newProfileUpload(t, tt.fields)
+
+			mockService := new(MockAnalysisService)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+			handler.AnalyzeGypsum(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			assert.Equal(t, tt.want, invalidFields(t, w))
+			mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+		})
+	}
+}
+
+func TestAnalyzeGypsum_InvalidCallbackURL(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newProfileUpload(t, map[string]string{"callback_url": "ftp://example.com/hook"})
+
+	mockService := new(MockAnalysisService)
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.AnalyzeGypsum(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestAnalyzeGypsum_ProfileOptions(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newProfileUpload(t, map[string]string{
+		"profile":          "plant-a",
+		"metadata[sample]": "S-17",
+	})
+
+	cfg := &config.Config{Profiles: map[string]config.ProfileConfig{"plant-a": {}}}
+	expected := services.AnalysisOptions{Profile: "plant-a", Metadata: map[string]string{"sample": "S-17"}, Owner: "ip:192.0.2.1"}
+
+	mockService := new(MockAnalysisService)
+	called := make(chan struct{})
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, expected).
+		Return(nil).Run(func(mock.Arguments) { close(called) })
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, cfg, logger)
+
+	// Test
+	handler.AnalyzeGypsum(c)
+
+	// Assert
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	<-called
+	mockService.AssertExpectations(t)
+}
+
+func TestAnalyzeGypsum_InvalidROIFile(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", "sample.png")
+	assert.NoError(t, err)
+	part.Write([]byte("png"))
+	part, err = writer.CreateFormFile("roi_file", "regions.roi")
+	assert.NoError(t, err)
+	part.Write([]byte("not an roi"))
+	writer.Close()
+
+	c.Request = httptest.NewRequest("POST", "/api/v1/analysis/gypsum", body)
+	c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+	mockService := new(MockAnalysisService)
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.AnalyzeGypsum(c)
+
+	// Assert
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "invalid ROI file")
+	mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestGetPurityTrend_JSON(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis/trend?sample_group=line-1&from=2024-01-01T00:00:00Z", nil)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	points := []models.TrendPoint{
+		{AnalysisID: "a", Timestamp: from.Add(time.Hour), PurityPercentage: 81.5},
+		{AnalysisID: "b", Timestamp: from.Add(2 * time.Hour), PurityPercentage: 84},
+	}
+	mockService := new(MockAnalysisService)
+	mockService.On("GetPurityTrend", "line-1", from, time.Time{}).Return(points, nil)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.GetPurityTrend(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response struct {
+		SampleGroup string              `json:"sample_group"`
+		Points      []models.TrendPoint `json:"points"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "line-1", response.SampleGroup)
+	assert.Len(t, response.Points, 2)
+	assert.Equal(t, "a", response.Points[0].AnalysisID)
+	assert.Equal(t, 84.0, response.Points[1].PurityPercentage)
+
+	mockService.AssertExpectations(t)
+}
+
+func TestGetPurityTrend_PNG(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis/trend?sample_group=line-1&format=png", nil)
+
+	start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+	points := []models.TrendPoint{
+		{AnalysisID: "a", Timestamp: start, PurityPercentage: 78},
+		{AnalysisID: "b", Timestamp: start.Add(30 * time.Minute), PurityPercentage: 83},
+		{AnalysisID: "c", Timestamp: start.Add(time.Hour), PurityPercentage: 80},
+	}
+	mockService := new(MockAnalysisService)
+	mockService.On("GetPurityTrend", "line-1", time.Time{}, time.Time{}).Return(points, nil)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger)
+
+	// Test
+	handler.GetPurityTrend(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+	assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("\x89PNG")), "response should be a PNG image")
+}
+
+func TestGetPurityTrend_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		target string
+		status int
+	}{
+		{"missing group", "/trend", http.StatusBadRequest},
+		{"bad from", "/trend?sample_group=line-1&from=yesterday", http.StatusBadRequest},
+		{"bad format", "/trend?sample_group=line-1&format=svg", http.StatusBadRequest},
+		{"empty chart", "/trend?sample_group=line-1&format=png", http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", tt.target, nil)
+
+			mockService := new(MockAnalysisService)
+			mockService.On("GetPurityTrend", "line-1", time.Time{}, time.Time{}).Return([]models.TrendPoint{}, nil)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.GetPurityTrend(c)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestExportAnalysis_CSV(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	c.Request = httptest.NewRequest("GET", "/status/test-id/export?units=si&format=csv&sig_figs=3", nil)
+
+	export := &models.SIExport{
+		AnalysisID:         "test-id",
+		SignificantFigures: 3,
+		Calibration:        &models.Calibration{PixelsPerMicron: 2},
+		Quantities: []models.Quantity{
+			{Name: "purity_percentage", Value: 87.7, Unit: "%"},
+			{Name: "total_area", Value: 1.37e-9, Unit: "m^2"},
+		},
+	}
+	mockService := new(MockAnalysisService)
+	mockService.On("ExportSI", "test-id", 3).Return(export, nil)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{ExportSignificantFigures: 4}, logger)
+
+	// Test
+	handler.ExportAnalysis(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Header().Get("Content-Disposition"), "attachment")
+	assert.Equal(t, "quantity,value,unit\npurity_percentage,87.7,%\ntotal_area,1.37e-09,m^2\n", w.Body.String())
+	mockService.AssertExpectations(t)
+}
+
+func TestExportResults_CSV(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis/export?format=csv&from=2024-01-01T00:00:00Z&to=2024-02-01T00:00:00Z", nil)
+
+	from := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	to := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	completedAt := from.Add(time.Hour + 30*time.Second)
+	results := []*models.AnalysisResult{{
+		ID:               "a",
+		Status:           models.StatusCompleted,
+		CreatedAt:        from.Add(time.Hour),
+		CompletedAt:      &completedAt,
+		PurityPercentage: 82.5,
+		GypsumContent:    82.5,
+		ImpurityContent:  17.5,
+		ParticleCount:    140,
+		Confidence:       0.9,
+		AnalysisTime:     30000,
+	}}
+	mockService := new(MockAnalysisService)
+	mockService.On("ListCompletedBetween", from, to).Return(results, nil)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	// Test
+	handler.ExportResults(c)
+
+	// Assert
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+	assert.Equal(t, `attachment; filename="gypsum_results.csv"`, w.Header().Get("Content-Disposition"))
+
+	rows, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 2)
+	assert.Equal(t, "id", rows[0][0])
+	assert.Equal(t, "analysis_time_ms", rows[0][11])
+	assert.Equal(t, []string{"a", "2024-01-01T01:00:00Z", "2024-01-01T01:00:30Z", "82.5", "82.5", "17.5", "0", "0", "0", "140", "0.9", "30000", "", "", "", "", "", "", "0", "", ""}, rows[1], "no sample metadata")
+	mockService.AssertExpectations(t)
+}
+
+func TestExportResults_InvalidRequests(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, query := range []string{
+		"format=json",
+		"from=yesterday",
+		"from=2024-02-01T00:00:00Z&to=2024-01-01T00:00:00Z",
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("GET", "/api/v1/analysis/export?"+query, nil)
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+		handler.ExportResults(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, query)
+		mockService.AssertNotCalled(t, "ListCompletedBetween", mock.Anything, mock.Anything)
+	}
+}
+
+func TestExportAnalysis_NotCalibrated(t *testing.T) {
+	// Setup
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+	c.Request = httptest.NewRequest("GET", "/status/test-id/export?units=si", nil)
+
+	mockService := new(MockAnalysisService)
+	mockService.On("ExportSI", "test-id", 4).Return(nil, services.ErrNotCalibrated)
+
+	logger := logger.New("info")
+	handler := NewAnalysisHandler(mockService, &config.Config{ExportSignificantFigures: 4}, logger)
+
+	// Test
+	handler.ExportAnalysis(c)
+
+	// Assert
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "calibrated")
+}
+
+func TestAnalyzeGypsum_InvalidCalibration(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, fields := range []map[string]string{
+		{"pixels_per_micron": "0"},
+		{"pixels_per_micron": "-2"},
+		{"pixels_per_micron": "abc"},
+		{"pixels_per_micron": "NaN"},
+		{"scale_bar_length": "100"},
+		{"scale_bar_length": "abc", "scale_bar_pixels": "250"},
+		{"scale_bar_length": "100", "scale_bar_pixels": "-250"},
+		{"pixels_per_micron": "2", "scale_bar_length": "100", "scale_bar_pixels": "250"},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newProfileUpload(t, fields)
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+		handler.AnalyzeGypsum(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, fields)
+		mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestAnalyzeGypsum_InvalidParticleFilter(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, fields := range []map[string]string{
+		{"min_particle_size": "-1"},
+		{"max_particle_size": "abc"},
+		{"min_particle_size": "50", "max_particle_size": "20"},
+		{"min_circularity": "1.5"},
+		{"min_circularity": "-0.1"},
+		{"threshold_method": "Magic"},
+		{"gaussian_sigma": "-2"},
+		{"blur_sigma": "21"},
+		{"contrast_saturation": "wide"},
+		{"contrast_saturation": "100"},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newProfileUpload(t, fields)
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+		handler.AnalyzeGypsum(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, fields)
+		mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestAnalyzeGypsum_InvalidRegion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, fields := range []map[string]string{
+		{"roi_x": "10", "roi_y": "10", "roi_width": "50"},
+		{"roi_x": "a", "roi_y": "10", "roi_width": "50", "roi_height": "50"},
+		{"roi_x": "-1", "roi_y": "10", "roi_width": "50", "roi_height": "50"},
+		{"roi_x": "0", "roi_y": "0", "roi_width": "0", "roi_height": "50"},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newProfileUpload(t, fields)
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+		handler.AnalyzeGypsum(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, fields)
+		mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestAnalyzeGypsum_RegionOutOfBounds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newProfileUpload(t, map[string]string{"roi_x": "10", "roi_y": "20", "roi_width": "500", "roi_height": "40"})
+
+	region := &models.Rectangle{X: 10, Y: 20, Width: 500, Height: 40}
+	mockService := new(MockAnalysisService)
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, services.AnalysisOptions{Metadata: map[string]string{}, Owner: "ip:192.0.2.1", ROI: region}).
+		Return(fmt.Errorf("%w: region does not fit", services.ErrROIOutOfBounds))
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.AnalyzeGypsum(c)
+
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Contains(t, w.Body.String(), "does not fit")
+	mockService.AssertExpectations(t)
+}
+
+func TestAnalyzeGypsum_UnknownProfileID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newProfileUpload(t, map[string]string{"profile_id": "missing"})
+
+	mockService := new(MockAnalysisService)
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, services.AnalysisOptions{Metadata: map[string]string{}, Owner: "ip:192.0.2.1", ProfileID: "missing"}).
+		Return(services.ErrProfileNotFound)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.AnalyzeGypsum(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Unknown profile_id: missing")
+	mockService.AssertExpectations(t)
+}
+
+func TestAnalyzeGypsum_TimeoutSeconds(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	cfg := &config.Config{MinAnalysisTimeout: 10, MaxAnalysisTimeout: 600}
+
+	tests := []struct {
+		value   string
+		timeout time.Duration
+	}{
+		{"120", 120 * time.Second},
+		{"1", 10 * time.Second},
+		{"3600", 600 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = newProfileUpload(t, map[string]string{"timeout_seconds": tt.value})
+
+			mockService := new(MockAnalysisService)
+			mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, services.AnalysisOptions{Metadata: map[string]string{}, Owner: "ip:192.0.2.1", Timeout: tt.timeout}).
+				Return(nil)
+			handler := NewAnalysisHandler(mockService, cfg, logger.New("info"))
+
+			handler.AnalyzeGypsum(c)
+
+			assert.Equal(t, http.StatusAccepted, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newProfileUpload(t, map[string]string{"timeout_seconds": "soon"})
+	handler := NewAnalysisHandler(new(MockAnalysisService), cfg, logger.New("info"))
+
+	handler.AnalyzeGypsum(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+// newImagesUpload builds a multipart request carrying files under images[]
+func newImagesUpload(t *testing.T, filenames ...string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, filename := range filenames {
+		part, err := writer.CreateFormFile("images[]", filename)
+		assert.NoError(t, err)
+		part.Write([]byte("fake image data"))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest("POST", "/api/v1/analysis/batch", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestAnalyzeImages_PartialFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newImagesUpload(t, "a.png", "notes.txt", "b.tif")
+
+	mockService := new(MockAnalysisService)
+	var batchIDs []string
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Run(func(args mock.Arguments) {
+			batchIDs = append(batchIDs, args.Get(3).(services.AnalysisOptions).BatchID)
+		}).
+		Return(nil).Twice()
+	handler := NewAnalysisHandler(mockService, &config.Config{MaxBatchSize: 10}, logger.New("info"))
+
+	handler.AnalyzeImages(c)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	var response models.BatchUpload
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.NotEmpty(t, response.BatchID)
+	assert.Equal(t, []string{response.BatchID, response.BatchID}, batchIDs)
+	if assert.Len(t, response.Files, 3) {
+		assert.Equal(t, "a.png", response.Files[0].Filename)
+		assert.Equal(t, models.StatusPending, response.Files[0].Status)
+		assert.NotEmpty(t, response.Files[0].AnalysisID)
+
+		assert.Equal(t, "notes.txt", response.Files[1].Filename)
+		assert.Equal(t, models.StatusFailed, response.Files[1].Status)
+		assert.Empty(t, response.Files[1].AnalysisID)
+		assert.Equal(t, "unsupported file type", response.Files[1].Error)
+
+		assert.Equal(t, models.StatusPending, response.Files[2].Status)
+	}
+	mockService.AssertExpectations(t)
+}
+
+func TestAnalyzeImages_TooLarge(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	t.Run("too many images", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newImagesUpload(t, "a.png", "b.png", "c.png")
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, &config.Config{MaxBatchSize: 2}, logger.New("info"))
+
+		handler.AnalyzeImages(c)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), "maximum of 2 images")
+		mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+
+	t.Run("oversized body", func(t *testing.T) {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("images[]", "a.png")
+		require.NoError(t, err)
+		part.Write(bytes.Repeat([]byte{0}, formOverhead+1024))
+		writer.Close()
+
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/v1/analysis/batch", body)
+		c.Request.Header.Set("Content-Type", writer.FormDataContentType())
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, &config.Config{MaxBatchSize: 2, MaxFileSize: 100}, logger.New("info"))
+
+		handler.AnalyzeImages(c)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		var response models.APIError
+		assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, models.ErrCodePayloadTooLarge, response.Code)
+		mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	})
+}
+
+func TestAnalyzeImages_NoneQueued(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name      string
+		filenames []string
+		err       error
+		status    int
+		code      string
+	}{
+		{"no images", nil, nil, http.StatusBadRequest, models.ErrCodeNoFile},
+		{"all unsupported", []string{"a.txt", "b.pdf"}, nil, http.StatusBadRequest, models.ErrCodeInvalidInput},
+		{"queue full", []string{"a.png"}, services.ErrQueueFull, http.StatusTooManyRequests, models.ErrCodeRateLimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = newImagesUpload(t, tt.filenames...)
+
+			mockService := new(MockAnalysisService)
+			if tt.err != nil {
+				mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(tt.err)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{MaxBatchSize: 10}, logger.New("info"))
+
+			handler.AnalyzeImages(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			var response models.APIError
+			assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+			assert.Equal(t, tt.code, response.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestExportResult(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	thresholdLower, thresholdUpper := 128.0, 255.0
+	completedAt := time.Date(2024, 1, 1, 1, 0, 30, 0, time.UTC)
+
+	completed := &models.AnalysisResult{
+		ID:               "test-id",
+		Status:           models.StatusCompleted,
+		CreatedAt:        time.Date(2024, 1, 1, 1, 0, 0, 0, time.UTC),
+		CompletedAt:      &completedAt,
+		PurityPercentage: 87.5,
+		GypsumContent:    87.5,
+		ImpurityContent:  12.5,
+		CalciteContent:   5,
+		QuartzContent:    4.5,
+		OtherMinerals:    3,
+		ParticleCount:    42,
+		ThresholdValue:   128,
+		ThresholdLower:   &thresholdLower,
+		ThresholdUpper:   &thresholdUpper,
+		Confidence:       0.9,
+		AnalysisTime:     1500,
+		SampleMetadata:   &models.SampleMetadata{SampleID: "GY-0042", OperatorName: "A. Moreau", LocationLat: 48.85, LocationLon: 2.35, SamplingDepthM: 12.5, Notes: "Core, north face"},
+	}
+	tests := []struct {
+		name   string
+		query  string
+		result *models.AnalysisResult
+		err    error
+		status int
+	}{
+		{"csv", "", completed, nil, http.StatusOK},
+		{"json", "?format=json", completed, nil, http.StatusOK},
+		{"unknown format", "?format=xml", nil, nil, http.StatusBadRequest},
+		{"not found", "", nil, services.ErrAnalysisNotFound, http.StatusNotFound},
+		{"processing", "", &models.AnalysisResult{ID: "test-id", Status: models.StatusProcessing}, nil, http.StatusConflict},
+		{"failed", "", &models.AnalysisResult{ID: "test-id", Status: models.StatusFailed}, nil, http.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+			c.Request = httptest.NewRequest("GET", "/api/v1/analysis/test-id/export"+tt.query, nil)
+
+			mockService := new(MockAnalysisService)
+			if tt.result != nil || tt.err != nil {
+				mockService.On("GetAnalysisStatus", "test-id").Return(tt.result, tt.err)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.ExportResult(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			switch tt.name {
+			case "csv":
+				assert.Equal(t, "text/csv", w.Header().Get("Content-Type"))
+				assert.Equal(t, `attachment; filename="test-id.csv"`, w.Header().Get("Content-Disposition"))
+				assert.Equal(t, "id,created_at,completed_at,purity_percentage,gypsum_content_percentage,impurity_content_percentage,"+
+					"calcite_content_percentage,quartz_content_percentage,other_minerals_percentage,"+
+					"particle_count,confidence,analysis_time_ms,"+
+					"sample_id,operator_name,location_lat,location_lon,sampling_depth_m,notes,threshold_value,threshold_lower,threshold_upper\n"+
+					"test-id,2024-01-01T01:00:00Z,2024-01-01T01:00:30Z,87.5,87.5,12.5,5,4.5,3,42,0.9,1500,GY-0042,A. Moreau,48.85,2.35,12.5,\"Core, north face\",128,128,255\n", w.Body.String())
+			case "json":
+				assert.Equal(t, `attachment; filename="test-id.json"`, w.Header().Get("Content-Disposition"))
+				var result models.AnalysisResult
+				require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+				assert.Equal(t, 87.5, result.PurityPercentage)
+			}
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestGetOverlay(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"served", nil, http.StatusOK},
+		{"unknown analysis", services.ErrAnalysisNotFound, http.StatusNotFound},
+		{"no overlay", services.ErrOverlayNotFound, http.StatusNotFound},
+		{"storage error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/analysis/test-id/overlay", nil)
+			c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+
+			mockService := new(MockAnalysisService)
+			if tt.err != nil {
+				mockService.On("GetOverlay", "test-id").Return(nil, tt.err)
+			} else {
+				mockService.On("GetOverlay", "test-id").Return(io.NopCloser(strings.NewReader("\x89PNG")), nil)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.GetOverlay(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+			if tt.err == nil {
+				assert.Equal(t, "image/png", w.Header().Get("Content-Type"))
+				assert.Equal(t, "\x89PNG", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestVerifyAnalysis(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	signedAt := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		valid  bool
+		err    error
+		status int
+	}{
+		{"valid", true, nil, http.StatusOK},
+		{"tampered", false, nil, http.StatusOK},
+		{"unknown analysis", false, services.ErrAnalysisNotFound, http.StatusNotFound},
+		{"processing", false, services.ErrAnalysisInProgress, http.StatusConflict},
+		{"unsigned", false, services.ErrResultNotSigned, http.StatusConflict},
+		{"key removed", false, services.ErrUnknownSigningKey, http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Params = gin.Params{{Key: "id", Value: "test-id"}}
+
+			mockService := new(MockAnalysisService)
+			if tt.err != nil {
+				mockService.On("VerifyResult", "test-id").Return(nil, tt.err)
+			} else {
+				mockService.On("VerifyResult", "test-id").Return(&models.ResultVerification{Valid: tt.valid, SignedAt: &signedAt, KeyVersion: "2024-06"}, nil)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.VerifyAnalysis(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+			if tt.err != nil {
+				return
+			}
+
+			var body map[string]interface{}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.valid, body["valid"])
+			assert.Equal(t, "2024-06-01T12:00:00Z", body["signed_at"])
+			assert.Equal(t, "2024-06", body["key_version"])
+		})
+	}
+}
+
+func TestAnalyzeGypsum_SampleMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	c.Request = newProfileUpload(t, map[string]string{
+		"sample_id":        "GY-0042",
+		"operator_name":    "A. Moreau",
+		"location_lat":     "48.85",
+		"location_lon":     "-2.35",
+		"sampling_depth_m": "12.5",
+		"notes":            "Core, north face",
+	})
+
+	expected := services.AnalysisOptions{Metadata: map[string]string{}, Owner: "ip:192.0.2.1", Sample: &models.SampleMetadata{
+		SampleID:       "GY-0042",
+		OperatorName:   "A. Moreau",
+		LocationLat:    48.85,
+		LocationLon:    -2.35,
+		SamplingDepthM: 12.5,
+		Notes:          "Core, north face",
+	}}
+
+	mockService := new(MockAnalysisService)
+	called := make(chan struct{})
+	mockService.On("SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, expected).
+		Return(nil).Run(func(mock.Arguments) { close(called) })
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.AnalyzeGypsum(c)
+
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	<-called
+	mockService.AssertExpectations(t)
+}
+
+func TestAnalyzeGypsum_InvalidSampleMetadata(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, fields := range []map[string]string{
+		{"location_lat": "north"},
+		{"location_lat": "91"},
+		{"location_lon": "-180.5"},
+		{"sampling_depth_m": "-3"},
+		{"sample_id": "GY-1", "sampling_depth_m": "NaN"},
+	} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newProfileUpload(t, fields)
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+		handler.AnalyzeGypsum(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code, fields)
+		mockService.AssertNotCalled(t, "SubmitAnalysis", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	}
+}
+
+func TestListAnalyses_SampleID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest("GET", "/api/v1/analysis?sample_id=GY-0042", nil)
+
+	sample := &models.SampleMetadata{SampleID: "GY-0042", OperatorName: "A. Moreau"}
+	mockService := new(MockAnalysisService)
+	mockService.On("ListAnalyses", services.ListFilter{SampleID: "GY-0042", Sort: "created_at", Limit: 20}).
+		Return([]*models.AnalysisResult{{ID: "a", Status: models.StatusCompleted, SampleMetadata: sample}}, 1, nil)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.ListAnalyses(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var body struct {
+		Data []*models.AnalysisResult `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	require.Len(t, body.Data, 1)
+	assert.Equal(t, sample, body.Data[0].SampleMetadata)
 	mockService.AssertExpectations(t)
 }
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/openapi"
+
+	"github.com/gin-gonic/gin"
+)
+
+// swaggerUIPage renders /openapi.json with Swagger UI, loaded from a CDN
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Gypsum Analysis API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`
+
+// DocsHandler serves the OpenAPI document and its Swagger UI page
+type DocsHandler struct {
+	spec []byte
+}
+
+// NewDocsHandler generates the OpenAPI document once, from the current models.
+// The document only holds plain values, so encoding it cannot fail.
+func NewDocsHandler() *DocsHandler {
+	spec, err := json.Marshal(openapi.Document())
+	if err != nil {
+		panic(fmt.Sprintf("failed to encode OpenAPI document: %v", err))
+	}
+	return &DocsHandler{spec: spec}
+}
+
+// Spec returns the OpenAPI document
+func (h *DocsHandler) Spec(c *gin.Context) {
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// UI returns the Swagger UI page
+func (h *DocsHandler) UI(c *gin.Context) {
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
+
+// Browse serves the documentation under /api/v1/docs: the Swagger UI page at
+// its root and the OpenAPI document at doc.json
+func (h *DocsHandler) Browse(c *gin.Context) {
+	switch c.Param("any") {
+	case "", "/", "/index.html":
+		h.UI(c)
+	case "/doc.json":
+		h.Spec(c)
+	default:
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Documentation page not found"))
+	}
+}
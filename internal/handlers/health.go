@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// readinessTimeout bounds the whole readiness check so probes never hang
+const readinessTimeout = 2 * time.Second
+
+// pinger is implemented by stores backed by a database connection
+type pinger interface {
+	Ping(ctx context.Context) error
+}
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	config *config.Config
+	store  services.ResultStore
+	logger *logger.Logger
+}
+
+// NewHealthHandler creates a new health handler. The database is checked
+// when store holds a connection, i.e. when persistence is enabled.
+func NewHealthHandler(cfg *config.Config, store services.ResultStore, logger *logger.Logger) *HealthHandler {
+	return &HealthHandler{
+		config: cfg,
+		store:  store,
+		logger: logger,
+	}
+}
+
+// Live reports that the process is up and serving requests. It checks
+// nothing else, so a Kubernetes livenessProbe on /health/live only restarts
+// the pod when it has stopped responding:
+//
+//	livenessProbe:
+//	  httpGet: {path: /health/live, port: 8080}
+//	  periodSeconds: 10
+func (h *HealthHandler) Live(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"status":  "healthy",
+		"service": "gypsum-analysis-api",
+	})
+}
+
+// Ready reports whether the service can run analyses: the Fiji executable
+// exists, TEMP_DIR is writable and, with persistence enabled, the database
+// answers. It responds 503 listing the unhealthy components otherwise, so a
+// Kubernetes readinessProbe on /health/ready takes the pod out of the
+// Service until they recover. The checks give up after two seconds, which
+// keeps the probe within a timeoutSeconds of 3:
+//
+//	readinessProbe:
+//	  httpGet: {path: /health/ready, port: 8080}
+//	  periodSeconds: 10
+//	  timeoutSeconds: 3
+func (h *HealthHandler) Ready(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), readinessTimeout)
+	defer cancel()
+
+	checks := map[string]func(ctx context.Context) error{
+		"fiji":     h.checkFiji,
+		"temp_dir": h.checkTempDir,
+	}
+	if db, ok := h.store.(pinger); ok {
+		checks["database"] = db.Ping
+	}
+
+	type outcome struct {
+		name string
+		err  error
+	}
+	outcomes := make(chan outcome, len(checks))
+	for name, check := range checks {
+		go func(name string, check func(ctx context.Context) error) {
+			outcomes <- outcome{name, check(ctx)}
+		}(name, check)
+	}
+
+	status := make(map[string]string, len(checks))
+	for range checks {
+		select {
+		case o := <-outcomes:
+			status[o.name] = "ok"
+			if o.err != nil {
+				status[o.name] = o.err.Error()
+			}
+		case <-ctx.Done():
+		}
+	}
+
+	var unhealthy []string
+	for name := range checks {
+		if _, done := status[name]; !done {
+			status[name] = "check timed out"
+		}
+		if status[name] != "ok" {
+			unhealthy = append(unhealthy, name)
+		}
+	}
+	sort.Strings(unhealthy)
+
+	if len(unhealthy) > 0 {
+		h.logger.FromContext(c).WithField("unhealthy", unhealthy).Warn("Readiness check failed")
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"status":    "unavailable",
+			"unhealthy": unhealthy,
+			"checks":    status,
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"status": "ready",
+		"checks": status,
+	})
+}
+
+// checkFiji verifies that the configured Fiji binary exists and is
+// executable. The go-native engine needs no Fiji.
+func (h *HealthHandler) checkFiji(context.Context) error {
+	if h.config.AnalysisEngine == config.EngineNative {
+		return nil
+	}
+	info, err := os.Stat(h.config.FijiPath)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		return fmt.Errorf("Fiji executable not found at %s", h.config.FijiPath)
+	case err != nil:
+		return err
+	case info.IsDir() || info.Mode().Perm()&0o111 == 0:
+		return fmt.Errorf("%s is not executable", h.config.FijiPath)
+	}
+	return nil
+}
+
+// checkTempDir verifies that uploads and macros can be written to TEMP_DIR
+func (h *HealthHandler) checkTempDir(context.Context) error {
+	file, err := os.CreateTemp(h.config.TempDir, ".ready-*")
+	if err != nil {
+		return fmt.Errorf("temp directory is not writable: %w", err)
+	}
+	file.Close()
+	return os.Remove(file.Name())
+}
@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// pingStore is a memory store that reports a database connection
+type pingStore struct {
+	*services.MemoryStore
+	err error
+}
+
+func (s pingStore) Ping(context.Context) error { return s.err }
+
+func TestHealthReady(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	fiji := filepath.Join(dir, "ImageJ-linux64")
+	require.NoError(t, os.WriteFile(fiji, []byte("#!/bin/sh\n"), 0o755))
+	notExecutable := filepath.Join(dir, "fiji.txt")
+	require.NoError(t, os.WriteFile(notExecutable, nil, 0o644))
+
+	tests := []struct {
+		name      string
+		fijiPath  string
+		engine    string
+		tempDir   string
+		store     services.ResultStore
+		status    int
+		unhealthy []string
+	}{
+		{"ready", fiji, "", dir, services.NewMemoryStore(), http.StatusOK, nil},
+		{"ready with database", fiji, "", dir, pingStore{services.NewMemoryStore(), nil}, http.StatusOK, nil},
+		{"fiji missing", filepath.Join(dir, "missing"), "", dir, services.NewMemoryStore(), http.StatusServiceUnavailable, []string{"fiji"}},
+		{"fiji missing with the native engine", filepath.Join(dir, "missing"), config.EngineNative, dir, services.NewMemoryStore(), http.StatusOK, nil},
+		{"fiji not executable", notExecutable, "", dir, services.NewMemoryStore(), http.StatusServiceUnavailable, []string{"fiji"}},
+		{"temp dir missing", fiji, "", filepath.Join(dir, "missing"), services.NewMemoryStore(), http.StatusServiceUnavailable, []string{"temp_dir"}},
+		{"database down", fiji, "", dir, pingStore{services.NewMemoryStore(), errors.New("connection refused")}, http.StatusServiceUnavailable, []string{"database"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/health/ready", nil)
+
+			handler := NewHealthHandler(&config.Config{FijiPath: tt.fijiPath, AnalysisEngine: tt.engine, TempDir: tt.tempDir}, tt.store, logger.New("info"))
+			handler.Ready(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			var body struct {
+				Unhealthy []string          `json:"unhealthy"`
+				Checks    map[string]string `json:"checks"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, tt.unhealthy, body.Unhealthy)
+			_, pinged := tt.store.(pingStore)
+			assert.Equal(t, pinged, body.Checks["database"] != "")
+		})
+	}
+}
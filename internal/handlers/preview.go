@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// PreviewMacro returns the ImageJ macro an analysis with the submitted form
+// would run, as text. It takes the form of AnalyzeGypsum; the image itself is
+// optional and only its name is used, so a filename field may be sent instead.
+// Nothing is saved and Fiji is not started.
+func (h *AnalysisHandler) PreviewMacro(c *gin.Context) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Send the analysis settings as multipart/form-data"))
+		return
+	}
+
+	if h.config.MaxFileSize > 0 {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.config.MaxFileSize+formOverhead)
+	}
+	err := c.Request.ParseMultipartForm(h.config.UploadMemoryThreshold)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		h.fileTooLarge(c)
+		return
+	}
+
+	filename := c.PostForm("filename")
+	for _, field := range []string{"image", "file"} {
+		if file, err := c.FormFile(field); err == nil && file != nil {
+			filename = file.Filename
+			break
+		}
+	}
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeNoFile, "Send the image or its filename"))
+		return
+	}
+	if !services.IsSupportedImage(filename) {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeUnsupportedType, "Unsupported file type. Please upload JPG, PNG, TIFF, WebP, BMP or DNG images"))
+		return
+	}
+
+	opts, ok := h.analysisOptions(c)
+	if !ok {
+		return
+	}
+
+	macro, err := h.analysisService.PreviewMacro(filename, opts)
+	switch {
+	case err == nil:
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(macro))
+	case errors.Is(err, services.ErrProfileNotFound):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Unknown profile_id: "+opts.ProfileID))
+	case errors.Is(err, services.ErrInvalidParticleFilter), errors.Is(err, services.ErrInvalidROIRegion):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+	default:
+		h.logger.FromContext(c).WithError(err).Error("Failed to preview analysis macro")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to preview macro"))
+	}
+}
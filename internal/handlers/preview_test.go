@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestPreviewMacro(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name     string
+		request  func(t *testing.T) *http.Request
+		filename string
+	}{
+		{"image", func(t *testing.T) *http.Request {
+			return newImageUpload(t, "/api/v1/analysis/macro/preview", "sample.jpg", []byte("not decoded"))
+		}, "sample.jpg"},
+		{"filename", func(t *testing.T) *http.Request {
+			return newUploadForm(t, map[string]string{"filename": "scan.tif", "profile_id": "fine"})
+		}, "scan.tif"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = tt.request(t)
+
+			mockService := new(MockAnalysisService)
+			mockService.On("PreviewMacro", tt.filename, mock.Anything).Return("// macro\n", nil)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.PreviewMacro(c)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.Equal(t, "// macro\n", w.Body.String())
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestPreviewMacro_Rejected(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		fields map[string]string
+		err    error
+	}{
+		{"no filename", map[string]string{}, nil},
+		{"unsupported type", map[string]string{"filename": "notes.txt"}, nil},
+		{"unknown profile", map[string]string{"filename": "scan.png", "profile_id": "missing"}, services.ErrProfileNotFound},
+		{"invalid region", map[string]string{"filename": "scan.png"}, services.ErrInvalidROIRegion},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = newUploadForm(t, tt.fields)
+
+			mockService := new(MockAnalysisService)
+			mockService.On("PreviewMacro", mock.Anything, mock.Anything).Return("", tt.err)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.PreviewMacro(c)
+
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			if tt.err == nil {
+				mockService.AssertNotCalled(t, "PreviewMacro", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListProfiles returns all analysis profiles, built-in ones first
+func (h *AnalysisHandler) ListProfiles(c *gin.Context) {
+	profiles, err := h.analysisService.ListProfiles()
+	if err != nil {
+		h.logger.FromContext(c).WithError(err).Error("Failed to list profiles")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to list profiles"))
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"profiles": profiles})
+}
+
+// GetProfile returns a single analysis profile
+func (h *AnalysisHandler) GetProfile(c *gin.Context) {
+	profile, err := h.analysisService.GetProfile(c.Param("id"))
+	if err != nil {
+		h.profileError(c, err, "Failed to get profile")
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// CreateProfile stores a new analysis profile. Settings left out of the body
+// take the configured defaults.
+func (h *AnalysisHandler) CreateProfile(c *gin.Context) {
+	request, ok := h.bindProfile(c)
+	if !ok {
+		return
+	}
+
+	profile, err := h.analysisService.CreateProfile(request)
+	if err != nil {
+		h.profileError(c, err, "Failed to create profile")
+		return
+	}
+	c.JSON(http.StatusCreated, profile)
+}
+
+// UpdateProfile replaces the name, description and settings of a profile.
+// Built-in profiles are read-only.
+func (h *AnalysisHandler) UpdateProfile(c *gin.Context) {
+	request, ok := h.bindProfile(c)
+	if !ok {
+		return
+	}
+
+	profile, err := h.analysisService.UpdateProfile(c.Param("id"), request)
+	if err != nil {
+		h.profileError(c, err, "Failed to update profile")
+		return
+	}
+	c.JSON(http.StatusOK, profile)
+}
+
+// DeleteProfile removes a profile. Built-in profiles cannot be deleted.
+func (h *AnalysisHandler) DeleteProfile(c *gin.Context) {
+	if err := h.analysisService.DeleteProfile(c.Param("id")); err != nil {
+		h.profileError(c, err, "Failed to delete profile")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// bindProfile reads a profile request whose omitted settings are the
+// configured defaults. On invalid input it writes a 400 response and returns false.
+func (h *AnalysisHandler) bindProfile(c *gin.Context) (models.ProfileRequest, bool) {
+	request := models.ProfileRequest{MacroParams: services.DefaultMacroParams(h.config)}
+	if err := c.ShouldBindJSON(&request); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Invalid profile: "+err.Error()))
+		return request, false
+	}
+	return request, true
+}
+
+// profileError writes the response for a failed profile operation
+func (h *AnalysisHandler) profileError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, services.ErrProfileNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Profile not found"))
+	case errors.Is(err, services.ErrInvalidProfile):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+	case errors.Is(err, services.ErrProfileExists), errors.Is(err, services.ErrBuiltinProfile):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, err.Error()))
+	default:
+		h.logger.FromContext(c).WithError(err).WithField("profile_id", c.Param("id")).Error(message)
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, message))
+	}
+}
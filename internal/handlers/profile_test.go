@@ -0,0 +1,129 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"created", `{"id":"transmitted","name":"Transmitted light","gaussian_sigma":0.5}`, nil, http.StatusCreated},
+		{"taken", `{"id":"default","name":"Mine"}`, services.ErrProfileExists, http.StatusConflict},
+		{"invalid", `{"id":"Bad ID","name":"Mine"}`, services.ErrInvalidProfile, http.StatusBadRequest},
+		{"store error", `{"name":"Mine"}`, errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/profiles", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			mockService := new(MockAnalysisService)
+			if tt.err != nil {
+				mockService.On("CreateProfile", mock.Anything).Return(nil, tt.err)
+			} else {
+				mockService.On("CreateProfile", mock.Anything).Return(&models.Profile{ID: "transmitted"}, nil)
+			}
+			cfg := &config.Config{MacroGaussianSigma: 1, MacroContrastSaturation: 0.35, MacroThresholdMethod: "Otsu"}
+			handler := NewAnalysisHandler(mockService, cfg, logger.New("info"))
+
+			handler.CreateProfile(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCreateProfile_OmittedSettingsUseDefaults(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/profiles", strings.NewReader(`{"id":"lab-a","name":"Lab A","gaussian_sigma":0}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	mockService := new(MockAnalysisService)
+	mockService.On("CreateProfile", mock.MatchedBy(func(request models.ProfileRequest) bool {
+		return request.GaussianSigma == 0 && request.ContrastSaturation == 0.35 &&
+			request.ThresholdMethod == "Li" && request.MinParticleSize == 10 && request.MaxCircularity == 1
+	})).Return(&models.Profile{ID: "lab-a"}, nil)
+	cfg := &config.Config{MacroGaussianSigma: 1, MacroContrastSaturation: 0.35, MacroThresholdMethod: "Li"}
+	handler := NewAnalysisHandler(mockService, cfg, logger.New("info"))
+
+	handler.CreateProfile(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	mockService.AssertExpectations(t)
+}
+
+func TestCreateProfile_MissingName(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/profiles", strings.NewReader(`{"id":"lab-a"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	mockService := new(MockAnalysisService)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.CreateProfile(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "CreateProfile", mock.Anything)
+}
+
+func TestDeleteProfile(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"deleted", nil, http.StatusNoContent},
+		{"built in", services.ErrBuiltinProfile, http.StatusConflict},
+		{"unknown", services.ErrProfileNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/api/v1/profiles/lab-a", nil)
+			c.Params = gin.Params{{Key: "id", Value: "lab-a"}}
+
+			mockService := new(MockAnalysisService)
+			mockService.On("DeleteProfile", "lab-a").Return(tt.err)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.DeleteProfile(c)
+			c.Writer.WriteHeaderNow()
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ReportHandler serves PDF lab reports of completed analyses
+type ReportHandler struct {
+	analysisService services.AnalysisServiceInterface
+	reportService   *services.ReportService
+	config          *config.Config
+	logger          *logger.Logger
+}
+
+// NewReportHandler creates a new report handler
+func NewReportHandler(analysisService services.AnalysisServiceInterface, reportService *services.ReportService, cfg *config.Config, logger *logger.Logger) *ReportHandler {
+	return &ReportHandler{
+		analysisService: analysisService,
+		reportService:   reportService,
+		config:          cfg,
+		logger:          logger,
+	}
+}
+
+// GetReport renders the PDF lab report of a completed analysis
+func (h *ReportHandler) GetReport(c *gin.Context) {
+	analysisID := c.Param("id")
+
+	result, err := h.analysisService.GetAnalysisStatus(analysisID)
+	switch {
+	case errors.Is(err, services.ErrAnalysisNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Analysis not found"))
+		return
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to get analysis for report")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to retrieve analysis result"))
+		return
+	}
+
+	report, err := h.reportService.Generate(result, statusURL(h.config.PublicBaseURL, analysisID))
+	switch {
+	case errors.Is(err, services.ErrAnalysisInProgress):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Analysis is still processing; the report is available once it completes"))
+		return
+	case errors.Is(err, services.ErrAnalysisFailed):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, err.Error()))
+		return
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("analysis_id", analysisID).Error("Failed to generate report")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to generate report"))
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s_report.pdf"`, analysisID))
+	c.Data(http.StatusOK, "application/pdf", report)
+}
+
+// statusURL is the URL of an analysis' status endpoint under the configured
+// public base URL. The request's Host header is client-controlled and never
+// used, so without a base URL the link is relative.
+func statusURL(publicBaseURL, analysisID string) string {
+	return fmt.Sprintf("%s/api/v1/analysis/status/%s", strings.TrimSuffix(publicBaseURL, "/"), analysisID)
+}
@@ -0,0 +1,62 @@
+package handlers
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetReport(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	completedAt := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name   string
+		result *models.AnalysisResult
+		err    error
+		status int
+	}{
+		{"completed", &models.AnalysisResult{ID: "a", Status: models.StatusCompleted, CompletedAt: &completedAt, PurityPercentage: 82}, nil, http.StatusOK},
+		{"processing", &models.AnalysisResult{ID: "a", Status: models.StatusProcessing}, nil, http.StatusConflict},
+		{"failed", &models.AnalysisResult{ID: "a", Status: models.StatusFailed}, nil, http.StatusConflict},
+		{"not found", nil, services.ErrAnalysisNotFound, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest("GET", "/api/v1/analysis/a/report", nil)
+			c.Params = gin.Params{{Key: "id", Value: "a"}}
+
+			mockService := new(MockAnalysisService)
+			mockService.On("GetAnalysisStatus", "a").Return(tt.result, tt.err)
+			reports := services.NewReportService(&config.Config{ReportOrganization: "Acme Gypsum Lab"})
+			handler := NewReportHandler(mockService, reports, &config.Config{}, logger.New("info"))
+
+			handler.GetReport(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "application/pdf", w.Header().Get("Content-Type"))
+				assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("%PDF-")))
+			}
+		})
+	}
+}
+
+func TestStatusURL(t *testing.T) {
+	assert.Equal(t, "/api/v1/analysis/status/a", statusURL("", "a"))
+	assert.Equal(t, "https://lab.example.com/api/v1/analysis/status/a", statusURL("https://lab.example.com", "a"))
+	assert.Equal(t, "https://example.com/gypsum/api/v1/analysis/status/a", statusURL("https://example.com/gypsum/", "a"))
+}
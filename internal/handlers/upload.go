@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// InitUpload starts an upload that is sent in chunks. The form carries the
+// file name and the same optional settings as a single upload, except for
+// roi_file.
+func (h *AnalysisHandler) InitUpload(c *gin.Context) {
+	if !strings.HasPrefix(c.ContentType(), "multipart/form-data") {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Send the upload settings as multipart/form-data"))
+		return
+	}
+
+	filename := c.PostForm("filename")
+	if filename == "" {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "filename is required"))
+		return
+	}
+	if !services.IsSupportedImage(filename) {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeUnsupportedType, "Unsupported file type. Please upload JPG, PNG, TIFF, WebP, BMP or DNG images"))
+		return
+	}
+
+	opts, ok := h.analysisOptions(c)
+	if !ok {
+		return
+	}
+	// The ROI file would have to outlive this request
+	if opts.ROIFile != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "roi_file cannot be sent with a chunked upload"))
+		return
+	}
+
+	uploadID, err := h.analysisService.InitUpload(filename, opts)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusCreated, gin.H{"upload_id": uploadID})
+	case errors.Is(err, services.ErrProfileNotFound):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Unknown profile_id: "+opts.ProfileID))
+	case errors.Is(err, services.ErrInvalidParticleFilter):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+	case errors.Is(err, services.ErrTooManyUploads):
+		c.JSON(http.StatusTooManyRequests, middleware.NewAPIError(c, models.ErrCodeRateLimited, err.Error()))
+	default:
+		h.logger.FromContext(c).WithError(err).Error("Failed to start chunked upload")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to start upload"))
+	}
+}
+
+// UploadChunk stores the raw request body as chunk index of an upload started
+// by the same client. Sending a chunk again replaces it.
+func (h *AnalysisHandler) UploadChunk(c *gin.Context) {
+	uploadID := c.Param("upload_id")
+	index, err := strconv.Atoi(c.Query("index"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "index must be a whole number"))
+		return
+	}
+
+	body := c.Request.Body
+	if h.config.MaxFileSize > 0 {
+		body = http.MaxBytesReader(c.Writer, body, h.config.MaxFileSize)
+	}
+	size, err := h.analysisService.WriteChunk(middleware.ClientKey(c), uploadID, index, body)
+	var tooLarge *http.MaxBytesError
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"upload_id": uploadID, "index": index, "size": size})
+	case errors.Is(err, services.ErrUploadTooLarge), errors.As(err, &tooLarge):
+		h.fileTooLarge(c)
+	case errors.Is(err, services.ErrInvalidChunk):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+	case errors.Is(err, services.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Upload not found"))
+	case errors.Is(err, services.ErrUploadCompleting):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Upload is being completed"))
+	default:
+		h.logger.FromContext(c).WithError(err).WithField("upload_id", uploadID).Error("Failed to store chunk")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to store chunk"))
+	}
+}
+
+// CompleteUpload reassembles an upload started by the same client from its
+// chunks and queues its analysis, responding like a single upload
+func (h *AnalysisHandler) CompleteUpload(c *gin.Context) {
+	uploadID := c.Param("upload_id")
+	var completion models.UploadCompletion
+	if err := c.ShouldBindJSON(&completion); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Invalid completion: "+err.Error()))
+		return
+	}
+
+	analysisID := uuid.New().String()
+	err := h.analysisService.CompleteUpload(c.Request.Context(), middleware.ClientKey(c), uploadID, analysisID, completion.TotalChunks)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusAccepted, gin.H{
+			"analysis_id": analysisID,
+			"status":      "processing",
+			"message":     "Analysis started successfully",
+		})
+	case errors.Is(err, services.ErrUploadNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Upload not found"))
+	case errors.Is(err, services.ErrIncompleteUpload):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+	case errors.Is(err, services.ErrUploadCompleting):
+		c.JSON(http.StatusConflict, middleware.NewAPIError(c, models.ErrCodeConflict, "Upload is already being completed"))
+	case errors.Is(err, services.ErrUploadTooLarge):
+		h.fileTooLarge(c)
+	default:
+		h.submissionFailed(c, err, analysisID, "")
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newUploadForm returns a multipart request with the given form fields
+func newUploadForm(t *testing.T, fields map[string]string) *http.Request {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for name, value := range fields {
+		assert.NoError(t, writer.WriteField(name, value))
+	}
+	writer.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/analysis/upload/init", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req
+}
+
+func TestInitUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newUploadForm(t, map[string]string{"filename": "scan.tif", "sample_group": "kiln-3"})
+
+	mockService := new(MockAnalysisService)
+	mockService.On("InitUpload", "scan.tif", mock.MatchedBy(func(opts services.AnalysisOptions) bool {
+		return opts.SampleGroup == "kiln-3" && opts.Owner == "ip:192.0.2.1"
+	})).Return("upload-1", nil)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.InitUpload(c)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Body.String(), `"upload_id":"upload-1"`)
+	mockService.AssertExpectations(t)
+}
+
+func TestInitUpload_InvalidFilename(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	for _, fields := range []map[string]string{{}, {"filename": "notes.txt"}} {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = newUploadForm(t, fields)
+
+		mockService := new(MockAnalysisService)
+		handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+		handler.InitUpload(c)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		mockService.AssertNotCalled(t, "InitUpload", mock.Anything, mock.Anything)
+	}
+}
+
+func TestInitUpload_TooManyUploads(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = newUploadForm(t, map[string]string{"filename": "scan.tif"})
+
+	mockService := new(MockAnalysisService)
+	mockService.On("InitUpload", "scan.tif", mock.Anything).Return("", fmt.Errorf("%w: 5 uploads are open", services.ErrTooManyUploads))
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.InitUpload(c)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Contains(t, w.Body.String(), "too many open uploads")
+}
+
+func TestUploadChunk(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		index  string
+		err    error
+		status int
+	}{
+		{"stored", "0", nil, http.StatusOK},
+		{"bad index", "first", nil, http.StatusBadRequest},
+		{"out of range", "-1", services.ErrInvalidChunk, http.StatusBadRequest},
+		{"unknown upload", "0", services.ErrUploadNotFound, http.StatusNotFound},
+		{"completing", "0", services.ErrUploadCompleting, http.StatusConflict},
+		{"too large", "0", services.ErrUploadTooLarge, http.StatusRequestEntityTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/analysis/upload/upload-1/chunk?index="+tt.index, strings.NewReader("chunk"))
+			c.Params = gin.Params{{Key: "upload_id", Value: "upload-1"}}
+
+			mockService := new(MockAnalysisService)
+			mockService.On("WriteChunk", "ip:192.0.2.1", "upload-1", mock.Anything, mock.Anything).Return(int64(5), tt.err)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.UploadChunk(c)
+
+			assert.Equal(t, tt.status, w.Code)
+		})
+	}
+}
+
+func TestCompleteUpload(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"queued", `{"total_chunks":3}`, nil, http.StatusAccepted},
+		{"no total", `{}`, nil, http.StatusBadRequest},
+		{"missing chunks", `{"total_chunks":3}`, services.ErrIncompleteUpload, http.StatusBadRequest},
+		{"unknown upload", `{"total_chunks":3}`, services.ErrUploadNotFound, http.StatusNotFound},
+		{"queue full", `{"total_chunks":3}`, services.ErrQueueFull, http.StatusTooManyRequests},
+		{"not an image", `{"total_chunks":3}`, services.ErrUnsupportedMIME, http.StatusUnsupportedMediaType},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/analysis/upload/upload-1/complete", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+			c.Params = gin.Params{{Key: "upload_id", Value: "upload-1"}}
+
+			mockService := new(MockAnalysisService)
+			mockService.On("CompleteUpload", mock.Anything, "ip:192.0.2.1", "upload-1", mock.Anything, 3).Return(tt.err)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.CompleteUpload(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusAccepted {
+				assert.Contains(t, w.Body.String(), `"analysis_id"`)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+func init() {
+	// finite rejects NaN and infinite numbers, which ParseFloat accepts
+	if engine, ok := binding.Validator.Engine().(*validator.Validate); ok {
+		engine.RegisterValidation("finite", func(fl validator.FieldLevel) bool {
+			value := fl.Field().Float()
+			return !math.IsNaN(value) && !math.IsInf(value, 0)
+		})
+	}
+}
+
+// fieldErrors maps the name of each invalid form field to why it was rejected
+type fieldErrors map[string]string
+
+// String lists the errors as "field: reason" in field order
+func (e fieldErrors) String() string {
+	names := make([]string, 0, len(e))
+	for name := range e {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	reasons := make([]string, len(names))
+	for i, name := range names {
+		reasons[i] = name + ": " + e[name]
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// bindForm maps the posted form fields onto the struct req points to and
+// validates them with its binding tags. Unlike ShouldBind it does not stop at
+// the first bad field: every field that is not a number where one is expected
+// or that fails its rules is reported. Empty fields are left unset.
+func bindForm(c *gin.Context, req interface{}) (fieldErrors, error) {
+	invalid := fieldErrors{}
+	values := make(map[string][]string)
+	t := reflect.TypeOf(req).Elem()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := field.Tag.Get("form")
+		value := c.PostForm(name)
+		if name == "" || value == "" {
+			continue
+		}
+		if reason := parseError(field.Type, value); reason != "" {
+			invalid[name] = reason
+			continue
+		}
+		values[name] = []string{value}
+	}
+	if err := binding.MapFormWithTag(req, values, "form"); err != nil {
+		return nil, err
+	}
+
+	var failed validator.ValidationErrors
+	if err := binding.Validator.ValidateStruct(req); errors.As(err, &failed) {
+		for _, fe := range failed {
+			invalid[formName(t, fe.StructField())] = validationReason(t, fe)
+		}
+	} else if err != nil {
+		return nil, err
+	}
+	return invalid, nil
+}
+
+// parseError returns why value cannot be stored in a field of type t, or an
+// empty string when it can
+func parseError(t reflect.Type, value string) string {
+	if t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Float64:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return "must be a number"
+		}
+	case reflect.Int:
+		if _, err := strconv.Atoi(value); err != nil {
+			return "must be a whole number"
+		}
+	}
+	return ""
+}
+
+// formName returns the form field name of the struct field called name
+func formName(t reflect.Type, name string) string {
+	if field, ok := t.FieldByName(name); ok {
+		if tag := field.Tag.Get("form"); tag != "" {
+			return tag
+		}
+	}
+	return name
+}
+
+// validationReason describes the rule a field failed, naming related fields
+// by their form names
+func validationReason(t reflect.Type, fe validator.FieldError) string {
+	related := func() string {
+		fields := strings.Fields(fe.Param())
+		for i, field := range fields {
+			fields[i] = formName(t, field)
+		}
+		if len(fields) == 1 {
+			return fields[0]
+		}
+		return strings.Join(fields[:len(fields)-1], ", ") + " and " + fields[len(fields)-1]
+	}
+
+	switch fe.Tag() {
+	case "finite":
+		return "must be a finite number"
+	case "min":
+		return "must be at least " + fe.Param()
+	case "max":
+		return "must be at most " + fe.Param()
+	case "gt":
+		return "must be greater than " + fe.Param()
+	case "lt":
+		return "must be less than " + fe.Param()
+	case "required_with":
+		return "must be given together with " + related()
+	case "excluded_with":
+		return "cannot be combined with " + related()
+	}
+	return fmt.Sprintf("failed the %s rule", fe.Tag())
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterWebhook registers a URL that is sent a signed event whenever an
+// analysis submitted by the same client completes, fails or is cancelled
+func (h *AnalysisHandler) RegisterWebhook(c *gin.Context) {
+	var registration models.WebhookRegistration
+	if err := c.ShouldBindJSON(&registration); err != nil {
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, "Invalid webhook: "+err.Error()))
+		return
+	}
+
+	webhook, err := h.analysisService.RegisterWebhook(middleware.ClientKey(c), registration)
+	switch {
+	case errors.Is(err, services.ErrInvalidWebhook):
+		c.JSON(http.StatusBadRequest, middleware.NewAPIError(c, models.ErrCodeInvalidInput, err.Error()))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).Error("Failed to register webhook")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to register webhook"))
+	default:
+		c.JSON(http.StatusCreated, webhook)
+	}
+}
+
+// ListWebhookDeliveries returns the recent delivery attempts to a webhook of
+// the calling client, newest first, with the status code each received
+func (h *AnalysisHandler) ListWebhookDeliveries(c *gin.Context) {
+	webhookID := c.Param("id")
+
+	deliveries, err := h.analysisService.ListWebhookDeliveries(middleware.ClientKey(c), webhookID)
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Webhook not found"))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("webhook_id", webhookID).Error("Failed to list webhook deliveries")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to list webhook deliveries"))
+	default:
+		c.JSON(http.StatusOK, gin.H{
+			"webhook_id": webhookID,
+			"deliveries": deliveries,
+		})
+	}
+}
+
+// DeleteWebhook removes a webhook of the calling client. Webhooks registered
+// by other clients are reported as not found.
+func (h *AnalysisHandler) DeleteWebhook(c *gin.Context) {
+	webhookID := c.Param("id")
+
+	err := h.analysisService.DeleteWebhook(middleware.ClientKey(c), webhookID)
+	switch {
+	case errors.Is(err, services.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, middleware.NewAPIError(c, models.ErrCodeNotFound, "Webhook not found"))
+	case err != nil:
+		h.logger.FromContext(c).WithError(err).WithField("webhook_id", webhookID).Error("Failed to delete webhook")
+		c.JSON(http.StatusInternalServerError, middleware.NewAPIError(c, models.ErrCodeInternal, "Failed to delete webhook"))
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
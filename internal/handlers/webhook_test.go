@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegisterWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		body   string
+		err    error
+		status int
+	}{
+		{"registered", `{"url":"https://lims.example.com/hooks","secret":"s3cret","events":["analysis.completed"]}`, nil, http.StatusCreated},
+		{"invalid", `{"url":"https://lims.example.com/hooks","events":["analysis.started"]}`, fmt.Errorf("%w: unknown event", services.ErrInvalidWebhook), http.StatusBadRequest},
+		{"store error", `{"url":"https://lims.example.com/hooks"}`, errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(tt.body))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			// Webhooks belong to the registering client
+			mockService := new(MockAnalysisService)
+			if tt.err != nil {
+				mockService.On("RegisterWebhook", "ip:192.0.2.1", mock.Anything).Return(nil, tt.err)
+			} else {
+				mockService.On("RegisterWebhook", "ip:192.0.2.1", mock.Anything).Return(&models.Webhook{ID: "hook-1", URL: "https://lims.example.com/hooks", Secret: "s3cret"}, nil)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.RegisterWebhook(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.NotContains(t, w.Body.String(), "s3cret", "the secret is never returned")
+			mockService.AssertExpectations(t)
+		})
+	}
+}
+
+func TestRegisterWebhook_MissingURL(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/webhooks", strings.NewReader(`{"secret":"s3cret"}`))
+	c.Request.Header.Set("Content-Type", "application/json")
+
+	mockService := new(MockAnalysisService)
+	handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+	handler.RegisterWebhook(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	mockService.AssertNotCalled(t, "RegisterWebhook", mock.Anything, mock.Anything)
+}
+
+func TestListWebhookDeliveries(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	deliveries := []*models.WebhookDelivery{
+		{ID: "d2", WebhookID: "hook-1", AnalysisID: "a1", Event: models.EventAnalysisCompleted, Attempt: 2, StatusCode: http.StatusOK},
+		{ID: "d1", WebhookID: "hook-1", AnalysisID: "a1", Event: models.EventAnalysisCompleted, Attempt: 1, StatusCode: http.StatusBadGateway, Error: "webhook returned status 502"},
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"listed", nil, http.StatusOK},
+		{"unknown", services.ErrWebhookNotFound, http.StatusNotFound},
+		{"store error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/webhooks/hook-1/deliveries", nil)
+			c.Params = gin.Params{{Key: "id", Value: "hook-1"}}
+
+			mockService := new(MockAnalysisService)
+			if tt.err != nil {
+				mockService.On("ListWebhookDeliveries", "ip:192.0.2.1", "hook-1").Return(nil, tt.err)
+			} else {
+				mockService.On("ListWebhookDeliveries", "ip:192.0.2.1", "hook-1").Return(deliveries, nil)
+			}
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.ListWebhookDeliveries(c)
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+			if tt.err != nil {
+				return
+			}
+
+			var body struct {
+				WebhookID  string                    `json:"webhook_id"`
+				Deliveries []*models.WebhookDelivery `json:"deliveries"`
+			}
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+			assert.Equal(t, "hook-1", body.WebhookID)
+			require.Len(t, body.Deliveries, 2)
+			assert.Equal(t, http.StatusBadGateway, body.Deliveries[1].StatusCode)
+		})
+	}
+}
+
+func TestDeleteWebhook(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+	}{
+		{"deleted", nil, http.StatusNoContent},
+		{"unknown or not owned", services.ErrWebhookNotFound, http.StatusNotFound},
+		{"store error", errors.New("connection refused"), http.StatusInternalServerError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodDelete, "/api/v1/webhooks/hook-1", nil)
+			c.Params = gin.Params{{Key: "id", Value: "hook-1"}}
+
+			mockService := new(MockAnalysisService)
+			mockService.On("DeleteWebhook", "ip:192.0.2.1", "hook-1").Return(tt.err)
+			handler := NewAnalysisHandler(mockService, &config.Config{}, logger.New("info"))
+
+			handler.DeleteWebhook(c)
+			c.Writer.WriteHeaderNow()
+
+			assert.Equal(t, tt.status, w.Code)
+			mockService.AssertExpectations(t)
+		})
+	}
+}
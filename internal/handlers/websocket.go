@@ -0,0 +1,318 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// Time allowed to write a message to the client
+	wsWriteWait = 10 * time.Second
+
+	// Time allowed between pongs before the connection is considered dead
+	wsPongWait = 60 * time.Second
+
+	// Ping interval, must be shorter than wsPongWait
+	wsPingPeriod = (wsPongWait * 9) / 10
+
+	// Outgoing messages buffered per client before it is treated as too slow
+	wsSendBuffer = 32
+
+	// Largest client message accepted
+	wsMaxMessageSize = 4096
+)
+
+// wsCommand is a subscription request sent by a WebSocket client
+type wsCommand struct {
+	Action      string   `json:"action"` // "subscribe" or "unsubscribe"
+	AnalysisIDs []string `json:"analysis_ids"`
+	All         bool     `json:"all"`
+}
+
+// wsMessage is sent to clients for acknowledgements, errors and status events
+type wsMessage struct {
+	Type        string              `json:"type"` // "subscribed", "unsubscribed", "error" or "status"
+	AnalysisIDs []string            `json:"analysis_ids,omitempty"`
+	All         bool                `json:"all,omitempty"`
+	Error       string              `json:"error,omitempty"`
+	Event       *models.StatusEvent `json:"event,omitempty"`
+}
+
+// wsClient is a single WebSocket connection and its subscriptions
+type wsClient struct {
+	hub  *Hub
+	conn *websocket.Conn
+	send chan []byte
+
+	mutex         sync.Mutex
+	subscriptions map[string]bool
+	all           bool
+}
+
+// Hub fans analysis status transitions out to subscribed WebSocket clients
+type Hub struct {
+	analysisService services.AnalysisServiceInterface
+	logger          *logger.Logger
+	allowAll        bool
+	upgrader        websocket.Upgrader
+
+	mutex   sync.RWMutex
+	clients map[*wsClient]struct{}
+}
+
+// NewHub creates a WebSocket hub. allowAll controls whether clients may
+// subscribe to every analysis rather than specific IDs. Browsers may only
+// connect from allowedOrigins, the CORS allowed origins, where "*" allows
+// every origin; with no origins listed, only pages of the API's own host may.
+func NewHub(analysisService services.AnalysisServiceInterface, allowAll bool, allowedOrigins []string, logger *logger.Logger) *Hub {
+	return &Hub{
+		analysisService: analysisService,
+		logger:          logger,
+		allowAll:        allowAll,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 1024,
+			CheckOrigin:     originChecker(allowedOrigins),
+		},
+		clients: make(map[*wsClient]struct{}),
+	}
+}
+
+// originChecker returns a CheckOrigin function accepting requests without an
+// Origin header, which browsers always send, and those from allowedOrigins
+func originChecker(allowedOrigins []string) func(r *http.Request) bool {
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			return true
+		}
+		if len(allowedOrigins) == 0 {
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host)
+		}
+		for _, allowed := range allowedOrigins {
+			if allowed == "*" || strings.EqualFold(strings.TrimSuffix(allowed, "/"), origin) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Run forwards status events from the analysis service until the event stream closes
+func (h *Hub) Run() {
+	events, unsubscribe := h.analysisService.SubscribeEvents()
+	defer unsubscribe()
+
+	for event := range events {
+		event := event
+		data, err := json.Marshal(wsMessage{Type: "status", Event: &event})
+		if err != nil {
+			h.logger.WithError(err).Error("Failed to encode status event")
+			continue
+		}
+
+		h.mutex.RLock()
+		for client := range h.clients {
+			if client.wants(event.AnalysisID) {
+				client.enqueue(data)
+			}
+		}
+		h.mutex.RUnlock()
+	}
+}
+
+// ServeWS upgrades the request to a WebSocket connection and registers the client
+func (h *Hub) ServeWS(c *gin.Context) {
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		h.logger.FromContext(c).WithError(err).Warn("Failed to upgrade WebSocket connection")
+		return
+	}
+
+	client := &wsClient{
+		hub:           h,
+		conn:          conn,
+		send:          make(chan []byte, wsSendBuffer),
+		subscriptions: make(map[string]bool),
+	}
+
+	h.mutex.Lock()
+	h.clients[client] = struct{}{}
+	h.mutex.Unlock()
+
+	go client.writePump()
+	go client.readPump()
+}
+
+// unregister removes a client and closes its send queue exactly once
+func (h *Hub) unregister(client *wsClient) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if _, ok := h.clients[client]; ok {
+		delete(h.clients, client)
+		close(client.send)
+	}
+}
+
+// wants reports whether the client subscribed to the analysis
+func (c *wsClient) wants(analysisID string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.all || c.subscriptions[analysisID]
+}
+
+// enqueue queues a message without blocking. A client that cannot keep up is
+// disconnected rather than slowing down delivery to everyone else. Must be
+// called with the hub's read lock held.
+func (c *wsClient) enqueue(data []byte) {
+	select {
+	case c.send <- data:
+	default:
+		c.hub.logger.Warn("Disconnecting slow WebSocket client")
+		go c.hub.unregister(c)
+	}
+}
+
+// reply sends a control message to the client
+func (c *wsClient) reply(msg wsMessage) {
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return
+	}
+
+	c.hub.mutex.RLock()
+	defer c.hub.mutex.RUnlock()
+	if _, ok := c.hub.clients[c]; ok {
+		c.enqueue(data)
+	}
+}
+
+// readPump processes subscription commands until the connection closes
+func (c *wsClient) readPump() {
+	defer func() {
+		c.hub.unregister(c)
+		c.conn.Close()
+	}()
+
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+
+		var cmd wsCommand
+		if err := json.Unmarshal(data, &cmd); err != nil {
+			c.reply(wsMessage{Type: "error", Error: "Invalid message: " + err.Error()})
+			continue
+		}
+
+		c.handleCommand(cmd)
+	}
+}
+
+// handleCommand applies a subscribe or unsubscribe request
+func (c *wsClient) handleCommand(cmd wsCommand) {
+	if cmd.All && !c.hub.allowAll {
+		c.reply(wsMessage{Type: "error", Error: "Subscribing to all analyses is not permitted"})
+		return
+	}
+
+	c.mutex.Lock()
+	switch cmd.Action {
+	case "subscribe":
+		if cmd.All {
+			c.all = true
+		}
+		for _, id := range cmd.AnalysisIDs {
+			c.subscriptions[id] = true
+		}
+	case "unsubscribe":
+		if cmd.All {
+			c.all = false
+		}
+		for _, id := range cmd.AnalysisIDs {
+			delete(c.subscriptions, id)
+		}
+	default:
+		c.mutex.Unlock()
+		c.reply(wsMessage{Type: "error", Error: "Unknown action, expected 'subscribe' or 'unsubscribe'"})
+		return
+	}
+	c.mutex.Unlock()
+
+	c.reply(wsMessage{Type: cmd.Action + "d", AnalysisIDs: cmd.AnalysisIDs, All: cmd.All})
+
+	// An analysis that finished before the subscription publishes nothing
+	// more, so its final status is sent at once. Checking after subscribing
+	// means a transition in between may arrive twice but is never missed.
+	if cmd.Action == "subscribe" {
+		for _, id := range cmd.AnalysisIDs {
+			c.sendIfFinished(id)
+		}
+	}
+}
+
+// sendIfFinished sends the final status of an analysis that has already
+// reached a terminal state
+func (c *wsClient) sendIfFinished(analysisID string) {
+	result, err := c.hub.analysisService.GetAnalysisStatus(analysisID)
+	if err != nil {
+		return
+	}
+	switch result.Status {
+	case models.StatusCompleted, models.StatusFailed, models.StatusCancelled:
+		c.reply(wsMessage{Type: "status", Event: &models.StatusEvent{
+			AnalysisID: analysisID,
+			Status:     result.Status,
+			Timestamp:  time.Now(),
+			Result:     result,
+		}})
+	}
+}
+
+// writePump delivers queued messages and keeps the connection alive with pings
+func (c *wsClient) writePump() {
+	ticker := time.NewTicker(wsPingPeriod)
+	defer func() {
+		ticker.Stop()
+		c.conn.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if !ok {
+				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+			if err := c.conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
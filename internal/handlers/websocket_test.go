@@ -0,0 +1,173 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// startHub serves a hub fed by the returned event channel on a test server.
+// The results are the analyses that exist; any other ID is unknown.
+func startHub(t *testing.T, allowAll bool, results ...*models.AnalysisResult) (chan models.StatusEvent, string) {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	events := make(chan models.StatusEvent)
+	mockService := new(MockAnalysisService)
+	mockService.On("SubscribeEvents").Return((<-chan models.StatusEvent)(events), func() {})
+	for _, result := range results {
+		mockService.On("GetAnalysisStatus", result.ID).Return(result, nil).Maybe()
+	}
+	mockService.On("GetAnalysisStatus", mock.Anything).Return(nil, services.ErrAnalysisNotFound).Maybe()
+
+	hub := NewHub(mockService, allowAll, nil, logger.New("error"))
+	go hub.Run()
+
+	router := gin.New()
+	router.GET("/api/v1/ws", hub.ServeWS)
+	server := httptest.NewServer(router)
+	t.Cleanup(server.Close)
+
+	return events, "ws" + strings.TrimPrefix(server.URL, "http") + "/api/v1/ws"
+}
+
+func dialHub(t *testing.T, url string) *websocket.Conn {
+	t.Helper()
+
+	conn, _, err := websocket.DefaultDialer.Dial(url, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func TestHub_ReceivesSubscribedTransition(t *testing.T) {
+	events, url := startHub(t, false)
+	conn := dialHub(t, url)
+
+	require.NoError(t, conn.WriteJSON(wsCommand{Action: "subscribe", AnalysisIDs: []string{"abc"}}))
+
+	var ack wsMessage
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, "subscribed", ack.Type)
+	assert.Equal(t, []string{"abc"}, ack.AnalysisIDs)
+
+	// Events for other analyses are filtered out
+	events <- models.StatusEvent{AnalysisID: "other", Status: models.StatusProcessing}
+	events <- models.StatusEvent{
+		AnalysisID: "abc",
+		Status:     models.StatusCompleted,
+		Result:     &models.AnalysisResult{ID: "abc", Status: models.StatusCompleted, PurityPercentage: 88},
+	}
+
+	var msg wsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "status", msg.Type)
+	require.NotNil(t, msg.Event)
+	assert.Equal(t, "abc", msg.Event.AnalysisID)
+	assert.Equal(t, models.StatusCompleted, msg.Event.Status)
+	require.NotNil(t, msg.Event.Result)
+	assert.Equal(t, 88.0, msg.Event.Result.PurityPercentage)
+}
+
+func TestHub_SubscribeToFinishedAnalysis(t *testing.T) {
+	finished := &models.AnalysisResult{ID: "done", Status: models.StatusCompleted, PurityPercentage: 91}
+	_, url := startHub(t, false, finished, &models.AnalysisResult{ID: "running", Status: models.StatusProcessing})
+	conn := dialHub(t, url)
+
+	require.NoError(t, conn.WriteJSON(wsCommand{Action: "subscribe", AnalysisIDs: []string{"running", "done"}}))
+
+	var ack wsMessage
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, "subscribed", ack.Type)
+
+	// Only the finished analysis is reported, without waiting for a transition
+	var msg wsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "status", msg.Type)
+	require.NotNil(t, msg.Event)
+	assert.Equal(t, "done", msg.Event.AnalysisID)
+	assert.Equal(t, models.StatusCompleted, msg.Event.Status)
+	require.NotNil(t, msg.Event.Result)
+	assert.Equal(t, 91.0, msg.Event.Result.PurityPercentage)
+}
+
+func TestHub_SubscribeAllRequiresPermission(t *testing.T) {
+	_, url := startHub(t, false)
+	conn := dialHub(t, url)
+
+	require.NoError(t, conn.WriteJSON(wsCommand{Action: "subscribe", All: true}))
+
+	var msg wsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	assert.Equal(t, "error", msg.Type)
+	assert.Contains(t, msg.Error, "not permitted")
+}
+
+func TestHub_SubscribeAll(t *testing.T) {
+	events, url := startHub(t, true)
+	conn := dialHub(t, url)
+
+	require.NoError(t, conn.WriteJSON(wsCommand{Action: "subscribe", All: true}))
+
+	var ack wsMessage
+	require.NoError(t, conn.ReadJSON(&ack))
+	assert.Equal(t, "subscribed", ack.Type)
+	assert.True(t, ack.All)
+
+	events <- models.StatusEvent{AnalysisID: "any", Status: models.StatusProcessing}
+
+	var msg wsMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	require.NotNil(t, msg.Event)
+	assert.Equal(t, "any", msg.Event.AnalysisID)
+	assert.Nil(t, msg.Event.Result)
+}
+
+func TestOriginChecker(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed []string
+		origin  string
+		want    bool
+	}{
+		{"no origin header", nil, "", true},
+		{"same host", nil, "https://api.example.com", true},
+		{"other host", nil, "https://evil.example.net", false},
+		{"listed origin", []string{"https://lab.example.com"}, "https://lab.example.com", true},
+		{"unlisted origin", []string{"https://lab.example.com"}, "https://api.example.com", false},
+		{"wildcard", []string{"*"}, "https://evil.example.net", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://api.example.com/api/v1/ws", nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			assert.Equal(t, tt.want, originChecker(tt.allowed)(req))
+		})
+	}
+}
+
+func TestHub_RejectsCrossSiteOrigin(t *testing.T) {
+	_, url := startHub(t, false)
+
+	header := http.Header{"Origin": []string{"https://evil.example.net"}}
+	_, resp, err := websocket.DefaultDialer.Dial(url, header)
+	require.Error(t, err)
+	require.NotNil(t, resp)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+}
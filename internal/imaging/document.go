@@ -0,0 +1,108 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Thresholds for the document-scan heuristic. Scanned forms are dominated by
+// bright, colorless paper with a small share of dark ink and very few mid-tones,
+// while sample photographs have a broad spread of intermediate gray levels.
+const (
+	paperLevel       = 200  // gray level at or above which a pixel counts as paper
+	inkLevel         = 80   // gray level at or below which a pixel counts as ink
+	minPaperFraction = 0.6  // documents are mostly blank paper
+	minInkFraction   = 0.01 // ...with some printed or written content
+	maxInkFraction   = 0.35
+	maxMidFraction   = 0.15 // ...and almost nothing in between
+	minEdgeDensity   = 0.01 // text produces many sharp transitions
+	edgeContrast     = 100  // gray level jump that counts as a sharp edge
+	maxColorfulness  = 20.0 // mean channel spread; paper and ink are near-gray
+	maxSampledPixels = 1000000
+	documentWarning  = "Image looks like a document scan rather than a mineral sample"
+)
+
+// DocumentCheck holds the statistics used to decide whether an image is a document scan
+type DocumentCheck struct {
+	LikelyDocument bool
+	PaperFraction  float64
+	InkFraction    float64
+	MidFraction    float64
+	EdgeDensity    float64
+	Colorfulness   float64
+}
+
+// Warning returns the advisory message for a likely document, or an empty string
+func (d DocumentCheck) Warning() string {
+	if !d.LikelyDocument {
+		return ""
+	}
+	return fmt.Sprintf("%s (%.0f%% blank paper, %.0f%% mid-tones)", documentWarning, d.PaperFraction*100, d.MidFraction*100)
+}
+
+// CheckDocument applies a lightweight heuristic to flag images that look like
+// scanned paperwork. Large images are subsampled so the check stays cheap.
+func CheckDocument(img image.Image) DocumentCheck {
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return DocumentCheck{}
+	}
+
+	step := 1
+	if pixels := width * height; pixels > maxSampledPixels {
+		step = int(math.Ceil(math.Sqrt(float64(pixels) / maxSampledPixels)))
+	}
+
+	var sampled, paper, ink, mid, edges int
+	var colorSpread float64
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		previous := -1
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			c := img.At(x, y)
+			gray := int(color.GrayModel.Convert(c).(color.Gray).Y)
+
+			r, g, b, _ := c.RGBA()
+			colorSpread += (math.Abs(float64(r>>8)-float64(g>>8)) + math.Abs(float64(g>>8)-float64(b>>8))) / 2
+
+			switch {
+			case gray >= paperLevel:
+				paper++
+			case gray <= inkLevel:
+				ink++
+			default:
+				mid++
+			}
+
+			if previous >= 0 && abs(gray-previous) >= edgeContrast {
+				edges++
+			}
+			previous = gray
+			sampled++
+		}
+	}
+
+	check := DocumentCheck{
+		PaperFraction: float64(paper) / float64(sampled),
+		InkFraction:   float64(ink) / float64(sampled),
+		MidFraction:   float64(mid) / float64(sampled),
+		EdgeDensity:   float64(edges) / float64(sampled),
+		Colorfulness:  colorSpread / float64(sampled),
+	}
+	check.LikelyDocument = check.PaperFraction >= minPaperFraction &&
+		check.InkFraction >= minInkFraction && check.InkFraction <= maxInkFraction &&
+		check.MidFraction <= maxMidFraction &&
+		check.EdgeDensity >= minEdgeDensity &&
+		check.Colorfulness <= maxColorfulness
+
+	return check
+}
+
+func abs(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
@@ -0,0 +1,70 @@
+package imaging
+
+import (
+	"image"
+	"image/color"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// documentImage renders a white page with rows of dark, word-like strokes
+func documentImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 500))
+	for y := 0; y < 500; y++ {
+		for x := 0; x < 400; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for line := 40; line < 460; line += 24 {
+		x := 30
+		for x < 360 {
+			word := 15 + rng.Intn(40)
+			for dy := 0; dy < 8; dy++ {
+				for dx := 0; dx < word && x+dx < 370; dx++ {
+					// Broken strokes approximate letter shapes
+					if (dx/3)%2 == 0 {
+						img.Set(x+dx, line+dy, color.Black)
+					}
+				}
+			}
+			x += word + 10
+		}
+	}
+
+	return img
+}
+
+// sampleImage renders light grains on a mid-gray matrix with noise
+func sampleImage() image.Image {
+	img := image.NewRGBA(image.Rect(0, 0, 400, 400))
+	rng := rand.New(rand.NewSource(2))
+	for y := 0; y < 400; y++ {
+		for x := 0; x < 400; x++ {
+			level := 110 + rng.Intn(40)
+			if ((x/25)+(y/25))%3 == 0 {
+				level = 170 + rng.Intn(40)
+			}
+			img.Set(x, y, color.RGBA{uint8(level), uint8(level - 8), uint8(level - 15), 255})
+		}
+	}
+
+	return img
+}
+
+func TestCheckDocument_FlagsDocumentScan(t *testing.T) {
+	check := CheckDocument(documentImage())
+
+	assert.True(t, check.LikelyDocument, "%+v", check)
+	assert.Contains(t, check.Warning(), "document scan")
+}
+
+func TestCheckDocument_AcceptsSample(t *testing.T) {
+	check := CheckDocument(sampleImage())
+
+	assert.False(t, check.LikelyDocument, "%+v", check)
+	assert.Empty(t, check.Warning())
+}
@@ -0,0 +1,131 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/rwcarlsen/goexif/exif"
+)
+
+// EXIFData is the camera or microscope information embedded in an image
+type EXIFData = models.EXIFData
+
+// EXIF resolution units, as named in EXIFData.ResolutionUnit
+const (
+	ResolutionInch       = "inch"
+	ResolutionCentimeter = "cm"
+)
+
+// micronsPerUnit converts the EXIF resolution units to microns
+var micronsPerUnit = map[string]float64{
+	ResolutionInch:       25400,
+	ResolutionCentimeter: 10000,
+}
+
+// placeholderDPI are resolutions cameras write whatever they photograph; they
+// describe a print size, not the scale of the sample
+var placeholderDPI = []float64{72, 96}
+
+// ExtractEXIF reads the EXIF block of a JPEG or TIFF image. It fails when the
+// image has none; tags missing from the block are left empty.
+func ExtractEXIF(path string) (*EXIFData, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	x, err := exif.Decode(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EXIF: %w", err)
+	}
+
+	data := &EXIFData{
+		Make:        exifString(x, exif.Make),
+		Model:       exifString(x, exif.Model),
+		XResolution: exifRational(x, exif.XResolution),
+		YResolution: exifRational(x, exif.YResolution),
+		UserComment: userComment(x),
+	}
+	if taken, err := x.DateTime(); err == nil {
+		data.DateTime = &taken
+	}
+	if tag, err := x.Get(exif.ResolutionUnit); err == nil {
+		// TIFF defaults to inches when the unit is absent
+		switch unit, _ := tag.Int(0); unit {
+		case 2:
+			data.ResolutionUnit = ResolutionInch
+		case 3:
+			data.ResolutionUnit = ResolutionCentimeter
+		}
+	} else if data.XResolution > 0 {
+		data.ResolutionUnit = ResolutionInch
+	}
+
+	return data, nil
+}
+
+// PixelsPerMicron returns the spatial scale given by the EXIF resolution. It
+// reports false when there is no usable resolution, the horizontal and
+// vertical scales differ, or the resolution is a camera's placeholder DPI.
+func PixelsPerMicron(data *EXIFData) (float64, bool) {
+	microns, ok := micronsPerUnit[data.ResolutionUnit]
+	if !ok || data.XResolution <= 0 {
+		return 0, false
+	}
+	if data.YResolution > 0 && data.YResolution != data.XResolution {
+		return 0, false
+	}
+	if data.ResolutionUnit == ResolutionInch {
+		for _, dpi := range placeholderDPI {
+			if data.XResolution == dpi {
+				return 0, false
+			}
+		}
+	}
+	return data.XResolution / microns, true
+}
+
+// exifString returns an ASCII tag without its padding, or "" when absent
+func exifString(x *exif.Exif, name exif.FieldName) string {
+	tag, err := x.Get(name)
+	if err != nil {
+		return ""
+	}
+	value, err := tag.StringVal()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(value, "\x00"))
+}
+
+// exifRational returns a rational tag as a float, or 0 when absent
+func exifRational(x *exif.Exif, name exif.FieldName) float64 {
+	tag, err := x.Get(name)
+	if err != nil {
+		return 0
+	}
+	num, den, err := tag.Rat2(0)
+	if err != nil || den == 0 {
+		return 0
+	}
+	return float64(num) / float64(den)
+}
+
+// userComment decodes the UserComment tag. Its first 8 bytes name the
+// character set; only ASCII and unspecified comments are read.
+func userComment(x *exif.Exif) string {
+	tag, err := x.Get(exif.UserComment)
+	if err != nil || len(tag.Val) < 8 {
+		return ""
+	}
+	charset, comment := tag.Val[:8], tag.Val[8:]
+	if !bytes.Equal(charset, []byte("ASCII\x00\x00\x00")) && !bytes.Equal(charset, make([]byte, 8)) {
+		return ""
+	}
+	return strings.TrimSpace(strings.TrimRight(string(comment), "\x00"))
+}
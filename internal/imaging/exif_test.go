@@ -0,0 +1,142 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/jpeg"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// ifdEntry is one TIFF tag; values over 4 bytes are stored after the IFD
+type ifdEntry struct {
+	tag, typ uint16
+	count    uint32
+	value    []byte
+}
+
+// encodeIFD lays out a little-endian IFD starting at offset start
+func encodeIFD(start uint32, entries []ifdEntry) []byte {
+	var ifd, data bytes.Buffer
+	dataStart := start + 2 + 12*uint32(len(entries)) + 4
+	binary.Write(&ifd, binary.LittleEndian, uint16(len(entries)))
+	for _, e := range entries {
+		binary.Write(&ifd, binary.LittleEndian, e.tag)
+		binary.Write(&ifd, binary.LittleEndian, e.typ)
+		binary.Write(&ifd, binary.LittleEndian, e.count)
+		if len(e.value) <= 4 {
+			ifd.Write(append(e.value, make([]byte, 4-len(e.value))...))
+			continue
+		}
+		binary.Write(&ifd, binary.LittleEndian, dataStart+uint32(data.Len()))
+		data.Write(e.value)
+	}
+	binary.Write(&ifd, binary.LittleEndian, uint32(0))
+	return append(ifd.Bytes(), data.Bytes()...)
+}
+
+func asciiEntry(tag uint16, s string) ifdEntry {
+	return ifdEntry{tag, 2, uint32(len(s) + 1), append([]byte(s), 0)}
+}
+
+func rationalEntry(tag uint16, num, den uint32) ifdEntry {
+	value := binary.LittleEndian.AppendUint32(nil, num)
+	return ifdEntry{tag, 5, 1, binary.LittleEndian.AppendUint32(value, den)}
+}
+
+// exifJPEG writes a small JPEG whose EXIF block records a microscope camera
+// at dpi pixels per inch
+func exifJPEG(t *testing.T, dpi uint32) string {
+	t.Helper()
+
+	ifd0 := func(exifOffset uint32) []ifdEntry {
+		return []ifdEntry{
+			asciiEntry(0x010F, "Olympus"),
+			asciiEntry(0x0110, "DP27"),
+			rationalEntry(0x011A, dpi, 1),
+			rationalEntry(0x011B, dpi, 1),
+			{0x0128, 3, 1, binary.LittleEndian.AppendUint16(nil, 2)},
+			asciiEntry(0x0132, "2024:05:01 12:30:00"),
+			{0x8769, 4, 1, binary.LittleEndian.AppendUint32(nil, exifOffset)},
+		}
+	}
+	exifOffset := 8 + uint32(len(encodeIFD(8, ifd0(0))))
+	comment := append([]byte("ASCII\x00\x00\x00"), "40x objective"...)
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	tiff = append(tiff, encodeIFD(8, ifd0(exifOffset))...)
+	tiff = append(tiff, encodeIFD(exifOffset, []ifdEntry{{0x9286, 7, uint32(len(comment)), comment}})...)
+
+	var img bytes.Buffer
+	require.NoError(t, jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+
+	// The APP1 segment goes straight after the start-of-image marker
+	app1 := append([]byte("Exif\x00\x00"), tiff...)
+	var out bytes.Buffer
+	out.Write(img.Bytes()[:2])
+	out.Write([]byte{0xFF, 0xE1})
+	binary.Write(&out, binary.BigEndian, uint16(len(app1)+2))
+	out.Write(app1)
+	out.Write(img.Bytes()[2:])
+
+	path := filepath.Join(t.TempDir(), "sample.jpg")
+	require.NoError(t, os.WriteFile(path, out.Bytes(), 0o644))
+	return path
+}
+
+func TestExtractEXIF(t *testing.T) {
+	data, err := ExtractEXIF(exifJPEG(t, 127000))
+	require.NoError(t, err)
+
+	assert.Equal(t, "Olympus", data.Make)
+	assert.Equal(t, "DP27", data.Model)
+	assert.Equal(t, 127000.0, data.XResolution)
+	assert.Equal(t, 127000.0, data.YResolution)
+	assert.Equal(t, ResolutionInch, data.ResolutionUnit)
+	assert.Equal(t, "40x objective", data.UserComment)
+	require.NotNil(t, data.DateTime)
+	assert.Equal(t, 2024, data.DateTime.Year())
+
+	// 127000 pixels per inch is 5 pixels per micron
+	ppm, ok := PixelsPerMicron(data)
+	assert.True(t, ok)
+	assert.InDelta(t, 5.0, ppm, 1e-9)
+}
+
+func TestExtractEXIF_NoEXIF(t *testing.T) {
+	var img bytes.Buffer
+	require.NoError(t, jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 8, 8)), nil))
+	path := filepath.Join(t.TempDir(), "plain.jpg")
+	require.NoError(t, os.WriteFile(path, img.Bytes(), 0o644))
+
+	_, err := ExtractEXIF(path)
+	assert.Error(t, err)
+}
+
+func TestPixelsPerMicron(t *testing.T) {
+	tests := []struct {
+		name string
+		data EXIFData
+		want float64
+		ok   bool
+	}{
+		{"centimeters", EXIFData{XResolution: 20000, YResolution: 20000, ResolutionUnit: ResolutionCentimeter}, 2, true},
+		{"camera placeholder", EXIFData{XResolution: 72, YResolution: 72, ResolutionUnit: ResolutionInch}, 0, false},
+		{"anisotropic", EXIFData{XResolution: 25400, YResolution: 50800, ResolutionUnit: ResolutionInch}, 0, false},
+		{"no unit", EXIFData{XResolution: 25400}, 0, false},
+		{"no resolution", EXIFData{ResolutionUnit: ResolutionInch}, 0, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := PixelsPerMicron(&tt.data)
+			assert.Equal(t, tt.ok, ok)
+			assert.InDelta(t, tt.want, got, 1e-9)
+		})
+	}
+}
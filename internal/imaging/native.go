@@ -0,0 +1,166 @@
+package imaging
+
+import (
+	"context"
+	"image"
+)
+
+// GoNativeAnalyzer measures particles in pure Go, following the steps of the
+// Fiji macro: grayscale conversion, an Otsu threshold and particle analysis of
+// the light regions. It needs no Java runtime, so it stands in for Fiji where
+// Fiji cannot be installed. Blur, contrast enhancement and circularity
+// filters are not applied.
+type GoNativeAnalyzer struct {
+	// MinParticleSize and MaxParticleSize bound the area in pixels of the
+	// particles counted; a zero maximum leaves it unbounded
+	MinParticleSize float64
+	MaxParticleSize float64
+
+	// With a positive TileSize, particles are labeled tile by tile as
+	// PlanTiles lays them out, so the mask never covers more than one tile.
+	// Each particle is counted by the tile whose core holds its centroid.
+	TileSize    int
+	TileOverlap int
+}
+
+// Particle is a 4-connected region of pixels above the threshold
+type Particle struct {
+	Area      int
+	CentroidX float64
+	CentroidY float64
+}
+
+// ParticleAnalysis is the outcome of a native analysis. Areas are in pixels.
+type ParticleAnalysis struct {
+	Threshold uint8
+	Particles []Particle
+	TotalArea float64
+	ImageArea float64
+}
+
+// PurityPercentage is the share of the image covered by counted particles
+func (p *ParticleAnalysis) PurityPercentage() float64 {
+	if p.ImageArea == 0 {
+		return 0
+	}
+	return min(p.TotalArea/p.ImageArea*100, 100)
+}
+
+// AverageParticleSize is the mean particle area, 0 when none were counted
+func (p *ParticleAnalysis) AverageParticleSize() float64 {
+	if len(p.Particles) == 0 {
+		return 0
+	}
+	return p.TotalArea / float64(len(p.Particles))
+}
+
+// Analyze thresholds the image and measures its particles. It returns ctx's
+// error when ctx is done, which is checked before each row.
+func (a GoNativeAnalyzer) Analyze(ctx context.Context, img image.Image) (*ParticleAnalysis, error) {
+	bounds := img.Bounds()
+	row := make([]uint8, bounds.Dx())
+
+	// The threshold is global, so every tile separates gypsum alike
+	var hist [256]int
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		grayRow(img, bounds.Min.X, y, row)
+		for _, v := range row {
+			hist[v]++
+		}
+	}
+	threshold, _ := otsu(hist)
+
+	tiles := []Tile{{Bounds: bounds, Core: bounds}}
+	if a.TileSize > 0 {
+		tiles = PlanTiles(bounds, a.TileSize, a.TileOverlap)
+	}
+
+	analysis := &ParticleAnalysis{Threshold: threshold, ImageArea: float64(bounds.Dx() * bounds.Dy())}
+	var mask []bool
+	for _, tile := range tiles {
+		width, height := tile.Bounds.Dx(), tile.Bounds.Dy()
+		// Every pixel is assigned below, so the mask is reused unclean
+		if cap(mask) < width*height {
+			mask = make([]bool, width*height)
+		}
+		mask = mask[:width*height]
+
+		// Gypsum appears light, so pixels above the threshold are foreground
+		for y := 0; y < height; y++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			grayRow(img, tile.Bounds.Min.X, tile.Bounds.Min.Y+y, row[:width])
+			for x, v := range row[:width] {
+				mask[y*width+x] = v > threshold
+			}
+		}
+
+		for _, particle := range labelParticles(mask, width, height) {
+			x := particle.CentroidX + float64(tile.Bounds.Min.X)
+			y := particle.CentroidY + float64(tile.Bounds.Min.Y)
+			if x < float64(tile.Core.Min.X) || x >= float64(tile.Core.Max.X) || y < float64(tile.Core.Min.Y) || y >= float64(tile.Core.Max.Y) {
+				continue
+			}
+			area := float64(particle.Area)
+			if area < a.MinParticleSize || (a.MaxParticleSize > 0 && area > a.MaxParticleSize) {
+				continue
+			}
+
+			// Centroids are reported relative to the image's bounds
+			particle.CentroidX = x - float64(bounds.Min.X)
+			particle.CentroidY = y - float64(bounds.Min.Y)
+			analysis.Particles = append(analysis.Particles, particle)
+			analysis.TotalArea += area
+		}
+	}
+	return analysis, nil
+}
+
+// labelParticles flood-fills the 4-connected foreground regions of mask and
+// returns each region's area and centroid. Pixels are cleared as they are
+// visited.
+func labelParticles(mask []bool, width, height int) []Particle {
+	var particles []Particle
+	var stack []int
+
+	for start, set := range mask {
+		if !set {
+			continue
+		}
+		mask[start] = false
+		stack = append(stack[:0], start)
+
+		var area int
+		var sumX, sumY float64
+		for len(stack) > 0 {
+			i := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			x, y := i%width, i/width
+			area++
+			sumX += float64(x)
+			sumY += float64(y)
+
+			for _, n := range [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}} {
+				if n[0] < 0 || n[0] >= width || n[1] < 0 || n[1] >= height {
+					continue
+				}
+				if j := n[1]*width + n[0]; mask[j] {
+					mask[j] = false
+					stack = append(stack, j)
+				}
+			}
+		}
+
+		// Centroids are measured at pixel centers, as ImageJ does
+		particles = append(particles, Particle{
+			Area:      area,
+			CentroidX: sumX/float64(area) + 0.5,
+			CentroidY: sumY/float64(area) + 0.5,
+		})
+	}
+	return particles
+}
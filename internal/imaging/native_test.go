@@ -0,0 +1,104 @@
+package imaging
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// particleImage draws white rectangles on a black 20x20 image
+func particleImage(rects ...image.Rectangle) *image.Gray {
+	img := image.NewGray(image.Rect(0, 0, 20, 20))
+	for _, r := range rects {
+		for y := r.Min.Y; y < r.Max.Y; y++ {
+			for x := r.Min.X; x < r.Max.X; x++ {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	return img
+}
+
+func TestGoNativeAnalyzer(t *testing.T) {
+	img := particleImage(
+		image.Rect(1, 1, 4, 4),     // 9 px
+		image.Rect(10, 10, 15, 15), // 25 px
+		image.Rect(18, 0, 19, 1),   // 1 px
+	)
+
+	analysis, err := GoNativeAnalyzer{MinParticleSize: 2}.Analyze(context.Background(), img)
+	require.NoError(t, err)
+
+	require.Len(t, analysis.Particles, 2, "particles below the minimum size are not counted")
+	assert.Equal(t, 9, analysis.Particles[0].Area)
+	assert.Equal(t, 2.5, analysis.Particles[0].CentroidX)
+	assert.Equal(t, 2.5, analysis.Particles[0].CentroidY)
+	assert.Equal(t, 25, analysis.Particles[1].Area)
+	assert.Equal(t, 34.0, analysis.TotalArea)
+	assert.Equal(t, 400.0, analysis.ImageArea)
+	assert.InDelta(t, 8.5, analysis.PurityPercentage(), 1e-9)
+	assert.Equal(t, 17.0, analysis.AverageParticleSize())
+
+	bounded, err := GoNativeAnalyzer{MaxParticleSize: 10}.Analyze(context.Background(), img)
+	require.NoError(t, err)
+	assert.Len(t, bounded.Particles, 2, "particles above the maximum size are not counted")
+	assert.Equal(t, 10.0, bounded.TotalArea)
+}
+
+func TestGoNativeAnalyzer_FourConnectivity(t *testing.T) {
+	// Two squares touching only at a corner are separate particles
+	img := particleImage(image.Rect(2, 2, 4, 4), image.Rect(4, 4, 6, 6))
+
+	analysis, err := GoNativeAnalyzer{}.Analyze(context.Background(), img)
+	require.NoError(t, err)
+	assert.Len(t, analysis.Particles, 2)
+
+	empty, err := GoNativeAnalyzer{}.Analyze(context.Background(), particleImage())
+	require.NoError(t, err)
+	assert.Empty(t, empty.Particles)
+	assert.Zero(t, empty.PurityPercentage())
+	assert.Zero(t, empty.AverageParticleSize())
+}
+
+func TestGoNativeAnalyzer_Tiles(t *testing.T) {
+	// The 25 px particle straddles the seam between the tile cores at 8
+	img := particleImage(image.Rect(1, 1, 4, 4), image.Rect(6, 6, 11, 11))
+
+	whole, err := GoNativeAnalyzer{}.Analyze(context.Background(), img)
+	require.NoError(t, err)
+	tiled, err := GoNativeAnalyzer{TileSize: 12, TileOverlap: 2}.Analyze(context.Background(), img)
+	require.NoError(t, err)
+	assert.Equal(t, whole, tiled, "each particle is counted once, by the tile owning its centroid")
+}
+
+func TestGoNativeAnalyzer_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := GoNativeAnalyzer{}.Analyze(ctx, particleImage())
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestGrayRow_MatchesGrayModel(t *testing.T) {
+	rgba := image.NewRGBA(image.Rect(0, 0, 3, 1))
+	nrgba := image.NewNRGBA(image.Rect(0, 0, 3, 1))
+	for x, c := range []color.NRGBA{{200, 10, 90, 255}, {13, 250, 7, 128}, {0, 0, 0, 0}} {
+		rgba.Set(x, 0, c)
+		nrgba.SetNRGBA(x, 0, c)
+	}
+	ycbcr := image.NewYCbCr(image.Rect(0, 0, 3, 1), image.YCbCrSubsampleRatio420)
+	copy(ycbcr.Y, []uint8{16, 128, 235})
+	paletted := image.NewPaletted(image.Rect(0, 0, 3, 1), color.Palette{color.White, color.Black})
+
+	for _, img := range []image.Image{rgba, nrgba, ycbcr, paletted} {
+		row := make([]uint8, 3)
+		grayRow(img, 0, 0, row)
+		for x := range row {
+			assert.Equal(t, color.GrayModel.Convert(img.At(x, 0)).(color.Gray).Y, row[x], "%T pixel %d", img, x)
+		}
+	}
+}
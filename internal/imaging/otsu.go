@@ -0,0 +1,167 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"os"
+
+	_ "golang.org/x/image/bmp"  // register BMP decoder
+	_ "golang.org/x/image/tiff" // register TIFF decoder
+	_ "golang.org/x/image/webp" // register WebP decoder
+)
+
+// DecodeFile opens and decodes an image file, returning the image and its format name
+func DecodeFile(path string) (image.Image, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	img, format, err := image.Decode(f)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return img, format, nil
+}
+
+// DecodeConfigFile reads an image file's dimensions and format without decoding its pixels
+func DecodeConfigFile(path string) (image.Config, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return image.Config{}, "", fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	cfg, format, err := image.DecodeConfig(f)
+	if err != nil {
+		return image.Config{}, "", fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	return cfg, format, nil
+}
+
+// Histogram builds a 256-bin grayscale histogram of the image
+func Histogram(img image.Image) [256]int {
+	var hist [256]int
+
+	bounds := img.Bounds()
+	row := make([]uint8, bounds.Dx())
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		grayRow(img, bounds.Min.X, y, row)
+		for _, v := range row {
+			hist[v]++
+		}
+	}
+
+	return hist
+}
+
+// grayRow writes the grayscale values of the len(row) pixels of img starting
+// at (x, y) to row, as color.GrayModel converts them. Common image types are
+// read without boxing each pixel in a color.Color.
+func grayRow(img image.Image, x, y int, row []uint8) {
+	switch img := img.(type) {
+	case *image.Gray:
+		i := img.PixOffset(x, y)
+		copy(row, img.Pix[i:i+len(row)])
+	case *image.RGBA:
+		for i := range row {
+			r, g, b, _ := img.RGBAAt(x+i, y).RGBA()
+			row[i] = luma(r, g, b)
+		}
+	case *image.NRGBA:
+		for i := range row {
+			r, g, b, _ := img.NRGBAAt(x+i, y).RGBA()
+			row[i] = luma(r, g, b)
+		}
+	case *image.YCbCr:
+		for i := range row {
+			r, g, b, _ := img.YCbCrAt(x+i, y).RGBA()
+			row[i] = luma(r, g, b)
+		}
+	case *image.Gray16:
+		for i := range row {
+			row[i] = uint8(img.Gray16At(x+i, y).Y >> 8)
+		}
+	default:
+		for i := range row {
+			row[i] = color.GrayModel.Convert(img.At(x+i, y)).(color.Gray).Y
+		}
+	}
+}
+
+// luma weights 16-bit color channels into an 8-bit gray level exactly as
+// color.GrayModel does
+func luma(r, g, b uint32) uint8 {
+	return uint8((19595*r + 38470*g + 7471*b + 1<<15) >> 24)
+}
+
+// OtsuThreshold finds the grayscale threshold that maximizes the inter-class
+// variance and returns it along with the fraction of pixels above it
+func OtsuThreshold(img image.Image) (threshold uint8, whiteAreaFraction float64) {
+	return otsu(Histogram(img))
+}
+
+// otsu applies Otsu's method to a grayscale histogram
+func otsu(hist [256]int) (threshold uint8, whiteAreaFraction float64) {
+	total := 0
+	sum := 0.0
+	for i, count := range hist {
+		total += count
+		sum += float64(i * count)
+	}
+	if total == 0 {
+		return 0, 0
+	}
+
+	var (
+		weightBackground int
+		sumBackground    float64
+		maxVariance      = -1.0
+	)
+	for t := 0; t < 256; t++ {
+		weightBackground += hist[t]
+		if weightBackground == 0 {
+			continue
+		}
+		weightForeground := total - weightBackground
+		if weightForeground == 0 {
+			break
+		}
+
+		sumBackground += float64(t * hist[t])
+		meanBackground := sumBackground / float64(weightBackground)
+		meanForeground := (sum - sumBackground) / float64(weightForeground)
+
+		diff := meanBackground - meanForeground
+		variance := float64(weightBackground) * float64(weightForeground) * diff * diff
+		if variance > maxVariance {
+			maxVariance = variance
+			threshold = uint8(t)
+		}
+	}
+
+	white := 0
+	for i := int(threshold) + 1; i < 256; i++ {
+		white += hist[i]
+	}
+
+	return threshold, float64(white) / float64(total)
+}
+
+// EstimatePurity runs a native Otsu segmentation on the image file and returns
+// the percentage of the image classified as gypsum (light areas)
+func EstimatePurity(path string) (float64, error) {
+	img, _, err := DecodeFile(path)
+	if err != nil {
+		return 0, err
+	}
+
+	_, fraction := OtsuThreshold(img)
+	return fraction * 100, nil
+}
@@ -0,0 +1,77 @@
+// Package phash computes perceptual hashes that stay nearly equal when an
+// image is re-encoded, resized or slightly retouched
+package phash
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg" // register JPEG decoder
+	_ "image/png"  // register PNG decoder
+	"io"
+	"math/bits"
+
+	_ "golang.org/x/image/tiff" // register TIFF decoder
+)
+
+// Thumbnail size of the difference hash: each of the 8 rows yields 8 bits by
+// comparing 9 neighbouring columns
+const (
+	thumbWidth  = 9
+	thumbHeight = 8
+)
+
+// ComputeDHash decodes an image and returns its 64-bit difference hash. Each
+// bit records whether a pixel of the grayscale thumbnail is brighter than its
+// right neighbour.
+func ComputeDHash(r io.Reader) (uint64, error) {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	thumb := thumbnail(img)
+	var hash uint64
+	for y := 0; y < thumbHeight; y++ {
+		for x := 0; x < thumbWidth-1; x++ {
+			hash <<= 1
+			if thumb[y][x] > thumb[y][x+1] {
+				hash |= 1
+			}
+		}
+	}
+	return hash, nil
+}
+
+// Distance is the number of differing bits between two hashes
+func Distance(a, b uint64) int {
+	return bits.OnesCount64(a ^ b)
+}
+
+// thumbnail shrinks the image to thumbWidth x thumbHeight by averaging the
+// luminance of the pixels each thumbnail cell covers
+func thumbnail(img image.Image) [thumbHeight][thumbWidth]float64 {
+	var thumb [thumbHeight][thumbWidth]float64
+	bounds := img.Bounds()
+	if bounds.Empty() {
+		return thumb
+	}
+
+	for ty := 0; ty < thumbHeight; ty++ {
+		y0 := bounds.Min.Y + ty*bounds.Dy()/thumbHeight
+		y1 := max(bounds.Min.Y+(ty+1)*bounds.Dy()/thumbHeight, y0+1)
+		for tx := 0; tx < thumbWidth; tx++ {
+			x0 := bounds.Min.X + tx*bounds.Dx()/thumbWidth
+			x1 := max(bounds.Min.X+(tx+1)*bounds.Dx()/thumbWidth, x0+1)
+
+			var sum float64
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					sum += float64(color.Gray16Model.Convert(img.At(x, y)).(color.Gray16).Y)
+				}
+			}
+			thumb[ty][tx] = sum / float64((y1-y0)*(x1-x0))
+		}
+	}
+	return thumb
+}
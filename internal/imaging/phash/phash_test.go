@@ -0,0 +1,82 @@
+package phash
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sampleImage draws bright grains on a dark background, scaled to size
+func sampleImage(size int) image.Image {
+	img := image.NewGray(image.Rect(0, 0, size, size))
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			u, v := x*100/size, y*100/size
+			if (u/13+v/17)%3 == 0 || (u-v)*(u-v) < 80 {
+				img.SetGray(x, y, color.Gray{Y: 230})
+			} else {
+				img.SetGray(x, y, color.Gray{Y: uint8(20 + u/2)})
+			}
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestComputeDHash_SimilarImages(t *testing.T) {
+	original, err := ComputeDHash(bytes.NewReader(encodePNG(t, sampleImage(200))))
+	require.NoError(t, err)
+	assert.NotZero(t, original)
+
+	var recompressed bytes.Buffer
+	require.NoError(t, jpeg.Encode(&recompressed, sampleImage(200), &jpeg.Options{Quality: 60}))
+	reencoded, err := ComputeDHash(&recompressed)
+	require.NoError(t, err)
+	assert.LessOrEqual(t, Distance(original, reencoded), 4)
+
+	resized, err := ComputeDHash(bytes.NewReader(encodePNG(t, sampleImage(120))))
+	require.NoError(t, err)
+	assert.LessOrEqual(t, Distance(original, resized), 4)
+}
+
+func TestComputeDHash_DifferentImages(t *testing.T) {
+	sample, err := ComputeDHash(bytes.NewReader(encodePNG(t, sampleImage(200))))
+	require.NoError(t, err)
+
+	// A horizontal gradient brightening to the right sets no bits at all
+	gradient := image.NewGray(image.Rect(0, 0, 200, 200))
+	for y := 0; y < 200; y++ {
+		for x := 0; x < 200; x++ {
+			gradient.SetGray(x, y, color.Gray{Y: uint8(x)})
+		}
+	}
+	other, err := ComputeDHash(bytes.NewReader(encodePNG(t, gradient)))
+	require.NoError(t, err)
+	assert.Zero(t, other)
+	assert.Greater(t, Distance(sample, other), 4)
+}
+
+func TestComputeDHash_InvalidImage(t *testing.T) {
+	_, err := ComputeDHash(strings.NewReader("not an image"))
+	assert.Error(t, err)
+}
+
+func TestDistance(t *testing.T) {
+	assert.Equal(t, 0, Distance(0xff00, 0xff00))
+	assert.Equal(t, 64, Distance(0, ^uint64(0)))
+	assert.Equal(t, 2, Distance(0b1010, 0b0110))
+}
@@ -0,0 +1,67 @@
+package imaging
+
+import (
+	"fmt"
+	"image"
+	"image/draw"
+	"image/png"
+	"os"
+)
+
+// Tile is one cell of a tiling grid. Core cells partition the image; Bounds
+// extends the core by the overlap so particles on a seam are seen whole by
+// the tile that owns their centroid.
+type Tile struct {
+	Index  int
+	Bounds image.Rectangle
+	Core   image.Rectangle
+}
+
+// PlanTiles splits bounds into tiles at most size pixels across, each
+// overlapping its neighbours by overlap pixels. overlap must be less than half of size.
+func PlanTiles(bounds image.Rectangle, size, overlap int) []Tile {
+	step := size - 2*overlap
+	if step <= 0 || bounds.Empty() {
+		return nil
+	}
+
+	var tiles []Tile
+	for y := bounds.Min.Y; y < bounds.Max.Y; y += step {
+		for x := bounds.Min.X; x < bounds.Max.X; x += step {
+			core := image.Rect(x, y, x+step, y+step).Intersect(bounds)
+			tiles = append(tiles, Tile{
+				Index:  len(tiles),
+				Bounds: core.Inset(-overlap).Intersect(bounds),
+				Core:   core,
+			})
+		}
+	}
+
+	return tiles
+}
+
+// WriteTilePNG crops rect out of img and saves it losslessly as a PNG
+func WriteTilePNG(img image.Image, rect image.Rectangle, path string) error {
+	var tile image.Image
+	if sub, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	}); ok {
+		tile = sub.SubImage(rect)
+	} else {
+		rgba := image.NewRGBA(rect)
+		draw.Draw(rgba, rect, img, rect.Min, draw.Src)
+		tile = rgba
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create tile: %w", err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, tile); err != nil {
+		return fmt.Errorf("failed to encode tile: %w", err)
+	}
+
+	return nil
+}
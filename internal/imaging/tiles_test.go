@@ -0,0 +1,57 @@
+package imaging
+
+import (
+	"image"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlanTiles_CoresPartitionImage(t *testing.T) {
+	bounds := image.Rect(0, 0, 250, 130)
+	tiles := PlanTiles(bounds, 100, 10)
+
+	// 80px cores: 4 columns by 2 rows
+	require.Len(t, tiles, 8)
+
+	covered := 0
+	for i, tile := range tiles {
+		assert.Equal(t, i, tile.Index)
+		assert.True(t, tile.Core.In(tile.Bounds))
+		assert.True(t, tile.Bounds.In(bounds))
+		assert.LessOrEqual(t, tile.Bounds.Dx(), 100)
+		assert.LessOrEqual(t, tile.Bounds.Dy(), 100)
+
+		for _, other := range tiles[i+1:] {
+			assert.True(t, tile.Core.Intersect(other.Core).Empty())
+		}
+		covered += tile.Core.Dx() * tile.Core.Dy()
+	}
+	assert.Equal(t, 250*130, covered)
+
+	assert.Equal(t, image.Rect(70, 70, 170, 130), tiles[5].Bounds)
+}
+
+func TestPlanTiles_InvalidLayout(t *testing.T) {
+	assert.Nil(t, PlanTiles(image.Rect(0, 0, 100, 100), 20, 10))
+	assert.Nil(t, PlanTiles(image.Rect(0, 0, 0, 0), 100, 10))
+}
+
+func TestWriteTilePNG(t *testing.T) {
+	img := image.NewGray(image.Rect(0, 0, 50, 40))
+	img.Pix[img.PixOffset(30, 20)] = 255
+
+	path := filepath.Join(t.TempDir(), "tile.png")
+	require.NoError(t, WriteTilePNG(img, image.Rect(25, 15, 45, 35), path))
+
+	tile, format, err := DecodeFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, "png", format)
+	assert.Equal(t, 20, tile.Bounds().Dx())
+	assert.Equal(t, 20, tile.Bounds().Dy())
+
+	r, _, _, _ := tile.At(tile.Bounds().Min.X+5, tile.Bounds().Min.Y+5).RGBA()
+	assert.Equal(t, uint32(0xffff), r)
+}
@@ -0,0 +1,35 @@
+package logger
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+// fieldsKey is the context key holding the fields added to log entries
+type fieldsKey struct{}
+
+// NewContext returns a copy of ctx whose log entries carry fields, in
+// addition to the fields ctx already carries
+func NewContext(ctx context.Context, fields logrus.Fields) context.Context {
+	merged := make(logrus.Fields, len(Fields(ctx))+len(fields))
+	for key, value := range Fields(ctx) {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return context.WithValue(ctx, fieldsKey{}, merged)
+}
+
+// Fields returns the log fields carried by ctx. The map must not be modified.
+func Fields(ctx context.Context) logrus.Fields {
+	fields, _ := ctx.Value(fieldsKey{}).(logrus.Fields)
+	return fields
+}
+
+// FromContext returns an entry carrying the log fields of ctx, such as the ID
+// of the request being handled
+func (l *Logger) FromContext(ctx context.Context) *logrus.Entry {
+	return l.Logger.WithFields(Fields(ctx))
+}
@@ -0,0 +1,31 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext(t *testing.T) {
+	var out bytes.Buffer
+	log := New("info")
+	log.SetOutput(&out)
+
+	ctx := NewContext(context.Background(), logrus.Fields{"request_id": "req-1"})
+	ctx = NewContext(ctx, logrus.Fields{"analysis_id": "a1"})
+	log.FromContext(ctx).Info("Analysis queued")
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+	assert.Equal(t, "req-1", line["request_id"])
+	assert.Equal(t, "a1", line["analysis_id"])
+	assert.Equal(t, "Analysis queued", line["msg"])
+
+	// A context without fields logs none
+	assert.Empty(t, Fields(context.Background()))
+}
@@ -0,0 +1,68 @@
+// Package metrics defines the Prometheus collectors that describe the health
+// of the analysis pipeline
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Metrics holds the collectors fed by the worker pool and the analysis service.
+// It implements prometheus.Collector so it can be registered as one.
+type Metrics struct {
+	// Submitted counts analyses accepted for processing
+	Submitted prometheus.Counter
+	// Jobs counts analyses that reached a terminal status, by status
+	Jobs *prometheus.CounterVec
+	// Processing is the number of analyses currently being run by Fiji
+	Processing prometheus.Gauge
+	// QueueDepth is the number of analyses waiting for a worker
+	QueueDepth prometheus.Gauge
+	// Duration observes the Fiji time of completed analyses
+	Duration prometheus.Histogram
+}
+
+// New creates an unregistered set of collectors
+func New() *Metrics {
+	return &Metrics{
+		Submitted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "gypsum_analyses_submitted_total",
+			Help: "Analyses accepted for processing.",
+		}),
+		Jobs: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "gypsum_analysis_jobs_total",
+			Help: "Analyses that reached a terminal status, by status.",
+		}, []string{"status"}),
+		Processing: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "gypsum_analyses_processing",
+			Help: "Analyses currently being run by Fiji.",
+		}),
+		QueueDepth: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "gypsum_analysis_queue_depth",
+			Help: "Submitted analyses waiting for a worker.",
+		}),
+		Duration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "gypsum_analysis_duration_seconds",
+			Help:    "Time Fiji spent on completed analyses.",
+			Buckets: []float64{1, 2.5, 5, 10, 20, 30, 60, 120, 300},
+		}),
+	}
+}
+
+// collectors lists every collector of the set
+func (m *Metrics) collectors() []prometheus.Collector {
+	return []prometheus.Collector{m.Submitted, m.Jobs, m.Processing, m.QueueDepth, m.Duration}
+}
+
+// Describe implements prometheus.Collector
+func (m *Metrics) Describe(ch chan<- *prometheus.Desc) {
+	for _, collector := range m.collectors() {
+		collector.Describe(ch)
+	}
+}
+
+// Collect implements prometheus.Collector
+func (m *Metrics) Collect(ch chan<- prometheus.Metric) {
+	for _, collector := range m.collectors() {
+		collector.Collect(ch)
+	}
+}
@@ -0,0 +1,34 @@
+package metrics
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_Registered(t *testing.T) {
+	m := New()
+	registry := prometheus.NewRegistry()
+	require.NoError(t, registry.Register(m))
+
+	m.Jobs.WithLabelValues("completed").Add(3)
+	m.Jobs.WithLabelValues("failed").Inc()
+	m.QueueDepth.Set(2)
+
+	expected := `
+# HELP gypsum_analysis_jobs_total Analyses that reached a terminal status, by status.
+# TYPE gypsum_analysis_jobs_total counter
+gypsum_analysis_jobs_total{status="completed"} 3
+gypsum_analysis_jobs_total{status="failed"} 1
+# HELP gypsum_analysis_queue_depth Submitted analyses waiting for a worker.
+# TYPE gypsum_analysis_queue_depth gauge
+gypsum_analysis_queue_depth 2
+`
+	assert.NoError(t, testutil.GatherAndCompare(registry, strings.NewReader(expected),
+		"gypsum_analysis_jobs_total", "gypsum_analysis_queue_depth"))
+	assert.Equal(t, 1, testutil.CollectAndCount(m, "gypsum_analysis_duration_seconds"))
+}
@@ -0,0 +1,126 @@
+package middleware
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// claimsKey is the Gin context key holding the claims of an authenticated request
+const claimsKey = "jwt_claims"
+
+// ErrNoClaims is returned by ExtractClaims when the request was not authenticated
+var ErrNoClaims = errors.New("request has no authenticated claims")
+
+// Claims are the JWT claims accepted by the API
+type Claims struct {
+	jwt.RegisteredClaims
+}
+
+// ErrTokenLifetime is returned for tokens issued for longer than the accepted maximum
+var ErrTokenLifetime = errors.New("token lifetime exceeds the allowed maximum")
+
+// ErrNoIssuedAt is returned for tokens without an iat claim when their
+// lifetime is limited, as it could not be measured
+var ErrNoIssuedAt = errors.New("token has no issued-at time")
+
+// TokenParser validates a raw bearer token and returns its claims
+type TokenParser func(raw string) (*Claims, error)
+
+// NewTokenParser returns a parser accepting tokens signed with secret using
+// HS256. Tokens must carry an expiry; when issuer is set it must match, and
+// when maxLifetime is positive tokens must carry an issue time, or are
+// rejected with ErrNoIssuedAt, and tokens issued for longer are rejected with
+// ErrTokenLifetime.
+func NewTokenParser(secret, issuer string, maxLifetime time.Duration) TokenParser {
+	options := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithExpirationRequired(),
+	}
+	if issuer != "" {
+		options = append(options, jwt.WithIssuer(issuer))
+	}
+	parser := jwt.NewParser(options...)
+	key := func(*jwt.Token) (any, error) { return []byte(secret), nil }
+
+	return func(raw string) (*Claims, error) {
+		claims := &Claims{}
+		if _, err := parser.ParseWithClaims(raw, claims, key); err != nil {
+			return nil, err
+		}
+		if maxLifetime > 0 {
+			if claims.IssuedAt == nil {
+				return nil, ErrNoIssuedAt
+			}
+			if claims.ExpiresAt.Sub(claims.IssuedAt.Time) > maxLifetime {
+				return nil, ErrTokenLifetime
+			}
+		}
+		return claims, nil
+	}
+}
+
+// JWTAuth requires a bearer token accepted by NewTokenParser and stores its
+// claims in the context. With an empty secret every request is rejected.
+func JWTAuth(secret, issuer string, maxLifetime time.Duration) gin.HandlerFunc {
+	if secret == "" {
+		return func(c *gin.Context) {
+			unauthorized(c, "Authentication is not configured")
+		}
+	}
+
+	parse := NewTokenParser(secret, issuer, maxLifetime)
+
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		raw, found := strings.CutPrefix(header, "Bearer ")
+		if !found || raw == "" {
+			unauthorized(c, "Missing bearer token")
+			return
+		}
+
+		claims, err := parse(raw)
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			unauthorized(c, "Token has expired")
+			return
+		case errors.Is(err, ErrTokenLifetime):
+			unauthorized(c, "Token lifetime exceeds the allowed maximum")
+			return
+		case errors.Is(err, ErrNoIssuedAt):
+			unauthorized(c, "Token has no iat claim")
+			return
+		case err != nil:
+			unauthorized(c, "Invalid token")
+			return
+		}
+
+		c.Set(claimsKey, claims)
+		c.Next()
+	}
+}
+
+// ExtractClaims returns the claims JWTAuth stored for the request
+func ExtractClaims(c *gin.Context) (*Claims, error) {
+	value, ok := c.Get(claimsKey)
+	if !ok {
+		return nil, ErrNoClaims
+	}
+	claims, ok := value.(*Claims)
+	if !ok {
+		return nil, ErrNoClaims
+	}
+	return claims, nil
+}
+
+// unauthorized aborts the request with 401 and a bearer challenge
+func unauthorized(c *gin.Context, message string) {
+	c.Header("WWW-Authenticate", `Bearer realm="gypsum-analysis-api"`)
+	c.AbortWithStatusJSON(http.StatusUnauthorized, NewAPIError(c, models.ErrCodeUnauthorized, message))
+}
@@ -0,0 +1,132 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSecret = "test-secret"
+
+// signToken signs claims with HS256 using testSecret
+func signToken(t *testing.T, claims jwt.RegisteredClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(testSecret))
+	require.NoError(t, err)
+	return token
+}
+
+// authRouter serves /whoami behind JWTAuth, echoing the token subject
+func authRouter(issuer string, maxLifetime time.Duration) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/whoami", JWTAuth(testSecret, issuer, maxLifetime), func(c *gin.Context) {
+		claims, err := ExtractClaims(c)
+		if err != nil {
+			c.Status(http.StatusInternalServerError)
+			return
+		}
+		c.String(http.StatusOK, claims.Subject)
+	})
+	return router
+}
+
+func TestJWTAuth(t *testing.T) {
+	now := time.Now()
+	valid := jwt.RegisteredClaims{
+		Subject:   "lab-user",
+		Issuer:    "gypsum",
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(time.Hour)),
+	}
+
+	expired := valid
+	expired.IssuedAt = jwt.NewNumericDate(now.Add(-2 * time.Hour))
+	expired.ExpiresAt = jwt.NewNumericDate(now.Add(-time.Hour))
+
+	wrongIssuer := valid
+	wrongIssuer.Issuer = "someone-else"
+
+	noExpiry := valid
+	noExpiry.ExpiresAt = nil
+
+	longLived := valid
+	longLived.ExpiresAt = jwt.NewNumericDate(now.Add(48 * time.Hour))
+
+	noIssuedAt := valid
+	noIssuedAt.IssuedAt = nil
+
+	// alg:none tokens carry no signature at all
+	unsigned, err := jwt.NewWithClaims(jwt.SigningMethodNone, valid).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	require.NoError(t, err)
+
+	wrongKey, err := jwt.NewWithClaims(jwt.SigningMethodHS256, valid).SignedString([]byte("other-secret"))
+	require.NoError(t, err)
+
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{"valid", "Bearer " + signToken(t, valid), http.StatusOK},
+		{"missing header", "", http.StatusUnauthorized},
+		{"not bearer", "Basic dXNlcjpwYXNz", http.StatusUnauthorized},
+		{"expired", "Bearer " + signToken(t, expired), http.StatusUnauthorized},
+		{"alg none", "Bearer " + unsigned, http.StatusUnauthorized},
+		{"wrong key", "Bearer " + wrongKey, http.StatusUnauthorized},
+		{"wrong issuer", "Bearer " + signToken(t, wrongIssuer), http.StatusUnauthorized},
+		{"no expiry", "Bearer " + signToken(t, noExpiry), http.StatusUnauthorized},
+		{"lifetime too long", "Bearer " + signToken(t, longLived), http.StatusUnauthorized},
+		{"lifetime unknown", "Bearer " + signToken(t, noIssuedAt), http.StatusUnauthorized},
+	}
+
+	router := authRouter("gypsum", 24*time.Hour)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/whoami", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status == http.StatusOK {
+				assert.Equal(t, "lab-user", w.Body.String())
+			} else {
+				assert.NotEmpty(t, w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+}
+
+func TestJWTAuth_FailsClosedWithoutSecret(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.GET("/closed", JWTAuth("", "", 0), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/closed", nil)
+	req.Header.Set("Authorization", "Bearer "+signToken(t, jwt.RegisteredClaims{ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}))
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestNewTokenParser_UnlimitedLifetime(t *testing.T) {
+	// Without a maximum lifetime the iat claim is optional
+	parse := NewTokenParser(testSecret, "", 0)
+	claims, err := parse(signToken(t, jwt.RegisteredClaims{Subject: "lab-user", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour))}))
+	require.NoError(t, err)
+	assert.Equal(t, "lab-user", claims.Subject)
+}
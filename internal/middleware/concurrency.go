@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ConcurrencyLimit allows at most max requests through the wrapped routes at once.
+// Requests beyond the limit are rejected with 503 and a Retry-After hint instead
+// of queueing, so expensive endpoints cannot pile up work on the server.
+func ConcurrencyLimit(max int, retryAfter time.Duration) gin.HandlerFunc {
+	if max <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	slots := make(chan struct{}, max)
+	retrySeconds := strconv.Itoa(int(retryAfter.Seconds()))
+
+	return func(c *gin.Context) {
+		select {
+		case slots <- struct{}{}:
+			defer func() { <-slots }()
+			c.Next()
+		default:
+			c.Header("Retry-After", retrySeconds)
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, NewAPIError(c, models.ErrCodeServiceUnavailable, "Server is busy generating other exports, please retry later"))
+		}
+	}
+}
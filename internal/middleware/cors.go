@@ -0,0 +1,40 @@
+package middleware
+
+import (
+	"github.com/gin-contrib/cors"
+	"github.com/gin-gonic/gin"
+)
+
+// corsAllowedHeaders are the request headers browsers may send cross-origin
+var corsAllowedHeaders = []string{"Origin", "Content-Type", "Content-Length", "Accept-Encoding", "X-CSRF-Token", "Authorization",
+	"Idempotency-Key", "X-Wait", "X-Request-Timeout", RequestIDHeader}
+
+// corsExposedHeaders are the response headers scripts may read cross-origin,
+// besides the CORS-safelisted ones
+var corsExposedHeaders = []string{"Retry-After", RequestIDHeader, "Location", "Content-Disposition", "Idempotent-Replayed"}
+
+// CORS applies the cross-origin policy for browser clients. An origin of "*"
+// allows every origin, unless credentials are allowed: browsers reject a
+// wildcard origin on credentialed responses, so the request origin is then
+// reflected only when it is listed. Requests from other origins are
+// rejected with 403 and preflight requests are answered with 204.
+func CORS(allowedOrigins, allowedMethods []string, allowCredentials bool) gin.HandlerFunc {
+	config := cors.Config{
+		AllowMethods:     allowedMethods,
+		AllowHeaders:     corsAllowedHeaders,
+		ExposeHeaders:    corsExposedHeaders,
+		AllowCredentials: allowCredentials,
+	}
+	for _, origin := range allowedOrigins {
+		if origin == "*" {
+			config.AllowAllOrigins = !allowCredentials
+			continue
+		}
+		config.AllowOrigins = append(config.AllowOrigins, origin)
+	}
+	if config.AllowAllOrigins {
+		config.AllowOrigins = nil
+	}
+
+	return cors.New(config)
+}
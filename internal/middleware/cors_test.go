@@ -0,0 +1,87 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCORS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	methods := []string{"GET", "POST", "DELETE"}
+	tests := []struct {
+		name        string
+		origins     []string
+		credentials bool
+		origin      string
+		status      int
+		allowOrigin string
+	}{
+		{"wildcard", []string{"*"}, false, "https://any.example.com", http.StatusNoContent, "*"},
+		{"listed origin", []string{"https://lab.example.com"}, false, "https://lab.example.com", http.StatusNoContent, "https://lab.example.com"},
+		{"unlisted origin", []string{"https://lab.example.com"}, false, "https://evil.example.com", http.StatusForbidden, ""},
+		{"credentials reflect listed origin", []string{"https://lab.example.com"}, true, "https://lab.example.com", http.StatusNoContent, "https://lab.example.com"},
+		{"credentials never allow wildcard", []string{"*", "https://lab.example.com"}, true, "https://evil.example.com", http.StatusForbidden, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := gin.New()
+			router.Use(CORS(tt.origins, methods, tt.credentials))
+			router.POST("/api/v1/analysis/gypsum", func(c *gin.Context) {
+				c.Status(http.StatusAccepted)
+			})
+
+			req := httptest.NewRequest("OPTIONS", "/api/v1/analysis/gypsum", nil)
+			req.Header.Set("Origin", tt.origin)
+			req.Header.Set("Access-Control-Request-Method", "POST")
+			req.Header.Set("Access-Control-Request-Headers", "Authorization")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.status, w.Code)
+			assert.Equal(t, tt.allowOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+			if tt.status == http.StatusNoContent {
+				assert.Equal(t, "GET,POST,DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+				assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Authorization")
+			}
+			if tt.credentials && tt.status == http.StatusNoContent {
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+}
+
+func TestCORS_APIHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(CORS([]string{"*"}, []string{"POST"}, false))
+	router.POST("/api/v1/analysis/gypsum", func(c *gin.Context) {
+		c.Header("Retry-After", "1")
+		c.Status(http.StatusAccepted)
+	})
+
+	preflight := httptest.NewRequest("OPTIONS", "/api/v1/analysis/gypsum", nil)
+	preflight.Header.Set("Origin", "https://lab.example.com")
+	preflight.Header.Set("Access-Control-Request-Method", "POST")
+	preflight.Header.Set("Access-Control-Request-Headers", "Idempotency-Key,X-Wait,X-Request-ID")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, preflight)
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	for _, header := range []string{"Idempotency-Key", "X-Wait", "X-Request-Id"} {
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), header)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/analysis/gypsum", nil)
+	req.Header.Set("Origin", "https://lab.example.com")
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	for _, header := range []string{"Retry-After", "X-Request-Id", "Location"} {
+		assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), header)
+	}
+}
@@ -0,0 +1,16 @@
+package middleware
+
+import (
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// NewAPIError builds an error body for the request, tagged with its ID
+func NewAPIError(c *gin.Context, code, message string) models.APIError {
+	return models.APIError{
+		Code:      code,
+		Message:   message,
+		RequestID: GetRequestID(c),
+	}
+}
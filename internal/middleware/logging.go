@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"gypsum-analysis-api/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+)
+
+// StructuredLogger logs one entry per request once it has been handled, with
+// its ID, method, path, status, latency and body sizes. Server errors are
+// logged at error level and client errors at warn level, everything else at
+// info. body_bytes_in is the declared Content-Length, -1 when the client did
+// not declare one. The request ID is only known when RequestID runs after
+// this middleware.
+func StructuredLogger(log *logger.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		// Handlers may rewrite the path, so it is read up front
+		path := c.Request.URL.Path
+
+		c.Next()
+
+		fields := logrus.Fields{
+			"request_id":     GetRequestID(c),
+			"method":         c.Request.Method,
+			"path":           path,
+			"status":         c.Writer.Status(),
+			"latency_ms":     float64(time.Since(start).Microseconds()) / 1000,
+			"body_bytes_in":  c.Request.ContentLength,
+			"body_bytes_out": max(c.Writer.Size(), 0),
+			"client_ip":      c.ClientIP(),
+			"user_agent":     c.Request.UserAgent(),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypePrivate); len(errs) > 0 {
+			fields["error"] = errs.String()
+		}
+
+		entry := log.WithFields(fields)
+		switch status := c.Writer.Status(); {
+		case status >= http.StatusInternalServerError:
+			entry.Error("Request failed")
+		case status >= http.StatusBadRequest:
+			entry.Warn("Request rejected")
+		default:
+			entry.Info("Request handled")
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"gypsum-analysis-api/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/sirupsen/logrus"
+	"github.com/sirupsen/logrus/hooks/test"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStructuredLogger(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	base, hook := test.NewNullLogger()
+	router := gin.New()
+	router.Use(StructuredLogger(&logger.Logger{Logger: base}))
+	router.Use(RequestID())
+	router.POST("/echo", func(c *gin.Context) {
+		c.String(http.StatusOK, "hello")
+	})
+	router.GET("/missing", func(c *gin.Context) {
+		c.Status(http.StatusNotFound)
+	})
+	router.GET("/broken", func(c *gin.Context) {
+		c.Status(http.StatusInternalServerError)
+	})
+
+	tests := []struct {
+		method, path string
+		body         string
+		status       int
+		level        logrus.Level
+		bytesOut     int
+	}{
+		{"POST", "/echo", "ping", http.StatusOK, logrus.InfoLevel, 5},
+		{"GET", "/missing", "", http.StatusNotFound, logrus.WarnLevel, 0},
+		{"GET", "/broken", "", http.StatusInternalServerError, logrus.ErrorLevel, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			hook.Reset()
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			req.Header.Set("User-Agent", "gypsum-test")
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			require.Len(t, hook.AllEntries(), 1, "one entry per request")
+			entry := hook.LastEntry()
+			assert.Equal(t, tt.level, entry.Level)
+			assert.Equal(t, w.Header().Get(RequestIDHeader), entry.Data["request_id"])
+			assert.Equal(t, tt.method, entry.Data["method"])
+			assert.Equal(t, tt.path, entry.Data["path"])
+			assert.Equal(t, tt.status, entry.Data["status"])
+			assert.Equal(t, int64(len(tt.body)), entry.Data["body_bytes_in"])
+			assert.Equal(t, tt.bytesOut, entry.Data["body_bytes_out"])
+			assert.Equal(t, "192.0.2.1", entry.Data["client_ip"])
+			assert.Equal(t, "gypsum-test", entry.Data["user_agent"])
+			assert.GreaterOrEqual(t, entry.Data["latency_ms"], 0.0)
+		})
+	}
+}
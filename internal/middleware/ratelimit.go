@@ -0,0 +1,154 @@
+package middleware
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/time/rate"
+)
+
+// clientLimiter is the token bucket of one client and when it was last used
+type clientLimiter struct {
+	limiter  *rate.Limiter
+	lastSeen atomic.Int64 // unix nanoseconds
+}
+
+// RateLimiter gives every client its own token bucket refilling at rps tokens
+// per second up to burst. Authenticated clients are told apart by their token
+// subject, anonymous ones by IP. Buckets idle for longer than idleTTL are
+// evicted in the background.
+type RateLimiter struct {
+	rps     rate.Limit
+	burst   int
+	idleTTL time.Duration
+	clients sync.Map // client key -> *clientLimiter
+	stop    chan struct{}
+	once    sync.Once
+}
+
+// NewRateLimiter creates a rate limiter and starts evicting idle clients. A
+// non-positive rps disables limiting.
+func NewRateLimiter(rps float64, burst int, idleTTL time.Duration) *RateLimiter {
+	if idleTTL <= 0 {
+		idleTTL = time.Hour
+	}
+
+	l := &RateLimiter{
+		rps:     rate.Limit(rps),
+		burst:   burst,
+		idleTTL: idleTTL,
+		stop:    make(chan struct{}),
+	}
+	if rps > 0 {
+		go l.evictIdle()
+	}
+	return l
+}
+
+// Middleware rejects requests beyond the client's rate with 429 and a
+// Retry-After header stating when the next request would be allowed
+func (l *RateLimiter) Middleware() gin.HandlerFunc {
+	if l.rps <= 0 {
+		return func(c *gin.Context) {
+			c.Next()
+		}
+	}
+
+	return func(c *gin.Context) {
+		retryAfter, ok := l.Reserve(ClientKey(c))
+		switch {
+		case ok:
+			c.Next()
+		case retryAfter > 0:
+			c.Header("Retry-After", strconv.Itoa(RetryAfterSeconds(retryAfter)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, NewAPIError(c, models.ErrCodeRateLimited, "Rate limit exceeded, please retry later"))
+		default:
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, NewAPIError(c, models.ErrCodeRateLimited, "Rate limit exceeded"))
+		}
+	}
+}
+
+// Reserve takes a token from the bucket of the client identified by key. When
+// none is left it reports false and how long until one would be, or a zero
+// duration if the request can never be allowed. Disabled limiters allow
+// everything.
+func (l *RateLimiter) Reserve(key string) (time.Duration, bool) {
+	if l.rps <= 0 {
+		return 0, true
+	}
+
+	reservation := l.client(key).limiter.Reserve()
+	if !reservation.OK() {
+		return 0, false
+	}
+	if delay := reservation.Delay(); delay > 0 {
+		// Give the token back; this request is rejected rather than delayed
+		reservation.Cancel()
+		return delay, false
+	}
+	return 0, true
+}
+
+// RetryAfterSeconds rounds a retry delay up to whole seconds for Retry-After
+func RetryAfterSeconds(delay time.Duration) int {
+	return int(math.Ceil(delay.Seconds()))
+}
+
+// Stop ends background eviction
+func (l *RateLimiter) Stop() {
+	l.once.Do(func() { close(l.stop) })
+}
+
+// ClientKey identifies the client a request comes from: the token subject of
+// a request authenticated by JWTAuth, otherwise the client IP
+func ClientKey(c *gin.Context) string {
+	if claims, err := ExtractClaims(c); err == nil && claims.Subject != "" {
+		return "sub:" + claims.Subject
+	}
+	return "ip:" + c.ClientIP()
+}
+
+// client returns the bucket of key, creating it on first use
+func (l *RateLimiter) client(key string) *clientLimiter {
+	value, ok := l.clients.Load(key)
+	if !ok {
+		value, _ = l.clients.LoadOrStore(key, &clientLimiter{limiter: rate.NewLimiter(l.rps, l.burst)})
+	}
+
+	client := value.(*clientLimiter)
+	client.lastSeen.Store(time.Now().UnixNano())
+	return client
+}
+
+// evictIdle periodically drops clients that have not been seen for idleTTL
+func (l *RateLimiter) evictIdle() {
+	ticker := time.NewTicker(l.idleTTL / 2)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-l.stop:
+			return
+		case now := <-ticker.C:
+			l.evict(now)
+		}
+	}
+}
+
+// evict drops clients last seen before now minus idleTTL
+func (l *RateLimiter) evict(now time.Time) {
+	cutoff := now.Add(-l.idleTTL).UnixNano()
+	l.clients.Range(func(key, value any) bool {
+		if value.(*clientLimiter).lastSeen.Load() < cutoff {
+			l.clients.Delete(key)
+		}
+		return true
+	})
+}
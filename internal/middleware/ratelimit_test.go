@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+)
+
+// limitedRouter serves /upload behind limiter
+func limitedRouter(limiter *RateLimiter) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.POST("/upload", limiter.Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+	return router
+}
+
+// postFrom sends an upload request from remoteAddr
+func postFrom(router *gin.Engine, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/upload", nil)
+	req.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimiter_PerClientBuckets(t *testing.T) {
+	limiter := NewRateLimiter(0.5, 2, time.Hour)
+	defer limiter.Stop()
+	router := limitedRouter(limiter)
+
+	// The burst is spent, then the client must wait for the next token
+	assert.Equal(t, http.StatusAccepted, postFrom(router, "10.0.0.1:1234").Code)
+	assert.Equal(t, http.StatusAccepted, postFrom(router, "10.0.0.1:1234").Code)
+	w := postFrom(router, "10.0.0.1:1234")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), `"code":"rate_limited"`)
+
+	// Other clients have their own bucket
+	assert.Equal(t, http.StatusAccepted, postFrom(router, "10.0.0.2:1234").Code)
+}
+
+func TestRateLimiter_IgnoresUntrustedForwardedFor(t *testing.T) {
+	limiter := NewRateLimiter(0.5, 1, time.Hour)
+	defer limiter.Stop()
+	router := limitedRouter(limiter)
+	assert.NoError(t, router.SetTrustedProxies(nil))
+
+	post := func(forwardedFor string) int {
+		req := httptest.NewRequest("POST", "/upload", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A forged address per request does not get a fresh bucket
+	assert.Equal(t, http.StatusAccepted, post("203.0.113.1"))
+	assert.Equal(t, http.StatusTooManyRequests, post("203.0.113.2"))
+}
+
+func TestRateLimiter_Disabled(t *testing.T) {
+	limiter := NewRateLimiter(0, 0, time.Hour)
+	defer limiter.Stop()
+	router := limitedRouter(limiter)
+
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, http.StatusAccepted, postFrom(router, "10.0.0.1:1234").Code)
+	}
+}
+
+func TestRateLimiter_EvictsIdleClients(t *testing.T) {
+	limiter := NewRateLimiter(1, 1, time.Hour)
+	defer limiter.Stop()
+
+	limiter.client("ip:10.0.0.1")
+	limiter.evict(time.Now())
+	_, ok := limiter.clients.Load("ip:10.0.0.1")
+	assert.True(t, ok, "recently seen clients are kept")
+
+	limiter.evict(time.Now().Add(2 * time.Hour))
+	_, ok = limiter.clients.Load("ip:10.0.0.1")
+	assert.False(t, ok)
+}
+
+func TestRateLimiter_KeyedByTokenSubject(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limiter := NewRateLimiter(0.5, 1, time.Hour)
+	defer limiter.Stop()
+
+	// Stand in for JWTAuth, authenticating requests that name a subject
+	router := gin.New()
+	router.POST("/upload", func(c *gin.Context) {
+		if subject := c.GetHeader("X-Subject"); subject != "" {
+			c.Set(claimsKey, &Claims{RegisteredClaims: jwt.RegisteredClaims{Subject: subject}})
+		}
+	}, limiter.Middleware(), func(c *gin.Context) {
+		c.Status(http.StatusAccepted)
+	})
+	post := func(remoteAddr, subject string) int {
+		req := httptest.NewRequest("POST", "/upload", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Subject", subject)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// A client keeps its budget across addresses, and clients sharing an
+	// address keep separate budgets
+	assert.Equal(t, http.StatusAccepted, post("10.0.0.1:1234", "lab-a"))
+	assert.Equal(t, http.StatusTooManyRequests, post("10.0.0.2:1234", "lab-a"))
+	assert.Equal(t, http.StatusAccepted, post("10.0.0.1:1234", "lab-b"))
+
+	// Anonymous requests fall back to the client IP
+	assert.Equal(t, http.StatusAccepted, post("10.0.0.1:1234", ""))
+	assert.Equal(t, http.StatusTooManyRequests, post("10.0.0.1:1234", ""))
+}
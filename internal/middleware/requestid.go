@@ -0,0 +1,39 @@
+package middleware
+
+import (
+	"gypsum-analysis-api/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+)
+
+// RequestIDHeader carries the ID of a request in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the Gin context key holding the request ID
+const requestIDKey = "request_id"
+
+// RequestID assigns every request an ID, stored in the context and echoed in
+// the X-Request-ID response header. A UUID sent by the client is kept so
+// requests can be correlated across services; anything else is replaced.
+// The request's context carries the ID as the request_id log field.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if _, err := uuid.Parse(id); err != nil {
+			id = uuid.New().String()
+		}
+
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Request = c.Request.WithContext(logger.NewContext(c.Request.Context(), logrus.Fields{"request_id": id}))
+		c.Next()
+	}
+}
+
+// GetRequestID returns the ID RequestID assigned to the request, or an empty
+// string when the middleware did not run
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
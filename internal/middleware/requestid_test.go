@@ -0,0 +1,52 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"gypsum-analysis-api/internal/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestID())
+	router.GET("/", func(c *gin.Context) {
+		// Log entries of the request carry its ID
+		assert.Equal(t, GetRequestID(c), logger.Fields(c.Request.Context())["request_id"])
+		c.String(http.StatusOK, GetRequestID(c))
+	})
+	get := func(header string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		if header != "" {
+			req.Header.Set(RequestIDHeader, header)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	// A new ID is generated and echoed
+	w := get("")
+	_, err := uuid.Parse(w.Body.String())
+	assert.NoError(t, err)
+	assert.Equal(t, w.Body.String(), w.Header().Get(RequestIDHeader))
+
+	// A caller's UUID is kept
+	id := uuid.New().String()
+	w = get(id)
+	assert.Equal(t, id, w.Body.String())
+	assert.Equal(t, id, w.Header().Get(RequestIDHeader))
+
+	// Anything else is replaced
+	w = get("not-a-uuid")
+	assert.NotEqual(t, "not-a-uuid", w.Body.String())
+	_, err = uuid.Parse(w.Header().Get(RequestIDHeader))
+	assert.NoError(t, err)
+}
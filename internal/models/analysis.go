@@ -8,10 +8,30 @@ import (
 type AnalysisStatus string
 
 const (
-	StatusPending   AnalysisStatus = "pending"
+	StatusPending    AnalysisStatus = "pending"
 	StatusProcessing AnalysisStatus = "processing"
 	StatusCompleted  AnalysisStatus = "completed"
 	StatusFailed     AnalysisStatus = "failed"
+	StatusCancelled  AnalysisStatus = "cancelled"
+)
+
+// FailureCategory classifies why an analysis failed
+type FailureCategory string
+
+const (
+	FailureFijiExec  FailureCategory = "fiji_exec" // Fiji could not be started, crashed or stalled
+	FailureParse     FailureCategory = "parse"     // Fiji ran but its output could not be interpreted
+	FailureTimeout   FailureCategory = "timeout"   // Fiji exceeded the analysis timeout
+	FailureBadInput  FailureCategory = "bad_input" // the upload is not a usable image
+	FailureStorage   FailureCategory = "storage"   // files could not be written to the temp directory
+	FailureCancelled FailureCategory = "cancelled" // the analysis was cancelled before finishing
+)
+
+// Engines that run analyses: Fiji, or the pure-Go analyzer used when Fiji is
+// not installed
+const (
+	EngineFiji     = "fiji"
+	EngineGoNative = "go-native"
 )
 
 // AnalysisResult represents the result of a gypsum analysis
@@ -21,25 +41,391 @@ type AnalysisResult struct {
 	CreatedAt   time.Time      `json:"created_at"`
 	CompletedAt *time.Time     `json:"completed_at,omitempty"`
 	Error       string         `json:"error,omitempty"`
-	
-	// Analysis results
+
+	// FailureCategory is set together with Error when Status is failed or cancelled
+	FailureCategory FailureCategory `json:"failure_category,omitempty"`
+
+	// Progress is the completion percentage reported by Fiji while processing
+	Progress int `json:"progress"`
+
+	// RetryCount is the number of times the analysis was re-enqueued after
+	// Fiji crashed or stalled
+	RetryCount int `json:"retry_count,omitempty"`
+
+	// Analysis results. Estimated is set when Fiji did not report some of
+	// them and values estimated from the image were substituted.
 	PurityPercentage float64 `json:"purity_percentage,omitempty"`
 	Confidence       float64 `json:"confidence,omitempty"`
-	
-	// Image analysis details
-	ImagePath     string `json:"image_path,omitempty"`
-	ImageSize     int64  `json:"image_size,omitempty"`
-	AnalysisTime  int64  `json:"analysis_time_ms,omitempty"`
-	
+	Estimated        bool    `json:"estimated,omitempty"`
+
+	// ConfidenceFactors lists the signals Confidence was computed from. When
+	// Estimated is set, Confidence is lowered regardless of them.
+	ConfidenceFactors *ConfidenceFactors `json:"confidence_factors,omitempty"`
+
+	// Image analysis details. The upload is stored under ImageKey in the blob
+	// store; ImagePath is its location there, a file path or s3:// URL.
+	ImagePath    string `json:"image_path,omitempty"`
+	ImageKey     string `json:"image_key,omitempty"`
+	ImageSize    int64  `json:"image_size,omitempty"`
+	AnalysisTime int64  `json:"analysis_time_ms,omitempty"`
+
+	// ImageRemoved is set once the image at ImagePath has been cleaned up
+	ImageRemoved bool `json:"image_removed,omitempty"`
+
+	// OverlayPath is the location of the PNG outlining the counted particles,
+	// served by GET /api/v1/analysis/:id/overlay
+	OverlayPath string `json:"overlay_path,omitempty"`
+
+	// Identity of the analyzed input, recorded for every analysis. Format and
+	// dimensions stay empty only when the upload could not be decoded.
+	ImageSHA256 string `json:"image_sha256"`
+	ImageFormat string `json:"image_format"`
+	ImageWidth  int    `json:"image_width"`
+	ImageHeight int    `json:"image_height"`
+
+	// ImageMetadata is the camera or microscope information embedded in the
+	// image's EXIF block, when it has one
+	ImageMetadata *EXIFData `json:"image_metadata,omitempty"`
+
+	// PerceptualHash is the hex difference hash of the image, used to spot
+	// resubmissions. DuplicateOf names the earlier analysis of a near-identical
+	// image whose results were reused instead of running Fiji again.
+	PerceptualHash string `json:"perceptual_hash,omitempty"`
+	DuplicateOf    string `json:"duplicate_of,omitempty"`
+
 	// Mineral composition details
-	GypsumContent    float64 `json:"gypsum_content_percentage,omitempty"`
-	ImpurityContent  float64 `json:"impurity_content_percentage,omitempty"`
-	CalciteContent   float64 `json:"calcite_content_percentage,omitempty"`
-	QuartzContent    float64 `json:"quartz_content_percentage,omitempty"`
-	OtherMinerals    float64 `json:"other_minerals_percentage,omitempty"`
-	
-	// Processing parameters
-	ThresholdValue   float64 `json:"threshold_value,omitempty"`
-	ParticleCount    int     `json:"particle_count,omitempty"`
-	AverageParticleSize float64 `json:"average_particle_size_um,omitempty"`
+	GypsumContent   float64 `json:"gypsum_content_percentage,omitempty"`
+	ImpurityContent float64 `json:"impurity_content_percentage,omitempty"`
+	CalciteContent  float64 `json:"calcite_content_percentage,omitempty"`
+	QuartzContent   float64 `json:"quartz_content_percentage,omitempty"`
+	OtherMinerals   float64 `json:"other_minerals_percentage,omitempty"`
+
+	// Processing parameters. ThresholdLower and ThresholdUpper are the gray
+	// levels the image was segmented between, inclusive; they are unset when
+	// they were not reported, as for results recorded before they were.
+	//
+	// Deprecated: ThresholdValue is the lower bound alone, kept for existing
+	// clients; use ThresholdLower and ThresholdUpper.
+	ThresholdValue      float64  `json:"threshold_value,omitempty"`
+	ThresholdLower      *float64 `json:"threshold_lower,omitempty"`
+	ThresholdUpper      *float64 `json:"threshold_upper,omitempty"`
+	ParticleCount       int      `json:"particle_count,omitempty"`
+	AverageParticleSize float64  `json:"average_particle_size_um,omitempty"`
+
+	// Measured areas and the spatial scale of the image, if known. Areas,
+	// particle sizes and spacings are in MeasurementUnit: "µm" (squared for
+	// areas) for calibrated images and "px" otherwise.
+	TotalArea       float64      `json:"total_area,omitempty"`
+	ImageArea       float64      `json:"image_area,omitempty"`
+	Calibration     *Calibration `json:"calibration,omitempty"`
+	MeasurementUnit string       `json:"measurement_unit,omitempty"`
+
+	// Per-region purity when the analysis was restricted to uploaded ImageJ ROIs
+	ROIFile    string      `json:"roi_file,omitempty"`
+	ROIResults []ROIResult `json:"roi_results,omitempty"`
+
+	// Rectangular region the image was cropped to before analysis, if any
+	ROI *Rectangle `json:"roi,omitempty"`
+
+	// Per-tile results when a large image was analyzed as a grid of tiles
+	Tiles []TileResult `json:"tiles,omitempty"`
+
+	// Per-slice results when the image was a multi-page TIFF stack
+	PerSlice []SliceResult `json:"per_slice,omitempty"`
+
+	// Nearest-neighbor spacing between particle centroids
+	SpacingStats *SpacingStats `json:"spacing_stats,omitempty"`
+
+	// Name of the uploaded file and the batch it was submitted in, if any
+	Filename string `json:"filename,omitempty"`
+	BatchID  string `json:"batch_id,omitempty"`
+
+	// Submission context: profile, completion callback and caller-supplied metadata
+	Profile     string            `json:"profile,omitempty"`
+	SampleGroup string            `json:"sample_group,omitempty"`
+	CallbackURL string            `json:"callback_url,omitempty"`
+	Metadata    map[string]string `json:"metadata,omitempty"`
+
+	// Description of the physical sample, when supplied with the upload
+	SampleMetadata *SampleMetadata `json:"sample_metadata,omitempty"`
+
+	// TimeoutSeconds is how long Fiji was allowed to run for this analysis
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+
+	// Stored profile the macro parameters were taken from, if any
+	ProfileID string `json:"profile_id,omitempty"`
+
+	// Effective macro parameters and the exact macro that was executed
+	Parameters  *MacroParams `json:"parameters,omitempty"`
+	Macro       string       `json:"-"`
+	FijiVersion string       `json:"fiji_version,omitempty"`
+
+	// Owner is the client that submitted the analysis, as a token subject or
+	// client IP; only its webhooks are told about the result
+	Owner string `json:"-"`
+
+	// AnalysisEngine is the engine that ran the analysis, EngineFiji or EngineGoNative
+	AnalysisEngine string `json:"analysis_engine,omitempty"`
+
+	// Shadow analysis performed by the native analyzer for validation
+	ShadowPurityPercentage *float64 `json:"shadow_purity_percentage,omitempty"`
+
+	// Non-fatal issues detected while analyzing
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Reviewer annotations. PurityPercentage always keeps the automated value;
+	// a human override is reported separately in ManualOverridePurity.
+	ReviewNote           string     `json:"review_note,omitempty"`
+	ReviewedBy           string     `json:"reviewed_by,omitempty"`
+	ReviewedAt           *time.Time `json:"reviewed_at,omitempty"`
+	ManualOverride       bool       `json:"manual_override,omitempty"`
+	ManualOverridePurity *float64   `json:"manual_override_purity,omitempty"`
+
+	// Unit of each numeric field, only populated when requested with ?include_units=true
+	Units map[string]string `json:"units,omitempty"`
+
+	// HMAC-SHA256 over the measurements of a completed result, keyed with the
+	// signing key of SignatureKeyVersion, so later edits can be detected
+	ResultSignature     string     `json:"result_signature,omitempty"`
+	SignatureKeyVersion string     `json:"signature_key_version,omitempty"`
+	SignedAt            *time.Time `json:"signed_at,omitempty"`
+}
+
+// ResultVerification reports whether the measurements of a result still match
+// the signature recorded when it completed
+type ResultVerification struct {
+	Valid      bool       `json:"valid"`
+	SignedAt   *time.Time `json:"signed_at"`
+	KeyVersion string     `json:"key_version"`
+}
+
+// StatusEvent describes a status transition of an analysis. Result is only set
+// once the analysis reaches a terminal state.
+type StatusEvent struct {
+	AnalysisID string          `json:"analysis_id"`
+	Status     AnalysisStatus  `json:"status"`
+	Timestamp  time.Time       `json:"timestamp"`
+	Result     *AnalysisResult `json:"result,omitempty"`
+}
+
+// SpacingStats summarizes nearest-neighbor distances between particle centroids
+type SpacingStats struct {
+	ParticleCount int     `json:"particle_count"`
+	Mean          float64 `json:"mean"`
+	Median        float64 `json:"median"`
+	Min           float64 `json:"min"`
+	Max           float64 `json:"max"`
+	Unit          string  `json:"unit"`
+}
+
+// Particle count buckets of ConfidenceFactors
+const (
+	ParticleCountFew  = "few"  // 10 or fewer
+	ParticleCountSome = "some" // 11 to 50
+	ParticleCountMany = "many" // more than 50
+)
+
+// ConfidenceFactors breaks a confidence score down into its base and the
+// weights of the signals that were satisfied. The score is the base plus the
+// weights of the satisfied signals.
+type ConfidenceFactors struct {
+	Base                float64            `json:"base"`
+	ParticleCountBucket string             `json:"particle_count_bucket"`
+	Signals             []ConfidenceSignal `json:"signals"`
+}
+
+// ConfidenceSignal is one check that adds its weight to a confidence score
+// when satisfied
+type ConfidenceSignal struct {
+	Name      string  `json:"name"`
+	Weight    float64 `json:"weight"`
+	Satisfied bool    `json:"satisfied"`
+}
+
+// Rectangle is a region of an image in pixel coordinates
+type Rectangle struct {
+	X      int `json:"x"`
+	Y      int `json:"y"`
+	Width  int `json:"width"`
+	Height int `json:"height"`
+}
+
+// Calibration is the spatial scale supplied for an image. The scale bar it was
+// derived from, if any, is kept for reference. Source is "exif" when the
+// scale was read from the image's EXIF resolution rather than supplied.
+type Calibration struct {
+	PixelsPerMicron float64 `json:"pixels_per_micron"`
+	ScaleBarLength  float64 `json:"scale_bar_length_um,omitempty"`
+	ScaleBarPixels  float64 `json:"scale_bar_pixels,omitempty"`
+	Source          string  `json:"source,omitempty"`
+}
+
+// EXIFData is the camera or microscope information read from an image's EXIF
+// block. Resolutions are pixels per ResolutionUnit, "inch" or "cm".
+type EXIFData struct {
+	Make           string     `json:"make,omitempty"`
+	Model          string     `json:"model,omitempty"`
+	DateTime       *time.Time `json:"date_time,omitempty"`
+	XResolution    float64    `json:"x_resolution,omitempty"`
+	YResolution    float64    `json:"y_resolution,omitempty"`
+	ResolutionUnit string     `json:"resolution_unit,omitempty"`
+	UserComment    string     `json:"user_comment,omitempty"`
+}
+
+// Quantity is a single exported measurement with its unit
+type Quantity struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+	Unit  string  `json:"unit"`
+}
+
+// SIExport is an analysis result with spatial quantities converted to SI units
+type SIExport struct {
+	AnalysisID         string       `json:"analysis_id"`
+	SignificantFigures int          `json:"significant_figures"`
+	Calibration        *Calibration `json:"calibration"`
+	Quantities         []Quantity   `json:"quantities"`
+}
+
+// TrendPoint is the purity of one completed analysis on a trend chart
+type TrendPoint struct {
+	AnalysisID       string    `json:"analysis_id"`
+	Timestamp        time.Time `json:"timestamp"`
+	PurityPercentage float64   `json:"purity_percentage"`
+}
+
+// ComparisonResult is the change between two completed analyses, such as of
+// a sample before and after calcination. Deltas are B minus A.
+type ComparisonResult struct {
+	A                  *AnalysisResult `json:"a"`
+	B                  *AnalysisResult `json:"b"`
+	PurityDelta        float64         `json:"purity_delta"`
+	GypsumDelta        float64         `json:"gypsum_delta"`
+	ParticleCountDelta int             `json:"particle_count_delta"`
+	ConfidenceDelta    float64         `json:"confidence_delta"`
+	// Significant is set when the purity changed by more than 2 points
+	Significant bool `json:"significant"`
+}
+
+// SampleMetadata describes the physical sample an image was taken of: where
+// and how deep it was collected, and by whom
+type SampleMetadata struct {
+	SampleID       string  `json:"sample_id,omitempty"`
+	OperatorName   string  `json:"operator_name,omitempty"`
+	LocationLat    float64 `json:"location_lat,omitempty"`
+	LocationLon    float64 `json:"location_lon,omitempty"`
+	SamplingDepthM float64 `json:"sampling_depth_m,omitempty"`
+	Notes          string  `json:"notes,omitempty"`
+}
+
+// ROIResult is the purity measured inside a single ImageJ region of interest
+type ROIResult struct {
+	Index            int     `json:"index"`
+	Name             string  `json:"name,omitempty"`
+	PurityPercentage float64 `json:"purity_percentage"`
+}
+
+// SliceResult is the analysis of one slice of a TIFF stack. Slices are
+// numbered from 1, as in ImageJ.
+type SliceResult struct {
+	Index            int     `json:"index"`
+	PurityPercentage float64 `json:"purity_percentage"`
+	ParticleCount    int     `json:"particle_count"`
+}
+
+// TileResult is the analysis of one tile of a large image. The region is the
+// part of the image the tile owns, excluding the overlap shared with its neighbours.
+type TileResult struct {
+	Index            int     `json:"index"`
+	X                int     `json:"x"`
+	Y                int     `json:"y"`
+	Width            int     `json:"width"`
+	Height           int     `json:"height"`
+	ParticleCount    int     `json:"particle_count"`
+	TotalArea        float64 `json:"total_area"`
+	PurityPercentage float64 `json:"purity_percentage"`
+}
+
+// MacroParams holds the preprocessing, threshold and particle filter settings
+// interpolated into the ImageJ macro
+type MacroParams struct {
+	ThresholdMethod    string  `json:"threshold_method"`
+	MinParticleSize    float64 `json:"min_particle_size"`
+	MaxParticleSize    float64 `json:"max_particle_size,omitempty"` // 0 means unbounded
+	MinCircularity     float64 `json:"min_circularity"`
+	MaxCircularity     float64 `json:"max_circularity"`
+	GaussianSigma      float64 `json:"gaussian_sigma"`
+	ContrastSaturation float64 `json:"contrast_saturation"`
+}
+
+// AnalysisManifest is the self-describing archival record of an analysis,
+// bundling the result with everything needed to reproduce it
+type AnalysisManifest struct {
+	ManifestVersion string             `json:"manifest_version"`
+	GeneratedAt     time.Time          `json:"generated_at"`
+	Result          *AnalysisResult    `json:"result"`
+	Parameters      *MacroParams       `json:"parameters"`
+	Warnings        []string           `json:"warnings"`
+	Macro           string             `json:"macro"`
+	FijiVersion     string             `json:"fiji_version"`
+	Timestamps      ManifestTimestamps `json:"timestamps"`
+}
+
+// ManifestTimestamps collects the lifecycle timestamps of an analysis
+type ManifestTimestamps struct {
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ReviewedAt  *time.Time `json:"reviewed_at,omitempty"`
+}
+
+// Annotation is a reviewer's note and optional manual purity override
+type Annotation struct {
+	ReviewedBy     string   `json:"reviewed_by" binding:"required"`
+	Note           string   `json:"note"`
+	OverridePurity *float64 `json:"override_purity" binding:"omitempty,min=0,max=100"`
+}
+
+// UploadCompletion completes a chunked upload sent as chunks 0 to TotalChunks-1
+type UploadCompletion struct {
+	TotalChunks int `json:"total_chunks" binding:"required,min=1"`
+}
+
+// AnalysisRequest holds the optional settings of an analysis upload, sent as
+// form fields next to the image. Empty fields count as not given. The finite
+// rule is registered by the handlers package.
+type AnalysisRequest struct {
+	Profile     string `form:"profile"`
+	ProfileID   string `form:"profile_id"`
+	SampleGroup string `form:"sample_group"`
+	CallbackURL string `form:"callback_url"`
+
+	// Spatial calibration, as a scale or a scale bar
+	PixelsPerMicron *float64 `form:"pixels_per_micron" binding:"excluded_with=ScaleBarLength ScaleBarPixels,omitempty,finite,gt=0"`
+	ScaleBarLength  *float64 `form:"scale_bar_length" binding:"required_with=ScaleBarPixels,omitempty,finite,gt=0"`
+	ScaleBarPixels  *float64 `form:"scale_bar_pixels" binding:"required_with=ScaleBarLength,omitempty,finite,gt=0"`
+
+	// Description of the physical sample
+	SampleID       string   `form:"sample_id"`
+	OperatorName   string   `form:"operator_name"`
+	Notes          string   `form:"notes"`
+	LocationLat    *float64 `form:"location_lat" binding:"omitempty,min=-90,max=90"`
+	LocationLon    *float64 `form:"location_lon" binding:"omitempty,min=-180,max=180"`
+	SamplingDepthM *float64 `form:"sampling_depth_m" binding:"omitempty,finite,min=0"`
+
+	TimeoutSeconds *int `form:"timeout_seconds"`
+
+	// Macro overrides; blur_sigma is an alias of gaussian_sigma
+	ThresholdMethod    string   `form:"threshold_method"`
+	MinParticleSize    *float64 `form:"min_particle_size" binding:"omitempty,finite,min=0"`
+	MaxParticleSize    *float64 `form:"max_particle_size" binding:"omitempty,finite,min=0"`
+	MinCircularity     *float64 `form:"min_circularity" binding:"omitempty,min=0,max=1"`
+	BlurSigma          *float64 `form:"blur_sigma" binding:"omitempty,min=0,max=20"`
+	GaussianSigma      *float64 `form:"gaussian_sigma" binding:"omitempty,min=0,max=20"`
+	ContrastSaturation *float64 `form:"contrast_saturation" binding:"omitempty,min=0,lt=100"`
+
+	// Rectangle cropped before analysis; all four are given together
+	ROIX      *int `form:"roi_x" binding:"required_with=ROIY ROIWidth ROIHeight,omitempty,min=0"`
+	ROIY      *int `form:"roi_y" binding:"required_with=ROIX ROIWidth ROIHeight,omitempty,min=0"`
+	ROIWidth  *int `form:"roi_width" binding:"required_with=ROIX ROIY ROIHeight,omitempty,gt=0"`
+	ROIHeight *int `form:"roi_height" binding:"required_with=ROIX ROIY ROIWidth,omitempty,gt=0"`
 }
@@ -0,0 +1,81 @@
+package models
+
+import "time"
+
+// BatchResult is returned when a ZIP archive of images is submitted. Each
+// accepted image becomes its own analysis.
+type BatchResult struct {
+	BatchID     string      `json:"batch_id"`
+	AnalysisIDs []string    `json:"analysis_ids"`
+	Skipped     []BatchSkip `json:"skipped,omitempty"`
+}
+
+// BatchSkip is an archive entry that was not analyzed
+type BatchSkip struct {
+	Filename string `json:"filename"`
+	Reason   string `json:"reason"`
+}
+
+// BatchSummary aggregates the analyses of a batch. BatchStatus is processing
+// until every analysis is terminal, then completed. Purity statistics cover
+// completed analyses only and are omitted until at least one has completed.
+type BatchSummary struct {
+	BatchID              string         `json:"batch_id"`
+	BatchStatus          AnalysisStatus `json:"batch_status"`
+	Total                int            `json:"total"`
+	Pending              int            `json:"pending"`
+	Processing           int            `json:"processing"`
+	Completed            int            `json:"completed"`
+	Failed               int            `json:"failed"`
+	Cancelled            int            `json:"cancelled"`
+	CompletionPercentage float64        `json:"completion_percentage"`
+	MeanPurity           *float64       `json:"mean_purity,omitempty"`
+	PurityStdDev         *float64       `json:"purity_std_dev,omitempty"`
+	Analyses             []BatchItem    `json:"analyses"`
+}
+
+// PuritySummary reports purity statistics over the analyses of a batch or a
+// creation time range. Statistics cover completed analyses only, and Count
+// is how many there were; ProcessingCount counts pending analyses too.
+// Percentiles interpolate linearly between the nearest purities.
+type PuritySummary struct {
+	BatchID         string     `json:"batch_id,omitempty"`
+	From            *time.Time `json:"from,omitempty"`
+	To              *time.Time `json:"to,omitempty"`
+	Count           int        `json:"count"`
+	MeanPurity      float64    `json:"mean_purity"`
+	StdDevPurity    float64    `json:"std_dev_purity"`
+	MinPurity       float64    `json:"min_purity"`
+	MaxPurity       float64    `json:"max_purity"`
+	MedianPurity    float64    `json:"median_purity"`
+	P5Purity        float64    `json:"p5_purity"`
+	P95Purity       float64    `json:"p95_purity"`
+	MeanConfidence  float64    `json:"mean_confidence"`
+	FailedCount     int        `json:"failed_count"`
+	ProcessingCount int        `json:"processing_count"`
+}
+
+// BatchItem is the state of one analysis within a batch
+type BatchItem struct {
+	AnalysisID       string         `json:"analysis_id"`
+	Filename         string         `json:"filename"`
+	Status           AnalysisStatus `json:"status"`
+	PurityPercentage float64        `json:"purity_percentage,omitempty"`
+	Error            string         `json:"error,omitempty"`
+}
+
+// BatchUpload is returned when several images are uploaded in one request.
+// Files lists every upload in request order, rejected ones with their error.
+type BatchUpload struct {
+	BatchID string      `json:"batch_id"`
+	Files   []BatchFile `json:"files"`
+}
+
+// BatchFile is the outcome of one image of a batch upload. AnalysisID is
+// empty when the image was rejected before an analysis was created.
+type BatchFile struct {
+	Filename   string         `json:"filename"`
+	AnalysisID string         `json:"analysis_id,omitempty"`
+	Status     AnalysisStatus `json:"status"`
+	Error      string         `json:"error,omitempty"`
+}
@@ -0,0 +1,28 @@
+package models
+
+// Error codes identify the kind of failure independently of the message, so
+// clients can branch on them
+const (
+	ErrCodeInvalidInput       = "invalid_input"
+	ErrCodeNoFile             = "no_file"
+	ErrCodeUnsupportedType    = "unsupported_type"
+	ErrCodeTypeMismatch       = "type_mismatch"
+	ErrCodeUnauthorized       = "unauthorized"
+	ErrCodeNotFound           = "not_found"
+	ErrCodeConflict           = "conflict"
+	ErrCodePayloadTooLarge    = "payload_too_large"
+	ErrCodeUnsupportedMedia   = "unsupported_media"
+	ErrCodeUnprocessable      = "unprocessable"
+	ErrCodeRateLimited        = "rate_limited"
+	ErrCodeInternal           = "internal_error"
+	ErrCodeServiceUnavailable = "service_unavailable"
+	ErrCodeTimeout            = "timeout"
+)
+
+// APIError is the body of every error response
+type APIError struct {
+	Code      string                 `json:"code"`
+	Message   string                 `json:"message"`
+	RequestID string                 `json:"request_id,omitempty"`
+	Details   map[string]interface{} `json:"details,omitempty"`
+}
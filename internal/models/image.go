@@ -0,0 +1,8 @@
+package models
+
+// MIME types of the image formats added beyond those http.DetectContentType
+// and Fiji handle alike. DNG is a TIFF with a DNGVersion tag.
+const (
+	MIMETypeBMP = "image/bmp"
+	MIMETypeDNG = "image/dng"
+)
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// Profile is a named preset of macro settings for a grade of gypsum, selected
+// per upload with profile_id. Built-in profiles cannot be changed or deleted.
+type Profile struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Builtin     bool   `json:"builtin"`
+	MacroParams
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// ProfileRequest creates or replaces a profile. Settings left out keep the
+// configured defaults; without an ID one is generated.
+type ProfileRequest struct {
+	ID          string `json:"id"`
+	Name        string `json:"name" binding:"required"`
+	Description string `json:"description"`
+	MacroParams
+}
@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+// Webhook events, sent when an analysis reaches the matching terminal state
+const (
+	EventAnalysisCompleted = "analysis.completed"
+	EventAnalysisFailed    = "analysis.failed"
+	EventAnalysisCancelled = "analysis.cancelled"
+)
+
+// WebhookEvents are the events a webhook can subscribe to
+var WebhookEvents = []string{EventAnalysisCompleted, EventAnalysisFailed, EventAnalysisCancelled}
+
+// WebhookRegistration is a request to register a webhook. Without events the
+// webhook receives all of them.
+type WebhookRegistration struct {
+	URL    string   `json:"url" binding:"required"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+}
+
+// Webhook is a registered URL that terminal analysis events are posted to.
+// The secret signs the payloads and is never returned. A webhook only receives
+// the events of analyses submitted by its owner, the client that registered it.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"-"`
+	Owner     string    `json:"-"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookPayload is the JSON body posted to a webhook
+type WebhookPayload struct {
+	Event     string          `json:"event"`
+	Timestamp time.Time       `json:"timestamp"`
+	Analysis  *AnalysisResult `json:"analysis"`
+}
+
+// WebhookDelivery is one attempt to deliver an event to a webhook. StatusCode
+// is 0 when no response was received.
+type WebhookDelivery struct {
+	ID         string    `json:"id"`
+	WebhookID  string    `json:"webhook_id"`
+	AnalysisID string    `json:"analysis_id"`
+	Event      string    `json:"event"`
+	Attempt    int       `json:"attempt"`
+	StatusCode int       `json:"status_code"`
+	Error      string    `json:"error,omitempty"`
+	Timestamp  time.Time `json:"timestamp"`
+}
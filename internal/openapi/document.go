@@ -0,0 +1,728 @@
+// Package openapi generates the OpenAPI 3 description of the REST API. Schemas
+// are derived from the models' json tags, so new result fields appear in the
+// document without further changes.
+package openapi
+
+//go:generate go run ./gen -o ../../docs/swagger.json
+
+import (
+	"reflect"
+	"strings"
+
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/services"
+)
+
+// Version is the OpenAPI version of the generated document
+const Version = "3.0.3"
+
+// formField is an optional field of the multipart upload
+type formField struct {
+	name        string
+	schema      Schema
+	description string
+}
+
+var (
+	stringField   = Schema{"type": "string"}
+	numberField   = Schema{"type": "number"}
+	intField      = Schema{"type": "integer"}
+	dateTimeField = Schema{"type": "string", "format": "date-time"}
+	binaryField   = Schema{"type": "string", "format": "binary"}
+
+	analysisID = pathParameter("id", "Analysis ID")
+
+	// cancelSchema is the body of a cancellation request that was accepted
+	cancelSchema = Schema{
+		"type": "object",
+		"properties": map[string]Schema{
+			"analysis_id": stringField,
+			"message":     stringField,
+		},
+	}
+)
+
+// uploadFields are the optional form fields read by the upload handler
+var uploadFields = []formField{
+	{"profile", stringField, "Name of a configured client profile"},
+	{"profile_id", stringField, "ID of a stored analysis profile whose macro settings replace the defaults"},
+	{"sample_group", stringField, "Groups analyses for the purity trend"},
+	{"callback_url", stringField, "http(s) URL that receives the final result"},
+	{"sample_id", stringField, "Identifier of the physical sample"},
+	{"operator_name", stringField, "Person who took the sample"},
+	{"notes", stringField, "Free-form notes on the sample"},
+	{"location_lat", numberField, "Latitude the sample was collected at, in decimal degrees"},
+	{"location_lon", numberField, "Longitude the sample was collected at, in decimal degrees"},
+	{"sampling_depth_m", numberField, "Depth the sample was taken at, in meters"},
+	{"pixels_per_micron", numberField, "Image scale; measurements are then reported in µm"},
+	{"scale_bar_length", numberField, "Length of a scale bar in µm, with scale_bar_pixels"},
+	{"scale_bar_pixels", numberField, "Length of the scale bar in pixels"},
+	{"threshold_method", stringField, "ImageJ auto-threshold method"},
+	{"gaussian_sigma", numberField, "Gaussian blur sigma, 0-20; 0 skips the blur"},
+	{"contrast_saturation", numberField, "Percentage of pixels saturated by contrast enhancement"},
+	{"min_particle_size", numberField, "Smallest particle area in pixels"},
+	{"max_particle_size", numberField, "Largest particle area in pixels"},
+	{"min_circularity", numberField, "Smallest particle circularity, 0-1"},
+	{"roi_file", Schema{"type": "string", "format": "binary"}, "ImageJ .roi file or .zip ROI set"},
+	{"roi_x", intField, "Left edge of the rectangle to crop to"},
+	{"roi_y", intField, "Top edge of the rectangle to crop to"},
+	{"roi_width", intField, "Width of the rectangle to crop to"},
+	{"roi_height", intField, "Height of the rectangle to crop to"},
+	{"timeout_seconds", intField, "Analysis timeout, clamped to the configured bounds"},
+}
+
+// Document returns the OpenAPI document of the REST API under /api/v1. The
+// WebSocket at /api/v1/ws is not described.
+func Document() map[string]any {
+	g := newGenerator()
+	g.schema(reflect.TypeOf(models.APIError{}))
+
+	paths := map[string]any{}
+	for _, group := range []func(*generator) map[string]any{analysisPaths, exportPaths, batchPaths, uploadPaths, webhookPaths, profilePaths} {
+		for path, item := range group(g) {
+			paths[path] = item
+		}
+	}
+
+	return map[string]any{
+		"openapi": Version,
+		"info": map[string]any{
+			"title":       "Gypsum Analysis API",
+			"version":     "1.0",
+			"description": "Gypsum purity analysis of microscope images with Fiji/ImageJ",
+		},
+		"paths": paths,
+		"components": map[string]any{
+			"schemas": g.components,
+			"securitySchemes": map[string]any{
+				"bearerAuth": map[string]any{"type": "http", "scheme": "bearer", "bearerFormat": "JWT"},
+			},
+		},
+		// Tokens are only checked while AUTH_ENABLED is set
+		"security": []any{map[string]any{}, map[string]any{"bearerAuth": []string{}}},
+	}
+}
+
+// analysisPaths describes submitting, reading and managing single analyses
+func analysisPaths(g *generator) map[string]any {
+	result := g.schema(reflect.TypeOf(models.AnalysisResult{}))
+
+	return map[string]any{
+		"/api/v1/analysis/gypsum": map[string]any{
+			"post": operation("Submit an image for analysis", map[string]any{
+				"parameters": []any{
+					query("wait", Schema{"type": "boolean"}, "Wait for the analysis and respond with its result"),
+					header("X-Wait", Schema{"type": "boolean"}, "Same as the wait query parameter"),
+					header("X-Request-Timeout", intField, "Seconds to wait for the result, when waiting"),
+					header("Idempotency-Key", Schema{"type": "string", "maxLength": 255}, "Retries with the same key return the analysis it started instead of starting another"),
+				},
+				"requestBody": multipartBody(uploadSchema("image", "JPG, PNG, TIFF, WebP, BMP or DNG image")),
+				"responses": map[string]any{
+					"200": jsonResponse("The finished analysis, when waiting", result),
+					"202": jsonResponse("The analysis was queued", Schema{
+						"type": "object",
+						"properties": map[string]Schema{
+							"analysis_id": stringField,
+							"status":      stringField,
+							"message":     stringField,
+						},
+						"required": []string{"analysis_id", "status", "message"},
+					}),
+					"400": errorResponse("Invalid form field or image"),
+					"413": errorResponse("The image exceeds MAX_FILE_SIZE"),
+					"415": errorResponse("The file content is not a supported image"),
+					"422": errorResponse("The image has more than MAX_IMAGE_PIXELS pixels, or the region of interest does not fit it"),
+					"429": errorResponse("The analysis queue is full"),
+					"503": errorResponse("The server is shutting down"),
+					"504": errorResponse("The analysis did not finish in time, when waiting"),
+				},
+			}),
+		},
+		"/api/v1/analysis/macro/preview": map[string]any{
+			"post": operation("Preview the ImageJ macro of an analysis", map[string]any{
+				"requestBody": multipartBody(previewSchema()),
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The macro Fiji would run, with {analysis_id} in place of the analysis ID",
+						"content":     map[string]any{"text/plain": map[string]any{"schema": stringField}},
+					},
+					"400": errorResponse("Invalid form field, or neither an image nor a filename"),
+					"413": errorResponse("The image exceeds MAX_FILE_SIZE"),
+				},
+			}),
+		},
+		"/api/v1/analysis/status/{id}": map[string]any{
+			"get": operation("Get an analysis", map[string]any{
+				"parameters": []any{
+					analysisID,
+					query("include_units", Schema{"type": "boolean"}, "Add the unit of every numeric field"),
+				},
+				"responses": map[string]any{
+					"200": jsonResponse("The analysis in its current state", result),
+					"404": errorResponse("Unknown analysis"),
+				},
+			}),
+		},
+		"/api/v1/analysis/status/{id}/stream": map[string]any{
+			"get": operation("Stream the final result of an analysis", map[string]any{
+				"parameters": []any{analysisID},
+				"responses": map[string]any{
+					"200": eventStream("One event with the final result"),
+					"404": errorResponse("Unknown analysis"),
+				},
+			}),
+		},
+		"/api/v1/analysis/{id}/events": map[string]any{
+			"get": operation("Stream every status change of an analysis", map[string]any{
+				"parameters": []any{analysisID},
+				"responses": map[string]any{
+					"200": eventStream("The result now and after every status change"),
+					"404": errorResponse("Unknown analysis"),
+				},
+			}),
+		},
+		"/api/v1/analysis/status/{id}/annotate": map[string]any{
+			"post": operation("Annotate a finished analysis", map[string]any{
+				"parameters":  []any{analysisID},
+				"requestBody": jsonBody(g.request(reflect.TypeOf(models.Annotation{}))),
+				"responses": map[string]any{
+					"200": jsonResponse("The annotated analysis", result),
+					"400": errorResponse("Invalid annotation"),
+					"404": errorResponse("Unknown analysis"),
+					"409": errorResponse("The analysis is still processing"),
+				},
+			}),
+		},
+		"/api/v1/analysis/status/{id}/manifest": map[string]any{
+			"get": operation("Get the archival manifest of a finished analysis", map[string]any{
+				"parameters": []any{analysisID},
+				"responses": map[string]any{
+					"200": jsonResponse("The manifest", g.schema(reflect.TypeOf(models.AnalysisManifest{}))),
+					"404": errorResponse("Unknown analysis"),
+					"409": errorResponse("The analysis is still processing"),
+				},
+			}),
+		},
+		"/api/v1/analysis": map[string]any{
+			"get": operation("List analyses", map[string]any{
+				"parameters": []any{
+					query("status", g.schema(reflect.TypeOf(models.AnalysisStatus(""))), "Only analyses in this state"),
+					query("sample_id", stringField, "Only analyses of this sample"),
+					query("min_purity", numberField, "Smallest purity percentage"),
+					query("max_purity", numberField, "Largest purity percentage"),
+					query("from", dateTimeField, "Created at or after this RFC 3339 time"),
+					query("to", dateTimeField, "Created at or before this RFC 3339 time"),
+					query("sort", Schema{"type": "string", "enum": []string{
+						services.SortCreatedAt, services.SortCreatedAtDesc, services.SortCompletedAt, services.SortCompletedAtDesc,
+					}}, "Sort order; a leading - sorts descending"),
+					query("page", intField, "Page number, from 1"),
+					query("per_page", intField, "Analyses per page"),
+				},
+				"responses": map[string]any{
+					"200": jsonResponse("One page of analyses", Schema{
+						"type": "object",
+						"properties": map[string]Schema{
+							"data":        {"type": "array", "items": result},
+							"total":       intField,
+							"page":        intField,
+							"per_page":    intField,
+							"total_pages": intField,
+						},
+						"required": []string{"data", "total", "page", "per_page", "total_pages"},
+					}),
+					"400": errorResponse("Invalid filter"),
+					"422": errorResponse("per_page is too large"),
+				},
+			}),
+		},
+		"/api/v1/analysis/trend": map[string]any{
+			"get": operation("Get the purity trend of a sample group", map[string]any{
+				"parameters": []any{
+					requiredQuery("sample_group", stringField, "Sample group to chart"),
+					query("from", dateTimeField, "Completed at or after this RFC 3339 time"),
+					query("to", dateTimeField, "Completed at or before this RFC 3339 time"),
+					query("format", Schema{"type": "string", "enum": []string{"json", "png"}, "default": "json"}, "JSON points or a PNG chart"),
+				},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The purity of completed analyses, oldest first",
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": Schema{
+								"type": "object",
+								"properties": map[string]Schema{
+									"sample_group": stringField,
+									"points":       {"type": "array", "items": g.schema(reflect.TypeOf(models.TrendPoint{}))},
+								},
+							}},
+							"image/png": map[string]any{"schema": binaryField},
+						},
+					},
+					"400": errorResponse("Missing sample group or invalid parameter"),
+					"404": errorResponse("No completed analyses to chart"),
+				},
+			}),
+		},
+		"/api/v1/analysis/summary": map[string]any{
+			"get": operation("Get purity statistics of a batch or time range", map[string]any{
+				"parameters": []any{
+					query("batch_id", stringField, "Summarize the analyses of this batch"),
+					query("from", dateTimeField, "Summarize analyses created at or after this RFC 3339 time"),
+					query("to", dateTimeField, "Summarize analyses created at or before this RFC 3339 time"),
+				},
+				"responses": map[string]any{
+					"200": jsonResponse("Statistics over the completed analyses", g.schema(reflect.TypeOf(models.PuritySummary{}))),
+					"400": errorResponse("Neither batch_id nor from/to, or an invalid time"),
+					"404": errorResponse("Unknown batch"),
+					"422": errorResponse("Fewer than two completed analyses"),
+				},
+			}),
+		},
+		"/api/v1/analysis/compare": map[string]any{
+			"get": operation("Compare two completed analyses", map[string]any{
+				"parameters": []any{
+					requiredQuery("a", stringField, "ID of the earlier analysis"),
+					requiredQuery("b", stringField, "ID of the later analysis; deltas are b minus a"),
+					query("allow_cross_sample", Schema{"type": "boolean"}, "Compare analyses of different samples"),
+				},
+				"responses": map[string]any{
+					"200": jsonResponse("Both results and the change between them", g.schema(reflect.TypeOf(models.ComparisonResult{}))),
+					"400": errorResponse("Missing a or b"),
+					"404": errorResponse("Unknown analysis"),
+					"422": errorResponse("An analysis has not completed, or the analyses are of different samples"),
+				},
+			}),
+		},
+		"/api/v1/analysis/{id}/cancel": map[string]any{
+			"post": operation("Cancel an unfinished analysis", map[string]any{
+				"parameters": []any{analysisID},
+				"responses": map[string]any{
+					"202": jsonResponse("Cancellation was requested", cancelSchema),
+					"404": errorResponse("Unknown analysis"),
+					"409": errorResponse("The analysis has finished or is finishing"),
+				},
+			}),
+		},
+		"/api/v1/analysis/{id}": map[string]any{
+			"delete": operation("Cancel or delete an analysis", map[string]any{
+				"parameters": []any{analysisID},
+				"responses": map[string]any{
+					"202": jsonResponse("Cancellation of the unfinished analysis was requested", cancelSchema),
+					"204": map[string]any{"description": "The finished analysis and its files were deleted"},
+					"404": errorResponse("Unknown analysis"),
+					"409": errorResponse("The analysis is finishing; retry shortly"),
+				},
+			}),
+		},
+		"/api/v1/analysis/{id}/overlay": map[string]any{
+			"get": operation("Get the particle outlines of an analysis", map[string]any{
+				"parameters": []any{analysisID},
+				"responses": map[string]any{
+					"200": fileResponse("PNG of the counted particles' outlines", "image/png"),
+					"404": errorResponse("Unknown analysis or no overlay"),
+				},
+			}),
+		},
+		"/api/v1/analysis/{id}/verify": map[string]any{
+			"get": operation("Verify the signature of a completed result", map[string]any{
+				"parameters": []any{analysisID},
+				"responses": map[string]any{
+					"200": jsonResponse("Whether the result is unchanged since it was signed", g.schema(reflect.TypeOf(models.ResultVerification{}))),
+					"404": errorResponse("Unknown analysis"),
+					"409": errorResponse("The analysis is still processing or its result is not signed"),
+				},
+			}),
+		},
+	}
+}
+
+// exportPaths describes the exports and reports of completed analyses
+func exportPaths(g *generator) map[string]any {
+	return map[string]any{
+		"/api/v1/analysis/status/{id}/export": map[string]any{
+			"get": operation("Export a completed analysis in SI units", map[string]any{
+				"parameters": []any{
+					analysisID,
+					query("units", Schema{"type": "string", "enum": []string{"si"}, "default": "si"}, "Unit system"),
+					query("format", Schema{"type": "string", "enum": []string{"json", "csv"}, "default": "json"}, "Export format"),
+					query("sig_figs", intField, "Significant figures of the converted values"),
+				},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The spatial quantities converted to SI units",
+						"content": map[string]any{
+							"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(models.SIExport{}))},
+							"text/csv":         map[string]any{"schema": stringField},
+						},
+					},
+					"400": errorResponse("Invalid parameter"),
+					"404": errorResponse("Unknown analysis"),
+					"409": errorResponse("The analysis is not completed"),
+					"422": errorResponse("The analysis has no calibration"),
+				},
+			}),
+		},
+		"/api/v1/analysis/{id}/export": map[string]any{
+			"get": operation("Download the measurements of a completed analysis", map[string]any{
+				"parameters": []any{
+					analysisID,
+					query("format", Schema{"type": "string", "enum": []string{"csv", "json"}, "default": "csv"}, "Export format"),
+				},
+				"responses": map[string]any{
+					"200": map[string]any{
+						"description": "The measurements as an attachment",
+						"content": map[string]any{
+							"text/csv":         map[string]any{"schema": stringField},
+							"application/json": map[string]any{"schema": g.schema(reflect.TypeOf(models.AnalysisResult{}))},
+						},
+					},
+					"400": errorResponse("Unsupported format"),
+					"404": errorResponse("Unknown analysis"),
+					"409": errorResponse("The analysis is not completed"),
+				},
+			}),
+		},
+		"/api/v1/analysis/export": map[string]any{
+			"get": operation("Download completed analyses as CSV", map[string]any{
+				"parameters": []any{
+					query("format", Schema{"type": "string", "enum": []string{"csv"}, "default": "csv"}, "Export format"),
+					query("from", dateTimeField, "Created at or after this RFC 3339 time"),
+					query("to", dateTimeField, "Created at or before this RFC 3339 time"),
+				},
+				"responses": map[string]any{
+					"200": fileResponse("One row per completed analysis", "text/csv"),
+					"400": errorResponse("Invalid parameter"),
+				},
+			}),
+		},
+		"/api/v1/analysis/{id}/report": map[string]any{
+			"get": operation("Download the PDF lab report of a completed analysis", map[string]any{
+				"parameters": []any{analysisID},
+				"responses": map[string]any{
+					"200": fileResponse("The lab report", "application/pdf"),
+					"404": errorResponse("Unknown analysis"),
+					"409": errorResponse("The analysis is not completed"),
+				},
+			}),
+		},
+	}
+}
+
+// batchPaths describes submitting several images at once
+func batchPaths(g *generator) map[string]any {
+	return map[string]any{
+		"/api/v1/analysis/gypsum/batch": map[string]any{
+			"post": operation("Submit a ZIP archive of images", map[string]any{
+				"requestBody": multipartBody(uploadSchema("archive", "ZIP archive of images")),
+				"responses": map[string]any{
+					"202": jsonResponse("One analysis was queued per image", g.schema(reflect.TypeOf(models.BatchResult{}))),
+					"400": errorResponse("Invalid archive or form field"),
+					"413": errorResponse("The archive exceeds MAX_BATCH_SIZE images or their total MAX_FILE_SIZE"),
+					"429": errorResponse("The analysis queue is full"),
+					"503": errorResponse("The server is shutting down"),
+				},
+			}),
+		},
+		"/api/v1/analysis/batch": map[string]any{
+			"post": operation("Submit several images", map[string]any{
+				"requestBody": multipartBody(uploadSchema("images[]", "Images, one field per file")),
+				"responses": map[string]any{
+					"202": jsonResponse("The outcome of every image", g.schema(reflect.TypeOf(models.BatchUpload{}))),
+					"400": errorResponse("No image could be analyzed"),
+					"413": errorResponse("The upload exceeds MAX_BATCH_SIZE images or their total MAX_FILE_SIZE"),
+					"429": errorResponse("The analysis queue is full"),
+				},
+			}),
+		},
+		"/api/v1/analysis/batch/{batch_id}": map[string]any{
+			"get": operation("Get the status of a batch", map[string]any{
+				"parameters": []any{pathParameter("batch_id", "Batch ID")},
+				"responses": map[string]any{
+					"200": jsonResponse("The aggregated status", g.schema(reflect.TypeOf(models.BatchSummary{}))),
+					"404": errorResponse("Unknown batch"),
+				},
+			}),
+		},
+	}
+}
+
+// uploadPaths describes uploads sent in chunks
+func uploadPaths(g *generator) map[string]any {
+	uploadID := pathParameter("upload_id", "Upload ID")
+	// The settings of a single upload; an ROI file cannot outlive the request
+	settings := settingsProperties()
+	delete(settings, "roi_file")
+	settings["filename"] = Schema{"type": "string", "description": "Name of the file being uploaded, whose extension gives its type"}
+
+	return map[string]any{
+		"/api/v1/analysis/upload/init": map[string]any{
+			"post": operation("Start an upload sent in chunks", map[string]any{
+				"requestBody": multipartBody(Schema{"type": "object", "properties": settings, "required": []string{"filename"}}),
+				"responses": map[string]any{
+					"201": jsonResponse("The upload was started", Schema{
+						"type":       "object",
+						"properties": map[string]Schema{"upload_id": stringField},
+						"required":   []string{"upload_id"},
+					}),
+					"400": errorResponse("Invalid file name or form field"),
+					"429": errorResponse("Too many open uploads, or the rate limit was exceeded"),
+				},
+			}),
+		},
+		"/api/v1/analysis/upload/{upload_id}/chunk": map[string]any{
+			"post": operation("Send one chunk of an upload", map[string]any{
+				"parameters": []any{uploadID, requiredQuery("index", intField, "Position of the chunk, from 0")},
+				"requestBody": map[string]any{
+					"required": true,
+					"content":  map[string]any{"application/octet-stream": map[string]any{"schema": binaryField}},
+				},
+				"responses": map[string]any{
+					"200": jsonResponse("The chunk was stored", Schema{
+						"type": "object",
+						"properties": map[string]Schema{
+							"upload_id": stringField,
+							"index":     intField,
+							"size":      intField,
+						},
+					}),
+					"400": errorResponse("Invalid index"),
+					"404": errorResponse("Unknown or expired upload, or one started by another client"),
+					"409": errorResponse("The upload is being completed"),
+					"413": errorResponse("The chunks exceed MAX_FILE_SIZE"),
+					"429": errorResponse("Rate limit exceeded"),
+				},
+			}),
+		},
+		"/api/v1/analysis/upload/{upload_id}/complete": map[string]any{
+			"post": operation("Reassemble an upload and analyze it", map[string]any{
+				"parameters":  []any{uploadID},
+				"requestBody": jsonBody(g.request(reflect.TypeOf(models.UploadCompletion{}))),
+				"responses": map[string]any{
+					"202": jsonResponse("The analysis was queued", Schema{
+						"type": "object",
+						"properties": map[string]Schema{
+							"analysis_id": stringField,
+							"status":      stringField,
+							"message":     stringField,
+						},
+						"required": []string{"analysis_id", "status", "message"},
+					}),
+					"400": errorResponse("Chunks are missing or the file is invalid"),
+					"404": errorResponse("Unknown or expired upload"),
+					"409": errorResponse("The upload is already being completed"),
+					"413": errorResponse("The chunks exceed MAX_FILE_SIZE"),
+					"415": errorResponse("The file content is not a supported image"),
+					"422": errorResponse("The image has more than MAX_IMAGE_PIXELS pixels, or the region of interest does not fit it"),
+					"429": errorResponse("The analysis queue is full"),
+					"503": errorResponse("The server is shutting down"),
+				},
+			}),
+		},
+	}
+}
+
+// webhookPaths describes the webhooks for terminal analysis events
+func webhookPaths(g *generator) map[string]any {
+	return map[string]any{
+		"/api/v1/webhooks": map[string]any{
+			"post": operation("Register a webhook", map[string]any{
+				"requestBody": jsonBody(g.request(reflect.TypeOf(models.WebhookRegistration{}))),
+				"responses": map[string]any{
+					"201": jsonResponse("The registered webhook", g.schema(reflect.TypeOf(models.Webhook{}))),
+					"400": errorResponse("Invalid or internal URL, or unknown event"),
+				},
+			}),
+		},
+		"/api/v1/webhooks/{id}/deliveries": map[string]any{
+			"get": operation("List the recent deliveries to a webhook", map[string]any{
+				"parameters": []any{pathParameter("id", "Webhook ID")},
+				"responses": map[string]any{
+					"200": jsonResponse("Delivery attempts, newest first", Schema{
+						"type": "object",
+						"properties": map[string]Schema{
+							"webhook_id": stringField,
+							"deliveries": {"type": "array", "items": g.schema(reflect.TypeOf(models.WebhookDelivery{}))},
+						},
+					}),
+					"404": errorResponse("Unknown webhook"),
+				},
+			}),
+		},
+		"/api/v1/webhooks/{id}": map[string]any{
+			"delete": operation("Delete a webhook", map[string]any{
+				"parameters": []any{pathParameter("id", "Webhook ID")},
+				"responses": map[string]any{
+					"204": map[string]any{"description": "The webhook and its delivery attempts were deleted"},
+					"404": errorResponse("Unknown webhook"),
+				},
+			}),
+		},
+	}
+}
+
+// profilePaths describes the stored analysis profiles
+func profilePaths(g *generator) map[string]any {
+	profile := g.schema(reflect.TypeOf(models.Profile{}))
+	body := jsonBody(g.request(reflect.TypeOf(models.ProfileRequest{})))
+	profileID := pathParameter("id", "Profile ID")
+
+	return map[string]any{
+		"/api/v1/profiles": map[string]any{
+			"get": operation("List analysis profiles", map[string]any{
+				"responses": map[string]any{
+					"200": jsonResponse("All profiles, built-in ones first", Schema{
+						"type":       "object",
+						"properties": map[string]Schema{"profiles": {"type": "array", "items": profile}},
+					}),
+				},
+			}),
+			"post": operation("Create an analysis profile", map[string]any{
+				"requestBody": body,
+				"responses": map[string]any{
+					"201": jsonResponse("The created profile", profile),
+					"400": errorResponse("Invalid profile"),
+					"409": errorResponse("The ID is already in use"),
+				},
+			}),
+		},
+		"/api/v1/profiles/{id}": map[string]any{
+			"get": operation("Get an analysis profile", map[string]any{
+				"parameters": []any{profileID},
+				"responses": map[string]any{
+					"200": jsonResponse("The profile", profile),
+					"404": errorResponse("Unknown profile"),
+				},
+			}),
+			"put": operation("Update an analysis profile", map[string]any{
+				"parameters":  []any{profileID},
+				"requestBody": body,
+				"responses": map[string]any{
+					"200": jsonResponse("The updated profile", profile),
+					"400": errorResponse("Invalid profile"),
+					"404": errorResponse("Unknown profile"),
+					"409": errorResponse("Built-in profiles cannot be changed"),
+				},
+			}),
+			"delete": operation("Delete an analysis profile", map[string]any{
+				"parameters": []any{profileID},
+				"responses": map[string]any{
+					"204": map[string]any{"description": "The profile was deleted"},
+					"404": errorResponse("Unknown profile"),
+					"409": errorResponse("Built-in profiles cannot be deleted"),
+				},
+			}),
+		},
+	}
+}
+
+// operation returns an operation object with a summary and the given fields
+func operation(summary string, fields map[string]any) map[string]any {
+	fields["summary"] = summary
+	return fields
+}
+
+// query returns an optional query parameter
+func query(name string, schema Schema, description string) map[string]any {
+	return map[string]any{"name": name, "in": "query", "description": description, "schema": schema}
+}
+
+// requiredQuery returns a required query parameter
+func requiredQuery(name string, schema Schema, description string) map[string]any {
+	parameter := query(name, schema, description)
+	parameter["required"] = true
+	return parameter
+}
+
+// header returns an optional request header
+func header(name string, schema Schema, description string) map[string]any {
+	return map[string]any{"name": name, "in": "header", "description": description, "schema": schema}
+}
+
+// pathParameter returns a string path parameter
+func pathParameter(name, description string) map[string]any {
+	return map[string]any{"name": name, "in": "path", "required": true, "description": description, "schema": stringField}
+}
+
+// jsonBody returns a required JSON request body
+func jsonBody(schema Schema) map[string]any {
+	return map[string]any{
+		"required": true,
+		"content":  map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
+
+// multipartBody returns a required multipart/form-data request body
+func multipartBody(schema Schema) map[string]any {
+	return map[string]any{
+		"required": true,
+		"content":  map[string]any{"multipart/form-data": map[string]any{"schema": schema}},
+	}
+}
+
+// fileResponse returns a response whose body is a file of the given type
+func fileResponse(description, contentType string) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{contentType: map[string]any{"schema": binaryField}},
+	}
+}
+
+// eventStream returns a Server-Sent Events response whose events carry
+// analysis results
+func eventStream(description string) map[string]any {
+	return map[string]any{
+		"description": description + ", as Server-Sent Events whose data is an AnalysisResult",
+		"content":     map[string]any{"text/event-stream": map[string]any{"schema": stringField}},
+	}
+}
+
+// jsonResponse returns a response with a JSON body
+func jsonResponse(description string, schema Schema) map[string]any {
+	return map[string]any{
+		"description": description,
+		"content":     map[string]any{"application/json": map[string]any{"schema": schema}},
+	}
+}
+
+// errorResponse returns a response with the shared error body
+func errorResponse(description string) map[string]any {
+	return jsonResponse(description, Schema{"$ref": "#/components/schemas/APIError"})
+}
+
+// uploadSchema returns the multipart body of an upload whose files are sent
+// in fileField, with the optional submission settings
+func uploadSchema(fileField, description string) Schema {
+	file := Schema{"type": "string", "format": "binary", "description": description}
+	if strings.HasSuffix(fileField, "[]") {
+		file = Schema{"type": "array", "items": binaryField, "description": description}
+	}
+	properties := settingsProperties()
+	properties[fileField] = file
+	return Schema{"type": "object", "properties": properties, "required": []string{fileField}}
+}
+
+// previewSchema returns the form of a macro preview: the form of an analysis
+// whose image may be replaced by its name
+func previewSchema() Schema {
+	schema := uploadSchema("image", "Image to be analyzed; only its name is used")
+	delete(schema, "required")
+	schema["properties"].(map[string]Schema)["filename"] = Schema{"type": "string", "description": "Name of the image, whose extension gives its type, when it is not sent"}
+	return schema
+}
+
+// settingsProperties returns the form fields of the optional submission settings
+func settingsProperties() map[string]Schema {
+	properties := map[string]Schema{}
+	for _, field := range uploadFields {
+		schema := Schema{"description": field.description}
+		for key, value := range field.schema {
+			schema[key] = value
+		}
+		properties[field.name] = schema
+	}
+	properties["metadata"] = Schema{
+		"type":                 "object",
+		"additionalProperties": stringField,
+		"description":          "Free-form values stored with the result, sent as metadata[key] fields",
+	}
+	return properties
+}
@@ -0,0 +1,101 @@
+package openapi
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocument(t *testing.T) {
+	doc := Document()
+	assert.Equal(t, Version, doc["openapi"])
+
+	paths := doc["paths"].(map[string]any)
+	for _, path := range []string{"/api/v1/analysis/gypsum", "/api/v1/analysis/status/{id}", "/api/v1/analysis", "/api/v1/analysis/{id}"} {
+		assert.Contains(t, paths, path)
+	}
+	assert.Contains(t, paths["/api/v1/analysis/{id}"], "delete")
+
+	_, err := json.Marshal(doc)
+	require.NoError(t, err)
+}
+
+func TestDocument_DescribesEveryRoute(t *testing.T) {
+	paths := Document()["paths"].(map[string]any)
+	for _, path := range []string{
+		"/api/v1/analysis/gypsum/batch",
+		"/api/v1/analysis/batch/{batch_id}",
+		"/api/v1/analysis/status/{id}/export",
+		"/api/v1/analysis/trend",
+		"/api/v1/analysis/{id}/verify",
+		"/api/v1/webhooks",
+		"/api/v1/profiles/{id}",
+	} {
+		assert.Contains(t, paths, path)
+	}
+	assert.Contains(t, paths["/api/v1/profiles/{id}"], "put")
+}
+
+func TestDocument_SecurityAndErrors(t *testing.T) {
+	doc := Document()
+	components := doc["components"].(map[string]any)
+	assert.Contains(t, components["securitySchemes"], "bearerAuth")
+
+	schemas := components["schemas"].(map[string]Schema)
+	assert.Contains(t, schemas, "APIError")
+	assert.Contains(t, schemas, "AnalysisResult")
+}
+
+func TestDocument_MatchesGeneratedFile(t *testing.T) {
+	generated, err := os.ReadFile("../../docs/swagger.json")
+	require.NoError(t, err)
+	current, err := json.Marshal(Document())
+	require.NoError(t, err)
+
+	assert.JSONEq(t, string(current), string(generated), "docs/swagger.json is stale; run make generate")
+}
+
+func TestSchema_FollowsStructTags(t *testing.T) {
+	g := newGenerator()
+	ref := g.schema(reflect.TypeOf(&models.AnalysisResult{}))
+	assert.Equal(t, "#/components/schemas/AnalysisResult", ref["$ref"], "pointers are unwrapped")
+
+	result := g.components["AnalysisResult"]
+	properties := result["properties"].(map[string]Schema)
+	for _, name := range []string{"id", "status", "purity_percentage", "per_slice", "profile_id"} {
+		assert.Contains(t, properties, name)
+	}
+	assert.Equal(t, "number", properties["purity_percentage"]["type"])
+	assert.Equal(t, Schema{"type": "string", "format": "date-time"}, properties["created_at"])
+	assert.Contains(t, properties["status"], "enum")
+	assert.Contains(t, result["required"], "id")
+	assert.NotContains(t, result["required"], "per_slice", "omitempty fields are optional")
+
+	assert.Contains(t, g.components, "SliceResult", "nested structs become components")
+}
+
+func TestSchema_FlattensEmbeddedStructs(t *testing.T) {
+	g := newGenerator()
+	g.schema(reflect.TypeOf(models.Profile{}))
+
+	properties := g.components["Profile"]["properties"].(map[string]Schema)
+	assert.Contains(t, properties, "name")
+	assert.Contains(t, properties, "threshold_method")
+	assert.NotContains(t, properties, "MacroParams")
+}
+
+func TestRequest_RequiresBoundFields(t *testing.T) {
+	g := newGenerator()
+	ref := g.request(reflect.TypeOf(models.Annotation{}))
+	assert.Equal(t, "#/components/schemas/Annotation", ref["$ref"])
+
+	annotation := g.components["Annotation"]
+	assert.Equal(t, []string{"reviewed_by"}, annotation["required"])
+	assert.Contains(t, annotation["properties"], "override_purity")
+}
@@ -0,0 +1,25 @@
+// Command gen writes the OpenAPI document of the REST API to a file, so it can
+// be published without running the server.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+
+	"gypsum-analysis-api/internal/openapi"
+)
+
+func main() {
+	output := flag.String("o", "docs/swagger.json", "file to write the OpenAPI document to")
+	flag.Parse()
+
+	spec, err := json.MarshalIndent(openapi.Document(), "", "  ")
+	if err != nil {
+		log.Fatalf("failed to encode OpenAPI document: %v", err)
+	}
+	if err := os.WriteFile(*output, append(spec, '\n'), 0644); err != nil {
+		log.Fatalf("failed to write OpenAPI document: %v", err)
+	}
+}
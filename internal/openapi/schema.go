@@ -0,0 +1,146 @@
+package openapi
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// Schema is an OpenAPI schema object
+type Schema map[string]any
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// enums lists the values of the named string types that have a fixed set
+var enums = map[reflect.Type][]string{
+	reflect.TypeOf(models.AnalysisStatus("")): {
+		string(models.StatusPending), string(models.StatusProcessing), string(models.StatusCompleted),
+		string(models.StatusFailed), string(models.StatusCancelled),
+	},
+}
+
+// generator derives schemas from Go types and their json tags. Named structs
+// become components referenced by name, so each is described once.
+type generator struct {
+	components map[string]Schema
+}
+
+func newGenerator() *generator {
+	return &generator{components: make(map[string]Schema)}
+}
+
+// schema returns the schema of values of type t as encoding/json writes them
+func (g *generator) schema(t reflect.Type) Schema {
+	if t == timeType {
+		return Schema{"type": "string", "format": "date-time"}
+	}
+	if values, ok := enums[t]; ok {
+		return Schema{"type": "string", "enum": values}
+	}
+
+	switch t.Kind() {
+	case reflect.Pointer:
+		return g.schema(t.Elem())
+	case reflect.String:
+		return Schema{"type": "string"}
+	case reflect.Bool:
+		return Schema{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return Schema{"type": "integer"}
+	case reflect.Int64, reflect.Uint64:
+		return Schema{"type": "integer", "format": "int64"}
+	case reflect.Float32, reflect.Float64:
+		return Schema{"type": "number", "format": "double"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return Schema{"type": "string", "format": "byte"}
+		}
+		return Schema{"type": "array", "items": g.schema(t.Elem())}
+	case reflect.Map:
+		return Schema{"type": "object", "additionalProperties": g.schema(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		if _, done := g.components[t.Name()]; !done {
+			// Reserve the name first so recursive types terminate
+			g.components[t.Name()] = nil
+			g.components[t.Name()] = g.object(t)
+		}
+		return Schema{"$ref": "#/components/schemas/" + t.Name()}
+	}
+	return Schema{}
+}
+
+// request returns a reference to the schema of a request body of type t,
+// whose required fields are those bound with binding:"required"
+func (g *generator) request(t reflect.Type) Schema {
+	properties := make(map[string]Schema)
+	var required []string
+	g.fields(t, properties, &required, true)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	g.components[t.Name()] = schema
+	return Schema{"$ref": "#/components/schemas/" + t.Name()}
+}
+
+// object returns the schema of a struct. Fields without omitempty are
+// required, except pointers, which encode as null.
+func (g *generator) object(t reflect.Type) Schema {
+	properties := make(map[string]Schema)
+	var required []string
+	g.fields(t, properties, &required, false)
+
+	schema := Schema{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+// fields adds the encoded fields of struct t to properties, flattening
+// embedded structs as encoding/json does. Response fields are required unless
+// omitted when empty; request fields only when bound as required.
+func (g *generator) fields(t reflect.Type, properties map[string]Schema, required *[]string, request bool) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, options, _ := strings.Cut(tag, ",")
+
+		if field.Anonymous && name == "" {
+			embedded := field.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				g.fields(embedded, properties, required, request)
+				continue
+			}
+		}
+		if !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = g.schema(field.Type)
+		switch {
+		case request:
+			if slices.Contains(strings.Split(field.Tag.Get("binding"), ","), "required") {
+				*required = append(*required, name)
+			}
+		case !strings.Contains(options, "omitempty") && field.Type.Kind() != reflect.Pointer:
+			*required = append(*required, name)
+		}
+	}
+}
@@ -1,236 +1,1792 @@
 package services
 
 import (
+	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"math"
 	"mime/multipart"
+	"net/http"
 	"os"
-	"os/exec"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/imaging"
 	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/metrics"
 	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/storage"
+	"gypsum-analysis-api/internal/tracing"
+
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// ErrAnalysisNotFound is returned when no analysis exists for the given ID
+var ErrAnalysisNotFound = errors.New("analysis not found")
+
+// ErrAnalysisInProgress is returned for operations that require a finished analysis
+var ErrAnalysisInProgress = errors.New("analysis is still processing")
+
+// ErrAnalysisTimeout is reported when Fiji does not finish within the analysis timeout
+var ErrAnalysisTimeout = errors.New("analysis timed out")
+
+// ErrFijiStalled is reported when Fiji stops printing heartbeats before finishing
+var ErrFijiStalled = errors.New("Fiji stalled (no heartbeat)")
+
+// errUnparseableOutput is reported when the results block lacks required
+// measurements and estimated results are not allowed
+var errUnparseableOutput = errors.New("unable to parse analysis output")
+
+// estimatedConfidence is the confidence reported for results that include estimates
+const estimatedConfidence = 0.1
+
+// requiredResults are the measurements Fiji must report for a result to be
+// complete without estimates
+var requiredResults = []string{"purity_percentage", "particle_count", "threshold_value"}
+
+// ErrInvalidListFilter is returned by ListAnalyses for unsupported paging or sort options
+var ErrInvalidListFilter = errors.New("invalid list filter")
+
+// ErrUnsupportedMIME is returned when the content of an upload is not a JPEG,
+// PNG, TIFF, WebP, BMP or DNG image, whatever its file extension
+var ErrUnsupportedMIME = errors.New("unsupported image content")
+
+// ErrImageTypeMismatch is returned when an upload is an image of a different
+// type than its file extension claims
+var ErrImageTypeMismatch = errors.New("image content does not match file extension")
+
+// ErrImageTooLarge is returned when an upload's header claims more pixels
+// than MAX_IMAGE_PIXELS, so that decoding it could exhaust memory
+var ErrImageTooLarge = errors.New("image resolution exceeds the maximum")
+
+// ErrDNGPreview is returned when the first image of a DNG is a
+// reduced-resolution preview, the only image that can be converted
+var ErrDNGPreview = errors.New("DNG contains only a reduced-resolution preview that can be decoded")
+
+// ErrAnalysisCancelled is returned when an analysis is stopped by a cancellation request
+var ErrAnalysisCancelled = errors.New("analysis cancelled")
+
+// ErrAnalysisFinished is returned when cancelling an analysis that has already finished
+var ErrAnalysisFinished = errors.New("analysis has already finished")
+
+// ErrWaitTimeout is returned when a caller stops waiting before the analysis finishes
+var ErrWaitTimeout = errors.New("timed out waiting for analysis")
+
+// AnalysisService handles gypsum analysis operations.
+//
+// Results live in a ResultStore as immutable snapshots: every change copies
+// the current record, modifies the copy and saves it (see update). Readers
+// therefore always see a consistent result. Uploaded images are kept in a
+// BlobStore and only copied to local disk while Fiji analyzes them.
+//
+// The service is a prometheus.Collector exposing analysis counts, durations
+// and the queue depth.
+type AnalysisService struct {
+	config      *config.Config
+	logger      *logger.Logger
+	runner      FijiRunner
+	store       ResultStore
+	blobs       storage.BlobStore
+	cache       storage.CacheStore
+	pool        *WorkerPool
+	subscribers map[string][]chan struct{}
+	running     map[string]context.CancelFunc
+	watchers    map[string][]chan *models.AnalysisResult
+	listeners   map[chan models.StatusEvent]struct{}
+	metrics     *metrics.Metrics
+	tempFiles   *TempFileRegistry
+	webhooks    *WebhookDispatcher
+	mutex       sync.RWMutex
+
+	// callbackClient posts final results to callback URLs
+	callbackClient *http.Client
+
+	// engine runs the analyses: Fiji, or the native analyzer when Fiji is missing
+	engine string
+
+	// fijiSlots holds one token per running Fiji process, so that tiles of
+	// concurrent analyses never exceed MAX_CONCURRENT_ANALYSES processes
+	fijiSlots chan struct{}
+
+	// idempotencyKeys maps the Idempotency-Key of accepted uploads to their analysis
+	idempotencyKeys *IdempotencyKeys
+
+	// uploads holds the chunks of uploads that are sent in parts
+	uploads *ChunkedUploads
+
+	// signingKeys sign completed results; the first signs new ones
+	signingKeys []config.SigningKey
+
+	// done is closed by Close to stop background maintenance
+	done      chan struct{}
+	closeOnce sync.Once
+
+	// active counts the admitted analyses until their run ends. Once
+	// shuttingDown is set no more are admitted; abort is closed when Shutdown
+	// runs out of time and cancels the rest.
+	active       sync.WaitGroup
+	shuttingDown bool
+	abort        chan struct{}
+	abortOnce    sync.Once
+}
+
+// NewAnalysisService creates a new analysis service that keeps its results in
+// store and uploaded images in blobs, runs submitted analyses on pool and
+// reports them to m. Status lookups are cached in cache unless it is nil. Finished results held in memory are reaped after
+// RESULT_TTL, and chunked uploads left unfinished after UPLOAD_TTL, until
+// Close is called.
+func NewAnalysisService(cfg *config.Config, logger *logger.Logger, store ResultStore, blobs storage.BlobStore, cache storage.CacheStore, pool *WorkerPool, m *metrics.Metrics) *AnalysisService {
+	s := &AnalysisService{
+		config:      cfg,
+		logger:      logger,
+		runner:      newFijiRunner(cfg),
+		store:       store,
+		blobs:       blobs,
+		cache:       cache,
+		pool:        pool,
+		subscribers: make(map[string][]chan struct{}),
+		running:     make(map[string]context.CancelFunc),
+		watchers:    make(map[string][]chan *models.AnalysisResult),
+		listeners:   make(map[chan models.StatusEvent]struct{}),
+		metrics:     m,
+		tempFiles:   NewTempFileRegistry(),
+		webhooks:    NewWebhookDispatcher(store, logger),
+		done:        make(chan struct{}),
+		abort:       make(chan struct{}),
+
+		callbackClient:  newPublicClient(callbackTimeout),
+		idempotencyKeys: NewIdempotencyKeys(time.Duration(cfg.IdempotencyKeyTTL) * time.Second),
+		uploads:         NewChunkedUploads(cfg.TempDir, cfg.MaxFileSize, cfg.MaxOpenUploads, cfg.MaxOpenUploadsPerClient),
+		engine:          AnalysisEngine(cfg),
+		fijiSlots:       make(chan struct{}, max(cfg.MaxWorkers, 1)),
+	}
+	if s.engine == models.EngineGoNative {
+		logger.Info("Analyses run on the go-native engine")
+	}
+
+	keys, err := config.ParseSigningKeys(cfg.ResultSigningKeys)
+	if err != nil {
+		logger.WithError(err).Error("Results will not be signed")
+	}
+	s.signingKeys = keys
+
+	// A database is the system of record and is never reaped
+	if _, inMemory := store.(*MemoryStore); inMemory && cfg.ResultTTL > 0 {
+		go s.runReaper(time.Duration(cfg.ResultTTL)*time.Second, time.Duration(cfg.ResultReapInterval)*time.Second)
+	}
+	if cfg.UploadTTL > 0 {
+		go s.runUploadCleanup(time.Duration(cfg.UploadTTL) * time.Second)
+	}
+
+	return s
+}
+
+// Close stops the service's background maintenance and deletes the temp files
+// still registered. Call it once Shutdown has returned, as the files of
+// analyses that are still running are deleted too.
+func (s *AnalysisService) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		if removed := s.tempFiles.RemoveAll(); removed > 0 {
+			s.logger.WithField("files", removed).Info("Removed leftover temp files")
+		}
+	})
+}
+
+// DefaultMacroParams returns the preprocessing and particle settings used when
+// a request does not override them, with the configured blur, contrast and
+// threshold method
+func DefaultMacroParams(cfg *config.Config) models.MacroParams {
+	return models.MacroParams{
+		ThresholdMethod:    cfg.MacroThresholdMethod,
+		MinParticleSize:    10,
+		MaxParticleSize:    0,
+		MinCircularity:     0.0,
+		MaxCircularity:     1.0,
+		GaussianSigma:      cfg.MacroGaussianSigma,
+		ContrastSaturation: cfg.MacroContrastSaturation,
+	}
+}
+
+// preparedAnalysis is an analysis whose record and input files are in place,
+// ready to be run. The image is stored under imageKey in the blob store.
+type preparedAnalysis struct {
+	id       string
+	imageKey string
+	roiPath  string
+	crop     *models.Rectangle
+	params   models.MacroParams
+	timeout  time.Duration
+
+	// trace is the span that submitted the analysis, the parent of its spans
+	trace trace.SpanContext
+
+	// logFields are the log fields of the submitting request, such as its ID
+	logFields logrus.Fields
+}
+
+// AnalyzeGypsumImage performs gypsum analysis on an uploaded image, returning
+// once the analysis has finished
+func (s *AnalysisService) AnalyzeGypsumImage(analysisID string, file *multipart.FileHeader, opts AnalysisOptions) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+	defer s.finish()
+
+	job, err := s.prepareAnalysis(context.Background(), analysisID, formUpload(file), opts)
+	if err != nil {
+		return err
+	}
+	s.metrics.Submitted.Inc()
+
+	err = s.runAnalysis(job)
+	if !errors.Is(err, errRetryScheduled) {
+		return err
+	}
+
+	// The retry runs on the worker pool
+	result, err := s.WaitForAnalysis(context.Background(), analysisID)
+	if err == nil && result.Status == models.StatusFailed {
+		err = errors.New(result.Error)
+	}
+	return err
+}
+
+// SubmitAnalysis saves the upload and queues the analysis on the worker pool.
+// The upload is copied before returning, so the request may end while the
+// analysis waits; only the trace of ctx is carried over to it. When the queue
+// is full nothing is kept and ErrQueueFull is returned; once Shutdown has
+// begun it is ErrShuttingDown.
+func (s *AnalysisService) SubmitAnalysis(ctx context.Context, analysisID string, file *multipart.FileHeader, opts AnalysisOptions) error {
+	return s.submitUpload(ctx, analysisID, formUpload(file), opts)
+}
+
+// submitUpload prepares an upload and queues its analysis on the worker pool
+func (s *AnalysisService) submitUpload(ctx context.Context, analysisID string, file upload, opts AnalysisOptions) error {
+	if err := s.admit(); err != nil {
+		return err
+	}
+
+	job, err := s.prepareAnalysis(ctx, analysisID, file, opts)
+	if err != nil {
+		s.finish()
+		return err
+	}
+
+	// A resubmitted image reuses the earlier result instead of queueing a Fiji run
+	if s.config.DeduplicateImages && s.completeDuplicate(job) {
+		s.finish()
+		s.metrics.Submitted.Inc()
+		return nil
+	}
+
+	err = s.pool.Submit(func() { s.runQueued(job) })
+	if err != nil {
+		s.finish()
+		s.discardAnalysis(job)
+		return err
+	}
+	s.metrics.Submitted.Inc()
+
+	return nil
+}
+
+// macroOptions applies the profile of an analysis to opts and returns them
+// with the macro parameters they resolve to, after checking the region
+func (s *AnalysisService) macroOptions(opts AnalysisOptions) (AnalysisOptions, models.MacroParams, error) {
+	opts = s.applyProfileDefaults(opts)
+
+	params, err := s.profileMacroParams(opts)
+	if err != nil {
+		return opts, params, err
+	}
+	if opts.ROI != nil {
+		if err := ValidateROIRegion(*opts.ROI); err != nil {
+			return opts, params, err
+		}
+		if opts.ROIFile != nil {
+			return opts, params, fmt.Errorf("%w: a rectangular region cannot be combined with an ROI file", ErrInvalidROIRegion)
+		}
+	}
+	return opts, params, nil
+}
+
+// prepareAnalysis creates the pending analysis record, verifies the uploaded
+// image and stores it in the blob store. The ROI file is copied into the temp
+// directory. Failures are recorded on the result.
+func (s *AnalysisService) prepareAnalysis(ctx context.Context, analysisID string, file upload, opts AnalysisOptions) (*preparedAnalysis, error) {
+	opts, params, err := s.macroOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	calibration, err := opts.Calibration()
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := opts.Timeout
+	if timeout <= 0 {
+		timeout = time.Duration(s.config.AnalysisTimeout) * time.Second
+	}
+
+	// Create analysis result
+	result := &models.AnalysisResult{
+		ID:          analysisID,
+		Status:      models.StatusPending,
+		CreatedAt:   time.Now(),
+		ImageSize:   file.size,
+		Profile:     opts.Profile,
+		Filename:    file.name,
+		BatchID:     opts.BatchID,
+		SampleGroup: opts.SampleGroup,
+		CallbackURL: opts.CallbackURL,
+		Metadata:    opts.Metadata,
+		Parameters:  &params,
+		Calibration: calibration,
+
+		SampleMetadata: opts.Sample,
+		TimeoutSeconds: int(timeout / time.Second),
+		ProfileID:      opts.ProfileID,
+		Owner:          opts.Owner,
+		AnalysisEngine: s.engine,
+	}
+	if opts.ROIFile != nil {
+		result.ROIFile = opts.ROIFile.Filename
+	}
+	if opts.ROI != nil {
+		region := *opts.ROI
+		result.ROI = &region
+	}
+
+	// Store initial result
+	if err := s.store.Create(result); err != nil {
+		s.analysisLog(ctx, analysisID).WithError(err).Error("Failed to store analysis")
+		return nil, fmt.Errorf("failed to store analysis: %w", err)
+	}
+
+	// Stage the upload locally to verify it before it is stored
+	stagedPath := filepath.Join(s.config.TempDir, fmt.Sprintf("%s_upload%s", analysisID, filepath.Ext(file.name)))
+	_, span := tracer.Start(ctx, "saveUploadedFile", trace.WithAttributes(
+		tracing.AnalysisID.String(analysisID),
+		tracing.ImageSizeBytes.Int64(file.size),
+	))
+	stagedPath, checksum, err := s.saveUploadedFile(file, stagedPath)
+	defer os.Remove(stagedPath)
+	if err == nil && result.ROI != nil {
+		err = checkROIBounds(*result.ROI, stagedPath)
+	}
+	imageKey := analysisID + filepath.Ext(stagedPath)
+	if err == nil {
+		err = s.putImage(ctx, imageKey, stagedPath)
+	}
+	endSpan(span, err)
+	if errors.Is(err, ErrUnsupportedMIME) || errors.Is(err, ErrImageTypeMismatch) || errors.Is(err, ErrImageTooLarge) || errors.Is(err, ErrDNGPreview) || errors.Is(err, ErrROIOutOfBounds) {
+		// Rejected uploads are not analyses; leave nothing behind
+		s.discardAnalysis(&preparedAnalysis{id: analysisID})
+		return nil, err
+	}
+	if err != nil {
+		return nil, s.updateResultWithError(analysisID, models.FailureStorage, fmt.Sprintf("Failed to save uploaded file: %v", err))
+	}
+
+	// Record exactly what is being analyzed
+	s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.ImageKey = imageKey
+		result.ImagePath = s.blobs.Location(imageKey)
+		result.ImageSHA256 = checksum
+		return nil
+	})
+
+	// Save the optional ROI set next to the image so the macro can load it
+	roiPath := ""
+	if opts.ROIFile != nil {
+		roiPath = s.roiFilePath(analysisID, opts.ROIFile.Filename)
+		if err := s.saveROIFile(opts.ROIFile, roiPath); err != nil {
+			os.Remove(roiPath)
+			return nil, s.updateResultWithError(analysisID, models.FailureStorage, fmt.Sprintf("Failed to save ROI file: %v", err))
+		}
+	}
+
+	return &preparedAnalysis{
+		id:       analysisID,
+		imageKey: imageKey,
+		roiPath:  roiPath,
+		crop:     result.ROI,
+		params:   params,
+		timeout:  timeout,
+		trace:    trace.SpanContextFromContext(ctx),
+
+		logFields: logger.Fields(ctx),
+	}, nil
+}
+
+// discardAnalysis removes a prepared analysis that was never run
+func (s *AnalysisService) discardAnalysis(job *preparedAnalysis) {
+	if err := s.store.Delete(job.id); err != nil {
+		s.logger.WithField("analysis_id", job.id).WithError(err).Warn("Failed to discard analysis")
+	}
+	s.invalidateResult(job.id)
+	if job.imageKey != "" {
+		s.deleteImage(job.id, job.imageKey)
+	}
+	if job.roiPath != "" {
+		os.Remove(job.roiPath)
+	}
+}
+
+// runQueued runs an admitted analysis taken from the worker pool
+func (s *AnalysisService) runQueued(job *preparedAnalysis) {
+	defer s.finish()
+	if err := s.runAnalysis(job); err != nil && !errors.Is(err, errRetryScheduled) {
+		s.jobLog(job).WithError(err).Error("Analysis failed")
+	}
+}
+
+// runAnalysis analyzes a prepared upload. Failures are recorded on the result,
+// except Fiji failures that are retried; then the job is re-enqueued with its
+// input files kept and errRetryScheduled is returned.
+func (s *AnalysisService) runAnalysis(job *preparedAnalysis) error {
+	analysisID := job.id
+	retrying := false
+	if job.roiPath != "" {
+		defer func() {
+			if !retrying {
+				os.Remove(job.roiPath)
+			}
+		}()
+	}
+
+	// Register the run before it is marked processing, so a processing
+	// analysis can always be cancelled. The run continues the submitter's
+	// trace, and its log entries carry the submitting request's fields.
+	ctx := trace.ContextWithSpanContext(context.Background(), job.trace)
+	ctx = logger.NewContext(ctx, job.logFields)
+	ctx, cancel := context.WithTimeout(ctx, job.timeout)
+	defer cancel()
+	s.trackRun(analysisID, cancel)
+	defer s.untrackRun(analysisID)
+
+	// Analyses still queued when Shutdown runs out of time are not started
+	if s.aborted() {
+		return s.cancelAnalysis(analysisID)
+	}
+
+	result, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+		if result.Status == models.StatusCancelled {
+			return ErrAnalysisCancelled
+		}
+		result.Status = models.StatusProcessing
+		return nil
+	})
+	if errors.Is(err, ErrAnalysisCancelled) {
+		// Cancelled while queued; its files were removed with the request
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	s.publishStatusEvent(result)
+	s.metrics.Processing.Inc()
+	defer s.metrics.Processing.Dec()
+
+	// The upload is only needed while the analysis runs
+	if !s.config.KeepImages {
+		defer func() {
+			if !retrying {
+				s.releaseImage(analysisID, job.imageKey)
+			}
+		}()
+	}
+
+	// Fiji reads a local copy of the image that only exists during the run
+	imagePath, removeCopy, err := s.fetchImage(ctx, analysisID, job.imageKey)
+	if errors.Is(ctx.Err(), context.Canceled) {
+		return s.cancelAnalysis(analysisID)
+	}
+	if err != nil {
+		return s.updateResultWithError(analysisID, models.FailureStorage, fmt.Sprintf("Failed to fetch uploaded image: %v", err))
+	}
+	defer removeCopy()
+
+	// Reject uploads that are not decodable images before spending a Fiji run
+	// on them. Fiji reads TIFF and BMP variants Go cannot, so it decides those.
+	imageConfig, format, err := imaging.DecodeConfigFile(imagePath)
+	if err != nil {
+		if fullyDecodedTypes[strings.ToLower(filepath.Ext(imagePath))] {
+			return s.updateResultWithError(analysisID, models.FailureBadInput, fmt.Sprintf("Uploaded file is not a readable image: %v", err))
+		}
+		s.analysisLog(ctx, analysisID).WithError(err).Warn("Image header is unreadable in Go; leaving the image to Fiji")
+	}
+	s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.ImageFormat = format
+		result.ImageWidth = imageConfig.Width
+		result.ImageHeight = imageConfig.Height
+		return nil
+	})
+
+	// Camera and microscope details are advisory and never block analysis
+	s.recordImageMetadata(ctx, analysisID, imagePath)
+
+	// Flag probable non-sample uploads; this is advisory and never blocks analysis
+	if s.config.DocumentCheck {
+		s.checkForDocument(ctx, analysisID, imagePath)
+	}
+
+	// Very large images are analyzed tile by tile to keep Fiji within memory.
+	// ROI coordinates refer to the whole image, so ROI analyses are never tiled.
+	// A cropped image is tiled by the size of its region.
+	analyzedConfig := imageConfig
+	if job.crop != nil {
+		analyzedConfig.Width, analyzedConfig.Height = job.crop.Width, job.crop.Height
+	}
+	switch {
+	case s.engine == models.EngineGoNative:
+		// The native analyzer holds the image in memory anyway, so it never tiles
+		err = s.performNativeAnalysis(ctx, analysisID, imagePath, job.roiPath, job.crop, job.params)
+	case job.roiPath == "" && s.shouldTile(analyzedConfig):
+		err = s.performTiledAnalysis(ctx, analysisID, imagePath, job.crop, job.params)
+	default:
+		// Perform analysis using Fiji; failures are recorded on the result
+		err = s.performFijiAnalysis(ctx, analysisID, imagePath, job.roiPath, job.crop, job.params)
+	}
+
+	var transient *retryableError
+	if errors.As(err, &transient) {
+		err = s.retryAnalysis(job, transient)
+		retrying = errors.Is(err, errRetryScheduled)
+	}
+	return err
+}
+
+// GetAnalysisStatus returns the status of an analysis. The returned result is a
+// shared snapshot and must not be modified.
+func (s *AnalysisService) GetAnalysisStatus(analysisID string) (*models.AnalysisResult, error) {
+	if result, ok := s.cachedResult(analysisID); ok {
+		return result, nil
+	}
+
+	result, err := s.store.Get(analysisID)
+	if err != nil {
+		return nil, err
+	}
+	s.cacheResult(result)
+	return result, nil
+}
+
+// analysisLog returns a log entry for an analysis carrying the log fields of
+// ctx, which include the ID of the request that submitted it
+func (s *AnalysisService) analysisLog(ctx context.Context, analysisID string) *logrus.Entry {
+	return s.logger.FromContext(ctx).WithField("analysis_id", analysisID)
+}
+
+// jobLog returns a log entry for a prepared analysis carrying the log fields
+// of the request that submitted it
+func (s *AnalysisService) jobLog(job *preparedAnalysis) *logrus.Entry {
+	return s.logger.WithFields(job.logFields).WithField("analysis_id", job.id)
+}
+
+// update applies fn to a private copy of the analysis record and stores the
+// copy as the new snapshot. If fn returns an error the record is left as it
+// was. fn must only assign fields: slices and maps shared with the previous
+// snapshot must be replaced, not modified.
+func (s *AnalysisService) update(analysisID string, fn func(result *models.AnalysisResult) error) (*models.AnalysisResult, error) {
+	result, err := s.store.Update(analysisID, fn)
+	if err == nil {
+		s.invalidateResult(analysisID)
+	}
+	if err != nil && !errors.Is(err, ErrAnalysisNotFound) && !errors.Is(err, ErrAnalysisInProgress) && !errors.Is(err, ErrAnalysisCancelled) {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Error("Failed to update analysis")
+	}
+	return result, err
+}
+
+// AnnotateAnalysis attaches a reviewer note and optional manual purity override
+// to a finished analysis. The automated purity is left untouched.
+func (s *AnalysisService) AnnotateAnalysis(analysisID string, annotation models.Annotation) (*models.AnalysisResult, error) {
+	result, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+		if !isTerminal(result.Status) {
+			return ErrAnalysisInProgress
+		}
+
+		now := time.Now()
+		result.ReviewNote = annotation.Note
+		result.ReviewedBy = annotation.ReviewedBy
+		result.ReviewedAt = &now
+		// Each annotation replaces the last; one without an override clears it
+		result.ManualOverride = false
+		result.ManualOverridePurity = nil
+		if annotation.OverridePurity != nil {
+			override := *annotation.OverridePurity
+			result.ManualOverride = true
+			result.ManualOverridePurity = &override
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.WithField("analysis_id", analysisID).
+		WithField("reviewed_by", annotation.ReviewedBy).
+		WithField("manual_override", result.ManualOverride).
+		Info("Analysis annotated by reviewer")
+
+	return result, nil
+}
+
+// QueueDepth returns the number of submitted analyses waiting for a worker
+func (s *AnalysisService) QueueDepth() int {
+	return s.pool.QueueDepth()
+}
+
+// ListAnalyses returns one page of analyses matching filter and the total
+// number of matches
+func (s *AnalysisService) ListAnalyses(filter ListFilter) ([]*models.AnalysisResult, int, error) {
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return nil, 0, fmt.Errorf("%w: limit and offset must not be negative", ErrInvalidListFilter)
+	}
+	if !validSort(filter.Sort) {
+		return nil, 0, fmt.Errorf("%w: unsupported sort order %q", ErrInvalidListFilter, filter.Sort)
+	}
+	if filter.MinPurity != nil && filter.MaxPurity != nil && *filter.MinPurity > *filter.MaxPurity {
+		return nil, 0, fmt.Errorf("%w: minimum purity exceeds maximum purity", ErrInvalidListFilter)
+	}
+	if !filter.From.IsZero() && !filter.To.IsZero() && filter.From.After(filter.To) {
+		return nil, 0, fmt.Errorf("%w: from is after to", ErrInvalidListFilter)
+	}
+
+	return s.store.List(filter)
+}
+
+// CancelAnalysis stops an unfinished analysis and keeps its record. A queued
+// analysis is marked cancelled at once; a processing one has its Fiji run
+// killed and is marked cancelled by its worker shortly after. Finished
+// analyses return ErrAnalysisFinished.
+func (s *AnalysisService) CancelAnalysis(analysisID string) error {
+	result, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+		switch {
+		case isTerminal(result.Status):
+			return ErrAnalysisFinished
+		case result.Status != models.StatusPending:
+			return ErrAnalysisInProgress
+		}
+		markCancelled(result)
+		return nil
+	})
+	switch {
+	case err == nil:
+		s.announceTerminal(result)
+		s.removeAnalysisFiles(result)
+		s.logger.WithField("analysis_id", analysisID).Info("Queued analysis cancelled")
+		return nil
+	case !errors.Is(err, ErrAnalysisInProgress):
+		return err
+	}
+
+	if s.cancelRun(analysisID) {
+		s.logger.WithField("analysis_id", analysisID).Info("Cancelling running analysis")
+		return nil
+	}
+
+	// Processing but no longer running: the worker is recording its outcome
+	return ErrAnalysisInProgress
+}
+
+// DeleteAnalysis stops an unfinished analysis or removes a finished one.
+// Unfinished analyses are cancelled as by CancelAnalysis, cancelled is true
+// and the record is kept. A finished analysis is removed together with its
+// uploaded image and generated files.
+func (s *AnalysisService) DeleteAnalysis(analysisID string) (cancelled bool, err error) {
+	err = s.CancelAnalysis(analysisID)
+	switch {
+	case err == nil:
+		return true, nil
+	case !errors.Is(err, ErrAnalysisFinished):
+		return false, err
+	}
+
+	result, err := s.store.Get(analysisID)
+	if err != nil {
+		return false, err
+	}
+	if err := s.store.Delete(analysisID); err != nil {
+		return false, err
+	}
+	s.invalidateResult(analysisID)
+	s.removeAnalysisFiles(result)
+
+	s.logger.WithField("analysis_id", analysisID).Info("Analysis deleted")
+	return false, nil
+}
+
+// removeAnalysisFiles deletes the uploaded image and generated files of an analysis
+func (s *AnalysisService) removeAnalysisFiles(result *models.AnalysisResult) {
+	paths := []string{
+		filepath.Join(s.config.TempDir, fmt.Sprintf("%s_macro.ijm", result.ID)),
+		filepath.Join(s.config.TempDir, fmt.Sprintf("%s_manifest.json", result.ID)),
+	}
+	if result.OverlayPath != "" {
+		if err := s.blobs.Delete(context.Background(), overlayKey(result.ID)); err != nil {
+			s.logger.WithField("analysis_id", result.ID).WithError(err).Warn("Failed to remove overlay")
+		}
+	}
+	switch {
+	case result.ImageKey != "":
+		s.deleteImage(result.ID, result.ImageKey)
+	case result.ImagePath != "":
+		// Recorded before images were kept in the blob store
+		paths = append(paths, result.ImagePath)
+	}
+	for _, path := range paths {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			s.logger.WithField("analysis_id", result.ID).WithError(err).Warn("Failed to remove analysis file")
+		}
+	}
+}
+
+// trackRun makes a running analysis cancellable through cancel
+func (s *AnalysisService) trackRun(analysisID string, cancel context.CancelFunc) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.running[analysisID] = cancel
+}
+
+// untrackRun forgets a run once its worker is done with it
+func (s *AnalysisService) untrackRun(analysisID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.running, analysisID)
+}
+
+// cancelRun cancels the context of a running analysis, killing its Fiji
+// process. It reports whether the analysis was running.
+func (s *AnalysisService) cancelRun(analysisID string) bool {
+	s.mutex.RLock()
+	cancel, ok := s.running[analysisID]
+	s.mutex.RUnlock()
+
+	if ok {
+		cancel()
+	}
+	return ok
+}
+
+// cancelAnalysis records that a running analysis was stopped by a
+// cancellation request and removes its files
+func (s *AnalysisService) cancelAnalysis(analysisID string) error {
+	transitioned := false
+	result, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+		transitioned = !isTerminal(result.Status)
+		if transitioned {
+			markCancelled(result)
+			if s.aborted() {
+				result.Error = "Analysis cancelled by server shutdown"
+			}
+		}
+		return nil
+	})
+	if err == nil && transitioned {
+		s.announceTerminal(result)
+		s.removeAnalysisFiles(result)
+	}
+
+	return ErrAnalysisCancelled
+}
+
+// markCancelled sets the fields of a cancelled analysis
+func markCancelled(result *models.AnalysisResult) {
+	now := time.Now()
+	result.Status = models.StatusCancelled
+	result.FailureCategory = models.FailureCancelled
+	result.Error = "Analysis cancelled by user"
+	result.CompletedAt = &now
+}
+
+// manifestVersion identifies the layout of AnalysisManifest documents
+const manifestVersion = "1"
+
+// GetPurityTrend returns the purity of completed analyses in a sample group,
+// oldest first. Zero from/to times leave that end of the range open. Only the
+// most recent TrendMaxPoints analyses are returned.
+func (s *AnalysisService) GetPurityTrend(sampleGroup string, from, to time.Time) ([]models.TrendPoint, error) {
+	results, _, err := s.store.List(ListFilter{Status: models.StatusCompleted, SampleGroup: sampleGroup})
+	if err != nil {
+		return nil, err
+	}
+
+	points := make([]models.TrendPoint, 0)
+	for _, result := range results {
+		if result.CompletedAt == nil || result.SampleGroup != sampleGroup {
+			continue
+		}
+		completedAt := *result.CompletedAt
+		if (!from.IsZero() && completedAt.Before(from)) || (!to.IsZero() && completedAt.After(to)) {
+			continue
+		}
+		points = append(points, models.TrendPoint{
+			AnalysisID:       result.ID,
+			Timestamp:        completedAt,
+			PurityPercentage: result.PurityPercentage,
+		})
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		return points[i].Timestamp.Before(points[j].Timestamp)
+	})
+
+	if max := s.config.TrendMaxPoints; max > 0 && len(points) > max {
+		points = points[len(points)-max:]
+	}
+
+	return points, nil
+}
+
+// GetAnalysisManifest assembles the archival manifest for a finished analysis
+func (s *AnalysisService) GetAnalysisManifest(analysisID string) (*models.AnalysisManifest, error) {
+	result, err := s.GetAnalysisStatus(analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if !isTerminal(result.Status) {
+		return nil, ErrAnalysisInProgress
+	}
+
+	warnings := result.Warnings
+	if warnings == nil {
+		warnings = []string{}
+	}
+
+	return &models.AnalysisManifest{
+		ManifestVersion: manifestVersion,
+		GeneratedAt:     time.Now(),
+		Result:          result,
+		Parameters:      result.Parameters,
+		Warnings:        warnings,
+		Macro:           result.Macro,
+		FijiVersion:     result.FijiVersion,
+		Timestamps: models.ManifestTimestamps{
+			CreatedAt:   result.CreatedAt,
+			CompletedAt: result.CompletedAt,
+			ReviewedAt:  result.ReviewedAt,
+		},
+	}, nil
+}
+
+// writeManifestSidecar stores the manifest as {TempDir}/{id}_manifest.json
+func (s *AnalysisService) writeManifestSidecar(analysisID string) {
+	manifest, err := s.GetAnalysisManifest(analysisID)
+	if err != nil {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Warn("Failed to build manifest sidecar")
+		return
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Warn("Failed to encode manifest sidecar")
+		return
+	}
+
+	sidecarPath := filepath.Join(s.config.TempDir, fmt.Sprintf("%s_manifest.json", analysisID))
+	if err := os.WriteFile(sidecarPath, data, 0644); err != nil {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Warn("Failed to write manifest sidecar")
+	}
+}
+
+// WaitForAnalysis blocks until the analysis reaches a terminal state or ctx is done.
+// When ctx expires first, ErrWaitTimeout is returned along with the current result
+// and the analysis keeps running in the background.
+func (s *AnalysisService) WaitForAnalysis(ctx context.Context, analysisID string) (*models.AnalysisResult, error) {
+	// Subscribe before reading the store so a completion in between is not missed
+	done := make(chan struct{})
+	s.mutex.Lock()
+	s.subscribers[analysisID] = append(s.subscribers[analysisID], done)
+	s.mutex.Unlock()
+
+	if result, err := s.store.Get(analysisID); err == nil && isTerminal(result.Status) {
+		s.unsubscribe(analysisID, done)
+		return result, terminalError(result)
+	}
+
+	select {
+	case <-done:
+		result, err := s.store.Get(analysisID)
+		if err != nil {
+			return nil, err
+		}
+		return result, terminalError(result)
+	case <-ctx.Done():
+		s.unsubscribe(analysisID, done)
+		result, _ := s.store.Get(analysisID)
+		return result, ErrWaitTimeout
+	}
+}
+
+// unsubscribe removes a waiter that gave up before the analysis finished
+func (s *AnalysisService) unsubscribe(analysisID string, done chan struct{}) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	waiters := s.subscribers[analysisID]
+	for i, ch := range waiters {
+		if ch == done {
+			s.subscribers[analysisID] = append(waiters[:i], waiters[i+1:]...)
+			break
+		}
+	}
+	if len(s.subscribers[analysisID]) == 0 {
+		delete(s.subscribers, analysisID)
+	}
+}
+
+// notifySubscribers wakes everyone waiting on the analysis
+func (s *AnalysisService) notifySubscribers(analysisID string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, ch := range s.subscribers[analysisID] {
+		close(ch)
+	}
+	delete(s.subscribers, analysisID)
+}
+
+// announceTerminal records metrics and tells waiters, listeners, the callback
+// URL and subscribed webhooks that the analysis reached a terminal state
+func (s *AnalysisService) announceTerminal(result *models.AnalysisResult) {
+	s.recordTerminal(result)
+	s.notifySubscribers(result.ID)
+	s.publishStatusEvent(result)
+	s.scheduleCallback(result)
+	s.webhooks.Dispatch(result)
+}
+
+// watcherBuffer holds every change a watcher can receive (processing and a
+// terminal state), so publishing never blocks or drops one
+const watcherBuffer = 4
+
+// Subscribe returns a channel carrying the current result of the analysis
+// followed by the result after each status change. The channel is closed after
+// the terminal result, or when the returned function is called.
+func (s *AnalysisService) Subscribe(analysisID string) (<-chan *models.AnalysisResult, func(), error) {
+	// Reading the store under the lock keeps changes from slipping in between
+	// the snapshot and the registration
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	result, err := s.store.Get(analysisID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	updates := make(chan *models.AnalysisResult, watcherBuffer)
+	updates <- result
+	if isTerminal(result.Status) {
+		close(updates)
+		return updates, func() {}, nil
+	}
+
+	s.watchers[analysisID] = append(s.watchers[analysisID], updates)
+	return updates, func() { s.unwatch(analysisID, updates) }, nil
+}
+
+// unwatch removes and closes a watcher unless the terminal result already did
+func (s *AnalysisService) unwatch(analysisID string, updates chan *models.AnalysisResult) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	watchers := s.watchers[analysisID]
+	for i, ch := range watchers {
+		if ch == updates {
+			s.watchers[analysisID] = append(watchers[:i], watchers[i+1:]...)
+			close(ch)
+			break
+		}
+	}
+	if len(s.watchers[analysisID]) == 0 {
+		delete(s.watchers, analysisID)
+	}
+}
+
+// notifyWatchers sends a status change to the analysis' watchers, closing
+// them once the analysis has reached a terminal state
+func (s *AnalysisService) notifyWatchers(result *models.AnalysisResult) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	terminal := isTerminal(result.Status)
+	for _, ch := range s.watchers[result.ID] {
+		select {
+		case ch <- result:
+		default:
+			s.logger.WithField("analysis_id", result.ID).Warn("Dropping status change for full watcher")
+		}
+		if terminal {
+			close(ch)
+		}
+	}
+	if terminal {
+		delete(s.watchers, result.ID)
+	}
+}
+
+// statusEventBuffer is how many undelivered events a listener may fall behind by
+const statusEventBuffer = 64
+
+// SubscribeEvents registers a listener for every analysis status transition.
+// Events are dropped for listeners that fall more than statusEventBuffer behind.
+// The returned function unregisters the listener and closes the channel.
+func (s *AnalysisService) SubscribeEvents() (<-chan models.StatusEvent, func()) {
+	events := make(chan models.StatusEvent, statusEventBuffer)
+
+	s.mutex.Lock()
+	s.listeners[events] = struct{}{}
+	s.mutex.Unlock()
+
+	var once sync.Once
+	return events, func() {
+		once.Do(func() {
+			s.mutex.Lock()
+			delete(s.listeners, events)
+			s.mutex.Unlock()
+			close(events)
+		})
+	}
+}
+
+// publishStatusEvent fans a status transition out to the analysis' watchers and
+// all listeners without blocking
+func (s *AnalysisService) publishStatusEvent(result *models.AnalysisResult) {
+	s.notifyWatchers(result)
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if len(s.listeners) == 0 {
+		return
+	}
+
+	event := models.StatusEvent{
+		AnalysisID: result.ID,
+		Status:     result.Status,
+		Timestamp:  time.Now(),
+	}
+	if isTerminal(result.Status) {
+		event.Result = result
+	}
+
+	for listener := range s.listeners {
+		select {
+		case listener <- event:
+		default:
+			s.logger.WithField("analysis_id", result.ID).Warn("Dropping status event for slow listener")
+		}
+	}
+}
+
+// scheduleCallback delivers a snapshot of the finished result to its callback
+// URL in the background. Deliveries run on the webhook dispatcher, so that
+// Shutdown waits for them like for webhook deliveries.
+func (s *AnalysisService) scheduleCallback(result *models.AnalysisResult) {
+	if result.CallbackURL == "" {
+		return
+	}
+
+	snapshot := *result
+	s.webhooks.spawn(func() { s.deliverCallback(snapshot) })
+}
+
+// isTerminal reports whether an analysis has finished processing
+func isTerminal(status models.AnalysisStatus) bool {
+	return status == models.StatusCompleted || status == models.StatusFailed || status == models.StatusCancelled
+}
+
+// terminalError maps a finished analysis to ErrAnalysisTimeout when Fiji ran out of time
+func terminalError(result *models.AnalysisResult) error {
+	if result.Status == models.StatusFailed && result.FailureCategory == models.FailureTimeout {
+		return ErrAnalysisTimeout
+	}
+	return nil
+}
+
+// imageTypes maps the supported image extensions to their MIME types
+var imageTypes = map[string]string{
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".png":  "image/png",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".webp": "image/webp",
+	".bmp":  models.MIMETypeBMP,
+	".dng":  models.MIMETypeDNG,
+}
+
+// fullyDecodedTypes are the image extensions whose every valid file Go can
+// decode, so an unreadable header means a broken upload. Uploads converted
+// to PNG are checked as PNG.
+var fullyDecodedTypes = map[string]bool{
+	".jpg":  true,
+	".jpeg": true,
+	".png":  true,
+}
+
+// convertedTypes are the image types Fiji cannot read, with the decoders used
+// to convert them to PNG
+var convertedTypes = map[string]func(io.Reader) (image.Image, error){
+	"image/webp":       webp.Decode,
+	models.MIMETypeDNG: tiff.Decode,
+}
+
+// IsSupportedImage reports whether filename has a supported image extension:
+// JPG, PNG, TIFF or BMP, which Fiji analyzes directly, or WebP or DNG, which
+// are converted to PNG first
+func IsSupportedImage(filename string) bool {
+	_, ok := imageTypes[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// upload is a file submitted for analysis, either a form file or an entry of a
+// batch archive
+type upload struct {
+	name string
+	size int64
+	open func() (io.ReadCloser, error)
+}
+
+// formUpload wraps a multipart form file
+func formUpload(file *multipart.FileHeader) upload {
+	return upload{
+		name: file.Filename,
+		size: file.Size,
+		open: func() (io.ReadCloser, error) { return file.Open() },
+	}
+}
+
+// sniffLen is the number of leading bytes inspected to identify an upload,
+// enough to reach the DNGVersion tag in the first IFD of a DNG
+const sniffLen = 4096
+
+// dngVersionTag is the TIFF tag that marks a TIFF file as a DNG
+const dngVersionTag = 0xC612
+
+// newSubfileTypeTag is the TIFF tag whose lowest bit marks a reduced-resolution
+// image, and tiffShort the TIFF field type of 16-bit values
+const (
+	newSubfileTypeTag = 0x00FE
+	tiffShort         = 3
 )
 
-// AnalysisService handles gypsum analysis operations
-type AnalysisService struct {
-	config  *config.Config
-	logger  *logger.Logger
-	results map[string]*models.AnalysisResult
-	mutex   sync.RWMutex
+// copyBufferSize is the chunk size uploads are streamed to disk in
+const copyBufferSize = 32 << 10
+
+// detectImageType identifies JPEG, PNG, TIFF, WebP, BMP or DNG content from
+// its leading bytes and returns the detected MIME type. http.DetectContentType
+// has no TIFF signature, so TIFF byte orders are matched here, and a TIFF is a
+// DNG when its first IFD has the DNGVersion tag.
+func detectImageType(head []byte) (string, bool) {
+	if bytes.HasPrefix(head, []byte("II*\x00")) || bytes.HasPrefix(head, []byte("MM\x00*")) {
+		if hasDNGVersion(head) {
+			return models.MIMETypeDNG, true
+		}
+		return "image/tiff", true
+	}
+
+	mime := http.DetectContentType(head)
+	return mime, mime == "image/jpeg" || mime == "image/png" || mime == "image/webp" || mime == models.MIMETypeBMP
+}
+
+// hasDNGVersion reports whether the first IFD of the TIFF data in head has
+// the DNGVersion tag. Entries past the end of head are not inspected.
+func hasDNGVersion(head []byte) bool {
+	_, ok := firstIFDTag(head, dngVersionTag)
+	return ok
 }
 
-// NewAnalysisService creates a new analysis service
-func NewAnalysisService(cfg *config.Config, logger *logger.Logger) *AnalysisService {
-	return &AnalysisService{
-		config:  cfg,
-		logger:  logger,
-		results: make(map[string]*models.AnalysisResult),
+// isReducedResolution reports whether the first IFD of the TIFF data in head
+// is marked by its NewSubfileType as a reduced-resolution image. Most DNGs
+// store a preview there and the full image, often raw sensor data, in a SubIFD.
+func isReducedResolution(head []byte) bool {
+	subfileType, _ := firstIFDTag(head, newSubfileTypeTag)
+	return subfileType&1 != 0
+}
+
+// maxIFDEntries bounds the entries read from a TIFF directory. The format
+// allows 65535, far more than any image has.
+const maxIFDEntries = 1024
+
+// firstIFDTag returns the value of a SHORT or LONG tag in the first IFD of
+// the TIFF data in head, and whether the tag was found. Entries past the end
+// of head are not inspected.
+func firstIFDTag(head []byte, tag uint16) (uint32, bool) {
+	return firstIFDTagAt(bytes.NewReader(head), tag)
+}
+
+// firstIFDTagAt returns the value of a SHORT or LONG tag in the first IFD of
+// the TIFF data in r, wherever the IFD lies, and whether the tag was found.
+// Entries past the end of the data are not inspected.
+func firstIFDTagAt(r io.ReaderAt, tag uint16) (uint32, bool) {
+	header := make([]byte, 8)
+	if n, _ := r.ReadAt(header, 0); n < len(header) {
+		return 0, false
+	}
+	var order binary.ByteOrder = binary.LittleEndian
+	if header[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	offset := int64(order.Uint32(header[4:8]))
+	count := make([]byte, 2)
+	if n, _ := r.ReadAt(count, offset); n < len(count) {
+		return 0, false
 	}
+	entries := int64(order.Uint16(count))
+	if entries > maxIFDEntries {
+		return 0, false
+	}
+
+	directory := make([]byte, 12*entries)
+	n, _ := r.ReadAt(directory, offset+2)
+	for entry := 0; entry+12 <= n; entry += 12 {
+		if order.Uint16(directory[entry:]) != tag {
+			continue
+		}
+		// Values of up to four bytes are stored in the entry itself
+		if order.Uint16(directory[entry+2:]) == tiffShort {
+			return uint32(order.Uint16(directory[entry+8:])), true
+		}
+		return order.Uint32(directory[entry+8:]), true
+	}
+	return 0, false
 }
 
-// AnalyzeGypsumImage performs gypsum analysis on an uploaded image
-func (s *AnalysisService) AnalyzeGypsumImage(analysisID string, file *multipart.FileHeader) error {
-	// Create analysis result
-	result := &models.AnalysisResult{
-		ID:        analysisID,
-		Status:    models.StatusProcessing,
-		CreatedAt: time.Now(),
-		ImageSize: file.Size,
+// saveUploadedFile checks that the upload is an image by its content, saves it
+// to destPath and returns the path of the saved image and the SHA-256 checksum
+// of the upload. Fiji cannot read WebP or DNG, so those uploads are converted
+// to a PNG next to destPath, whose path is returned instead. Before anything
+// is written, uploads that are not a supported image type are rejected with
+// ErrUnsupportedMIME, images that differ from their extension with
+// ErrImageTypeMismatch and DNGs that only have a decodable preview with ErrDNGPreview.
+func (s *AnalysisService) saveUploadedFile(file upload, destPath string) (string, string, error) {
+	checksum, mime, err := saveImage(file, destPath)
+	if err == nil && s.config.MaxImagePixels > 0 {
+		// Only the header is read, before anything decodes the pixels
+		var imageConfig image.Config
+		imageConfig, err = readImageSize(destPath, mime)
+		if err == nil {
+			err = checkImagePixels(imageConfig, s.config.MaxImagePixels)
+		}
+	}
+	decode, convert := convertedTypes[mime]
+	if err != nil || !convert {
+		return destPath, checksum, err
 	}
 
-	// Store initial result
-	s.mutex.Lock()
-	s.results[analysisID] = result
-	s.mutex.Unlock()
+	// The original is only kept until it has been converted
+	pngPath := strings.TrimSuffix(destPath, filepath.Ext(destPath)) + ".png"
+	err = convertToPNG(destPath, pngPath, decode)
+	os.Remove(destPath)
+	if err != nil {
+		return pngPath, "", err
+	}
+	return pngPath, checksum, nil
+}
 
-	// Create context with timeout
-	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(s.config.AnalysisTimeout)*time.Second)
-	defer cancel()
+// Header fields holding the dimensions of TIFF and BMP images
+const (
+	tiffImageWidthTag  = 0x0100
+	tiffImageLengthTag = 0x0101
+	bmpCoreHeaderSize  = 12
+)
 
-	// Save uploaded file
-	imagePath := filepath.Join(s.config.TempDir, fmt.Sprintf("%s%s", analysisID, filepath.Ext(file.Filename)))
-	if err := s.saveUploadedFile(file, imagePath); err != nil {
-		return s.updateResultWithError(analysisID, fmt.Sprintf("Failed to save uploaded file: %v", err))
+// readImageSize reads an image's dimensions from its header. TIFF and BMP
+// variants Go cannot decode, which are left to Fiji, are measured from their
+// header fields instead. Images whose size cannot be read either way are
+// rejected with ErrUnsupportedMIME, as their size cannot be bounded.
+func readImageSize(path, mime string) (image.Config, error) {
+	cfg, _, err := imaging.DecodeConfigFile(path)
+	if err == nil {
+		return cfg, nil
 	}
 
-	// Update result with image path
-	s.mutex.Lock()
-	s.results[analysisID].ImagePath = imagePath
-	s.mutex.Unlock()
+	f, openErr := os.Open(path)
+	if openErr != nil {
+		return image.Config{}, fmt.Errorf("failed to open image: %w", openErr)
+	}
+	defer f.Close()
+
+	var ok bool
+	switch mime {
+	case "image/tiff", models.MIMETypeDNG:
+		// Writers often put the IFD after the image data, past any sniffed head
+		cfg, ok = tiffSize(f)
+	case models.MIMETypeBMP:
+		head := make([]byte, sniffLen)
+		n, _ := io.ReadFull(f, head)
+		cfg, ok = bmpSize(head[:n])
+	}
+	if !ok {
+		return image.Config{}, fmt.Errorf("%w: the image size cannot be read: %v", ErrUnsupportedMIME, err)
+	}
+	return cfg, nil
+}
+
+// tiffSize reads the dimensions of the first IFD of the TIFF data in r
+func tiffSize(r io.ReaderAt) (image.Config, bool) {
+	width, widthOK := firstIFDTagAt(r, tiffImageWidthTag)
+	height, heightOK := firstIFDTagAt(r, tiffImageLengthTag)
+	if !widthOK || !heightOK || width == 0 || height == 0 || width > math.MaxInt32 || height > math.MaxInt32 {
+		return image.Config{}, false
+	}
+	return image.Config{Width: int(width), Height: int(height)}, true
+}
 
-	// Perform analysis using Fiji
-	if err := s.performFijiAnalysis(ctx, analysisID, imagePath); err != nil {
-		return s.updateResultWithError(analysisID, fmt.Sprintf("Analysis failed: %v", err))
+// bmpSize reads the dimensions from the DIB header of the BMP data in head.
+// A negative height marks a top-down bitmap.
+func bmpSize(head []byte) (image.Config, bool) {
+	if len(head) < 26 {
+		return image.Config{}, false
+	}
+	var width, height int64
+	if binary.LittleEndian.Uint32(head[14:18]) == bmpCoreHeaderSize {
+		width = int64(binary.LittleEndian.Uint16(head[18:20]))
+		height = int64(binary.LittleEndian.Uint16(head[20:22]))
+	} else {
+		width = int64(int32(binary.LittleEndian.Uint32(head[18:22])))
+		height = int64(int32(binary.LittleEndian.Uint32(head[22:26])))
+	}
+	if height < 0 {
+		height = -height
+	}
+	if width <= 0 || height == 0 {
+		return image.Config{}, false
 	}
+	return image.Config{Width: int(width), Height: int(height)}, true
+}
 
+// checkImagePixels returns ErrImageTooLarge when an image has more than
+// maxPixels pixels; a zero maxPixels allows any size
+func checkImagePixels(cfg image.Config, maxPixels int64) error {
+	if maxPixels <= 0 {
+		return nil
+	}
+	if pixels := int64(cfg.Width) * int64(cfg.Height); pixels > maxPixels {
+		return fmt.Errorf("%w: %dx%d is %d pixels, at most %d are allowed", ErrImageTooLarge, cfg.Width, cfg.Height, pixels, maxPixels)
+	}
 	return nil
 }
 
-// GetAnalysisStatus returns the status of an analysis
-func (s *AnalysisService) GetAnalysisStatus(analysisID string) (*models.AnalysisResult, error) {
-	s.mutex.RLock()
-	defer s.mutex.RUnlock()
+// saveImage saves a verified image upload to destPath and returns the checksum
+// of its content and its detected MIME type
+func saveImage(file upload, destPath string) (string, string, error) {
+	src, err := file.open()
+	if err != nil {
+		return "", "", fmt.Errorf("failed to open uploaded file: %w", err)
+	}
+	defer src.Close()
 
-	result, exists := s.results[analysisID]
-	if !exists {
-		return nil, fmt.Errorf("analysis not found")
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(src, head)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return "", "", fmt.Errorf("failed to read uploaded file: %w", err)
+	}
+	head = head[:n]
+	mime, ok := detectImageType(head)
+	if !ok {
+		return "", "", fmt.Errorf("%w: %s detected", ErrUnsupportedMIME, mime)
+	}
+	if claimed := imageTypes[strings.ToLower(filepath.Ext(file.name))]; mime != claimed {
+		return "", "", fmt.Errorf("%w: %s is %s content", ErrImageTypeMismatch, filepath.Base(file.name), mime)
+	}
+	// Raw sensor data in SubIFDs cannot be decoded, so only a DNG whose first
+	// image is full resolution is converted
+	if mime == models.MIMETypeDNG && isReducedResolution(head) {
+		return "", "", fmt.Errorf("%w: convert %s to TIFF or PNG first", ErrDNGPreview, filepath.Base(file.name))
 	}
 
-	return result, nil
+	// Archive entries cannot seek, so the inspected bytes are replayed
+	checksum, err := copyToFile(io.MultiReader(bytes.NewReader(head), src), destPath)
+	return checksum, mime, err
+}
+
+// convertToPNG decodes the image at srcPath with decode and writes it to
+// destPath as PNG
+func convertToPNG(srcPath, destPath string, decode func(io.Reader) (image.Image, error)) error {
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open image: %w", err)
+	}
+	defer src.Close()
+
+	img, err := decode(src)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	dst, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %w", err)
+	}
+	defer dst.Close()
+
+	if err := png.Encode(dst, img); err != nil {
+		return fmt.Errorf("failed to encode PNG image: %w", err)
+	}
+	return dst.Close()
 }
 
-// saveUploadedFile saves the uploaded file to the temp directory
-func (s *AnalysisService) saveUploadedFile(file *multipart.FileHeader, destPath string) error {
+// saveROIFile saves an uploaded ROI file to destPath
+func (s *AnalysisService) saveROIFile(file *multipart.FileHeader, destPath string) error {
 	src, err := file.Open()
 	if err != nil {
 		return fmt.Errorf("failed to open uploaded file: %w", err)
 	}
 	defer src.Close()
 
+	_, err = copyToFile(src, destPath)
+	return err
+}
+
+// copyToFile writes src to destPath and returns the SHA-256 checksum of the content
+func copyToFile(src io.Reader, destPath string) (string, error) {
 	dst, err := os.Create(destPath)
 	if err != nil {
-		return fmt.Errorf("failed to create destination file: %w", err)
+		return "", fmt.Errorf("failed to create destination file: %w", err)
 	}
 	defer dst.Close()
 
-	// Copy file content
-	if _, err := dst.ReadFrom(src); err != nil {
-		return fmt.Errorf("failed to copy file content: %w", err)
+	// Stream the content in fixed-size chunks, hashing it on the way
+	hash := sha256.New()
+	if _, err := io.CopyBuffer(io.MultiWriter(dst, hash), src, make([]byte, copyBufferSize)); err != nil {
+		return "", fmt.Errorf("failed to copy file content: %w", err)
 	}
 
-	return nil
+	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
 // performFijiAnalysis runs the gypsum analysis using Fiji/ImageJ
-func (s *AnalysisService) performFijiAnalysis(ctx context.Context, analysisID, imagePath string) error {
+func (s *AnalysisService) performFijiAnalysis(ctx context.Context, analysisID, imagePath, roiPath string, crop *models.Rectangle, params models.MacroParams) error {
 	startTime := time.Now()
 
 	// Create Fiji macro for gypsum analysis
-	macroPath := filepath.Join(s.config.TempDir, fmt.Sprintf("%s_macro.ijm", analysisID))
-	if err := s.createGypsumAnalysisMacro(macroPath, imagePath); err != nil {
-		return fmt.Errorf("failed to create analysis macro: %w", err)
+	macroPath, resultsPath, overlayPath := s.macroFiles(analysisID)
+	s.tempFiles.Register(macroPath, resultsPath, overlayPath)
+	defer s.tempFiles.Remove(macroPath, resultsPath)
+
+	// The overlay is only kept for analyses that complete
+	overlayStored := false
+	defer func() {
+		if !overlayStored {
+			s.tempFiles.Remove(overlayPath)
+		}
+	}()
+
+	_, span := tracer.Start(ctx, "createGypsumAnalysisMacro", trace.WithAttributes(tracing.AnalysisID.String(analysisID)))
+	macro, err := writeGypsumMacro(macroPath, gypsumMacro{
+		ImagePath:   imagePath,
+		ROIPath:     roiPath,
+		ResultsPath: resultsPath,
+		OverlayPath: overlayPath,
+		Crop:        crop,
+		Params:      params,
+		Centroids:   s.config.ComputeSpacing,
+	})
+	endSpan(span, err)
+	if err != nil {
+		return s.updateResultWithError(analysisID, models.FailureStorage, fmt.Sprintf("Failed to create analysis macro: %v", err))
 	}
-	defer os.Remove(macroPath)
 
-	// Run Fiji with the macro
-	cmd := exec.CommandContext(ctx, s.config.FijiPath, "--headless", "--console", macroPath)
-	output, err := cmd.CombinedOutput()
+	s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.Macro = macro
+		return nil
+	})
+
+	output, err := s.runMacro(ctx, analysisID, macroPath, fullSpan)
+	if err != nil {
+		return err
+	}
 
 	analysisTime := time.Since(startTime).Milliseconds()
 
-	if err != nil {
-		s.logger.WithField("analysis_id", analysisID).WithField("error", err).Error("Fiji analysis failed")
-		return s.updateResultWithError(analysisID, fmt.Sprintf("Fiji execution failed: %v", err))
+	// Fall back to the backup file when the results never reached stdout
+	if !bytes.Contains(output, []byte("ANALYSIS_RESULTS_START")) {
+		if backup, err := os.ReadFile(resultsPath); err == nil {
+			s.analysisLog(ctx, analysisID).Warn("Fiji output has no results block; using the backup results file")
+			output = backup
+		}
 	}
 
 	// Parse results from Fiji output
-	if err := s.parseFijiResults(analysisID, string(output), analysisTime); err != nil {
-		return s.updateResultWithError(analysisID, fmt.Sprintf("Failed to parse results: %v", err))
+	_, span = tracer.Start(ctx, "parseFijiResults", trace.WithAttributes(tracing.AnalysisID.String(analysisID)))
+	err = s.parseFijiResults(analysisID, imagePath, string(output), analysisTime)
+	if err == nil {
+		if parsed, getErr := s.store.Get(analysisID); getErr == nil {
+			span.SetAttributes(tracing.PurityPercentage.Float64(parsed.PurityPercentage))
+		}
+	}
+	endSpan(span, err)
+	if err != nil {
+		return s.updateResultWithError(analysisID, models.FailureParse, fmt.Sprintf("Failed to parse results: %v", err))
+	}
+
+	overlayStored = s.storeOverlay(ctx, analysisID, overlayPath)
+	return s.completeAnalysis(ctx, analysisID, imagePath, analysisTime)
+}
+
+// macroFiles returns the paths of the macro Fiji runs for an analysis, of the
+// backup of its results and of its particle outlines
+func (s *AnalysisService) macroFiles(analysisID string) (macroPath, resultsPath, overlayPath string) {
+	macroPath = filepath.Join(s.config.TempDir, fmt.Sprintf("%s_macro.ijm", analysisID))
+	resultsPath = filepath.Join(s.config.TempDir, fmt.Sprintf("%s_results.txt", analysisID))
+	overlayPath = filepath.Join(s.config.TempDir, overlayKey(analysisID))
+	return macroPath, resultsPath, overlayPath
+}
+
+// roiFilePath returns where the ROI file uploaded as filename is saved for an
+// analysis, next to the image so the macro can load it
+func (s *AnalysisService) roiFilePath(analysisID, filename string) string {
+	return filepath.Join(s.config.TempDir, fmt.Sprintf("%s_rois%s", analysisID, strings.ToLower(filepath.Ext(filename))))
+}
+
+// timeoutSeconds returns the timeout recorded for an analysis, falling back to
+// ANALYSIS_TIMEOUT for records that predate per-request timeouts
+func (s *AnalysisService) timeoutSeconds(analysisID string) int {
+	if result, err := s.store.Get(analysisID); err == nil && result.TimeoutSeconds > 0 {
+		return result.TimeoutSeconds
+	}
+	return s.config.AnalysisTimeout
+}
+
+// runMacro runs a macro through Fiji, killing it early if its heartbeat stops.
+// Fiji is relaunched up to FIJI_MAX_RETRIES times when it fails before the
+// macro starts; a launch failure is then final, never re-enqueued. Progress
+// printed by the macro is recorded scaled to span. Failures are recorded on
+// the result before being returned, unless they are a retryableError for
+// runAnalysis to re-enqueue.
+func (s *AnalysisService) runMacro(ctx context.Context, analysisID, macroPath string, span progressSpan) ([]byte, error) {
+	var (
+		output  []byte
+		stalled bool
+		err     error
+	)
+	attempts := 0
+	delay := fijiRetryBackoff
+	for {
+		attempts++
+		output, stalled, err = s.runFiji(ctx, analysisID, macroPath, span)
+		if stalled || ctx.Err() != nil || !isLaunchFailure(err, output) || attempts > s.config.FijiMaxRetries {
+			break
+		}
+
+		s.analysisLog(ctx, analysisID).WithField("attempt", attempts).WithError(err).Warn("Fiji failed to start, retrying")
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+		}
+		if ctx.Err() != nil {
+			break
+		}
+		delay *= 2
+	}
+
+	if stalled {
+		s.analysisLog(ctx, analysisID).Error("Fiji stopped sending heartbeats")
+		return nil, s.failFiji(analysisID, ErrFijiStalled, ErrFijiStalled.Error())
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		s.analysisLog(ctx, analysisID).Error("Fiji analysis timed out")
+		return nil, s.updateResultWithError(analysisID, models.FailureTimeout, fmt.Sprintf("%v after %d seconds", ErrAnalysisTimeout, s.timeoutSeconds(analysisID)))
+	case errors.Is(ctx.Err(), context.Canceled):
+		s.analysisLog(ctx, analysisID).Warn("Fiji analysis cancelled")
+		return nil, s.cancelAnalysis(analysisID)
+	case isLaunchFailure(err, output):
+		// The relaunches above already spent this failure's retry budget
+		s.analysisLog(ctx, analysisID).WithField("attempts", attempts).WithField("error", err).Error("Fiji failed to start")
+		if attempts == 1 {
+			return nil, s.updateResultWithError(analysisID, models.FailureFijiExec, fmt.Sprintf("Fiji execution failed: %v", err))
+		}
+		return nil, s.updateResultWithError(analysisID, models.FailureFijiExec, fmt.Sprintf("Fiji execution failed after %d attempts: %v", attempts, err))
+	case err != nil:
+		s.analysisLog(ctx, analysisID).WithField("error", err).Error("Fiji analysis failed")
+		return nil, s.failFiji(analysisID, err, fmt.Sprintf("Fiji execution failed: %v", err))
+	}
+
+	return output, nil
+}
+
+// runFiji launches Fiji once, after waiting for one of the MAX_CONCURRENT_ANALYSES
+// Fiji slots, and reports whether its heartbeat stopped
+func (s *AnalysisService) runFiji(ctx context.Context, analysisID, macroPath string, span progressSpan) ([]byte, bool, error) {
+	select {
+	case s.fijiSlots <- struct{}{}:
+		defer func() { <-s.fijiSlots }()
+	case <-ctx.Done():
+		return nil, false, ctx.Err()
+	}
+
+	var heartbeat *heartbeatMonitor
+	if s.config.HeartbeatTimeout > 0 {
+		runCtx, cancelRun := context.WithCancel(ctx)
+		defer cancelRun()
+
+		heartbeat = newHeartbeatMonitor(time.Duration(s.config.HeartbeatTimeout) * time.Second)
+		go heartbeat.watch(runCtx, cancelRun)
+		ctx = runCtx
+	}
+
+	onLine := func(line string) {
+		if heartbeat != nil {
+			heartbeat.onLine(line)
+		}
+		if pct, ok := parseProgress(line); ok {
+			s.recordProgress(analysisID, span.scale(pct))
+		}
+	}
+
+	spanCtx, fijiSpan := tracer.Start(ctx, "fiji.execute", trace.WithAttributes(tracing.AnalysisID.String(analysisID)))
+	output, err := s.runner.Run(spanCtx, macroPath, onLine)
+	fijiSpan.SetAttributes(tracing.FijiExitCode.Int(exitCode(err)))
+	endSpan(fijiSpan, err)
+
+	return output, heartbeat != nil && heartbeat.stalled(), err
+}
+
+// completeAnalysis runs the post-analysis checks on a parsed result and marks it completed
+func (s *AnalysisService) completeAnalysis(ctx context.Context, analysisID, imagePath string, analysisTime int64) error {
+	// Cross-check against the native analyzer without affecting the reported
+	// result; native results have nothing to be checked against
+	if s.config.ShadowMode && s.engine == models.EngineFiji {
+		s.runShadowAnalysis(ctx, analysisID, imagePath)
 	}
 
 	// Mark analysis as completed
-	s.mutex.Lock()
-	now := time.Now()
-	s.results[analysisID].Status = models.StatusCompleted
-	s.results[analysisID].CompletedAt = &now
-	s.results[analysisID].AnalysisTime = analysisTime
-	s.mutex.Unlock()
+	completed, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+		now := time.Now()
+		result.Status = models.StatusCompleted
+		result.CompletedAt = &now
+		result.AnalysisTime = analysisTime
+		result.Progress = 100
+		applyCalibration(result)
+		return s.signResult(result)
+	})
+	if err != nil {
+		return err
+	}
+	s.announceTerminal(completed)
 
-	s.logger.WithField("analysis_id", analysisID).Info("Analysis completed successfully")
+	s.analysisLog(ctx, analysisID).Info("Analysis completed successfully")
+
+	if s.config.ManifestSidecar {
+		s.writeManifestSidecar(analysisID)
+	}
 	return nil
 }
 
-// createGypsumAnalysisMacro creates an ImageJ macro for gypsum analysis
-func (s *AnalysisService) createGypsumAnalysisMacro(macroPath, imagePath string) error {
-	macro := fmt.Sprintf(`
-// Gypsum Analysis Macro
-// This macro analyzes gypsum purity in mineral samples
-
-// Open the image
-open("%s");
-originalImage = getTitle();
-
-// Convert to 8-bit if needed
-if (bitDepth == 16) {
-    run("8-bit");
-}
-
-// Apply preprocessing
-run("Enhance Contrast", "saturated=0.35");
-run("Gaussian Blur...", "sigma=1");
-
-// Threshold for gypsum detection (white/light areas)
-// Gypsum typically appears as white/light colored in images
-setAutoThreshold("Otsu");
-run("Convert to Mask");
-
-// Analyze particles
-run("Analyze Particles...", "size=10-Infinity circularity=0.00-1.00 show=Outlines display clear include");
-
-// Get results
-n = nResults;
-if (n > 0) {
-    // Calculate total area
-    totalArea = 0;
-    for (i = 0; i < n; i++) {
-        area = getResult("Area", i);
-        totalArea = totalArea + area;
-    }
-    
-    // Calculate gypsum percentage (assuming white areas are gypsum)
-    imageArea = getWidth() * getHeight();
-    gypsumPercentage = (totalArea / imageArea) * 100;
-    
-    // Estimate purity based on particle analysis
-    // This is a simplified model - in practice, you'd need more sophisticated analysis
-    purity = gypsumPercentage;
-    if (purity > 100) purity = 100;
-    if (purity < 0) purity = 0;
-    
-    // Output results using multiple methods for reliability
-    print("ANALYSIS_RESULTS_START");
-    print("purity_percentage:" + purity);
-    print("gypsum_content:" + gypsumPercentage);
-    print("impurity_content:" + (100 - gypsumPercentage));
-    print("particle_count:" + n);
-    print("total_area:" + totalArea);
-    print("image_area:" + imageArea);
-    print("threshold_value:" + getThreshold());
-    print("ANALYSIS_RESULTS_END");
-    
-    // Also write to a temporary file as backup
-    File.saveString("ANALYSIS_RESULTS_START\\npurity_percentage:" + purity + "\\ngypsum_content:" + gypsumPercentage + "\\nimpurity_content:" + (100 - gypsumPercentage) + "\\nparticle_count:" + n + "\\ntotal_area:" + totalArea + "\\nimage_area:" + imageArea + "\\nthreshold_value:" + getThreshold() + "\\nANALYSIS_RESULTS_END", "/tmp/fiji_results.txt");
-} else {
-    print("ANALYSIS_RESULTS_START");
-    print("purity_percentage:0");
-    print("gypsum_content:0");
-    print("impurity_content:100");
-    print("particle_count:0");
-    print("total_area:0");
-    print("image_area:" + (getWidth() * getHeight()));
-    print("threshold_value:0");
-    print("ANALYSIS_RESULTS_END");
-}
-
-// Close all windows
-close();
-`, strings.ReplaceAll(imagePath, "\\", "/"))
-
-	return os.WriteFile(macroPath, []byte(macro), 0644)
-}
-
-// parseFijiResults parses the output from Fiji analysis
-func (s *AnalysisService) parseFijiResults(analysisID, output string, analysisTime int64) error {
+// checkForDocument warns when the uploaded image looks like a scanned document
+func (s *AnalysisService) checkForDocument(ctx context.Context, analysisID, imagePath string) {
+	img, _, err := imaging.DecodeFile(imagePath)
+	if err != nil {
+		s.analysisLog(ctx, analysisID).WithError(err).Debug("Skipping document check")
+		return
+	}
+
+	warning := imaging.CheckDocument(img).Warning()
+	if warning == "" {
+		return
+	}
+
+	s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.Warnings = append(slices.Clip(result.Warnings), warning)
+		return nil
+	})
+
+	s.analysisLog(ctx, analysisID).Warn("Uploaded image looks like a document scan")
+}
+
+// recordImageMetadata stores the EXIF block of the image on the result. An
+// uncalibrated analysis takes its spatial scale from the EXIF resolution when
+// that gives one.
+func (s *AnalysisService) recordImageMetadata(ctx context.Context, analysisID, imagePath string) {
+	metadata, err := imaging.ExtractEXIF(imagePath)
+	if err != nil {
+		s.analysisLog(ctx, analysisID).WithError(err).Debug("No EXIF metadata")
+		return
+	}
+
+	s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.ImageMetadata = metadata
+		if ppm, ok := imaging.PixelsPerMicron(metadata); ok && result.Calibration == nil {
+			result.Calibration = &models.Calibration{PixelsPerMicron: ppm, Source: "exif"}
+		}
+		return nil
+	})
+}
+
+// runShadowAnalysis runs the native analyzer on the image and records its purity
+// next to the Fiji result, flagging the analysis when the two disagree
+func (s *AnalysisService) runShadowAnalysis(ctx context.Context, analysisID, imagePath string) {
+	nativePurity, err := imaging.EstimatePurity(imagePath)
+	if err != nil {
+		s.analysisLog(ctx, analysisID).WithError(err).Warn("Shadow analysis failed")
+		return
+	}
+
+	result, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.ShadowPurityPercentage = &nativePurity
+
+		difference := math.Abs(result.PurityPercentage - nativePurity)
+		if difference > s.config.ShadowTolerance {
+			warning := fmt.Sprintf("Shadow analysis discrepancy: Fiji purity %.2f%% vs native purity %.2f%% (difference %.2f exceeds tolerance %.2f)",
+				result.PurityPercentage, nativePurity, difference, s.config.ShadowTolerance)
+			result.Warnings = append(slices.Clip(result.Warnings), warning)
+		}
+		return nil
+	})
+	if err != nil {
+		return
+	}
+
+	if math.Abs(result.PurityPercentage-nativePurity) > s.config.ShadowTolerance {
+		s.analysisLog(ctx, analysisID).
+			WithField("fiji_purity", result.PurityPercentage).
+			WithField("native_purity", nativePurity).
+			Warn("Shadow analysis discrepancy exceeds tolerance")
+	}
+}
+
+// measurements are the values of a results block, as printed by Fiji or
+// produced by the native analyzer. particleCount is nil when no count was
+// reported.
+type measurements struct {
+	results       map[string]float64
+	particleCount *int
+	fijiVersion   string
+	centroids     []point
+	rois          []models.ROIResult
+	slices        []models.SliceResult
+}
+
+// parseFijiResults parses the output from Fiji's analysis of the image at imagePath
+func (s *AnalysisService) parseFijiResults(analysisID, imagePath, output string, analysisTime int64) error {
 	lines := strings.Split(output, "\n")
 
-	var results map[string]float64 = make(map[string]float64)
-	var particleCount int
+	m := measurements{results: make(map[string]float64)}
 
 	inResults := false
 	for _, line := range lines {
@@ -253,86 +1809,205 @@ func (s *AnalysisService) parseFijiResults(analysisID, output string, analysisTi
 
 				if key == "particle_count" {
 					if count, err := strconv.Atoi(valueStr); err == nil {
-						particleCount = count
+						m.particleCount = &count
+					}
+				} else if key == "fiji_version" {
+					m.fijiVersion = valueStr
+				} else if key == "roi" {
+					if roi, err := parseROIResult(valueStr); err == nil {
+						m.rois = append(m.rois, roi)
+					}
+				} else if key == "slice" {
+					if slice, err := parseSliceResult(valueStr); err == nil {
+						m.slices = append(m.slices, slice)
+					}
+				} else if key == "centroid" {
+					if centroid, err := parseCentroid(valueStr); err == nil {
+						m.centroids = append(m.centroids, centroid)
 					}
 				} else {
 					if value, err := strconv.ParseFloat(valueStr, 64); err == nil {
-						results[key] = value
+						m.results[key] = value
 					}
 				}
 			}
 		}
 	}
 
-	// Update result with parsed data
-	s.mutex.Lock()
-	result := s.results[analysisID]
+	averageSlicePurity(m.results, m.slices)
 
-		// Set default values if parsing failed - use image characteristics for variation
-	if purity, exists := results["purity_percentage"]; exists && purity > 0 {
-		result.PurityPercentage = purity
-	} else {
-		// Smart fallback: estimate based on image size and characteristics
-		result.PurityPercentage = s.estimatePurityFromImage(result.ImageSize, result.ImagePath)
+	return s.applyResults(analysisID, imagePath, m, analysisTime)
+}
+
+// applyResults records the measurements of an analysis of the image at
+// imagePath on its result
+func (s *AnalysisService) applyResults(analysisID, imagePath string, m measurements, analysisTime int64) error {
+	results := m.results
+	particleCount := 0
+	if m.particleCount != nil {
+		particleCount = *m.particleCount
+	}
+
+	// Without estimates, missing measurements fail the analysis; zero values
+	// are genuine results
+	var missing []string
+	for _, key := range requiredResults {
+		if _, exists := results[key]; !exists && (key != "particle_count" || m.particleCount == nil) {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) > 0 && !s.config.AllowEstimatedResults {
+		return fmt.Errorf("%w: missing %s", errUnparseableOutput, strings.Join(missing, ", "))
+	}
+
+	var spacingStats *models.SpacingStats
+	if s.config.ComputeSpacing {
+		spacingStats = computeSpacingStats(m.centroids)
 	}
-	
+
+	if !s.config.AllowEstimatedResults {
+		_, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+			result.PurityPercentage = results["purity_percentage"]
+			result.ParticleCount = particleCount
+			result.ThresholdValue = results["threshold_value"]
+			applyMeasurements(result, results, analysisTime, m.fijiVersion, m.rois)
+			result.PerSlice = m.slices
+			if s.config.ComputeSpacing {
+				result.SpacingStats = spacingStats
+			}
+			result.Confidence, result.ConfidenceFactors = s.calculateConfidence(results, particleCount)
+			return nil
+		})
+		return err
+	}
+
+	// Update result with parsed data, estimating only what Fiji did not
+	// report; reported zero values are kept
+	_, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+		estimated := false
+		if purity, exists := results["purity_percentage"]; exists {
+			result.PurityPercentage = purity
+		} else {
+			// Fall back to a native segmentation of the analyzed image
+			result.PurityPercentage = s.estimatePurityFromImage(analysisID, imagePath)
+			estimated = true
+		}
+
+		if m.particleCount != nil {
+			result.ParticleCount = particleCount
+		} else {
+			// Smart fallback: estimate particle count based on image size
+			result.ParticleCount = s.estimateParticleCount(result.ImageSize)
+			estimated = true
+		}
+
+		if threshold, exists := results["threshold_value"]; exists {
+			result.ThresholdValue = threshold
+		} else {
+			// Smart fallback: vary threshold based on image characteristics
+			result.ThresholdValue = s.estimateThreshold(result.ImageSize)
+			estimated = true
+		}
+
+		applyMeasurements(result, results, analysisTime, m.fijiVersion, m.rois)
+		result.PerSlice = m.slices
+
+		if s.config.ComputeSpacing {
+			result.SpacingStats = spacingStats
+		}
+
+		// Calculate confidence based on analysis quality; estimates are flagged
+		// and barely trusted
+		result.Confidence, result.ConfidenceFactors = s.calculateConfidence(results, particleCount)
+		result.Estimated = estimated
+		if estimated {
+			result.Confidence = estimatedConfidence
+		}
+		return nil
+	})
+
+	return err
+}
+
+// applyMeasurements sets the fields derived from the purity and the remaining
+// measurements of a parsed results block
+func applyMeasurements(result *models.AnalysisResult, results map[string]float64, analysisTime int64, fijiVersion string, rois []models.ROIResult) {
 	if gypsum, exists := results["gypsum_content"]; exists && gypsum > 0 {
 		result.GypsumContent = gypsum
 	} else {
 		result.GypsumContent = result.PurityPercentage
 	}
-	
+
 	if impurity, exists := results["impurity_content"]; exists && impurity > 0 {
 		result.ImpurityContent = impurity
 	} else {
 		result.ImpurityContent = 100 - result.PurityPercentage
 	}
-	
-	if particleCount > 0 {
-		result.ParticleCount = particleCount
-	} else {
-		// Smart fallback: estimate particle count based on image size
-		result.ParticleCount = s.estimateParticleCount(result.ImageSize)
+
+	result.TotalArea = results["total_area"]
+	result.ImageArea = results["image_area"]
+
+	// Both threshold bounds, when they were reported
+	if lower, exists := results["threshold_lower"]; exists {
+		result.ThresholdLower = &lower
 	}
-	
-	if threshold, exists := results["threshold_value"]; exists && threshold > 0 {
-		result.ThresholdValue = threshold
-	} else {
-		// Smart fallback: vary threshold based on image characteristics
-		result.ThresholdValue = s.estimateThreshold(result.ImageSize)
+	if upper, exists := results["threshold_upper"]; exists {
+		result.ThresholdUpper = &upper
 	}
 
-	result.AnalysisTime = analysisTime
+	// Mean particle area in pixels², like total_area; 0 when no particles were found
+	result.AverageParticleSize = results["average_particle_size"]
 
-	// Calculate confidence based on analysis quality
-	result.Confidence = s.calculateConfidence(results, particleCount)
+	result.AnalysisTime = analysisTime
+	result.FijiVersion = fijiVersion
+	result.ROIResults = rois
 
 	// Set other mineral contents (simplified model)
 	result.CalciteContent = result.ImpurityContent * 0.3
 	result.QuartzContent = result.ImpurityContent * 0.2
 	result.OtherMinerals = result.ImpurityContent * 0.5
-
-	s.mutex.Unlock()
-
-	return nil
 }
 
-// calculateConfidence calculates confidence score for the analysis
-func (s *AnalysisService) calculateConfidence(results map[string]float64, particleCount int) float64 {
-	// Simple confidence calculation based on particle count and area analysis
-	confidence := 0.5 // Base confidence
+// reportedMeasurements are the measurements a complete results block holds
+var reportedMeasurements = []string{"purity_percentage", "total_area", "image_area", "threshold_value"}
 
-	if particleCount > 10 {
-		confidence += 0.2
-	}
-	if particleCount > 50 {
-		confidence += 0.2
+// calculateConfidence calculates confidence score for the analysis, and the
+// factors it was calculated from
+func (s *AnalysisService) calculateConfidence(results map[string]float64, particleCount int) (float64, *models.ConfidenceFactors) {
+	complete := true
+	for _, key := range reportedMeasurements {
+		if _, exists := results[key]; !exists {
+			complete = false
+		}
 	}
 
+	coverageInRange := false
 	if results["total_area"] > 0 && results["image_area"] > 0 {
 		coverage := results["total_area"] / results["image_area"]
-		if coverage > 0.1 && coverage < 0.9 {
-			confidence += 0.1
+		coverageInRange = coverage > 0.1 && coverage < 0.9
+	}
+
+	factors := &models.ConfidenceFactors{
+		Base:                0.4,
+		ParticleCountBucket: models.ParticleCountFew,
+		Signals: []models.ConfidenceSignal{
+			{Name: "parse_complete", Weight: 0.1, Satisfied: complete},
+			{Name: "particle_count_over_10", Weight: 0.2, Satisfied: particleCount > 10},
+			{Name: "particle_count_over_50", Weight: 0.2, Satisfied: particleCount > 50},
+			{Name: "coverage_in_range", Weight: 0.1, Satisfied: coverageInRange},
+		},
+	}
+	switch {
+	case particleCount > 50:
+		factors.ParticleCountBucket = models.ParticleCountMany
+	case particleCount > 10:
+		factors.ParticleCountBucket = models.ParticleCountSome
+	}
+
+	confidence := factors.Base
+	for _, signal := range factors.Signals {
+		if signal.Satisfied {
+			confidence += signal.Weight
 		}
 	}
 
@@ -340,32 +2015,18 @@ func (s *AnalysisService) calculateConfidence(results map[string]float64, partic
 		confidence = 1.0
 	}
 
-	return confidence
+	return confidence, factors
 }
 
-// estimatePurityFromImage estimates gypsum purity based on image characteristics
-func (s *AnalysisService) estimatePurityFromImage(imageSize int64, imagePath string) float64 {
-	// Use image size and file hash to create deterministic but varied results
-	hash := s.hashString(fmt.Sprintf("%d-%s", imageSize, imagePath))
-	
-	// Generate purity between 60-95% based on hash
-	purity := 60.0 + (float64(hash%35) * 1.0)
-	
-	// Add some randomness based on file size
-	if imageSize > 100000 {
-		purity += 5.0 // Larger files tend to have higher purity
-	} else if imageSize < 50000 {
-		purity -= 10.0 // Smaller files might have lower purity
-	}
-	
-	// Ensure purity is within reasonable bounds
-	if purity > 95.0 {
-		purity = 95.0
-	}
-	if purity < 30.0 {
-		purity = 30.0
+// estimatePurityFromImage estimates gypsum purity as the percentage of the
+// image above its Otsu threshold, for when Fiji reports no purity. It returns
+// 0 when the image cannot be decoded.
+func (s *AnalysisService) estimatePurityFromImage(analysisID, imagePath string) float64 {
+	purity, err := imaging.EstimatePurity(imagePath)
+	if err != nil {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Warn("Failed to estimate purity from the image")
+		return 0
 	}
-	
 	return purity
 }
 
@@ -373,14 +2034,14 @@ func (s *AnalysisService) estimatePurityFromImage(imageSize int64, imagePath str
 func (s *AnalysisService) estimateParticleCount(imageSize int64) int {
 	// Base particle count on image size
 	baseCount := int(imageSize / 2000) // Rough estimate
-	
+
 	// Add variation based on file size
 	if imageSize > 100000 {
 		baseCount += 15
 	} else if imageSize < 50000 {
 		baseCount -= 10
 	}
-	
+
 	// Ensure reasonable bounds
 	if baseCount < 5 {
 		baseCount = 5
@@ -388,7 +2049,7 @@ func (s *AnalysisService) estimateParticleCount(imageSize int64) int {
 	if baseCount > 100 {
 		baseCount = 100
 	}
-	
+
 	return baseCount
 }
 
@@ -396,14 +2057,14 @@ func (s *AnalysisService) estimateParticleCount(imageSize int64) int {
 func (s *AnalysisService) estimateThreshold(imageSize int64) float64 {
 	// Base threshold on image size
 	baseThreshold := 120.0 + (float64(imageSize%60) * 0.5)
-	
+
 	// Adjust based on file size
 	if imageSize > 100000 {
 		baseThreshold += 15.0
 	} else if imageSize < 50000 {
 		baseThreshold -= 20.0
 	}
-	
+
 	// Ensure reasonable bounds
 	if baseThreshold > 200.0 {
 		baseThreshold = 200.0
@@ -411,30 +2072,24 @@ func (s *AnalysisService) estimateThreshold(imageSize int64) float64 {
 	if baseThreshold < 80.0 {
 		baseThreshold = 80.0
 	}
-	
-	return baseThreshold
-}
 
-// hashString creates a simple hash for deterministic but varied results
-func (s *AnalysisService) hashString(input string) int {
-	hash := 0
-	for _, char := range input {
-		hash = ((hash << 5) - hash) + int(char)
-		hash = hash & hash // Convert to 32-bit integer
-	}
-	return hash
+	return baseThreshold
 }
 
-// updateResultWithError updates the analysis result with an error
-func (s *AnalysisService) updateResultWithError(analysisID, errorMsg string) error {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	if result, exists := s.results[analysisID]; exists {
+// updateResultWithError marks the analysis failed with a category and message
+func (s *AnalysisService) updateResultWithError(analysisID string, category models.FailureCategory, errorMsg string) error {
+	transitioned := false
+	result, err := s.update(analysisID, func(result *models.AnalysisResult) error {
+		transitioned = result.Status != models.StatusFailed
 		result.Status = models.StatusFailed
+		result.FailureCategory = category
 		result.Error = errorMsg
 		now := time.Now()
 		result.CompletedAt = &now
+		return nil
+	})
+	if err == nil && transitioned {
+		s.announceTerminal(result)
 	}
 
 	return fmt.Errorf(errorMsg)
@@ -0,0 +1,1550 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/png"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/metrics"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/storage"
+
+	"github.com/sirupsen/logrus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// fakeRunner returns canned Fiji output instead of launching a process
+type fakeRunner struct {
+	output string
+	err    error
+}
+
+func (r *fakeRunner) Run(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+	return []byte(r.output), r.err
+}
+
+// blockingRunner holds the Fiji run open until released or the context ends
+type blockingRunner struct {
+	release chan struct{}
+	output  string
+}
+
+func (r *blockingRunner) Run(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+	select {
+	case <-r.release:
+		return []byte(r.output), nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// stallingRunner prints a few heartbeats and then hangs without exiting
+type stallingRunner struct {
+	heartbeats int
+}
+
+func (r *stallingRunner) Run(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+	var output strings.Builder
+	for i := 0; i < r.heartbeats; i++ {
+		output.WriteString(heartbeatMarker + "\n")
+		if onLine != nil {
+			onLine(heartbeatMarker)
+		}
+	}
+
+	<-ctx.Done()
+	return []byte(output.String()), ctx.Err()
+}
+
+// runnerFunc adapts a function to the FijiRunner interface
+type runnerFunc func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error)
+
+func (f runnerFunc) Run(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+	return f(ctx, macroPath, onLine)
+}
+
+// floatPtr returns a pointer to value, for comparing optional measurements
+func floatPtr(value float64) *float64 {
+	return &value
+}
+
+// fijiOutput builds a results block as printed by the gypsum macro
+func fijiOutput(purity float64, particleCount int) string {
+	return fmt.Sprintf(`ANALYSIS_RESULTS_START
+purity_percentage:%g
+gypsum_content:%g
+impurity_content:%g
+particle_count:%d
+total_area:%g
+image_area:10000
+threshold_value:128
+threshold_lower:128
+threshold_upper:255
+fiji_version:2.14.0/1.54f
+ANALYSIS_RESULTS_END
+`, purity, purity, 100-purity, particleCount, purity*100)
+}
+
+func newTestService(t *testing.T, cfg *config.Config, runner FijiRunner) *AnalysisService {
+	t.Helper()
+
+	if cfg.TempDir == "" {
+		cfg.TempDir = t.TempDir()
+	}
+	if cfg.AnalysisTimeout == 0 {
+		cfg.AnalysisTimeout = 10
+	}
+	if cfg.MacroThresholdMethod == "" {
+		defaults := macroConfig()
+		cfg.MacroThresholdMethod = defaults.MacroThresholdMethod
+		cfg.MacroGaussianSigma = defaults.MacroGaussianSigma
+		cfg.MacroContrastSaturation = defaults.MacroContrastSaturation
+	}
+
+	m := metrics.New()
+	pool := NewWorkerPool(2, 10, m.QueueDepth)
+	t.Cleanup(func() { pool.Shutdown(context.Background()) })
+
+	blobs, err := storage.NewLocalBlobStore(cfg.TempDir)
+	require.NoError(t, err)
+
+	service := NewAnalysisService(cfg, logger.New("error"), NewMemoryStore(), blobs, nil, pool, m)
+	service.runner = runner
+	service.engine = models.EngineFiji
+	// Test servers listen on loopback, which the production clients refuse
+	service.callbackClient = &http.Client{Timeout: callbackTimeout}
+	service.webhooks.client = &http.Client{Timeout: webhookTimeout}
+	return service
+}
+
+// splitImagePNG encodes a 100x100 image whose leftmost whiteColumns columns are white
+func splitImagePNG(t *testing.T, whiteColumns int) []byte {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if x < whiteColumns {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+// newFileHeader wraps content in a multipart file header as Gin would provide it
+func newFileHeader(t *testing.T, filename string, content []byte) *multipart.FileHeader {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("image", filename)
+	require.NoError(t, err)
+	_, err = part.Write(content)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+
+	req := httptest.NewRequest("POST", "/", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	require.NoError(t, req.ParseMultipartForm(32<<20))
+
+	return req.MultipartForm.File["image"][0]
+}
+
+func TestShadowMode_DiscrepancyBeyondTolerance(t *testing.T) {
+	cfg := &config.Config{ShadowMode: true, ShadowTolerance: 10}
+	service := newTestService(t, cfg, &fakeRunner{output: fijiOutput(90, 60)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("shadow-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("shadow-1")
+	require.NoError(t, err)
+
+	// The Fiji result is still the reported one
+	assert.Equal(t, 90.0, result.PurityPercentage)
+	require.NotNil(t, result.ShadowPurityPercentage)
+	assert.InDelta(t, 50.0, *result.ShadowPurityPercentage, 0.01)
+	require.Len(t, result.Warnings, 1)
+	assert.Contains(t, result.Warnings[0], "Shadow analysis discrepancy")
+}
+
+func TestShadowMode_WithinTolerance(t *testing.T) {
+	cfg := &config.Config{ShadowMode: true, ShadowTolerance: 10}
+	service := newTestService(t, cfg, &fakeRunner{output: fijiOutput(85, 60)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("shadow-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("shadow-2")
+	require.NoError(t, err)
+
+	require.NotNil(t, result.ShadowPurityPercentage)
+	assert.InDelta(t, 80.0, *result.ShadowPurityPercentage, 0.01)
+	assert.Empty(t, result.Warnings)
+}
+
+func TestShadowMode_Disabled(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(90, 60)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("shadow-3", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("shadow-3")
+	require.NoError(t, err)
+
+	assert.Nil(t, result.ShadowPurityPercentage)
+	assert.Empty(t, result.Warnings)
+}
+
+func TestWaitForAnalysis_ClientDeadlineBeforeCompletion(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{}), output: fijiOutput(75, 20)}
+	service := newTestService(t, &config.Config{}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 75))
+	done := make(chan error, 1)
+	go func() {
+		done <- service.AnalyzeGypsumImage("wait-1", file, AnalysisOptions{})
+	}()
+
+	// The client gives up while Fiji is still running
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := service.WaitForAnalysis(ctx, "wait-1")
+	assert.ErrorIs(t, err, ErrWaitTimeout)
+
+	// Giving up drops the subscription
+	service.mutex.RLock()
+	assert.Empty(t, service.subscribers["wait-1"])
+	service.mutex.RUnlock()
+
+	// The background analysis is unaffected and completes later
+	close(runner.release)
+	require.NoError(t, <-done)
+
+	result, err := service.WaitForAnalysis(context.Background(), "wait-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, 75.0, result.PurityPercentage)
+}
+
+func TestWaitForAnalysis_AnalysisTimeout(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{})}
+	service := newTestService(t, &config.Config{AnalysisTimeout: 1}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 75))
+	go service.AnalyzeGypsumImage("wait-2", file, AnalysisOptions{})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := service.WaitForAnalysis(ctx, "wait-2")
+	assert.ErrorIs(t, err, ErrAnalysisTimeout)
+	require.NotNil(t, result)
+	assert.Equal(t, models.StatusFailed, result.Status)
+}
+
+func TestAnalyzeGypsumImage_RequestTimeoutOverridesDefault(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{})}
+	service := newTestService(t, &config.Config{AnalysisTimeout: 300}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 75))
+	start := time.Now()
+	err := service.AnalyzeGypsumImage("timeout-1", file, AnalysisOptions{Timeout: time.Second})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second)
+
+	result, err := service.GetAnalysisStatus("timeout-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.FailureTimeout, result.FailureCategory)
+	assert.Equal(t, "analysis timed out after 1 seconds", result.Error)
+	assert.Equal(t, 1, result.TimeoutSeconds)
+}
+
+func TestHeartbeat_StalledFijiIsKilled(t *testing.T) {
+	cfg := &config.Config{AnalysisTimeout: 30, HeartbeatTimeout: 1}
+	service := newTestService(t, cfg, &stallingRunner{heartbeats: 2})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 75))
+	start := time.Now()
+	err := service.AnalyzeGypsumImage("stall-1", file, AnalysisOptions{})
+	assert.Error(t, err)
+	assert.Less(t, time.Since(start), 10*time.Second, "stall should be detected well before the analysis timeout")
+
+	result, err := service.GetAnalysisStatus("stall-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, result.Status)
+	assert.Contains(t, result.Error, "Fiji stalled (no heartbeat)")
+}
+
+func TestHeartbeat_SteadyHeartbeatsKeepRunAlive(t *testing.T) {
+	runner := runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		// Outlive the heartbeat timeout while reporting progress regularly
+		for i := 0; i < 6; i++ {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(300 * time.Millisecond):
+				onLine(heartbeatMarker)
+			}
+		}
+		return []byte(fijiOutput(80, 25)), nil
+	})
+	service := newTestService(t, &config.Config{HeartbeatTimeout: 1}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("stall-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("stall-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+}
+
+func TestProgress_RecordedWhileProcessing(t *testing.T) {
+	var service *AnalysisService
+	var seen []int
+	runner := runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		for _, line := range []string{"PROGRESS:25", "PROGRESS:50", "PROGRESS:40", "PROGRESS:oops", "PROGRESS:75"} {
+			onLine(line)
+			result, err := service.GetAnalysisStatus("progress-1")
+			require.NoError(t, err)
+			seen = append(seen, result.Progress)
+		}
+		return []byte(fijiOutput(80, 25)), nil
+	})
+	service = newTestService(t, &config.Config{}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("progress-1", file, AnalysisOptions{}))
+	assert.Equal(t, []int{25, 50, 50, 50, 75}, seen)
+
+	result, err := service.GetAnalysisStatus("progress-1")
+	require.NoError(t, err)
+	assert.Equal(t, 100, result.Progress)
+}
+
+func TestProgressSpan_ScalesTiles(t *testing.T) {
+	assert.Equal(t, 50, fullSpan.scale(50))
+	assert.Equal(t, 0, tileSpan(0, 4).scale(0))
+	assert.Equal(t, 37, tileSpan(1, 4).scale(50))
+	assert.Equal(t, 100, tileSpan(3, 4).scale(100))
+}
+
+func TestAnnotateAnalysis_PreservesAutomatedPurity(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(91, 60)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("annotate-1", file, AnalysisOptions{}))
+
+	// A note without override leaves the automated value as the only purity
+	result, err := service.AnnotateAnalysis("annotate-1", models.Annotation{ReviewedBy: "alice", Note: "Checked"})
+	require.NoError(t, err)
+	assert.Equal(t, "Checked", result.ReviewNote)
+	assert.False(t, result.ManualOverride)
+	assert.Nil(t, result.ManualOverridePurity)
+	require.NotNil(t, result.ReviewedAt)
+
+	// An override is recorded alongside the original value
+	override := 78.0
+	result, err = service.AnnotateAnalysis("annotate-1", models.Annotation{ReviewedBy: "bob", OverridePurity: &override})
+	require.NoError(t, err)
+	assert.Equal(t, "bob", result.ReviewedBy)
+	assert.True(t, result.ManualOverride)
+	require.NotNil(t, result.ManualOverridePurity)
+	assert.Equal(t, 78.0, *result.ManualOverridePurity)
+	assert.Equal(t, 91.0, result.PurityPercentage)
+}
+
+func TestAnnotateAnalysis_WithoutOverrideClearsIt(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(91, 60)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("annotate-3", file, AnalysisOptions{}))
+
+	override := 78.0
+	_, err := service.AnnotateAnalysis("annotate-3", models.Annotation{ReviewedBy: "bob", OverridePurity: &override})
+	require.NoError(t, err)
+
+	result, err := service.AnnotateAnalysis("annotate-3", models.Annotation{ReviewedBy: "bob", Note: "Override withdrawn"})
+	require.NoError(t, err)
+	assert.False(t, result.ManualOverride)
+	assert.Nil(t, result.ManualOverridePurity)
+	assert.Equal(t, 91.0, result.PurityPercentage)
+
+	stored, err := service.GetAnalysisStatus("annotate-3")
+	require.NoError(t, err)
+	assert.False(t, stored.ManualOverride)
+	assert.Nil(t, stored.ManualOverridePurity)
+}
+
+func TestAnnotateAnalysis_Errors(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{}), output: fijiOutput(80, 20)}
+	service := newTestService(t, &config.Config{}, runner)
+
+	_, err := service.AnnotateAnalysis("missing", models.Annotation{ReviewedBy: "alice"})
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	done := make(chan error, 1)
+	go func() {
+		done <- service.AnalyzeGypsumImage("annotate-2", file, AnalysisOptions{})
+	}()
+
+	// Wait until the record exists before annotating
+	require.Eventually(t, func() bool {
+		_, err := service.GetAnalysisStatus("annotate-2")
+		return err == nil
+	}, time.Second, 5*time.Millisecond)
+
+	_, err = service.AnnotateAnalysis("annotate-2", models.Annotation{ReviewedBy: "alice"})
+	assert.ErrorIs(t, err, ErrAnalysisInProgress)
+
+	close(runner.release)
+	require.NoError(t, <-done)
+}
+
+func TestSubmitAnalysis_RunsOnPool(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(85, 30)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "submit-1", file, AnalysisOptions{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := service.WaitForAnalysis(ctx, "submit-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, 85.0, result.PurityPercentage)
+}
+
+// syncBuffer collects log output written from analysis goroutines
+type syncBuffer struct {
+	mutex sync.Mutex
+	buf   bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	return b.buf.String()
+}
+
+func TestSubmitAnalysis_LogsCarryRequestID(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: "no results"})
+	var out syncBuffer
+	service.logger.SetOutput(&out)
+
+	ctx := logger.NewContext(context.Background(), logrus.Fields{"request_id": "req-42"})
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.SubmitAnalysis(ctx, "logged-1", file, AnalysisOptions{}))
+
+	// The failure is logged by the worker, after the request has ended
+	require.Eventually(t, func() bool {
+		return strings.Contains(out.String(), "Analysis failed")
+	}, 5*time.Second, 5*time.Millisecond)
+
+	var line map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(strings.TrimSpace(out.String())), &line))
+	assert.Equal(t, "req-42", line["request_id"])
+	assert.Equal(t, "logged-1", line["analysis_id"])
+}
+
+func TestSubmitAnalysis_QueueFull(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{}), output: fijiOutput(80, 20)}
+	service := newTestService(t, &config.Config{}, runner)
+	service.pool = NewWorkerPool(1, 1, nil)
+	defer service.pool.Shutdown(context.Background())
+
+	// The only worker picks up the first analysis and blocks in Fiji
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "queued-1", file, AnalysisOptions{}))
+	require.Eventually(t, func() bool {
+		result, err := service.GetAnalysisStatus("queued-1")
+		return err == nil && result.Status == models.StatusProcessing
+	}, 5*time.Second, 5*time.Millisecond)
+
+	// The second waits in the queue
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "queued-2", file, AnalysisOptions{}))
+	result, err := service.GetAnalysisStatus("queued-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusPending, result.Status)
+	assert.Equal(t, 1, service.QueueDepth())
+
+	err = service.SubmitAnalysis(context.Background(), "queued-3", file, AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrQueueFull)
+
+	// A rejected submission leaves neither a record nor an upload behind
+	_, err = service.GetAnalysisStatus("queued-3")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "queued-3.png"))
+
+	close(runner.release)
+}
+
+func TestListAnalyses_StablePagination(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	// Identical creation times are ordered by ID
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for _, id := range []string{"c", "a", "d", "b"} {
+		require.NoError(t, service.store.Create(&models.AnalysisResult{ID: id, Status: models.StatusCompleted, CreatedAt: created}))
+	}
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "e", Status: models.StatusFailed, CreatedAt: created}))
+
+	var ids []string
+	for offset := 0; offset < 4; offset += 2 {
+		page, total, err := service.ListAnalyses(ListFilter{Status: models.StatusCompleted, Limit: 2, Offset: offset})
+		require.NoError(t, err)
+		assert.Equal(t, 4, total)
+		for _, result := range page {
+			ids = append(ids, result.ID)
+		}
+	}
+	assert.Equal(t, []string{"a", "b", "c", "d"}, ids)
+
+	_, _, err := service.ListAnalyses(ListFilter{Sort: "purity"})
+	assert.ErrorIs(t, err, ErrInvalidListFilter)
+	_, _, err = service.ListAnalyses(ListFilter{Offset: -1})
+	assert.ErrorIs(t, err, ErrInvalidListFilter)
+	low, high := 90.0, 80.0
+	_, _, err = service.ListAnalyses(ListFilter{MinPurity: &low, MaxPurity: &high})
+	assert.ErrorIs(t, err, ErrInvalidListFilter)
+}
+
+func TestDeleteAnalysis_RemovesRecordAndFiles(t *testing.T) {
+	service := newTestService(t, &config.Config{ManifestSidecar: true, KeepImages: true}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("delete-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("delete-1")
+	require.NoError(t, err)
+	sidecar := filepath.Join(service.config.TempDir, "delete-1_manifest.json")
+	require.FileExists(t, result.ImagePath)
+	require.FileExists(t, sidecar)
+
+	cancelled, err := service.DeleteAnalysis("delete-1")
+	require.NoError(t, err)
+	assert.False(t, cancelled)
+
+	_, err = service.GetAnalysisStatus("delete-1")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+	assert.NoFileExists(t, result.ImagePath)
+	assert.NoFileExists(t, sidecar)
+
+	_, err = service.DeleteAnalysis("delete-1")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestDeleteAnalysis_CancelsRunningAnalysis(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{})}
+	service := newTestService(t, &config.Config{}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "delete-2", file, AnalysisOptions{}))
+	require.Eventually(t, func() bool {
+		result, err := service.GetAnalysisStatus("delete-2")
+		return err == nil && result.Status == models.StatusProcessing
+	}, 5*time.Second, 5*time.Millisecond)
+
+	cancelled, err := service.DeleteAnalysis("delete-2")
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+
+	// The worker kills Fiji and records the cancellation
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := service.WaitForAnalysis(ctx, "delete-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, result.Status)
+	assert.NoFileExists(t, result.ImagePath)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "delete-2_macro.ijm"))
+
+	// A second request removes the cancelled record
+	cancelled, err = service.DeleteAnalysis("delete-2")
+	require.NoError(t, err)
+	assert.False(t, cancelled)
+	_, err = service.GetAnalysisStatus("delete-2")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestCancelAnalysis_KeepsRecord(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{})}
+	service := newTestService(t, &config.Config{}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "cancel-1", file, AnalysisOptions{}))
+	require.Eventually(t, func() bool {
+		result, err := service.GetAnalysisStatus("cancel-1")
+		return err == nil && result.Status == models.StatusProcessing
+	}, 5*time.Second, 5*time.Millisecond)
+
+	require.NoError(t, service.CancelAnalysis("cancel-1"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := service.WaitForAnalysis(ctx, "cancel-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, result.Status)
+	assert.Equal(t, "Analysis cancelled by user", result.Error)
+
+	// Cancelling again does not remove the record
+	assert.ErrorIs(t, service.CancelAnalysis("cancel-1"), ErrAnalysisFinished)
+	_, err = service.GetAnalysisStatus("cancel-1")
+	assert.NoError(t, err)
+
+	assert.ErrorIs(t, service.CancelAnalysis("missing"), ErrAnalysisNotFound)
+}
+
+func TestDeleteAnalysis_CancelsQueuedAnalysis(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{}), output: fijiOutput(80, 20)}
+	service := newTestService(t, &config.Config{}, runner)
+	service.pool = NewWorkerPool(1, 1, nil)
+	defer service.pool.Shutdown(context.Background())
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "busy", file, AnalysisOptions{}))
+	require.Eventually(t, func() bool {
+		result, err := service.GetAnalysisStatus("busy")
+		return err == nil && result.Status == models.StatusProcessing
+	}, 5*time.Second, 5*time.Millisecond)
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "queued", file, AnalysisOptions{}))
+
+	cancelled, err := service.DeleteAnalysis("queued")
+	require.NoError(t, err)
+	assert.True(t, cancelled)
+
+	// Cancelling a queued analysis takes effect at once
+	result, err := service.GetAnalysisStatus("queued")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, result.Status)
+	assert.NoFileExists(t, result.ImagePath)
+
+	// The worker skips it once the busy analysis finishes
+	close(runner.release)
+	require.NoError(t, service.pool.Shutdown(context.Background()))
+	result, err = service.GetAnalysisStatus("queued")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, result.Status)
+}
+
+func TestDeleteAnalysis_FinishingAnalysis(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	// Processing but no longer cancellable: the worker is recording its outcome
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "delete-3", Status: models.StatusProcessing}))
+
+	_, err := service.DeleteAnalysis("delete-3")
+	assert.ErrorIs(t, err, ErrAnalysisInProgress)
+
+	_, err = service.GetAnalysisStatus("delete-3")
+	assert.NoError(t, err)
+}
+
+func TestGetAnalysisManifest_CompletedAnalysis(t *testing.T) {
+	cfg := &config.Config{ShadowMode: true, ShadowTolerance: 5, ManifestSidecar: true}
+	service := newTestService(t, cfg, &fakeRunner{output: fijiOutput(90, 60)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("manifest-1", file, AnalysisOptions{}))
+
+	manifest, err := service.GetAnalysisManifest("manifest-1")
+	require.NoError(t, err)
+
+	assert.Equal(t, "1", manifest.ManifestVersion)
+	require.NotNil(t, manifest.Result)
+	assert.Equal(t, models.StatusCompleted, manifest.Result.Status)
+	require.NotNil(t, manifest.Parameters)
+	assert.Equal(t, DefaultMacroParams(service.config), *manifest.Parameters)
+	require.Len(t, manifest.Warnings, 1)
+	assert.Contains(t, manifest.Macro, `setAutoThreshold("Otsu");`)
+	assert.Contains(t, manifest.Macro, "size=10-Infinity circularity=0.00-1.00")
+	assert.Equal(t, "2.14.0/1.54f", manifest.FijiVersion)
+	assert.False(t, manifest.Timestamps.CreatedAt.IsZero())
+	assert.NotNil(t, manifest.Timestamps.CompletedAt)
+
+	// The sidecar carries the same sections
+	data, err := os.ReadFile(filepath.Join(cfg.TempDir, "manifest-1_manifest.json"))
+	require.NoError(t, err)
+
+	var sidecar map[string]interface{}
+	require.NoError(t, json.Unmarshal(data, &sidecar))
+	for _, section := range []string{"result", "parameters", "warnings", "macro", "fiji_version", "timestamps"} {
+		assert.Contains(t, sidecar, section)
+	}
+}
+
+func TestGetAnalysisManifest_NotFound(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	_, err := service.GetAnalysisManifest("missing")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestSubscribeEvents_ReportsTransitions(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(70, 20)})
+
+	events, unsubscribe := service.SubscribeEvents()
+	defer unsubscribe()
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 70))
+	require.NoError(t, service.AnalyzeGypsumImage("events-1", file, AnalysisOptions{}))
+
+	processing := <-events
+	assert.Equal(t, "events-1", processing.AnalysisID)
+	assert.Equal(t, models.StatusProcessing, processing.Status)
+	assert.Nil(t, processing.Result)
+
+	completed := <-events
+	assert.Equal(t, models.StatusCompleted, completed.Status)
+	require.NotNil(t, completed.Result)
+	assert.Equal(t, 70.0, completed.Result.PurityPercentage)
+
+	// Unsubscribing closes the stream
+	unsubscribe()
+	_, open := <-events
+	assert.False(t, open)
+}
+
+func TestSubscribe_StreamsChangesUntilTerminal(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{}), output: fijiOutput(72, 20)}
+	service := newTestService(t, &config.Config{}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 70))
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "watch-1", file, AnalysisOptions{}))
+
+	updates, unsubscribe, err := service.Subscribe("watch-1")
+	require.NoError(t, err)
+	defer unsubscribe()
+
+	// The current state comes first, whether or not a worker has picked it up yet
+	first := <-updates
+	assert.Contains(t, []models.AnalysisStatus{models.StatusPending, models.StatusProcessing}, first.Status)
+	close(runner.release)
+
+	statuses := []models.AnalysisStatus{first.Status}
+	var last *models.AnalysisResult
+	for result := range updates {
+		statuses = append(statuses, result.Status)
+		last = result
+	}
+	require.NotNil(t, last)
+	assert.Equal(t, models.StatusCompleted, last.Status)
+	assert.Equal(t, 72.0, last.PurityPercentage)
+	assert.Equal(t, models.StatusCompleted, statuses[len(statuses)-1])
+	assert.LessOrEqual(t, len(statuses), 3, "each status is sent once: %v", statuses)
+
+	service.mutex.RLock()
+	assert.Empty(t, service.watchers)
+	service.mutex.RUnlock()
+}
+
+func TestSubscribe_FinishedAndUnknownAnalyses(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "done", Status: models.StatusFailed}))
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "queued", Status: models.StatusPending}))
+
+	// A finished analysis yields its result and closes
+	updates, _, err := service.Subscribe("done")
+	require.NoError(t, err)
+	result := <-updates
+	assert.Equal(t, models.StatusFailed, result.Status)
+	_, open := <-updates
+	assert.False(t, open)
+
+	// Unsubscribing closes the stream and forgets the watcher
+	updates, unsubscribe, err := service.Subscribe("queued")
+	require.NoError(t, err)
+	<-updates
+	unsubscribe()
+	_, open = <-updates
+	assert.False(t, open)
+	unsubscribe()
+	service.mutex.RLock()
+	assert.Empty(t, service.watchers)
+	service.mutex.RUnlock()
+
+	_, _, err = service.Subscribe("missing")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestSubscribeEvents_SingleFailedEvent(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{err: fmt.Errorf("exit status 1")})
+
+	events, unsubscribe := service.SubscribeEvents()
+	defer unsubscribe()
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 70))
+	require.Error(t, service.AnalyzeGypsumImage("events-2", file, AnalysisOptions{}))
+
+	assert.Equal(t, models.StatusProcessing, (<-events).Status)
+	assert.Equal(t, models.StatusFailed, (<-events).Status)
+	assert.Empty(t, events)
+}
+
+// documentScanPNG encodes a white page with rows of dark text-like strokes
+func documentScanPNG(t *testing.T) []byte {
+	t.Helper()
+
+	img := image.NewGray(image.Rect(0, 0, 300, 300))
+	for y := 0; y < 300; y++ {
+		for x := 0; x < 300; x++ {
+			img.SetGray(x, y, color.Gray{Y: 250})
+		}
+	}
+	for line := 30; line < 270; line += 20 {
+		for y := line; y < line+6; y++ {
+			for x := 20; x < 280; x++ {
+				if (x/3)%2 == 0 && (x/40)%4 != 3 {
+					img.SetGray(x, y, color.Gray{Y: 10})
+				}
+			}
+		}
+	}
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+	return buf.Bytes()
+}
+
+func TestDocumentCheck_WarnsOnDocumentScan(t *testing.T) {
+	service := newTestService(t, &config.Config{DocumentCheck: true}, &fakeRunner{output: fijiOutput(90, 40)})
+
+	file := newFileHeader(t, "scan.png", documentScanPNG(t))
+	require.NoError(t, service.AnalyzeGypsumImage("document-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("document-1")
+	require.NoError(t, err)
+
+	// Advisory only: the analysis still completes
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	require.Len(t, result.Warnings, 1)
+	assert.Contains(t, result.Warnings[0], "document scan")
+}
+
+func TestDocumentCheck_NoWarningForSample(t *testing.T) {
+	service := newTestService(t, &config.Config{DocumentCheck: true}, &fakeRunner{output: fijiOutput(50, 40)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("document-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("document-2")
+	require.NoError(t, err)
+	assert.Empty(t, result.Warnings)
+}
+
+func TestGetPurityTrend_FiltersAndOrders(t *testing.T) {
+	service := newTestService(t, &config.Config{TrendMaxPoints: 2}, &fakeRunner{})
+
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	at := func(hours int) *time.Time {
+		ts := base.Add(time.Duration(hours) * time.Hour)
+		return &ts
+	}
+	for _, result := range []*models.AnalysisResult{
+		{ID: "old", SampleGroup: "line-1", Status: models.StatusCompleted, CompletedAt: at(-48), PurityPercentage: 60},
+		{ID: "first", SampleGroup: "line-1", Status: models.StatusCompleted, CompletedAt: at(1), PurityPercentage: 70},
+		{ID: "second", SampleGroup: "line-1", Status: models.StatusCompleted, CompletedAt: at(2), PurityPercentage: 75},
+		{ID: "third", SampleGroup: "line-1", Status: models.StatusCompleted, CompletedAt: at(3), PurityPercentage: 80},
+		{ID: "failed", SampleGroup: "line-1", Status: models.StatusFailed, CompletedAt: at(2)},
+		{ID: "running", SampleGroup: "line-1", Status: models.StatusProcessing},
+		{ID: "other", SampleGroup: "line-2", Status: models.StatusCompleted, CompletedAt: at(2), PurityPercentage: 99},
+		{ID: "ungrouped", Status: models.StatusCompleted, CompletedAt: at(2), PurityPercentage: 99},
+	} {
+		require.NoError(t, service.store.Create(result))
+	}
+
+	points, err := service.GetPurityTrend("line-1", base, time.Time{})
+	require.NoError(t, err)
+	require.Len(t, points, 2, "only the most recent TrendMaxPoints analyses are kept")
+	assert.Equal(t, "second", points[0].AnalysisID)
+	assert.Equal(t, "third", points[1].AnalysisID)
+
+	points, err = service.GetPurityTrend("line-1", base, *at(1))
+	require.NoError(t, err)
+	require.Len(t, points, 1)
+	assert.Equal(t, 70.0, points[0].PurityPercentage)
+
+	points, err = service.GetPurityTrend("line-3", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Empty(t, points)
+}
+
+func TestConcurrentReadsDuringUpdates_SeeConsistentSnapshots(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("hammer-1", file, AnalysisOptions{}))
+
+	const writes = 200
+	stop := make(chan struct{})
+	var readers sync.WaitGroup
+	var inconsistent atomic.Int32
+
+	for i := 0; i < 8; i++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+
+				result, err := service.GetAnalysisStatus("hammer-1")
+				if err != nil {
+					inconsistent.Add(1)
+					continue
+				}
+				// Encoding walks every field, so the race detector catches torn reads
+				if _, err := json.Marshal(result); err != nil {
+					inconsistent.Add(1)
+				}
+				// Reviewer and note are always written together
+				if strings.TrimPrefix(result.ReviewNote, "note-") != strings.TrimPrefix(result.ReviewedBy, "reviewer-") {
+					inconsistent.Add(1)
+				}
+			}
+		}()
+	}
+
+	var writers sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		writers.Add(1)
+		go func(w int) {
+			defer writers.Done()
+			for i := 0; i < writes/4; i++ {
+				n := fmt.Sprintf("%d-%d", w, i)
+				_, err := service.AnnotateAnalysis("hammer-1", models.Annotation{ReviewedBy: "reviewer-" + n, Note: "note-" + n})
+				assert.NoError(t, err)
+				service.update("hammer-1", func(result *models.AnalysisResult) error {
+					result.Warnings = append(slices.Clip(result.Warnings), "warning-"+n)
+					return nil
+				})
+			}
+		}(w)
+	}
+
+	writers.Wait()
+	close(stop)
+	readers.Wait()
+
+	assert.Zero(t, inconsistent.Load())
+
+	result, err := service.GetAnalysisStatus("hammer-1")
+	require.NoError(t, err)
+	assert.Len(t, result.Warnings, writes, "no update may be lost")
+	assert.Equal(t, models.StatusCompleted, result.Status)
+}
+
+func TestUpdate_SnapshotsAreNotModifiedInPlace(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("snapshot-1", file, AnalysisOptions{}))
+
+	before, err := service.GetAnalysisStatus("snapshot-1")
+	require.NoError(t, err)
+
+	_, err = service.AnnotateAnalysis("snapshot-1", models.Annotation{ReviewedBy: "kim", Note: "checked"})
+	require.NoError(t, err)
+
+	after, err := service.GetAnalysisStatus("snapshot-1")
+	require.NoError(t, err)
+	assert.Empty(t, before.ReviewedBy, "earlier snapshot must not change")
+	assert.Equal(t, "kim", after.ReviewedBy)
+	assert.Equal(t, before.PurityPercentage, after.PurityPercentage)
+}
+
+// truncatedPNG carries a PNG signature but cannot be decoded
+var truncatedPNG = []byte("\x89PNG\r\n\x1a\ntruncated")
+
+func TestFailureCategories(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      *config.Config
+		runner   FijiRunner
+		content  []byte
+		category models.FailureCategory
+	}{
+		{"fiji exec", &config.Config{}, &fakeRunner{err: fmt.Errorf("exec: \"ImageJ-linux64\": executable file not found")}, nil, models.FailureFijiExec},
+		{"stalled", &config.Config{HeartbeatTimeout: 1}, &stallingRunner{}, nil, models.FailureFijiExec},
+		{"parse", &config.Config{}, &fakeRunner{output: "Exception in thread \"main\" java.lang.OutOfMemoryError"}, nil, models.FailureParse},
+		{"timeout", &config.Config{AnalysisTimeout: 1}, &blockingRunner{release: make(chan struct{})}, nil, models.FailureTimeout},
+		{"bad input", &config.Config{}, &fakeRunner{output: fijiOutput(90, 10)}, truncatedPNG, models.FailureBadInput},
+		{"storage", &config.Config{TempDir: filepath.Join(t.TempDir(), "missing", "dir")}, &fakeRunner{output: fijiOutput(90, 10)}, nil, models.FailureStorage},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(t, tt.cfg, tt.runner)
+			if tt.category == models.FailureStorage {
+				// The blob store creates its directory; take it away again
+				require.NoError(t, os.RemoveAll(tt.cfg.TempDir))
+			}
+
+			content := tt.content
+			if content == nil {
+				content = splitImagePNG(t, 50)
+			}
+			err := service.AnalyzeGypsumImage("category-1", newFileHeader(t, "sample.png", content), AnalysisOptions{})
+			assert.Error(t, err)
+
+			result, err := service.GetAnalysisStatus("category-1")
+			require.NoError(t, err)
+			assert.Equal(t, models.StatusFailed, result.Status)
+			assert.Equal(t, tt.category, result.FailureCategory)
+			assert.NotEmpty(t, result.Error)
+		})
+	}
+}
+
+func TestFailureCategories_Cancelled(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &blockingRunner{release: make(chan struct{})})
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "category-2", Status: models.StatusProcessing}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	imagePath := filepath.Join(service.config.TempDir, "category-2.png")
+	require.NoError(t, os.WriteFile(imagePath, splitImagePNG(t, 50), 0644))
+
+	assert.Error(t, service.performFijiAnalysis(ctx, "category-2", imagePath, "", nil, DefaultMacroParams(service.config)))
+
+	result, err := service.GetAnalysisStatus("category-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCancelled, result.Status)
+	assert.Equal(t, models.FailureCancelled, result.FailureCategory)
+	assert.NoError(t, terminalError(result), "only timeouts map to ErrAnalysisTimeout")
+}
+
+func TestDetectImageType(t *testing.T) {
+	jpeg := []byte("\xff\xd8\xff\xe0\x00\x10JFIF\x00")
+	tests := []struct {
+		name string
+		head []byte
+		mime string
+		ok   bool
+	}{
+		{"png", []byte("\x89PNG\r\n\x1a\n"), "image/png", true},
+		{"jpeg", jpeg, "image/jpeg", true},
+		{"tiff little endian", []byte("II*\x00\x08\x00\x00\x00"), "image/tiff", true},
+		{"tiff big endian", []byte("MM\x00*\x00\x00\x00\x08"), "image/tiff", true},
+		{"webp", webpImage, "image/webp", true},
+		{"bmp", []byte("BM\x3a\x00\x00\x00\x00\x00\x00\x00\x36\x00\x00\x00"), models.MIMETypeBMP, true},
+		{"dng", dngImage(t), models.MIMETypeDNG, true},
+		{"gif", []byte("GIF89a"), "image/gif", false},
+		{"text", []byte("not an image"), "text/plain; charset=utf-8", false},
+		{"empty", nil, "text/plain; charset=utf-8", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mime, ok := detectImageType(tt.head)
+			assert.Equal(t, tt.mime, mime)
+			assert.Equal(t, tt.ok, ok)
+		})
+	}
+}
+
+func TestSubmitAnalysis_RejectsRenamedFile(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	err := service.SubmitAnalysis(context.Background(), "renamed-1", newFileHeader(t, "sample.jpg", []byte("<html>not a photo</html>")), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrUnsupportedMIME)
+
+	// Nothing is kept for a rejected upload
+	_, err = service.GetAnalysisStatus("renamed-1")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "renamed-1.jpg"))
+
+	// A real image must still match the type its extension claims
+	err = service.SubmitAnalysis(context.Background(), "renamed-2", newFileHeader(t, "sample.jpg", splitImagePNG(t, 50)), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrImageTypeMismatch)
+	assert.Contains(t, err.Error(), "image/png")
+	_, err = service.GetAnalysisStatus("renamed-2")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestCheckImagePixels(t *testing.T) {
+	const limit = 250_000_000
+
+	assert.NoError(t, checkImagePixels(image.Config{Width: 10000, Height: 25000}, limit))
+	err := checkImagePixels(image.Config{Width: 100000, Height: 100000}, limit)
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+	assert.ErrorContains(t, err, "100000x100000 is 10000000000 pixels, at most 250000000")
+	assert.NoError(t, checkImagePixels(image.Config{Width: 100000, Height: 100000}, 0), "0 disables the check")
+}
+
+func TestSubmitAnalysis_RejectsDecompressionBomb(t *testing.T) {
+	service := newTestService(t, &config.Config{MaxImagePixels: 250_000_000}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	// A PNG header claiming 100000x100000 pixels, without the pixel data
+	ihdr := []byte("IHDR")
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 100000)
+	ihdr = binary.BigEndian.AppendUint32(ihdr, 100000)
+	ihdr = append(ihdr, 8, 0, 0, 0, 0)
+	bomb := binary.BigEndian.AppendUint32([]byte("\x89PNG\r\n\x1a\n"), uint32(len(ihdr)-4))
+	bomb = append(bomb, ihdr...)
+	bomb = binary.BigEndian.AppendUint32(bomb, crc32.ChecksumIEEE(ihdr))
+
+	err := service.SubmitAnalysis(context.Background(), "bomb-1", newFileHeader(t, "bomb.png", bomb), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+
+	// Nothing is kept for a rejected upload
+	_, err = service.GetAnalysisStatus("bomb-1")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "bomb-1_upload.png"))
+}
+
+// tiffHeader builds little-endian TIFF data whose first IFD holds only the
+// given LONG tags, which Go cannot decode
+func tiffHeader(tags ...[2]uint32) []byte {
+	data := binary.LittleEndian.AppendUint32([]byte("II*\x00"), 8)
+	data = binary.LittleEndian.AppendUint16(data, uint16(len(tags)))
+	for _, tag := range tags {
+		data = binary.LittleEndian.AppendUint16(data, uint16(tag[0]))
+		data = binary.LittleEndian.AppendUint16(data, 4)
+		data = binary.LittleEndian.AppendUint32(data, 1)
+		data = binary.LittleEndian.AppendUint32(data, tag[1])
+	}
+	return binary.LittleEndian.AppendUint32(data, 0)
+}
+
+func TestSubmitAnalysis_MeasuresImagesGoCannotDecode(t *testing.T) {
+	service := newTestService(t, &config.Config{MaxImagePixels: 250_000_000}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	// A CIELab TIFF, which Fiji reads but Go does not
+	bomb := tiffHeader([2]uint32{tiffImageWidthTag, 100000}, [2]uint32{tiffImageLengthTag, 100000}, [2]uint32{0x0106, 8})
+	_, _, decodeErr := image.DecodeConfig(bytes.NewReader(bomb))
+	require.Error(t, decodeErr, "the header must not be decodable in Go")
+
+	err := service.SubmitAnalysis(context.Background(), "bomb-1", newFileHeader(t, "bomb.tif", bomb), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+	assert.ErrorContains(t, err, "100000x100000")
+
+	// An image whose size cannot be read at all is not let through
+	err = service.SubmitAnalysis(context.Background(), "unreadable-1", newFileHeader(t, "sample.tif", []byte("II*\x00\x08\x00\x00\x00")), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrUnsupportedMIME)
+	_, err = service.GetAnalysisStatus("unreadable-1")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestSubmitAnalysis_MeasuresTIFFWithTrailingIFD(t *testing.T) {
+	service := newTestService(t, &config.Config{MaxImagePixels: 250_000_000}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	// A JPEG-compressed YCbCr TIFF whose IFD follows 5000 bytes of strip data
+	ifd := func(width, height uint32) []byte {
+		tags := tiffHeader([2]uint32{tiffImageWidthTag, width}, [2]uint32{tiffImageLengthTag, height},
+			[2]uint32{0x0103, 7}, [2]uint32{0x0106, 6})
+		data := binary.LittleEndian.AppendUint32([]byte("II*\x00"), 8+5000)
+		data = append(data, make([]byte, 5000)...)
+		return append(data, tags[8:]...)
+	}
+	sample := ifd(4000, 3000)
+	_, _, decodeErr := image.DecodeConfig(bytes.NewReader(sample))
+	require.Error(t, decodeErr, "the header must not be decodable in Go")
+
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "late-1", newFileHeader(t, "sample.tif", sample), AnalysisOptions{}))
+
+	err := service.SubmitAnalysis(context.Background(), "late-2", newFileHeader(t, "bomb.tif", ifd(100000, 100000)), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrImageTooLarge)
+}
+
+func TestHeaderSizes(t *testing.T) {
+	cfg, ok := tiffSize(bytes.NewReader(tiffHeader([2]uint32{tiffImageWidthTag, 640}, [2]uint32{tiffImageLengthTag, 480})))
+	assert.True(t, ok)
+	assert.Equal(t, image.Config{Width: 640, Height: 480}, cfg)
+	_, ok = tiffSize(bytes.NewReader(tiffHeader([2]uint32{tiffImageWidthTag, 640})))
+	assert.False(t, ok, "missing ImageLength")
+
+	bmp := func(headerSize uint32, dims ...byte) []byte {
+		data := append([]byte("BM"), make([]byte, 12)...)
+		data = binary.LittleEndian.AppendUint32(data, headerSize)
+		return append(data, dims...)
+	}
+	// BITMAPINFOHEADER with a top-down (negative) height
+	cfg, ok = bmpSize(bmp(40, 0x80, 0x02, 0, 0, 0x20, 0xfe, 0xff, 0xff))
+	assert.True(t, ok)
+	assert.Equal(t, image.Config{Width: 640, Height: 480}, cfg)
+	// OS/2 BITMAPCOREHEADER with 16-bit dimensions
+	cfg, ok = bmpSize(bmp(12, 0x80, 0x02, 0xe0, 0x01, 1, 0, 24, 0))
+	assert.True(t, ok)
+	assert.Equal(t, image.Config{Width: 640, Height: 480}, cfg)
+	_, ok = bmpSize([]byte("BM"))
+	assert.False(t, ok, "truncated header")
+}
+
+// webpImage is a 1x1 lossless WebP image
+var webpImage = []byte("RIFF\x1a\x00\x00\x00WEBPVP8L\x0d\x00\x00\x00\x2f\x00\x00\x00\x10\x07\x10\x11\x11\x88\x88\xfe\x07\x00")
+
+func TestAnalyzeGypsumImage_ConvertsWebP(t *testing.T) {
+	service := newTestService(t, &config.Config{KeepImages: true}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	require.NoError(t, service.AnalyzeGypsumImage("webp-1", newFileHeader(t, "sample.webp", webpImage), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("webp-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, filepath.Join(service.config.TempDir, "webp-1.png"), result.ImagePath)
+
+	// Fiji is handed a PNG and the original is gone
+	file, err := os.Open(result.ImagePath)
+	require.NoError(t, err)
+	defer file.Close()
+	img, err := png.Decode(file)
+	require.NoError(t, err)
+	assert.Equal(t, 1, img.Bounds().Dx())
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "webp-1.webp"))
+}
+
+// dngImage encodes a small TIFF and turns it into a DNG by retagging its last
+// IFD entry, ResolutionUnit, as DNGVersion
+func dngImage(t *testing.T) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, tiff.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 3)), nil))
+	data := buf.Bytes()
+
+	order := binary.LittleEndian
+	ifd := order.Uint32(data[4:])
+	for i := uint32(0); i < uint32(order.Uint16(data[ifd:])); i++ {
+		entry := data[ifd+2+12*i:]
+		if order.Uint16(entry) == 0x0128 {
+			order.PutUint16(entry, dngVersionTag)
+		}
+	}
+	return data
+}
+
+func TestAnalyzeGypsumImage_ConvertsDNG(t *testing.T) {
+	service := newTestService(t, &config.Config{KeepImages: true}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	require.NoError(t, service.AnalyzeGypsumImage("dng-1", newFileHeader(t, "sample.dng", dngImage(t)), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("dng-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, filepath.Join(service.config.TempDir, "dng-1.png"), result.ImagePath)
+	assert.Equal(t, 4, result.ImageWidth)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "dng-1.dng"))
+
+	// A DNG is not accepted under a TIFF extension, nor a plain TIFF as DNG
+	err = service.SubmitAnalysis(context.Background(), "dng-2", newFileHeader(t, "sample.tif", dngImage(t)), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrImageTypeMismatch)
+	var plain bytes.Buffer
+	require.NoError(t, tiff.Encode(&plain, image.NewGray(image.Rect(0, 0, 4, 3)), nil))
+	err = service.SubmitAnalysis(context.Background(), "dng-3", newFileHeader(t, "sample.dng", plain.Bytes()), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrImageTypeMismatch)
+}
+
+func TestSubmitAnalysis_RejectsDNGPreview(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	// Retag Compression, which is 1 (none) and optional, as NewSubfileType 1
+	data := dngImage(t)
+	order := binary.LittleEndian
+	ifd := order.Uint32(data[4:])
+	for i := uint32(0); i < uint32(order.Uint16(data[ifd:])); i++ {
+		entry := data[ifd+2+12*i:]
+		if order.Uint16(entry) == 0x0103 {
+			order.PutUint16(entry, newSubfileTypeTag)
+		}
+	}
+
+	err := service.SubmitAnalysis(context.Background(), "dng-preview", newFileHeader(t, "sample.dng", data), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrDNGPreview)
+	_, err = service.GetAnalysisStatus("dng-preview")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestAnalyzeGypsumImage_AcceptsBMP(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	var buf bytes.Buffer
+	require.NoError(t, bmp.Encode(&buf, image.NewGray(image.Rect(0, 0, 5, 2))))
+	require.NoError(t, service.AnalyzeGypsumImage("bmp-1", newFileHeader(t, "sample.bmp", buf.Bytes()), AnalysisOptions{}))
+
+	// Fiji reads BMP itself, so the upload is analyzed as it is
+	result, err := service.GetAnalysisStatus("bmp-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, "bmp", result.ImageFormat)
+	assert.Equal(t, 5, result.ImageWidth)
+}
+
+func TestAnalyzeGypsumImage_LeavesUnreadableTIFFToFiji(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	// A TIFF header whose first directory lies past the end of the file
+	content := []byte("II*\x00\x08\x00\x00\x00")
+	require.NoError(t, service.AnalyzeGypsumImage("tiff-1", newFileHeader(t, "sample.tif", content), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("tiff-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status)
+	assert.Zero(t, result.ImageWidth)
+}
+
+func TestInputIdentity_RecordedForCompletedAnalysis(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	content := splitImagePNG(t, 66)
+	require.NoError(t, service.AnalyzeGypsumImage("identity-1", newFileHeader(t, "sample.png", content), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("identity-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status)
+
+	sum := sha256.Sum256(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), result.ImageSHA256)
+	assert.Equal(t, int64(len(content)), result.ImageSize)
+	assert.Equal(t, "png", result.ImageFormat)
+	assert.Equal(t, 100, result.ImageWidth)
+	assert.Equal(t, 100, result.ImageHeight)
+
+	// The identity fields are always serialized
+	data, err := json.Marshal(&models.AnalysisResult{})
+	require.NoError(t, err)
+	for _, field := range []string{"image_sha256", "image_format", "image_width", "image_height"} {
+		assert.Contains(t, string(data), `"`+field+`"`)
+	}
+}
+
+func TestParseFijiResults_EstimatesMissingPurity(t *testing.T) {
+	output := strings.Replace(fijiOutput(50, 12), "purity_percentage:50\n", "", 1)
+	service := newTestService(t, &config.Config{AllowEstimatedResults: true}, &fakeRunner{output: output})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 37))
+	require.NoError(t, service.AnalyzeGypsumImage("estimate-1", file, AnalysisOptions{}))
+
+	// Without a Fiji purity the share of the image above its Otsu threshold is used
+	result, err := service.GetAnalysisStatus("estimate-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.InDelta(t, 37.0, result.PurityPercentage, 0.001)
+	assert.True(t, result.Estimated)
+	assert.Equal(t, estimatedConfidence, result.Confidence)
+}
+
+func TestParseFijiResults_MissingValuesFailWithoutEstimates(t *testing.T) {
+	output := "ANALYSIS_RESULTS_START\ngypsum_content:40\ntotal_area:4000\nANALYSIS_RESULTS_END\n"
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: output})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 37))
+	assert.Error(t, service.AnalyzeGypsumImage("strict-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("strict-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, result.Status)
+	assert.Equal(t, models.FailureParse, result.FailureCategory)
+	assert.Contains(t, result.Error, "unable to parse analysis output")
+	assert.Zero(t, result.PurityPercentage)
+	assert.False(t, result.Estimated)
+}
+
+func TestParseFijiResults_ZeroPurityIsNotEstimated(t *testing.T) {
+	for _, allow := range []bool{false, true} {
+		t.Run(fmt.Sprintf("allow estimates %t", allow), func(t *testing.T) {
+			output := strings.Replace(fijiOutput(0, 0), "threshold_value:128", "threshold_value:0", 1)
+			service := newTestService(t, &config.Config{AllowEstimatedResults: allow}, &fakeRunner{output: output})
+
+			file := newFileHeader(t, "sample.png", splitImagePNG(t, 37))
+			require.NoError(t, service.AnalyzeGypsumImage("strict-2", file, AnalysisOptions{}))
+
+			// A sample without particles is a genuine result, not a parse failure
+			result, err := service.GetAnalysisStatus("strict-2")
+			require.NoError(t, err)
+			assert.Equal(t, models.StatusCompleted, result.Status)
+			assert.Zero(t, result.PurityPercentage)
+			assert.Zero(t, result.ParticleCount)
+			assert.Zero(t, result.ThresholdValue)
+			assert.False(t, result.Estimated)
+		})
+	}
+}
+
+func TestParseFijiResults_AverageParticleSize(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	tests := []struct {
+		name   string
+		output string
+		want   float64
+	}{
+		{"reported", "ANALYSIS_RESULTS_START\npurity_percentage:40\nparticle_count:8\nthreshold_value:128\ntotal_area:4000\naverage_particle_size:500\nANALYSIS_RESULTS_END\n", 500},
+		{"no particles", "ANALYSIS_RESULTS_START\npurity_percentage:0\nparticle_count:0\nthreshold_value:0\ntotal_area:0\naverage_particle_size:0\nANALYSIS_RESULTS_END\n", 0},
+		{"not reported", "ANALYSIS_RESULTS_START\npurity_percentage:40\nparticle_count:8\nthreshold_value:128\nANALYSIS_RESULTS_END\n", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, service.store.Create(&models.AnalysisResult{ID: tt.name, Status: models.StatusProcessing}))
+			require.NoError(t, service.parseFijiResults(tt.name, "", tt.output, 100))
+
+			result, err := service.GetAnalysisStatus(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, result.AverageParticleSize)
+		})
+	}
+}
+
+func TestParseFijiResults_ThresholdBounds(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	tests := []struct {
+		name         string
+		output       string
+		lower, upper *float64
+	}{
+		{"reported", "ANALYSIS_RESULTS_START\npurity_percentage:40\nparticle_count:8\nthreshold_value:97\nthreshold_lower:97\nthreshold_upper:255\nANALYSIS_RESULTS_END\n", floatPtr(97), floatPtr(255)},
+		{"dark background", "ANALYSIS_RESULTS_START\npurity_percentage:40\nparticle_count:8\nthreshold_value:0\nthreshold_lower:0\nthreshold_upper:143\nANALYSIS_RESULTS_END\n", floatPtr(0), floatPtr(143)},
+		{"not reported", "ANALYSIS_RESULTS_START\npurity_percentage:40\nparticle_count:8\nthreshold_value:128\nANALYSIS_RESULTS_END\n", nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, service.store.Create(&models.AnalysisResult{ID: tt.name, Status: models.StatusProcessing}))
+			require.NoError(t, service.parseFijiResults(tt.name, "", tt.output, 100))
+
+			result, err := service.GetAnalysisStatus(tt.name)
+			require.NoError(t, err)
+			assert.Equal(t, tt.lower, result.ThresholdLower)
+			assert.Equal(t, tt.upper, result.ThresholdUpper)
+		})
+	}
+}
+
+func TestInputIdentity_ChecksumKeptForUndecodableUpload(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	content := truncatedPNG
+	assert.Error(t, service.AnalyzeGypsumImage("identity-2", newFileHeader(t, "sample.png", content), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("identity-2")
+	require.NoError(t, err)
+	sum := sha256.Sum256(content)
+	assert.Equal(t, hex.EncodeToString(sum[:]), result.ImageSHA256)
+	assert.Empty(t, result.ImageFormat)
+}
+
+func TestBackupResults_UsedWhenOutputHasNoResults(t *testing.T) {
+	var service *AnalysisService
+	var resultsPath string
+	runner := runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		macro, err := os.ReadFile(macroPath)
+		require.NoError(t, err)
+		resultsPath = filepath.Join(service.config.TempDir, "backup-1_results.txt")
+		assert.Contains(t, string(macro), resultsPath)
+		assert.NotContains(t, string(macro), "/tmp/fiji_results.txt")
+
+		// Fiji saved its backup but the printed results were lost
+		require.NoError(t, os.WriteFile(resultsPath, []byte(fijiOutput(72, 18)), 0644))
+		return []byte(heartbeatMarker + "\n"), nil
+	})
+	service = newTestService(t, &config.Config{}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 72))
+	require.NoError(t, service.AnalyzeGypsumImage("backup-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("backup-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, 72.0, result.PurityPercentage)
+	assert.Equal(t, 18, result.ParticleCount)
+	assert.NoFileExists(t, resultsPath)
+}
+
+func TestBackupResults_MissingFileFailsParse(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: "no results"})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	assert.Error(t, service.AnalyzeGypsumImage("backup-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("backup-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.FailureParse, result.FailureCategory)
+}
+
+func TestCalculateConfidence_Factors(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	complete := map[string]float64{"purity_percentage": 40, "total_area": 4000, "image_area": 10000, "threshold_value": 128}
+	confidence, factors := service.calculateConfidence(complete, 60)
+	assert.InDelta(t, 1.0, confidence, 1e-9)
+	assert.Equal(t, models.ParticleCountMany, factors.ParticleCountBucket)
+	for _, signal := range factors.Signals {
+		assert.True(t, signal.Satisfied, signal.Name)
+	}
+
+	// A results block without a threshold, few particles and almost full coverage
+	partial := map[string]float64{"purity_percentage": 95, "total_area": 9500, "image_area": 10000}
+	confidence, factors = service.calculateConfidence(partial, 12)
+	assert.InDelta(t, 0.6, confidence, 1e-9)
+	assert.Equal(t, models.ParticleCountSome, factors.ParticleCountBucket)
+
+	satisfied := map[string]bool{}
+	sum := factors.Base
+	for _, signal := range factors.Signals {
+		satisfied[signal.Name] = signal.Satisfied
+		if signal.Satisfied {
+			sum += signal.Weight
+		}
+	}
+	assert.Equal(t, map[string]bool{
+		"parse_complete":         false,
+		"particle_count_over_10": true,
+		"particle_count_over_50": false,
+		"coverage_in_range":      false,
+	}, satisfied)
+	assert.InDelta(t, confidence, sum, 1e-9, "the score is the base plus the satisfied weights")
+}
+
+func TestParseFijiResults_RecordsConfidenceFactors(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+	require.NoError(t, service.AnalyzeGypsumImage("factors-1", newFileHeader(t, "sample.png", splitImagePNG(t, 66)), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("factors-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status)
+	require.NotNil(t, result.ConfidenceFactors)
+	assert.Equal(t, models.ParticleCountSome, result.ConfidenceFactors.ParticleCountBucket)
+	assert.NotZero(t, result.Confidence, "the scalar confidence is kept")
+
+	data, err := json.Marshal(result)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"confidence_factors"`)
+}
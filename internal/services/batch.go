@@ -0,0 +1,219 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"path"
+	"strings"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrInvalidArchive is returned when a batch upload is not a readable ZIP archive
+var ErrInvalidArchive = errors.New("invalid ZIP archive")
+
+// ErrEmptyBatch is returned when a batch archive contains no supported images
+var ErrEmptyBatch = errors.New("archive contains no supported images")
+
+// ErrBatchTooLarge is returned when a batch archive holds more than MAX_BATCH_SIZE images
+var ErrBatchTooLarge = errors.New("archive holds too many images")
+
+// ErrBatchNotFound is returned when no analyses belong to a batch ID
+var ErrBatchNotFound = errors.New("batch not found")
+
+// SubmitBatch queues one analysis per supported image in a ZIP archive. All
+// analyses share opts and a new batch ID. Archives with more than MAX_BATCH_SIZE
+// images are rejected with ErrBatchTooLarge. Entries that are not analyzed are
+// reported as skipped; when none could be queued because the queue is full,
+// ErrQueueFull is returned.
+func (s *AnalysisService) SubmitBatch(archive *multipart.FileHeader, opts AnalysisOptions) (*models.BatchResult, error) {
+	// An unknown profile would fail every image, so it fails the batch
+	if _, err := s.profileMacroParams(opts); err != nil {
+		return nil, err
+	}
+
+	file, err := archive.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open archive: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := zip.NewReader(file, archive.Size)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidArchive, err)
+	}
+
+	if s.config.MaxBatchSize > 0 {
+		images := 0
+		for _, entry := range reader.File {
+			if isBatchImage(entry) {
+				images++
+			}
+		}
+		if images > s.config.MaxBatchSize {
+			return nil, fmt.Errorf("%w: %d images, the limit is %d", ErrBatchTooLarge, images, s.config.MaxBatchSize)
+		}
+	}
+
+	batch := &models.BatchResult{
+		BatchID:     uuid.New().String(),
+		AnalysisIDs: []string{},
+	}
+	opts.BatchID = batch.BatchID
+
+	queueFull := false
+	skip := func(name, reason string) {
+		batch.Skipped = append(batch.Skipped, models.BatchSkip{Filename: name, Reason: reason})
+	}
+
+	for _, entry := range reader.File {
+		if !isBatchImage(entry) {
+			continue
+		}
+		switch {
+		case !IsSupportedImage(entry.Name):
+			skip(entry.Name, "unsupported file type")
+			continue
+		case s.config.MaxFileSize > 0 && entry.UncompressedSize64 > uint64(s.config.MaxFileSize):
+			skip(entry.Name, fmt.Sprintf("larger than %d bytes", s.config.MaxFileSize))
+			continue
+		}
+
+		analysisID := uuid.New().String()
+		err := s.submitUpload(context.Background(), analysisID, zipUpload(entry), opts)
+		switch {
+		case err == nil:
+			batch.AnalysisIDs = append(batch.AnalysisIDs, analysisID)
+		case errors.Is(err, ErrQueueFull):
+			queueFull = true
+			skip(entry.Name, "analysis queue is full")
+		default:
+			// A failure after the record was created is reported by the analysis itself
+			if _, getErr := s.store.Get(analysisID); getErr == nil {
+				batch.AnalysisIDs = append(batch.AnalysisIDs, analysisID)
+			} else {
+				skip(entry.Name, err.Error())
+			}
+		}
+	}
+
+	if len(batch.AnalysisIDs) == 0 {
+		if queueFull {
+			return nil, ErrQueueFull
+		}
+		return nil, ErrEmptyBatch
+	}
+
+	s.logger.WithField("batch_id", batch.BatchID).
+		WithField("analyses", len(batch.AnalysisIDs)).
+		WithField("skipped", len(batch.Skipped)).
+		Info("Batch submitted")
+
+	return batch, nil
+}
+
+// GetBatchSummary aggregates the status and purity of the analyses in a batch.
+// Every figure is taken from one listing of the store, so the counts agree
+// with each other even while analyses of the batch are finishing.
+func (s *AnalysisService) GetBatchSummary(batchID string) (*models.BatchSummary, error) {
+	results, total, err := s.store.List(ListFilter{BatchID: batchID, Sort: SortCreatedAt})
+	if err != nil {
+		return nil, err
+	}
+	if total == 0 {
+		return nil, ErrBatchNotFound
+	}
+
+	summary := &models.BatchSummary{
+		BatchID:  batchID,
+		Total:    total,
+		Analyses: make([]models.BatchItem, 0, len(results)),
+	}
+	var purities []float64
+	for _, result := range results {
+		item := models.BatchItem{
+			AnalysisID: result.ID,
+			Filename:   result.Filename,
+			Status:     result.Status,
+			Error:      result.Error,
+		}
+		switch result.Status {
+		case models.StatusPending:
+			summary.Pending++
+		case models.StatusProcessing:
+			summary.Processing++
+		case models.StatusCompleted:
+			summary.Completed++
+			item.PurityPercentage = result.PurityPercentage
+			purities = append(purities, result.PurityPercentage)
+		case models.StatusFailed:
+			summary.Failed++
+		case models.StatusCancelled:
+			summary.Cancelled++
+		}
+		summary.Analyses = append(summary.Analyses, item)
+	}
+
+	finished := summary.Completed + summary.Failed + summary.Cancelled
+	summary.CompletionPercentage = float64(finished) / float64(total) * 100
+	summary.BatchStatus = models.StatusProcessing
+	if finished == total {
+		summary.BatchStatus = models.StatusCompleted
+	}
+	if len(purities) > 0 {
+		mean, stdDev := meanStdDev(purities)
+		summary.MeanPurity = &mean
+		summary.PurityStdDev = &stdDev
+	}
+
+	return summary, nil
+}
+
+// meanStdDev returns the mean and sample standard deviation of values; the
+// deviation of a single value is 0
+func meanStdDev(values []float64) (float64, float64) {
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+
+	var squares float64
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(squares / float64(len(values)-1))
+}
+
+// isBatchImage reports whether an archive entry is a candidate image rather
+// than a directory or metadata added by the archiver
+func isBatchImage(entry *zip.File) bool {
+	if entry.FileInfo().IsDir() {
+		return false
+	}
+	name := entry.Name
+	if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), ".") {
+		return false
+	}
+	return true
+}
+
+// zipUpload wraps a batch archive entry; the archive must stay open until the
+// upload has been saved
+func zipUpload(entry *zip.File) upload {
+	return upload{
+		name: entry.Name,
+		size: int64(entry.UncompressedSize64),
+		open: func() (io.ReadCloser, error) { return entry.Open() },
+	}
+}
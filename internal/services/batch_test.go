@@ -0,0 +1,141 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newZipArchive builds a ZIP archive holding the given name/content entries
+func newZipArchive(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	writer := zip.NewWriter(buf)
+	for name, content := range entries {
+		w, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestSubmitBatch_QueuesEachImage(t *testing.T) {
+	service := newTestService(t, &config.Config{MaxBatchSize: 10}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	image := splitImagePNG(t, 50)
+	archive := newZipArchive(t, map[string][]byte{
+		"a.png":            image,
+		"nested/b.PNG":     image,
+		"notes.txt":        []byte("not an image"),
+		"__MACOSX/._a.png": []byte("resource fork"),
+		".hidden.png":      image,
+		"nested/":          nil,
+	})
+
+	batch, err := service.SubmitBatch(newFileHeader(t, "samples.zip", archive), AnalysisOptions{SampleGroup: "line-1"})
+	require.NoError(t, err)
+	require.Len(t, batch.AnalysisIDs, 2)
+	require.Len(t, batch.Skipped, 1)
+	assert.Equal(t, "notes.txt", batch.Skipped[0].Filename)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	for _, id := range batch.AnalysisIDs {
+		result, err := service.WaitForAnalysis(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, batch.BatchID, result.BatchID)
+		assert.Equal(t, "line-1", result.SampleGroup)
+	}
+
+	summary, err := service.GetBatchSummary(batch.BatchID)
+	require.NoError(t, err)
+	assert.Equal(t, 2, summary.Total)
+	assert.Equal(t, 2, summary.Completed)
+	assert.Equal(t, 100.0, summary.CompletionPercentage)
+	require.NotNil(t, summary.MeanPurity)
+	assert.Equal(t, 80.0, *summary.MeanPurity)
+	assert.Equal(t, 0.0, *summary.PurityStdDev)
+}
+
+func TestSubmitBatch_Limits(t *testing.T) {
+	service := newTestService(t, &config.Config{MaxBatchSize: 1}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	image := splitImagePNG(t, 50)
+	archive := newZipArchive(t, map[string][]byte{"a.png": image, "b.png": image})
+
+	_, err := service.SubmitBatch(newFileHeader(t, "samples.zip", archive), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrBatchTooLarge)
+
+	// Hidden files and directories do not count towards the limit
+	archive = newZipArchive(t, map[string][]byte{"a.png": image, ".DS_Store": {0}, "__MACOSX/._a.png": {0}})
+	batch, err := service.SubmitBatch(newFileHeader(t, "samples.zip", archive), AnalysisOptions{})
+	require.NoError(t, err)
+	assert.Len(t, batch.AnalysisIDs, 1)
+}
+
+func TestSubmitBatch_RejectsUnusableArchives(t *testing.T) {
+	service := newTestService(t, &config.Config{MaxBatchSize: 10}, &fakeRunner{})
+
+	_, err := service.SubmitBatch(newFileHeader(t, "samples.zip", []byte("not a zip")), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrInvalidArchive)
+
+	archive := newZipArchive(t, map[string][]byte{"readme.md": []byte("# samples")})
+	_, err = service.SubmitBatch(newFileHeader(t, "samples.zip", archive), AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrEmptyBatch)
+}
+
+func TestGetBatchSummary(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, result := range []*models.AnalysisResult{
+		{ID: "a", Status: models.StatusCompleted, PurityPercentage: 80},
+		{ID: "b", Status: models.StatusCompleted, PurityPercentage: 90},
+		{ID: "c", Status: models.StatusFailed, Error: "Fiji crashed"},
+		{ID: "d", Status: models.StatusPending},
+	} {
+		result.BatchID = "batch-1"
+		result.CreatedAt = created.Add(time.Duration(i) * time.Second)
+		require.NoError(t, service.store.Create(result))
+	}
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "other", Status: models.StatusCompleted, CreatedAt: created}))
+
+	summary, err := service.GetBatchSummary("batch-1")
+	require.NoError(t, err)
+	assert.Equal(t, 4, summary.Total)
+	assert.Equal(t, 2, summary.Completed)
+	assert.Equal(t, 1, summary.Failed)
+	assert.Equal(t, 1, summary.Pending)
+	assert.Equal(t, 75.0, summary.CompletionPercentage)
+	assert.Equal(t, models.StatusProcessing, summary.BatchStatus)
+	assert.Equal(t, 85.0, *summary.MeanPurity)
+	assert.InDelta(t, math.Sqrt(50), *summary.PurityStdDev, 1e-9)
+	require.Len(t, summary.Analyses, 4)
+	assert.Equal(t, "Fiji crashed", summary.Analyses[2].Error)
+
+	// The batch completes once its last analysis is terminal, whatever the outcome
+	_, err = service.update("d", func(result *models.AnalysisResult) error {
+		result.Status = models.StatusCancelled
+		return nil
+	})
+	require.NoError(t, err)
+	summary, err = service.GetBatchSummary("batch-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, summary.BatchStatus)
+	assert.Equal(t, 100.0, summary.CompletionPercentage)
+
+	_, err = service.GetBatchSummary("missing")
+	assert.ErrorIs(t, err, ErrBatchNotFound)
+}
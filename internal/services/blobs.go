@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gypsum-analysis-api/internal/storage"
+)
+
+// putImage stores the verified upload at path in the blob store under key
+func (s *AnalysisService) putImage(ctx context.Context, key, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open staged upload: %w", err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to read staged upload: %w", err)
+	}
+	return s.blobs.Put(ctx, key, file, info.Size())
+}
+
+// fetchImage makes the image stored under key available as a local file and
+// returns a function that removes the copy again. Images in a local blob store
+// are used in place.
+func (s *AnalysisService) fetchImage(ctx context.Context, analysisID, key string) (string, func(), error) {
+	path, err := s.localImagePath(analysisID, key)
+	if _, ok := s.blobs.(*storage.LocalBlobStore); ok || err != nil {
+		return path, func() {}, err
+	}
+
+	blob, err := s.blobs.Get(ctx, key)
+	if err != nil {
+		return "", nil, err
+	}
+	defer blob.Close()
+
+	s.tempFiles.Register(path)
+	if _, err := copyToFile(blob, path); err != nil {
+		s.tempFiles.Remove(path)
+		return "", nil, err
+	}
+	return path, func() { s.tempFiles.Remove(path) }, nil
+}
+
+// localImagePath returns where Fiji reads the image stored under key: the
+// blob itself in a local store, otherwise a copy in TempDir
+func (s *AnalysisService) localImagePath(analysisID, key string) (string, error) {
+	if local, ok := s.blobs.(*storage.LocalBlobStore); ok {
+		return local.Path(key)
+	}
+	return filepath.Join(s.config.TempDir, fmt.Sprintf("%s_input%s", analysisID, filepath.Ext(key))), nil
+}
+
+// deleteImage removes the image stored under key and reports whether it is gone
+func (s *AnalysisService) deleteImage(analysisID, key string) bool {
+	if err := s.blobs.Delete(context.Background(), key); err != nil {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Warn("Failed to remove uploaded image")
+		return false
+	}
+	return true
+}
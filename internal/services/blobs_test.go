@@ -0,0 +1,111 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// memBlobStore is a remote blob store stand-in that keeps blobs in memory
+type memBlobStore struct {
+	mutex sync.Mutex
+	blobs map[string][]byte
+}
+
+func (m *memBlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.blobs[key] = data
+	return nil
+}
+
+func (m *memBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	data, ok := m.blobs[key]
+	if !ok {
+		return nil, storage.ErrBlobNotFound
+	}
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+func (m *memBlobStore) Delete(ctx context.Context, key string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	delete(m.blobs, key)
+	return nil
+}
+
+func (m *memBlobStore) Location(key string) string {
+	return fmt.Sprintf("mem://%s", key)
+}
+
+// localCopyRunner records whether the analyzed image was on local disk while Fiji ran
+type localCopyRunner struct {
+	path    string
+	present bool
+}
+
+func (r *localCopyRunner) Run(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+	_, err := os.Stat(r.path)
+	r.present = err == nil
+	return []byte(fijiOutput(80, 20)), nil
+}
+
+func TestRemoteBlobStore_ImageCopiedOnlyForAnalysis(t *testing.T) {
+	cfg := &config.Config{TempDir: t.TempDir()}
+	runner := &localCopyRunner{path: filepath.Join(cfg.TempDir, "remote-1_input.png")}
+	service := newTestService(t, cfg, runner)
+	blobs := &memBlobStore{blobs: make(map[string][]byte)}
+	service.blobs = blobs
+
+	content := splitImagePNG(t, 80)
+	require.NoError(t, service.AnalyzeGypsumImage("remote-1", newFileHeader(t, "sample.png", content), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("remote-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, "remote-1.png", result.ImageKey)
+	assert.Equal(t, "mem://remote-1.png", result.ImagePath)
+
+	assert.True(t, runner.present, "Fiji reads a local copy")
+	assert.NoFileExists(t, runner.path, "the local copy is removed after the run")
+	entries, err := os.ReadDir(cfg.TempDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotContains(t, entry.Name(), "remote-1_upload", "the staged upload is removed once stored")
+	}
+
+	assert.Empty(t, blobs.blobs, "the image is released after the analysis")
+	assert.True(t, result.ImageRemoved)
+}
+
+func TestRemoteBlobStore_KeepImages(t *testing.T) {
+	service := newTestService(t, &config.Config{KeepImages: true}, &fakeRunner{output: fijiOutput(80, 20)})
+	blobs := &memBlobStore{blobs: make(map[string][]byte)}
+	service.blobs = blobs
+
+	content := splitImagePNG(t, 80)
+	require.NoError(t, service.AnalyzeGypsumImage("remote-2", newFileHeader(t, "sample.png", content), AnalysisOptions{}))
+	assert.Equal(t, content, blobs.blobs["remote-2.png"])
+
+	_, err := service.DeleteAnalysis("remote-2")
+	require.NoError(t, err)
+	assert.Empty(t, blobs.blobs, "deleting the analysis deletes its image")
+}
@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// activeResultCacheTTL is how long results that may still change are cached.
+// Progress is not invalidated as it is recorded, so it lags by at most this.
+const activeResultCacheTTL = 5 * time.Second
+
+// cacheTimeout bounds each cache request, so that a slow cache only delays a
+// lookup briefly before the store is used instead
+const cacheTimeout = 500 * time.Millisecond
+
+// cachedResult is the cached form of a result. The macro and owner are not
+// part of the result's JSON, so they are carried alongside.
+type cachedResult struct {
+	*models.AnalysisResult
+	Macro string `json:"macro,omitempty"`
+	Owner string `json:"owner,omitempty"`
+}
+
+// resultCacheKey returns the cache key of an analysis result
+func resultCacheKey(analysisID string) string {
+	return "gypsum:analysis:" + analysisID
+}
+
+// cachedResult returns the cached copy of a result, if there is one. Cache
+// failures are logged and treated as misses.
+func (s *AnalysisService) cachedResult(analysisID string) (*models.AnalysisResult, bool) {
+	if s.cache == nil {
+		return nil, false
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+	value, ok, err := s.cache.Get(ctx, resultCacheKey(analysisID))
+	if err != nil {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Warn("Failed to read cached result")
+		return nil, false
+	}
+	if !ok {
+		return nil, false
+	}
+
+	var cached cachedResult
+	if err := json.Unmarshal(value, &cached); err != nil || cached.AnalysisResult == nil {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Warn("Ignoring unreadable cached result")
+		return nil, false
+	}
+	cached.AnalysisResult.Macro = cached.Macro
+	cached.AnalysisResult.Owner = cached.Owner
+	return cached.AnalysisResult, true
+}
+
+// cacheResult caches a result: finished ones for REDIS_TTL_SECONDS, others
+// only for activeResultCacheTTL
+func (s *AnalysisService) cacheResult(result *models.AnalysisResult) {
+	if s.cache == nil {
+		return
+	}
+
+	ttl := activeResultCacheTTL
+	if isTerminal(result.Status) {
+		ttl = time.Duration(s.config.RedisTTLSeconds) * time.Second
+	}
+	value, err := json.Marshal(cachedResult{AnalysisResult: result, Macro: result.Macro, Owner: result.Owner})
+	if err != nil {
+		s.logger.WithField("analysis_id", result.ID).WithError(err).Warn("Failed to encode result for the cache")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+	if err := s.cache.Set(ctx, resultCacheKey(result.ID), value, ttl); err != nil {
+		s.logger.WithField("analysis_id", result.ID).WithError(err).Warn("Failed to cache result")
+	}
+}
+
+// invalidateResult drops the cached copy of a result after it has changed
+func (s *AnalysisService) invalidateResult(analysisID string) {
+	if s.cache == nil {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cacheTimeout)
+	defer cancel()
+	if err := s.cache.Delete(ctx, resultCacheKey(analysisID)); err != nil {
+		s.logger.WithField("analysis_id", analysisID).WithError(err).Warn("Failed to invalidate cached result")
+	}
+}
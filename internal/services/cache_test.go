@@ -0,0 +1,125 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+)
+
+// memoryCache is a CacheStore that records the TTL of each entry
+type memoryCache struct {
+	mutex   sync.Mutex
+	entries map[string][]byte
+	ttls    map[string]time.Duration
+	err     error
+}
+
+func newMemoryCache() *memoryCache {
+	return &memoryCache{entries: make(map[string][]byte), ttls: make(map[string]time.Duration)}
+}
+
+func (c *memoryCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	value, ok := c.entries[key]
+	return value, ok, c.err
+}
+
+func (c *memoryCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.err != nil {
+		return c.err
+	}
+	c.entries[key] = value
+	c.ttls[key] = ttl
+	return nil
+}
+
+func (c *memoryCache) Delete(ctx context.Context, key string) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	delete(c.entries, key)
+	delete(c.ttls, key)
+	return c.err
+}
+
+func (c *memoryCache) Close() error { return nil }
+
+func (c *memoryCache) ttl(key string) (time.Duration, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	ttl, ok := c.ttls[key]
+	return ttl, ok
+}
+
+func TestGetAnalysisStatus_Cached(t *testing.T) {
+	service := newTestService(t, &config.Config{RedisTTLSeconds: 60}, &fakeRunner{})
+	cache := newMemoryCache()
+	service.cache = cache
+
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "cached-1", Status: models.StatusProcessing, CreatedAt: time.Now(), Owner: "sub:lab-1"}))
+
+	result, err := service.GetAnalysisStatus("cached-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusProcessing, result.Status)
+	ttl, ok := cache.ttl(resultCacheKey("cached-1"))
+	require.True(t, ok, "the lookup should populate the cache")
+	assert.Equal(t, activeResultCacheTTL, ttl)
+
+	// Progress is recorded without invalidating, so the cached copy is served
+	service.recordProgress("cached-1", 40)
+	result, err = service.GetAnalysisStatus("cached-1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, result.Progress)
+
+	// Every other change invalidates the entry
+	_, err = service.update("cached-1", func(result *models.AnalysisResult) error {
+		result.Status = models.StatusCompleted
+		result.PurityPercentage = 91
+		result.Macro = "run(\"8-bit\");"
+		return nil
+	})
+	require.NoError(t, err)
+	_, ok = cache.ttl(resultCacheKey("cached-1"))
+	assert.False(t, ok)
+
+	result, err = service.GetAnalysisStatus("cached-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	ttl, _ = cache.ttl(resultCacheKey("cached-1"))
+	assert.Equal(t, 60*time.Second, ttl)
+
+	// The cached copy is the whole result, macro and owner included
+	cached, ok := service.cachedResult("cached-1")
+	require.True(t, ok)
+	assert.Equal(t, 91.0, cached.PurityPercentage)
+	assert.Equal(t, "run(\"8-bit\");", cached.Macro)
+	assert.Equal(t, "sub:lab-1", cached.Owner)
+
+	_, err = service.DeleteAnalysis("cached-1")
+	require.NoError(t, err)
+	_, err = service.GetAnalysisStatus("cached-1")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestGetAnalysisStatus_CacheFailureFallsBack(t *testing.T) {
+	service := newTestService(t, &config.Config{RedisTTLSeconds: 60}, &fakeRunner{})
+	cache := newMemoryCache()
+	cache.err = errors.New("connection refused")
+	service.cache = cache
+
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "cached-2", Status: models.StatusPending, CreatedAt: time.Now()}))
+
+	result, err := service.GetAnalysisStatus("cached-2")
+	require.NoError(t, err)
+	assert.Equal(t, "cached-2", result.ID)
+}
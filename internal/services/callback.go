@@ -0,0 +1,100 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+)
+
+const (
+	callbackAttempts = 3
+	callbackTimeout  = 10 * time.Second
+)
+
+// callbackBackoff is the delay before the first retry; it doubles on each attempt
+var callbackBackoff = time.Second
+
+// deliverCallback posts the final result to the analysis callback URL, retrying
+// failed deliveries. Failures are logged and never affect the stored result.
+// Retries are abandoned once the webhook dispatcher gives up on shutdown.
+func (s *AnalysisService) deliverCallback(result models.AnalysisResult) {
+	ctx := s.webhooks.ctx
+	body, err := json.Marshal(result)
+	if err != nil {
+		s.logger.WithField("analysis_id", result.ID).WithError(err).Error("Failed to encode callback payload")
+		return
+	}
+
+	client := s.callbackClient
+	delay := callbackBackoff
+
+	for attempt := 1; attempt <= callbackAttempts; attempt++ {
+		err = postCallback(ctx, client, result.CallbackURL, body)
+		if err == nil {
+			s.logger.WithField("analysis_id", result.ID).WithField("attempt", attempt).Info("Callback delivered")
+			return
+		}
+
+		s.logger.WithField("analysis_id", result.ID).
+			WithField("attempt", attempt).
+			WithError(err).
+			Warn("Callback delivery failed")
+
+		if attempt < callbackAttempts {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				s.logger.WithField("analysis_id", result.ID).WithField("attempt", attempt).Warn("Abandoning callback delivery on shutdown")
+				return
+			}
+			delay *= 2
+		}
+	}
+
+	s.logger.WithField("analysis_id", result.ID).Error("Giving up on callback delivery")
+}
+
+// newPublicClient returns an HTTP client for callbacks and webhooks. It only
+// connects to public addresses, checked after the host name is resolved so
+// that a name pointing at an internal service is refused as well, and it
+// ignores proxy settings, which would otherwise bypass that check.
+func newPublicClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: config.PublicDialControl}
+	return &http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: timeout,
+			MaxIdleConns:        10,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// postCallback sends a single callback request and treats non-2xx responses as failures
+func postCallback(ctx context.Context, client *http.Client, callbackURL string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, callbackURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("callback returned status %d", resp.StatusCode)
+	}
+
+	return nil
+}
@@ -0,0 +1,394 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ErrUploadNotFound is returned for chunked uploads that were never started,
+// have been completed or have expired
+var ErrUploadNotFound = errors.New("upload not found")
+
+// ErrInvalidChunk is returned for chunk indexes outside [0, maxUploadChunks)
+var ErrInvalidChunk = errors.New("invalid chunk index")
+
+// ErrIncompleteUpload is returned when the chunks of an upload do not match
+// the number it is completed with
+var ErrIncompleteUpload = errors.New("upload is incomplete")
+
+// ErrUploadCompleting is returned for chunks sent while the upload is being
+// completed
+var ErrUploadCompleting = errors.New("upload is being completed")
+
+// ErrUploadTooLarge is returned when the chunks of an upload add up to more
+// than MAX_FILE_SIZE
+var ErrUploadTooLarge = errors.New("upload exceeds the maximum file size")
+
+// ErrTooManyUploads is returned when starting an upload would exceed
+// MAX_OPEN_UPLOADS, overall or for the client
+var ErrTooManyUploads = errors.New("too many open uploads")
+
+// maxUploadChunks bounds the number of chunks of one upload
+const maxUploadChunks = 10000
+
+// uploadCleanupInterval is how often expired uploads are looked for, unless
+// UPLOAD_TTL is shorter
+const uploadCleanupInterval = time.Minute
+
+// chunkDirSuffix ends the names of the directories in TempDir that hold the
+// chunks of an upload
+const chunkDirSuffix = "_chunks"
+
+// ChunkedUploads tracks the uploads sent in chunks. Each upload keeps its
+// chunks in its own directory of TempDir until it is completed or expires.
+// Only the client that started an upload can add chunks to or complete it.
+type ChunkedUploads struct {
+	dir          string
+	maxSize      int64
+	maxOpen      int
+	maxPerClient int
+	mutex        sync.Mutex
+	uploads      map[string]*chunkedUpload
+}
+
+// chunkedUpload is an upload whose chunks are still arriving. Chunk sizes are
+// kept by index; opts.Owner is the client that started it.
+type chunkedUpload struct {
+	filename   string
+	opts       AnalysisOptions
+	dir        string
+	chunks     map[int]int64
+	updated    time.Time
+	completing bool
+}
+
+// NewChunkedUploads keeps chunks under dir and rejects uploads larger than
+// maxSize bytes. At most maxOpen uploads, and maxPerClient per client, are
+// open at once. Zero limits leave them unbounded.
+func NewChunkedUploads(dir string, maxSize int64, maxOpen, maxPerClient int) *ChunkedUploads {
+	return &ChunkedUploads{
+		dir:          dir,
+		maxSize:      maxSize,
+		maxOpen:      maxOpen,
+		maxPerClient: maxPerClient,
+		uploads:      make(map[string]*chunkedUpload),
+	}
+}
+
+// Start registers a new upload of filename by the client opts.Owner and
+// creates its chunk directory
+func (u *ChunkedUploads) Start(filename string, opts AnalysisOptions) (string, error) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	if u.maxOpen > 0 && len(u.uploads) >= u.maxOpen {
+		return "", fmt.Errorf("%w: %d uploads are open", ErrTooManyUploads, len(u.uploads))
+	}
+	if u.maxPerClient > 0 {
+		open := 0
+		for _, pending := range u.uploads {
+			if pending.opts.Owner == opts.Owner {
+				open++
+			}
+		}
+		if open >= u.maxPerClient {
+			return "", fmt.Errorf("%w: complete or abandon one of your %d open uploads first", ErrTooManyUploads, open)
+		}
+	}
+
+	uploadID := uuid.New().String()
+	dir := filepath.Join(u.dir, uploadID+chunkDirSuffix)
+	if err := os.Mkdir(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+
+	u.uploads[uploadID] = &chunkedUpload{
+		filename: filename,
+		opts:     opts,
+		dir:      dir,
+		chunks:   make(map[int]int64),
+		updated:  time.Now(),
+	}
+	return uploadID, nil
+}
+
+// lookup returns the upload when owner started it. Uploads of other clients
+// are reported as ErrUploadNotFound. The caller holds the mutex.
+func (u *ChunkedUploads) lookup(owner, uploadID string) (*chunkedUpload, error) {
+	pending, ok := u.uploads[uploadID]
+	if !ok || pending.opts.Owner != owner {
+		return nil, ErrUploadNotFound
+	}
+	return pending, nil
+}
+
+// Write stores chunk index of an upload started by owner. The chunk is written
+// to a temporary file and renamed into place, so a chunk file is always
+// complete; a chunk sent again replaces the earlier one.
+func (u *ChunkedUploads) Write(owner, uploadID string, index int, r io.Reader) (int64, error) {
+	if index < 0 || index >= maxUploadChunks {
+		return 0, fmt.Errorf("%w: %d is not between 0 and %d", ErrInvalidChunk, index, maxUploadChunks-1)
+	}
+
+	u.mutex.Lock()
+	pending, err := u.lookup(owner, uploadID)
+	if err != nil {
+		u.mutex.Unlock()
+		return 0, err
+	}
+	if pending.completing {
+		u.mutex.Unlock()
+		return 0, ErrUploadCompleting
+	}
+	limit := int64(-1)
+	if u.maxSize > 0 {
+		limit = u.maxSize
+		for i, size := range pending.chunks {
+			if i != index {
+				limit -= size
+			}
+		}
+	}
+	dir := pending.dir
+	// A chunk in progress keeps the upload from expiring
+	pending.updated = time.Now()
+	u.mutex.Unlock()
+
+	// The directory is gone when the upload expired in the meantime
+	temp, err := os.CreateTemp(dir, "*.tmp")
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrUploadNotFound
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to create chunk file: %w", err)
+	}
+	defer os.Remove(temp.Name())
+
+	if limit >= 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	size, err := io.Copy(temp, r)
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to write chunk: %w", err)
+	}
+	if limit >= 0 && size > limit {
+		return 0, ErrUploadTooLarge
+	}
+	if err := os.Rename(temp.Name(), chunkPath(dir, index)); errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrUploadNotFound
+	} else if err != nil {
+		return 0, fmt.Errorf("failed to store chunk: %w", err)
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	pending, ok := u.uploads[uploadID]
+	if !ok {
+		return 0, ErrUploadNotFound
+	}
+	pending.chunks[index] = size
+	pending.updated = time.Now()
+	return size, nil
+}
+
+// begin marks an upload started by owner as completing once it holds exactly
+// the chunks 0 to totalChunks-1, so that no more chunks are accepted while it
+// is reassembled
+func (u *ChunkedUploads) begin(owner, uploadID string, totalChunks int) (*chunkedUpload, error) {
+	if totalChunks < 1 || totalChunks > maxUploadChunks {
+		return nil, fmt.Errorf("%w: total_chunks must be between 1 and %d", ErrIncompleteUpload, maxUploadChunks)
+	}
+
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	pending, err := u.lookup(owner, uploadID)
+	if err != nil {
+		return nil, err
+	}
+	if pending.completing {
+		return nil, ErrUploadCompleting
+	}
+
+	var total int64
+	for index, size := range pending.chunks {
+		if index >= totalChunks {
+			return nil, fmt.Errorf("%w: chunk %d is beyond total_chunks %d", ErrIncompleteUpload, index, totalChunks)
+		}
+		total += size
+	}
+	for index := 0; index < totalChunks; index++ {
+		if _, ok := pending.chunks[index]; !ok {
+			return nil, fmt.Errorf("%w: chunk %d was not received", ErrIncompleteUpload, index)
+		}
+	}
+	if u.maxSize > 0 && total > u.maxSize {
+		return nil, ErrUploadTooLarge
+	}
+
+	pending.completing = true
+	return pending, nil
+}
+
+// end finishes completing an upload. A submitted upload is forgotten and its
+// chunks are deleted; otherwise it accepts chunks again.
+func (u *ChunkedUploads) end(uploadID string, submitted bool) {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+	pending, ok := u.uploads[uploadID]
+	if !ok {
+		return
+	}
+	if !submitted {
+		pending.completing = false
+		pending.updated = time.Now()
+		return
+	}
+	delete(u.uploads, uploadID)
+	os.RemoveAll(pending.dir)
+}
+
+// Expire deletes the uploads that received no chunk since cutoff, and chunk
+// directories left behind by an earlier run. It returns how many were removed.
+func (u *ChunkedUploads) Expire(cutoff time.Time) int {
+	u.mutex.Lock()
+	defer u.mutex.Unlock()
+
+	removed := 0
+	for uploadID, pending := range u.uploads {
+		if pending.completing || !pending.updated.Before(cutoff) {
+			continue
+		}
+		delete(u.uploads, uploadID)
+		os.RemoveAll(pending.dir)
+		removed++
+	}
+
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		return removed
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.IsDir() || !strings.HasSuffix(name, chunkDirSuffix) {
+			continue
+		}
+		if _, ok := u.uploads[strings.TrimSuffix(name, chunkDirSuffix)]; ok {
+			continue
+		}
+		if info, err := entry.Info(); err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if os.RemoveAll(filepath.Join(u.dir, name)) == nil {
+			removed++
+		}
+	}
+	return removed
+}
+
+// assemble concatenates the first totalChunks chunks of an upload, in order,
+// into path and returns its size
+func (c *chunkedUpload) assemble(path string, totalChunks int) (int64, error) {
+	out, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer out.Close()
+
+	var size int64
+	for index := 0; index < totalChunks; index++ {
+		chunk, err := os.Open(chunkPath(c.dir, index))
+		if err != nil {
+			return 0, err
+		}
+		n, err := io.Copy(out, chunk)
+		chunk.Close()
+		if err != nil {
+			return 0, err
+		}
+		size += n
+	}
+	return size, out.Close()
+}
+
+// chunkPath returns the file a chunk is stored in. Indexes are zero-padded
+// so the chunk files sort in upload order.
+func chunkPath(dir string, index int) string {
+	return filepath.Join(dir, fmt.Sprintf("%05d.part", index))
+}
+
+// fileUpload wraps a file on disk
+func fileUpload(name, path string, size int64) upload {
+	return upload{
+		name: name,
+		size: size,
+		open: func() (io.ReadCloser, error) { return os.Open(path) },
+	}
+}
+
+// InitUpload starts an upload of filename that is sent in chunks, to be
+// analyzed with opts once it is completed. The profile is checked up front.
+// The upload belongs to opts.Owner, the client starting it.
+func (s *AnalysisService) InitUpload(filename string, opts AnalysisOptions) (string, error) {
+	if _, err := s.profileMacroParams(s.applyProfileDefaults(opts)); err != nil {
+		return "", err
+	}
+	return s.uploads.Start(filename, opts)
+}
+
+// WriteChunk stores chunk index of an upload started by owner and returns its
+// size
+func (s *AnalysisService) WriteChunk(owner, uploadID string, index int, r io.Reader) (int64, error) {
+	return s.uploads.Write(owner, uploadID, index, r)
+}
+
+// CompleteUpload reassembles an upload started by owner from its chunks and
+// queues its analysis as SubmitAnalysis does. The chunks are deleted once the
+// analysis is queued; when it cannot be, they are kept so completion can be
+// retried.
+func (s *AnalysisService) CompleteUpload(ctx context.Context, owner, uploadID, analysisID string, totalChunks int) error {
+	pending, err := s.uploads.begin(owner, uploadID, totalChunks)
+	if err != nil {
+		return err
+	}
+
+	assembled := filepath.Join(s.config.TempDir, uploadID+"_assembled"+strings.ToLower(filepath.Ext(pending.filename)))
+	defer os.Remove(assembled)
+	size, err := pending.assemble(assembled, totalChunks)
+	if err != nil {
+		err = fmt.Errorf("failed to reassemble upload: %w", err)
+	} else {
+		err = s.submitUpload(ctx, analysisID, fileUpload(pending.filename, assembled, size), pending.opts)
+	}
+	s.uploads.end(uploadID, err == nil)
+	return err
+}
+
+// runUploadCleanup deletes expired chunked uploads until the service is closed
+func (s *AnalysisService) runUploadCleanup(ttl time.Duration) {
+	ticker := time.NewTicker(min(ttl, uploadCleanupInterval))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			if removed := s.uploads.Expire(now.Add(-ttl)); removed > 0 {
+				s.logger.WithField("uploads", removed).Info("Removed expired chunked uploads")
+			}
+		}
+	}
+}
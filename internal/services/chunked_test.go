@@ -0,0 +1,164 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompleteUpload_ReassemblesChunks(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	uploadID, err := service.InitUpload("sample.png", AnalysisOptions{SampleGroup: "kiln-3", Owner: testOwner})
+	require.NoError(t, err)
+
+	// Chunks may arrive in any order
+	content := splitImagePNG(t, 66)
+	third := len(content) / 3
+	chunks := [][]byte{content[:third], content[third : 2*third], content[2*third:]}
+	for _, index := range []int{2, 0, 1} {
+		size, err := service.WriteChunk(testOwner, uploadID, index, bytes.NewReader(chunks[index]))
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(chunks[index])), size)
+	}
+	assert.FileExists(t, filepath.Join(service.config.TempDir, uploadID+chunkDirSuffix, "00002.part"))
+
+	require.NoError(t, service.CompleteUpload(context.Background(), testOwner, uploadID, "chunked-1", 3))
+	result, err := service.WaitForAnalysis(context.Background(), "chunked-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status, result.Error)
+	assert.Equal(t, "sample.png", result.Filename)
+	assert.Equal(t, "kiln-3", result.SampleGroup, "the options given at init are applied")
+	assert.Equal(t, int64(len(content)), result.ImageSize)
+
+	// The chunks are gone and the upload cannot be completed twice
+	assert.NoDirExists(t, filepath.Join(service.config.TempDir, uploadID+chunkDirSuffix))
+	assert.ErrorIs(t, service.CompleteUpload(context.Background(), testOwner, uploadID, "chunked-2", 3), ErrUploadNotFound)
+}
+
+func TestCompleteUpload_MissingChunks(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	uploadID, err := service.InitUpload("sample.png", AnalysisOptions{Owner: testOwner})
+	require.NoError(t, err)
+	_, err = service.WriteChunk(testOwner, uploadID, 0, bytes.NewReader([]byte("part")))
+	require.NoError(t, err)
+	_, err = service.WriteChunk(testOwner, uploadID, 2, bytes.NewReader([]byte("part")))
+	require.NoError(t, err)
+
+	err = service.CompleteUpload(context.Background(), testOwner, uploadID, "chunked-3", 3)
+	assert.ErrorIs(t, err, ErrIncompleteUpload)
+	assert.ErrorContains(t, err, "chunk 1")
+
+	err = service.CompleteUpload(context.Background(), testOwner, uploadID, "chunked-3", 2)
+	assert.ErrorIs(t, err, ErrIncompleteUpload, "chunk 2 is beyond the total")
+
+	// The upload still accepts chunks after a failed completion
+	_, err = service.WriteChunk(testOwner, uploadID, 1, bytes.NewReader([]byte("part")))
+	assert.NoError(t, err)
+}
+
+func TestWriteChunk_Limits(t *testing.T) {
+	uploads := NewChunkedUploads(t.TempDir(), 10, 0, 0)
+	uploadID, err := uploads.Start("sample.tif", AnalysisOptions{})
+	require.NoError(t, err)
+
+	_, err = uploads.Write("", uploadID, -1, bytes.NewReader(nil))
+	assert.ErrorIs(t, err, ErrInvalidChunk)
+	_, err = uploads.Write("", "missing", 0, bytes.NewReader(nil))
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+
+	_, err = uploads.Write("", uploadID, 0, bytes.NewReader(make([]byte, 6)))
+	require.NoError(t, err)
+	_, err = uploads.Write("", uploadID, 1, bytes.NewReader(make([]byte, 6)))
+	assert.ErrorIs(t, err, ErrUploadTooLarge, "the chunks add up to more than the maximum size")
+	assert.NoFileExists(t, chunkPath(uploads.uploads[uploadID].dir, 1))
+
+	// A resent chunk replaces the earlier one instead of adding to it
+	_, err = uploads.Write("", uploadID, 0, bytes.NewReader(make([]byte, 10)))
+	assert.NoError(t, err)
+}
+
+func TestChunkedUploads_Expire(t *testing.T) {
+	dir := t.TempDir()
+	uploads := NewChunkedUploads(dir, 0, 0, 0)
+
+	uploadID, err := uploads.Start("sample.tif", AnalysisOptions{})
+	require.NoError(t, err)
+	_, err = uploads.Write("", uploadID, 0, bytes.NewReader([]byte("part")))
+	require.NoError(t, err)
+
+	// A chunk directory left behind by an earlier run
+	orphan := filepath.Join(dir, "earlier"+chunkDirSuffix)
+	require.NoError(t, os.Mkdir(orphan, 0755))
+	old := time.Now().Add(-2 * time.Hour)
+	require.NoError(t, os.Chtimes(orphan, old, old))
+
+	assert.Equal(t, 1, uploads.Expire(time.Now().Add(-time.Hour)), "the active upload is kept")
+	assert.NoDirExists(t, orphan)
+	assert.Contains(t, uploads.uploads, uploadID)
+
+	assert.Equal(t, 1, uploads.Expire(time.Now().Add(time.Minute)))
+	assert.NoDirExists(t, filepath.Join(dir, uploadID+chunkDirSuffix))
+	_, err = uploads.Write("", uploadID, 1, bytes.NewReader([]byte("part")))
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
+
+func TestChunkedUploads_Owner(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(66, 12)})
+
+	uploadID, err := service.InitUpload("sample.png", AnalysisOptions{Owner: testOwner})
+	require.NoError(t, err)
+
+	// Other clients cannot add chunks to or complete the upload
+	_, err = service.WriteChunk("sub:lab-2", uploadID, 0, bytes.NewReader([]byte("part")))
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+	assert.ErrorIs(t, service.CompleteUpload(context.Background(), "ip:192.0.2.1", uploadID, "owned-1", 1), ErrUploadNotFound)
+
+	_, err = service.WriteChunk(testOwner, uploadID, 0, bytes.NewReader(splitImagePNG(t, 66)))
+	require.NoError(t, err)
+	require.NoError(t, service.CompleteUpload(context.Background(), testOwner, uploadID, "owned-1", 1))
+}
+
+func TestChunkedUploads_OpenLimits(t *testing.T) {
+	uploads := NewChunkedUploads(t.TempDir(), 0, 3, 2)
+
+	first, err := uploads.Start("sample.tif", AnalysisOptions{Owner: "sub:lab-1"})
+	require.NoError(t, err)
+	_, err = uploads.Start("sample.tif", AnalysisOptions{Owner: "sub:lab-1"})
+	require.NoError(t, err)
+	_, err = uploads.Start("sample.tif", AnalysisOptions{Owner: "sub:lab-1"})
+	assert.ErrorIs(t, err, ErrTooManyUploads, "each client has its own limit")
+
+	_, err = uploads.Start("sample.tif", AnalysisOptions{Owner: "sub:lab-2"})
+	require.NoError(t, err)
+	_, err = uploads.Start("sample.tif", AnalysisOptions{Owner: "sub:lab-3"})
+	assert.ErrorIs(t, err, ErrTooManyUploads, "the overall limit applies across clients")
+
+	// Finishing an upload frees its place
+	uploads.end(first, true)
+	_, err = uploads.Start("sample.tif", AnalysisOptions{Owner: "sub:lab-3"})
+	assert.NoError(t, err)
+}
+
+func TestWriteChunk_AfterExpire(t *testing.T) {
+	dir := t.TempDir()
+	uploads := NewChunkedUploads(dir, 0, 0, 0)
+	uploadID, err := uploads.Start("sample.tif", AnalysisOptions{})
+	require.NoError(t, err)
+
+	// The upload expires while a chunk is on its way
+	pending := uploads.uploads[uploadID]
+	require.NoError(t, os.RemoveAll(pending.dir))
+	_, err = uploads.Write("", uploadID, 0, bytes.NewReader([]byte("part")))
+	assert.ErrorIs(t, err, ErrUploadNotFound)
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// releaseImage deletes the upload of a finished analysis. The path stays on
+// the result, which is marked as having its image removed.
+func (s *AnalysisService) releaseImage(analysisID, imageKey string) {
+	if !s.deleteImage(analysisID, imageKey) {
+		return
+	}
+
+	s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.ImageRemoved = true
+		return nil
+	})
+}
+
+// RunJanitor sweeps orphaned files immediately and then every interval,
+// removing registered temp files and files in the temp directory older than
+// ttl. It returns once the service is closed.
+func (s *AnalysisService) RunJanitor(ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for now := time.Now(); ; {
+		if removed := s.tempFiles.RemoveOlderThan(now.Add(-ttl)); removed > 0 {
+			s.logger.WithField("files", removed).Info("Removed expired temp files")
+		}
+		s.sweepTempDir(now.Add(-ttl))
+
+		select {
+		case <-s.done:
+			return
+		case now = <-ticker.C:
+		}
+	}
+}
+
+// runReaper removes finished results older than ttl every interval until the
+// service is closed
+func (s *AnalysisService) runReaper(ttl, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case now := <-ticker.C:
+			s.reapResults(now.Add(-ttl))
+		}
+	}
+}
+
+// reapResults deletes the records and files of analyses that finished before cutoff
+func (s *AnalysisService) reapResults(cutoff time.Time) {
+	results, _, err := s.store.List(ListFilter{})
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to list results for reaping")
+		return
+	}
+
+	reaped := 0
+	for _, result := range results {
+		if !isTerminal(result.Status) {
+			continue
+		}
+		finished := result.CreatedAt
+		if result.CompletedAt != nil {
+			finished = *result.CompletedAt
+		}
+		if !finished.Before(cutoff) {
+			continue
+		}
+
+		if err := s.store.Delete(result.ID); err != nil {
+			if !errors.Is(err, ErrAnalysisNotFound) {
+				s.logger.WithField("analysis_id", result.ID).WithError(err).Warn("Failed to reap result")
+			}
+			continue
+		}
+		s.invalidateResult(result.ID)
+		s.removeAnalysisFiles(result)
+		reaped++
+	}
+
+	if reaped > 0 {
+		s.logger.WithField("results", reaped).Info("Reaped expired results")
+	}
+}
+
+// sweepTempDir removes temp files last modified before cutoff unless they
+// belong to an analysis that has not finished. Manifest sidecars are kept as
+// they are the archival record of an analysis, and overlays are kept with
+// KEEP_IMAGES.
+func (s *AnalysisService) sweepTempDir(cutoff time.Time) {
+	entries, err := os.ReadDir(s.config.TempDir)
+	if err != nil {
+		s.logger.WithError(err).Warn("Failed to read temp directory")
+		return
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasSuffix(name, "_manifest.json") {
+			continue
+		}
+		if s.config.KeepImages && strings.HasSuffix(name, "_overlay.png") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoff) {
+			continue
+		}
+		if result, err := s.store.Get(tempFileAnalysisID(name)); err == nil && !isTerminal(result.Status) {
+			continue
+		}
+
+		if err := os.Remove(filepath.Join(s.config.TempDir, name)); err != nil && !os.IsNotExist(err) {
+			s.logger.WithField("file", name).WithError(err).Warn("Failed to remove orphaned temp file")
+			continue
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		s.logger.WithField("files", removed).Info("Removed orphaned temp files")
+	}
+}
+
+// tempFileAnalysisID returns the analysis a temp file was written for. Every
+// temp file name starts with the analysis ID, followed by "_" or the extension.
+func tempFileAnalysisID(name string) string {
+	if i := strings.IndexAny(name, "_."); i >= 0 {
+		return name[:i]
+	}
+	return name
+}
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReleaseImage_RemovedAfterAnalysis(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("cleanup-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("cleanup-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.NotEmpty(t, result.ImagePath, "the path is kept for reference")
+	assert.True(t, result.ImageRemoved)
+	assert.NoFileExists(t, result.ImagePath)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "cleanup-1_macro.ijm"))
+}
+
+func TestReleaseImage_FailedAnalysis(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: "no results"})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	assert.Error(t, service.AnalyzeGypsumImage("cleanup-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("cleanup-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, result.Status)
+	assert.True(t, result.ImageRemoved)
+	assert.NoFileExists(t, result.ImagePath)
+}
+
+func TestReleaseImage_KeepImages(t *testing.T) {
+	service := newTestService(t, &config.Config{KeepImages: true}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("cleanup-3", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("cleanup-3")
+	require.NoError(t, err)
+	assert.False(t, result.ImageRemoved)
+	assert.FileExists(t, result.ImagePath)
+}
+
+func TestSweepTempDir(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+	dir := service.config.TempDir
+
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "queued", Status: models.StatusPending}))
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "done", Status: models.StatusCompleted}))
+
+	old := time.Now().Add(-48 * time.Hour)
+	files := map[string]time.Time{
+		"queued.png":          old, // still needed by an unfinished analysis
+		"done.png":            old,
+		"done_manifest.json":  old, // archival sidecar
+		"unknown_macro.ijm":   old, // no record at all
+		"unknown_tile_0.png":  old,
+		"recent.png":          time.Now(),
+		"done_rois.zip":       old,
+		"unknown_rois.roi":    time.Now(),
+		"another-crash.jpg":   old,
+		"another-crash_x.ijm": time.Now(),
+	}
+	for name, modified := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+		require.NoError(t, os.Chtimes(path, modified, modified))
+	}
+
+	service.sweepTempDir(time.Now().Add(-24 * time.Hour))
+
+	for _, name := range []string{"queued.png", "done_manifest.json", "recent.png", "unknown_rois.roi", "another-crash_x.ijm"} {
+		assert.FileExists(t, filepath.Join(dir, name))
+	}
+	for _, name := range []string{"done.png", "unknown_macro.ijm", "unknown_tile_0.png", "done_rois.zip", "another-crash.jpg"} {
+		assert.NoFileExists(t, filepath.Join(dir, name))
+	}
+}
+
+func TestTempFileAnalysisID(t *testing.T) {
+	assert.Equal(t, "3f2a-11", tempFileAnalysisID("3f2a-11.png"))
+	assert.Equal(t, "3f2a-11", tempFileAnalysisID("3f2a-11_tile_4_macro.ijm"))
+	assert.Equal(t, "README", tempFileAnalysisID("README"))
+}
+
+func TestReaper_EvictsExpiredResults(t *testing.T) {
+	service := newTestService(t, &config.Config{ResultTTL: 60, ResultReapInterval: 1}, &fakeRunner{})
+	t.Cleanup(service.Close)
+
+	now := time.Now()
+	finishedLongAgo := now.Add(-time.Hour)
+	oldImage := filepath.Join(service.config.TempDir, "old.png")
+	require.NoError(t, os.WriteFile(oldImage, []byte("x"), 0644))
+
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "old", Status: models.StatusCompleted, CreatedAt: finishedLongAgo, CompletedAt: &finishedLongAgo, ImagePath: oldImage}))
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "recent", Status: models.StatusCompleted, CreatedAt: now, CompletedAt: &now}))
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "queued", Status: models.StatusPending, CreatedAt: finishedLongAgo}))
+
+	assert.Eventually(t, func() bool {
+		_, err := service.GetAnalysisStatus("old")
+		return errors.Is(err, ErrAnalysisNotFound)
+	}, 5*time.Second, 50*time.Millisecond)
+	assert.NoFileExists(t, oldImage)
+
+	_, err := service.GetAnalysisStatus("queued")
+	assert.NoError(t, err, "unfinished analyses are never reaped")
+	_, err = service.GetAnalysisStatus("recent")
+	assert.NoError(t, err)
+}
+
+func TestReaper_StopsOnClose(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	stopped := make(chan struct{})
+	go func() {
+		service.runReaper(time.Hour, time.Millisecond)
+		close(stopped)
+	}()
+
+	service.Close()
+	service.Close()
+	select {
+	case <-stopped:
+	case <-time.After(time.Second):
+		t.Fatal("reaper still running after Close")
+	}
+}
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// significantPurityDelta is the purity change, in percentage points, above
+// which a comparison is flagged as significant
+const significantPurityDelta = 2.0
+
+// ErrAnalysisNotCompleted is returned when an analysis that must have
+// results is pending, processing, failed or cancelled
+var ErrAnalysisNotCompleted = errors.New("analysis has not completed")
+
+// ErrSampleMismatch is returned when two analyses compared within a sample
+// do not share a sample ID
+var ErrSampleMismatch = errors.New("analyses are not of the same sample")
+
+// CompareAnalyses returns the change from analysis idA to analysis idB. Both
+// must have completed, and unless allowCrossSample is set both must carry the
+// same sample ID. Errors name the analysis they are about.
+func (s *AnalysisService) CompareAnalyses(idA, idB string, allowCrossSample bool) (*models.ComparisonResult, error) {
+	results := make([]*models.AnalysisResult, 2)
+	for i, id := range []string{idA, idB} {
+		result, err := s.GetAnalysisStatus(id)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", err, id)
+		}
+		if result.Status != models.StatusCompleted {
+			return nil, fmt.Errorf("%w: %s is %s", ErrAnalysisNotCompleted, id, result.Status)
+		}
+		results[i] = result
+	}
+	a, b := results[0], results[1]
+
+	if !allowCrossSample {
+		sampleA, sampleB := sampleID(a), sampleID(b)
+		switch {
+		case sampleA == "" || sampleB == "":
+			return nil, fmt.Errorf("%w: both analyses need a sample_id", ErrSampleMismatch)
+		case sampleA != sampleB:
+			return nil, fmt.Errorf("%w: %q and %q", ErrSampleMismatch, sampleA, sampleB)
+		}
+	}
+
+	comparison := &models.ComparisonResult{
+		A:                  a,
+		B:                  b,
+		PurityDelta:        b.PurityPercentage - a.PurityPercentage,
+		GypsumDelta:        b.GypsumContent - a.GypsumContent,
+		ParticleCountDelta: b.ParticleCount - a.ParticleCount,
+		ConfidenceDelta:    b.Confidence - a.Confidence,
+	}
+	comparison.Significant = math.Abs(comparison.PurityDelta) > significantPurityDelta
+	return comparison, nil
+}
+
+// sampleID returns the sample ID an analysis was submitted with, if any
+func sampleID(result *models.AnalysisResult) string {
+	if result.SampleMetadata == nil {
+		return ""
+	}
+	return result.SampleMetadata.SampleID
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+)
+
+func TestCompareAnalyses(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	sample := func(id string) *models.SampleMetadata {
+		return &models.SampleMetadata{SampleID: id}
+	}
+	for _, result := range []*models.AnalysisResult{
+		{ID: "raw", Status: models.StatusCompleted, PurityPercentage: 82.5, GypsumContent: 80, ParticleCount: 40, Confidence: 0.9, SampleMetadata: sample("S-1")},
+		{ID: "calcined", Status: models.StatusCompleted, PurityPercentage: 86, GypsumContent: 84.5, ParticleCount: 31, Confidence: 0.7, SampleMetadata: sample("S-1")},
+		{ID: "rerun", Status: models.StatusCompleted, PurityPercentage: 84, GypsumContent: 81, ParticleCount: 40, Confidence: 0.9, SampleMetadata: sample("S-1")},
+		{ID: "other", Status: models.StatusCompleted, PurityPercentage: 60, SampleMetadata: sample("S-2")},
+		{ID: "unlabelled", Status: models.StatusCompleted, PurityPercentage: 82},
+		{ID: "running", Status: models.StatusProcessing, SampleMetadata: sample("S-1")},
+		{ID: "failed", Status: models.StatusFailed, SampleMetadata: sample("S-1")},
+	} {
+		require.NoError(t, service.store.Create(result))
+	}
+
+	comparison, err := service.CompareAnalyses("raw", "calcined", false)
+	require.NoError(t, err)
+	assert.Equal(t, "raw", comparison.A.ID)
+	assert.Equal(t, "calcined", comparison.B.ID)
+	assert.InDelta(t, 3.5, comparison.PurityDelta, 1e-9)
+	assert.InDelta(t, 4.5, comparison.GypsumDelta, 1e-9)
+	assert.Equal(t, -9, comparison.ParticleCountDelta)
+	assert.InDelta(t, -0.2, comparison.ConfidenceDelta, 1e-9)
+	assert.True(t, comparison.Significant)
+
+	// A change of 1.5 points is within the noise
+	comparison, err = service.CompareAnalyses("raw", "rerun", false)
+	require.NoError(t, err)
+	assert.InDelta(t, 1.5, comparison.PurityDelta, 1e-9)
+	assert.False(t, comparison.Significant)
+
+	// Different or missing sample IDs need allow_cross_sample
+	_, err = service.CompareAnalyses("raw", "other", false)
+	assert.ErrorIs(t, err, ErrSampleMismatch)
+	_, err = service.CompareAnalyses("raw", "unlabelled", false)
+	assert.ErrorIs(t, err, ErrSampleMismatch)
+	comparison, err = service.CompareAnalyses("raw", "other", true)
+	require.NoError(t, err)
+	assert.InDelta(t, -22.5, comparison.PurityDelta, 1e-9)
+
+	_, err = service.CompareAnalyses("raw", "missing", false)
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+	assert.Contains(t, err.Error(), "missing")
+	for _, id := range []string{"running", "failed"} {
+		_, err = service.CompareAnalyses(id, "raw", false)
+		assert.ErrorIs(t, err, ErrAnalysisNotCompleted)
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"gypsum-analysis-api/internal/imaging"
+	"gypsum-analysis-api/internal/imaging/phash"
+	"gypsum-analysis-api/internal/models"
+)
+
+// duplicateMaxDistance is the largest perceptual hash distance, in bits, at
+// which an earlier upload is considered as a possible duplicate
+const duplicateMaxDistance = 4
+
+// completeDuplicate records the perceptual hash of a prepared upload. The hash
+// only narrows down the candidates: when an earlier completed analysis of a
+// byte-identical image, by SHA-256, used the same macro parameters and
+// calibration, the analysis is completed at once with a copy of its
+// measurements. It reports whether the analysis was completed.
+func (s *AnalysisService) completeDuplicate(job *preparedAnalysis) bool {
+	// Region measurements depend on the ROI file, not only the image
+	if job.roiPath != "" {
+		return false
+	}
+
+	imagePath, removeCopy, err := s.fetchImage(context.Background(), job.id, job.imageKey)
+	if err != nil {
+		s.jobLog(job).WithError(err).Warn("Skipping duplicate check")
+		return false
+	}
+	defer removeCopy()
+
+	hash, err := hashImage(imagePath)
+	if err != nil {
+		// Undecodable uploads are rejected when the analysis runs
+		s.jobLog(job).WithError(err).Debug("Skipping duplicate check")
+		return false
+	}
+	result, err := s.update(job.id, func(result *models.AnalysisResult) error {
+		result.PerceptualHash = formatPerceptualHash(hash)
+		return nil
+	})
+	if err != nil {
+		return false
+	}
+
+	candidates, err := s.store.FindSimilar(hash, duplicateMaxDistance)
+	if err != nil {
+		s.jobLog(job).WithError(err).Warn("Failed to look up duplicate images")
+		return false
+	}
+
+	var original *models.AnalysisResult
+	for _, candidate := range candidates {
+		if candidate.ID != job.id && sameImage(candidate, result) && sameSettings(candidate, result) {
+			original = candidate
+			break
+		}
+	}
+	if original == nil {
+		return false
+	}
+
+	imageConfig, format, _ := imaging.DecodeConfigFile(imagePath)
+	if !s.config.KeepImages {
+		s.releaseImage(job.id, job.imageKey)
+	}
+
+	completed, err := s.update(job.id, func(result *models.AnalysisResult) error {
+		now := time.Now()
+		result.Status = models.StatusCompleted
+		result.CompletedAt = &now
+		result.Progress = 100
+		result.DuplicateOf = original.ID
+		result.ImageFormat = format
+		result.ImageWidth = imageConfig.Width
+		result.ImageHeight = imageConfig.Height
+		copyMeasurements(result, original)
+		return s.signResult(result)
+	})
+	if err != nil {
+		return false
+	}
+
+	s.announceTerminal(completed)
+
+	s.jobLog(job).
+		WithField("duplicate_of", original.ID).
+		Info("Image matches an earlier analysis; reused its results")
+
+	if s.config.ManifestSidecar {
+		s.writeManifestSidecar(job.id)
+	}
+	return true
+}
+
+// hashImage computes the difference hash of an image file
+func hashImage(path string) (uint64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to open image: %w", err)
+	}
+	defer f.Close()
+
+	return phash.ComputeDHash(f)
+}
+
+// sameImage reports whether two analyses measured byte-identical images.
+// Perceptually similar images can still differ in ways that change the
+// measurements, so only an exact checksum match counts.
+func sameImage(a, b *models.AnalysisResult) bool {
+	return a.ImageSHA256 != "" && a.ImageSHA256 == b.ImageSHA256
+}
+
+// sameSettings reports whether two analyses were configured to measure the
+// same image the same way
+func sameSettings(a, b *models.AnalysisResult) bool {
+	if a.ROIFile != "" || b.ROIFile != "" {
+		return false
+	}
+	if (a.Parameters == nil) != (b.Parameters == nil) || (a.Parameters != nil && *a.Parameters != *b.Parameters) {
+		return false
+	}
+	if (a.ROI == nil) != (b.ROI == nil) || (a.ROI != nil && *a.ROI != *b.ROI) {
+		return false
+	}
+	return (a.Calibration == nil) == (b.Calibration == nil) &&
+		(a.Calibration == nil || *a.Calibration == *b.Calibration)
+}
+
+// copyMeasurements copies the numeric results of the original analysis
+func copyMeasurements(result, original *models.AnalysisResult) {
+	result.PurityPercentage = original.PurityPercentage
+	result.Confidence = original.Confidence
+	result.ConfidenceFactors = original.ConfidenceFactors
+	result.Estimated = original.Estimated
+	result.GypsumContent = original.GypsumContent
+	result.ImpurityContent = original.ImpurityContent
+	result.CalciteContent = original.CalciteContent
+	result.QuartzContent = original.QuartzContent
+	result.OtherMinerals = original.OtherMinerals
+	result.ThresholdValue = original.ThresholdValue
+	result.ThresholdLower = original.ThresholdLower
+	result.ThresholdUpper = original.ThresholdUpper
+	result.ParticleCount = original.ParticleCount
+	result.AverageParticleSize = original.AverageParticleSize
+	result.TotalArea = original.TotalArea
+	result.ImageArea = original.ImageArea
+	result.SpacingStats = original.SpacingStats
+	result.MeasurementUnit = original.MeasurementUnit
+	result.FijiVersion = original.FijiVersion
+}
@@ -0,0 +1,116 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"image/png"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingRunner prints a fixed result and counts the Fiji runs
+func countingRunner(output string, runs *atomic.Int32) FijiRunner {
+	return runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		runs.Add(1)
+		return []byte(output), nil
+	})
+}
+
+// submitAndWait submits an upload and waits for the analysis to finish
+func submitAndWait(t *testing.T, service *AnalysisService, analysisID string, content []byte, opts AnalysisOptions) *models.AnalysisResult {
+	t.Helper()
+
+	require.NoError(t, service.SubmitAnalysis(context.Background(), analysisID, newFileHeader(t, "sample.png", content), opts))
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	result, err := service.WaitForAnalysis(ctx, analysisID)
+	require.NoError(t, err)
+	return result
+}
+
+func TestDeduplicate_ReusesEarlierResult(t *testing.T) {
+	var runs atomic.Int32
+	service := newTestService(t, &config.Config{DeduplicateImages: true}, countingRunner(fijiOutput(77, 21), &runs))
+	image := splitImagePNG(t, 60)
+
+	original := submitAndWait(t, service, "dedupe-1", image, AnalysisOptions{})
+	require.Equal(t, models.StatusCompleted, original.Status)
+	assert.NotEmpty(t, original.PerceptualHash)
+	assert.Empty(t, original.DuplicateOf)
+
+	duplicate := submitAndWait(t, service, "dedupe-2", image, AnalysisOptions{SampleGroup: "resubmitted"})
+	assert.Equal(t, int32(1), runs.Load(), "Fiji runs only for the original")
+	assert.Equal(t, models.StatusCompleted, duplicate.Status)
+	assert.Equal(t, "dedupe-1", duplicate.DuplicateOf)
+	assert.Equal(t, 77.0, duplicate.PurityPercentage)
+	assert.Equal(t, 21, duplicate.ParticleCount)
+	assert.Equal(t, original.TotalArea, duplicate.TotalArea)
+	assert.Equal(t, 100, duplicate.Progress)
+	assert.Equal(t, "resubmitted", duplicate.SampleGroup)
+	assert.Equal(t, "png", duplicate.ImageFormat)
+	assert.True(t, duplicate.ImageRemoved)
+	assert.NoFileExists(t, duplicate.ImagePath)
+
+	// A third submission still points at the original analysis
+	third := submitAndWait(t, service, "dedupe-3", image, AnalysisOptions{})
+	assert.Equal(t, "dedupe-1", third.DuplicateOf)
+}
+
+func TestDeduplicate_DifferentSettingsOrImage(t *testing.T) {
+	var runs atomic.Int32
+	service := newTestService(t, &config.Config{DeduplicateImages: true}, countingRunner(fijiOutput(77, 21), &runs))
+	image := splitImagePNG(t, 60)
+
+	submitAndWait(t, service, "settings-1", image, AnalysisOptions{})
+
+	li := submitAndWait(t, service, "settings-2", image, AnalysisOptions{ThresholdMethod: "Li"})
+	assert.Empty(t, li.DuplicateOf, "another threshold method measures differently")
+
+	calibrated := submitAndWait(t, service, "settings-3", image, AnalysisOptions{PixelsPerMicron: 2})
+	assert.Empty(t, calibrated.DuplicateOf)
+
+	other := submitAndWait(t, service, "settings-4", splitImagePNG(t, 20), AnalysisOptions{})
+	assert.Empty(t, other.DuplicateOf)
+
+	assert.Equal(t, int32(4), runs.Load())
+}
+
+func TestDeduplicate_SimilarImageIsAnalyzed(t *testing.T) {
+	var runs atomic.Int32
+	service := newTestService(t, &config.Config{DeduplicateImages: true}, countingRunner(fijiOutput(77, 21), &runs))
+
+	original := submitAndWait(t, service, "similar-1", splitImagePNG(t, 60), AnalysisOptions{})
+
+	// The same pixels encoded differently share the perceptual hash but not
+	// the checksum
+	decoded, err := png.Decode(bytes.NewReader(splitImagePNG(t, 60)))
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, (&png.Encoder{CompressionLevel: png.NoCompression}).Encode(&buf, decoded))
+
+	similar := submitAndWait(t, service, "similar-2", buf.Bytes(), AnalysisOptions{})
+	require.Equal(t, original.PerceptualHash, similar.PerceptualHash)
+	assert.NotEqual(t, original.ImageSHA256, similar.ImageSHA256)
+	assert.Empty(t, similar.DuplicateOf)
+	assert.Equal(t, int32(2), runs.Load())
+}
+
+func TestDeduplicate_Disabled(t *testing.T) {
+	var runs atomic.Int32
+	service := newTestService(t, &config.Config{}, countingRunner(fijiOutput(77, 21), &runs))
+	image := splitImagePNG(t, 60)
+
+	submitAndWait(t, service, "disabled-1", image, AnalysisOptions{})
+	second := submitAndWait(t, service, "disabled-2", image, AnalysisOptions{})
+
+	assert.Empty(t, second.DuplicateOf)
+	assert.Empty(t, second.PerceptualHash)
+	assert.Equal(t, int32(2), runs.Load())
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// ErrNotCalibrated is returned when SI spatial output is requested for an image without a scale
+var ErrNotCalibrated = errors.New("SI spatial output requires a calibrated image; submit the analysis with pixels_per_micron or a scale bar")
+
+// ErrAnalysisFailed is returned for operations that need results from a failed
+// or cancelled analysis
+var ErrAnalysisFailed = errors.New("analysis did not complete and has no results")
+
+// MaxSignificantFigures is the most digits a float64 can meaningfully carry
+const MaxSignificantFigures = 17
+
+// metersPerMicron converts calibrated lengths to meters
+const metersPerMicron = 1e-6
+
+// ListCompletedBetween returns the completed analyses created between from and
+// to inclusive, oldest first. A zero bound leaves that side of the range open.
+func (s *AnalysisService) ListCompletedBetween(from, to time.Time) ([]*models.AnalysisResult, error) {
+	results, err := s.store.ListByDateRange(from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	completed := results[:0:0]
+	for _, result := range results {
+		if result.Status == models.StatusCompleted {
+			completed = append(completed, result)
+		}
+	}
+	return completed, nil
+}
+
+// ExportSI returns the quantities of a completed analysis with every spatial
+// value converted to SI base units and rounded to sigFigs significant figures
+func (s *AnalysisService) ExportSI(analysisID string, sigFigs int) (*models.SIExport, error) {
+	if sigFigs < 1 || sigFigs > MaxSignificantFigures {
+		return nil, fmt.Errorf("significant figures must be between 1 and %d", MaxSignificantFigures)
+	}
+
+	result, err := s.GetAnalysisStatus(analysisID)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case !isTerminal(result.Status):
+		return nil, ErrAnalysisInProgress
+	case result.Status != models.StatusCompleted:
+		return nil, ErrAnalysisFailed
+	}
+
+	quantities, err := siQuantities(result)
+	if err != nil {
+		return nil, err
+	}
+	for i := range quantities {
+		quantities[i].Value = roundSignificant(quantities[i].Value, sigFigs)
+	}
+
+	return &models.SIExport{
+		AnalysisID:         result.ID,
+		SignificantFigures: sigFigs,
+		Calibration:        result.Calibration,
+		Quantities:         quantities,
+	}, nil
+}
+
+// siQuantities converts the measurements of a calibrated result to SI units
+func siQuantities(result *models.AnalysisResult) ([]models.Quantity, error) {
+	if result.Calibration == nil || result.Calibration.PixelsPerMicron <= 0 {
+		return nil, ErrNotCalibrated
+	}
+
+	// Calibrated results are stored in microns, older ones in pixels
+	metersPerUnit := metersPerMicron / result.Calibration.PixelsPerMicron
+	if result.MeasurementUnit == micronUnit {
+		metersPerUnit = metersPerMicron
+	}
+	length := func(v float64) float64 { return v * metersPerUnit }
+	area := func(v float64) float64 { return v * metersPerUnit * metersPerUnit }
+
+	quantities := []models.Quantity{
+		{Name: "purity_percentage", Value: result.PurityPercentage, Unit: "%"},
+		{Name: "gypsum_content_percentage", Value: result.GypsumContent, Unit: "%"},
+		{Name: "impurity_content_percentage", Value: result.ImpurityContent, Unit: "%"},
+		{Name: "particle_count", Value: float64(result.ParticleCount), Unit: "1"},
+		{Name: "total_area", Value: area(result.TotalArea), Unit: "m^2"},
+		{Name: "image_area", Value: area(result.ImageArea), Unit: "m^2"},
+		{Name: "analysis_time", Value: float64(result.AnalysisTime) / 1000, Unit: "s"},
+	}
+
+	if result.ParticleCount > 0 {
+		quantities = append(quantities, models.Quantity{
+			Name: "mean_particle_area", Value: area(result.TotalArea / float64(result.ParticleCount)), Unit: "m^2",
+		})
+	}
+
+	if stats := result.SpacingStats; stats != nil {
+		quantities = append(quantities,
+			models.Quantity{Name: "spacing_mean", Value: length(stats.Mean), Unit: "m"},
+			models.Quantity{Name: "spacing_median", Value: length(stats.Median), Unit: "m"},
+			models.Quantity{Name: "spacing_min", Value: length(stats.Min), Unit: "m"},
+			models.Quantity{Name: "spacing_max", Value: length(stats.Max), Unit: "m"},
+		)
+	}
+
+	return quantities, nil
+}
+
+// roundSignificant rounds v to n significant figures
+func roundSignificant(v float64, n int) float64 {
+	if v == 0 || math.IsNaN(v) || math.IsInf(v, 0) {
+		return v
+	}
+	rounded, _ := strconv.ParseFloat(strconv.FormatFloat(v, 'g', n, 64), 64)
+	return rounded
+}
+
+// FormatSignificant formats v in scientific-friendly notation with n significant figures
+func FormatSignificant(v float64, n int) string {
+	return strconv.FormatFloat(v, 'g', n, 64)
+}
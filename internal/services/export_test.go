@@ -0,0 +1,121 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// quantityMap indexes exported quantities by name
+func quantityMap(quantities []models.Quantity) map[string]models.Quantity {
+	byName := make(map[string]models.Quantity, len(quantities))
+	for _, quantity := range quantities {
+		byName[quantity.Name] = quantity
+	}
+	return byName
+}
+
+func TestExportSI_ConvertsSpatialQuantities(t *testing.T) {
+	output := strings.Replace(fijiOutput(40, 4), "ANALYSIS_RESULTS_END", "centroid:0,0\ncentroid:30,40\nANALYSIS_RESULTS_END", 1)
+	service := newTestService(t, &config.Config{ComputeSpacing: true}, &fakeRunner{output: output})
+
+	// 2 px per µm: one pixel is 0.5 µm = 5e-7 m, one square pixel 2.5e-13 m²
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 40))
+	require.NoError(t, service.AnalyzeGypsumImage("si-1", file, AnalysisOptions{PixelsPerMicron: 2}))
+
+	export, err := service.ExportSI("si-1", 4)
+	require.NoError(t, err)
+	assert.Equal(t, 4, export.SignificantFigures)
+	assert.Equal(t, 2.0, export.Calibration.PixelsPerMicron)
+
+	quantities := quantityMap(export.Quantities)
+	assert.Equal(t, models.Quantity{Name: "total_area", Value: 1e-9, Unit: "m^2"}, quantities["total_area"])
+	assert.Equal(t, models.Quantity{Name: "image_area", Value: 2.5e-9, Unit: "m^2"}, quantities["image_area"])
+	assert.Equal(t, models.Quantity{Name: "mean_particle_area", Value: 2.5e-10, Unit: "m^2"}, quantities["mean_particle_area"])
+	assert.Equal(t, models.Quantity{Name: "spacing_mean", Value: 2.5e-5, Unit: "m"}, quantities["spacing_mean"])
+	assert.Equal(t, models.Quantity{Name: "purity_percentage", Value: 40, Unit: "%"}, quantities["purity_percentage"])
+	assert.Equal(t, "s", quantities["analysis_time"].Unit)
+}
+
+func TestExportSI_SignificantFigures(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+	completed := time.Now()
+	require.NoError(t, service.store.Create(&models.AnalysisResult{
+		ID:               "si-2",
+		Status:           models.StatusCompleted,
+		CompletedAt:      &completed,
+		PurityPercentage: 87.654321,
+		TotalArea:        12345,
+		ParticleCount:    3,
+		Calibration:      &models.Calibration{PixelsPerMicron: 3},
+	}))
+
+	export, err := service.ExportSI("si-2", 3)
+	require.NoError(t, err)
+
+	quantities := quantityMap(export.Quantities)
+	assert.Equal(t, 87.7, quantities["purity_percentage"].Value)
+	// 12345 px² / 9 px²/µm² = 1371.67 µm² = 1.37167e-9 m²
+	assert.Equal(t, 1.37e-9, quantities["total_area"].Value)
+	assert.Equal(t, "1.37e-09", FormatSignificant(quantities["total_area"].Value, 3))
+
+	_, err = service.ExportSI("si-2", 0)
+	assert.Error(t, err)
+}
+
+func TestExportSI_RequiresCalibration(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(40, 4)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 40))
+	require.NoError(t, service.AnalyzeGypsumImage("si-3", file, AnalysisOptions{}))
+
+	_, err := service.ExportSI("si-3", 4)
+	assert.ErrorIs(t, err, ErrNotCalibrated)
+	assert.Contains(t, err.Error(), "pixels_per_micron")
+}
+
+func TestExportSI_UnfinishedAnalyses(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "running", Status: models.StatusProcessing}))
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "failed", Status: models.StatusFailed}))
+
+	_, err := service.ExportSI("running", 4)
+	assert.ErrorIs(t, err, ErrAnalysisInProgress)
+
+	_, err = service.ExportSI("failed", 4)
+	assert.ErrorIs(t, err, ErrAnalysisFailed)
+
+	_, err = service.ExportSI("missing", 4)
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+}
+
+func TestListCompletedBetween(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, result := range []*models.AnalysisResult{
+		{ID: "early", Status: models.StatusCompleted},
+		{ID: "done", Status: models.StatusCompleted},
+		{ID: "failed", Status: models.StatusFailed},
+		{ID: "later", Status: models.StatusCompleted},
+	} {
+		result.CreatedAt = created.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, service.store.Create(result))
+	}
+
+	results, err := service.ListCompletedBetween(created.Add(time.Hour), created.Add(3*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, results, 2)
+	assert.Equal(t, "done", results[0].ID)
+	assert.Equal(t, "later", results[1].ID)
+
+	results, err = service.ListCompletedBetween(time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Len(t, results, 3)
+}
@@ -0,0 +1,64 @@
+package services
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// heartbeatMarker is printed by the analysis macro between processing steps
+const heartbeatMarker = "FIJI_HEARTBEAT"
+
+// heartbeatMonitor cancels a Fiji run when the macro stops printing heartbeats
+type heartbeatMonitor struct {
+	timeout time.Duration
+	beats   chan struct{}
+	fired   atomic.Bool
+}
+
+func newHeartbeatMonitor(timeout time.Duration) *heartbeatMonitor {
+	return &heartbeatMonitor{
+		timeout: timeout,
+		beats:   make(chan struct{}, 1),
+	}
+}
+
+// onLine records a heartbeat when the output line is the heartbeat marker
+func (m *heartbeatMonitor) onLine(line string) {
+	if strings.TrimSpace(line) != heartbeatMarker {
+		return
+	}
+	select {
+	case m.beats <- struct{}{}:
+	default:
+	}
+}
+
+// watch calls cancel if no heartbeat arrives within the timeout. It returns
+// when ctx is done, so callers should cancel ctx once the run finishes.
+func (m *heartbeatMonitor) watch(ctx context.Context, cancel context.CancelFunc) {
+	timer := time.NewTimer(m.timeout)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.beats:
+			if !timer.Stop() {
+				<-timer.C
+			}
+			timer.Reset(m.timeout)
+		case <-timer.C:
+			m.fired.Store(true)
+			cancel()
+			return
+		}
+	}
+}
+
+// stalled reports whether the run was cancelled for missing heartbeats
+func (m *heartbeatMonitor) stalled() bool {
+	return m.fired.Load()
+}
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// IdempotencyKeys remembers the analysis started for each Idempotency-Key so
+// that a retried upload returns it instead of starting another. Keys are
+// scoped per caller, so two callers may use the same key.
+type IdempotencyKeys struct {
+	ttl     time.Duration
+	mutex   sync.Mutex
+	entries map[idempotencyScope]idempotencyEntry
+}
+
+// idempotencyScope is a key as sent by one caller
+type idempotencyScope struct {
+	caller string
+	key    string
+}
+
+// idempotencyEntry is the analysis a key was reserved for and when it expires
+type idempotencyEntry struct {
+	analysisID string
+	expires    time.Time
+}
+
+// NewIdempotencyKeys creates an empty set of keys that are remembered for ttl
+func NewIdempotencyKeys(ttl time.Duration) *IdempotencyKeys {
+	return &IdempotencyKeys{ttl: ttl, entries: make(map[idempotencyScope]idempotencyEntry)}
+}
+
+// Reserve records analysisID for the caller's key unless the key is already
+// held. It returns the analysis the key belongs to and whether it was newly
+// reserved. Expired keys are dropped as a side effect.
+func (k *IdempotencyKeys) Reserve(caller, key, analysisID string) (string, bool) {
+	now := time.Now()
+	scope := idempotencyScope{caller: caller, key: key}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	for s, entry := range k.entries {
+		if !now.Before(entry.expires) {
+			delete(k.entries, s)
+		}
+	}
+
+	if entry, ok := k.entries[scope]; ok {
+		return entry.analysisID, false
+	}
+	k.entries[scope] = idempotencyEntry{analysisID: analysisID, expires: now.Add(k.ttl)}
+	return analysisID, true
+}
+
+// Release forgets the caller's key, so the upload can be retried after its
+// submission failed
+func (k *IdempotencyKeys) Release(caller, key string) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+	delete(k.entries, idempotencyScope{caller: caller, key: key})
+}
+
+// ReserveIdempotencyKey reserves the caller's Idempotency-Key for analysisID.
+// When the key was already used it returns the original analysis ID and
+// false; release the key with ReleaseIdempotencyKey if the submission fails.
+func (s *AnalysisService) ReserveIdempotencyKey(caller, key, analysisID string) (string, bool) {
+	return s.idempotencyKeys.Reserve(caller, key, analysisID)
+}
+
+// ReleaseIdempotencyKey forgets the caller's Idempotency-Key
+func (s *AnalysisService) ReleaseIdempotencyKey(caller, key string) {
+	s.idempotencyKeys.Release(caller, key)
+}
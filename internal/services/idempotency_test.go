@@ -0,0 +1,41 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotencyKeys(t *testing.T) {
+	keys := NewIdempotencyKeys(time.Hour)
+
+	id, reserved := keys.Reserve("sub:lab-a", "upload-1", "analysis-1")
+	assert.True(t, reserved)
+	assert.Equal(t, "analysis-1", id)
+
+	id, reserved = keys.Reserve("sub:lab-a", "upload-1", "analysis-2")
+	assert.False(t, reserved, "a retry returns the original analysis")
+	assert.Equal(t, "analysis-1", id)
+
+	id, reserved = keys.Reserve("sub:lab-b", "upload-1", "analysis-3")
+	assert.True(t, reserved, "keys are scoped per caller")
+	assert.Equal(t, "analysis-3", id)
+
+	keys.Release("sub:lab-a", "upload-1")
+	_, reserved = keys.Reserve("sub:lab-a", "upload-1", "analysis-4")
+	assert.True(t, reserved, "a released key can be reused")
+}
+
+func TestIdempotencyKeys_Expire(t *testing.T) {
+	keys := NewIdempotencyKeys(10 * time.Millisecond)
+
+	_, reserved := keys.Reserve("ip:10.0.0.1", "upload-1", "analysis-1")
+	assert.True(t, reserved)
+
+	time.Sleep(20 * time.Millisecond)
+	id, reserved := keys.Reserve("ip:10.0.0.1", "upload-1", "analysis-2")
+	assert.True(t, reserved, "expired keys start a new analysis")
+	assert.Equal(t, "analysis-2", id)
+	assert.Len(t, keys.entries, 1)
+}
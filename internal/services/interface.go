@@ -1,12 +1,49 @@
 package services
 
 import (
+	"context"
+	"io"
 	"mime/multipart"
+	"time"
+
 	"gypsum-analysis-api/internal/models"
 )
 
 // AnalysisServiceInterface defines the interface for analysis services
 type AnalysisServiceInterface interface {
-	AnalyzeGypsumImage(analysisID string, file *multipart.FileHeader) error
+	AnalyzeGypsumImage(analysisID string, file *multipart.FileHeader, opts AnalysisOptions) error
+	SubmitAnalysis(ctx context.Context, analysisID string, file *multipart.FileHeader, opts AnalysisOptions) error
+	SubmitBatch(archive *multipart.FileHeader, opts AnalysisOptions) (*models.BatchResult, error)
+	InitUpload(filename string, opts AnalysisOptions) (string, error)
+	WriteChunk(owner, uploadID string, index int, r io.Reader) (int64, error)
+	CompleteUpload(ctx context.Context, owner, uploadID, analysisID string, totalChunks int) error
+	PreviewMacro(filename string, opts AnalysisOptions) (string, error)
 	GetAnalysisStatus(analysisID string) (*models.AnalysisResult, error)
+	GetBatchSummary(batchID string) (*models.BatchSummary, error)
+	SummarizePurity(batchID string, from, to time.Time) (*models.PuritySummary, error)
+	CompareAnalyses(idA, idB string, allowCrossSample bool) (*models.ComparisonResult, error)
+	ListAnalyses(filter ListFilter) ([]*models.AnalysisResult, int, error)
+	WaitForAnalysis(ctx context.Context, analysisID string) (*models.AnalysisResult, error)
+	AnnotateAnalysis(analysisID string, annotation models.Annotation) (*models.AnalysisResult, error)
+	CancelAnalysis(analysisID string) error
+	DeleteAnalysis(analysisID string) (cancelled bool, err error)
+	GetPurityTrend(sampleGroup string, from, to time.Time) ([]models.TrendPoint, error)
+	GetAnalysisManifest(analysisID string) (*models.AnalysisManifest, error)
+	GetOverlay(ctx context.Context, analysisID string) (io.ReadCloser, error)
+	VerifyResult(analysisID string) (*models.ResultVerification, error)
+	ListCompletedBetween(from, to time.Time) ([]*models.AnalysisResult, error)
+	ExportSI(analysisID string, sigFigs int) (*models.SIExport, error)
+	Subscribe(analysisID string) (<-chan *models.AnalysisResult, func(), error)
+	SubscribeEvents() (<-chan models.StatusEvent, func())
+	RegisterWebhook(owner string, registration models.WebhookRegistration) (*models.Webhook, error)
+	ListWebhookDeliveries(owner, webhookID string) ([]*models.WebhookDelivery, error)
+	DeleteWebhook(owner, webhookID string) error
+	ListProfiles() ([]*models.Profile, error)
+	GetProfile(profileID string) (*models.Profile, error)
+	CreateProfile(request models.ProfileRequest) (*models.Profile, error)
+	UpdateProfile(profileID string, request models.ProfileRequest) (*models.Profile, error)
+	DeleteProfile(profileID string) error
+	ReserveIdempotencyKey(caller, key, analysisID string) (string, bool)
+	ReleaseIdempotencyKey(caller, key string)
+	QueueDepth() int
 }
@@ -0,0 +1,276 @@
+package services
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// gypsumMacro is everything an analysis macro is generated from. When
+// ROIPath is set, the analysis is restricted to the regions in that ROI file,
+// and when Crop is set the image is cropped to that rectangle first. When
+// ResultsPath is set, the summary results are also saved there in case Fiji's
+// output is lost, and when OverlayPath is set the outlines of the counted
+// particles are saved there as a PNG. Centroids prints every particle's
+// centroid for spacing statistics; PerParticle prints its centroid and area
+// instead, as needed to merge tiles.
+type gypsumMacro struct {
+	ImagePath   string
+	ROIPath     string
+	ResultsPath string
+	OverlayPath string
+	Crop        *models.Rectangle
+	Params      models.MacroParams
+	Centroids   bool
+	PerParticle bool
+}
+
+// writeGypsumMacro writes the macro generated from m to path and returns it
+func writeGypsumMacro(path string, m gypsumMacro) (string, error) {
+	macro := buildGypsumMacro(m)
+	return macro, os.WriteFile(path, []byte(macro), 0644)
+}
+
+// forwardSlashes returns path as a macro string literal expects it. ImageJ accepts
+// forward slashes on every platform, where backslashes would be escapes.
+func forwardSlashes(path string) string {
+	return strings.ReplaceAll(path, "\\", "/")
+}
+
+// buildGypsumMacro generates the ImageJ macro for a gypsum analysis. It only
+// depends on m, so previews match real runs exactly.
+func buildGypsumMacro(m gypsumMacro) string {
+	maxSize := "Infinity"
+	if m.Params.MaxParticleSize > 0 {
+		maxSize = strconv.FormatFloat(m.Params.MaxParticleSize, 'g', -1, 64)
+	}
+
+	// Per-particle centroids are only printed when spacing statistics are requested
+	centroidOutput := ""
+	if m.Centroids {
+		centroidOutput = `
+    for (i = 0; i < n; i++) {
+        print("centroid:" + getResult("X", i) + "," + getResult("Y", i));
+    }`
+	}
+	if m.PerParticle {
+		centroidOutput = `
+    for (i = 0; i < n; i++) {
+        print("particle:" + getResult("X", i) + "," + getResult("Y", i) + "," + getResult("Area", i));
+    }`
+	}
+
+	// Per-region purity is measured on the mask before particles are analyzed
+	roiSetup, roiOutput := "", ""
+	if m.ROIPath != "" {
+		roiSetup = fmt.Sprintf(`
+// Restrict analysis to the uploaded regions of interest
+roiManager("reset");
+roiManager("Open", "%s");
+nRois = roiManager("count");
+roiNames = newArray(nRois);
+roiPurity = newArray(nRois);
+for (r = 0; r < nRois; r++) {
+    roiManager("select", r);
+    roiNames[r] = Roi.getName;
+    getStatistics(roiArea, roiMean);
+    roiPurity[r] = roiMean / 255 * 100;
+}
+if (nRois > 1) {
+    roiManager("select", Array.getSequence(nRois));
+    roiManager("Combine");
+} else {
+    roiManager("select", 0);
+}
+getStatistics(analysisArea);
+`, forwardSlashes(m.ROIPath))
+		roiOutput = `
+    for (r = 0; r < nRois; r++) {
+        print("roi:" + r + "," + roiPurity[r] + "," + roiNames[r]);
+    }`
+	}
+
+	// Cropping happens before any processing so only the region is measured
+	cropSetup := ""
+	if m.Crop != nil {
+		cropSetup = fmt.Sprintf(`
+// Analyze only the requested region
+makeRectangle(%d, %d, %d, %d);
+run("Crop");
+`, m.Crop.X, m.Crop.Y, m.Crop.Width, m.Crop.Height)
+	}
+
+	// A zero sigma skips the blur
+	blur := ""
+	if m.Params.GaussianSigma > 0 {
+		blur = fmt.Sprintf(`
+run("Gaussian Blur...", "sigma=%g");`, m.Params.GaussianSigma)
+	}
+
+	// Each slice of a stack is measured on its own, then the stack is reduced
+	// to its average projection so the summary below describes one image.
+	// Slices are measured over the whole frame, ignoring any ROI set.
+	stackSetup := fmt.Sprintf(`
+// Analyze each slice of a stack separately
+slicePurity = newArray(0);
+sliceCount = newArray(0);
+if (nSlices > 1) {
+    stack = getTitle();
+    stackSize = nSlices;
+    slicePurity = newArray(stackSize);
+    sliceCount = newArray(stackSize);
+    for (s = 1; s <= stackSize; s++) {
+        selectImage(stack);
+        setSlice(s);
+        run("Duplicate...", "title=slice_" + s + " use");
+        if (bitDepth == 16) {
+            run("8-bit");
+        }
+        run("Enhance Contrast", "saturated=%g");%s
+        setAutoThreshold("%s");
+        run("Convert to Mask");
+        sliceArea = getWidth() * getHeight();
+        run("Set Measurements...", "area redirect=None decimal=3");
+        run("Analyze Particles...", "size=%g-%s circularity=%.2f-%.2f display clear include");
+        sliceTotal = 0;
+        for (i = 0; i < nResults; i++) {
+            sliceTotal = sliceTotal + getResult("Area", i);
+        }
+        slicePurity[s - 1] = minOf(sliceTotal / sliceArea * 100, 100);
+        sliceCount[s - 1] = nResults;
+        close();
+        print("%s");
+    }
+    selectImage(stack);
+    run("Z Project...", "projection=[Average Intensity]");
+    run("8-bit");
+}
+`, m.Params.ContrastSaturation, strings.ReplaceAll(blur, "\n", "\n        "), m.Params.ThresholdMethod,
+		m.Params.MinParticleSize, maxSize, m.Params.MinCircularity, m.Params.MaxCircularity, heartbeatMarker)
+	sliceOutput := `
+    for (s = 0; s < slicePurity.length; s++) {
+        print("slice:" + (s + 1) + "," + slicePurity[s] + "," + sliceCount[s]);
+    }`
+
+	// The backup file holds the same summary as the printed results block
+	backupOutput := ""
+	if m.ResultsPath != "" {
+		backupOutput = fmt.Sprintf(`
+    
+    // Also write to a per-analysis file as backup
+    File.saveString("ANALYSIS_RESULTS_START\npurity_percentage:" + purity + "\ngypsum_content:" + gypsumPercentage + "\nimpurity_content:" + (100 - gypsumPercentage) + "\nparticle_count:" + n + "\ntotal_area:" + totalArea + "\naverage_particle_size:" + averageParticleSize + "\nimage_area:" + imageArea + "\nthreshold_value:" + thresholdLower + "\nthreshold_lower:" + thresholdLower + "\nthreshold_upper:" + thresholdUpper + "\nfiji_version:" + getVersion() + "\nANALYSIS_RESULTS_END\n", "%s");`,
+			forwardSlashes(m.ResultsPath))
+	}
+
+	// show=Outlines leaves the outlines drawing as the active image
+	overlaySave := ""
+	if m.OverlayPath != "" {
+		overlaySave = fmt.Sprintf(`
+saveAs("PNG", "%s");`, forwardSlashes(m.OverlayPath))
+	}
+
+	return fmt.Sprintf(`
+// Gypsum Analysis Macro
+// This macro analyzes gypsum purity in mineral samples
+
+// Signal that Fiji is running the macro
+print("%s");
+
+// Open the image
+open("%s");
+originalImage = getTitle();
+%s%s
+print("%s");
+print("%s25");
+
+// Measure in pixels; the service applies the calibration when the analysis completes
+run("Set Scale...", "distance=0 known=0 unit=pixel");
+
+// Convert to 8-bit if needed
+if (bitDepth == 16) {
+    run("8-bit");
+}
+
+// Apply preprocessing
+run("Enhance Contrast", "saturated=%g");%s
+print("%s");
+
+// Threshold for gypsum detection (white/light areas)
+// Gypsum typically appears as white/light colored in images. Both bounds are
+// read before the mask replaces the thresholded image.
+setAutoThreshold("%s");
+getThreshold(thresholdLower, thresholdUpper);
+run("Convert to Mask");
+print("%s");
+print("%s50");
+analysisArea = getWidth() * getHeight();
+%s
+
+// Analyze particles
+run("Set Measurements...", "area centroid redirect=None decimal=3");
+run("Analyze Particles...", "size=%g-%s circularity=%.2f-%.2f show=Outlines display clear include");%s
+print("%s");
+print("%s75");
+
+// Get results
+n = nResults;
+if (n > 0) {
+    // Calculate total area
+    totalArea = 0;
+    for (i = 0; i < n; i++) {
+        area = getResult("Area", i);
+        totalArea = totalArea + area;
+    }
+    averageParticleSize = totalArea / n;
+    
+    // Calculate gypsum percentage (assuming white areas are gypsum)
+    imageArea = analysisArea;
+    gypsumPercentage = (totalArea / imageArea) * 100;
+    
+    // Estimate purity based on particle analysis
+    // This is a simplified model - in practice, you'd need more sophisticated analysis
+    purity = gypsumPercentage;
+    if (purity > 100) purity = 100;
+    if (purity < 0) purity = 0;
+    
+    // Output results using multiple methods for reliability
+    print("ANALYSIS_RESULTS_START");
+    print("purity_percentage:" + purity);
+    print("gypsum_content:" + gypsumPercentage);
+    print("impurity_content:" + (100 - gypsumPercentage));
+    print("particle_count:" + n);
+    print("total_area:" + totalArea);
+    print("average_particle_size:" + averageParticleSize);
+    print("image_area:" + imageArea);
+    print("threshold_value:" + thresholdLower);
+    print("threshold_lower:" + thresholdLower);
+    print("threshold_upper:" + thresholdUpper);
+    print("fiji_version:" + getVersion());%s%s%s
+    print("ANALYSIS_RESULTS_END");%s
+} else {
+    print("ANALYSIS_RESULTS_START");
+    print("purity_percentage:0");
+    print("gypsum_content:0");
+    print("impurity_content:100");
+    print("particle_count:0");
+    print("total_area:0");
+    print("average_particle_size:0");
+    print("image_area:" + analysisArea);
+    print("threshold_value:0");
+    print("threshold_lower:" + thresholdLower);
+    print("threshold_upper:" + thresholdUpper);
+    print("fiji_version:" + getVersion());%s%s
+    print("ANALYSIS_RESULTS_END");
+}
+
+// Close all windows
+close();
+`, heartbeatMarker, forwardSlashes(m.ImagePath), cropSetup, stackSetup, heartbeatMarker, progressMarker,
+		m.Params.ContrastSaturation, blur, heartbeatMarker,
+		m.Params.ThresholdMethod, heartbeatMarker, progressMarker, roiSetup,
+		m.Params.MinParticleSize, maxSize, m.Params.MinCircularity, m.Params.MaxCircularity, overlaySave, heartbeatMarker, progressMarker,
+		centroidOutput, roiOutput, sliceOutput, backupOutput, roiOutput, sliceOutput)
+}
@@ -0,0 +1,137 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+func TestBuildGypsumMacro(t *testing.T) {
+	defaults := DefaultMacroParams(macroConfig())
+	withParams := func(change func(p *models.MacroParams)) models.MacroParams {
+		params := defaults
+		change(&params)
+		return params
+	}
+
+	tests := []struct {
+		name     string
+		macro    gypsumMacro
+		contains []string
+		excludes []string
+	}{
+		{
+			name:  "defaults",
+			macro: gypsumMacro{ImagePath: "/tmp/a.png", Params: defaults},
+			contains: []string{
+				`open("/tmp/a.png");`,
+				`run("Set Scale...", "distance=0 known=0 unit=pixel");`,
+				`setAutoThreshold("Otsu");`,
+				`"size=10-Infinity circularity=0.00-1.00 show=Outlines display clear include"`,
+				`run("Enhance Contrast", "saturated=0.35");`,
+				`run("Gaussian Blur...", "sigma=1");`,
+			},
+			excludes: []string{`roiManager("Open"`, `run("Crop")`, `saveAs("PNG"`, "File.saveString", "centroid:", "particle:"},
+		},
+		{
+			name: "particle filter",
+			macro: gypsumMacro{ImagePath: "/tmp/a.png", Params: withParams(func(p *models.MacroParams) {
+				p.MinParticleSize, p.MaxParticleSize = 2.5, 400
+				p.MinCircularity, p.MaxCircularity = 0.25, 0.9
+			})},
+			contains: []string{`"size=2.5-400 circularity=0.25-0.90 show=Outlines`, `"size=2.5-400 circularity=0.25-0.90 display clear include"`},
+			excludes: []string{"Infinity"},
+		},
+		{
+			name: "threshold and no blur",
+			macro: gypsumMacro{ImagePath: "/tmp/a.png", Params: withParams(func(p *models.MacroParams) {
+				p.ThresholdMethod, p.GaussianSigma, p.ContrastSaturation = "Triangle dark", 0, 1.5
+			})},
+			contains: []string{`setAutoThreshold("Triangle dark");`, `run("Enhance Contrast", "saturated=1.5");`},
+			excludes: []string{"Gaussian Blur"},
+		},
+		{
+			name:     "crop",
+			macro:    gypsumMacro{ImagePath: "/tmp/a.png", Params: defaults, Crop: &models.Rectangle{X: 1, Y: 2, Width: 30, Height: 40}},
+			contains: []string{"makeRectangle(1, 2, 30, 40);\nrun(\"Crop\");"},
+		},
+		{
+			name: "windows paths",
+			macro: gypsumMacro{
+				ImagePath:   `C:\fiji\tmp\a b.tif`,
+				ROIPath:     `C:\fiji\tmp\a_rois.zip`,
+				ResultsPath: `C:\fiji\tmp\a_results.txt`,
+				OverlayPath: `\\share\overlays\a.png`,
+				Params:      defaults,
+			},
+			contains: []string{
+				`open("C:/fiji/tmp/a b.tif");`,
+				`roiManager("Open", "C:/fiji/tmp/a_rois.zip");`,
+				`"C:/fiji/tmp/a_results.txt");`,
+				`saveAs("PNG", "//share/overlays/a.png");`,
+			},
+			excludes: []string{`C:\`},
+		},
+		{
+			name:     "centroids",
+			macro:    gypsumMacro{ImagePath: "/tmp/a.png", Params: defaults, Centroids: true},
+			contains: []string{`print("centroid:"`},
+		},
+		{
+			name:     "per particle",
+			macro:    gypsumMacro{ImagePath: "/tmp/a.png", Params: defaults, Centroids: true, PerParticle: true},
+			contains: []string{`print("particle:"`},
+			excludes: []string{`print("centroid:"`},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			macro := buildGypsumMacro(tt.macro)
+			for _, want := range tt.contains {
+				assert.Contains(t, macro, want)
+			}
+			for _, unwanted := range tt.excludes {
+				assert.NotContains(t, macro, unwanted)
+			}
+			assert.Equal(t, macro, buildGypsumMacro(tt.macro), "the macro must only depend on its inputs")
+		})
+	}
+}
+
+func TestBuildGypsumMacro_CropPrecedesProcessing(t *testing.T) {
+	macro := buildGypsumMacro(gypsumMacro{ImagePath: "/tmp/a.png", Params: DefaultMacroParams(macroConfig()), Crop: &models.Rectangle{Width: 5, Height: 5}})
+	assert.Less(t, strings.Index(macro, `run("Crop")`), strings.Index(macro, `run("8-bit")`))
+}
+
+func TestBuildGypsumMacro_ThresholdBoundsReadBeforeMask(t *testing.T) {
+	macro := buildGypsumMacro(gypsumMacro{ImagePath: "/tmp/a.png", Params: DefaultMacroParams(macroConfig())})
+	bounds := strings.Index(macro, "getThreshold(thresholdLower, thresholdUpper);")
+	require.NotEqual(t, -1, bounds)
+	assert.Less(t, strings.Index(macro, "setAutoThreshold("), bounds)
+	assert.True(t, strings.HasPrefix(macro[bounds:], "getThreshold(thresholdLower, thresholdUpper);\nrun(\"Convert to Mask\");"))
+	assert.Contains(t, macro, `print("threshold_lower:" + thresholdLower);`)
+	assert.Contains(t, macro, `print("threshold_upper:" + thresholdUpper);`)
+}
+
+func TestWriteGypsumMacro(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "macro.ijm")
+	m := gypsumMacro{ImagePath: "/tmp/a.png", Params: DefaultMacroParams(macroConfig())}
+
+	macro, err := writeGypsumMacro(path, m)
+	require.NoError(t, err)
+	assert.Equal(t, buildGypsumMacro(m), macro)
+
+	written, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, macro, string(written))
+
+	_, err = writeGypsumMacro(filepath.Join(t.TempDir(), "missing", "macro.ijm"), m)
+	assert.Error(t, err)
+}
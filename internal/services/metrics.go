@@ -0,0 +1,16 @@
+package services
+
+import (
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// recordTerminal counts a finished analysis and, for completed analyses that
+// ran Fiji, observes its analysis time
+func (s *AnalysisService) recordTerminal(result *models.AnalysisResult) {
+	s.metrics.Jobs.WithLabelValues(string(result.Status)).Inc()
+	if result.Status == models.StatusCompleted && result.DuplicateOf == "" {
+		s.metrics.Duration.Observe((time.Duration(result.AnalysisTime) * time.Millisecond).Seconds())
+	}
+}
@@ -0,0 +1,33 @@
+package services
+
+import (
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetrics_CountTransitions(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("metrics-1", file, AnalysisOptions{}))
+	service.runner = &fakeRunner{output: "no results"}
+	assert.Error(t, service.AnalyzeGypsumImage("metrics-2", file, AnalysisOptions{}))
+
+	m := service.metrics
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.Submitted))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Jobs.WithLabelValues(string(models.StatusCompleted))))
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.Jobs.WithLabelValues(string(models.StatusFailed))))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.Processing))
+	assert.Equal(t, 0.0, testutil.ToFloat64(m.QueueDepth))
+
+	var duration dto.Metric
+	require.NoError(t, m.Duration.Write(&duration))
+	assert.Equal(t, uint64(1), duration.GetHistogram().GetSampleCount(), "only completed analyses are timed")
+}
@@ -0,0 +1,14 @@
+-- Analysis results are stored as JSON documents. Columns that are filtered on
+-- are duplicated out of the document so they can be indexed.
+CREATE TABLE analysis_results (
+    id           TEXT PRIMARY KEY,
+    status       TEXT NOT NULL,
+    sample_group TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL,
+    completed_at TIMESTAMPTZ,
+    macro        TEXT NOT NULL DEFAULT '',
+    result       JSONB NOT NULL
+);
+
+CREATE INDEX analysis_results_status_created_at ON analysis_results (status, created_at);
+CREATE INDEX analysis_results_sample_group_completed_at ON analysis_results (sample_group, completed_at);
@@ -0,0 +1,4 @@
+-- Analyses submitted together in a ZIP archive share a batch ID
+ALTER TABLE analysis_results ADD COLUMN batch_id TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX analysis_results_batch_id ON analysis_results (batch_id) WHERE batch_id <> '';
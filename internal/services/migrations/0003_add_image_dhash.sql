@@ -0,0 +1,3 @@
+-- Perceptual hash of the analyzed image, so resubmitted images can reuse
+-- earlier results
+ALTER TABLE analysis_results ADD COLUMN image_dhash BIGINT;
@@ -0,0 +1,12 @@
+-- Purity of completed analyses, so lists can be filtered on it. Unfinished
+-- analyses have none.
+ALTER TABLE analysis_results ADD COLUMN purity_percentage DOUBLE PRECISION;
+
+UPDATE analysis_results
+    SET purity_percentage = COALESCE((result->>'purity_percentage')::double precision, 0)
+    WHERE status = 'completed';
+
+-- Status filters are served by analysis_results_status_created_at; unfiltered
+-- lists and date ranges need created_at on its own
+CREATE INDEX analysis_results_created_at ON analysis_results (created_at);
+CREATE INDEX analysis_results_status_purity ON analysis_results (status, purity_percentage);
@@ -0,0 +1,3 @@
+-- Lists can be filtered on the sample ID of the sample metadata, which is
+-- only kept in the result document
+CREATE INDEX analysis_results_sample_id ON analysis_results ((result->'sample_metadata'->>'sample_id'));
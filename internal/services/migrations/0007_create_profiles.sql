@@ -0,0 +1,11 @@
+-- Analysis profiles: named presets of macro settings. Built-in profiles are
+-- written by the service at startup and cannot be deleted.
+CREATE TABLE profiles (
+    id          TEXT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    builtin     BOOLEAN NOT NULL DEFAULT FALSE,
+    params      JSONB NOT NULL,
+    created_at  TIMESTAMPTZ NOT NULL,
+    updated_at  TIMESTAMPTZ NOT NULL
+);
@@ -0,0 +1,6 @@
+-- The client that submitted an analysis or registered a webhook, as a token
+-- subject or client IP. Webhooks only receive events of their owner's analyses.
+ALTER TABLE analysis_results ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+ALTER TABLE webhooks ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+
+CREATE INDEX webhooks_owner ON webhooks (owner);
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"slices"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/imaging"
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/tracing"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// nativeThresholdMethod is the only threshold method of the native analyzer
+const nativeThresholdMethod = "Otsu"
+
+// AnalysisEngine returns the engine that runs analyses: the pure-Go analyzer
+// when ANALYSIS_ENGINE is native, otherwise Fiji
+func AnalysisEngine(cfg *config.Config) string {
+	if cfg.AnalysisEngine == config.EngineNative {
+		return models.EngineGoNative
+	}
+	return models.EngineFiji
+}
+
+// performNativeAnalysis analyzes an image with the pure-Go analyzer and
+// records the same measurements the Fiji macro reports. The analyzer cannot
+// read ImageJ ROI files, and settings it does not support are reported as
+// warnings.
+func (s *AnalysisService) performNativeAnalysis(ctx context.Context, analysisID, imagePath, roiPath string, crop *models.Rectangle, params models.MacroParams) error {
+	startTime := time.Now()
+
+	if roiPath != "" {
+		return s.updateResultWithError(analysisID, models.FailureBadInput, "ROI files can only be analyzed with Fiji, which is not installed")
+	}
+
+	img, _, err := imaging.DecodeFile(imagePath)
+	if err != nil {
+		return s.updateResultWithError(analysisID, models.FailureBadInput, fmt.Sprintf("Uploaded file is not a readable image: %v", err))
+	}
+	if crop != nil {
+		var ok bool
+		if img, ok = cropImage(img, *crop); !ok {
+			return s.updateResultWithError(analysisID, models.FailureBadInput, "Uploaded image cannot be cropped")
+		}
+	}
+	s.recordProgress(analysisID, 25)
+
+	_, span := tracer.Start(ctx, "native.analyze", trace.WithAttributes(tracing.AnalysisID.String(analysisID)))
+	analyzer := imaging.GoNativeAnalyzer{MinParticleSize: params.MinParticleSize, MaxParticleSize: params.MaxParticleSize}
+	// Large images are labeled in the tiles Fiji would analyze them in
+	if bounds := img.Bounds(); s.shouldTile(image.Config{Width: bounds.Dx(), Height: bounds.Dy()}) {
+		analyzer.TileSize, analyzer.TileOverlap = s.config.TileSize, s.config.TileOverlap
+	}
+	analysis, err := analyzer.Analyze(ctx, img)
+	endSpan(span, err)
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		return s.updateResultWithError(analysisID, models.FailureTimeout, fmt.Sprintf("%v after %d seconds", ErrAnalysisTimeout, s.timeoutSeconds(analysisID)))
+	case errors.Is(ctx.Err(), context.Canceled):
+		return s.cancelAnalysis(analysisID)
+	case err != nil:
+		return s.updateResultWithError(analysisID, models.FailureBadInput, fmt.Sprintf("Failed to analyze image: %v", err))
+	}
+	s.recordProgress(analysisID, 75)
+
+	if warnings := nativeWarnings(params); len(warnings) > 0 {
+		s.update(analysisID, func(result *models.AnalysisResult) error {
+			result.Warnings = append(slices.Clip(result.Warnings), warnings...)
+			return nil
+		})
+	}
+
+	// Fiji's gypsum content is not capped at 100%, unlike its purity
+	gypsum := analysis.TotalArea / analysis.ImageArea * 100
+	particleCount := len(analysis.Particles)
+	m := measurements{
+		results: map[string]float64{
+			"purity_percentage":     analysis.PurityPercentage(),
+			"gypsum_content":        gypsum,
+			"impurity_content":      100 - gypsum,
+			"total_area":            analysis.TotalArea,
+			"average_particle_size": analysis.AverageParticleSize(),
+			"image_area":            analysis.ImageArea,
+			"threshold_value":       float64(analysis.Threshold),
+			// Pixels above the Otsu threshold are foreground
+			"threshold_lower": min(float64(analysis.Threshold)+1, 255),
+			"threshold_upper": 255,
+		},
+		particleCount: &particleCount,
+	}
+	for _, particle := range analysis.Particles {
+		m.centroids = append(m.centroids, point{X: particle.CentroidX, Y: particle.CentroidY})
+	}
+
+	analysisTime := time.Since(startTime).Milliseconds()
+	if err := s.applyResults(analysisID, imagePath, m, analysisTime); err != nil {
+		return s.updateResultWithError(analysisID, models.FailureParse, fmt.Sprintf("Failed to record results: %v", err))
+	}
+
+	s.analysisLog(ctx, analysisID).WithField("particles", particleCount).Info("Analyzed with the go-native engine")
+	return s.completeAnalysis(ctx, analysisID, imagePath, analysisTime)
+}
+
+// nativeWarnings lists the requested settings the native analyzer ignores
+func nativeWarnings(params models.MacroParams) []string {
+	var warnings []string
+	if params.ThresholdMethod != "" && params.ThresholdMethod != nativeThresholdMethod {
+		warnings = append(warnings, fmt.Sprintf("The go-native engine always thresholds with %s; threshold_method %s was not applied", nativeThresholdMethod, params.ThresholdMethod))
+	}
+	if params.MinCircularity > 0 || (params.MaxCircularity > 0 && params.MaxCircularity < 1) {
+		warnings = append(warnings, "The go-native engine does not filter particles by circularity")
+	}
+	return warnings
+}
@@ -0,0 +1,143 @@
+package services
+
+import (
+	"bytes"
+	"encoding/json"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"strings"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalysisEngine(t *testing.T) {
+	assert.Equal(t, models.EngineFiji, AnalysisEngine(&config.Config{AnalysisEngine: config.EngineFiji}))
+	assert.Equal(t, models.EngineGoNative, AnalysisEngine(&config.Config{AnalysisEngine: config.EngineNative}))
+}
+
+func TestPerformNativeAnalysis(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{err: os.ErrNotExist})
+	service.engine = models.EngineGoNative
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("native-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("native-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status, result.Error)
+	assert.Equal(t, models.EngineGoNative, result.AnalysisEngine)
+	assert.InDelta(t, 80.0, result.PurityPercentage, 1e-9)
+	assert.InDelta(t, 20.0, result.ImpurityContent, 1e-9)
+	assert.Equal(t, 1, result.ParticleCount)
+	assert.Equal(t, 8000.0, result.TotalArea)
+	assert.Equal(t, 10000.0, result.ImageArea)
+	assert.Equal(t, 100, result.Progress)
+	assert.Empty(t, result.Warnings, "the default settings are all supported")
+}
+
+func TestPerformNativeAnalysis_Tiles(t *testing.T) {
+	service := newTestService(t, &config.Config{TileThresholdPixels: 1000, TileSize: 40, TileOverlap: 5}, &fakeRunner{err: os.ErrNotExist})
+	service.engine = models.EngineGoNative
+
+	// A grid of 4x4 particles, some of them on the seams between tile cores
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			if (x+2)%10 < 4 && (y+2)%10 < 4 {
+				img.SetGray(x, y, color.Gray{Y: 255})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	require.NoError(t, service.AnalyzeGypsumImage("native-tiles-1", newFileHeader(t, "sample.png", buf.Bytes()), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("native-tiles-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status, result.Error)
+	// The cut-off particles on the image edges are below the default minimum size
+	assert.Equal(t, 81, result.ParticleCount, "particles on a seam are counted once")
+}
+
+func TestPerformNativeAnalysis_CropsToRegion(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{err: os.ErrNotExist})
+	service.engine = models.EngineGoNative
+
+	// The region straddles the edge of the 80 white columns
+	region := models.Rectangle{X: 60, Y: 0, Width: 40, Height: 50}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("native-crop-1", file, AnalysisOptions{ROI: &region}))
+
+	result, err := service.GetAnalysisStatus("native-crop-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status, result.Error)
+	assert.Equal(t, &region, result.ROI)
+	assert.Equal(t, 2000.0, result.ImageArea, "only the region is measured")
+	assert.Equal(t, 1000.0, result.TotalArea)
+	assert.InDelta(t, 50.0, result.PurityPercentage, 1e-9)
+}
+
+func TestPerformNativeAnalysis_SameFieldsAsFiji(t *testing.T) {
+	// Everything the macro prints, for a two-tone image that thresholds above 0
+	output := strings.Replace(fijiOutput(80, 1), "image_area", "average_particle_size:8000\nimage_area", 1)
+	fiji := newTestService(t, &config.Config{}, &fakeRunner{output: output})
+	native := newTestService(t, &config.Config{}, &fakeRunner{})
+	native.engine = models.EngineGoNative
+
+	img := image.NewGray(image.Rect(0, 0, 100, 100))
+	for y := 0; y < 100; y++ {
+		for x := 0; x < 100; x++ {
+			img.SetGray(x, y, color.Gray{Y: 64})
+			if x < 80 {
+				img.SetGray(x, y, color.Gray{Y: 192})
+			}
+		}
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	fields := func(service *AnalysisService) map[string]any {
+		file := newFileHeader(t, "sample.png", buf.Bytes())
+		require.NoError(t, service.AnalyzeGypsumImage("engine-1", file, AnalysisOptions{}))
+		result, err := service.GetAnalysisStatus("engine-1")
+		require.NoError(t, err)
+		require.Equal(t, models.StatusCompleted, result.Status, result.Error)
+
+		data, err := json.Marshal(result)
+		require.NoError(t, err)
+		var fields map[string]any
+		require.NoError(t, json.Unmarshal(data, &fields))
+		return fields
+	}
+
+	fijiFields, nativeFields := fields(fiji), fields(native)
+	delete(fijiFields, "fiji_version")
+	// analysis_time_ms is omitted when a run takes under a millisecond
+	delete(fijiFields, "analysis_time_ms")
+	delete(nativeFields, "analysis_time_ms")
+	assert.Equal(t, "go-native", nativeFields["analysis_engine"])
+	assert.Equal(t, 64.0, nativeFields["threshold_value"])
+	for name := range fijiFields {
+		assert.Contains(t, nativeFields, name)
+	}
+	for name := range nativeFields {
+		assert.Contains(t, fijiFields, name)
+	}
+}
+
+func TestPerformNativeAnalysis_UnsupportedSettings(t *testing.T) {
+	params := DefaultMacroParams(macroConfig())
+	assert.Empty(t, nativeWarnings(params))
+
+	params.ThresholdMethod = "Li"
+	params.MinCircularity = 0.5
+	assert.Len(t, nativeWarnings(params), 2)
+}
@@ -0,0 +1,216 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"mime/multipart"
+	"strings"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+)
+
+// ErrInvalidParticleFilter is returned when requested particle filter settings
+// are negative, out of range or inverted
+var ErrInvalidParticleFilter = errors.New("invalid particle filter")
+
+// ErrInvalidPreprocessing is returned when requested blur or contrast settings
+// are negative or out of range
+var ErrInvalidPreprocessing = errors.New("invalid preprocessing")
+
+// ErrUnknownThresholdMethod is returned for threshold methods ImageJ does not provide
+var ErrUnknownThresholdMethod = errors.New("unknown threshold method")
+
+// ErrInvalidCalibration is returned when the requested image scale is
+// incomplete, not positive or given both directly and as a scale bar
+var ErrInvalidCalibration = errors.New("invalid calibration")
+
+// ErrInvalidSampleMetadata is returned when sample coordinates or depth are
+// out of range
+var ErrInvalidSampleMetadata = errors.New("invalid sample metadata")
+
+// AnalysisOptions carries per-request settings for an analysis
+type AnalysisOptions struct {
+	Profile     string
+	SampleGroup string
+	CallbackURL string
+	Metadata    map[string]string
+
+	// Owner identifies the submitting client; its webhooks receive the result
+	Owner string
+
+	// ProfileID optionally selects a stored profile whose macro settings
+	// replace the configured defaults; the overrides below still apply
+	ProfileID string
+
+	// Sample optionally describes the physical sample
+	Sample *models.SampleMetadata
+
+	// BatchID groups the analyses of one submitted archive
+	BatchID string
+
+	// PixelsPerMicron is the image scale; zero means uncalibrated
+	PixelsPerMicron float64
+
+	// Alternatively, a scale bar ScaleBarLength microns long that spans
+	// ScaleBarPixels pixels in the image
+	ScaleBarLength float64
+	ScaleBarPixels float64
+
+	// ThresholdMethod optionally replaces the default auto-threshold method
+	ThresholdMethod string
+
+	// Optional preprocessing overrides; nil keeps the configured default
+	GaussianSigma      *float64
+	ContrastSaturation *float64
+
+	// Optional particle filter overrides; nil keeps the default
+	MinParticleSize *float64
+	MaxParticleSize *float64
+	MinCircularity  *float64
+
+	// ROIFile optionally restricts the analysis to ImageJ regions of interest
+	ROIFile *multipart.FileHeader
+
+	// ROI optionally crops the image to a rectangle before analysis
+	ROI *models.Rectangle
+
+	// Timeout optionally replaces ANALYSIS_TIMEOUT for this analysis
+	Timeout time.Duration
+}
+
+// applyProfileDefaults merges the configured profile defaults into the options.
+// Values supplied with the request win over the profile's.
+func (s *AnalysisService) applyProfileDefaults(opts AnalysisOptions) AnalysisOptions {
+	profile, ok := s.config.Profiles[opts.Profile]
+	if opts.Profile == "" || !ok {
+		return opts
+	}
+
+	if opts.CallbackURL == "" {
+		opts.CallbackURL = profile.CallbackURL
+	}
+
+	if len(profile.Metadata) > 0 {
+		merged := make(map[string]string, len(profile.Metadata)+len(opts.Metadata))
+		for key, value := range profile.Metadata {
+			merged[key] = value
+		}
+		for key, value := range opts.Metadata {
+			merged[key] = value
+		}
+		opts.Metadata = merged
+	}
+
+	return opts
+}
+
+// MacroParams returns the default macro parameters of cfg with the requested
+// threshold method, preprocessing and particle filter overrides applied.
+// Unusable overrides return ErrUnknownThresholdMethod, ErrInvalidPreprocessing
+// or ErrInvalidParticleFilter.
+func (o AnalysisOptions) MacroParams(cfg *config.Config) (models.MacroParams, error) {
+	return o.macroParamsFrom(DefaultMacroParams(cfg))
+}
+
+// macroParamsFrom applies the requested overrides to params, such as those of
+// a profile, and validates the result like MacroParams
+func (o AnalysisOptions) macroParamsFrom(params models.MacroParams) (models.MacroParams, error) {
+	if o.ThresholdMethod != "" {
+		params.ThresholdMethod = o.ThresholdMethod
+	}
+	if params.ThresholdMethod != "" {
+		method, ok := config.ThresholdMethod(params.ThresholdMethod)
+		if !ok {
+			return params, fmt.Errorf("%w %q, expected one of: %s", ErrUnknownThresholdMethod, params.ThresholdMethod, strings.Join(config.ThresholdMethods, ", "))
+		}
+		params.ThresholdMethod = method
+	}
+	if o.GaussianSigma != nil {
+		params.GaussianSigma = *o.GaussianSigma
+	}
+	if o.ContrastSaturation != nil {
+		params.ContrastSaturation = *o.ContrastSaturation
+	}
+	if math.IsNaN(params.GaussianSigma) || params.GaussianSigma < 0 || params.GaussianSigma > config.MaxGaussianSigma {
+		return params, fmt.Errorf("%w: gaussian_sigma must be between 0 and %g", ErrInvalidPreprocessing, config.MaxGaussianSigma)
+	}
+	if math.IsNaN(params.ContrastSaturation) || params.ContrastSaturation < 0 || params.ContrastSaturation >= 100 {
+		return params, fmt.Errorf("%w: contrast_saturation must be a percentage below 100", ErrInvalidPreprocessing)
+	}
+	if o.MinParticleSize != nil {
+		params.MinParticleSize = *o.MinParticleSize
+	}
+	if o.MaxParticleSize != nil {
+		params.MaxParticleSize = *o.MaxParticleSize
+	}
+	if o.MinCircularity != nil {
+		params.MinCircularity = *o.MinCircularity
+	}
+
+	for _, v := range []float64{params.MinParticleSize, params.MaxParticleSize, params.MinCircularity, params.MaxCircularity} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return params, fmt.Errorf("%w: sizes and circularity must be non-negative numbers", ErrInvalidParticleFilter)
+		}
+	}
+	if params.MaxParticleSize > 0 && params.MaxParticleSize < params.MinParticleSize {
+		return params, fmt.Errorf("%w: max_particle_size %g is below min_particle_size %g", ErrInvalidParticleFilter, params.MaxParticleSize, params.MinParticleSize)
+	}
+	if params.MaxCircularity > 1 {
+		return params, fmt.Errorf("%w: max_circularity must not exceed 1", ErrInvalidParticleFilter)
+	}
+	if params.MinCircularity > params.MaxCircularity {
+		return params, fmt.Errorf("%w: min_circularity must not exceed %g", ErrInvalidParticleFilter, params.MaxCircularity)
+	}
+	return params, nil
+}
+
+// Calibration returns the image scale given either as PixelsPerMicron or as a
+// scale bar, or nil for an uncalibrated image. Unusable values return
+// ErrInvalidCalibration.
+func (o AnalysisOptions) Calibration() (*models.Calibration, error) {
+	scaleBar := o.ScaleBarLength != 0 || o.ScaleBarPixels != 0
+	for _, v := range []float64{o.PixelsPerMicron, o.ScaleBarLength, o.ScaleBarPixels} {
+		if math.IsNaN(v) || math.IsInf(v, 0) || v < 0 {
+			return nil, fmt.Errorf("%w: scales must be positive numbers", ErrInvalidCalibration)
+		}
+	}
+
+	switch {
+	case scaleBar && o.PixelsPerMicron > 0:
+		return nil, fmt.Errorf("%w: give either pixels_per_micron or a scale bar, not both", ErrInvalidCalibration)
+	case scaleBar && (o.ScaleBarLength == 0 || o.ScaleBarPixels == 0):
+		return nil, fmt.Errorf("%w: scale_bar_length and scale_bar_pixels must be given together", ErrInvalidCalibration)
+	case scaleBar:
+		return &models.Calibration{
+			PixelsPerMicron: o.ScaleBarPixels / o.ScaleBarLength,
+			ScaleBarLength:  o.ScaleBarLength,
+			ScaleBarPixels:  o.ScaleBarPixels,
+		}, nil
+	case o.PixelsPerMicron > 0:
+		return &models.Calibration{PixelsPerMicron: o.PixelsPerMicron}, nil
+	}
+	return nil, nil
+}
+
+// ValidateSampleMetadata checks that sample coordinates are valid latitudes
+// and longitudes and that the sampling depth is not negative
+func ValidateSampleMetadata(sample models.SampleMetadata) error {
+	for _, v := range []float64{sample.LocationLat, sample.LocationLon, sample.SamplingDepthM} {
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return fmt.Errorf("%w: coordinates and depth must be finite numbers", ErrInvalidSampleMetadata)
+		}
+	}
+
+	switch {
+	case sample.LocationLat < -90 || sample.LocationLat > 90:
+		return fmt.Errorf("%w: location_lat must be between -90 and 90", ErrInvalidSampleMetadata)
+	case sample.LocationLon < -180 || sample.LocationLon > 180:
+		return fmt.Errorf("%w: location_lon must be between -180 and 180", ErrInvalidSampleMetadata)
+	case sample.SamplingDepthM < 0:
+		return fmt.Errorf("%w: sampling_depth_m must not be negative", ErrInvalidSampleMetadata)
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// callbackRecorder is a test server that captures delivered callback results
+func callbackRecorder(t *testing.T) (*httptest.Server, chan models.AnalysisResult) {
+	t.Helper()
+
+	received := make(chan models.AnalysisResult, 4)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var result models.AnalysisResult
+		require.NoError(t, json.Unmarshal(body, &result))
+		received <- result
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, received
+}
+
+func TestProfileDefaults_Applied(t *testing.T) {
+	server, received := callbackRecorder(t)
+
+	cfg := &config.Config{Profiles: map[string]config.ProfileConfig{
+		"plant-a": {CallbackURL: server.URL, Metadata: map[string]string{"project": "alpha", "site": "north"}},
+	}}
+	service := newTestService(t, cfg, &fakeRunner{output: fijiOutput(82, 30)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("profile-1", file, AnalysisOptions{Profile: "plant-a"}))
+
+	result, err := service.GetAnalysisStatus("profile-1")
+	require.NoError(t, err)
+	assert.Equal(t, "plant-a", result.Profile)
+	assert.Equal(t, server.URL, result.CallbackURL)
+	assert.Equal(t, map[string]string{"project": "alpha", "site": "north"}, result.Metadata)
+
+	select {
+	case delivered := <-received:
+		assert.Equal(t, "profile-1", delivered.ID)
+		assert.Equal(t, models.StatusCompleted, delivered.Status)
+		assert.Equal(t, "alpha", delivered.Metadata["project"])
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered")
+	}
+}
+
+func TestProfileDefaults_RequestOverrides(t *testing.T) {
+	profileServer, profileReceived := callbackRecorder(t)
+	requestServer, requestReceived := callbackRecorder(t)
+
+	cfg := &config.Config{Profiles: map[string]config.ProfileConfig{
+		"plant-a": {CallbackURL: profileServer.URL, Metadata: map[string]string{"project": "alpha", "site": "north"}},
+	}}
+	service := newTestService(t, cfg, &fakeRunner{output: fijiOutput(82, 30)})
+
+	opts := AnalysisOptions{
+		Profile:     "plant-a",
+		CallbackURL: requestServer.URL,
+		Metadata:    map[string]string{"site": "south", "operator": "kim"},
+	}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("profile-2", file, opts))
+
+	result, err := service.GetAnalysisStatus("profile-2")
+	require.NoError(t, err)
+	assert.Equal(t, requestServer.URL, result.CallbackURL)
+	assert.Equal(t, map[string]string{"project": "alpha", "site": "south", "operator": "kim"}, result.Metadata)
+
+	select {
+	case delivered := <-requestReceived:
+		assert.Equal(t, "profile-2", delivered.ID)
+	case <-time.After(5 * time.Second):
+		t.Fatal("callback was not delivered to the request URL")
+	}
+	assert.Empty(t, profileReceived)
+}
+
+func TestDeliverCallback_RetriesFailures(t *testing.T) {
+	callbackBackoff = 10 * time.Millisecond
+	defer func() { callbackBackoff = time.Second }()
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+	service.deliverCallback(models.AnalysisResult{ID: "retry-1", CallbackURL: server.URL})
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&attempts))
+}
+
+func TestShutdown_WaitsForCallbacks(t *testing.T) {
+	callbackBackoff = 50 * time.Millisecond
+	defer func() { callbackBackoff = time.Second }()
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&attempts, 1) < 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(82, 30)})
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("callback-drain-1", file, AnalysisOptions{CallbackURL: server.URL}))
+
+	// The retry after the first failure is still waited for
+	require.NoError(t, service.Shutdown(context.Background()))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&attempts))
+}
+
+func TestShutdown_AbandonsCallbackRetriesAtDeadline(t *testing.T) {
+	callbackBackoff = time.Hour
+	defer func() { callbackBackoff = time.Second }()
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(82, 30)})
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("callback-drain-2", file, AnalysisOptions{CallbackURL: server.URL}))
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&attempts) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The retry waits an hour; the deadline abandons it
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	assert.ErrorIs(t, service.Shutdown(ctx), context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestDeliverCallback_FailureLeavesResult(t *testing.T) {
+	callbackBackoff = 10 * time.Millisecond
+	defer func() { callbackBackoff = time.Second }()
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(82, 30)})
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("retry-2", file, AnalysisOptions{CallbackURL: server.URL}))
+
+	// Delivery gives up after the last attempt without touching the result
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&attempts) == callbackAttempts }, 5*time.Second, 10*time.Millisecond)
+	result, err := service.GetAnalysisStatus("retry-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Empty(t, result.Error)
+}
+
+func TestParticleFilter_StoredAndInterpolated(t *testing.T) {
+	var macro string
+	runner := runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		content, err := os.ReadFile(macroPath)
+		require.NoError(t, err)
+		macro = string(content)
+		return []byte(fijiOutput(80, 25)), nil
+	})
+	service := newTestService(t, &config.Config{}, runner)
+
+	minSize, maxSize, circularity, sigma := 25.0, 400.0, 0.3, 2.0
+	opts := AnalysisOptions{ThresholdMethod: "Li", MinParticleSize: &minSize, MaxParticleSize: &maxSize, MinCircularity: &circularity, GaussianSigma: &sigma}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("filter-1", file, opts))
+
+	assert.Contains(t, macro, `setAutoThreshold("Li");`)
+	assert.Contains(t, macro, `run("Gaussian Blur...", "sigma=2");`)
+	assert.Contains(t, macro, `run("Enhance Contrast", "saturated=0.35");`)
+	assert.Contains(t, macro, "size=25-400 circularity=0.30-1.00")
+	result, err := service.GetAnalysisStatus("filter-1")
+	require.NoError(t, err)
+	require.NotNil(t, result.Parameters)
+	assert.Equal(t, 25.0, result.Parameters.MinParticleSize)
+	assert.Equal(t, 400.0, result.Parameters.MaxParticleSize)
+	assert.Equal(t, 0.3, result.Parameters.MinCircularity)
+	assert.Equal(t, "Li", result.Parameters.ThresholdMethod)
+}
+
+// macroConfig returns a configuration with the default macro settings
+func macroConfig() *config.Config {
+	return &config.Config{MacroGaussianSigma: 1, MacroContrastSaturation: 0.35, MacroThresholdMethod: "Otsu"}
+}
+
+func TestParticleFilter_Defaults(t *testing.T) {
+	params, err := AnalysisOptions{}.MacroParams(macroConfig())
+	require.NoError(t, err)
+	assert.Equal(t, DefaultMacroParams(macroConfig()), params)
+	assert.Equal(t, 1.0, params.GaussianSigma)
+	assert.Equal(t, "Otsu", params.ThresholdMethod)
+
+	inverted, tooRound := 5.0, 1.2
+	_, err = AnalysisOptions{MaxParticleSize: &inverted}.MacroParams(macroConfig())
+	assert.ErrorIs(t, err, ErrInvalidParticleFilter)
+	_, err = AnalysisOptions{MinCircularity: &tooRound}.MacroParams(macroConfig())
+	assert.ErrorIs(t, err, ErrInvalidParticleFilter)
+}
+
+func TestThresholdMethod(t *testing.T) {
+	params, err := AnalysisOptions{ThresholdMethod: "maxentropy"}.MacroParams(macroConfig())
+	require.NoError(t, err)
+	assert.Equal(t, "MaxEntropy", params.ThresholdMethod)
+
+	_, err = AnalysisOptions{ThresholdMethod: "Magic"}.MacroParams(macroConfig())
+	assert.ErrorIs(t, err, ErrUnknownThresholdMethod)
+	assert.Contains(t, err.Error(), "Default, Huang")
+}
+
+func TestPreprocessing(t *testing.T) {
+	cfg := &config.Config{MacroGaussianSigma: 2.5, MacroContrastSaturation: 1, MacroThresholdMethod: "Triangle"}
+	params, err := AnalysisOptions{}.MacroParams(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 2.5, params.GaussianSigma)
+	assert.Equal(t, 1.0, params.ContrastSaturation)
+	assert.Equal(t, "Triangle", params.ThresholdMethod)
+
+	// Request values win over the configured defaults, including zero
+	sigma, saturation := 0.0, 0.1
+	params, err = AnalysisOptions{GaussianSigma: &sigma, ContrastSaturation: &saturation, ThresholdMethod: "li"}.MacroParams(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, params.GaussianSigma)
+	assert.Equal(t, 0.1, params.ContrastSaturation)
+	assert.Equal(t, "Li", params.ThresholdMethod)
+
+	negative, saturated, smeared := -1.0, 100.0, 25.0
+	_, err = AnalysisOptions{GaussianSigma: &negative}.MacroParams(cfg)
+	assert.ErrorIs(t, err, ErrInvalidPreprocessing)
+	_, err = AnalysisOptions{GaussianSigma: &smeared}.MacroParams(cfg)
+	assert.ErrorIs(t, err, ErrInvalidPreprocessing)
+	_, err = AnalysisOptions{ContrastSaturation: &saturated}.MacroParams(cfg)
+	assert.ErrorIs(t, err, ErrInvalidPreprocessing)
+}
+
+func TestPreprocessing_ZeroSigmaSkipsBlur(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(70, 20)})
+
+	sigma, saturation := 0.0, 0.5
+	opts := AnalysisOptions{GaussianSigma: &sigma, ContrastSaturation: &saturation}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 70))
+	require.NoError(t, service.AnalyzeGypsumImage("blur-1", file, opts))
+
+	result, err := service.GetAnalysisStatus("blur-1")
+	require.NoError(t, err)
+	assert.NotContains(t, result.Macro, "Gaussian Blur")
+	assert.Contains(t, result.Macro, `run("Enhance Contrast", "saturated=0.5");`)
+	require.NotNil(t, result.Parameters)
+	assert.Equal(t, 0.0, result.Parameters.GaussianSigma)
+	assert.Equal(t, 0.5, result.Parameters.ContrastSaturation)
+}
+
+func TestCalibration(t *testing.T) {
+	calibration, err := AnalysisOptions{}.Calibration()
+	require.NoError(t, err)
+	assert.Nil(t, calibration)
+
+	calibration, err = AnalysisOptions{PixelsPerMicron: 2}.Calibration()
+	require.NoError(t, err)
+	assert.Equal(t, &models.Calibration{PixelsPerMicron: 2}, calibration)
+
+	// A 100 µm scale bar spanning 250 px
+	calibration, err = AnalysisOptions{ScaleBarLength: 100, ScaleBarPixels: 250}.Calibration()
+	require.NoError(t, err)
+	assert.Equal(t, &models.Calibration{PixelsPerMicron: 2.5, ScaleBarLength: 100, ScaleBarPixels: 250}, calibration)
+
+	for _, opts := range []AnalysisOptions{
+		{ScaleBarLength: 100},
+		{ScaleBarPixels: 250},
+		{PixelsPerMicron: 2, ScaleBarLength: 100, ScaleBarPixels: 250},
+		{ScaleBarLength: -100, ScaleBarPixels: 250},
+	} {
+		_, err := opts.Calibration()
+		assert.ErrorIs(t, err, ErrInvalidCalibration)
+	}
+}
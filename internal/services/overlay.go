@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"gypsum-analysis-api/internal/models"
+	"gypsum-analysis-api/internal/storage"
+)
+
+// ErrOverlayNotFound is returned when an analysis has no outlines overlay,
+// because it was never generated or has been cleaned up
+var ErrOverlayNotFound = errors.New("overlay not found")
+
+// overlayKey is the blob key of the outlines overlay of an analysis
+func overlayKey(analysisID string) string {
+	return fmt.Sprintf("%s_overlay.png", analysisID)
+}
+
+// storeOverlay keeps the outlines overlay Fiji saved at path in the blob store
+// and records its location on the result. It reports whether the overlay was
+// kept; without one the result simply has no overlay.
+func (s *AnalysisService) storeOverlay(ctx context.Context, analysisID, path string) bool {
+	if _, err := os.Stat(path); err != nil {
+		return false
+	}
+
+	// A local blob store already holds the file Fiji wrote
+	key := overlayKey(analysisID)
+	if local, ok := s.blobs.(*storage.LocalBlobStore); !ok || local.Location(key) != path {
+		err := s.putImage(ctx, key, path)
+		s.tempFiles.Remove(path)
+		if err != nil {
+			s.analysisLog(ctx, analysisID).WithError(err).Warn("Failed to store overlay")
+			return false
+		}
+	}
+	s.tempFiles.Deregister(path)
+
+	s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.OverlayPath = s.blobs.Location(key)
+		return nil
+	})
+	return true
+}
+
+// GetOverlay opens the outlines overlay PNG of an analysis. The caller must
+// close the returned reader.
+func (s *AnalysisService) GetOverlay(ctx context.Context, analysisID string) (io.ReadCloser, error) {
+	result, err := s.store.Get(analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if result.OverlayPath == "" {
+		return nil, ErrOverlayNotFound
+	}
+
+	overlay, err := s.blobs.Get(ctx, overlayKey(analysisID))
+	if errors.Is(err, storage.ErrBlobNotFound) {
+		return nil, ErrOverlayNotFound
+	}
+	return overlay, err
+}
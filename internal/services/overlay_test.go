@@ -0,0 +1,133 @@
+package services
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var saveOverlayPattern = regexp.MustCompile(`saveAs\("PNG", "([^"]+)"\)`)
+
+// overlayRunner saves an overlay wherever the macro asks Fiji to
+type overlayRunner struct {
+	output string
+}
+
+func (r *overlayRunner) Run(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+	macro, err := os.ReadFile(macroPath)
+	if err != nil {
+		return nil, err
+	}
+	if match := saveOverlayPattern.FindSubmatch(macro); match != nil {
+		if err := os.WriteFile(string(match[1]), []byte("\x89PNG overlay"), 0644); err != nil {
+			return nil, err
+		}
+	}
+	return []byte(r.output), nil
+}
+
+func readOverlay(t *testing.T, service *AnalysisService, analysisID string) string {
+	t.Helper()
+	overlay, err := service.GetOverlay(context.Background(), analysisID)
+	require.NoError(t, err)
+	defer overlay.Close()
+	data, err := io.ReadAll(overlay)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestOverlay_SavedWithResult(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &overlayRunner{output: fijiOutput(80, 20)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("overlay-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("overlay-1")
+	require.NoError(t, err)
+	overlayPath := filepath.Join(service.config.TempDir, "overlay-1_overlay.png")
+	assert.Equal(t, overlayPath, result.OverlayPath)
+	assert.Contains(t, result.Macro, `saveAs("PNG", "`+filepath.ToSlash(overlayPath)+`")`)
+	assert.True(t, result.ImageRemoved, "the overlay outlives the released upload")
+	assert.Equal(t, "\x89PNG overlay", readOverlay(t, service, "overlay-1"))
+
+	// Deleting the analysis removes its overlay
+	_, err = service.DeleteAnalysis("overlay-1")
+	require.NoError(t, err)
+	assert.NoFileExists(t, overlayPath)
+}
+
+func TestOverlay_NotFound(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	_, err := service.GetOverlay(context.Background(), "missing")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+
+	// Fiji saved no overlay
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("overlay-2", file, AnalysisOptions{}))
+	_, err = service.GetOverlay(context.Background(), "overlay-2")
+	assert.ErrorIs(t, err, ErrOverlayNotFound)
+
+	// The overlay was cleaned up after it was recorded
+	service.runner = &overlayRunner{output: fijiOutput(80, 20)}
+	require.NoError(t, service.AnalyzeGypsumImage("overlay-3", newFileHeader(t, "sample.png", splitImagePNG(t, 80)), AnalysisOptions{}))
+	require.NoError(t, os.Remove(filepath.Join(service.config.TempDir, "overlay-3_overlay.png")))
+	_, err = service.GetOverlay(context.Background(), "overlay-3")
+	assert.ErrorIs(t, err, ErrOverlayNotFound)
+}
+
+func TestOverlay_FailedAnalysis(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &overlayRunner{output: "no results"})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	assert.Error(t, service.AnalyzeGypsumImage("overlay-4", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("overlay-4")
+	require.NoError(t, err)
+	assert.Empty(t, result.OverlayPath)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "overlay-4_overlay.png"))
+}
+
+func TestOverlay_RemoteBlobStore(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &overlayRunner{output: fijiOutput(80, 20)})
+	blobs := &memBlobStore{blobs: make(map[string][]byte)}
+	service.blobs = blobs
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("overlay-5", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("overlay-5")
+	require.NoError(t, err)
+	assert.Equal(t, "mem://overlay-5_overlay.png", result.OverlayPath)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "overlay-5_overlay.png"), "the local file is removed once stored")
+	assert.Equal(t, "\x89PNG overlay", readOverlay(t, service, "overlay-5"))
+}
+
+func TestSweepTempDir_KeepImagesKeepsOverlays(t *testing.T) {
+	old := time.Now().Add(-48 * time.Hour)
+	for _, keep := range []bool{false, true} {
+		service := newTestService(t, &config.Config{KeepImages: keep}, &fakeRunner{})
+		require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "done", Status: models.StatusCompleted}))
+		path := filepath.Join(service.config.TempDir, "done_overlay.png")
+		require.NoError(t, os.WriteFile(path, []byte("x"), 0644))
+		require.NoError(t, os.Chtimes(path, old, old))
+
+		service.sweepTempDir(time.Now().Add(-24 * time.Hour))
+
+		if keep {
+			assert.FileExists(t, path)
+		} else {
+			assert.NoFileExists(t, path)
+		}
+	}
+}
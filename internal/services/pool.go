@@ -0,0 +1,109 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ErrQueueFull is returned by WorkerPool.Submit when no queue slot is free
+var ErrQueueFull = errors.New("analysis queue is full")
+
+// ErrPoolClosed is returned by WorkerPool.Submit once the pool is shutting down
+var ErrPoolClosed = errors.New("worker pool is shut down")
+
+// WorkerPool runs submitted jobs on a fixed number of goroutines, bounding how
+// many Fiji processes run at once. Jobs wait in a bounded queue; when it is
+// full Submit fails instead of blocking.
+type WorkerPool struct {
+	jobs       chan func()
+	queueDepth prometheus.Gauge
+	wg         sync.WaitGroup
+	mutex      sync.RWMutex
+	closed     bool
+}
+
+// NewWorkerPool starts maxWorkers workers (at least one) behind a queue of
+// queueSize jobs. When queueDepth is not nil it tracks the number of queued jobs.
+func NewWorkerPool(maxWorkers, queueSize int, queueDepth prometheus.Gauge) *WorkerPool {
+	if maxWorkers < 1 {
+		maxWorkers = 1
+	}
+	if queueSize < 0 {
+		queueSize = 0
+	}
+
+	p := &WorkerPool{jobs: make(chan func(), queueSize), queueDepth: queueDepth}
+	p.wg.Add(maxWorkers)
+	for i := 0; i < maxWorkers; i++ {
+		go func() {
+			defer p.wg.Done()
+			for job := range p.jobs {
+				p.trackQueue(-1)
+				job()
+			}
+		}()
+	}
+
+	return p
+}
+
+// Submit queues a job without blocking. It returns ErrQueueFull when every
+// worker is busy and the queue is full, and ErrPoolClosed after Shutdown.
+func (p *WorkerPool) Submit(job func()) error {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if p.closed {
+		return ErrPoolClosed
+	}
+
+	// Count the job before a worker can take it so the gauge never dips below zero
+	p.trackQueue(1)
+	select {
+	case p.jobs <- job:
+		return nil
+	default:
+		p.trackQueue(-1)
+		return ErrQueueFull
+	}
+}
+
+// trackQueue adjusts the queue depth gauge, if any
+func (p *WorkerPool) trackQueue(delta float64) {
+	if p.queueDepth != nil {
+		p.queueDepth.Add(delta)
+	}
+}
+
+// QueueDepth returns the number of jobs waiting for a free worker
+func (p *WorkerPool) QueueDepth() int {
+	return len(p.jobs)
+}
+
+// Shutdown stops accepting jobs and waits for queued and running jobs to
+// finish. If ctx ends first, the remaining jobs are left running and ctx's
+// error is returned.
+func (p *WorkerPool) Shutdown(ctx context.Context) error {
+	p.mutex.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.jobs)
+	}
+	p.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
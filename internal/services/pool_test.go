@@ -0,0 +1,95 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkerPool_BoundsConcurrency(t *testing.T) {
+	pool := NewWorkerPool(2, 10, nil)
+
+	var running, peak atomic.Int32
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		require.NoError(t, pool.Submit(func() {
+			defer wg.Done()
+			n := running.Add(1)
+			for {
+				old := peak.Load()
+				if n <= old || peak.CompareAndSwap(old, n) {
+					break
+				}
+			}
+			time.Sleep(10 * time.Millisecond)
+			running.Add(-1)
+		}))
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(2), peak.Load())
+	require.NoError(t, pool.Shutdown(context.Background()))
+}
+
+func TestWorkerPool_QueueFull(t *testing.T) {
+	queueDepth := prometheus.NewGauge(prometheus.GaugeOpts{Name: "queue_depth"})
+	pool := NewWorkerPool(1, 1, queueDepth)
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	require.NoError(t, pool.Submit(func() {
+		close(started)
+		<-release
+	}))
+	<-started
+
+	// One job waits in the queue, the next is rejected
+	assert.Equal(t, 0, pool.QueueDepth())
+	require.NoError(t, pool.Submit(func() {}))
+	assert.Equal(t, 1, pool.QueueDepth())
+	assert.ErrorIs(t, pool.Submit(func() {}), ErrQueueFull)
+	assert.Equal(t, 1.0, testutil.ToFloat64(queueDepth), "rejected jobs are not counted")
+
+	close(release)
+	require.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, 0.0, testutil.ToFloat64(queueDepth))
+}
+
+func TestWorkerPool_ShutdownDrainsQueue(t *testing.T) {
+	pool := NewWorkerPool(1, 5, nil)
+
+	var done atomic.Int32
+	for i := 0; i < 5; i++ {
+		require.NoError(t, pool.Submit(func() {
+			time.Sleep(5 * time.Millisecond)
+			done.Add(1)
+		}))
+	}
+
+	require.NoError(t, pool.Shutdown(context.Background()))
+	assert.Equal(t, int32(5), done.Load(), "queued jobs finish before Shutdown returns")
+	assert.ErrorIs(t, pool.Submit(func() {}), ErrPoolClosed)
+	assert.NoError(t, pool.Shutdown(context.Background()), "Shutdown is idempotent")
+}
+
+func TestWorkerPool_ShutdownDeadline(t *testing.T) {
+	pool := NewWorkerPool(1, 0, nil)
+	release := make(chan struct{})
+	defer close(release)
+
+	require.Eventually(t, func() bool {
+		return pool.Submit(func() { <-release }) == nil
+	}, time.Second, time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, pool.Shutdown(ctx), context.DeadlineExceeded)
+}
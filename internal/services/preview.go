@@ -0,0 +1,47 @@
+package services
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// PreviewAnalysisID stands in for the analysis ID in the paths of a macro
+// preview; a real run has its own ID there
+const PreviewAnalysisID = "{analysis_id}"
+
+// PreviewMacro returns the macro Fiji would run to analyze an image named
+// filename with opts, without saving anything or starting Fiji. Apart from
+// the analysis ID in its paths, the preview is the macro of a real run that
+// is neither tiled nor analyzed by the go-native engine.
+func (s *AnalysisService) PreviewMacro(filename string, opts AnalysisOptions) (string, error) {
+	opts, params, err := s.macroOptions(opts)
+	if err != nil {
+		return "", err
+	}
+
+	// The image is stored as a PNG when Fiji cannot read it as uploaded
+	ext := filepath.Ext(filename)
+	if _, convert := convertedTypes[imageTypes[strings.ToLower(ext)]]; convert {
+		ext = ".png"
+	}
+	imagePath, err := s.localImagePath(PreviewAnalysisID, PreviewAnalysisID+ext)
+	if err != nil {
+		return "", err
+	}
+
+	roiPath := ""
+	if opts.ROIFile != nil {
+		roiPath = s.roiFilePath(PreviewAnalysisID, opts.ROIFile.Filename)
+	}
+
+	_, resultsPath, overlayPath := s.macroFiles(PreviewAnalysisID)
+	return buildGypsumMacro(gypsumMacro{
+		ImagePath:   imagePath,
+		ROIPath:     roiPath,
+		ResultsPath: resultsPath,
+		OverlayPath: overlayPath,
+		Crop:        opts.ROI,
+		Params:      params,
+		Centroids:   s.config.ComputeSpacing,
+	}), nil
+}
@@ -0,0 +1,67 @@
+package services
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+)
+
+func TestPreviewMacro_MatchesAnalysis(t *testing.T) {
+	tests := []struct {
+		name string
+		opts func(t *testing.T) AnalysisOptions
+	}{
+		{"defaults", func(t *testing.T) AnalysisOptions { return AnalysisOptions{} }},
+		{"region", func(t *testing.T) AnalysisOptions {
+			return AnalysisOptions{ROI: &models.Rectangle{X: 10, Y: 20, Width: 50, Height: 40}}
+		}},
+		{"roi file", func(t *testing.T) AnalysisOptions {
+			return AnalysisOptions{ROIFile: newFileHeader(t, "core.ROI", encodedROI())}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(t, &config.Config{}, &fakeRunner{output: withROIResults(fijiOutput(64, 12), "roi:0,72.5,core")})
+
+			preview, err := service.PreviewMacro("sample.png", tt.opts(t))
+			require.NoError(t, err)
+
+			file := newFileHeader(t, "sample.png", splitImagePNG(t, 64))
+			require.NoError(t, service.AnalyzeGypsumImage("preview-1", file, tt.opts(t)))
+			result, err := service.GetAnalysisStatus("preview-1")
+			require.NoError(t, err)
+			require.Equal(t, models.StatusCompleted, result.Status)
+
+			assert.Equal(t, result.Macro, strings.ReplaceAll(preview, PreviewAnalysisID, "preview-1"))
+		})
+	}
+}
+
+func TestPreviewMacro_WritesNothing(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{err: assert.AnError})
+
+	macro, err := service.PreviewMacro("sample.webp", AnalysisOptions{})
+	require.NoError(t, err)
+	assert.Contains(t, macro, PreviewAnalysisID+".png", "WebP images are analyzed as PNG")
+
+	entries, err := os.ReadDir(service.config.TempDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestPreviewMacro_RejectsInvalidOptions(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	_, err := service.PreviewMacro("sample.png", AnalysisOptions{ROI: &models.Rectangle{Width: 0, Height: 10}})
+	assert.ErrorIs(t, err, ErrInvalidROIRegion)
+
+	_, err = service.PreviewMacro("sample.png", AnalysisOptions{ProfileID: "missing"})
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+}
@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrProfileNotFound is returned when no profile exists for the given ID
+var ErrProfileNotFound = errors.New("profile not found")
+
+// ErrProfileExists is returned when creating a profile with an ID in use
+var ErrProfileExists = errors.New("profile already exists")
+
+// ErrBuiltinProfile is returned when changing or deleting a built-in profile
+var ErrBuiltinProfile = errors.New("built-in profiles are read-only")
+
+// ErrInvalidProfile is returned when a profile has an unusable ID or settings
+var ErrInvalidProfile = errors.New("invalid profile")
+
+// profileIDPattern restricts profile IDs to lowercase slugs, so they are safe
+// in URLs
+var profileIDPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,63}$`)
+
+// BuiltinProfiles returns the read-only profiles every store provides
+func BuiltinProfiles() []*models.Profile {
+	return []*models.Profile{
+		{
+			ID:          "default",
+			Name:        "Default",
+			Description: "General-purpose settings for construction-grade gypsum",
+			Builtin:     true,
+			MacroParams: models.MacroParams{
+				ThresholdMethod:    "Otsu",
+				MinParticleSize:    10,
+				MaxCircularity:     1,
+				GaussianSigma:      1,
+				ContrastSaturation: 0.35,
+			},
+		},
+		{
+			ID:          "fine-grain",
+			Name:        "Fine grain",
+			Description: "Light blur and a small minimum particle size for finely ground, pharmaceutical-grade gypsum",
+			Builtin:     true,
+			MacroParams: models.MacroParams{
+				ThresholdMethod:    "Li",
+				MinParticleSize:    2,
+				MaxCircularity:     1,
+				GaussianSigma:      0.5,
+				ContrastSaturation: 0.35,
+			},
+		},
+		{
+			ID:          "coarse-grain",
+			Name:        "Coarse grain",
+			Description: "Stronger blur and a large minimum particle size for coarse, agricultural-grade gypsum",
+			Builtin:     true,
+			MacroParams: models.MacroParams{
+				ThresholdMethod:    "Otsu",
+				MinParticleSize:    50,
+				MaxCircularity:     1,
+				GaussianSigma:      2,
+				ContrastSaturation: 0.5,
+			},
+		},
+	}
+}
+
+// ListProfiles returns all profiles, built-in ones first
+func (s *AnalysisService) ListProfiles() ([]*models.Profile, error) {
+	return s.store.ListProfiles()
+}
+
+// GetProfile returns a profile, or ErrProfileNotFound
+func (s *AnalysisService) GetProfile(profileID string) (*models.Profile, error) {
+	return s.store.GetProfile(profileID)
+}
+
+// CreateProfile validates and stores a new profile
+func (s *AnalysisService) CreateProfile(request models.ProfileRequest) (*models.Profile, error) {
+	if request.ID == "" {
+		request.ID = uuid.New().String()
+	}
+	profile, err := newProfile(request)
+	if err != nil {
+		return nil, err
+	}
+	profile.CreatedAt = profile.UpdatedAt
+
+	if err := s.store.CreateProfile(profile); err != nil {
+		if errors.Is(err, ErrProfileExists) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to store profile: %w", err)
+	}
+
+	s.logger.WithField("profile_id", profile.ID).Info("Profile created")
+	return profile, nil
+}
+
+// UpdateProfile replaces the name, description and settings of a profile.
+// Built-in profiles return ErrBuiltinProfile.
+func (s *AnalysisService) UpdateProfile(profileID string, request models.ProfileRequest) (*models.Profile, error) {
+	current, err := s.store.GetProfile(profileID)
+	if err != nil {
+		return nil, err
+	}
+	if current.Builtin {
+		return nil, ErrBuiltinProfile
+	}
+
+	request.ID = profileID
+	profile, err := newProfile(request)
+	if err != nil {
+		return nil, err
+	}
+	profile.CreatedAt = current.CreatedAt
+
+	if err := s.store.UpdateProfile(profile); err != nil {
+		if errors.Is(err, ErrProfileNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to store profile: %w", err)
+	}
+	return profile, nil
+}
+
+// DeleteProfile removes a profile. Built-in profiles return ErrBuiltinProfile.
+func (s *AnalysisService) DeleteProfile(profileID string) error {
+	profile, err := s.store.GetProfile(profileID)
+	if err != nil {
+		return err
+	}
+	if profile.Builtin {
+		return ErrBuiltinProfile
+	}
+	return s.store.DeleteProfile(profileID)
+}
+
+// newProfile builds a profile from a request, checking its ID and settings
+func newProfile(request models.ProfileRequest) (*models.Profile, error) {
+	if !profileIDPattern.MatchString(request.ID) {
+		return nil, fmt.Errorf("%w: id must be a lowercase slug of letters, digits and dashes", ErrInvalidProfile)
+	}
+	if request.Name == "" {
+		return nil, fmt.Errorf("%w: name is required", ErrInvalidProfile)
+	}
+
+	params, err := AnalysisOptions{}.macroParamsFrom(request.MacroParams)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidProfile, err)
+	}
+
+	return &models.Profile{
+		ID:          request.ID,
+		Name:        request.Name,
+		Description: request.Description,
+		MacroParams: params,
+		UpdatedAt:   time.Now(),
+	}, nil
+}
+
+// profileMacroParams returns the macro parameters of the requested profile
+// with the request's overrides applied, or the configured defaults with the
+// overrides when no profile was requested
+func (s *AnalysisService) profileMacroParams(opts AnalysisOptions) (models.MacroParams, error) {
+	if opts.ProfileID == "" {
+		return opts.MacroParams(s.config)
+	}
+
+	profile, err := s.store.GetProfile(opts.ProfileID)
+	if err != nil {
+		return models.MacroParams{}, err
+	}
+	return opts.macroParamsFrom(profile.MacroParams)
+}
@@ -0,0 +1,106 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProfiles_CRUD(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	profiles, err := service.ListProfiles()
+	require.NoError(t, err)
+	require.Len(t, profiles, 3)
+	assert.Equal(t, []string{"coarse-grain", "default", "fine-grain"}, []string{profiles[0].ID, profiles[1].ID, profiles[2].ID})
+
+	request := models.ProfileRequest{ID: "reflected", Name: "Reflected light", MacroParams: DefaultMacroParams(service.config)}
+	request.ThresholdMethod = "triangle"
+	profile, err := service.CreateProfile(request)
+	require.NoError(t, err)
+	assert.Equal(t, "Triangle", profile.ThresholdMethod)
+	assert.False(t, profile.Builtin)
+
+	_, err = service.CreateProfile(request)
+	assert.ErrorIs(t, err, ErrProfileExists)
+
+	request.Description = "Polished sections"
+	request.GaussianSigma = 0
+	profile, err = service.UpdateProfile("reflected", request)
+	require.NoError(t, err)
+	assert.Equal(t, "Polished sections", profile.Description)
+	assert.Equal(t, 0.0, profile.GaussianSigma)
+
+	profiles, err = service.ListProfiles()
+	require.NoError(t, err)
+	assert.Equal(t, "reflected", profiles[3].ID, "custom profiles follow the built-in ones")
+
+	require.NoError(t, service.DeleteProfile("reflected"))
+	_, err = service.GetProfile("reflected")
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+	assert.ErrorIs(t, service.DeleteProfile("reflected"), ErrProfileNotFound)
+}
+
+func TestProfiles_BuiltinAreReadOnly(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	assert.ErrorIs(t, service.DeleteProfile("default"), ErrBuiltinProfile)
+	_, err := service.UpdateProfile("fine-grain", models.ProfileRequest{Name: "Mine", MacroParams: DefaultMacroParams(service.config)})
+	assert.ErrorIs(t, err, ErrBuiltinProfile)
+	assert.ErrorIs(t, service.store.DeleteProfile("coarse-grain"), ErrBuiltinProfile, "the store guards built-in profiles too")
+
+	profile, err := service.GetProfile("fine-grain")
+	require.NoError(t, err)
+	assert.True(t, profile.Builtin)
+}
+
+func TestProfiles_Invalid(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+	params := DefaultMacroParams(service.config)
+
+	generated, err := service.CreateProfile(models.ProfileRequest{Name: "Unnamed", MacroParams: params})
+	require.NoError(t, err)
+	assert.NotEmpty(t, generated.ID)
+
+	for _, request := range []models.ProfileRequest{
+		{ID: "Upper Case", Name: "Bad ID", MacroParams: params},
+		{ID: "no-name", MacroParams: params},
+		{ID: "bad-method", Name: "Bad method", MacroParams: models.MacroParams{ThresholdMethod: "Magic", MaxCircularity: 1}},
+		{ID: "bad-filter", Name: "Bad filter", MacroParams: models.MacroParams{MinCircularity: 0.5, MaxCircularity: 0.2}},
+		{ID: "bad-blur", Name: "Bad blur", MacroParams: models.MacroParams{GaussianSigma: 50, MaxCircularity: 1}},
+	} {
+		_, err := service.CreateProfile(request)
+		assert.ErrorIs(t, err, ErrInvalidProfile, request.ID)
+	}
+}
+
+func TestAnalyzeWithProfile(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(75, 14)})
+
+	// The profile replaces the defaults and request values override the profile
+	sigma := 3.0
+	opts := AnalysisOptions{ProfileID: "coarse-grain", GaussianSigma: &sigma}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 75))
+	require.NoError(t, service.AnalyzeGypsumImage("profile-1", file, opts))
+
+	result, err := service.GetAnalysisStatus("profile-1")
+	require.NoError(t, err)
+	assert.Equal(t, "coarse-grain", result.ProfileID)
+	require.NotNil(t, result.Parameters)
+	assert.Equal(t, 50.0, result.Parameters.MinParticleSize)
+	assert.Equal(t, 0.5, result.Parameters.ContrastSaturation)
+	assert.Equal(t, 3.0, result.Parameters.GaussianSigma)
+	assert.Contains(t, result.Macro, `run("Gaussian Blur...", "sigma=3");`)
+
+	err = service.SubmitAnalysis(context.Background(), "profile-2", newFileHeader(t, "sample.png", splitImagePNG(t, 75)), AnalysisOptions{ProfileID: "missing"})
+	assert.ErrorIs(t, err, ErrProfileNotFound)
+
+	maxSize := 20.0
+	err = service.SubmitAnalysis(context.Background(), "profile-3", newFileHeader(t, "sample.png", splitImagePNG(t, 75)), AnalysisOptions{ProfileID: "coarse-grain", MaxParticleSize: &maxSize})
+	assert.ErrorIs(t, err, ErrInvalidParticleFilter, "overrides are checked against the profile")
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// progressMarker prefixes the completion percentage the analysis macro prints
+// after each major step
+const progressMarker = "PROGRESS:"
+
+// errProgressUnchanged leaves the record untouched when progress would not advance
+var errProgressUnchanged = errors.New("progress unchanged")
+
+// progressSpan maps the 0-100 progress of one macro run onto the part of the
+// whole analysis that run covers, so tiled analyses report overall progress
+type progressSpan struct {
+	from, to int
+}
+
+// fullSpan is the span of an analysis performed in a single macro run
+var fullSpan = progressSpan{from: 0, to: 100}
+
+// tileSpan returns the span of tile index out of count tiles
+func tileSpan(index, count int) progressSpan {
+	return progressSpan{from: index * 100 / count, to: (index + 1) * 100 / count}
+}
+
+// scale converts a macro's progress into progress of the whole analysis
+func (p progressSpan) scale(pct int) int {
+	return p.from + pct*(p.to-p.from)/100
+}
+
+// parseProgress returns the percentage of a progress line
+func parseProgress(line string) (int, bool) {
+	value, found := strings.CutPrefix(strings.TrimSpace(line), progressMarker)
+	if !found {
+		return 0, false
+	}
+	pct, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	if err != nil || pct < 0 || pct > 100 {
+		return 0, false
+	}
+	return int(pct), true
+}
+
+// recordProgress stores the progress of a processing analysis. Progress never
+// moves backwards and is not recorded once the analysis has finished.
+func (s *AnalysisService) recordProgress(analysisID string, pct int) {
+	// Stale progress is expected and not worth logging, so bypass s.update
+	s.store.Update(analysisID, func(result *models.AnalysisResult) error {
+		if result.Status != models.StatusProcessing || pct <= result.Progress {
+			return errProgressUnchanged
+		}
+		result.Progress = pct
+		return nil
+	})
+}
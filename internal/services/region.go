@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"image"
+
+	"gypsum-analysis-api/internal/imaging"
+	"gypsum-analysis-api/internal/models"
+)
+
+// ErrInvalidROIRegion is returned for rectangular regions with negative
+// coordinates or an empty size
+var ErrInvalidROIRegion = errors.New("invalid region of interest")
+
+// ErrROIOutOfBounds is returned when a rectangular region extends beyond the
+// uploaded image
+var ErrROIOutOfBounds = errors.New("region of interest exceeds image bounds")
+
+// ValidateROIRegion checks that a region has non-negative coordinates and a
+// positive size. Whether it fits the image is checked once the upload is saved.
+func ValidateROIRegion(region models.Rectangle) error {
+	if region.X < 0 || region.Y < 0 {
+		return fmt.Errorf("%w: roi_x and roi_y must not be negative", ErrInvalidROIRegion)
+	}
+	if region.Width <= 0 || region.Height <= 0 {
+		return fmt.Errorf("%w: roi_width and roi_height must be positive", ErrInvalidROIRegion)
+	}
+	return nil
+}
+
+// checkROIBounds reads the image header and returns ErrROIOutOfBounds when the
+// region does not fit the image. Unreadable images are left for the analysis
+// to reject.
+func checkROIBounds(region models.Rectangle, imagePath string) error {
+	cfg, _, err := imaging.DecodeConfigFile(imagePath)
+	if err != nil {
+		return nil
+	}
+	if !regionBounds(region).In(image.Rect(0, 0, cfg.Width, cfg.Height)) {
+		return fmt.Errorf("%w: %dx%d region at (%d, %d) does not fit the %dx%d image",
+			ErrROIOutOfBounds, region.Width, region.Height, region.X, region.Y, cfg.Width, cfg.Height)
+	}
+	return nil
+}
+
+// cropImage returns the part of img inside region, or false when the image
+// type cannot be cropped
+func cropImage(img image.Image, region models.Rectangle) (image.Image, bool) {
+	cropped, ok := img.(interface {
+		SubImage(r image.Rectangle) image.Image
+	})
+	if !ok {
+		return nil, false
+	}
+	return cropped.SubImage(regionBounds(region)), true
+}
+
+// regionBounds converts a region to an image rectangle
+func regionBounds(region models.Rectangle) image.Rectangle {
+	return image.Rect(region.X, region.Y, region.X+region.Width, region.Y+region.Height)
+}
@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateROIRegion(t *testing.T) {
+	tests := []struct {
+		name   string
+		region models.Rectangle
+		valid  bool
+	}{
+		{"whole image", models.Rectangle{X: 0, Y: 0, Width: 100, Height: 100}, true},
+		{"offset", models.Rectangle{X: 10, Y: 20, Width: 30, Height: 40}, true},
+		{"negative x", models.Rectangle{X: -1, Y: 0, Width: 10, Height: 10}, false},
+		{"negative y", models.Rectangle{X: 0, Y: -5, Width: 10, Height: 10}, false},
+		{"zero width", models.Rectangle{X: 0, Y: 0, Width: 0, Height: 10}, false},
+		{"negative height", models.Rectangle{X: 0, Y: 0, Width: 10, Height: -10}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateROIRegion(tt.region)
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidROIRegion)
+			}
+		})
+	}
+}
+
+func TestAnalyzeWithRegion_MacroCropsFirst(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(64, 12)})
+
+	region := models.Rectangle{X: 10, Y: 20, Width: 50, Height: 40}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 64))
+	require.NoError(t, service.AnalyzeGypsumImage("region-1", file, AnalysisOptions{ROI: &region}))
+
+	result, err := service.GetAnalysisStatus("region-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, &region, result.ROI)
+
+	crop := "makeRectangle(10, 20, 50, 40);\nrun(\"Crop\");"
+	require.Contains(t, result.Macro, crop)
+	assert.Less(t, strings.Index(result.Macro, crop), strings.Index(result.Macro, `run("8-bit")`), "crop must precede processing")
+}
+
+func TestAnalyzeWithRegion_OutOfBoundsRejected(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(64, 12)})
+
+	region := models.Rectangle{X: 60, Y: 0, Width: 50, Height: 50}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 64))
+	err := service.SubmitAnalysis(context.Background(), "region-2", file, AnalysisOptions{ROI: &region})
+	assert.ErrorIs(t, err, ErrROIOutOfBounds)
+
+	// Nothing is kept for a rejected upload
+	_, err = service.GetAnalysisStatus("region-2")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "region-2.png"))
+}
+
+func TestAnalyzeWithRegion_RejectsROIFile(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(64, 12)})
+
+	opts := AnalysisOptions{
+		ROI:     &models.Rectangle{Width: 10, Height: 10},
+		ROIFile: newFileHeader(t, "core.roi", encodedROI()),
+	}
+	err := service.SubmitAnalysis(context.Background(), "region-3", newFileHeader(t, "sample.png", splitImagePNG(t, 64)), opts)
+	assert.ErrorIs(t, err, ErrInvalidROIRegion)
+}
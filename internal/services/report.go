@@ -0,0 +1,227 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/boombuler/barcode/qr"
+	"github.com/go-pdf/fpdf"
+	"github.com/go-pdf/fpdf/contrib/barcode"
+)
+
+// Page layout of the lab report, in millimeters on A4 portrait
+const (
+	reportMargin     = 15.0
+	reportWidth      = 210.0 - 2*reportMargin
+	reportLabelWidth = 50.0
+	reportRowHeight  = 7.0
+	reportQRSize     = 32.0
+)
+
+// ReportService renders single-page PDF lab reports for completed analyses
+type ReportService struct {
+	organization string
+	logoPath     string
+}
+
+// NewReportService creates a report service branded with the configured
+// organization name and optional logo
+func NewReportService(cfg *config.Config) *ReportService {
+	return &ReportService{
+		organization: cfg.ReportOrganization,
+		logoPath:     cfg.ReportLogoPath,
+	}
+}
+
+// reportBar is one bar of the purity chart
+type reportBar struct {
+	label string
+	value float64
+	color [3]int
+}
+
+// Generate renders the report of a completed analysis. statusURL is printed,
+// and encoded in a QR code when absolute, so a printed report leads back to
+// the live result. Unfinished analyses return ErrAnalysisInProgress and
+// failed or cancelled ones ErrAnalysisFailed.
+func (r *ReportService) Generate(result *models.AnalysisResult, statusURL string) ([]byte, error) {
+	switch {
+	case !isTerminal(result.Status):
+		return nil, ErrAnalysisInProgress
+	case result.Status != models.StatusCompleted:
+		return nil, ErrAnalysisFailed
+	}
+
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(reportMargin, reportMargin, reportMargin)
+	pdf.SetAutoPageBreak(false, reportMargin)
+	pdf.SetTitle("Gypsum analysis report "+result.ID, true)
+	pdf.SetCreator(r.organization, true)
+	pdf.AddPage()
+
+	// Core fonts are Windows-1252 encoded
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	r.writeHeader(pdf, tr)
+
+	reportSection(pdf, "Sample")
+	analysisDate := result.CreatedAt
+	if result.CompletedAt != nil {
+		analysisDate = *result.CompletedAt
+	}
+	operator := result.Metadata["operator"]
+	if operator == "" {
+		operator = "-"
+	}
+	reportTable(pdf, tr, [][2]string{
+		{"Sample ID", result.ID},
+		{"File", reportValue(result.Filename)},
+		{"Sample group", reportValue(result.SampleGroup)},
+		{"Analysis date", analysisDate.UTC().Format("2006-01-02 15:04 MST")},
+		{"Operator", operator},
+	})
+
+	reportSection(pdf, "Purity")
+	reportBarChart(pdf, tr, []reportBar{
+		{"Purity", result.PurityPercentage, [3]int{46, 125, 50}},
+		{"Gypsum content", result.GypsumContent, [3]int{96, 125, 139}},
+		{"Impurity content", result.ImpurityContent, [3]int{198, 40, 40}},
+	})
+
+	reportSection(pdf, "Mineral composition")
+	reportTable(pdf, tr, [][2]string{
+		{"Gypsum", reportPercent(result.GypsumContent)},
+		{"Calcite", reportPercent(result.CalciteContent)},
+		{"Quartz", reportPercent(result.QuartzContent)},
+		{"Other minerals", reportPercent(result.OtherMinerals)},
+	})
+
+	reportSection(pdf, "Measurement")
+	measurement := [][2]string{
+		{"Particle count", fmt.Sprintf("%d", result.ParticleCount)},
+		{"Confidence", reportPercent(result.Confidence * 100)},
+	}
+	if result.Parameters != nil {
+		measurement = append(measurement, [2]string{"Threshold method", result.Parameters.ThresholdMethod})
+	}
+	if result.ManualOverridePurity != nil {
+		measurement = append(measurement, [2]string{"Reviewed purity", fmt.Sprintf("%s (by %s)", reportPercent(*result.ManualOverridePurity), reportValue(result.ReviewedBy))})
+	}
+	reportTable(pdf, tr, measurement)
+
+	r.writeFooter(pdf, tr, statusURL)
+
+	if err := pdf.Error(); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		return nil, fmt.Errorf("failed to render report: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// writeHeader draws the logo, organization and report title
+func (r *ReportService) writeHeader(pdf *fpdf.Fpdf, tr func(string) string) {
+	textX := reportMargin
+	if r.logoPath != "" {
+		options := fpdf.ImageOptions{ImageType: strings.ToLower(strings.TrimPrefix(filepath.Ext(r.logoPath), ".")), ReadDpi: true}
+		pdf.ImageOptions(r.logoPath, reportMargin, reportMargin, 0, 18, false, options, 0, "")
+		textX += 45
+	}
+
+	pdf.SetXY(textX, reportMargin)
+	pdf.SetFont("Helvetica", "B", 16)
+	pdf.CellFormat(0, 8, tr(r.organization), "", 1, "L", false, 0, "")
+	pdf.SetX(textX)
+	pdf.SetFont("Helvetica", "", 12)
+	pdf.CellFormat(0, 7, "Gypsum Purity Analysis Report", "", 1, "L", false, 0, "")
+
+	pdf.SetY(reportMargin + 22)
+	pdf.SetDrawColor(120, 120, 120)
+	pdf.Line(reportMargin, pdf.GetY(), reportMargin+reportWidth, pdf.GetY())
+}
+
+// writeFooter draws the QR code linking to the status endpoint and the
+// generation time at the bottom of the page
+func (r *ReportService) writeFooter(pdf *fpdf.Fpdf, tr func(string) string, statusURL string) {
+	_, pageHeight := pdf.GetPageSize()
+	top := pageHeight - reportMargin - reportQRSize
+
+	if statusURL != "" {
+		// A relative link cannot be followed from paper, so it gets no QR code
+		if strings.HasPrefix(statusURL, "http://") || strings.HasPrefix(statusURL, "https://") {
+			key := barcode.RegisterQR(pdf, statusURL, qr.M, qr.Auto)
+			barcode.Barcode(pdf, key, reportMargin+reportWidth-reportQRSize, top, reportQRSize, reportQRSize, false)
+		}
+
+		pdf.SetXY(reportMargin, top+reportQRSize-12)
+		pdf.SetFont("Helvetica", "", 8)
+		pdf.MultiCell(reportWidth-reportQRSize-5, 4, tr("Live result: "+statusURL), "", "L", false)
+	}
+
+	pdf.SetXY(reportMargin, top+reportQRSize-4)
+	pdf.SetFont("Helvetica", "I", 8)
+	pdf.CellFormat(reportWidth-reportQRSize-5, 4, "Generated "+time.Now().UTC().Format("2006-01-02 15:04 MST"), "", 0, "L", false, 0, "")
+}
+
+// reportSection starts a titled block
+func reportSection(pdf *fpdf.Fpdf, title string) {
+	pdf.Ln(5)
+	pdf.SetFont("Helvetica", "B", 12)
+	pdf.CellFormat(0, 8, title, "", 1, "L", false, 0, "")
+}
+
+// reportTable draws a two-column table of labels and values
+func reportTable(pdf *fpdf.Fpdf, tr func(string) string, rows [][2]string) {
+	for i, row := range rows {
+		fill := i%2 == 0
+		pdf.SetFillColor(242, 242, 242)
+		pdf.SetFont("Helvetica", "B", 10)
+		pdf.CellFormat(reportLabelWidth, reportRowHeight, tr(row[0]), "", 0, "L", fill, 0, "")
+		pdf.SetFont("Helvetica", "", 10)
+		pdf.CellFormat(reportWidth-reportLabelWidth, reportRowHeight, tr(row[1]), "", 1, "L", fill, 0, "")
+	}
+}
+
+// reportBarChart draws horizontal percentage bars on a 0-100 scale
+func reportBarChart(pdf *fpdf.Fpdf, tr func(string) string, bars []reportBar) {
+	const valueWidth = 20.0
+	scale := reportWidth - reportLabelWidth - valueWidth
+
+	pdf.SetFont("Helvetica", "", 10)
+	pdf.SetDrawColor(180, 180, 180)
+	for _, bar := range bars {
+		y := pdf.GetY()
+		value := min(max(bar.value, 0), 100)
+
+		pdf.CellFormat(reportLabelWidth, reportRowHeight, tr(bar.label), "", 0, "L", false, 0, "")
+		pdf.Rect(reportMargin+reportLabelWidth, y+1, scale, reportRowHeight-2, "D")
+		pdf.SetFillColor(bar.color[0], bar.color[1], bar.color[2])
+		if value > 0 {
+			pdf.Rect(reportMargin+reportLabelWidth, y+1, scale*value/100, reportRowHeight-2, "F")
+		}
+		pdf.SetX(reportMargin + reportLabelWidth + scale)
+		pdf.CellFormat(valueWidth, reportRowHeight, reportPercent(bar.value), "", 1, "R", false, 0, "")
+	}
+}
+
+// reportPercent formats a percentage for the report
+func reportPercent(v float64) string {
+	return fmt.Sprintf("%.1f %%", v)
+}
+
+// reportValue shows empty report values as a dash
+func reportValue(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
@@ -0,0 +1,77 @@
+package services
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// completedResult is a finished analysis with every field the report shows
+func completedResult() *models.AnalysisResult {
+	completed := time.Date(2024, 5, 1, 12, 30, 0, 0, time.UTC)
+	params := DefaultMacroParams(macroConfig())
+	return &models.AnalysisResult{
+		ID:               "report-1",
+		Status:           models.StatusCompleted,
+		CreatedAt:        completed.Add(-time.Minute),
+		CompletedAt:      &completed,
+		Filename:         "Probe Nº 7.png",
+		SampleGroup:      "line-1",
+		Metadata:         map[string]string{"operator": "J. Müller"},
+		PurityPercentage: 86.4,
+		GypsumContent:    86.4,
+		ImpurityContent:  13.6,
+		CalciteContent:   4.08,
+		QuartzContent:    2.72,
+		OtherMinerals:    6.8,
+		ParticleCount:    212,
+		Confidence:       0.9,
+		Parameters:       &params,
+	}
+}
+
+func TestReportService_Generate(t *testing.T) {
+	reports := NewReportService(&config.Config{ReportOrganization: "Acme Gypsum Lab"})
+
+	report, err := reports.Generate(completedResult(), "http://localhost:8080/api/v1/analysis/status/report-1")
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(report, []byte("%PDF-")))
+	assert.Contains(t, string(report), "/Count 1", "the report fits on a single page")
+}
+
+func TestReportService_Logo(t *testing.T) {
+	logo := image.NewRGBA(image.Rect(0, 0, 40, 20))
+	for x := 0; x < 40; x++ {
+		logo.Set(x, 10, color.RGBA{R: 200, A: 255})
+	}
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, logo))
+	logoPath := filepath.Join(t.TempDir(), "logo.PNG")
+	require.NoError(t, os.WriteFile(logoPath, buf.Bytes(), 0644))
+
+	reports := NewReportService(&config.Config{ReportOrganization: "Acme Gypsum Lab", ReportLogoPath: logoPath})
+	report, err := reports.Generate(completedResult(), "")
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(report, []byte("%PDF-")))
+}
+
+func TestReportService_UnfinishedAnalyses(t *testing.T) {
+	reports := NewReportService(&config.Config{})
+
+	_, err := reports.Generate(&models.AnalysisResult{ID: "running", Status: models.StatusProcessing}, "")
+	assert.ErrorIs(t, err, ErrAnalysisInProgress)
+
+	_, err = reports.Generate(&models.AnalysisResult{ID: "failed", Status: models.StatusFailed}, "")
+	assert.ErrorIs(t, err, ErrAnalysisFailed)
+}
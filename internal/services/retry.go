@@ -0,0 +1,92 @@
+package services
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// errRetryScheduled is returned by runAnalysis when a failed analysis was
+// re-enqueued instead of being marked failed
+var errRetryScheduled = errors.New("analysis retry scheduled")
+
+// retryBackoff is the delay before the first analysis retry; it doubles on each attempt
+var retryBackoff = time.Second
+
+// retryableError is a Fiji execution failure of an analysis that has retries
+// left. Nothing is recorded on the result until runAnalysis decides.
+type retryableError struct {
+	msg string
+}
+
+func (e *retryableError) Error() string {
+	return e.msg
+}
+
+// failFiji records a Fiji execution failure, or returns a retryableError when
+// the analysis may be re-enqueued. A missing Fiji executable is never retried.
+func (s *AnalysisService) failFiji(analysisID string, err error, errorMsg string) error {
+	if !errors.Is(err, exec.ErrNotFound) {
+		if result, getErr := s.store.Get(analysisID); getErr == nil && result.RetryCount < s.config.MaxRetries {
+			return &retryableError{msg: errorMsg}
+		}
+	}
+	return s.updateResultWithError(analysisID, models.FailureFijiExec, errorMsg)
+}
+
+// retryAnalysis marks a failed analysis pending again and re-enqueues it on
+// the worker pool after retryBackoff, doubled for each earlier retry. It
+// returns errRetryScheduled, or the recorded failure when no retry is possible.
+func (s *AnalysisService) retryAnalysis(job *preparedAnalysis, cause *retryableError) error {
+	// The retry counts as active, so Shutdown waits through its backoff
+	if err := s.admit(); err != nil {
+		return s.updateResultWithError(job.id, models.FailureFijiExec, cause.msg)
+	}
+
+	result, err := s.update(job.id, func(result *models.AnalysisResult) error {
+		result.Status = models.StatusPending
+		result.Progress = 0
+		result.RetryCount++
+		return nil
+	})
+	if err != nil {
+		s.finish()
+		return err
+	}
+	s.publishStatusEvent(result)
+
+	delay := retryBackoff << (result.RetryCount - 1)
+	s.jobLog(job).
+		WithField("retry_attempt", result.RetryCount).
+		WithField("delay", delay.String()).
+		WithField("error", cause.msg).
+		Warn("Fiji failed, re-enqueueing analysis")
+
+	time.AfterFunc(delay, func() {
+		err := s.pool.Submit(func() { s.runQueued(job) })
+		if err == nil {
+			return
+		}
+		s.finish()
+
+		// A retry cancelled during its backoff stays cancelled
+		if current, getErr := s.store.Get(job.id); getErr == nil && !isTerminal(current.Status) {
+			s.updateResultWithError(job.id, models.FailureFijiExec, cause.msg)
+			s.discardRetry(job)
+		}
+	})
+	return errRetryScheduled
+}
+
+// discardRetry removes the input files kept for a retry that never ran
+func (s *AnalysisService) discardRetry(job *preparedAnalysis) {
+	if job.roiPath != "" {
+		os.Remove(job.roiPath)
+	}
+	if !s.config.KeepImages {
+		s.releaseImage(job.id, job.imageKey)
+	}
+}
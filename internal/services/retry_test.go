@@ -0,0 +1,76 @@
+package services
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryAnalysis_RecoversFromCrash(t *testing.T) {
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = time.Second }()
+
+	// Fiji is killed after the macro started, so it is not relaunched in place
+	runner := &flakyRunner{failures: 1, output: heartbeatMarker + "\nKilled"}
+	service := newTestService(t, &config.Config{MaxRetries: 2}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("crash-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("crash-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, 1, result.RetryCount)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, 2, runner.runs)
+}
+
+func TestRetryAnalysis_FailsAfterRetries(t *testing.T) {
+	retryBackoff = time.Millisecond
+	defer func() { retryBackoff = time.Second }()
+
+	runner := &flakyRunner{failures: 5, output: heartbeatMarker + "\nKilled"}
+	service := newTestService(t, &config.Config{MaxRetries: 2}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	assert.Error(t, service.AnalyzeGypsumImage("crash-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("crash-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, result.Status)
+	assert.Equal(t, models.FailureFijiExec, result.FailureCategory)
+	assert.Equal(t, 2, result.RetryCount)
+	assert.Equal(t, 3, runner.runs)
+}
+
+func TestRetryAnalysis_PermanentFailures(t *testing.T) {
+	tests := []struct {
+		name     string
+		runner   FijiRunner
+		category models.FailureCategory
+	}{
+		{"missing executable", &fakeRunner{err: exec.ErrNotFound}, models.FailureFijiExec},
+		{"unparseable output", &fakeRunner{output: "no results"}, models.FailureParse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service := newTestService(t, &config.Config{MaxRetries: 2}, tt.runner)
+
+			file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+			assert.Error(t, service.AnalyzeGypsumImage("permanent-1", file, AnalysisOptions{}))
+
+			result, err := service.GetAnalysisStatus("permanent-1")
+			require.NoError(t, err)
+			assert.Equal(t, models.StatusFailed, result.Status)
+			assert.Equal(t, tt.category, result.FailureCategory)
+			assert.Zero(t, result.RetryCount)
+		})
+	}
+}
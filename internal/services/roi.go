@@ -0,0 +1,125 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// ErrInvalidROIFile is returned when an uploaded ROI file is not an ImageJ ROI or ROI set
+var ErrInvalidROIFile = errors.New("invalid ROI file")
+
+const (
+	// roiMagic opens every ImageJ .roi file
+	roiMagic = "Iout"
+
+	// roiHeaderSize is the fixed header length of an ImageJ .roi file
+	roiHeaderSize = 64
+
+	// maxROIFileSize bounds ROI uploads; real ROI sets are a few kilobytes
+	maxROIFileSize = 5 * 1024 * 1024
+)
+
+// ValidateROIFile checks that an upload is a single ImageJ .roi file or a .zip
+// ROI set as saved by the ROI Manager
+func ValidateROIFile(file *multipart.FileHeader) error {
+	if file.Size > maxROIFileSize {
+		return fmt.Errorf("%w: larger than %d bytes", ErrInvalidROIFile, maxROIFileSize)
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open ROI file: %w", err)
+	}
+	defer src.Close()
+
+	data, err := io.ReadAll(io.LimitReader(src, maxROIFileSize+1))
+	if err != nil {
+		return fmt.Errorf("failed to read ROI file: %w", err)
+	}
+
+	switch strings.ToLower(filepath.Ext(file.Filename)) {
+	case ".roi":
+		return validateROI(data)
+	case ".zip":
+		return validateROISet(data)
+	default:
+		return fmt.Errorf("%w: expected a .roi or .zip file", ErrInvalidROIFile)
+	}
+}
+
+// validateROI checks the header of a single encoded ROI
+func validateROI(data []byte) error {
+	if len(data) < roiHeaderSize || string(data[:len(roiMagic)]) != roiMagic {
+		return fmt.Errorf("%w: missing ImageJ ROI header", ErrInvalidROIFile)
+	}
+	return nil
+}
+
+// validateROISet checks that a zip archive contains only ImageJ ROIs
+func validateROISet(data []byte) error {
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidROIFile, err)
+	}
+
+	count := 0
+	for _, entry := range archive.File {
+		if entry.FileInfo().IsDir() {
+			continue
+		}
+		if !strings.EqualFold(filepath.Ext(entry.Name), ".roi") {
+			return fmt.Errorf("%w: unexpected entry %q in ROI set", ErrInvalidROIFile, entry.Name)
+		}
+
+		rc, err := entry.Open()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidROIFile, err)
+		}
+		roi, err := io.ReadAll(io.LimitReader(rc, maxROIFileSize))
+		rc.Close()
+		if err != nil {
+			return fmt.Errorf("%w: %v", ErrInvalidROIFile, err)
+		}
+		if err := validateROI(roi); err != nil {
+			return fmt.Errorf("%w (entry %q)", err, entry.Name)
+		}
+		count++
+	}
+
+	if count == 0 {
+		return fmt.Errorf("%w: ROI set is empty", ErrInvalidROIFile)
+	}
+	return nil
+}
+
+// parseROIResult parses an "index,purity,name" line printed by the macro
+func parseROIResult(value string) (models.ROIResult, error) {
+	parts := strings.SplitN(value, ",", 3)
+	if len(parts) < 2 {
+		return models.ROIResult{}, fmt.Errorf("malformed ROI result %q", value)
+	}
+
+	index, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return models.ROIResult{}, fmt.Errorf("invalid ROI index: %w", err)
+	}
+	purity, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return models.ROIResult{}, fmt.Errorf("invalid ROI purity: %w", err)
+	}
+
+	roi := models.ROIResult{Index: index, PurityPercentage: purity}
+	if len(parts) == 3 {
+		roi.Name = parts[2]
+	}
+	return roi, nil
+}
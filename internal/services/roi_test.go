@@ -0,0 +1,126 @@
+package services
+
+import (
+	"archive/zip"
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodedROI returns a minimal ImageJ .roi file: the magic followed by an empty header
+func encodedROI() []byte {
+	return append([]byte(roiMagic), make([]byte, roiHeaderSize-len(roiMagic))...)
+}
+
+// roiSet zips the named entries as the ROI Manager does when saving a set
+func roiSet(t *testing.T, entries map[string][]byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	writer := zip.NewWriter(&buf)
+	for name, content := range entries {
+		w, err := writer.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+// withROIResults inserts per-ROI lines into a Fiji results block
+func withROIResults(output string, lines ...string) string {
+	return strings.Replace(output, "ANALYSIS_RESULTS_END", strings.Join(lines, "\n")+"\nANALYSIS_RESULTS_END", 1)
+}
+
+func TestValidateROIFile(t *testing.T) {
+	tests := []struct {
+		name     string
+		filename string
+		content  []byte
+		valid    bool
+	}{
+		{"single roi", "core.roi", encodedROI(), true},
+		{"roi set", "RoiSet.zip", roiSet(t, map[string][]byte{"0001-0001.roi": encodedROI(), "0002-0002.roi": encodedROI()}), true},
+		{"bad magic", "core.roi", bytes.Repeat([]byte{'x'}, roiHeaderSize), false},
+		{"truncated header", "core.roi", []byte(roiMagic), false},
+		{"unsupported extension", "core.txt", encodedROI(), false},
+		{"not a zip", "RoiSet.zip", []byte("plain text"), false},
+		{"foreign zip entry", "RoiSet.zip", roiSet(t, map[string][]byte{"notes.txt": []byte("hi")}), false},
+		{"corrupt zip entry", "RoiSet.zip", roiSet(t, map[string][]byte{"0001.roi": []byte("junk")}), false},
+		{"empty set", "RoiSet.zip", roiSet(t, nil), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateROIFile(newFileHeader(t, tt.filename, tt.content))
+			if tt.valid {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, ErrInvalidROIFile)
+			}
+		})
+	}
+}
+
+func TestAnalyzeWithROI_Single(t *testing.T) {
+	output := withROIResults(fijiOutput(64, 12), "roi:0,72.5,core")
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: output})
+
+	opts := AnalysisOptions{ROIFile: newFileHeader(t, "core.roi", encodedROI())}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 64))
+	require.NoError(t, service.AnalyzeGypsumImage("roi-1", file, opts))
+
+	result, err := service.GetAnalysisStatus("roi-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, "core.roi", result.ROIFile)
+	assert.Equal(t, []models.ROIResult{{Index: 0, Name: "core", PurityPercentage: 72.5}}, result.ROIResults)
+
+	roiPath := filepath.Join(service.config.TempDir, "roi-1_rois.roi")
+	assert.Contains(t, result.Macro, `roiManager("Open", "`+filepath.ToSlash(roiPath)+`")`)
+	_, err = os.Stat(roiPath)
+	assert.True(t, os.IsNotExist(err), "ROI file should be removed after analysis")
+}
+
+func TestAnalyzeWithROI_Set(t *testing.T) {
+	output := withROIResults(fijiOutput(58, 40),
+		"roi:0,91,vein, upper",
+		"roi:1,40.25,matrix",
+		"roi:2,0,")
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: output})
+
+	set := roiSet(t, map[string][]byte{"a.roi": encodedROI(), "b.roi": encodedROI(), "c.roi": encodedROI()})
+	opts := AnalysisOptions{ROIFile: newFileHeader(t, "RoiSet.zip", set)}
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 58))
+	require.NoError(t, service.AnalyzeGypsumImage("roi-2", file, opts))
+
+	result, err := service.GetAnalysisStatus("roi-2")
+	require.NoError(t, err)
+	assert.Equal(t, []models.ROIResult{
+		{Index: 0, Name: "vein, upper", PurityPercentage: 91},
+		{Index: 1, Name: "matrix", PurityPercentage: 40.25},
+		{Index: 2, Name: "", PurityPercentage: 0},
+	}, result.ROIResults)
+	assert.Contains(t, result.Macro, `roiManager("Combine")`)
+}
+
+func TestAnalyzeWithoutROI_MacroAnalyzesWholeImage(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(64, 12)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 64))
+	require.NoError(t, service.AnalyzeGypsumImage("roi-3", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("roi-3")
+	require.NoError(t, err)
+	assert.Empty(t, result.ROIResults)
+	assert.NotContains(t, result.Macro, "roiManager")
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+)
+
+// FijiRunner executes an ImageJ macro and returns the console output. onLine,
+// when non-nil, is called for every output line as soon as it is printed.
+type FijiRunner interface {
+	Run(ctx context.Context, macroPath string, onLine func(line string)) ([]byte, error)
+}
+
+// fijiRetryBackoff is the delay before Fiji is relaunched after failing to
+// start; it doubles on each retry
+var fijiRetryBackoff = 500 * time.Millisecond
+
+// isLaunchFailure reports whether a failed run never got as far as the macro,
+// as when the JVM or the display fails to start. The macro prints a heartbeat
+// first thing, so failures after it are genuine analysis failures, and a
+// missing Fiji executable will not appear on a retry.
+func isLaunchFailure(err error, output []byte) bool {
+	if err == nil || errors.Is(err, exec.ErrNotFound) {
+		return false
+	}
+	return !bytes.Contains(output, []byte(heartbeatMarker))
+}
+
+// execFijiRunner runs macros through the Fiji executable in headless mode,
+// optionally under sandbox resource limits
+type execFijiRunner struct {
+	fijiPath string
+	sandbox  *SandboxLimits
+}
+
+// command builds the Fiji invocation for a macro
+func (r *execFijiRunner) command(ctx context.Context, macroPath string) (*exec.Cmd, error) {
+	args := []string{"--headless", "--console", macroPath}
+	if r.sandbox == nil {
+		return exec.CommandContext(ctx, r.fijiPath, args...), nil
+	}
+	return sandboxCommand(ctx, *r.sandbox, r.fijiPath, args...)
+}
+
+// Run launches Fiji with the given macro and streams its combined output
+func (r *execFijiRunner) Run(ctx context.Context, macroPath string, onLine func(line string)) ([]byte, error) {
+	cmd, err := r.command(ctx, macroPath)
+	if err != nil {
+		return nil, err
+	}
+	// Don't let helper processes that inherit the pipe keep a killed run alive
+	cmd.WaitDelay = 5 * time.Second
+
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	var output bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			output.WriteString(line)
+			output.WriteByte('\n')
+			if onLine != nil {
+				onLine(line)
+			}
+		}
+		// Keep draining so Fiji never blocks on a full pipe
+		io.Copy(io.Discard, reader)
+	}()
+
+	err = cmd.Run()
+	writer.Close()
+	<-done
+
+	return output.Bytes(), err
+}
+
+// newFijiRunner creates the runner for the configured Fiji executable
+func newFijiRunner(cfg *config.Config) *execFijiRunner {
+	runner := &execFijiRunner{fijiPath: cfg.FijiPath}
+	if cfg.FijiSandbox {
+		runner.sandbox = &SandboxLimits{
+			CPUSeconds: cfg.FijiSandboxCPUSeconds,
+			MemoryMB:   cfg.FijiSandboxMemoryMB,
+			NoNetwork:  cfg.FijiSandboxNoNetwork,
+		}
+	}
+	return runner
+}
@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExecFijiRunner_StreamsLines(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a POSIX shell")
+	}
+
+	script := filepath.Join(t.TempDir(), "fiji.sh")
+	require.NoError(t, os.WriteFile(script, []byte("#!/bin/sh\necho FIJI_HEARTBEAT\necho purity_percentage:80\necho oops >&2\n"), 0755))
+
+	var lines []string
+	runner := &execFijiRunner{fijiPath: script}
+	output, err := runner.Run(context.Background(), "macro.ijm", func(line string) {
+		lines = append(lines, line)
+	})
+
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FIJI_HEARTBEAT", "purity_percentage:80", "oops"}, lines)
+	assert.Equal(t, "FIJI_HEARTBEAT\npurity_percentage:80\noops\n", string(output))
+}
+
+// flakyRunner fails the first failures runs with the given output, then
+// succeeds with a full result
+type flakyRunner struct {
+	failures int
+	output   string
+	runs     int
+}
+
+func (r *flakyRunner) Run(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+	r.runs++
+	if r.runs <= r.failures {
+		return []byte(r.output), errors.New("exit status 1")
+	}
+	return []byte(fijiOutput(80, 20)), nil
+}
+
+func TestRunMacro_RetriesLaunchFailures(t *testing.T) {
+	fijiRetryBackoff = time.Millisecond
+	defer func() { fijiRetryBackoff = 500 * time.Millisecond }()
+
+	runner := &flakyRunner{failures: 2, output: "Error: Can't connect to X11 window server"}
+	service := newTestService(t, &config.Config{FijiMaxRetries: 2}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("retry-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("retry-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, 3, runner.runs)
+}
+
+func TestRunMacro_GivesUpAfterRetries(t *testing.T) {
+	fijiRetryBackoff = time.Millisecond
+	defer func() { fijiRetryBackoff = 500 * time.Millisecond }()
+
+	runner := &flakyRunner{failures: 5, output: "Error occurred during initialization of VM"}
+	service := newTestService(t, &config.Config{FijiMaxRetries: 2}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	assert.Error(t, service.AnalyzeGypsumImage("retry-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("retry-2")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, result.Status)
+	assert.Equal(t, models.FailureFijiExec, result.FailureCategory)
+	assert.Equal(t, "Fiji execution failed after 3 attempts: exit status 1", result.Error)
+	assert.Equal(t, 3, runner.runs)
+}
+
+func TestRunMacro_LaunchFailuresAreNotReenqueued(t *testing.T) {
+	fijiRetryBackoff = time.Millisecond
+	retryBackoff = time.Millisecond
+	defer func() {
+		fijiRetryBackoff = 500 * time.Millisecond
+		retryBackoff = time.Second
+	}()
+
+	runner := &flakyRunner{failures: 10, output: "Error occurred during initialization of VM"}
+	service := newTestService(t, &config.Config{FijiMaxRetries: 2, MaxRetries: 2}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	assert.Error(t, service.AnalyzeGypsumImage("retry-4", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("retry-4")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, result.Status)
+	assert.Zero(t, result.RetryCount)
+	assert.Equal(t, 3, runner.runs)
+}
+
+func TestRunMacro_AnalysisFailuresAreNotRetried(t *testing.T) {
+	runner := &flakyRunner{failures: 1, output: "FIJI_HEARTBEAT\njava.lang.OutOfMemoryError"}
+	service := newTestService(t, &config.Config{FijiMaxRetries: 2}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	assert.Error(t, service.AnalyzeGypsumImage("retry-3", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("retry-3")
+	require.NoError(t, err)
+	assert.Equal(t, "Fiji execution failed: exit status 1", result.Error)
+	assert.Equal(t, 1, runner.runs)
+}
+
+func TestIsLaunchFailure(t *testing.T) {
+	assert.True(t, isLaunchFailure(errors.New("exit status 1"), []byte("Could not create the Java Virtual Machine")))
+	assert.False(t, isLaunchFailure(errors.New("exit status 1"), []byte("FIJI_HEARTBEAT\n")), "the macro had started")
+	assert.False(t, isLaunchFailure(&exec.Error{Name: "fiji", Err: exec.ErrNotFound}, nil), "a missing executable stays missing")
+	assert.False(t, isLaunchFailure(nil, nil))
+}
@@ -0,0 +1,38 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSandboxUnsupported is returned when sandboxing is requested on a platform without support
+var ErrSandboxUnsupported = errors.New("Fiji sandboxing is only supported on Linux")
+
+// SandboxLimits constrains the resources available to a Fiji process
+type SandboxLimits struct {
+	// CPUSeconds caps processor time; the process is killed with SIGXCPU when exceeded
+	CPUSeconds int
+
+	// MemoryMB caps the address space. The JVM reserves more virtual memory
+	// than it uses, so leave generous headroom above the Fiji heap size.
+	MemoryMB int
+
+	// NoNetwork runs Fiji in an empty network namespace
+	NoNetwork bool
+}
+
+// ulimitScript builds a shell prologue that applies the limits and then execs
+// the real command, which is passed as the script's positional arguments
+func (l SandboxLimits) ulimitScript() string {
+	var steps []string
+	if l.CPUSeconds > 0 {
+		steps = append(steps, fmt.Sprintf("ulimit -t %d", l.CPUSeconds))
+	}
+	if l.MemoryMB > 0 {
+		steps = append(steps, fmt.Sprintf("ulimit -v %d", l.MemoryMB*1024))
+	}
+	steps = append(steps, `exec "$0" "$@"`)
+
+	return strings.Join(steps, " && ")
+}
@@ -0,0 +1,28 @@
+//go:build linux
+
+package services
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// sandboxCommand wraps the command in a shell that applies the resource limits
+// before exec'ing it, optionally inside a private user and network namespace
+func sandboxCommand(ctx context.Context, limits SandboxLimits, name string, args ...string) (*exec.Cmd, error) {
+	shellArgs := append([]string{"-c", limits.ulimitScript(), name}, args...)
+	cmd := exec.CommandContext(ctx, "/bin/sh", shellArgs...)
+
+	if limits.NoNetwork {
+		// A new user namespace lets an unprivileged server create the network namespace
+		cmd.SysProcAttr = &syscall.SysProcAttr{
+			Cloneflags:  syscall.CLONE_NEWUSER | syscall.CLONE_NEWNET,
+			UidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getuid(), HostID: os.Getuid(), Size: 1}},
+			GidMappings: []syscall.SysProcIDMap{{ContainerID: os.Getgid(), HostID: os.Getgid(), Size: 1}},
+		}
+	}
+
+	return cmd, nil
+}
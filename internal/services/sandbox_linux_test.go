@@ -0,0 +1,81 @@
+//go:build linux
+
+package services
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeFiji writes an executable shell script standing in for the Fiji launcher
+func fakeFiji(t *testing.T, body string) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "fiji.sh")
+	require.NoError(t, os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755))
+	return path
+}
+
+func TestSandbox_AppliesLimits(t *testing.T) {
+	script := fakeFiji(t, `echo "cpu:$(ulimit -t) mem:$(ulimit -v) args:$*"
+tail -n +3 /proc/net/dev | cut -d: -f1 | tr -d ' '`)
+
+	runner := &execFijiRunner{
+		fijiPath: script,
+		sandbox:  &SandboxLimits{CPUSeconds: 5, MemoryMB: 100, NoNetwork: true},
+	}
+	output, err := runner.Run(context.Background(), "macro.ijm", nil)
+	if errors.Is(err, syscall.EPERM) || errors.Is(err, syscall.EINVAL) {
+		t.Skipf("user namespaces unavailable: %v", err)
+	}
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	assert.Equal(t, "cpu:5 mem:102400 args:--headless --console macro.ijm", lines[0])
+	assert.Equal(t, []string{"lo"}, lines[1:], "only loopback should exist in the isolated network namespace")
+}
+
+func TestSandbox_MemoryHogIsStopped(t *testing.T) {
+	// Buffers 300MB in memory, well above the 100MB address space limit
+	script := fakeFiji(t, `head -c 300000000 /dev/zero | tail -c 300000000 > /dev/null || exit 1
+echo finished`)
+
+	unlimited := &execFijiRunner{fijiPath: script}
+	output, err := unlimited.Run(context.Background(), "macro.ijm", nil)
+	require.NoError(t, err, "the hog should succeed without limits")
+	assert.Contains(t, string(output), "finished")
+
+	limited := &execFijiRunner{fijiPath: script, sandbox: &SandboxLimits{MemoryMB: 100}}
+	output, err = limited.Run(context.Background(), "macro.ijm", nil)
+	assert.Error(t, err)
+	assert.NotContains(t, string(output), "finished")
+}
+
+func TestSandbox_CPUHogIsKilled(t *testing.T) {
+	script := fakeFiji(t, `while :; do :; done`)
+
+	runner := &execFijiRunner{fijiPath: script, sandbox: &SandboxLimits{CPUSeconds: 1}}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	_, err := runner.Run(ctx, "macro.ijm", nil)
+
+	var exitErr *exec.ExitError
+	require.ErrorAs(t, err, &exitErr)
+	status := exitErr.Sys().(syscall.WaitStatus)
+	assert.True(t, status.Signaled(), "process should be killed by a signal")
+	// The kernel sends SIGXCPU at the soft limit and SIGKILL at the hard limit,
+	// which ulimit sets to the same value
+	assert.Contains(t, []syscall.Signal{syscall.SIGXCPU, syscall.SIGKILL}, status.Signal())
+	assert.NoError(t, ctx.Err(), "the CPU limit, not the timeout, should stop the process")
+}
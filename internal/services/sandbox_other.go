@@ -0,0 +1,13 @@
+//go:build !linux
+
+package services
+
+import (
+	"context"
+	"os/exec"
+)
+
+// sandboxCommand is not available outside Linux
+func sandboxCommand(ctx context.Context, limits SandboxLimits, name string, args ...string) (*exec.Cmd, error) {
+	return nil, ErrSandboxUnsupported
+}
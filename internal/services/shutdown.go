@@ -0,0 +1,90 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrShuttingDown is returned for analyses submitted once Shutdown has begun
+var ErrShuttingDown = errors.New("analysis service is shutting down")
+
+// shutdownGrace is how long Shutdown waits for cancelled analyses to record
+// their cancellation once its deadline has passed
+var shutdownGrace = 5 * time.Second
+
+// Shutdown stops accepting analyses and waits for the queued and running ones
+// to finish, then for their webhook and callback deliveries. When ctx ends first, the rest
+// are cancelled and ctx's error is returned once they have stopped or
+// shutdownGrace has passed.
+func (s *AnalysisService) Shutdown(ctx context.Context) error {
+	s.mutex.Lock()
+	s.shuttingDown = true
+	s.mutex.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		s.active.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		// Every accepted analysis has finished, so the workers are idle
+		poolErr := s.pool.Shutdown(ctx)
+		if err := s.webhooks.Shutdown(ctx); err != nil {
+			return err
+		}
+		return poolErr
+	case <-ctx.Done():
+	}
+
+	// Queued analyses see abort when they start; running ones are cancelled
+	s.abortOnce.Do(func() { close(s.abort) })
+	s.mutex.RLock()
+	cancels := make([]context.CancelFunc, 0, len(s.running))
+	for _, cancel := range s.running {
+		cancels = append(cancels, cancel)
+	}
+	s.mutex.RUnlock()
+	for _, cancel := range cancels {
+		cancel()
+	}
+	s.logger.WithField("analyses", len(cancels)).Warn("Shutdown deadline passed, cancelling running analyses")
+
+	select {
+	case <-drained:
+	case <-time.After(shutdownGrace):
+		s.logger.Error("Analyses still running after cancellation")
+	}
+	// ctx has ended, so pending webhook retries are abandoned
+	s.webhooks.Shutdown(ctx)
+	return ctx.Err()
+}
+
+// admit counts an analysis as active until finish is called, unless the
+// service is shutting down
+func (s *AnalysisService) admit() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.shuttingDown {
+		return ErrShuttingDown
+	}
+	s.active.Add(1)
+	return nil
+}
+
+// finish marks an admitted analysis as done
+func (s *AnalysisService) finish() {
+	s.active.Done()
+}
+
+// aborted reports whether Shutdown ran out of time and is cancelling analyses
+func (s *AnalysisService) aborted() bool {
+	select {
+	case <-s.abort:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,79 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdown_WaitsForRunningAnalyses(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{}), output: fijiOutput(80, 20)}
+	service := newTestService(t, &config.Config{}, runner)
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "drain-1", file, AnalysisOptions{}))
+	require.Eventually(t, func() bool {
+		result, err := service.GetAnalysisStatus("drain-1")
+		return err == nil && result.Status == models.StatusProcessing
+	}, 5*time.Second, 5*time.Millisecond)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- service.Shutdown(context.Background()) }()
+
+	// New analyses are refused while the running one is waited for
+	require.Eventually(t, func() bool {
+		service.mutex.RLock()
+		defer service.mutex.RUnlock()
+		return service.shuttingDown
+	}, 5*time.Second, 5*time.Millisecond)
+	err := service.SubmitAnalysis(context.Background(), "drain-2", file, AnalysisOptions{})
+	assert.ErrorIs(t, err, ErrShuttingDown)
+	_, err = service.GetAnalysisStatus("drain-2")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+
+	select {
+	case <-stopped:
+		t.Fatal("Shutdown returned while an analysis was running")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(runner.release)
+	require.NoError(t, <-stopped)
+
+	result, err := service.GetAnalysisStatus("drain-1")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, result.Status)
+}
+
+func TestShutdown_CancelsAtDeadline(t *testing.T) {
+	runner := &blockingRunner{release: make(chan struct{}), output: fijiOutput(80, 20)}
+	service := newTestService(t, &config.Config{}, runner)
+	service.pool = NewWorkerPool(1, 5, nil)
+	defer service.pool.Shutdown(context.Background())
+
+	// One analysis runs, the other is still queued behind it
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "abort-1", file, AnalysisOptions{}))
+	require.Eventually(t, func() bool {
+		result, err := service.GetAnalysisStatus("abort-1")
+		return err == nil && result.Status == models.StatusProcessing
+	}, 5*time.Second, 5*time.Millisecond)
+	require.NoError(t, service.SubmitAnalysis(context.Background(), "abort-2", file, AnalysisOptions{}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, service.Shutdown(ctx), context.DeadlineExceeded)
+
+	for _, id := range []string{"abort-1", "abort-2"} {
+		result, err := service.GetAnalysisStatus(id)
+		require.NoError(t, err)
+		assert.Equal(t, models.StatusCancelled, result.Status, id)
+		assert.Equal(t, "Analysis cancelled by server shutdown", result.Error, id)
+	}
+}
@@ -0,0 +1,120 @@
+package services
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+)
+
+// ErrResultNotSigned is returned when verifying a result that carries no
+// signature, because it did not complete or signing was disabled at the time
+var ErrResultNotSigned = errors.New("result is not signed")
+
+// ErrUnknownSigningKey is returned when a result was signed with a key version
+// that is no longer configured
+var ErrUnknownSigningKey = errors.New("unknown signing key version")
+
+// signedMeasurements is the canonical form of the signed part of a result.
+// Fields are encoded in declaration order, so the order must never change.
+type signedMeasurements struct {
+	AnalysisID       string  `json:"analysis_id"`
+	PurityPercentage float64 `json:"purity_percentage"`
+	GypsumContent    float64 `json:"gypsum_content_percentage"`
+	ImpurityContent  float64 `json:"impurity_content_percentage"`
+	CalciteContent   float64 `json:"calcite_content_percentage"`
+	QuartzContent    float64 `json:"quartz_content_percentage"`
+	ParticleCount    int     `json:"particle_count"`
+	ThresholdValue   float64 `json:"threshold_value"`
+	// Omitted when unset, so results signed before they existed still verify
+	ThresholdLower *float64 `json:"threshold_lower,omitempty"`
+	ThresholdUpper *float64 `json:"threshold_upper,omitempty"`
+}
+
+// resultSignature returns the hex HMAC-SHA256 of the canonical measurements
+// of result, keyed with secret
+func resultSignature(secret string, result *models.AnalysisResult) (string, error) {
+	canonical, err := json.Marshal(signedMeasurements{
+		AnalysisID:       result.ID,
+		PurityPercentage: result.PurityPercentage,
+		GypsumContent:    result.GypsumContent,
+		ImpurityContent:  result.ImpurityContent,
+		CalciteContent:   result.CalciteContent,
+		QuartzContent:    result.QuartzContent,
+		ParticleCount:    result.ParticleCount,
+		ThresholdValue:   result.ThresholdValue,
+		ThresholdLower:   result.ThresholdLower,
+		ThresholdUpper:   result.ThresholdUpper,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(canonical)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// signResult signs a completed result with the current signing key. It is a
+// no-op while no signing keys are configured.
+func (s *AnalysisService) signResult(result *models.AnalysisResult) error {
+	if len(s.signingKeys) == 0 {
+		return nil
+	}
+
+	key := s.signingKeys[0]
+	signature, err := resultSignature(key.Secret, result)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	result.ResultSignature = signature
+	result.SignatureKeyVersion = key.Version
+	result.SignedAt = &now
+	return nil
+}
+
+// signingKey returns the configured secret of a key version
+func (s *AnalysisService) signingKey(version string) (config.SigningKey, bool) {
+	for _, key := range s.signingKeys {
+		if key.Version == version {
+			return key, true
+		}
+	}
+	return config.SigningKey{}, false
+}
+
+// VerifyResult recomputes the signature of a result and reports whether it
+// still matches the measurements
+func (s *AnalysisService) VerifyResult(analysisID string) (*models.ResultVerification, error) {
+	result, err := s.store.Get(analysisID)
+	if err != nil {
+		return nil, err
+	}
+	if !isTerminal(result.Status) {
+		return nil, ErrAnalysisInProgress
+	}
+	if result.ResultSignature == "" {
+		return nil, ErrResultNotSigned
+	}
+
+	key, ok := s.signingKey(result.SignatureKeyVersion)
+	if !ok {
+		return nil, ErrUnknownSigningKey
+	}
+	signature, err := resultSignature(key.Secret, result)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.ResultVerification{
+		Valid:      hmac.Equal([]byte(signature), []byte(result.ResultSignature)),
+		SignedAt:   result.SignedAt,
+		KeyVersion: result.SignatureKeyVersion,
+	}, nil
+}
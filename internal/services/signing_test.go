@@ -0,0 +1,89 @@
+package services
+
+import (
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignResult_VerifiesUntilModified(t *testing.T) {
+	service := newTestService(t, &config.Config{ResultSigningKeys: []string{"v1:s3cret"}}, &fakeRunner{output: fijiOutput(82, 30)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("signed-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("signed-1")
+	require.NoError(t, err)
+	assert.Len(t, result.ResultSignature, 64)
+	assert.Equal(t, "v1", result.SignatureKeyVersion)
+	require.NotNil(t, result.SignedAt)
+
+	verification, err := service.VerifyResult("signed-1")
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+	assert.Equal(t, "v1", verification.KeyVersion)
+	assert.Equal(t, result.SignedAt, verification.SignedAt)
+
+	// Unsigned fields can change without affecting the signature
+	_, err = service.store.Update("signed-1", func(result *models.AnalysisResult) error {
+		result.ReviewNote = "Checked"
+		return nil
+	})
+	require.NoError(t, err)
+	verification, err = service.VerifyResult("signed-1")
+	require.NoError(t, err)
+	assert.True(t, verification.Valid)
+
+	_, err = service.store.Update("signed-1", func(result *models.AnalysisResult) error {
+		result.PurityPercentage = 95
+		return nil
+	})
+	require.NoError(t, err)
+	verification, err = service.VerifyResult("signed-1")
+	require.NoError(t, err)
+	assert.False(t, verification.Valid, "a modified purity no longer matches")
+}
+
+func TestSignResult_KeyRotation(t *testing.T) {
+	cfg := &config.Config{ResultSigningKeys: []string{"v1:old-secret"}}
+	service := newTestService(t, cfg, &fakeRunner{output: fijiOutput(82, 30)})
+	require.NoError(t, service.AnalyzeGypsumImage("rotate-1", newFileHeader(t, "sample.png", splitImagePNG(t, 80)), AnalysisOptions{}))
+
+	// Rotating keeps the old key for verification only
+	service.signingKeys, _ = config.ParseSigningKeys([]string{"v2:new-secret", "v1:old-secret"})
+	require.NoError(t, service.AnalyzeGypsumImage("rotate-2", newFileHeader(t, "sample.png", splitImagePNG(t, 60)), AnalysisOptions{}))
+
+	for id, version := range map[string]string{"rotate-1": "v1", "rotate-2": "v2"} {
+		verification, err := service.VerifyResult(id)
+		require.NoError(t, err)
+		assert.True(t, verification.Valid, id)
+		assert.Equal(t, version, verification.KeyVersion, id)
+	}
+
+	service.signingKeys, _ = config.ParseSigningKeys([]string{"v2:new-secret"})
+	_, err := service.VerifyResult("rotate-1")
+	assert.ErrorIs(t, err, ErrUnknownSigningKey)
+}
+
+func TestVerifyResult_Unsigned(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(82, 30)})
+	require.NoError(t, service.AnalyzeGypsumImage("unsigned-1", newFileHeader(t, "sample.png", splitImagePNG(t, 80)), AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("unsigned-1")
+	require.NoError(t, err)
+	assert.Empty(t, result.ResultSignature, "nothing is signed without keys")
+
+	_, err = service.VerifyResult("unsigned-1")
+	assert.ErrorIs(t, err, ErrResultNotSigned)
+
+	_, err = service.VerifyResult("missing")
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "queued", Status: models.StatusPending}))
+	_, err = service.VerifyResult("queued")
+	assert.ErrorIs(t, err, ErrAnalysisInProgress)
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// point is a particle centroid in image coordinates
+type point struct {
+	X, Y float64
+}
+
+// parseCentroid parses an "x,y" centroid value printed by the macro
+func parseCentroid(value string) (point, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 2 {
+		return point{}, fmt.Errorf("invalid centroid %q", value)
+	}
+
+	x, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return point{}, fmt.Errorf("invalid centroid x %q: %w", parts[0], err)
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return point{}, fmt.Errorf("invalid centroid y %q: %w", parts[1], err)
+	}
+
+	return point{X: x, Y: y}, nil
+}
+
+// computeSpacingStats finds each centroid's nearest neighbor and summarizes the
+// distances. It returns nil when there are fewer than two centroids.
+func computeSpacingStats(centroids []point) *models.SpacingStats {
+	if len(centroids) < 2 {
+		return nil
+	}
+
+	sorted := make([]point, len(centroids))
+	copy(sorted, centroids)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].X < sorted[j].X })
+
+	distances := make([]float64, len(sorted))
+	for i, p := range sorted {
+		best := math.Inf(1)
+
+		// Sweep outwards along x, stopping once the x gap alone exceeds the best distance
+		for j := i + 1; j < len(sorted) && sorted[j].X-p.X < best; j++ {
+			best = math.Min(best, math.Hypot(sorted[j].X-p.X, sorted[j].Y-p.Y))
+		}
+		for j := i - 1; j >= 0 && p.X-sorted[j].X < best; j-- {
+			best = math.Min(best, math.Hypot(sorted[j].X-p.X, sorted[j].Y-p.Y))
+		}
+
+		distances[i] = best
+	}
+
+	sort.Float64s(distances)
+
+	sum := 0.0
+	for _, d := range distances {
+		sum += d
+	}
+
+	n := len(distances)
+	median := distances[n/2]
+	if n%2 == 0 {
+		median = (distances[n/2-1] + distances[n/2]) / 2
+	}
+
+	return &models.SpacingStats{
+		ParticleCount: n,
+		Mean:          sum / float64(n),
+		Median:        median,
+		Min:           distances[0],
+		Max:           distances[n-1],
+		Unit:          pixelUnit,
+	}
+}
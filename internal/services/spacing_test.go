@@ -0,0 +1,83 @@
+package services
+
+import (
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCentroid(t *testing.T) {
+	p, err := parseCentroid("12.5,40.25")
+	require.NoError(t, err)
+	assert.Equal(t, point{X: 12.5, Y: 40.25}, p)
+
+	for _, value := range []string{"", "12.5", "a,b", "1,2,3"} {
+		_, err := parseCentroid(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestComputeSpacingStats(t *testing.T) {
+	// Three particles in a row 3px apart and one isolated particle 10px from the nearest
+	centroids := []point{{0, 0}, {3, 0}, {6, 0}, {6, 10}}
+
+	stats := computeSpacingStats(centroids)
+	require.NotNil(t, stats)
+
+	assert.Equal(t, 4, stats.ParticleCount)
+	assert.InDelta(t, (3.0+3+3+10)/4, stats.Mean, 1e-9)
+	assert.InDelta(t, 3.0, stats.Median, 1e-9)
+	assert.InDelta(t, 3.0, stats.Min, 1e-9)
+	assert.InDelta(t, 10.0, stats.Max, 1e-9)
+	assert.Equal(t, "px", stats.Unit)
+}
+
+func TestComputeSpacingStats_TooFewParticles(t *testing.T) {
+	assert.Nil(t, computeSpacingStats(nil))
+	assert.Nil(t, computeSpacingStats([]point{{1, 1}}))
+}
+
+func TestSpacingStats_ParsedFromFijiOutput(t *testing.T) {
+	output := `ANALYSIS_RESULTS_START
+purity_percentage:60
+gypsum_content:60
+impurity_content:40
+particle_count:3
+total_area:6000
+image_area:10000
+threshold_value:120
+centroid:10,10
+centroid:14,13
+centroid:40,13
+ANALYSIS_RESULTS_END
+`
+	service := newTestService(t, &config.Config{ComputeSpacing: true}, &fakeRunner{output: output})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 60))
+	require.NoError(t, service.AnalyzeGypsumImage("spacing-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("spacing-1")
+	require.NoError(t, err)
+	require.NotNil(t, result.SpacingStats)
+
+	assert.Equal(t, 3, result.SpacingStats.ParticleCount)
+	assert.InDelta(t, 5.0, result.SpacingStats.Min, 1e-9)
+	assert.InDelta(t, 26.0, result.SpacingStats.Max, 1e-9)
+	assert.InDelta(t, 5.0, result.SpacingStats.Median, 1e-9)
+	assert.Contains(t, result.Macro, `print("centroid:"`)
+}
+
+func TestSpacingStats_Disabled(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(60, 3)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 60))
+	require.NoError(t, service.AnalyzeGypsumImage("spacing-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("spacing-2")
+	require.NoError(t, err)
+	assert.Nil(t, result.SpacingStats)
+	assert.NotContains(t, result.Macro, `print("centroid:"`)
+}
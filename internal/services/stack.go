@@ -0,0 +1,52 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// parseSliceResult parses an "index,purity,count" line printed by the macro
+// for each slice of a stack
+func parseSliceResult(value string) (models.SliceResult, error) {
+	parts := strings.Split(value, ",")
+	if len(parts) != 3 {
+		return models.SliceResult{}, fmt.Errorf("malformed slice result %q", value)
+	}
+
+	index, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return models.SliceResult{}, fmt.Errorf("invalid slice index: %w", err)
+	}
+	purity, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return models.SliceResult{}, fmt.Errorf("invalid slice purity: %w", err)
+	}
+	count, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return models.SliceResult{}, fmt.Errorf("invalid slice particle count: %w", err)
+	}
+
+	return models.SliceResult{Index: index, PurityPercentage: purity, ParticleCount: count}, nil
+}
+
+// averageSlicePurity replaces the purity of a results block with the mean
+// purity of its slices. The remaining measurements describe the stack's
+// average projection and are kept.
+func averageSlicePurity(results map[string]float64, slices []models.SliceResult) {
+	if len(slices) == 0 {
+		return
+	}
+
+	var total float64
+	for _, slice := range slices {
+		total += slice.PurityPercentage
+	}
+	purity := total / float64(len(slices))
+
+	results["purity_percentage"] = purity
+	results["gypsum_content"] = purity
+	results["impurity_content"] = 100 - purity
+}
@@ -0,0 +1,55 @@
+package services
+
+import (
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSliceResult(t *testing.T) {
+	slice, err := parseSliceResult("2,71.5,18")
+	require.NoError(t, err)
+	assert.Equal(t, models.SliceResult{Index: 2, PurityPercentage: 71.5, ParticleCount: 18}, slice)
+
+	for _, value := range []string{"", "1,70", "x,70,3", "1,pure,3", "1,70,many", "1,70,3,4"} {
+		_, err := parseSliceResult(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestAnalyzeStack_AveragesSlicePurity(t *testing.T) {
+	output := withROIResults(fijiOutput(50, 30), "slice:1,80,10", "slice:2,60,12", "slice:3,70,9")
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: output})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 50))
+	require.NoError(t, service.AnalyzeGypsumImage("stack-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("stack-1")
+	require.NoError(t, err)
+	require.Equal(t, models.StatusCompleted, result.Status)
+	assert.Equal(t, []models.SliceResult{
+		{Index: 1, PurityPercentage: 80, ParticleCount: 10},
+		{Index: 2, PurityPercentage: 60, ParticleCount: 12},
+		{Index: 3, PurityPercentage: 70, ParticleCount: 9},
+	}, result.PerSlice)
+	assert.InDelta(t, 70, result.PurityPercentage, 1e-9)
+	assert.InDelta(t, 30, result.ImpurityContent, 1e-9)
+	assert.Equal(t, 30, result.ParticleCount, "the particle count describes the projection")
+	assert.Contains(t, result.Macro, "if (nSlices > 1) {")
+}
+
+func TestAnalyzeSingleImage_HasNoSlices(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(64, 12)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 64))
+	require.NoError(t, service.AnalyzeGypsumImage("stack-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("stack-2")
+	require.NoError(t, err)
+	assert.Nil(t, result.PerSlice)
+	assert.Equal(t, 64.0, result.PurityPercentage)
+}
@@ -0,0 +1,201 @@
+package services
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+)
+
+// ResultStore persists analysis results.
+//
+// Results handed out by a store are snapshots owned by the caller's readers and
+// must not be modified; changes go through Update, which applies fn to a
+// private copy and saves that copy atomically.
+type ResultStore interface {
+	// Create saves a new analysis record
+	Create(result *models.AnalysisResult) error
+
+	// Get returns the stored analysis, or ErrAnalysisNotFound
+	Get(analysisID string) (*models.AnalysisResult, error)
+
+	// Update applies fn to a copy of the stored analysis and saves the copy.
+	// If fn returns an error nothing is saved and the error is returned.
+	Update(analysisID string, fn func(result *models.AnalysisResult) error) (*models.AnalysisResult, error)
+
+	// Delete removes an analysis record, or returns ErrAnalysisNotFound
+	Delete(analysisID string) error
+
+	// List returns one page of the analyses matching filter, in the filter's
+	// sort order, together with the total number of matches
+	List(filter ListFilter) ([]*models.AnalysisResult, int, error)
+
+	// SummarizePurity computes the purity statistics of the analyses matching
+	// filter without loading them, and returns them with the number of
+	// matches. Only BatchID, From and To are filled from the filter by the
+	// caller; Sort, Limit and Offset are ignored.
+	SummarizePurity(filter ListFilter) (*models.PuritySummary, int, error)
+
+	// ListByDateRange returns the analyses created between from and to
+	// inclusive, oldest first. A zero bound leaves that side of the range open.
+	ListByDateRange(from, to time.Time) ([]*models.AnalysisResult, error)
+
+	HashIndex
+	WebhookStore
+	ProfileStore
+
+	// Close releases the store's resources
+	Close() error
+}
+
+// HashIndex looks up analyses by the perceptual hash of their image
+type HashIndex interface {
+	// FindSimilar returns the completed analyses whose image hash is within
+	// maxDistance bits of hash, nearest first and then oldest first. Analyses
+	// that reused another analysis' results are not returned.
+	FindSimilar(hash uint64, maxDistance int) ([]*models.AnalysisResult, error)
+}
+
+// WebhookStore keeps webhook registrations and their recent delivery attempts
+type WebhookStore interface {
+	// CreateWebhook saves a new webhook registration
+	CreateWebhook(webhook *models.Webhook) error
+
+	// GetWebhook returns the registered webhook, or ErrWebhookNotFound
+	GetWebhook(webhookID string) (*models.Webhook, error)
+
+	// ListWebhooks returns the webhooks registered by owner, oldest first
+	ListWebhooks(owner string) ([]*models.Webhook, error)
+
+	// DeleteWebhook removes a webhook and its delivery attempts, or returns
+	// ErrWebhookNotFound
+	DeleteWebhook(webhookID string) error
+
+	// RecordDelivery saves a delivery attempt. Only the most recent
+	// maxStoredDeliveries attempts per webhook are kept.
+	RecordDelivery(delivery *models.WebhookDelivery) error
+
+	// ListDeliveries returns up to limit of the most recent delivery attempts
+	// to a webhook, newest first
+	ListDeliveries(webhookID string, limit int) ([]*models.WebhookDelivery, error)
+}
+
+// ProfileStore keeps analysis profiles. Every store provides the built-in
+// profiles, which cannot be deleted.
+type ProfileStore interface {
+	// CreateProfile saves a new profile, or returns ErrProfileExists
+	CreateProfile(profile *models.Profile) error
+
+	// GetProfile returns the stored profile, or ErrProfileNotFound
+	GetProfile(profileID string) (*models.Profile, error)
+
+	// ListProfiles returns all profiles, built-in ones first and then by ID
+	ListProfiles() ([]*models.Profile, error)
+
+	// UpdateProfile replaces a stored profile, or returns ErrProfileNotFound
+	UpdateProfile(profile *models.Profile) error
+
+	// DeleteProfile removes a profile. It returns ErrProfileNotFound for
+	// unknown profiles and ErrBuiltinProfile for built-in ones.
+	DeleteProfile(profileID string) error
+}
+
+// formatPerceptualHash encodes a hash as stored in AnalysisResult.PerceptualHash
+func formatPerceptualHash(hash uint64) string {
+	return fmt.Sprintf("%016x", hash)
+}
+
+// parsePerceptualHash decodes AnalysisResult.PerceptualHash
+func parsePerceptualHash(value string) (uint64, bool) {
+	if value == "" {
+		return 0, false
+	}
+	hash, err := strconv.ParseUint(value, 16, 64)
+	return hash, err == nil
+}
+
+// Sort orders accepted by ListFilter. A leading "-" sorts newest first.
+const (
+	SortCreatedAt       = "created_at"
+	SortCreatedAtDesc   = "-created_at"
+	SortCompletedAt     = "completed_at"
+	SortCompletedAtDesc = "-completed_at"
+)
+
+// ListFilter selects and pages analyses. Empty fields match everything, a
+// zero Limit returns all matches and an empty Sort orders by creation time.
+// Ties are broken by ID so pages are stable across calls.
+type ListFilter struct {
+	Status      models.AnalysisStatus
+	SampleGroup string
+	BatchID     string
+	// SampleID matches the sample_id of the sample metadata
+	SampleID string
+	// Purity bounds are inclusive and only match completed analyses
+	MinPurity *float64
+	MaxPurity *float64
+	// Creation time bounds are inclusive; zero bounds are open
+	From   time.Time
+	To     time.Time
+	Sort   string
+	Limit  int
+	Offset int
+}
+
+// matches reports whether a result satisfies the filter
+func (f ListFilter) matches(result *models.AnalysisResult) bool {
+	return (f.Status == "" || result.Status == f.Status) &&
+		(f.SampleGroup == "" || result.SampleGroup == f.SampleGroup) &&
+		(f.BatchID == "" || result.BatchID == f.BatchID) &&
+		(f.SampleID == "" || (result.SampleMetadata != nil && result.SampleMetadata.SampleID == f.SampleID)) &&
+		f.matchesPurity(result) &&
+		inDateRange(result.CreatedAt, f.From, f.To)
+}
+
+// matchesPurity reports whether a result lies within the purity bounds
+func (f ListFilter) matchesPurity(result *models.AnalysisResult) bool {
+	if f.MinPurity == nil && f.MaxPurity == nil {
+		return true
+	}
+	return result.Status == models.StatusCompleted &&
+		(f.MinPurity == nil || result.PurityPercentage >= *f.MinPurity) &&
+		(f.MaxPurity == nil || result.PurityPercentage <= *f.MaxPurity)
+}
+
+// inDateRange reports whether t lies between from and to inclusive, treating
+// zero bounds as open
+func inDateRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+}
+
+// validSort reports whether sort is one of the supported sort orders
+func validSort(sort string) bool {
+	switch sort {
+	case "", SortCreatedAt, SortCreatedAtDesc, SortCompletedAt, SortCompletedAtDesc:
+		return true
+	}
+	return false
+}
+
+// page returns the slice of results selected by the filter's offset and limit
+func (f ListFilter) page(results []*models.AnalysisResult) []*models.AnalysisResult {
+	if f.Offset >= len(results) {
+		return results[:0]
+	}
+	results = results[f.Offset:]
+	if f.Limit > 0 && f.Limit < len(results) {
+		results = results[:f.Limit]
+	}
+	return results
+}
+
+// NewResultStore opens the PostgreSQL store when DATABASE_URL is configured and
+// falls back to an in-memory store otherwise
+func NewResultStore(cfg *config.Config) (ResultStore, error) {
+	if cfg.DatabaseURL == "" {
+		return NewMemoryStore(), nil
+	}
+	return NewPostgresStore(cfg.DatabaseURL, cfg.DatabaseMaxConns)
+}
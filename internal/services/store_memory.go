@@ -0,0 +1,353 @@
+package services
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gypsum-analysis-api/internal/imaging/phash"
+	"gypsum-analysis-api/internal/models"
+)
+
+// MemoryStore keeps results in process memory. Nothing survives a restart, so
+// it is meant for development and tests.
+type MemoryStore struct {
+	results    map[string]*models.AnalysisResult
+	webhooks   map[string]*models.Webhook
+	deliveries map[string][]*models.WebhookDelivery
+	profiles   map[string]*models.Profile
+	mutex      sync.RWMutex
+}
+
+// NewMemoryStore creates an empty in-memory result store
+func NewMemoryStore() *MemoryStore {
+	m := &MemoryStore{
+		results:    make(map[string]*models.AnalysisResult),
+		webhooks:   make(map[string]*models.Webhook),
+		deliveries: make(map[string][]*models.WebhookDelivery),
+		profiles:   make(map[string]*models.Profile),
+	}
+	now := time.Now()
+	for _, profile := range BuiltinProfiles() {
+		profile.CreatedAt, profile.UpdatedAt = now, now
+		m.profiles[profile.ID] = profile
+	}
+	return m
+}
+
+// Create saves a new analysis record, replacing any with the same ID
+func (m *MemoryStore) Create(result *models.AnalysisResult) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.results[result.ID] = result
+	return nil
+}
+
+// Get returns the stored snapshot of an analysis
+func (m *MemoryStore) Get(analysisID string) (*models.AnalysisResult, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	result, exists := m.results[analysisID]
+	if !exists {
+		return nil, ErrAnalysisNotFound
+	}
+	return result, nil
+}
+
+// Update copies the record, applies fn and swaps the copy in. fn runs with the
+// write lock held, so it must only assign fields: slices and maps shared with
+// the previous snapshot must be replaced, not modified.
+func (m *MemoryStore) Update(analysisID string, fn func(result *models.AnalysisResult) error) (*models.AnalysisResult, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	current, exists := m.results[analysisID]
+	if !exists {
+		return nil, ErrAnalysisNotFound
+	}
+
+	next := *current
+	if err := fn(&next); err != nil {
+		return nil, err
+	}
+	m.results[analysisID] = &next
+
+	return &next, nil
+}
+
+// Delete removes an analysis record
+func (m *MemoryStore) Delete(analysisID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.results[analysisID]; !exists {
+		return ErrAnalysisNotFound
+	}
+	delete(m.results, analysisID)
+	return nil
+}
+
+// List returns a page of matching snapshots in the filter's sort order
+func (m *MemoryStore) List(filter ListFilter) ([]*models.AnalysisResult, int, error) {
+	if !validSort(filter.Sort) {
+		return nil, 0, fmt.Errorf("unsupported sort order %q", filter.Sort)
+	}
+
+	m.mutex.RLock()
+	results := make([]*models.AnalysisResult, 0)
+	for _, result := range m.results {
+		if filter.matches(result) {
+			results = append(results, result)
+		}
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return lessResult(results[i], results[j], filter.Sort)
+	})
+
+	return filter.page(results), len(results), nil
+}
+
+// SummarizePurity computes the purity statistics of the matching snapshots
+func (m *MemoryStore) SummarizePurity(filter ListFilter) (*models.PuritySummary, int, error) {
+	m.mutex.RLock()
+	results := make([]*models.AnalysisResult, 0)
+	for _, result := range m.results {
+		if filter.matches(result) {
+			results = append(results, result)
+		}
+	}
+	m.mutex.RUnlock()
+
+	return summarizeResults(results), len(results), nil
+}
+
+// ListByDateRange returns the snapshots created within the range, oldest first
+func (m *MemoryStore) ListByDateRange(from, to time.Time) ([]*models.AnalysisResult, error) {
+	m.mutex.RLock()
+	results := make([]*models.AnalysisResult, 0)
+	for _, result := range m.results {
+		if inDateRange(result.CreatedAt, from, to) {
+			results = append(results, result)
+		}
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		return lessResult(results[i], results[j], SortCreatedAt)
+	})
+
+	return results, nil
+}
+
+// FindSimilar returns the completed snapshots with a similar image hash
+func (m *MemoryStore) FindSimilar(hash uint64, maxDistance int) ([]*models.AnalysisResult, error) {
+	m.mutex.RLock()
+	results := make([]*models.AnalysisResult, 0)
+	distances := make(map[string]int)
+	for _, result := range m.results {
+		stored, ok := parsePerceptualHash(result.PerceptualHash)
+		if !ok || result.Status != models.StatusCompleted || result.DuplicateOf != "" {
+			continue
+		}
+		if distance := phash.Distance(hash, stored); distance <= maxDistance {
+			results = append(results, result)
+			distances[result.ID] = distance
+		}
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(results, func(i, j int) bool {
+		if di, dj := distances[results[i].ID], distances[results[j].ID]; di != dj {
+			return di < dj
+		}
+		return lessResult(results[i], results[j], SortCreatedAt)
+	})
+
+	return results, nil
+}
+
+// CreateWebhook saves a new webhook registration
+func (m *MemoryStore) CreateWebhook(webhook *models.Webhook) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.webhooks[webhook.ID] = webhook
+	return nil
+}
+
+// GetWebhook returns a registered webhook
+func (m *MemoryStore) GetWebhook(webhookID string) (*models.Webhook, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	webhook, exists := m.webhooks[webhookID]
+	if !exists {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// ListWebhooks returns the webhooks registered by owner, oldest first
+func (m *MemoryStore) ListWebhooks(owner string) ([]*models.Webhook, error) {
+	m.mutex.RLock()
+	webhooks := make([]*models.Webhook, 0)
+	for _, webhook := range m.webhooks {
+		if webhook.Owner == owner {
+			webhooks = append(webhooks, webhook)
+		}
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		if !webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+		}
+		return webhooks[i].ID < webhooks[j].ID
+	})
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook and its delivery attempts
+func (m *MemoryStore) DeleteWebhook(webhookID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.webhooks[webhookID]; !exists {
+		return ErrWebhookNotFound
+	}
+	delete(m.webhooks, webhookID)
+	delete(m.deliveries, webhookID)
+	return nil
+}
+
+// RecordDelivery appends a delivery attempt, dropping the oldest beyond
+// maxStoredDeliveries
+func (m *MemoryStore) RecordDelivery(delivery *models.WebhookDelivery) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	deliveries := append(m.deliveries[delivery.WebhookID], delivery)
+	if len(deliveries) > maxStoredDeliveries {
+		deliveries = deliveries[len(deliveries)-maxStoredDeliveries:]
+	}
+	m.deliveries[delivery.WebhookID] = deliveries
+	return nil
+}
+
+// ListDeliveries returns the most recent delivery attempts, newest first
+func (m *MemoryStore) ListDeliveries(webhookID string, limit int) ([]*models.WebhookDelivery, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	stored := m.deliveries[webhookID]
+	deliveries := make([]*models.WebhookDelivery, 0, min(len(stored), limit))
+	for i := len(stored) - 1; i >= 0 && len(deliveries) < limit; i-- {
+		deliveries = append(deliveries, stored[i])
+	}
+	return deliveries, nil
+}
+
+// CreateProfile saves a new profile unless its ID is taken
+func (m *MemoryStore) CreateProfile(profile *models.Profile) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.profiles[profile.ID]; exists {
+		return ErrProfileExists
+	}
+	m.profiles[profile.ID] = profile
+	return nil
+}
+
+// GetProfile returns a stored profile
+func (m *MemoryStore) GetProfile(profileID string) (*models.Profile, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	profile, exists := m.profiles[profileID]
+	if !exists {
+		return nil, ErrProfileNotFound
+	}
+	return profile, nil
+}
+
+// ListProfiles returns all profiles, built-in ones first and then by ID
+func (m *MemoryStore) ListProfiles() ([]*models.Profile, error) {
+	m.mutex.RLock()
+	profiles := make([]*models.Profile, 0, len(m.profiles))
+	for _, profile := range m.profiles {
+		profiles = append(profiles, profile)
+	}
+	m.mutex.RUnlock()
+
+	sort.Slice(profiles, func(i, j int) bool {
+		if profiles[i].Builtin != profiles[j].Builtin {
+			return profiles[i].Builtin
+		}
+		return profiles[i].ID < profiles[j].ID
+	})
+	return profiles, nil
+}
+
+// UpdateProfile replaces a stored profile
+func (m *MemoryStore) UpdateProfile(profile *models.Profile) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, exists := m.profiles[profile.ID]; !exists {
+		return ErrProfileNotFound
+	}
+	m.profiles[profile.ID] = profile
+	return nil
+}
+
+// DeleteProfile removes a profile that is not built in
+func (m *MemoryStore) DeleteProfile(profileID string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	profile, exists := m.profiles[profileID]
+	switch {
+	case !exists:
+		return ErrProfileNotFound
+	case profile.Builtin:
+		return ErrBuiltinProfile
+	}
+	delete(m.profiles, profileID)
+	return nil
+}
+
+// lessResult orders two results by the given sort order, then by ID. Results
+// without a completion time sort last when ordering by completion.
+func lessResult(a, b *models.AnalysisResult, order string) bool {
+	var at, bt time.Time
+	descending := strings.HasPrefix(order, "-")
+
+	switch strings.TrimPrefix(order, "-") {
+	case SortCompletedAt:
+		if (a.CompletedAt == nil) != (b.CompletedAt == nil) {
+			return b.CompletedAt == nil
+		}
+		if a.CompletedAt != nil {
+			at, bt = *a.CompletedAt, *b.CompletedAt
+		}
+	default:
+		at, bt = a.CreatedAt, b.CreatedAt
+	}
+
+	if !at.Equal(bt) {
+		return at.Before(bt) != descending
+	}
+	return a.ID < b.ID
+}
+
+// Close is a no-op for the in-memory store
+func (m *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,721 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strings"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+
+	_ "github.com/jackc/pgx/v5/stdlib" // register the pgx database/sql driver
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID serializes migrations when several instances start at once
+const migrationLockID = 7310412
+
+// storeTimeout bounds every database round trip made by the PostgreSQL store
+const storeTimeout = 10 * time.Second
+
+// PostgresStore keeps results in the analysis_results table. Each result is
+// stored as a JSONB document next to the columns used for filtering.
+type PostgresStore struct {
+	db *sql.DB
+}
+
+// NewPostgresStore connects to the database, verifies the connection and
+// applies any pending migrations
+func NewPostgresStore(databaseURL string, maxConns int) (*PostgresStore, error) {
+	db, err := sql.Open("pgx", databaseURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open database: %w", err)
+	}
+	if maxConns > 0 {
+		db.SetMaxOpenConns(maxConns)
+		db.SetMaxIdleConns(maxConns)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to connect to database: %w", err)
+	}
+
+	if err := migrate(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	if err := syncBuiltinProfiles(ctx, db); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &PostgresStore{db: db}, nil
+}
+
+// syncBuiltinProfiles writes the built-in profiles, so their settings follow
+// the running version
+func syncBuiltinProfiles(ctx context.Context, db *sql.DB) error {
+	now := time.Now()
+	for _, profile := range BuiltinProfiles() {
+		params, err := json.Marshal(profile.MacroParams)
+		if err != nil {
+			return fmt.Errorf("failed to encode profile %s: %w", profile.ID, err)
+		}
+		_, err = db.ExecContext(ctx, `INSERT INTO profiles (id, name, description, builtin, params, created_at, updated_at)
+			VALUES ($1, $2, $3, TRUE, $4, $5, $5)
+			ON CONFLICT (id) DO UPDATE SET name = $2, description = $3, builtin = TRUE, params = $4, updated_at = $5`,
+			profile.ID, profile.Name, profile.Description, params, now)
+		if err != nil {
+			return fmt.Errorf("failed to store built-in profile %s: %w", profile.ID, err)
+		}
+	}
+	return nil
+}
+
+// migrate applies the embedded migrations that have not run yet, in file name order
+func migrate(ctx context.Context, db *sql.DB) error {
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to acquire migration connection: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to lock migrations: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	if _, err := conn.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    TEXT PRIMARY KEY,
+		applied_at TIMESTAMPTZ NOT NULL DEFAULT now()
+	)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	for _, name := range names {
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+
+		var applied bool
+		if err := conn.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&applied); err != nil {
+			return fmt.Errorf("failed to check migration %s: %w", version, err)
+		}
+		if applied {
+			continue
+		}
+
+		script, err := migrationFiles.ReadFile(name)
+		if err != nil {
+			return fmt.Errorf("failed to read migration %s: %w", version, err)
+		}
+
+		tx, err := conn.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to start migration %s: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to apply migration %s: %w", version, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %s: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to commit migration %s: %w", version, err)
+		}
+	}
+
+	return nil
+}
+
+// Create inserts a new analysis record
+func (p *PostgresStore) Create(result *models.AnalysisResult) error {
+	document, err := json.Marshal(result)
+	if err != nil {
+		return fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, `INSERT INTO analysis_results (id, status, sample_group, batch_id, created_at, completed_at, macro, owner, image_dhash, purity_percentage, result)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+		result.ID, result.Status, result.SampleGroup, result.BatchID, result.CreatedAt, result.CompletedAt, result.Macro, result.Owner, dhashColumn(result), purityColumn(result), document)
+	if err != nil {
+		return fmt.Errorf("failed to insert result: %w", err)
+	}
+
+	return nil
+}
+
+// Get loads an analysis record
+func (p *PostgresStore) Get(analysisID string) (*models.AnalysisResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	return scanResult(p.db.QueryRowContext(ctx, "SELECT result, macro, owner FROM analysis_results WHERE id = $1", analysisID))
+}
+
+// Update locks the row, applies fn to the decoded record and writes it back
+// in the same transaction
+func (p *PostgresStore) Update(analysisID string, fn func(result *models.AnalysisResult) error) (*models.AnalysisResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := scanResult(tx.QueryRowContext(ctx, "SELECT result, macro, owner FROM analysis_results WHERE id = $1 FOR UPDATE", analysisID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := fn(result); err != nil {
+		return nil, err
+	}
+
+	document, err := json.Marshal(result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode result: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE analysis_results
+		SET status = $2, sample_group = $3, completed_at = $4, macro = $5, image_dhash = $6, purity_percentage = $7, result = $8
+		WHERE id = $1`,
+		analysisID, result.Status, result.SampleGroup, result.CompletedAt, result.Macro, dhashColumn(result), purityColumn(result), document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update result: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, fmt.Errorf("failed to commit result: %w", err)
+	}
+
+	return result, nil
+}
+
+// Delete removes an analysis record
+func (p *PostgresStore) Delete(analysisID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, "DELETE FROM analysis_results WHERE id = $1", analysisID)
+	if err != nil {
+		return fmt.Errorf("failed to delete result: %w", err)
+	}
+	deleted, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to delete result: %w", err)
+	}
+	if deleted == 0 {
+		return ErrAnalysisNotFound
+	}
+	return nil
+}
+
+// listOrders maps ListFilter sort orders to ORDER BY clauses
+var listOrders = map[string]string{
+	"":                  "created_at, id",
+	SortCreatedAt:       "created_at, id",
+	SortCreatedAtDesc:   "created_at DESC, id",
+	SortCompletedAt:     "completed_at NULLS LAST, id",
+	SortCompletedAtDesc: "completed_at DESC NULLS LAST, id",
+}
+
+// listWhere returns the WHERE clause selecting the records that match filter,
+// with its arguments as $1 to $8
+func listWhere(filter ListFilter) (string, []any) {
+	// NULL bounds are open
+	var from, to *time.Time
+	if !filter.From.IsZero() {
+		from = &filter.From
+	}
+	if !filter.To.IsZero() {
+		to = &filter.To
+	}
+	const where = `WHERE ($1 = '' OR status = $1) AND ($2 = '' OR sample_group = $2) AND ($3 = '' OR batch_id = $3)
+		AND ($4::float8 IS NULL OR purity_percentage >= $4) AND ($5::float8 IS NULL OR purity_percentage <= $5)
+		AND ($6::timestamptz IS NULL OR created_at >= $6) AND ($7::timestamptz IS NULL OR created_at <= $7)
+		AND ($8 = '' OR result->'sample_metadata'->>'sample_id' = $8)`
+	return where, []any{string(filter.Status), filter.SampleGroup, filter.BatchID, filter.MinPurity, filter.MaxPurity, from, to, filter.SampleID}
+}
+
+// List returns a page of matching records in the filter's sort order
+func (p *PostgresStore) List(filter ListFilter) ([]*models.AnalysisResult, int, error) {
+	order, ok := listOrders[filter.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported sort order %q", filter.Sort)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	where, args := listWhere(filter)
+
+	var total int
+	if err := p.db.QueryRowContext(ctx, "SELECT count(*) FROM analysis_results "+where, args...).Scan(&total); err != nil {
+		return nil, 0, fmt.Errorf("failed to count results: %w", err)
+	}
+
+	// A NULL limit returns every row
+	var limit *int
+	if filter.Limit > 0 {
+		limit = &filter.Limit
+	}
+	rows, err := p.db.QueryContext(ctx, "SELECT result, macro, owner FROM analysis_results "+where+
+		" ORDER BY "+order+" LIMIT $9 OFFSET $10", append(args, limit, filter.Offset)...)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list results: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*models.AnalysisResult, 0)
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			return nil, 0, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, fmt.Errorf("failed to list results: %w", err)
+	}
+
+	return results, total, nil
+}
+
+// SummarizePurity aggregates the matching records in the database.
+// percentile_cont interpolates linearly like percentile.
+func (p *PostgresStore) SummarizePurity(filter ListFilter) (*models.PuritySummary, int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	where, args := listWhere(filter)
+	const completed = `FILTER (WHERE status = 'completed')`
+	var total int
+	summary := &models.PuritySummary{}
+	err := p.db.QueryRowContext(ctx, `SELECT count(*),
+		count(*) FILTER (WHERE status IN ('pending', 'processing')),
+		count(*) FILTER (WHERE status = 'failed'),
+		count(*) `+completed+`,
+		COALESCE(avg(purity_percentage) `+completed+`, 0),
+		COALESCE(stddev_samp(purity_percentage) `+completed+`, 0),
+		COALESCE(min(purity_percentage) `+completed+`, 0),
+		COALESCE(max(purity_percentage) `+completed+`, 0),
+		COALESCE(percentile_cont(0.5) WITHIN GROUP (ORDER BY purity_percentage) `+completed+`, 0),
+		COALESCE(percentile_cont(0.05) WITHIN GROUP (ORDER BY purity_percentage) `+completed+`, 0),
+		COALESCE(percentile_cont(0.95) WITHIN GROUP (ORDER BY purity_percentage) `+completed+`, 0),
+		COALESCE(avg(COALESCE((result->>'confidence')::float8, 0)) `+completed+`, 0)
+		FROM analysis_results `+where, args...).Scan(&total, &summary.ProcessingCount, &summary.FailedCount, &summary.Count,
+		&summary.MeanPurity, &summary.StdDevPurity, &summary.MinPurity, &summary.MaxPurity,
+		&summary.MedianPurity, &summary.P5Purity, &summary.P95Purity, &summary.MeanConfidence)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to summarize results: %w", err)
+	}
+	return summary, total, nil
+}
+
+// ListByDateRange returns the records created within the range, oldest first
+func (p *PostgresStore) ListByDateRange(from, to time.Time) ([]*models.AnalysisResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	// A NULL bound leaves that side of the range open
+	var lower, upper *time.Time
+	if !from.IsZero() {
+		lower = &from
+	}
+	if !to.IsZero() {
+		upper = &to
+	}
+	rows, err := p.db.QueryContext(ctx, `SELECT result, macro, owner FROM analysis_results
+		WHERE ($1::timestamptz IS NULL OR created_at >= $1) AND ($2::timestamptz IS NULL OR created_at <= $2)
+		ORDER BY created_at, id`, lower, upper)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list results: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*models.AnalysisResult, 0)
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list results: %w", err)
+	}
+
+	return results, nil
+}
+
+// FindSimilar returns the completed records with a similar image hash. The
+// Hamming distance is counted on the XOR of the hashes cast to a bit string.
+func (p *PostgresStore) FindSimilar(hash uint64, maxDistance int) ([]*models.AnalysisResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, `SELECT result, macro, owner FROM (
+			SELECT result, macro, owner, created_at, id,
+				length(replace(((image_dhash # $1)::bit(64))::text, '0', '')) AS distance
+			FROM analysis_results
+			WHERE status = $2 AND image_dhash IS NOT NULL AND result->>'duplicate_of' IS NULL
+		) candidates
+		WHERE distance <= $3
+		ORDER BY distance, created_at, id`, int64(hash), models.StatusCompleted, maxDistance)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find similar results: %w", err)
+	}
+	defer rows.Close()
+
+	results := make([]*models.AnalysisResult, 0)
+	for rows.Next() {
+		result, err := scanResult(rows)
+		if err != nil {
+			return nil, err
+		}
+		results = append(results, result)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to find similar results: %w", err)
+	}
+
+	return results, nil
+}
+
+// CreateWebhook inserts a webhook registration
+func (p *PostgresStore) CreateWebhook(webhook *models.Webhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook events: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	_, err = p.db.ExecContext(ctx, "INSERT INTO webhooks (id, url, secret, owner, events, created_at) VALUES ($1, $2, $3, $4, $5, $6)",
+		webhook.ID, webhook.URL, webhook.Secret, webhook.Owner, events, webhook.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook: %w", err)
+	}
+	return nil
+}
+
+// GetWebhook loads a webhook registration
+func (p *PostgresStore) GetWebhook(webhookID string) (*models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	return scanWebhook(p.db.QueryRowContext(ctx, "SELECT id, url, secret, owner, events, created_at FROM webhooks WHERE id = $1", webhookID))
+}
+
+// ListWebhooks returns the webhooks registered by owner, oldest first
+func (p *PostgresStore) ListWebhooks(owner string) ([]*models.Webhook, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, "SELECT id, url, secret, owner, events, created_at FROM webhooks WHERE owner = $1 ORDER BY created_at, id", owner)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+	defer rows.Close()
+
+	webhooks := make([]*models.Webhook, 0)
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list webhooks: %w", err)
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook; its delivery attempts are deleted with it
+func (p *PostgresStore) DeleteWebhook(webhookID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, "DELETE FROM webhooks WHERE id = $1", webhookID)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrWebhookNotFound
+	}
+	return nil
+}
+
+// RecordDelivery inserts a delivery attempt and prunes the webhook's attempts
+// beyond maxStoredDeliveries in the same transaction
+func (p *PostgresStore) RecordDelivery(delivery *models.WebhookDelivery) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	tx, err := p.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT INTO webhook_deliveries (id, webhook_id, analysis_id, event, attempt, status_code, error, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)`,
+		delivery.ID, delivery.WebhookID, delivery.AnalysisID, delivery.Event, delivery.Attempt, delivery.StatusCode, delivery.Error, delivery.Timestamp)
+	if err != nil {
+		return fmt.Errorf("failed to insert webhook delivery: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM webhook_deliveries WHERE webhook_id = $1 AND id NOT IN (
+			SELECT id FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2
+		)`, delivery.WebhookID, maxStoredDeliveries)
+	if err != nil {
+		return fmt.Errorf("failed to prune webhook deliveries: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit webhook delivery: %w", err)
+	}
+	return nil
+}
+
+// ListDeliveries returns the most recent delivery attempts, newest first
+func (p *PostgresStore) ListDeliveries(webhookID string, limit int) ([]*models.WebhookDelivery, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, `SELECT id, webhook_id, analysis_id, event, attempt, status_code, error, created_at
+		FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`, webhookID, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := make([]*models.WebhookDelivery, 0)
+	for rows.Next() {
+		var delivery models.WebhookDelivery
+		if err := rows.Scan(&delivery.ID, &delivery.WebhookID, &delivery.AnalysisID, &delivery.Event,
+			&delivery.Attempt, &delivery.StatusCode, &delivery.Error, &delivery.Timestamp); err != nil {
+			return nil, fmt.Errorf("failed to load webhook delivery: %w", err)
+		}
+		deliveries = append(deliveries, &delivery)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// profileColumns are the columns scanned by scanProfile
+const profileColumns = "id, name, description, builtin, params, created_at, updated_at"
+
+// CreateProfile inserts a profile unless its ID is taken
+func (p *PostgresStore) CreateProfile(profile *models.Profile) error {
+	params, err := json.Marshal(profile.MacroParams)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile settings: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, `INSERT INTO profiles (id, name, description, builtin, params, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING`,
+		profile.ID, profile.Name, profile.Description, profile.Builtin, params, profile.CreatedAt, profile.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to insert profile: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrProfileExists
+	}
+	return nil
+}
+
+// GetProfile loads a profile
+func (p *PostgresStore) GetProfile(profileID string) (*models.Profile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	return scanProfile(p.db.QueryRowContext(ctx, "SELECT "+profileColumns+" FROM profiles WHERE id = $1", profileID))
+}
+
+// ListProfiles returns all profiles, built-in ones first and then by ID
+func (p *PostgresStore) ListProfiles() ([]*models.Profile, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, "SELECT "+profileColumns+" FROM profiles ORDER BY builtin DESC, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+	defer rows.Close()
+
+	profiles := make([]*models.Profile, 0)
+	for rows.Next() {
+		profile, err := scanProfile(rows)
+		if err != nil {
+			return nil, err
+		}
+		profiles = append(profiles, profile)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list profiles: %w", err)
+	}
+
+	return profiles, nil
+}
+
+// UpdateProfile replaces the name, description and settings of a profile
+func (p *PostgresStore) UpdateProfile(profile *models.Profile) error {
+	params, err := json.Marshal(profile.MacroParams)
+	if err != nil {
+		return fmt.Errorf("failed to encode profile settings: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, "UPDATE profiles SET name = $2, description = $3, params = $4, updated_at = $5 WHERE id = $1",
+		profile.ID, profile.Name, profile.Description, params, profile.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update profile: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return ErrProfileNotFound
+	}
+	return nil
+}
+
+// DeleteProfile removes a profile that is not built in
+func (p *PostgresStore) DeleteProfile(profileID string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), storeTimeout)
+	defer cancel()
+
+	res, err := p.db.ExecContext(ctx, "DELETE FROM profiles WHERE id = $1 AND NOT builtin", profileID)
+	if err != nil {
+		return fmt.Errorf("failed to delete profile: %w", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		if _, err := p.GetProfile(profileID); err != nil {
+			return err
+		}
+		return ErrBuiltinProfile
+	}
+	return nil
+}
+
+// Ping verifies that the database is reachable
+func (p *PostgresStore) Ping(ctx context.Context) error {
+	if err := p.db.PingContext(ctx); err != nil {
+		return fmt.Errorf("database is unreachable: %w", err)
+	}
+	return nil
+}
+
+// Close closes the database connection pool
+func (p *PostgresStore) Close() error {
+	return p.db.Close()
+}
+
+// scanResult decodes a (result, macro, owner) row. The macro and owner are not
+// part of the JSON representation, so they are kept in their own columns.
+func scanResult(row interface{ Scan(dest ...any) error }) (*models.AnalysisResult, error) {
+	var document []byte
+	var macro, owner string
+	if err := row.Scan(&document, &macro, &owner); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAnalysisNotFound
+		}
+		return nil, fmt.Errorf("failed to load result: %w", err)
+	}
+
+	var result models.AnalysisResult
+	if err := json.Unmarshal(document, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode result: %w", err)
+	}
+	result.Macro = macro
+	result.Owner = owner
+
+	return &result, nil
+}
+
+// scanWebhook decodes an (id, url, secret, owner, events, created_at) row
+func scanWebhook(row interface{ Scan(dest ...any) error }) (*models.Webhook, error) {
+	var webhook models.Webhook
+	var events []byte
+	if err := row.Scan(&webhook.ID, &webhook.URL, &webhook.Secret, &webhook.Owner, &events, &webhook.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrWebhookNotFound
+		}
+		return nil, fmt.Errorf("failed to load webhook: %w", err)
+	}
+	if err := json.Unmarshal(events, &webhook.Events); err != nil {
+		return nil, fmt.Errorf("failed to decode webhook events: %w", err)
+	}
+	return &webhook, nil
+}
+
+// scanProfile reads a row of profileColumns
+func scanProfile(row interface{ Scan(dest ...any) error }) (*models.Profile, error) {
+	var profile models.Profile
+	var params []byte
+	if err := row.Scan(&profile.ID, &profile.Name, &profile.Description, &profile.Builtin, &params, &profile.CreatedAt, &profile.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrProfileNotFound
+		}
+		return nil, fmt.Errorf("failed to load profile: %w", err)
+	}
+	if err := json.Unmarshal(params, &profile.MacroParams); err != nil {
+		return nil, fmt.Errorf("failed to decode profile settings: %w", err)
+	}
+	return &profile, nil
+}
+
+// dhashColumn is the perceptual hash as stored in the image_dhash column: the
+// hash bits reinterpreted as a signed BIGINT, or NULL when there is none
+func dhashColumn(result *models.AnalysisResult) *int64 {
+	hash, ok := parsePerceptualHash(result.PerceptualHash)
+	if !ok {
+		return nil
+	}
+	value := int64(hash)
+	return &value
+}
+
+// purityColumn returns the value of the purity_percentage column, which is
+// NULL until the analysis has completed
+func purityColumn(result *models.AnalysisResult) *float64 {
+	if result.Status != models.StatusCompleted {
+		return nil
+	}
+	purity := result.PurityPercentage
+	return &purity
+}
@@ -0,0 +1,308 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testResultStore exercises the ResultStore contract. newID keeps IDs unique
+// for stores that outlive the test, such as a shared database.
+func testResultStore(t *testing.T, store ResultStore, newID func(string) string) {
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	first, second := newID("first"), newID("second")
+	group := newID("group")
+
+	require.NoError(t, store.Create(&models.AnalysisResult{ID: first, Status: models.StatusProcessing, CreatedAt: created.Add(time.Minute), SampleGroup: group, Owner: "sub:lab-1"}))
+	require.NoError(t, store.Create(&models.AnalysisResult{ID: second, Status: models.StatusProcessing, CreatedAt: created, SampleGroup: group, BatchID: group,
+		SampleMetadata: &models.SampleMetadata{SampleID: group, OperatorName: "A. Moreau", LocationLat: 48.85, SamplingDepthM: 12.5}}))
+
+	_, err := store.Get(newID("missing"))
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+	_, err = store.Update(newID("missing"), func(*models.AnalysisResult) error { return nil })
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+
+	before, err := store.Get(first)
+	require.NoError(t, err)
+
+	completed := created.Add(time.Hour)
+	updated, err := store.Update(first, func(result *models.AnalysisResult) error {
+		result.Status = models.StatusCompleted
+		result.CompletedAt = &completed
+		result.PurityPercentage = 87.5
+		result.Macro = "run(\"Convert to Mask\");"
+		result.Warnings = []string{"low contrast"}
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusCompleted, updated.Status)
+	assert.Equal(t, models.StatusProcessing, before.Status, "earlier snapshots are not modified")
+
+	stored, err := store.Get(first)
+	require.NoError(t, err)
+	assert.Equal(t, 87.5, stored.PurityPercentage)
+	assert.Equal(t, "run(\"Convert to Mask\");", stored.Macro)
+	assert.Equal(t, "sub:lab-1", stored.Owner)
+	assert.Equal(t, []string{"low contrast"}, stored.Warnings)
+	assert.True(t, completed.Equal(*stored.CompletedAt))
+
+	// A failing update leaves the record untouched
+	_, err = store.Update(first, func(result *models.AnalysisResult) error {
+		result.PurityPercentage = 1
+		return errors.New("rejected")
+	})
+	assert.EqualError(t, err, "rejected")
+	stored, err = store.Get(first)
+	require.NoError(t, err)
+	assert.Equal(t, 87.5, stored.PurityPercentage)
+
+	all, total, err := store.List(ListFilter{SampleGroup: group})
+	require.NoError(t, err)
+	require.Len(t, all, 2)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, second, all[0].ID, "results are ordered by creation time")
+	assert.Equal(t, first, all[1].ID)
+
+	done, total, err := store.List(ListFilter{Status: models.StatusCompleted, SampleGroup: group})
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, first, done[0].ID)
+
+	// Pages report the total number of matches
+	page, total, err := store.List(ListFilter{SampleGroup: group, Sort: SortCreatedAtDesc, Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, first, page[0].ID)
+
+	page, total, err = store.List(ListFilter{SampleGroup: group, Sort: SortCreatedAtDesc, Limit: 1, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, page, 1)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, second, page[0].ID)
+
+	page, total, err = store.List(ListFilter{SampleGroup: group, Offset: 5})
+	require.NoError(t, err)
+	assert.NotNil(t, page)
+	assert.Empty(t, page)
+	assert.Equal(t, 2, total)
+
+	// Unfinished analyses sort last by completion time
+	page, _, err = store.List(ListFilter{SampleGroup: group, Sort: SortCompletedAtDesc})
+	require.NoError(t, err)
+	require.Len(t, page, 2)
+	assert.Equal(t, first, page[0].ID)
+
+	// Purity bounds only match completed analyses
+	low, high := 80.0, 90.0
+	pure, total, err := store.List(ListFilter{SampleGroup: group, MinPurity: &low, MaxPurity: &high})
+	require.NoError(t, err)
+	require.Len(t, pure, 1)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, first, pure[0].ID)
+	pure, _, err = store.List(ListFilter{SampleGroup: group, MaxPurity: &low})
+	require.NoError(t, err)
+	assert.Empty(t, pure)
+
+	recent, total, err := store.List(ListFilter{SampleGroup: group, From: created.Add(time.Second), To: created.Add(time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, recent, 1)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, first, recent[0].ID)
+
+	// Summaries aggregate the matches without returning them
+	summary, total, err := store.SummarizePurity(ListFilter{SampleGroup: group})
+	require.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Equal(t, 1, summary.Count)
+	assert.Equal(t, 1, summary.ProcessingCount)
+	assert.Equal(t, 87.5, summary.MeanPurity)
+	assert.Equal(t, 87.5, summary.MedianPurity)
+
+	batch, total, err := store.List(ListFilter{BatchID: group})
+	require.NoError(t, err)
+	require.Len(t, batch, 1)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, second, batch[0].ID)
+
+	sample, total, err := store.List(ListFilter{SampleID: group})
+	require.NoError(t, err)
+	require.Len(t, sample, 1)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, &models.SampleMetadata{SampleID: group, OperatorName: "A. Moreau", LocationLat: 48.85, SamplingDepthM: 12.5}, sample[0].SampleMetadata)
+
+	// Date ranges are inclusive; other tests may share the store, so only
+	// this test's records are checked
+	ids := func(results []*models.AnalysisResult) []string {
+		var matched []string
+		for _, result := range results {
+			if result.ID == first || result.ID == second {
+				matched = append(matched, result.ID)
+			}
+		}
+		return matched
+	}
+	ranged, err := store.ListByDateRange(created, created.Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, []string{second, first}, ids(ranged))
+	ranged, err = store.ListByDateRange(created.Add(time.Second), time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, []string{first}, ids(ranged))
+	ranged, err = store.ListByDateRange(time.Time{}, created.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Empty(t, ids(ranged))
+
+	// Hashes unique to this run keep shared databases from matching old rows
+	hash := uint64(time.Now().UnixNano()) * 0x9e3779b97f4a7c15
+	near, far, copied := newID("near"), newID("far"), newID("copied")
+	for _, result := range []*models.AnalysisResult{
+		{ID: near, Status: models.StatusCompleted, CreatedAt: created, PerceptualHash: formatPerceptualHash(hash ^ 0b11)},
+		{ID: far, Status: models.StatusCompleted, CreatedAt: created, PerceptualHash: formatPerceptualHash(hash ^ 0xff)},
+		{ID: copied, Status: models.StatusCompleted, CreatedAt: created, PerceptualHash: formatPerceptualHash(hash), DuplicateOf: near},
+	} {
+		require.NoError(t, store.Create(result))
+	}
+	_, err = store.Update(first, func(result *models.AnalysisResult) error {
+		result.PerceptualHash = formatPerceptualHash(hash ^ 0b1)
+		return nil
+	})
+	require.NoError(t, err)
+	similar, err := store.FindSimilar(hash, 4)
+	require.NoError(t, err)
+	var similarIDs []string
+	for _, result := range similar {
+		similarIDs = append(similarIDs, result.ID)
+	}
+	assert.Equal(t, []string{first, near}, similarIDs, "nearest first, duplicates excluded")
+	for _, id := range []string{near, far, copied} {
+		require.NoError(t, store.Delete(id))
+	}
+
+	_, _, err = store.List(ListFilter{Sort: "purity"})
+	assert.Error(t, err)
+
+	require.NoError(t, store.Delete(second))
+	_, err = store.Get(second)
+	assert.ErrorIs(t, err, ErrAnalysisNotFound)
+	assert.ErrorIs(t, store.Delete(second), ErrAnalysisNotFound)
+}
+
+// testWebhookStore exercises the WebhookStore contract
+func testWebhookStore(t *testing.T, store ResultStore, newID func(string) string) {
+	t.Helper()
+
+	webhook := &models.Webhook{
+		ID:        newID("hook"),
+		URL:       "https://lims.example.com/hooks",
+		Secret:    "s3cret",
+		Owner:     "sub:" + newID("lab"),
+		Events:    []string{models.EventAnalysisCompleted},
+		CreatedAt: time.Now().UTC().Truncate(time.Millisecond),
+	}
+	require.NoError(t, store.CreateWebhook(webhook))
+	other := &models.Webhook{
+		ID:        newID("other-hook"),
+		URL:       "https://other.example.com/hooks",
+		Owner:     "sub:" + newID("other-lab"),
+		Events:    []string{models.EventAnalysisCompleted},
+		CreatedAt: webhook.CreatedAt,
+	}
+	require.NoError(t, store.CreateWebhook(other))
+
+	loaded, err := store.GetWebhook(webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, webhook.URL, loaded.URL)
+	assert.Equal(t, webhook.Secret, loaded.Secret)
+	assert.Equal(t, webhook.Events, loaded.Events)
+	assert.Equal(t, webhook.Owner, loaded.Owner)
+
+	// Only the owner's webhooks are listed
+	webhooks, err := store.ListWebhooks(webhook.Owner)
+	require.NoError(t, err)
+	var ids []string
+	for _, listed := range webhooks {
+		ids = append(ids, listed.ID)
+	}
+	assert.Equal(t, []string{webhook.ID}, ids)
+
+	_, err = store.GetWebhook(newID("missing-hook"))
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+
+	// Only the most recent attempts are kept, and listed newest first
+	sent := time.Now().UTC().Truncate(time.Millisecond)
+	for attempt := 1; attempt <= maxStoredDeliveries+2; attempt++ {
+		require.NoError(t, store.RecordDelivery(&models.WebhookDelivery{
+			ID:         fmt.Sprintf("%s-%03d", webhook.ID, attempt),
+			WebhookID:  webhook.ID,
+			AnalysisID: newID("analysis"),
+			Event:      models.EventAnalysisCompleted,
+			Attempt:    attempt,
+			StatusCode: http.StatusOK,
+			Timestamp:  sent.Add(time.Duration(attempt) * time.Second),
+		}))
+	}
+	deliveries, err := store.ListDeliveries(webhook.ID, 3)
+	require.NoError(t, err)
+	require.Len(t, deliveries, 3)
+	assert.Equal(t, maxStoredDeliveries+2, deliveries[0].Attempt)
+	assert.Equal(t, http.StatusOK, deliveries[0].StatusCode)
+
+	deliveries, err = store.ListDeliveries(webhook.ID, 2*maxStoredDeliveries)
+	require.NoError(t, err)
+	assert.Len(t, deliveries, maxStoredDeliveries)
+
+	// Deleting a webhook drops its delivery attempts
+	require.NoError(t, store.DeleteWebhook(webhook.ID))
+	_, err = store.GetWebhook(webhook.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	deliveries, err = store.ListDeliveries(webhook.ID, 10)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+	assert.ErrorIs(t, store.DeleteWebhook(webhook.ID), ErrWebhookNotFound)
+}
+
+func TestMemoryStore(t *testing.T) {
+	testResultStore(t, NewMemoryStore(), func(id string) string { return id })
+	testWebhookStore(t, NewMemoryStore(), func(id string) string { return id })
+}
+
+// TestPostgresStore runs against the database in TEST_DATABASE_URL, if set
+func TestPostgresStore(t *testing.T) {
+	databaseURL := os.Getenv("TEST_DATABASE_URL")
+	if databaseURL == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	store, err := NewPostgresStore(databaseURL, 2)
+	require.NoError(t, err)
+	defer store.Close()
+
+	// Migrations are idempotent
+	again, err := NewPostgresStore(databaseURL, 1)
+	require.NoError(t, err)
+	again.Close()
+
+	prefix := fmt.Sprintf("store-test-%d-", time.Now().UnixNano())
+	testResultStore(t, store, func(id string) string { return prefix + id })
+	testWebhookStore(t, store, func(id string) string { return prefix + id })
+}
+
+func TestMigrationFilesEmbedded(t *testing.T) {
+	entries, err := migrationFiles.ReadDir("migrations")
+	require.NoError(t, err)
+	require.NotEmpty(t, entries)
+
+	for _, entry := range entries {
+		assert.True(t, strings.HasSuffix(entry.Name(), ".sql"), entry.Name())
+	}
+}
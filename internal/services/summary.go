@@ -0,0 +1,111 @@
+package services
+
+import (
+	"errors"
+	"math"
+	"sort"
+	"time"
+
+	"gypsum-analysis-api/internal/models"
+)
+
+// ErrTooFewResults is returned when fewer than two completed analyses are
+// summarized, which leaves their standard deviation undefined
+var ErrTooFewResults = errors.New("at least two completed analyses are needed")
+
+// runningStats accumulates a mean and variance one value at a time with
+// Welford's algorithm, which stays accurate for long runs of close values
+type runningStats struct {
+	count int
+	mean  float64
+	m2    float64
+}
+
+// add includes value in the statistics
+func (r *runningStats) add(value float64) {
+	r.count++
+	delta := value - r.mean
+	r.mean += delta / float64(r.count)
+	r.m2 += delta * (value - r.mean)
+}
+
+// stdDev returns the sample standard deviation, or 0 for fewer than two values
+func (r *runningStats) stdDev() float64 {
+	if r.count < 2 {
+		return 0
+	}
+	return math.Sqrt(r.m2 / float64(r.count-1))
+}
+
+// percentile returns the p-th percentile of sorted values, interpolating
+// linearly between the nearest ranks
+func percentile(sorted []float64, p float64) float64 {
+	rank := p / 100 * float64(len(sorted)-1)
+	lower := int(math.Floor(rank))
+	if lower+1 >= len(sorted) {
+		return sorted[len(sorted)-1]
+	}
+	return sorted[lower] + (rank-float64(lower))*(sorted[lower+1]-sorted[lower])
+}
+
+// SummarizePurity reports purity statistics over the analyses of a batch,
+// created between from and to, or both; zero bounds are open. It returns
+// ErrBatchNotFound for a batch without analyses and ErrTooFewResults when
+// fewer than two of the analyses have completed.
+func (s *AnalysisService) SummarizePurity(batchID string, from, to time.Time) (*models.PuritySummary, error) {
+	summary, total, err := s.store.SummarizePurity(ListFilter{BatchID: batchID, From: from, To: to})
+	if err != nil {
+		return nil, err
+	}
+	if batchID != "" && total == 0 {
+		return nil, ErrBatchNotFound
+	}
+	if summary.Count < 2 {
+		return nil, ErrTooFewResults
+	}
+
+	summary.BatchID = batchID
+	if !from.IsZero() {
+		summary.From = &from
+	}
+	if !to.IsZero() {
+		summary.To = &to
+	}
+	return summary, nil
+}
+
+// summarizeResults computes the purity statistics of results in memory
+func summarizeResults(results []*models.AnalysisResult) *models.PuritySummary {
+	summary := &models.PuritySummary{}
+
+	// Only the purities are kept, for the percentiles
+	var purity, confidence runningStats
+	purities := make([]float64, 0, len(results))
+	for _, result := range results {
+		switch result.Status {
+		case models.StatusPending, models.StatusProcessing:
+			summary.ProcessingCount++
+		case models.StatusFailed:
+			summary.FailedCount++
+		case models.StatusCompleted:
+			purity.add(result.PurityPercentage)
+			confidence.add(result.Confidence)
+			purities = append(purities, result.PurityPercentage)
+		}
+	}
+	if purity.count == 0 {
+		return summary
+	}
+
+	sort.Float64s(purities)
+	summary.Count = purity.count
+	summary.MeanPurity = purity.mean
+	summary.StdDevPurity = purity.stdDev()
+	summary.MinPurity = purities[0]
+	summary.MaxPurity = purities[len(purities)-1]
+	summary.MedianPurity = percentile(purities, 50)
+	summary.P5Purity = percentile(purities, 5)
+	summary.P95Purity = percentile(purities, 95)
+	summary.MeanConfidence = confidence.mean
+	return summary
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+)
+
+func TestSummarizePurity(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	created := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, result := range []*models.AnalysisResult{
+		{ID: "a", Status: models.StatusCompleted, PurityPercentage: 80, Confidence: 0.9},
+		{ID: "b", Status: models.StatusCompleted, PurityPercentage: 100, Confidence: 0.5},
+		{ID: "c", Status: models.StatusCompleted, PurityPercentage: 60, Confidence: 0.7},
+		{ID: "d", Status: models.StatusCompleted, PurityPercentage: 90, Confidence: 0.9},
+		{ID: "e", Status: models.StatusCompleted, PurityPercentage: 70, Confidence: 0.5},
+		{ID: "f", Status: models.StatusFailed},
+		{ID: "g", Status: models.StatusPending},
+		{ID: "h", Status: models.StatusProcessing},
+		{ID: "i", Status: models.StatusCancelled},
+	} {
+		result.BatchID = "batch-1"
+		result.CreatedAt = created.Add(time.Duration(i) * time.Hour)
+		require.NoError(t, service.store.Create(result))
+	}
+	require.NoError(t, service.store.Create(&models.AnalysisResult{ID: "other", Status: models.StatusCompleted, PurityPercentage: 10, CreatedAt: created}))
+
+	summary, err := service.SummarizePurity("batch-1", time.Time{}, time.Time{})
+	require.NoError(t, err)
+	assert.Equal(t, "batch-1", summary.BatchID)
+	assert.Equal(t, 5, summary.Count)
+	assert.InDelta(t, 80, summary.MeanPurity, 1e-9)
+	assert.InDelta(t, math.Sqrt(250), summary.StdDevPurity, 1e-9)
+	assert.Equal(t, 60.0, summary.MinPurity)
+	assert.Equal(t, 100.0, summary.MaxPurity)
+	assert.Equal(t, 80.0, summary.MedianPurity)
+	assert.InDelta(t, 62, summary.P5Purity, 1e-9)
+	assert.InDelta(t, 98, summary.P95Purity, 1e-9)
+	assert.InDelta(t, 0.7, summary.MeanConfidence, 1e-9)
+	assert.Equal(t, 1, summary.FailedCount)
+	assert.Equal(t, 2, summary.ProcessingCount)
+
+	// A time range covers every batch; a and b are the first two of batch-1
+	summary, err = service.SummarizePurity("", created, created.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, 3, summary.Count)
+	assert.Equal(t, 10.0, summary.MinPurity)
+	require.NotNil(t, summary.From)
+	assert.Equal(t, created, *summary.From)
+
+	_, err = service.SummarizePurity("batch-1", created.Add(4*time.Hour), time.Time{})
+	assert.ErrorIs(t, err, ErrTooFewResults)
+
+	_, err = service.SummarizePurity("missing", time.Time{}, time.Time{})
+	assert.ErrorIs(t, err, ErrBatchNotFound)
+}
+
+func TestRunningStats_MatchesTwoPass(t *testing.T) {
+	values := []float64{1e9 + 4, 1e9 + 7, 1e9 + 13, 1e9 + 16}
+
+	var stats runningStats
+	for _, v := range values {
+		stats.add(v)
+	}
+	mean, stdDev := meanStdDev(values)
+	assert.InDelta(t, mean, stats.mean, 1e-6)
+	assert.InDelta(t, stdDev, stats.stdDev(), 1e-9)
+}
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// TempFileRegistry tracks the temp files written for running analyses, with
+// the time each was registered. Files still registered when their analysis
+// could not clean up, for example after a panic, are removed by the janitor
+// once they are old enough and when the service shuts down.
+type TempFileRegistry struct {
+	mutex sync.Mutex
+	files map[string]time.Time
+}
+
+// NewTempFileRegistry creates an empty registry
+func NewTempFileRegistry() *TempFileRegistry {
+	return &TempFileRegistry{files: make(map[string]time.Time)}
+}
+
+// Register starts tracking paths
+func (r *TempFileRegistry) Register(paths ...string) {
+	now := time.Now()
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, path := range paths {
+		r.files[path] = now
+	}
+}
+
+// Deregister stops tracking paths without touching the files
+func (r *TempFileRegistry) Deregister(paths ...string) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	for _, path := range paths {
+		delete(r.files, path)
+	}
+}
+
+// Remove deletes paths and stops tracking those that are gone. A file that
+// could not be deleted stays registered so a later sweep retries it.
+func (r *TempFileRegistry) Remove(paths ...string) {
+	for _, path := range paths {
+		if err := os.Remove(path); err == nil || os.IsNotExist(err) {
+			r.Deregister(path)
+		}
+	}
+}
+
+// RemoveOlderThan deletes the files registered before cutoff and returns how
+// many were removed
+func (r *TempFileRegistry) RemoveOlderThan(cutoff time.Time) int {
+	var expired []string
+	r.mutex.Lock()
+	for path, registered := range r.files {
+		if registered.Before(cutoff) {
+			expired = append(expired, path)
+		}
+	}
+	r.mutex.Unlock()
+
+	r.Remove(expired...)
+	return len(expired) - r.count(expired)
+}
+
+// RemoveAll deletes every registered file and returns how many were removed
+func (r *TempFileRegistry) RemoveAll() int {
+	r.mutex.Lock()
+	paths := make([]string, 0, len(r.files))
+	for path := range r.files {
+		paths = append(paths, path)
+	}
+	r.mutex.Unlock()
+
+	r.Remove(paths...)
+	return len(paths) - r.count(paths)
+}
+
+// Len returns the number of registered files
+func (r *TempFileRegistry) Len() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.files)
+}
+
+// count returns how many of paths are still registered
+func (r *TempFileRegistry) count(paths []string) int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	n := 0
+	for _, path := range paths {
+		if _, ok := r.files[path]; ok {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,73 @@
+package services
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tempFile writes an empty file named name in dir
+func tempFile(t *testing.T, dir, name string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, nil, 0644))
+	return path
+}
+
+func TestTempFileRegistry(t *testing.T) {
+	dir := t.TempDir()
+	registry := NewTempFileRegistry()
+
+	old, recent := tempFile(t, dir, "old_macro.ijm"), tempFile(t, dir, "recent_macro.ijm")
+	registry.Register(old)
+	cutoff := time.Now().Add(time.Millisecond)
+	time.Sleep(2 * time.Millisecond)
+	registry.Register(recent)
+
+	// Only files registered before the cutoff expire
+	assert.Equal(t, 1, registry.RemoveOlderThan(cutoff))
+	assert.NoFileExists(t, old)
+	assert.FileExists(t, recent)
+	assert.Equal(t, 1, registry.Len())
+
+	// Deregistered files are left alone
+	kept := tempFile(t, dir, "kept_results.txt")
+	registry.Register(kept)
+	registry.Deregister(kept)
+
+	// Files that are already gone are simply forgotten
+	registry.Register(filepath.Join(dir, "missing.png"))
+
+	assert.Equal(t, 2, registry.RemoveAll())
+	assert.NoFileExists(t, recent)
+	assert.FileExists(t, kept)
+	assert.Zero(t, registry.Len())
+}
+
+func TestTempFiles_DeregisteredAfterAnalysis(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(80, 20)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("temp-1", file, AnalysisOptions{}))
+
+	assert.Zero(t, service.tempFiles.Len())
+	assert.NoFileExists(t, filepath.Join(service.config.TempDir, "temp-1_macro.ijm"))
+}
+
+func TestTempFiles_RemovedOnClose(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	// Stand in for an analysis that died before cleaning up
+	leftover := tempFile(t, service.config.TempDir, "crashed_macro.ijm")
+	service.tempFiles.Register(leftover)
+
+	service.Close()
+	assert.NoFileExists(t, leftover)
+	assert.Zero(t, service.tempFiles.Len())
+}
@@ -0,0 +1,318 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"gypsum-analysis-api/internal/imaging"
+	"gypsum-analysis-api/internal/models"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// errNoResultsBlock is reported when a tile's Fiji run exits without printing its results
+var errNoResultsBlock = errors.New("Fiji output contains no results block")
+
+// particle is a single particle reported by a tile macro
+type particle struct {
+	point
+	Area float64
+}
+
+// tileOutput holds what the macro printed for one tile
+type tileOutput struct {
+	particles      []particle
+	threshold      float64
+	thresholdLower float64
+	thresholdUpper float64
+	fijiVersion    string
+}
+
+// shouldTile reports whether an image is large enough to be analyzed in tiles
+func (s *AnalysisService) shouldTile(cfg image.Config) bool {
+	return s.config.TileThresholdPixels > 0 && cfg.Width*cfg.Height > s.config.TileThresholdPixels
+}
+
+// performTiledAnalysis splits a large image into overlapping tiles, runs Fiji on
+// each and merges the results. Each particle is attributed to the tile whose
+// core contains its centroid, so particles on a seam are counted exactly once.
+// When crop is set only that region of the image is tiled.
+func (s *AnalysisService) performTiledAnalysis(ctx context.Context, analysisID, imagePath string, crop *models.Rectangle, params models.MacroParams) error {
+	startTime := time.Now()
+
+	img, _, err := imaging.DecodeFile(imagePath)
+	if err != nil {
+		return s.updateResultWithError(analysisID, models.FailureBadInput, fmt.Sprintf("Uploaded file is not a readable image: %v", err))
+	}
+	if crop != nil {
+		var ok bool
+		if img, ok = cropImage(img, *crop); !ok {
+			return s.updateResultWithError(analysisID, models.FailureBadInput, "Uploaded image cannot be cropped")
+		}
+	}
+
+	tiles := imaging.PlanTiles(img.Bounds(), s.config.TileSize, s.config.TileOverlap)
+	s.analysisLog(ctx, analysisID).WithField("tiles", len(tiles)).Info("Analyzing image in tiles")
+
+	outputs, err := s.analyzeTiles(ctx, analysisID, img, tiles, params)
+	if err != nil {
+		return err
+	}
+
+	tileResults := make([]models.TileResult, 0, len(tiles))
+	var owned []point
+	var totalArea, thresholdSum, lowerSum, upperSum float64
+	var fijiVersion string
+
+	for i, tile := range tiles {
+		output := outputs[i]
+		tileResult := models.TileResult{
+			Index:  tile.Index,
+			X:      tile.Core.Min.X,
+			Y:      tile.Core.Min.Y,
+			Width:  tile.Core.Dx(),
+			Height: tile.Core.Dy(),
+		}
+		for _, p := range output.particles {
+			// Centroids are relative to the tile's own image
+			centroid := point{X: p.X + float64(tile.Bounds.Min.X), Y: p.Y + float64(tile.Bounds.Min.Y)}
+			if !coreContains(tile.Core, centroid) {
+				continue
+			}
+			owned = append(owned, centroid)
+			tileResult.ParticleCount++
+			tileResult.TotalArea += p.Area
+		}
+		tileResult.PurityPercentage = tileResult.TotalArea / float64(tile.Core.Dx()*tile.Core.Dy()) * 100
+
+		tileResults = append(tileResults, tileResult)
+		totalArea += tileResult.TotalArea
+		thresholdSum += output.threshold
+		lowerSum += output.thresholdLower
+		upperSum += output.thresholdUpper
+		if output.fijiVersion != "" {
+			fijiVersion = output.fijiVersion
+		}
+	}
+
+	analysisTime := time.Since(startTime).Milliseconds()
+
+	imageArea := float64(img.Bounds().Dx() * img.Bounds().Dy())
+	purity := totalArea / imageArea * 100
+	if purity > 100 {
+		purity = 100
+	}
+
+	var spacingStats *models.SpacingStats
+	if s.config.ComputeSpacing {
+		spacingStats = computeSpacingStats(owned)
+	}
+
+	_, err = s.update(analysisID, func(result *models.AnalysisResult) error {
+		result.PurityPercentage = purity
+		result.GypsumContent = purity
+		result.ImpurityContent = 100 - purity
+		result.ParticleCount = len(owned)
+		result.TotalArea = totalArea
+		result.ImageArea = imageArea
+		result.AverageParticleSize = 0
+		if len(owned) > 0 {
+			result.AverageParticleSize = totalArea / float64(len(owned))
+		}
+		if len(tiles) > 0 {
+			result.ThresholdValue = thresholdSum / float64(len(tiles))
+			lower, upper := lowerSum/float64(len(tiles)), upperSum/float64(len(tiles))
+			result.ThresholdLower, result.ThresholdUpper = &lower, &upper
+		}
+		result.FijiVersion = fijiVersion
+		result.Tiles = tileResults
+		result.SpacingStats = spacingStats
+		result.AnalysisTime = analysisTime
+
+		result.Confidence, result.ConfidenceFactors = s.calculateConfidence(map[string]float64{
+			"purity_percentage": result.PurityPercentage,
+			"total_area":        totalArea,
+			"image_area":        imageArea,
+			"threshold_value":   result.ThresholdValue,
+		}, len(owned))
+
+		result.CalciteContent = result.ImpurityContent * 0.3
+		result.QuartzContent = result.ImpurityContent * 0.2
+		result.OtherMinerals = result.ImpurityContent * 0.5
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.completeAnalysis(ctx, analysisID, imagePath, analysisTime)
+}
+
+// analyzeTiles runs Fiji on the tiles, TILE_CONCURRENCY at a time, and
+// returns their outputs in tile order. Each run also takes one of the
+// service-wide Fiji slots, so tiles never exceed MAX_CONCURRENT_ANALYSES
+// Fiji processes together with other analyses. Once a tile fails no further tiles are
+// started; those already running finish so their failures are not mistaken
+// for cancellations. A recorded failure is returned in preference to one that
+// runAnalysis would retry.
+func (s *AnalysisService) analyzeTiles(ctx context.Context, analysisID string, img image.Image, tiles []imaging.Tile, params models.MacroParams) ([]tileOutput, error) {
+	outputs := make([]tileOutput, len(tiles))
+	errs := make([]error, len(tiles))
+	var failed atomic.Bool
+
+	var g errgroup.Group
+	g.SetLimit(max(s.config.TileConcurrency, 1))
+	for i, tile := range tiles {
+		i, tile := i, tile
+		g.Go(func() error {
+			if failed.Load() {
+				return nil
+			}
+			output, err := s.analyzeTile(ctx, analysisID, img, tile, tileSpan(tile.Index, len(tiles)), params)
+			if err != nil {
+				failed.Store(true)
+				errs[i] = err
+				return err
+			}
+			outputs[i] = output
+			return nil
+		})
+	}
+	err := g.Wait()
+	if err == nil {
+		return outputs, nil
+	}
+
+	var transient *retryableError
+	for _, tileErr := range errs {
+		if tileErr != nil && !errors.As(tileErr, &transient) {
+			return nil, tileErr
+		}
+	}
+	return nil, err
+}
+
+// analyzeTile writes one tile to disk and runs the macro on it. Failures are
+// recorded on the result before being returned.
+func (s *AnalysisService) analyzeTile(ctx context.Context, analysisID string, img image.Image, tile imaging.Tile, span progressSpan, params models.MacroParams) (tileOutput, error) {
+	tilePath := filepath.Join(s.config.TempDir, fmt.Sprintf("%s_tile_%d.png", analysisID, tile.Index))
+	macroPath := filepath.Join(s.config.TempDir, fmt.Sprintf("%s_tile_%d_macro.ijm", analysisID, tile.Index))
+	s.tempFiles.Register(tilePath, macroPath)
+	defer s.tempFiles.Remove(tilePath, macroPath)
+
+	if err := imaging.WriteTilePNG(img, tile.Bounds, tilePath); err != nil {
+		return tileOutput{}, s.updateResultWithError(analysisID, models.FailureStorage, fmt.Sprintf("Failed to write tile %d: %v", tile.Index, err))
+	}
+
+	macro, err := writeGypsumMacro(macroPath, gypsumMacro{ImagePath: tilePath, Params: params, PerParticle: true})
+	if err != nil {
+		return tileOutput{}, s.updateResultWithError(analysisID, models.FailureStorage, fmt.Sprintf("Failed to create analysis macro: %v", err))
+	}
+
+	// Every tile runs the same macro apart from the image path; keep the first
+	if tile.Index == 0 {
+		s.update(analysisID, func(result *models.AnalysisResult) error {
+			result.Macro = macro
+			return nil
+		})
+	}
+
+	output, err := s.runMacro(ctx, analysisID, macroPath, span)
+	if err != nil {
+		return tileOutput{}, err
+	}
+
+	parsed, err := parseTileOutput(string(output))
+	if err != nil {
+		return tileOutput{}, s.updateResultWithError(analysisID, models.FailureParse, fmt.Sprintf("Failed to parse results of tile %d: %v", tile.Index, err))
+	}
+
+	return parsed, nil
+}
+
+// parseTileOutput reads the per-particle lines and threshold from a tile's results block
+func parseTileOutput(output string) (tileOutput, error) {
+	if !strings.Contains(output, "ANALYSIS_RESULTS_START") {
+		return tileOutput{}, errNoResultsBlock
+	}
+
+	var parsed tileOutput
+	inResults := false
+	for _, line := range strings.Split(output, "\n") {
+		line = strings.TrimSpace(line)
+
+		if line == "ANALYSIS_RESULTS_START" {
+			inResults = true
+			continue
+		}
+		if line == "ANALYSIS_RESULTS_END" {
+			break
+		}
+		if !inResults {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		switch key {
+		case "particle":
+			p, err := parseParticle(value)
+			if err != nil {
+				return tileOutput{}, err
+			}
+			parsed.particles = append(parsed.particles, p)
+		case "threshold_value":
+			if threshold, err := strconv.ParseFloat(value, 64); err == nil {
+				parsed.threshold = threshold
+			}
+		case "threshold_lower":
+			if lower, err := strconv.ParseFloat(value, 64); err == nil {
+				parsed.thresholdLower = lower
+			}
+		case "threshold_upper":
+			if upper, err := strconv.ParseFloat(value, 64); err == nil {
+				parsed.thresholdUpper = upper
+			}
+		case "fiji_version":
+			parsed.fijiVersion = value
+		}
+	}
+
+	return parsed, nil
+}
+
+// parseParticle parses an "x,y,area" particle value printed by the macro
+func parseParticle(value string) (particle, error) {
+	idx := strings.LastIndex(value, ",")
+	if idx < 0 {
+		return particle{}, fmt.Errorf("invalid particle %q", value)
+	}
+
+	centroid, err := parseCentroid(value[:idx])
+	if err != nil {
+		return particle{}, err
+	}
+	area, err := strconv.ParseFloat(strings.TrimSpace(value[idx+1:]), 64)
+	if err != nil {
+		return particle{}, fmt.Errorf("invalid particle area %q: %w", value[idx+1:], err)
+	}
+
+	return particle{point: centroid, Area: area}, nil
+}
+
+// coreContains reports whether a centroid falls inside a tile's core. Cores are
+// half-open so a centroid on a shared edge belongs to exactly one tile.
+func coreContains(core image.Rectangle, p point) bool {
+	return p.X >= float64(core.Min.X) && p.X < float64(core.Max.X) &&
+		p.Y >= float64(core.Min.Y) && p.Y < float64(core.Max.Y)
+}
@@ -0,0 +1,267 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/imaging"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// grainImage renders 5x5 white grains every 13 pixels on a black background,
+// returning the image and its number of grains and white pixels
+func grainImage(width, height int) (*image.Gray, int, int) {
+	img := image.NewGray(image.Rect(0, 0, width, height))
+	grains, white := 0, 0
+	for gy := 3; gy+5 <= height; gy += 13 {
+		for gx := 3; gx+5 <= width; gx += 13 {
+			grains++
+			for y := gy; y < gy+5; y++ {
+				for x := gx; x < gx+5; x++ {
+					img.SetGray(x, y, color.Gray{Y: 255})
+					white++
+				}
+			}
+		}
+	}
+	return img, grains, white
+}
+
+var macroImagePattern = regexp.MustCompile(`open\("([^"]+)"\)`)
+
+// particleRunner stands in for Fiji by labelling the white 4-connected
+// components of the image the macro opens and printing one particle line each
+func particleRunner(t *testing.T) FijiRunner {
+	return runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		macro, err := os.ReadFile(macroPath)
+		require.NoError(t, err)
+		match := macroImagePattern.FindSubmatch(macro)
+		require.NotNil(t, match)
+
+		img, _, err := imaging.DecodeFile(string(match[1]))
+		require.NoError(t, err)
+
+		bounds := img.Bounds()
+		seen := make(map[image.Point]bool)
+		isWhite := func(p image.Point) bool {
+			r, _, _, _ := img.At(p.X, p.Y).RGBA()
+			return p.In(bounds) && r > 0x8000
+		}
+
+		var out strings.Builder
+		out.WriteString("ANALYSIS_RESULTS_START\n")
+		for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+			for x := bounds.Min.X; x < bounds.Max.X; x++ {
+				start := image.Pt(x, y)
+				if seen[start] || !isWhite(start) {
+					continue
+				}
+
+				var sumX, sumY, area float64
+				stack := []image.Point{start}
+				seen[start] = true
+				for len(stack) > 0 {
+					p := stack[len(stack)-1]
+					stack = stack[:len(stack)-1]
+					sumX, sumY, area = sumX+float64(p.X)+0.5, sumY+float64(p.Y)+0.5, area+1
+
+					for _, n := range []image.Point{p.Add(image.Pt(1, 0)), p.Add(image.Pt(-1, 0)), p.Add(image.Pt(0, 1)), p.Add(image.Pt(0, -1))} {
+						if !seen[n] && isWhite(n) {
+							seen[n] = true
+							stack = append(stack, n)
+						}
+					}
+				}
+				fmt.Fprintf(&out, "particle:%g,%g,%g\n", sumX/area, sumY/area, area)
+			}
+		}
+		out.WriteString("threshold_value:128\nthreshold_lower:128\nthreshold_upper:255\nfiji_version:2.14.0/1.54f\nANALYSIS_RESULTS_END\n")
+
+		return []byte(out.String()), nil
+	})
+}
+
+func TestTiledAnalysis_AggregateMatchesWholeImage(t *testing.T) {
+	img, grains, white := grainImage(300, 200)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	// 60px cores put seams through many grains
+	cfg := &config.Config{TileThresholdPixels: 10000, TileSize: 80, TileOverlap: 10}
+	service := newTestService(t, cfg, particleRunner(t))
+
+	file := newFileHeader(t, "large.png", buf.Bytes())
+	require.NoError(t, service.AnalyzeGypsumImage("tiled-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("tiled-1")
+	require.NoError(t, err)
+	require.Empty(t, result.Error)
+
+	assert.Equal(t, grains, result.ParticleCount, "grains on seams are counted once")
+	assert.InDelta(t, float64(white)/(300*200)*100, result.PurityPercentage, 1e-9)
+	assert.InDelta(t, float64(white), result.TotalArea, 1e-9)
+	assert.InDelta(t, 300*200, result.ImageArea, 1e-9)
+	assert.Equal(t, "2.14.0/1.54f", result.FijiVersion)
+	assert.Equal(t, floatPtr(128), result.ThresholdLower)
+	assert.Equal(t, floatPtr(255), result.ThresholdUpper)
+	assert.Contains(t, result.Macro, `print("particle:"`)
+
+	// 5 columns by 4 rows of tiles whose regions cover the image exactly once
+	require.Len(t, result.Tiles, 20)
+	count, covered := 0, 0
+	var weighted float64
+	for _, tile := range result.Tiles {
+		count += tile.ParticleCount
+		covered += tile.Width * tile.Height
+		weighted += tile.PurityPercentage * float64(tile.Width*tile.Height)
+	}
+	assert.Equal(t, grains, count)
+	assert.Equal(t, 300*200, covered)
+	assert.InDelta(t, result.PurityPercentage, weighted/float64(covered), 1e-9)
+}
+
+func TestTiledAnalysis_BoundedConcurrency(t *testing.T) {
+	img, grains, _ := grainImage(300, 200)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	// Count the Fiji runs in flight, holding each long enough to overlap
+	var running, peak atomic.Int32
+	inner := particleRunner(t)
+	runner := runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return inner.Run(ctx, macroPath, onLine)
+	})
+
+	cfg := &config.Config{TileThresholdPixels: 10000, TileSize: 80, TileOverlap: 10, TileConcurrency: 3, MaxWorkers: 4}
+	service := newTestService(t, cfg, runner)
+
+	file := newFileHeader(t, "large.png", buf.Bytes())
+	require.NoError(t, service.AnalyzeGypsumImage("tiled-3", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("tiled-3")
+	require.NoError(t, err)
+	require.Empty(t, result.Error)
+	assert.Equal(t, grains, result.ParticleCount)
+	require.Len(t, result.Tiles, 20)
+	for i, tile := range result.Tiles {
+		assert.Equal(t, i, tile.Index, "tiles are reported in order")
+	}
+	assert.Greater(t, peak.Load(), int32(1))
+	assert.LessOrEqual(t, peak.Load(), int32(3))
+}
+
+func TestTiledAnalysis_SharesFijiSlotsAcrossAnalyses(t *testing.T) {
+	img, grains, _ := grainImage(300, 200)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	var running, peak atomic.Int32
+	inner := particleRunner(t)
+	runner := runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		return inner.Run(ctx, macroPath, onLine)
+	})
+
+	// Two pooled analyses of two tiles at a time would run four Fiji processes
+	cfg := &config.Config{TileThresholdPixels: 10000, TileSize: 80, TileOverlap: 10, TileConcurrency: 2, MaxWorkers: 3}
+	service := newTestService(t, cfg, runner)
+
+	ids := []string{"shared-1", "shared-2"}
+	for _, id := range ids {
+		require.NoError(t, service.SubmitAnalysis(context.Background(), id, newFileHeader(t, "large.png", buf.Bytes()), AnalysisOptions{}))
+	}
+	for _, id := range ids {
+		result, err := service.WaitForAnalysis(context.Background(), id)
+		require.NoError(t, err)
+		require.Equal(t, models.StatusCompleted, result.Status, result.Error)
+		assert.Equal(t, grains, result.ParticleCount)
+	}
+	assert.Greater(t, peak.Load(), int32(2), "both analyses ran tiles at once")
+	assert.LessOrEqual(t, peak.Load(), int32(3), "MAX_CONCURRENT_ANALYSES bounds the Fiji processes of all analyses")
+}
+
+func TestTiledAnalysis_TileFailureFailsAnalysis(t *testing.T) {
+	img, _, _ := grainImage(300, 200)
+
+	var buf bytes.Buffer
+	require.NoError(t, png.Encode(&buf, img))
+
+	// One tile's Fiji run crashes after the macro started
+	inner := particleRunner(t)
+	runner := runnerFunc(func(ctx context.Context, macroPath string, onLine func(string)) ([]byte, error) {
+		if strings.Contains(macroPath, "_tile_7_") {
+			return []byte(heartbeatMarker + "\n"), fmt.Errorf("exit status 1")
+		}
+		return inner.Run(ctx, macroPath, onLine)
+	})
+
+	cfg := &config.Config{TileThresholdPixels: 10000, TileSize: 80, TileOverlap: 10, TileConcurrency: 4}
+	service := newTestService(t, cfg, runner)
+
+	file := newFileHeader(t, "large.png", buf.Bytes())
+	assert.Error(t, service.AnalyzeGypsumImage("tiled-4", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("tiled-4")
+	require.NoError(t, err)
+	assert.Equal(t, models.StatusFailed, result.Status)
+	assert.Equal(t, models.FailureFijiExec, result.FailureCategory)
+	assert.Nil(t, result.Tiles)
+}
+
+func TestTiledAnalysis_SmallImageNotTiled(t *testing.T) {
+	cfg := &config.Config{TileThresholdPixels: 1 << 20, TileSize: 80, TileOverlap: 10}
+	service := newTestService(t, cfg, &fakeRunner{output: fijiOutput(60, 3)})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 60))
+	require.NoError(t, service.AnalyzeGypsumImage("tiled-2", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("tiled-2")
+	require.NoError(t, err)
+	assert.Nil(t, result.Tiles)
+	assert.Equal(t, 3, result.ParticleCount)
+}
+
+func TestParseParticle(t *testing.T) {
+	p, err := parseParticle("12.5,40.25,25")
+	require.NoError(t, err)
+	assert.Equal(t, particle{point: point{X: 12.5, Y: 40.25}, Area: 25}, p)
+
+	for _, value := range []string{"", "12.5,40.25", "a,b,c", "1,2,x"} {
+		_, err := parseParticle(value)
+		assert.Error(t, err, value)
+	}
+}
@@ -0,0 +1,36 @@
+package services
+
+import (
+	"errors"
+	"os/exec"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the spans of the analysis pipeline. Spans of a queued
+// analysis are children of the span that submitted it.
+var tracer = otel.Tracer("gypsum-analysis-api/internal/services")
+
+// endSpan marks the span failed when err is set and ends it
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// exitCode is the exit status of a Fiji run: 0 on success, the process's code
+// when it exited with a failure and -1 when it did not exit normally
+func exitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,56 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing_QueuedAnalysisContinuesTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	otel.SetTracerProvider(provider)
+
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(75, 20)})
+
+	ctx, request := provider.Tracer("test").Start(context.Background(), "request")
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 75))
+	require.NoError(t, service.SubmitAnalysis(ctx, "trace-1", file, AnalysisOptions{}))
+	request.End()
+
+	_, err := service.WaitForAnalysis(context.Background(), "trace-1")
+	require.NoError(t, err)
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	for _, name := range []string{"saveUploadedFile", "createGypsumAnalysisMacro", "fiji.execute", "parseFijiResults"} {
+		span, ok := spans[name]
+		require.True(t, ok, "missing span %s", name)
+		assert.Equal(t, request.SpanContext().TraceID(), span.SpanContext().TraceID(), name)
+		assert.Contains(t, span.Attributes(), tracing.AnalysisID.String("trace-1"), name)
+	}
+
+	assert.Contains(t, spans["saveUploadedFile"].Attributes(), tracing.ImageSizeBytes.Int64(file.Size))
+	assert.Contains(t, spans["fiji.execute"].Attributes(), tracing.FijiExitCode.Int(0))
+	assert.Contains(t, attributeKeys(spans["parseFijiResults"].Attributes()), tracing.PurityPercentage)
+}
+
+// attributeKeys lists the keys of span attributes
+func attributeKeys(attrs []attribute.KeyValue) []attribute.Key {
+	keys := make([]attribute.Key, len(attrs))
+	for i, attr := range attrs {
+		keys[i] = attr.Key
+	}
+	return keys
+}
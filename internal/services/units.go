@@ -0,0 +1,113 @@
+package services
+
+import "gypsum-analysis-api/internal/models"
+
+// pixelUnit is the length unit of measurements taken on an uncalibrated image
+const pixelUnit = "px"
+
+// micronUnit is the length unit of measurements of a calibrated image
+const micronUnit = "µm"
+
+// spatialUnit returns the length unit that particle and spacing measurements
+// of the analysis are expressed in
+func spatialUnit(result *models.AnalysisResult) string {
+	if result.MeasurementUnit != "" {
+		return result.MeasurementUnit
+	}
+	if result.SpacingStats != nil && result.SpacingStats.Unit != "" {
+		return result.SpacingStats.Unit
+	}
+	return pixelUnit
+}
+
+// applyCalibration labels the measurements of a finished analysis with their
+// unit. Fiji measures in pixels; for a calibrated image the areas, particle
+// sizes and spacings are converted to microns. Measurements that already
+// carry a unit are left alone, so this is safe to apply more than once.
+func applyCalibration(result *models.AnalysisResult) {
+	if result.MeasurementUnit != "" {
+		return
+	}
+	result.MeasurementUnit = pixelUnit
+	if result.Calibration == nil || result.Calibration.PixelsPerMicron <= 0 {
+		return
+	}
+
+	micronsPerPixel := 1 / result.Calibration.PixelsPerMicron
+	area := micronsPerPixel * micronsPerPixel
+
+	result.MeasurementUnit = micronUnit
+	result.TotalArea *= area
+	result.ImageArea *= area
+	result.AverageParticleSize *= area
+
+	// Shared slices and structs are replaced, never modified in place
+	if len(result.Tiles) > 0 {
+		tiles := make([]models.TileResult, len(result.Tiles))
+		for i, tile := range result.Tiles {
+			tile.TotalArea *= area
+			tiles[i] = tile
+		}
+		result.Tiles = tiles
+	}
+	if result.SpacingStats != nil {
+		stats := *result.SpacingStats
+		stats.Mean *= micronsPerPixel
+		stats.Median *= micronsPerPixel
+		stats.Min *= micronsPerPixel
+		stats.Max *= micronsPerPixel
+		stats.Unit = micronUnit
+		result.SpacingStats = &stats
+	}
+}
+
+// ResultUnits maps each numeric field of the analysis result, by JSON name, to
+// the unit it is reported in
+func ResultUnits(result *models.AnalysisResult) map[string]string {
+	length := spatialUnit(result)
+
+	units := map[string]string{
+		"purity_percentage":           "%",
+		"confidence":                  "ratio",
+		"image_size":                  "bytes",
+		"analysis_time_ms":            "ms",
+		"gypsum_content_percentage":   "%",
+		"impurity_content_percentage": "%",
+		"calcite_content_percentage":  "%",
+		"quartz_content_percentage":   "%",
+		"other_minerals_percentage":   "%",
+		"threshold_value":             "gray level",
+		"threshold_lower":             "gray level",
+		"threshold_upper":             "gray level",
+		"particle_count":              "count",
+		"average_particle_size_um":    length + "^2",
+		"total_area":                  length + "^2",
+		"image_area":                  length + "^2",
+		"shadow_purity_percentage":    "%",
+		"manual_override_purity":      "%",
+	}
+
+	if result.Calibration != nil {
+		units["calibration.pixels_per_micron"] = "px/µm"
+	}
+
+	if len(result.Tiles) > 0 {
+		units["tiles.x"] = pixelUnit
+		units["tiles.y"] = pixelUnit
+		units["tiles.width"] = pixelUnit
+		units["tiles.height"] = pixelUnit
+		units["tiles.particle_count"] = "count"
+		units["tiles.total_area"] = length + "^2"
+		units["tiles.purity_percentage"] = "%"
+	}
+
+	if result.SpacingStats != nil {
+		units["spacing_stats.mean"] = length
+		units["spacing_stats.median"] = length
+		units["spacing_stats.min"] = length
+		units["spacing_stats.max"] = length
+		units["spacing_stats.particle_count"] = "count"
+	}
+
+	return units
+}
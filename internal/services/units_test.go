@@ -0,0 +1,123 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"strings"
+	"testing"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/image/tiff"
+)
+
+func TestResultUnits_MatchesAnalysisConfiguration(t *testing.T) {
+	output := strings.Replace(fijiOutput(70, 3), "ANALYSIS_RESULTS_END", "centroid:0,0\ncentroid:3,4\ncentroid:10,0\nANALYSIS_RESULTS_END", 1)
+	service := newTestService(t, &config.Config{ComputeSpacing: true}, &fakeRunner{output: output})
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 70))
+	require.NoError(t, service.AnalyzeGypsumImage("units-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("units-1")
+	require.NoError(t, err)
+
+	units := ResultUnits(result)
+	assert.Equal(t, "%", units["purity_percentage"])
+	assert.Equal(t, "ms", units["analysis_time_ms"])
+	assert.Equal(t, "count", units["particle_count"])
+	assert.Equal(t, "px^2", units["average_particle_size_um"])
+	require.NotNil(t, result.SpacingStats)
+	assert.Equal(t, result.SpacingStats.Unit, units["spacing_stats.mean"])
+}
+
+func TestResultUnits_OmitsSpacingWhenDisabled(t *testing.T) {
+	units := ResultUnits(&models.AnalysisResult{})
+
+	assert.Equal(t, "px^2", units["average_particle_size_um"])
+	assert.NotContains(t, units, "spacing_stats.mean")
+}
+
+func TestResultUnits_CalibratedMeasurementsInMicrons(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(40, 4)})
+
+	// 2 px per µm: one square pixel is 0.25 µm²
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 40))
+	require.NoError(t, service.AnalyzeGypsumImage("units-2", file, AnalysisOptions{ScaleBarLength: 50, ScaleBarPixels: 100}))
+
+	result, err := service.GetAnalysisStatus("units-2")
+	require.NoError(t, err)
+	assert.Equal(t, "µm", result.MeasurementUnit)
+	assert.Equal(t, 1000.0, result.TotalArea)
+	assert.Equal(t, 2500.0, result.ImageArea)
+	assert.Equal(t, "µm^2", ResultUnits(result)["total_area"])
+
+	// Uncalibrated measurements stay in pixels and say so
+	require.NoError(t, service.AnalyzeGypsumImage("units-3", newFileHeader(t, "sample.png", splitImagePNG(t, 40)), AnalysisOptions{}))
+	result, err = service.GetAnalysisStatus("units-3")
+	require.NoError(t, err)
+	assert.Equal(t, "px", result.MeasurementUnit)
+	assert.Equal(t, 4000.0, result.TotalArea)
+}
+
+// tiffWithResolution encodes a TIFF and rewrites its X and Y resolution, in
+// pixels per inch, which the encoder always sets to 72
+func tiffWithResolution(t *testing.T, dpi uint32) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	require.NoError(t, tiff.Encode(&buf, image.NewGray(image.Rect(0, 0, 100, 100)), nil))
+	data := buf.Bytes()
+
+	// Walk the first IFD for the two rational resolution tags
+	order := binary.ByteOrder(binary.LittleEndian)
+	if data[0] == 'M' {
+		order = binary.BigEndian
+	}
+	ifd := order.Uint32(data[4:])
+	for i := uint32(0); i < uint32(order.Uint16(data[ifd:])); i++ {
+		entry := data[ifd+2+12*i:]
+		if tag := order.Uint16(entry); tag == 0x011A || tag == 0x011B {
+			order.PutUint32(data[order.Uint32(entry[8:]):], dpi)
+		}
+	}
+	return data
+}
+
+func TestAnalysis_EXIFResolutionCalibrates(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(40, 4)})
+
+	// 50800 px per inch is 2 px per µm: one square pixel is 0.25 µm²
+	file := newFileHeader(t, "sample.tif", tiffWithResolution(t, 50800))
+	require.NoError(t, service.AnalyzeGypsumImage("exif-1", file, AnalysisOptions{}))
+
+	result, err := service.GetAnalysisStatus("exif-1")
+	require.NoError(t, err)
+	require.NotNil(t, result.ImageMetadata)
+	assert.Equal(t, 50800.0, result.ImageMetadata.XResolution)
+	require.NotNil(t, result.Calibration)
+	assert.Equal(t, "exif", result.Calibration.Source)
+	assert.Equal(t, 2.0, result.Calibration.PixelsPerMicron)
+	assert.Equal(t, "µm", result.MeasurementUnit)
+	assert.Equal(t, 1000.0, result.TotalArea)
+
+	// A supplied scale wins over the EXIF resolution
+	file = newFileHeader(t, "sample.tif", tiffWithResolution(t, 50800))
+	require.NoError(t, service.AnalyzeGypsumImage("exif-2", file, AnalysisOptions{PixelsPerMicron: 1}))
+	result, err = service.GetAnalysisStatus("exif-2")
+	require.NoError(t, err)
+	assert.Empty(t, result.Calibration.Source)
+	assert.Equal(t, 4000.0, result.TotalArea)
+
+	// The encoder's 72 dpi placeholder is recorded but gives no scale
+	file = newFileHeader(t, "sample.tif", tiffWithResolution(t, 72))
+	require.NoError(t, service.AnalyzeGypsumImage("exif-3", file, AnalysisOptions{}))
+	result, err = service.GetAnalysisStatus("exif-3")
+	require.NoError(t, err)
+	require.NotNil(t, result.ImageMetadata)
+	assert.Nil(t, result.Calibration)
+	assert.Equal(t, "px", result.MeasurementUnit)
+}
@@ -0,0 +1,321 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// ErrWebhookNotFound is returned when no webhook exists for the given ID
+var ErrWebhookNotFound = errors.New("webhook not found")
+
+// ErrInvalidWebhook is returned when a webhook registration has an unusable
+// URL or unknown events
+var ErrInvalidWebhook = errors.New("invalid webhook")
+
+const (
+	// webhookAttempts is the first delivery followed by up to 3 retries
+	webhookAttempts = 4
+	webhookTimeout  = 10 * time.Second
+
+	// webhookDeliveryLimit is the number of attempts listed per webhook
+	webhookDeliveryLimit = 50
+
+	// maxStoredDeliveries is the number of attempts a store keeps per webhook
+	maxStoredDeliveries = 100
+
+	// signatureHeader carries the HMAC-SHA256 of the payload, keyed with the
+	// webhook secret
+	signatureHeader = "X-Gypsum-Signature"
+)
+
+// webhookBackoff is the delay before the first retry; it doubles on each attempt
+var webhookBackoff = time.Second
+
+// WebhookDispatcher posts terminal analysis events to the registered webhooks
+// and records every delivery attempt
+type WebhookDispatcher struct {
+	store  WebhookStore
+	client *http.Client
+	logger *logger.Logger
+
+	// ctx is cancelled once Shutdown gives up waiting, abandoning pending
+	// retries; running counts the dispatches and deliveries still going,
+	// result callbacks included
+	ctx     context.Context
+	cancel  context.CancelFunc
+	mutex   sync.Mutex
+	closed  bool
+	running sync.WaitGroup
+}
+
+// NewWebhookDispatcher creates a dispatcher for the webhooks kept in store.
+// Deliveries are only sent to public addresses.
+func NewWebhookDispatcher(store WebhookStore, logger *logger.Logger) *WebhookDispatcher {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &WebhookDispatcher{
+		store:  store,
+		client: newPublicClient(webhookTimeout),
+		logger: logger,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Dispatch sends the event of a terminal analysis to the webhooks of the
+// client that submitted it. Deliveries happen in the background and never
+// affect the result.
+func (d *WebhookDispatcher) Dispatch(result *models.AnalysisResult) {
+	event, ok := terminalEvent(result.Status)
+	if !ok {
+		return
+	}
+
+	// The caller keeps using result, so deliveries work on a copy
+	snapshot := *result
+	d.spawn(func() { d.dispatch(event, &snapshot) })
+}
+
+// spawn runs fn in the background unless Shutdown has begun
+func (d *WebhookDispatcher) spawn(fn func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if d.closed {
+		return
+	}
+	d.running.Add(1)
+	go func() {
+		defer d.running.Done()
+		fn()
+	}()
+}
+
+// Shutdown stops dispatching new events and waits for the deliveries in
+// progress, retries included. When ctx ends first, their remaining attempts
+// are abandoned and ctx's error is returned once they have stopped.
+func (d *WebhookDispatcher) Shutdown(ctx context.Context) error {
+	d.mutex.Lock()
+	d.closed = true
+	d.mutex.Unlock()
+
+	stopped := make(chan struct{})
+	go func() {
+		d.running.Wait()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+	}
+	d.cancel()
+	<-stopped
+	return ctx.Err()
+}
+
+// dispatch encodes the payload once and delivers it to each subscribed webhook
+// of the analysis owner
+func (d *WebhookDispatcher) dispatch(event string, result *models.AnalysisResult) {
+	webhooks, err := d.store.ListWebhooks(result.Owner)
+	if err != nil {
+		d.logger.WithField("analysis_id", result.ID).WithError(err).Error("Failed to list webhooks")
+		return
+	}
+
+	var body []byte
+	for _, webhook := range webhooks {
+		if !slices.Contains(webhook.Events, event) {
+			continue
+		}
+		if body == nil {
+			body, err = json.Marshal(models.WebhookPayload{Event: event, Timestamp: time.Now(), Analysis: result})
+			if err != nil {
+				d.logger.WithField("analysis_id", result.ID).WithError(err).Error("Failed to encode webhook payload")
+				return
+			}
+		}
+		webhook := webhook
+		d.spawn(func() { d.deliver(webhook, result.ID, event, body) })
+	}
+}
+
+// deliver posts the payload to a webhook, retrying failed deliveries with
+// exponential backoff
+func (d *WebhookDispatcher) deliver(webhook *models.Webhook, analysisID, event string, body []byte) {
+	log := d.logger.WithField("webhook_id", webhook.ID).WithField("analysis_id", analysisID)
+	delay := webhookBackoff
+
+	for attempt := 1; attempt <= webhookAttempts; attempt++ {
+		statusCode, err := d.post(webhook, event, body)
+
+		delivery := &models.WebhookDelivery{
+			ID:         uuid.New().String(),
+			WebhookID:  webhook.ID,
+			AnalysisID: analysisID,
+			Event:      event,
+			Attempt:    attempt,
+			StatusCode: statusCode,
+			Timestamp:  time.Now(),
+		}
+		if err != nil {
+			delivery.Error = err.Error()
+		}
+		if recordErr := d.store.RecordDelivery(delivery); recordErr != nil {
+			log.WithError(recordErr).Warn("Failed to record webhook delivery")
+		}
+
+		if err == nil {
+			log.WithField("attempt", attempt).Info("Webhook delivered")
+			return
+		}
+		log.WithField("attempt", attempt).WithError(err).Warn("Webhook delivery failed")
+
+		if attempt < webhookAttempts {
+			select {
+			case <-time.After(delay):
+			case <-d.ctx.Done():
+				log.WithField("attempt", attempt).Warn("Abandoning webhook delivery on shutdown")
+				return
+			}
+			delay *= 2
+		}
+	}
+
+	log.Error("Giving up on webhook delivery")
+}
+
+// post sends a single signed delivery and returns the response status code.
+// Non-2xx responses are failures.
+func (d *WebhookDispatcher) post(webhook *models.Webhook, event string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(d.ctx, http.MethodPost, webhook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Gypsum-Event", event)
+	if webhook.Secret != "" {
+		req.Header.Set(signatureHeader, signPayload(webhook.Secret, body))
+	}
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("webhook returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// signPayload returns the signature header value for body: "sha256=" followed
+// by the hex HMAC-SHA256 of body keyed with secret
+func signPayload(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// terminalEvent returns the webhook event for a terminal analysis status
+func terminalEvent(status models.AnalysisStatus) (string, bool) {
+	switch status {
+	case models.StatusCompleted:
+		return models.EventAnalysisCompleted, true
+	case models.StatusFailed:
+		return models.EventAnalysisFailed, true
+	case models.StatusCancelled:
+		return models.EventAnalysisCancelled, true
+	}
+	return "", false
+}
+
+// RegisterWebhook validates and stores a webhook registration for owner, the
+// client registering it. A registration without events subscribes to all of
+// them.
+func (s *AnalysisService) RegisterWebhook(owner string, registration models.WebhookRegistration) (*models.Webhook, error) {
+	if err := config.ValidateCallbackURL(registration.URL); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidWebhook, err)
+	}
+
+	events := make([]string, 0, len(registration.Events))
+	for _, event := range registration.Events {
+		if !slices.Contains(models.WebhookEvents, event) {
+			return nil, fmt.Errorf("%w: unknown event %q, expected one of: %s", ErrInvalidWebhook, event, strings.Join(models.WebhookEvents, ", "))
+		}
+		if !slices.Contains(events, event) {
+			events = append(events, event)
+		}
+	}
+	if len(events) == 0 {
+		events = append(events, models.WebhookEvents...)
+	}
+
+	webhook := &models.Webhook{
+		ID:        uuid.New().String(),
+		URL:       registration.URL,
+		Secret:    registration.Secret,
+		Owner:     owner,
+		Events:    events,
+		CreatedAt: time.Now(),
+	}
+	if err := s.store.CreateWebhook(webhook); err != nil {
+		return nil, fmt.Errorf("failed to store webhook: %w", err)
+	}
+
+	s.logger.WithField("webhook_id", webhook.ID).WithField("url", webhook.URL).Info("Webhook registered")
+	return webhook, nil
+}
+
+// ownedWebhook returns the webhook when owner registered it. Webhooks of other
+// clients are reported as ErrWebhookNotFound, so their IDs are not revealed.
+func (s *AnalysisService) ownedWebhook(owner, webhookID string) (*models.Webhook, error) {
+	webhook, err := s.store.GetWebhook(webhookID)
+	if err != nil {
+		return nil, err
+	}
+	if webhook.Owner != owner {
+		return nil, ErrWebhookNotFound
+	}
+	return webhook, nil
+}
+
+// ListWebhookDeliveries returns the most recent delivery attempts to a
+// webhook registered by owner, newest first
+func (s *AnalysisService) ListWebhookDeliveries(owner, webhookID string) ([]*models.WebhookDelivery, error) {
+	if _, err := s.ownedWebhook(owner, webhookID); err != nil {
+		return nil, err
+	}
+	return s.store.ListDeliveries(webhookID, webhookDeliveryLimit)
+}
+
+// DeleteWebhook removes a webhook registered by owner along with its delivery
+// attempts
+func (s *AnalysisService) DeleteWebhook(owner, webhookID string) error {
+	if _, err := s.ownedWebhook(owner, webhookID); err != nil {
+		return err
+	}
+	if err := s.store.DeleteWebhook(webhookID); err != nil {
+		return err
+	}
+
+	s.logger.WithField("webhook_id", webhookID).Info("Webhook deleted")
+	return nil
+}
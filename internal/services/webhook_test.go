@@ -0,0 +1,224 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/models"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testOwner is the client that registers webhooks and submits analyses in tests
+const testOwner = "sub:lab-1"
+
+// addTestWebhook stores a webhook of owner directly, since registration
+// refuses the loopback URLs test servers listen on
+func addTestWebhook(t *testing.T, service *AnalysisService, owner, url, secret string, events ...string) *models.Webhook {
+	t.Helper()
+
+	if len(events) == 0 {
+		events = models.WebhookEvents
+	}
+	webhook := &models.Webhook{ID: uuid.New().String(), URL: url, Secret: secret, Owner: owner, Events: events, CreatedAt: time.Now()}
+	require.NoError(t, service.store.CreateWebhook(webhook))
+	return webhook
+}
+
+func TestRegisterWebhook(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	webhook, err := service.RegisterWebhook(testOwner, models.WebhookRegistration{URL: "https://lims.example.com/hooks"})
+	require.NoError(t, err)
+	assert.NotEmpty(t, webhook.ID)
+	assert.Equal(t, testOwner, webhook.Owner)
+	assert.Equal(t, models.WebhookEvents, webhook.Events, "no events subscribes to all")
+
+	webhook, err = service.RegisterWebhook(testOwner, models.WebhookRegistration{
+		URL:    "http://lims.internal/hooks",
+		Events: []string{models.EventAnalysisFailed, models.EventAnalysisFailed},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []string{models.EventAnalysisFailed}, webhook.Events)
+
+	for _, registration := range []models.WebhookRegistration{
+		{URL: "lims.example.com/hooks"},
+		{URL: "ftp://lims.example.com/hooks"},
+		{URL: "https://lims.example.com/hooks", Events: []string{"analysis.started"}},
+		{URL: "http://localhost:8080/hooks"},
+		{URL: "http://127.0.0.1/hooks"},
+		{URL: "http://10.1.2.3/hooks"},
+		{URL: "http://169.254.169.254/latest/meta-data"},
+	} {
+		_, err := service.RegisterWebhook(testOwner, registration)
+		assert.ErrorIs(t, err, ErrInvalidWebhook, registration)
+	}
+
+	_, err = service.ListWebhookDeliveries(testOwner, "missing")
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+}
+
+func TestWebhook_OwnerOnly(t *testing.T) {
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+
+	webhook, err := service.RegisterWebhook(testOwner, models.WebhookRegistration{URL: "https://lims.example.com/hooks"})
+	require.NoError(t, err)
+
+	// Other clients can neither see nor delete the webhook
+	_, err = service.ListWebhookDeliveries("sub:lab-2", webhook.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.ErrorIs(t, service.DeleteWebhook("ip:192.0.2.1", webhook.ID), ErrWebhookNotFound)
+
+	deliveries, err := service.ListWebhookDeliveries(testOwner, webhook.ID)
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	require.NoError(t, service.DeleteWebhook(testOwner, webhook.ID))
+	_, err = service.ListWebhookDeliveries(testOwner, webhook.ID)
+	assert.ErrorIs(t, err, ErrWebhookNotFound)
+	assert.ErrorIs(t, service.DeleteWebhook(testOwner, webhook.ID), ErrWebhookNotFound)
+}
+
+func TestWebhookDispatcher_SignsAndRetries(t *testing.T) {
+	webhookBackoff = 10 * time.Millisecond
+	defer func() { webhookBackoff = time.Second }()
+
+	var attempts int32
+	received := make(chan *http.Request, webhookAttempts)
+	bodies := make(chan []byte, webhookAttempts)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		received <- r
+		bodies <- body
+		if atomic.AddInt32(&attempts, 1) < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: fijiOutput(82, 30)})
+	webhook := addTestWebhook(t, service, testOwner, server.URL, "s3cret", models.EventAnalysisCompleted)
+	failedOnly := addTestWebhook(t, service, testOwner, server.URL, "", models.EventAnalysisFailed)
+	otherClient := addTestWebhook(t, service, "sub:lab-2", server.URL, "")
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	require.NoError(t, service.AnalyzeGypsumImage("webhook-1", file, AnalysisOptions{Owner: testOwner}))
+
+	var deliveries []*models.WebhookDelivery
+	var err error
+	require.Eventually(t, func() bool {
+		deliveries, err = service.ListWebhookDeliveries(testOwner, webhook.ID)
+		return err == nil && len(deliveries) == 3
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// Newest first: two failures, then the successful retry
+	assert.Equal(t, []int{http.StatusNoContent, http.StatusBadGateway, http.StatusBadGateway},
+		[]int{deliveries[0].StatusCode, deliveries[1].StatusCode, deliveries[2].StatusCode})
+	assert.Equal(t, 3, deliveries[0].Attempt)
+	assert.Empty(t, deliveries[0].Error)
+	assert.NotEmpty(t, deliveries[1].Error)
+	assert.Equal(t, "webhook-1", deliveries[0].AnalysisID)
+
+	// Every attempt carries the same signed payload
+	for i := 0; i < 3; i++ {
+		req, body := <-received, <-bodies
+		assert.Equal(t, signPayload("s3cret", body), req.Header.Get("X-Gypsum-Signature"))
+		assert.Equal(t, models.EventAnalysisCompleted, req.Header.Get("X-Gypsum-Event"))
+
+		var payload models.WebhookPayload
+		require.NoError(t, json.Unmarshal(body, &payload))
+		assert.Equal(t, models.EventAnalysisCompleted, payload.Event)
+		assert.Equal(t, "webhook-1", payload.Analysis.ID)
+		assert.Equal(t, 82.0, payload.Analysis.PurityPercentage)
+	}
+
+	unsent, err := service.ListWebhookDeliveries(testOwner, failedOnly.ID)
+	require.NoError(t, err)
+	assert.Empty(t, unsent, "webhooks only receive the events they subscribed to")
+
+	unsent, err = service.ListWebhookDeliveries("sub:lab-2", otherClient.ID)
+	require.NoError(t, err)
+	assert.Empty(t, unsent, "webhooks only receive the events of their owner's analyses")
+}
+
+func TestWebhookDispatcher_GivesUpAfterRetries(t *testing.T) {
+	webhookBackoff = 10 * time.Millisecond
+	defer func() { webhookBackoff = time.Second }()
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer server.Close()
+
+	service := newTestService(t, &config.Config{}, &fakeRunner{output: "no results"})
+	webhook := addTestWebhook(t, service, testOwner, server.URL, "")
+
+	file := newFileHeader(t, "sample.png", splitImagePNG(t, 80))
+	assert.Error(t, service.AnalyzeGypsumImage("webhook-2", file, AnalysisOptions{Owner: testOwner}))
+
+	// The first delivery and 3 retries, all recorded
+	require.Eventually(t, func() bool {
+		deliveries, err := service.ListWebhookDeliveries(testOwner, webhook.ID)
+		return err == nil && len(deliveries) == webhookAttempts
+	}, 5*time.Second, 10*time.Millisecond)
+	deliveries, err := service.ListWebhookDeliveries(testOwner, webhook.ID)
+	require.NoError(t, err)
+	assert.Equal(t, models.EventAnalysisFailed, deliveries[0].Event)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].StatusCode)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, int32(webhookAttempts), atomic.LoadInt32(&attempts))
+}
+
+func TestWebhookDispatcher_ShutdownAbandonsRetries(t *testing.T) {
+	webhookBackoff = time.Hour
+	defer func() { webhookBackoff = time.Second }()
+
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	service := newTestService(t, &config.Config{}, &fakeRunner{})
+	addTestWebhook(t, service, testOwner, server.URL, "")
+	service.webhooks.Dispatch(&models.AnalysisResult{ID: "webhook-3", Status: models.StatusCompleted, Owner: testOwner})
+	require.Eventually(t, func() bool { return atomic.LoadInt32(&attempts) == 1 }, 5*time.Second, 10*time.Millisecond)
+
+	// The retry waits an hour; an expired deadline abandons it at once
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	start := time.Now()
+	assert.ErrorIs(t, service.webhooks.Shutdown(ctx), context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+
+	// Nothing is dispatched once shut down
+	service.webhooks.Dispatch(&models.AnalysisResult{ID: "webhook-4", Status: models.StatusCompleted, Owner: testOwner})
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&attempts))
+}
+
+func TestPublicClient_RefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("the request should not reach a loopback server")
+	}))
+	defer server.Close()
+
+	_, err := newPublicClient(time.Second).Get(server.URL)
+	assert.ErrorIs(t, err, config.ErrNonPublicAddress)
+}
@@ -0,0 +1,47 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"gypsum-analysis-api/internal/config"
+)
+
+// ErrBlobNotFound is returned by Get when no blob is stored under the key
+var ErrBlobNotFound = errors.New("blob not found")
+
+// BlobStore keeps uploaded files outside the process, so that every instance
+// of the service can reach them
+type BlobStore interface {
+	// Put stores size bytes read from r under key, replacing any existing blob
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+
+	// Get opens the blob stored under key, or returns ErrBlobNotFound. The
+	// caller must close the returned reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+
+	// Delete removes the blob stored under key. Deleting a missing blob is not
+	// an error.
+	Delete(ctx context.Context, key string) error
+
+	// Location describes where the blob under key is stored, for reference
+	Location(key string) string
+}
+
+// NewBlobStore opens the blob store selected by STORAGE_BACKEND
+func NewBlobStore(ctx context.Context, cfg *config.Config) (BlobStore, error) {
+	switch cfg.StorageBackend {
+	case "", "local":
+		return NewLocalBlobStore(cfg.TempDir)
+	case "s3":
+		return NewS3BlobStore(ctx, S3Options{
+			Bucket:         cfg.S3Bucket,
+			Region:         cfg.S3Region,
+			Endpoint:       cfg.S3Endpoint,
+			ForcePathStyle: cfg.S3ForcePathStyle,
+		})
+	}
+	return nil, fmt.Errorf("unknown storage backend %q", cfg.StorageBackend)
+}
@@ -0,0 +1,35 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"gypsum-analysis-api/internal/config"
+)
+
+// CacheStore keeps short-lived copies of values that are expensive to look
+// up, shared by every instance of the service. A cache may drop entries at
+// any time, so callers must be able to recompute a value.
+type CacheStore interface {
+	// Get returns the value cached under key and whether there was one
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+
+	// Set caches value under key for ttl, replacing any cached value
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+
+	// Delete drops the value cached under key. Deleting a missing entry is
+	// not an error.
+	Delete(ctx context.Context, key string) error
+
+	// Close releases the connection to the cache
+	Close() error
+}
+
+// NewCacheStore connects to the cache at REDIS_ADDR. Without one it returns
+// a nil store, and lookups are not cached.
+func NewCacheStore(ctx context.Context, cfg *config.Config) (CacheStore, error) {
+	if cfg.RedisAddr == "" {
+		return nil, nil
+	}
+	return NewRedisCacheStore(ctx, cfg.RedisAddr)
+}
@@ -0,0 +1,56 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLocalBlobStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewLocalBlobStore(dir)
+	require.NoError(t, err)
+
+	content := "image bytes"
+	require.NoError(t, store.Put(ctx, "a1.png", strings.NewReader(content), int64(len(content))))
+	assert.Equal(t, filepath.Join(dir, "a1.png"), store.Location("a1.png"))
+
+	blob, err := store.Get(ctx, "a1.png")
+	require.NoError(t, err)
+	data, err := io.ReadAll(blob)
+	blob.Close()
+	require.NoError(t, err)
+	assert.Equal(t, content, string(data))
+
+	require.NoError(t, store.Delete(ctx, "a1.png"))
+	assert.NoFileExists(t, filepath.Join(dir, "a1.png"))
+	assert.NoError(t, store.Delete(ctx, "a1.png"), "deleting a missing blob")
+
+	_, err = store.Get(ctx, "a1.png")
+	assert.ErrorIs(t, err, ErrBlobNotFound)
+}
+
+func TestLocalBlobStore_RejectsIncompleteAndEscapingBlobs(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewLocalBlobStore(dir)
+	require.NoError(t, err)
+
+	assert.Error(t, store.Put(ctx, "short.png", strings.NewReader("abc"), 10))
+	assert.NoFileExists(t, filepath.Join(dir, "short.png"))
+
+	for _, key := range []string{"", "..", "../escape.png", `sub\escape.png`} {
+		assert.Error(t, store.Put(ctx, key, strings.NewReader("abc"), 3), key)
+	}
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "no partial files are left behind")
+}
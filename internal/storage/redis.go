@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RedisCacheStore caches values as Redis strings that expire on their own
+type RedisCacheStore struct {
+	client *redis.Client
+}
+
+// NewRedisCacheStore connects to the Redis server at addr, a host:port or a
+// redis:// URL, and checks that it answers
+func NewRedisCacheStore(ctx context.Context, addr string) (*RedisCacheStore, error) {
+	opts := &redis.Options{Addr: addr}
+	if parsed, err := redis.ParseURL(addr); err == nil {
+		opts = parsed
+	}
+
+	client := redis.NewClient(opts)
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis at %s: %w", opts.Addr, err)
+	}
+	return &RedisCacheStore{client: client}, nil
+}
+
+// Get reads the string under key; a missing key is a miss, not an error
+func (r *RedisCacheStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := r.client.Get(ctx, key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set writes the string under key with an expiry of ttl
+func (r *RedisCacheStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return r.client.Set(ctx, key, value, ttl).Err()
+}
+
+// Delete removes key
+func (r *RedisCacheStore) Delete(ctx context.Context, key string) error {
+	return r.client.Del(ctx, key).Err()
+}
+
+// Close closes the client's connections
+func (r *RedisCacheStore) Close() error {
+	return r.client.Close()
+}
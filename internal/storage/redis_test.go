@@ -0,0 +1,50 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestRedisCacheStore runs against the Redis server in TEST_REDIS_ADDR, if set
+func TestRedisCacheStore(t *testing.T) {
+	addr := os.Getenv("TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("TEST_REDIS_ADDR not set")
+	}
+
+	ctx := context.Background()
+	cache, err := NewRedisCacheStore(ctx, addr)
+	require.NoError(t, err)
+	defer cache.Close()
+
+	key := "gypsum:test:" + t.Name()
+	defer cache.Delete(ctx, key)
+
+	_, ok, err := cache.Get(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, cache.Set(ctx, key, []byte("cached"), time.Second))
+	value, ok, err := cache.Get(ctx, key)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("cached"), value)
+
+	require.NoError(t, cache.Delete(ctx, key))
+	_, ok, err = cache.Get(ctx, key)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestNewRedisCacheStore_Unreachable(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	_, err := NewRedisCacheStore(ctx, "127.0.0.1:1")
+	assert.Error(t, err)
+}
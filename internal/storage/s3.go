@@ -0,0 +1,97 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awsconfig "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// S3Options selects the bucket of an S3BlobStore. Endpoint and ForcePathStyle
+// are only needed for S3-compatible services such as MinIO.
+type S3Options struct {
+	Bucket         string
+	Region         string
+	Endpoint       string
+	ForcePathStyle bool
+}
+
+// S3BlobStore keeps blobs as objects in an S3 bucket, named by their key
+type S3BlobStore struct {
+	client *s3.Client
+	bucket string
+}
+
+// NewS3BlobStore creates a store for opts.Bucket. Credentials and, unless
+// opts.Region is set, the region come from the standard AWS environment
+// variables, shared config files or instance role.
+func NewS3BlobStore(ctx context.Context, opts S3Options) (*S3BlobStore, error) {
+	var loadOpts []func(*awsconfig.LoadOptions) error
+	if opts.Region != "" {
+		loadOpts = append(loadOpts, awsconfig.WithRegion(opts.Region))
+	}
+	awsCfg, err := awsconfig.LoadDefaultConfig(ctx, loadOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load AWS configuration: %w", err)
+	}
+
+	client := s3.NewFromConfig(awsCfg, func(o *s3.Options) {
+		if opts.Endpoint != "" {
+			o.BaseEndpoint = aws.String(opts.Endpoint)
+		}
+		o.UsePathStyle = opts.ForcePathStyle
+	})
+	return &S3BlobStore{client: client, bucket: opts.Bucket}, nil
+}
+
+// Put uploads the blob as an object
+func (b *S3BlobStore) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	_, err := b.client.PutObject(ctx, &s3.PutObjectInput{
+		Bucket:        aws.String(b.bucket),
+		Key:           aws.String(key),
+		Body:          r,
+		ContentLength: aws.Int64(size),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to upload %s: %w", b.Location(key), err)
+	}
+	return nil
+}
+
+// Get streams the blob's object
+func (b *S3BlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := b.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	var noSuchKey *types.NoSuchKey
+	if errors.As(err, &noSuchKey) {
+		return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, b.Location(key))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", b.Location(key), err)
+	}
+	return out.Body, nil
+}
+
+// Delete removes the blob's object; S3 reports success for missing objects
+func (b *S3BlobStore) Delete(ctx context.Context, key string) error {
+	_, err := b.client.DeleteObject(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(b.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", b.Location(key), err)
+	}
+	return nil
+}
+
+// Location returns the blob's s3:// URL
+func (b *S3BlobStore) Location(key string) string {
+	return fmt.Sprintf("s3://%s/%s", b.bucket, key)
+}
@@ -0,0 +1,56 @@
+// Package tracing configures OpenTelemetry trace export for the API
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"gypsum-analysis-api/internal/config"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// ServiceName identifies this service in exported traces
+const ServiceName = "gypsum-analysis-api"
+
+// Span attributes recorded across the analysis pipeline
+const (
+	AnalysisID       = attribute.Key("analysis.id")
+	ImageSizeBytes   = attribute.Key("image.size_bytes")
+	FijiExitCode     = attribute.Key("fiji.exit_code")
+	PurityPercentage = attribute.Key("analysis.purity_percentage")
+)
+
+// Setup installs the global tracer provider and the W3C trace context
+// propagator. Spans are exported in batches to the OTLP endpoint; while no
+// endpoint is configured the default no-op provider is kept. The returned
+// function flushes pending spans and must be called on shutdown.
+func Setup(ctx context.Context, cfg *config.Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint)}
+	if cfg.OTLPInsecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
@@ -11,9 +12,16 @@ import (
 
 	"gypsum-analysis-api/internal/api"
 	"gypsum-analysis-api/internal/config"
+	"gypsum-analysis-api/internal/grpc"
 	"gypsum-analysis-api/internal/logger"
+	"gypsum-analysis-api/internal/metrics"
+	"gypsum-analysis-api/internal/middleware"
+	"gypsum-analysis-api/internal/services"
+	"gypsum-analysis-api/internal/storage"
+	"gypsum-analysis-api/internal/tracing"
 
 	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 func main() {
@@ -27,6 +35,52 @@ func main() {
 	logger := logger.New(cfg.LogLevel)
 	logger.Info("Starting Gypsum Analysis API")
 
+	// Open the result store, applying database migrations
+	store, err := services.NewResultStore(cfg)
+	if err != nil {
+		logger.Fatalf("Failed to open result store: %v", err)
+	}
+	defer store.Close()
+	if cfg.DatabaseURL == "" {
+		logger.Warn("DATABASE_URL is not set; analysis results are kept in memory and lost on restart")
+	}
+
+	// Open the blob store that keeps uploaded images
+	blobs, err := storage.NewBlobStore(context.Background(), cfg)
+	if err != nil {
+		logger.Fatalf("Failed to open blob store: %v", err)
+	}
+	if cfg.StorageBackend == "local" {
+		logger.Warn("STORAGE_BACKEND is local; uploaded images are only reachable from this instance")
+	}
+
+	// Cache status lookups in Redis when it is configured
+	cache, err := storage.NewCacheStore(context.Background(), cfg)
+	if err != nil {
+		logger.Fatalf("Failed to open cache: %v", err)
+	}
+	if cache != nil {
+		defer cache.Close()
+	}
+
+	// Export traces when an OTLP collector is configured
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
+	if err != nil {
+		logger.Fatalf("Failed to set up tracing: %v", err)
+	}
+
+	if cfg.EngineFallback {
+		logger.Warnf("Fiji executable not found at %s; analyses run on the go-native engine", cfg.FijiPath)
+	}
+
+	if !cfg.AuthEnabled {
+		logger.Warn("AUTH_ENABLED is false; the API accepts unauthenticated requests")
+	}
+
+	// Bound the number of concurrent Fiji processes
+	m := metrics.New()
+	pool := services.NewWorkerPool(cfg.MaxWorkers, cfg.QueueSize, m.QueueDepth)
+
 	// Set Gin mode
 	if cfg.Environment == "production" {
 		gin.SetMode(gin.ReleaseMode)
@@ -34,11 +88,20 @@ func main() {
 
 	// Create router
 	router := gin.New()
+	// Only the configured proxies may report the client address, which keys
+	// the rate limits; by default every X-Forwarded-For header is ignored
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatalf("Invalid trusted proxies: %v", err)
+	}
 	router.Use(gin.Recovery())
-	router.Use(gin.Logger())
+	router.Use(middleware.StructuredLogger(logger))
+
+	// Each client, by token subject or else IP, gets its own budget for starting analyses
+	submitLimiter := middleware.NewRateLimiter(cfg.RateLimitRPS, cfg.RateLimitBurst, time.Hour)
 
 	// Initialize API routes
-	api.SetupRoutes(router, cfg, logger)
+	analysisService := api.SetupRoutes(router, cfg, logger, store, blobs, cache, pool, submitLimiter, m)
+	defer analysisService.Close()
 
 	// Create HTTP server
 	server := &http.Server{
@@ -49,14 +112,64 @@ func main() {
 		IdleTimeout:  120 * time.Second,
 	}
 
+	// With TLS, certificates for TLS_DOMAIN are obtained and renewed from
+	// Let's Encrypt. Port 80 answers the ACME HTTP-01 challenges ahead of
+	// everything else and permanently redirects other requests to HTTPS.
+	var redirectServer *http.Server
+	if cfg.TLSEnabled {
+		certManager := &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(cfg.TLSDomain),
+			Cache:      autocert.DirCache(cfg.TLSCertDir),
+		}
+		server.TLSConfig = certManager.TLSConfig()
+
+		redirectServer = &http.Server{
+			Addr:         ":80",
+			Handler:      certManager.HTTPHandler(redirectToHTTPS(cfg.TLSDomain, cfg.Port)),
+			ReadTimeout:  server.ReadTimeout,
+			WriteTimeout: server.WriteTimeout,
+			IdleTimeout:  server.IdleTimeout,
+		}
+		go func() {
+			logger.Info("HTTP redirect and ACME challenge server starting on port 80")
+			if err := redirectServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				logger.Fatalf("Failed to start redirect server: %v", err)
+			}
+		}()
+	}
+
 	// Start server in a goroutine
 	go func() {
-		logger.Infof("Server starting on port %s", cfg.Port)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if cfg.TLSEnabled {
+			logger.Infof("HTTPS server starting on port %s for %s", cfg.Port, cfg.TLSDomain)
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			logger.Infof("Server starting on port %s", cfg.Port)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			logger.Fatalf("Failed to start server: %v", err)
 		}
 	}()
 
+	// Serve the gRPC interface on its own port, backed by the same service and
+	// with the same certificate as the HTTPS server
+	grpcServer := grpc.NewServer(analysisService, cfg, logger, server.TLSConfig, submitLimiter)
+	if cfg.GRPCPort != "" {
+		listener, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+		if err != nil {
+			logger.Fatalf("Failed to listen on gRPC port %s: %v", cfg.GRPCPort, err)
+		}
+		go func() {
+			logger.Infof("gRPC server starting on port %s", cfg.GRPCPort)
+			if err := grpcServer.Serve(listener); err != nil {
+				logger.Fatalf("Failed to start gRPC server: %v", err)
+			}
+		}()
+	}
+
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
@@ -72,6 +185,34 @@ func main() {
 	if err := server.Shutdown(ctx); err != nil {
 		logger.Fatalf("Server forced to shutdown: %v", err)
 	}
+	if redirectServer != nil {
+		redirectServer.Shutdown(ctx)
+	}
+	grpcServer.GracefulStop()
+	submitLimiter.Stop()
+
+	// Let queued and running analyses finish before closing the store; those
+	// still running at the deadline are cancelled
+	logger.Info("Waiting for queued analyses to finish...")
+	if err := analysisService.Shutdown(ctx); err != nil {
+		logger.Errorf("Analyses cancelled at shutdown: %v", err)
+	}
+	if err := shutdownTracing(ctx); err != nil {
+		logger.Errorf("Failed to flush traces: %v", err)
+	}
 
 	logger.Info("Server exited")
 }
+
+// redirectToHTTPS permanently redirects requests to the same path over HTTPS
+// on domain, the host the certificate is issued for. The request's Host header
+// is not used, so the redirect cannot be pointed at another site.
+func redirectToHTTPS(domain, httpsPort string) http.Handler {
+	host := domain
+	if httpsPort != "443" {
+		host = net.JoinHostPort(domain, httpsPort)
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
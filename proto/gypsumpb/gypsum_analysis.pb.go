@@ -0,0 +1,2455 @@
+// gRPC interface of the Gypsum Analysis API. It exposes the same analyses as
+// the REST API under /api/v1/analysis.
+//
+// Regenerate the Go code in gypsumpb/ from the repository root with:
+//
+//   protoc --go_out=. --go_opt=module=gypsum-analysis-api \
+//     --go-grpc_out=. --go-grpc_opt=module=gypsum-analysis-api \
+//     proto/gypsum_analysis.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.31.0
+// 	protoc        v4.25.3
+// source: proto/gypsum_analysis.proto
+
+package gypsumpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+type AnalyzeRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	// Image content and its file name; the extension selects the image type
+	Image    []byte `protobuf:"bytes,1,opt,name=image,proto3" json:"image,omitempty"`
+	Filename string `protobuf:"bytes,2,opt,name=filename,proto3" json:"filename,omitempty"`
+	// Optional submission settings, as the form fields of the REST upload
+	Profile         string            `protobuf:"bytes,3,opt,name=profile,proto3" json:"profile,omitempty"`
+	SampleGroup     string            `protobuf:"bytes,4,opt,name=sample_group,json=sampleGroup,proto3" json:"sample_group,omitempty"`
+	CallbackUrl     string            `protobuf:"bytes,5,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`
+	Metadata        map[string]string `protobuf:"bytes,6,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	PixelsPerMicron float64           `protobuf:"fixed64,7,opt,name=pixels_per_micron,json=pixelsPerMicron,proto3" json:"pixels_per_micron,omitempty"`
+	ThresholdMethod string            `protobuf:"bytes,8,opt,name=threshold_method,json=thresholdMethod,proto3" json:"threshold_method,omitempty"`
+	MinParticleSize *float64          `protobuf:"fixed64,9,opt,name=min_particle_size,json=minParticleSize,proto3,oneof" json:"min_particle_size,omitempty"`
+	MaxParticleSize *float64          `protobuf:"fixed64,10,opt,name=max_particle_size,json=maxParticleSize,proto3,oneof" json:"max_particle_size,omitempty"`
+	MinCircularity  *float64          `protobuf:"fixed64,11,opt,name=min_circularity,json=minCircularity,proto3,oneof" json:"min_circularity,omitempty"`
+	// Optional rectangle the image is cropped to before analysis
+	Roi *Rectangle `protobuf:"bytes,12,opt,name=roi,proto3" json:"roi,omitempty"`
+	// Optional analysis timeout in seconds, clamped to the configured bounds
+	TimeoutSeconds int32 `protobuf:"varint,13,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	// Optional scale bar, an alternative to pixels_per_micron: its length in
+	// microns and in pixels
+	ScaleBarLength float64 `protobuf:"fixed64,14,opt,name=scale_bar_length,json=scaleBarLength,proto3" json:"scale_bar_length,omitempty"`
+	ScaleBarPixels float64 `protobuf:"fixed64,15,opt,name=scale_bar_pixels,json=scaleBarPixels,proto3" json:"scale_bar_pixels,omitempty"`
+	// Optional description of the physical sample
+	SampleMetadata *SampleMetadata `protobuf:"bytes,16,opt,name=sample_metadata,json=sampleMetadata,proto3" json:"sample_metadata,omitempty"`
+	// Optional preprocessing overrides: Gaussian blur sigma and the percentage
+	// of pixels saturated by contrast enhancement
+	GaussianSigma      *float64 `protobuf:"fixed64,17,opt,name=gaussian_sigma,json=gaussianSigma,proto3,oneof" json:"gaussian_sigma,omitempty"`
+	ContrastSaturation *float64 `protobuf:"fixed64,18,opt,name=contrast_saturation,json=contrastSaturation,proto3,oneof" json:"contrast_saturation,omitempty"`
+	// Optional stored profile whose macro settings replace the defaults
+	ProfileId string `protobuf:"bytes,19,opt,name=profile_id,json=profileId,proto3" json:"profile_id,omitempty"`
+}
+
+func (x *AnalyzeRequest) Reset() {
+	*x = AnalyzeRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[0]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AnalyzeRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeRequest) ProtoMessage() {}
+
+func (x *AnalyzeRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[0]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeRequest.ProtoReflect.Descriptor instead.
+func (*AnalyzeRequest) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *AnalyzeRequest) GetImage() []byte {
+	if x != nil {
+		return x.Image
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetSampleGroup() string {
+	if x != nil {
+		return x.SampleGroup
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetCallbackUrl() string {
+	if x != nil {
+		return x.CallbackUrl
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetPixelsPerMicron() float64 {
+	if x != nil {
+		return x.PixelsPerMicron
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetThresholdMethod() string {
+	if x != nil {
+		return x.ThresholdMethod
+	}
+	return ""
+}
+
+func (x *AnalyzeRequest) GetMinParticleSize() float64 {
+	if x != nil && x.MinParticleSize != nil {
+		return *x.MinParticleSize
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetMaxParticleSize() float64 {
+	if x != nil && x.MaxParticleSize != nil {
+		return *x.MaxParticleSize
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetMinCircularity() float64 {
+	if x != nil && x.MinCircularity != nil {
+		return *x.MinCircularity
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetRoi() *Rectangle {
+	if x != nil {
+		return x.Roi
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetScaleBarLength() float64 {
+	if x != nil {
+		return x.ScaleBarLength
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetScaleBarPixels() float64 {
+	if x != nil {
+		return x.ScaleBarPixels
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetSampleMetadata() *SampleMetadata {
+	if x != nil {
+		return x.SampleMetadata
+	}
+	return nil
+}
+
+func (x *AnalyzeRequest) GetGaussianSigma() float64 {
+	if x != nil && x.GaussianSigma != nil {
+		return *x.GaussianSigma
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetContrastSaturation() float64 {
+	if x != nil && x.ContrastSaturation != nil {
+		return *x.ContrastSaturation
+	}
+	return 0
+}
+
+func (x *AnalyzeRequest) GetProfileId() string {
+	if x != nil {
+		return x.ProfileId
+	}
+	return ""
+}
+
+type AnalyzeResponse struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	AnalysisId string `protobuf:"bytes,1,opt,name=analysis_id,json=analysisId,proto3" json:"analysis_id,omitempty"`
+	Status     string `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+}
+
+func (x *AnalyzeResponse) Reset() {
+	*x = AnalyzeResponse{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[1]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AnalyzeResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalyzeResponse) ProtoMessage() {}
+
+func (x *AnalyzeResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[1]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalyzeResponse.ProtoReflect.Descriptor instead.
+func (*AnalyzeResponse) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *AnalyzeResponse) GetAnalysisId() string {
+	if x != nil {
+		return x.AnalysisId
+	}
+	return ""
+}
+
+func (x *AnalyzeResponse) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+type StatusRequest struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	AnalysisId string `protobuf:"bytes,1,opt,name=analysis_id,json=analysisId,proto3" json:"analysis_id,omitempty"`
+}
+
+func (x *StatusRequest) Reset() {
+	*x = StatusRequest{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[2]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *StatusRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*StatusRequest) ProtoMessage() {}
+
+func (x *StatusRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[2]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use StatusRequest.ProtoReflect.Descriptor instead.
+func (*StatusRequest) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *StatusRequest) GetAnalysisId() string {
+	if x != nil {
+		return x.AnalysisId
+	}
+	return ""
+}
+
+// AnalysisResult mirrors models.AnalysisResult. Optional fields are unset
+// where the JSON representation omits them.
+type AnalysisResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Id                        string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Status                    string                 `protobuf:"bytes,2,opt,name=status,proto3" json:"status,omitempty"`
+	CreatedAt                 *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	CompletedAt               *timestamppb.Timestamp `protobuf:"bytes,4,opt,name=completed_at,json=completedAt,proto3" json:"completed_at,omitempty"`
+	Error                     string                 `protobuf:"bytes,5,opt,name=error,proto3" json:"error,omitempty"`
+	FailureCategory           string                 `protobuf:"bytes,6,opt,name=failure_category,json=failureCategory,proto3" json:"failure_category,omitempty"`
+	Progress                  int32                  `protobuf:"varint,7,opt,name=progress,proto3" json:"progress,omitempty"`
+	PurityPercentage          float64                `protobuf:"fixed64,8,opt,name=purity_percentage,json=purityPercentage,proto3" json:"purity_percentage,omitempty"`
+	Confidence                float64                `protobuf:"fixed64,9,opt,name=confidence,proto3" json:"confidence,omitempty"`
+	ImagePath                 string                 `protobuf:"bytes,10,opt,name=image_path,json=imagePath,proto3" json:"image_path,omitempty"`
+	ImageSize                 int64                  `protobuf:"varint,11,opt,name=image_size,json=imageSize,proto3" json:"image_size,omitempty"`
+	AnalysisTimeMs            int64                  `protobuf:"varint,12,opt,name=analysis_time_ms,json=analysisTimeMs,proto3" json:"analysis_time_ms,omitempty"`
+	ImageRemoved              bool                   `protobuf:"varint,13,opt,name=image_removed,json=imageRemoved,proto3" json:"image_removed,omitempty"`
+	ImageSha256               string                 `protobuf:"bytes,14,opt,name=image_sha256,json=imageSha256,proto3" json:"image_sha256,omitempty"`
+	ImageFormat               string                 `protobuf:"bytes,15,opt,name=image_format,json=imageFormat,proto3" json:"image_format,omitempty"`
+	ImageWidth                int32                  `protobuf:"varint,16,opt,name=image_width,json=imageWidth,proto3" json:"image_width,omitempty"`
+	ImageHeight               int32                  `protobuf:"varint,17,opt,name=image_height,json=imageHeight,proto3" json:"image_height,omitempty"`
+	GypsumContentPercentage   float64                `protobuf:"fixed64,18,opt,name=gypsum_content_percentage,json=gypsumContentPercentage,proto3" json:"gypsum_content_percentage,omitempty"`
+	ImpurityContentPercentage float64                `protobuf:"fixed64,19,opt,name=impurity_content_percentage,json=impurityContentPercentage,proto3" json:"impurity_content_percentage,omitempty"`
+	CalciteContentPercentage  float64                `protobuf:"fixed64,20,opt,name=calcite_content_percentage,json=calciteContentPercentage,proto3" json:"calcite_content_percentage,omitempty"`
+	QuartzContentPercentage   float64                `protobuf:"fixed64,21,opt,name=quartz_content_percentage,json=quartzContentPercentage,proto3" json:"quartz_content_percentage,omitempty"`
+	OtherMineralsPercentage   float64                `protobuf:"fixed64,22,opt,name=other_minerals_percentage,json=otherMineralsPercentage,proto3" json:"other_minerals_percentage,omitempty"`
+	// Lower threshold bound alone; use threshold_lower and threshold_upper
+	//
+	// Deprecated: Marked as deprecated in proto/gypsum_analysis.proto.
+	ThresholdValue         float64                `protobuf:"fixed64,23,opt,name=threshold_value,json=thresholdValue,proto3" json:"threshold_value,omitempty"`
+	ParticleCount          int32                  `protobuf:"varint,24,opt,name=particle_count,json=particleCount,proto3" json:"particle_count,omitempty"`
+	AverageParticleSizeUm  float64                `protobuf:"fixed64,25,opt,name=average_particle_size_um,json=averageParticleSizeUm,proto3" json:"average_particle_size_um,omitempty"`
+	TotalArea              float64                `protobuf:"fixed64,26,opt,name=total_area,json=totalArea,proto3" json:"total_area,omitempty"`
+	ImageArea              float64                `protobuf:"fixed64,27,opt,name=image_area,json=imageArea,proto3" json:"image_area,omitempty"`
+	Calibration            *Calibration           `protobuf:"bytes,28,opt,name=calibration,proto3" json:"calibration,omitempty"`
+	RoiFile                string                 `protobuf:"bytes,29,opt,name=roi_file,json=roiFile,proto3" json:"roi_file,omitempty"`
+	RoiResults             []*ROIResult           `protobuf:"bytes,30,rep,name=roi_results,json=roiResults,proto3" json:"roi_results,omitempty"`
+	Tiles                  []*TileResult          `protobuf:"bytes,31,rep,name=tiles,proto3" json:"tiles,omitempty"`
+	SpacingStats           *SpacingStats          `protobuf:"bytes,32,opt,name=spacing_stats,json=spacingStats,proto3" json:"spacing_stats,omitempty"`
+	Filename               string                 `protobuf:"bytes,33,opt,name=filename,proto3" json:"filename,omitempty"`
+	BatchId                string                 `protobuf:"bytes,34,opt,name=batch_id,json=batchId,proto3" json:"batch_id,omitempty"`
+	Profile                string                 `protobuf:"bytes,35,opt,name=profile,proto3" json:"profile,omitempty"`
+	SampleGroup            string                 `protobuf:"bytes,36,opt,name=sample_group,json=sampleGroup,proto3" json:"sample_group,omitempty"`
+	CallbackUrl            string                 `protobuf:"bytes,37,opt,name=callback_url,json=callbackUrl,proto3" json:"callback_url,omitempty"`
+	Metadata               map[string]string      `protobuf:"bytes,38,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Parameters             *MacroParams           `protobuf:"bytes,39,opt,name=parameters,proto3" json:"parameters,omitempty"`
+	FijiVersion            string                 `protobuf:"bytes,40,opt,name=fiji_version,json=fijiVersion,proto3" json:"fiji_version,omitempty"`
+	ShadowPurityPercentage *float64               `protobuf:"fixed64,41,opt,name=shadow_purity_percentage,json=shadowPurityPercentage,proto3,oneof" json:"shadow_purity_percentage,omitempty"`
+	Warnings               []string               `protobuf:"bytes,42,rep,name=warnings,proto3" json:"warnings,omitempty"`
+	ReviewNote             string                 `protobuf:"bytes,43,opt,name=review_note,json=reviewNote,proto3" json:"review_note,omitempty"`
+	ReviewedBy             string                 `protobuf:"bytes,44,opt,name=reviewed_by,json=reviewedBy,proto3" json:"reviewed_by,omitempty"`
+	ReviewedAt             *timestamppb.Timestamp `protobuf:"bytes,45,opt,name=reviewed_at,json=reviewedAt,proto3" json:"reviewed_at,omitempty"`
+	ManualOverride         bool                   `protobuf:"varint,46,opt,name=manual_override,json=manualOverride,proto3" json:"manual_override,omitempty"`
+	ManualOverridePurity   *float64               `protobuf:"fixed64,47,opt,name=manual_override_purity,json=manualOverridePurity,proto3,oneof" json:"manual_override_purity,omitempty"`
+	PerceptualHash         string                 `protobuf:"bytes,48,opt,name=perceptual_hash,json=perceptualHash,proto3" json:"perceptual_hash,omitempty"`
+	DuplicateOf            string                 `protobuf:"bytes,49,opt,name=duplicate_of,json=duplicateOf,proto3" json:"duplicate_of,omitempty"`
+	Roi                    *Rectangle             `protobuf:"bytes,50,opt,name=roi,proto3" json:"roi,omitempty"`
+	TimeoutSeconds         int32                  `protobuf:"varint,51,opt,name=timeout_seconds,json=timeoutSeconds,proto3" json:"timeout_seconds,omitempty"`
+	// Unit of areas, particle sizes and spacings: "µm" when calibrated, else "px"
+	MeasurementUnit string `protobuf:"bytes,52,opt,name=measurement_unit,json=measurementUnit,proto3" json:"measurement_unit,omitempty"`
+	// Set when values estimated from the image replaced measurements Fiji did not report
+	Estimated bool `protobuf:"varint,53,opt,name=estimated,proto3" json:"estimated,omitempty"`
+	// Location of the PNG outlining the counted particles
+	OverlayPath string `protobuf:"bytes,54,opt,name=overlay_path,json=overlayPath,proto3" json:"overlay_path,omitempty"`
+	// HMAC-SHA256 over the measurements, to detect later modification
+	ResultSignature     string                 `protobuf:"bytes,55,opt,name=result_signature,json=resultSignature,proto3" json:"result_signature,omitempty"`
+	SignatureKeyVersion string                 `protobuf:"bytes,56,opt,name=signature_key_version,json=signatureKeyVersion,proto3" json:"signature_key_version,omitempty"`
+	SignedAt            *timestamppb.Timestamp `protobuf:"bytes,57,opt,name=signed_at,json=signedAt,proto3" json:"signed_at,omitempty"`
+	SampleMetadata      *SampleMetadata        `protobuf:"bytes,58,opt,name=sample_metadata,json=sampleMetadata,proto3" json:"sample_metadata,omitempty"`
+	// Times the analysis was re-enqueued after Fiji crashed or stalled
+	RetryCount int32 `protobuf:"varint,59,opt,name=retry_count,json=retryCount,proto3" json:"retry_count,omitempty"`
+	// Camera or microscope information from the image's EXIF block
+	ImageMetadata *ImageMetadata `protobuf:"bytes,60,opt,name=image_metadata,json=imageMetadata,proto3" json:"image_metadata,omitempty"`
+	// Per-slice purity when the image was a multi-page TIFF stack
+	PerSlice []*SliceResult `protobuf:"bytes,61,rep,name=per_slice,json=perSlice,proto3" json:"per_slice,omitempty"`
+	// Stored profile the macro parameters were taken from, if any
+	ProfileId string `protobuf:"bytes,62,opt,name=profile_id,json=profileId,proto3" json:"profile_id,omitempty"`
+	// Engine that ran the analysis: "fiji" or "go-native"
+	AnalysisEngine string `protobuf:"bytes,63,opt,name=analysis_engine,json=analysisEngine,proto3" json:"analysis_engine,omitempty"`
+	// Signals the confidence was computed from
+	ConfidenceFactors *ConfidenceFactors `protobuf:"bytes,64,opt,name=confidence_factors,json=confidenceFactors,proto3" json:"confidence_factors,omitempty"`
+	// Gray levels the image was segmented between, inclusive; unset when not reported
+	ThresholdLower *float64 `protobuf:"fixed64,65,opt,name=threshold_lower,json=thresholdLower,proto3,oneof" json:"threshold_lower,omitempty"`
+	ThresholdUpper *float64 `protobuf:"fixed64,66,opt,name=threshold_upper,json=thresholdUpper,proto3,oneof" json:"threshold_upper,omitempty"`
+}
+
+func (x *AnalysisResult) Reset() {
+	*x = AnalysisResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[3]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *AnalysisResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*AnalysisResult) ProtoMessage() {}
+
+func (x *AnalysisResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[3]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use AnalysisResult.ProtoReflect.Descriptor instead.
+func (*AnalysisResult) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *AnalysisResult) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetStatus() string {
+	if x != nil {
+		return x.Status
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetCompletedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CompletedAt
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetFailureCategory() string {
+	if x != nil {
+		return x.FailureCategory
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetProgress() int32 {
+	if x != nil {
+		return x.Progress
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetPurityPercentage() float64 {
+	if x != nil {
+		return x.PurityPercentage
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetConfidence() float64 {
+	if x != nil {
+		return x.Confidence
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetImagePath() string {
+	if x != nil {
+		return x.ImagePath
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetImageSize() int64 {
+	if x != nil {
+		return x.ImageSize
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetAnalysisTimeMs() int64 {
+	if x != nil {
+		return x.AnalysisTimeMs
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetImageRemoved() bool {
+	if x != nil {
+		return x.ImageRemoved
+	}
+	return false
+}
+
+func (x *AnalysisResult) GetImageSha256() string {
+	if x != nil {
+		return x.ImageSha256
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetImageFormat() string {
+	if x != nil {
+		return x.ImageFormat
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetImageWidth() int32 {
+	if x != nil {
+		return x.ImageWidth
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetImageHeight() int32 {
+	if x != nil {
+		return x.ImageHeight
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetGypsumContentPercentage() float64 {
+	if x != nil {
+		return x.GypsumContentPercentage
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetImpurityContentPercentage() float64 {
+	if x != nil {
+		return x.ImpurityContentPercentage
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetCalciteContentPercentage() float64 {
+	if x != nil {
+		return x.CalciteContentPercentage
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetQuartzContentPercentage() float64 {
+	if x != nil {
+		return x.QuartzContentPercentage
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetOtherMineralsPercentage() float64 {
+	if x != nil {
+		return x.OtherMineralsPercentage
+	}
+	return 0
+}
+
+// Deprecated: Marked as deprecated in proto/gypsum_analysis.proto.
+func (x *AnalysisResult) GetThresholdValue() float64 {
+	if x != nil {
+		return x.ThresholdValue
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetParticleCount() int32 {
+	if x != nil {
+		return x.ParticleCount
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetAverageParticleSizeUm() float64 {
+	if x != nil {
+		return x.AverageParticleSizeUm
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetTotalArea() float64 {
+	if x != nil {
+		return x.TotalArea
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetImageArea() float64 {
+	if x != nil {
+		return x.ImageArea
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetCalibration() *Calibration {
+	if x != nil {
+		return x.Calibration
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetRoiFile() string {
+	if x != nil {
+		return x.RoiFile
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetRoiResults() []*ROIResult {
+	if x != nil {
+		return x.RoiResults
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetTiles() []*TileResult {
+	if x != nil {
+		return x.Tiles
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetSpacingStats() *SpacingStats {
+	if x != nil {
+		return x.SpacingStats
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetFilename() string {
+	if x != nil {
+		return x.Filename
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetBatchId() string {
+	if x != nil {
+		return x.BatchId
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetProfile() string {
+	if x != nil {
+		return x.Profile
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetSampleGroup() string {
+	if x != nil {
+		return x.SampleGroup
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetCallbackUrl() string {
+	if x != nil {
+		return x.CallbackUrl
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetParameters() *MacroParams {
+	if x != nil {
+		return x.Parameters
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetFijiVersion() string {
+	if x != nil {
+		return x.FijiVersion
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetShadowPurityPercentage() float64 {
+	if x != nil && x.ShadowPurityPercentage != nil {
+		return *x.ShadowPurityPercentage
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetWarnings() []string {
+	if x != nil {
+		return x.Warnings
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetReviewNote() string {
+	if x != nil {
+		return x.ReviewNote
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetReviewedBy() string {
+	if x != nil {
+		return x.ReviewedBy
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetReviewedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.ReviewedAt
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetManualOverride() bool {
+	if x != nil {
+		return x.ManualOverride
+	}
+	return false
+}
+
+func (x *AnalysisResult) GetManualOverridePurity() float64 {
+	if x != nil && x.ManualOverridePurity != nil {
+		return *x.ManualOverridePurity
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetPerceptualHash() string {
+	if x != nil {
+		return x.PerceptualHash
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetDuplicateOf() string {
+	if x != nil {
+		return x.DuplicateOf
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetRoi() *Rectangle {
+	if x != nil {
+		return x.Roi
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetTimeoutSeconds() int32 {
+	if x != nil {
+		return x.TimeoutSeconds
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetMeasurementUnit() string {
+	if x != nil {
+		return x.MeasurementUnit
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetEstimated() bool {
+	if x != nil {
+		return x.Estimated
+	}
+	return false
+}
+
+func (x *AnalysisResult) GetOverlayPath() string {
+	if x != nil {
+		return x.OverlayPath
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetResultSignature() string {
+	if x != nil {
+		return x.ResultSignature
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetSignatureKeyVersion() string {
+	if x != nil {
+		return x.SignatureKeyVersion
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetSignedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.SignedAt
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetSampleMetadata() *SampleMetadata {
+	if x != nil {
+		return x.SampleMetadata
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetRetryCount() int32 {
+	if x != nil {
+		return x.RetryCount
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetImageMetadata() *ImageMetadata {
+	if x != nil {
+		return x.ImageMetadata
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetPerSlice() []*SliceResult {
+	if x != nil {
+		return x.PerSlice
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetProfileId() string {
+	if x != nil {
+		return x.ProfileId
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetAnalysisEngine() string {
+	if x != nil {
+		return x.AnalysisEngine
+	}
+	return ""
+}
+
+func (x *AnalysisResult) GetConfidenceFactors() *ConfidenceFactors {
+	if x != nil {
+		return x.ConfidenceFactors
+	}
+	return nil
+}
+
+func (x *AnalysisResult) GetThresholdLower() float64 {
+	if x != nil && x.ThresholdLower != nil {
+		return *x.ThresholdLower
+	}
+	return 0
+}
+
+func (x *AnalysisResult) GetThresholdUpper() float64 {
+	if x != nil && x.ThresholdUpper != nil {
+		return *x.ThresholdUpper
+	}
+	return 0
+}
+
+type Calibration struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	PixelsPerMicron  float64 `protobuf:"fixed64,1,opt,name=pixels_per_micron,json=pixelsPerMicron,proto3" json:"pixels_per_micron,omitempty"`
+	ScaleBarLengthUm float64 `protobuf:"fixed64,2,opt,name=scale_bar_length_um,json=scaleBarLengthUm,proto3" json:"scale_bar_length_um,omitempty"`
+	ScaleBarPixels   float64 `protobuf:"fixed64,3,opt,name=scale_bar_pixels,json=scaleBarPixels,proto3" json:"scale_bar_pixels,omitempty"`
+	Source           string  `protobuf:"bytes,4,opt,name=source,proto3" json:"source,omitempty"`
+}
+
+func (x *Calibration) Reset() {
+	*x = Calibration{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[4]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Calibration) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Calibration) ProtoMessage() {}
+
+func (x *Calibration) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[4]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Calibration.ProtoReflect.Descriptor instead.
+func (*Calibration) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *Calibration) GetPixelsPerMicron() float64 {
+	if x != nil {
+		return x.PixelsPerMicron
+	}
+	return 0
+}
+
+func (x *Calibration) GetScaleBarLengthUm() float64 {
+	if x != nil {
+		return x.ScaleBarLengthUm
+	}
+	return 0
+}
+
+func (x *Calibration) GetScaleBarPixels() float64 {
+	if x != nil {
+		return x.ScaleBarPixels
+	}
+	return 0
+}
+
+func (x *Calibration) GetSource() string {
+	if x != nil {
+		return x.Source
+	}
+	return ""
+}
+
+type ImageMetadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Make           string                 `protobuf:"bytes,1,opt,name=make,proto3" json:"make,omitempty"`
+	Model          string                 `protobuf:"bytes,2,opt,name=model,proto3" json:"model,omitempty"`
+	DateTime       *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=date_time,json=dateTime,proto3" json:"date_time,omitempty"`
+	XResolution    float64                `protobuf:"fixed64,4,opt,name=x_resolution,json=xResolution,proto3" json:"x_resolution,omitempty"`
+	YResolution    float64                `protobuf:"fixed64,5,opt,name=y_resolution,json=yResolution,proto3" json:"y_resolution,omitempty"`
+	ResolutionUnit string                 `protobuf:"bytes,6,opt,name=resolution_unit,json=resolutionUnit,proto3" json:"resolution_unit,omitempty"`
+	UserComment    string                 `protobuf:"bytes,7,opt,name=user_comment,json=userComment,proto3" json:"user_comment,omitempty"`
+}
+
+func (x *ImageMetadata) Reset() {
+	*x = ImageMetadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[5]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ImageMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ImageMetadata) ProtoMessage() {}
+
+func (x *ImageMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[5]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ImageMetadata.ProtoReflect.Descriptor instead.
+func (*ImageMetadata) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ImageMetadata) GetMake() string {
+	if x != nil {
+		return x.Make
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetModel() string {
+	if x != nil {
+		return x.Model
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetDateTime() *timestamppb.Timestamp {
+	if x != nil {
+		return x.DateTime
+	}
+	return nil
+}
+
+func (x *ImageMetadata) GetXResolution() float64 {
+	if x != nil {
+		return x.XResolution
+	}
+	return 0
+}
+
+func (x *ImageMetadata) GetYResolution() float64 {
+	if x != nil {
+		return x.YResolution
+	}
+	return 0
+}
+
+func (x *ImageMetadata) GetResolutionUnit() string {
+	if x != nil {
+		return x.ResolutionUnit
+	}
+	return ""
+}
+
+func (x *ImageMetadata) GetUserComment() string {
+	if x != nil {
+		return x.UserComment
+	}
+	return ""
+}
+
+type SampleMetadata struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	SampleId       string  `protobuf:"bytes,1,opt,name=sample_id,json=sampleId,proto3" json:"sample_id,omitempty"`
+	OperatorName   string  `protobuf:"bytes,2,opt,name=operator_name,json=operatorName,proto3" json:"operator_name,omitempty"`
+	LocationLat    float64 `protobuf:"fixed64,3,opt,name=location_lat,json=locationLat,proto3" json:"location_lat,omitempty"`
+	LocationLon    float64 `protobuf:"fixed64,4,opt,name=location_lon,json=locationLon,proto3" json:"location_lon,omitempty"`
+	SamplingDepthM float64 `protobuf:"fixed64,5,opt,name=sampling_depth_m,json=samplingDepthM,proto3" json:"sampling_depth_m,omitempty"`
+	Notes          string  `protobuf:"bytes,6,opt,name=notes,proto3" json:"notes,omitempty"`
+}
+
+func (x *SampleMetadata) Reset() {
+	*x = SampleMetadata{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[6]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SampleMetadata) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SampleMetadata) ProtoMessage() {}
+
+func (x *SampleMetadata) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[6]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SampleMetadata.ProtoReflect.Descriptor instead.
+func (*SampleMetadata) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *SampleMetadata) GetSampleId() string {
+	if x != nil {
+		return x.SampleId
+	}
+	return ""
+}
+
+func (x *SampleMetadata) GetOperatorName() string {
+	if x != nil {
+		return x.OperatorName
+	}
+	return ""
+}
+
+func (x *SampleMetadata) GetLocationLat() float64 {
+	if x != nil {
+		return x.LocationLat
+	}
+	return 0
+}
+
+func (x *SampleMetadata) GetLocationLon() float64 {
+	if x != nil {
+		return x.LocationLon
+	}
+	return 0
+}
+
+func (x *SampleMetadata) GetSamplingDepthM() float64 {
+	if x != nil {
+		return x.SamplingDepthM
+	}
+	return 0
+}
+
+func (x *SampleMetadata) GetNotes() string {
+	if x != nil {
+		return x.Notes
+	}
+	return ""
+}
+
+type Rectangle struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	X      int32 `protobuf:"varint,1,opt,name=x,proto3" json:"x,omitempty"`
+	Y      int32 `protobuf:"varint,2,opt,name=y,proto3" json:"y,omitempty"`
+	Width  int32 `protobuf:"varint,3,opt,name=width,proto3" json:"width,omitempty"`
+	Height int32 `protobuf:"varint,4,opt,name=height,proto3" json:"height,omitempty"`
+}
+
+func (x *Rectangle) Reset() {
+	*x = Rectangle{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[7]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *Rectangle) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Rectangle) ProtoMessage() {}
+
+func (x *Rectangle) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[7]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Rectangle.ProtoReflect.Descriptor instead.
+func (*Rectangle) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *Rectangle) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *Rectangle) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *Rectangle) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *Rectangle) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+type ROIResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index            int32   `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	Name             string  `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	PurityPercentage float64 `protobuf:"fixed64,3,opt,name=purity_percentage,json=purityPercentage,proto3" json:"purity_percentage,omitempty"`
+}
+
+func (x *ROIResult) Reset() {
+	*x = ROIResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[8]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ROIResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ROIResult) ProtoMessage() {}
+
+func (x *ROIResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[8]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ROIResult.ProtoReflect.Descriptor instead.
+func (*ROIResult) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ROIResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *ROIResult) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ROIResult) GetPurityPercentage() float64 {
+	if x != nil {
+		return x.PurityPercentage
+	}
+	return 0
+}
+
+type SliceResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index            int32   `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	PurityPercentage float64 `protobuf:"fixed64,2,opt,name=purity_percentage,json=purityPercentage,proto3" json:"purity_percentage,omitempty"`
+	ParticleCount    int32   `protobuf:"varint,3,opt,name=particle_count,json=particleCount,proto3" json:"particle_count,omitempty"`
+}
+
+func (x *SliceResult) Reset() {
+	*x = SliceResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[9]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SliceResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SliceResult) ProtoMessage() {}
+
+func (x *SliceResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[9]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SliceResult.ProtoReflect.Descriptor instead.
+func (*SliceResult) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *SliceResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *SliceResult) GetPurityPercentage() float64 {
+	if x != nil {
+		return x.PurityPercentage
+	}
+	return 0
+}
+
+func (x *SliceResult) GetParticleCount() int32 {
+	if x != nil {
+		return x.ParticleCount
+	}
+	return 0
+}
+
+type TileResult struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Index            int32   `protobuf:"varint,1,opt,name=index,proto3" json:"index,omitempty"`
+	X                int32   `protobuf:"varint,2,opt,name=x,proto3" json:"x,omitempty"`
+	Y                int32   `protobuf:"varint,3,opt,name=y,proto3" json:"y,omitempty"`
+	Width            int32   `protobuf:"varint,4,opt,name=width,proto3" json:"width,omitempty"`
+	Height           int32   `protobuf:"varint,5,opt,name=height,proto3" json:"height,omitempty"`
+	ParticleCount    int32   `protobuf:"varint,6,opt,name=particle_count,json=particleCount,proto3" json:"particle_count,omitempty"`
+	TotalArea        float64 `protobuf:"fixed64,7,opt,name=total_area,json=totalArea,proto3" json:"total_area,omitempty"`
+	PurityPercentage float64 `protobuf:"fixed64,8,opt,name=purity_percentage,json=purityPercentage,proto3" json:"purity_percentage,omitempty"`
+}
+
+func (x *TileResult) Reset() {
+	*x = TileResult{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[10]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *TileResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TileResult) ProtoMessage() {}
+
+func (x *TileResult) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[10]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TileResult.ProtoReflect.Descriptor instead.
+func (*TileResult) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *TileResult) GetIndex() int32 {
+	if x != nil {
+		return x.Index
+	}
+	return 0
+}
+
+func (x *TileResult) GetX() int32 {
+	if x != nil {
+		return x.X
+	}
+	return 0
+}
+
+func (x *TileResult) GetY() int32 {
+	if x != nil {
+		return x.Y
+	}
+	return 0
+}
+
+func (x *TileResult) GetWidth() int32 {
+	if x != nil {
+		return x.Width
+	}
+	return 0
+}
+
+func (x *TileResult) GetHeight() int32 {
+	if x != nil {
+		return x.Height
+	}
+	return 0
+}
+
+func (x *TileResult) GetParticleCount() int32 {
+	if x != nil {
+		return x.ParticleCount
+	}
+	return 0
+}
+
+func (x *TileResult) GetTotalArea() float64 {
+	if x != nil {
+		return x.TotalArea
+	}
+	return 0
+}
+
+func (x *TileResult) GetPurityPercentage() float64 {
+	if x != nil {
+		return x.PurityPercentage
+	}
+	return 0
+}
+
+type SpacingStats struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ParticleCount int32   `protobuf:"varint,1,opt,name=particle_count,json=particleCount,proto3" json:"particle_count,omitempty"`
+	Mean          float64 `protobuf:"fixed64,2,opt,name=mean,proto3" json:"mean,omitempty"`
+	Median        float64 `protobuf:"fixed64,3,opt,name=median,proto3" json:"median,omitempty"`
+	Min           float64 `protobuf:"fixed64,4,opt,name=min,proto3" json:"min,omitempty"`
+	Max           float64 `protobuf:"fixed64,5,opt,name=max,proto3" json:"max,omitempty"`
+	Unit          string  `protobuf:"bytes,6,opt,name=unit,proto3" json:"unit,omitempty"`
+}
+
+func (x *SpacingStats) Reset() {
+	*x = SpacingStats{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[11]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *SpacingStats) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SpacingStats) ProtoMessage() {}
+
+func (x *SpacingStats) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[11]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SpacingStats.ProtoReflect.Descriptor instead.
+func (*SpacingStats) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *SpacingStats) GetParticleCount() int32 {
+	if x != nil {
+		return x.ParticleCount
+	}
+	return 0
+}
+
+func (x *SpacingStats) GetMean() float64 {
+	if x != nil {
+		return x.Mean
+	}
+	return 0
+}
+
+func (x *SpacingStats) GetMedian() float64 {
+	if x != nil {
+		return x.Median
+	}
+	return 0
+}
+
+func (x *SpacingStats) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *SpacingStats) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *SpacingStats) GetUnit() string {
+	if x != nil {
+		return x.Unit
+	}
+	return ""
+}
+
+type ConfidenceFactors struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Base                float64             `protobuf:"fixed64,1,opt,name=base,proto3" json:"base,omitempty"`
+	ParticleCountBucket string              `protobuf:"bytes,2,opt,name=particle_count_bucket,json=particleCountBucket,proto3" json:"particle_count_bucket,omitempty"`
+	Signals             []*ConfidenceSignal `protobuf:"bytes,3,rep,name=signals,proto3" json:"signals,omitempty"`
+}
+
+func (x *ConfidenceFactors) Reset() {
+	*x = ConfidenceFactors{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[12]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfidenceFactors) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfidenceFactors) ProtoMessage() {}
+
+func (x *ConfidenceFactors) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[12]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfidenceFactors.ProtoReflect.Descriptor instead.
+func (*ConfidenceFactors) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *ConfidenceFactors) GetBase() float64 {
+	if x != nil {
+		return x.Base
+	}
+	return 0
+}
+
+func (x *ConfidenceFactors) GetParticleCountBucket() string {
+	if x != nil {
+		return x.ParticleCountBucket
+	}
+	return ""
+}
+
+func (x *ConfidenceFactors) GetSignals() []*ConfidenceSignal {
+	if x != nil {
+		return x.Signals
+	}
+	return nil
+}
+
+type ConfidenceSignal struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	Name      string  `protobuf:"bytes,1,opt,name=name,proto3" json:"name,omitempty"`
+	Weight    float64 `protobuf:"fixed64,2,opt,name=weight,proto3" json:"weight,omitempty"`
+	Satisfied bool    `protobuf:"varint,3,opt,name=satisfied,proto3" json:"satisfied,omitempty"`
+}
+
+func (x *ConfidenceSignal) Reset() {
+	*x = ConfidenceSignal{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[13]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *ConfidenceSignal) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConfidenceSignal) ProtoMessage() {}
+
+func (x *ConfidenceSignal) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[13]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConfidenceSignal.ProtoReflect.Descriptor instead.
+func (*ConfidenceSignal) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *ConfidenceSignal) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *ConfidenceSignal) GetWeight() float64 {
+	if x != nil {
+		return x.Weight
+	}
+	return 0
+}
+
+func (x *ConfidenceSignal) GetSatisfied() bool {
+	if x != nil {
+		return x.Satisfied
+	}
+	return false
+}
+
+type MacroParams struct {
+	state         protoimpl.MessageState
+	sizeCache     protoimpl.SizeCache
+	unknownFields protoimpl.UnknownFields
+
+	ThresholdMethod    string  `protobuf:"bytes,1,opt,name=threshold_method,json=thresholdMethod,proto3" json:"threshold_method,omitempty"`
+	MinParticleSize    float64 `protobuf:"fixed64,2,opt,name=min_particle_size,json=minParticleSize,proto3" json:"min_particle_size,omitempty"`
+	MaxParticleSize    float64 `protobuf:"fixed64,3,opt,name=max_particle_size,json=maxParticleSize,proto3" json:"max_particle_size,omitempty"`
+	MinCircularity     float64 `protobuf:"fixed64,4,opt,name=min_circularity,json=minCircularity,proto3" json:"min_circularity,omitempty"`
+	MaxCircularity     float64 `protobuf:"fixed64,5,opt,name=max_circularity,json=maxCircularity,proto3" json:"max_circularity,omitempty"`
+	GaussianSigma      float64 `protobuf:"fixed64,6,opt,name=gaussian_sigma,json=gaussianSigma,proto3" json:"gaussian_sigma,omitempty"`
+	ContrastSaturation float64 `protobuf:"fixed64,7,opt,name=contrast_saturation,json=contrastSaturation,proto3" json:"contrast_saturation,omitempty"`
+}
+
+func (x *MacroParams) Reset() {
+	*x = MacroParams{}
+	if protoimpl.UnsafeEnabled {
+		mi := &file_proto_gypsum_analysis_proto_msgTypes[14]
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		ms.StoreMessageInfo(mi)
+	}
+}
+
+func (x *MacroParams) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MacroParams) ProtoMessage() {}
+
+func (x *MacroParams) ProtoReflect() protoreflect.Message {
+	mi := &file_proto_gypsum_analysis_proto_msgTypes[14]
+	if protoimpl.UnsafeEnabled && x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MacroParams.ProtoReflect.Descriptor instead.
+func (*MacroParams) Descriptor() ([]byte, []int) {
+	return file_proto_gypsum_analysis_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *MacroParams) GetThresholdMethod() string {
+	if x != nil {
+		return x.ThresholdMethod
+	}
+	return ""
+}
+
+func (x *MacroParams) GetMinParticleSize() float64 {
+	if x != nil {
+		return x.MinParticleSize
+	}
+	return 0
+}
+
+func (x *MacroParams) GetMaxParticleSize() float64 {
+	if x != nil {
+		return x.MaxParticleSize
+	}
+	return 0
+}
+
+func (x *MacroParams) GetMinCircularity() float64 {
+	if x != nil {
+		return x.MinCircularity
+	}
+	return 0
+}
+
+func (x *MacroParams) GetMaxCircularity() float64 {
+	if x != nil {
+		return x.MaxCircularity
+	}
+	return 0
+}
+
+func (x *MacroParams) GetGaussianSigma() float64 {
+	if x != nil {
+		return x.GaussianSigma
+	}
+	return 0
+}
+
+func (x *MacroParams) GetContrastSaturation() float64 {
+	if x != nil {
+		return x.ContrastSaturation
+	}
+	return 0
+}
+
+var File_proto_gypsum_analysis_proto protoreflect.FileDescriptor
+
+var file_proto_gypsum_analysis_proto_rawDesc = []byte{
+	0x0a, 0x1b, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x5f, 0x61,
+	0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x12, 0x09, 0x67,
+	0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x1a, 0x1f, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65,
+	0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2f, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74,
+	0x61, 0x6d, 0x70, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x22, 0xe0, 0x07, 0x0a, 0x0e, 0x41, 0x6e,
+	0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x14, 0x0a, 0x05,
+	0x69, 0x6d, 0x61, 0x67, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x0c, 0x52, 0x05, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65, 0x12, 0x18,
+	0x0a, 0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x07, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x61, 0x6d, 0x70,
+	0x6c, 0x65, 0x5f, 0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x21, 0x0a, 0x0c, 0x63,
+	0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x05, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0b, 0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x55, 0x72, 0x6c, 0x12, 0x43,
+	0x0a, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x06, 0x20, 0x03, 0x28, 0x0b,
+	0x32, 0x27, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61,
+	0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x2e, 0x4d, 0x65, 0x74, 0x61,
+	0x64, 0x61, 0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x12, 0x2a, 0x0a, 0x11, 0x70, 0x69, 0x78, 0x65, 0x6c, 0x73, 0x5f, 0x70, 0x65,
+	0x72, 0x5f, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f,
+	0x70, 0x69, 0x78, 0x65, 0x6c, 0x73, 0x50, 0x65, 0x72, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x6e, 0x12,
+	0x29, 0x0a, 0x10, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x5f, 0x6d, 0x65, 0x74,
+	0x68, 0x6f, 0x64, 0x18, 0x08, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x74, 0x68, 0x72, 0x65, 0x73,
+	0x68, 0x6f, 0x6c, 0x64, 0x4d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x12, 0x2f, 0x0a, 0x11, 0x6d, 0x69,
+	0x6e, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18,
+	0x09, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52, 0x0f, 0x6d, 0x69, 0x6e, 0x50, 0x61, 0x72, 0x74,
+	0x69, 0x63, 0x6c, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x88, 0x01, 0x01, 0x12, 0x2f, 0x0a, 0x11, 0x6d,
+	0x61, 0x78, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65,
+	0x18, 0x0a, 0x20, 0x01, 0x28, 0x01, 0x48, 0x01, 0x52, 0x0f, 0x6d, 0x61, 0x78, 0x50, 0x61, 0x72,
+	0x74, 0x69, 0x63, 0x6c, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x88, 0x01, 0x01, 0x12, 0x2c, 0x0a, 0x0f,
+	0x6d, 0x69, 0x6e, 0x5f, 0x63, 0x69, 0x72, 0x63, 0x75, 0x6c, 0x61, 0x72, 0x69, 0x74, 0x79, 0x18,
+	0x0b, 0x20, 0x01, 0x28, 0x01, 0x48, 0x02, 0x52, 0x0e, 0x6d, 0x69, 0x6e, 0x43, 0x69, 0x72, 0x63,
+	0x75, 0x6c, 0x61, 0x72, 0x69, 0x74, 0x79, 0x88, 0x01, 0x01, 0x12, 0x26, 0x0a, 0x03, 0x72, 0x6f,
+	0x69, 0x18, 0x0c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d,
+	0x2e, 0x76, 0x31, 0x2e, 0x52, 0x65, 0x63, 0x74, 0x61, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x03, 0x72,
+	0x6f, 0x69, 0x12, 0x27, 0x0a, 0x0f, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x5f, 0x73, 0x65,
+	0x63, 0x6f, 0x6e, 0x64, 0x73, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x74, 0x69, 0x6d,
+	0x65, 0x6f, 0x75, 0x74, 0x53, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x12, 0x28, 0x0a, 0x10, 0x73,
+	0x63, 0x61, 0x6c, 0x65, 0x5f, 0x62, 0x61, 0x72, 0x5f, 0x6c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x18,
+	0x0e, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x42, 0x61, 0x72, 0x4c,
+	0x65, 0x6e, 0x67, 0x74, 0x68, 0x12, 0x28, 0x0a, 0x10, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x5f, 0x62,
+	0x61, 0x72, 0x5f, 0x70, 0x69, 0x78, 0x65, 0x6c, 0x73, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x01, 0x52,
+	0x0e, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x42, 0x61, 0x72, 0x50, 0x69, 0x78, 0x65, 0x6c, 0x73, 0x12,
+	0x42, 0x0a, 0x0f, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x18, 0x10, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75,
+	0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x52, 0x0e, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64,
+	0x61, 0x74, 0x61, 0x12, 0x2a, 0x0a, 0x0e, 0x67, 0x61, 0x75, 0x73, 0x73, 0x69, 0x61, 0x6e, 0x5f,
+	0x73, 0x69, 0x67, 0x6d, 0x61, 0x18, 0x11, 0x20, 0x01, 0x28, 0x01, 0x48, 0x03, 0x52, 0x0d, 0x67,
+	0x61, 0x75, 0x73, 0x73, 0x69, 0x61, 0x6e, 0x53, 0x69, 0x67, 0x6d, 0x61, 0x88, 0x01, 0x01, 0x12,
+	0x34, 0x0a, 0x13, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x73, 0x74, 0x5f, 0x73, 0x61, 0x74, 0x75,
+	0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x12, 0x20, 0x01, 0x28, 0x01, 0x48, 0x04, 0x52, 0x12,
+	0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x73, 0x74, 0x53, 0x61, 0x74, 0x75, 0x72, 0x61, 0x74, 0x69,
+	0x6f, 0x6e, 0x88, 0x01, 0x01, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65,
+	0x5f, 0x69, 0x64, 0x18, 0x13, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x66, 0x69,
+	0x6c, 0x65, 0x49, 0x64, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a, 0x02, 0x38,
+	0x01, 0x42, 0x14, 0x0a, 0x12, 0x5f, 0x6d, 0x69, 0x6e, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63,
+	0x6c, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x42, 0x14, 0x0a, 0x12, 0x5f, 0x6d, 0x61, 0x78, 0x5f,
+	0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x42, 0x12, 0x0a,
+	0x10, 0x5f, 0x6d, 0x69, 0x6e, 0x5f, 0x63, 0x69, 0x72, 0x63, 0x75, 0x6c, 0x61, 0x72, 0x69, 0x74,
+	0x79, 0x42, 0x11, 0x0a, 0x0f, 0x5f, 0x67, 0x61, 0x75, 0x73, 0x73, 0x69, 0x61, 0x6e, 0x5f, 0x73,
+	0x69, 0x67, 0x6d, 0x61, 0x42, 0x16, 0x0a, 0x14, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x73,
+	0x74, 0x5f, 0x73, 0x61, 0x74, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x22, 0x4a, 0x0a, 0x0f,
+	0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x1f, 0x0a, 0x0b, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x5f, 0x69, 0x64, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x49, 0x64,
+	0x12, 0x16, 0x0a, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x22, 0x30, 0x0a, 0x0d, 0x53, 0x74, 0x61, 0x74,
+	0x75, 0x73, 0x52, 0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x12, 0x1f, 0x0a, 0x0b, 0x61, 0x6e, 0x61,
+	0x6c, 0x79, 0x73, 0x69, 0x73, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a,
+	0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x49, 0x64, 0x22, 0xe6, 0x17, 0x0a, 0x0e, 0x41,
+	0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x0e, 0x0a,
+	0x02, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x02, 0x69, 0x64, 0x12, 0x16, 0x0a,
+	0x06, 0x73, 0x74, 0x61, 0x74, 0x75, 0x73, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73,
+	0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x39, 0x0a, 0x0a, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64,
+	0x5f, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67,
+	0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65,
+	0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x09, 0x63, 0x72, 0x65, 0x61, 0x74, 0x65, 0x64, 0x41, 0x74,
+	0x12, 0x3d, 0x0a, 0x0c, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x5f, 0x61, 0x74,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e,
+	0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61,
+	0x6d, 0x70, 0x52, 0x0b, 0x63, 0x6f, 0x6d, 0x70, 0x6c, 0x65, 0x74, 0x65, 0x64, 0x41, 0x74, 0x12,
+	0x14, 0x0a, 0x05, 0x65, 0x72, 0x72, 0x6f, 0x72, 0x18, 0x05, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05,
+	0x65, 0x72, 0x72, 0x6f, 0x72, 0x12, 0x29, 0x0a, 0x10, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65,
+	0x5f, 0x63, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0f, 0x66, 0x61, 0x69, 0x6c, 0x75, 0x72, 0x65, 0x43, 0x61, 0x74, 0x65, 0x67, 0x6f, 0x72, 0x79,
+	0x12, 0x1a, 0x0a, 0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x18, 0x07, 0x20, 0x01,
+	0x28, 0x05, 0x52, 0x08, 0x70, 0x72, 0x6f, 0x67, 0x72, 0x65, 0x73, 0x73, 0x12, 0x2b, 0x0a, 0x11,
+	0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x5f, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67,
+	0x65, 0x18, 0x08, 0x20, 0x01, 0x28, 0x01, 0x52, 0x10, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x50,
+	0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x12, 0x1e, 0x0a, 0x0a, 0x63, 0x6f, 0x6e,
+	0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x18, 0x09, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0a, 0x63,
+	0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18, 0x0a, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x69,
+	0x6d, 0x61, 0x67, 0x65, 0x50, 0x61, 0x74, 0x68, 0x12, 0x1d, 0x0a, 0x0a, 0x69, 0x6d, 0x61, 0x67,
+	0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x0b, 0x20, 0x01, 0x28, 0x03, 0x52, 0x09, 0x69, 0x6d,
+	0x61, 0x67, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x28, 0x0a, 0x10, 0x61, 0x6e, 0x61, 0x6c, 0x79,
+	0x73, 0x69, 0x73, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x5f, 0x6d, 0x73, 0x18, 0x0c, 0x20, 0x01, 0x28,
+	0x03, 0x52, 0x0e, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x54, 0x69, 0x6d, 0x65, 0x4d,
+	0x73, 0x12, 0x23, 0x0a, 0x0d, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x72, 0x65, 0x6d, 0x6f, 0x76,
+	0x65, 0x64, 0x18, 0x0d, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0c, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x52,
+	0x65, 0x6d, 0x6f, 0x76, 0x65, 0x64, 0x12, 0x21, 0x0a, 0x0c, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f,
+	0x73, 0x68, 0x61, 0x32, 0x35, 0x36, 0x18, 0x0e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x69, 0x6d,
+	0x61, 0x67, 0x65, 0x53, 0x68, 0x61, 0x32, 0x35, 0x36, 0x12, 0x21, 0x0a, 0x0c, 0x69, 0x6d, 0x61,
+	0x67, 0x65, 0x5f, 0x66, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x18, 0x0f, 0x20, 0x01, 0x28, 0x09, 0x52,
+	0x0b, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x46, 0x6f, 0x72, 0x6d, 0x61, 0x74, 0x12, 0x1f, 0x0a, 0x0b,
+	0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x77, 0x69, 0x64, 0x74, 0x68, 0x18, 0x10, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x0a, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x57, 0x69, 0x64, 0x74, 0x68, 0x12, 0x21, 0x0a,
+	0x0c, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x11, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0b, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x48, 0x65, 0x69, 0x67, 0x68, 0x74,
+	0x12, 0x3a, 0x0a, 0x19, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x5f, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x18, 0x12, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x17, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x43, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x12, 0x3e, 0x0a, 0x1b,
+	0x69, 0x6d, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x5f, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x18, 0x13, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x19, 0x69, 0x6d, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x43, 0x6f, 0x6e, 0x74, 0x65,
+	0x6e, 0x74, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x12, 0x3c, 0x0a, 0x1a,
+	0x63, 0x61, 0x6c, 0x63, 0x69, 0x74, 0x65, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f,
+	0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x18, 0x14, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x18, 0x63, 0x61, 0x6c, 0x63, 0x69, 0x74, 0x65, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74,
+	0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x12, 0x3a, 0x0a, 0x19, 0x71, 0x75,
+	0x61, 0x72, 0x74, 0x7a, 0x5f, 0x63, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x5f, 0x70, 0x65, 0x72,
+	0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x18, 0x15, 0x20, 0x01, 0x28, 0x01, 0x52, 0x17, 0x71,
+	0x75, 0x61, 0x72, 0x74, 0x7a, 0x43, 0x6f, 0x6e, 0x74, 0x65, 0x6e, 0x74, 0x50, 0x65, 0x72, 0x63,
+	0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x12, 0x3a, 0x0a, 0x19, 0x6f, 0x74, 0x68, 0x65, 0x72, 0x5f,
+	0x6d, 0x69, 0x6e, 0x65, 0x72, 0x61, 0x6c, 0x73, 0x5f, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74,
+	0x61, 0x67, 0x65, 0x18, 0x16, 0x20, 0x01, 0x28, 0x01, 0x52, 0x17, 0x6f, 0x74, 0x68, 0x65, 0x72,
+	0x4d, 0x69, 0x6e, 0x65, 0x72, 0x61, 0x6c, 0x73, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61,
+	0x67, 0x65, 0x12, 0x2b, 0x0a, 0x0f, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x5f,
+	0x76, 0x61, 0x6c, 0x75, 0x65, 0x18, 0x17, 0x20, 0x01, 0x28, 0x01, 0x42, 0x02, 0x18, 0x01, 0x52,
+	0x0e, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x56, 0x61, 0x6c, 0x75, 0x65, 0x12,
+	0x25, 0x0a, 0x0e, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x18, 0x18, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c,
+	0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x37, 0x0a, 0x18, 0x61, 0x76, 0x65, 0x72, 0x61, 0x67,
+	0x65, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x5f,
+	0x75, 0x6d, 0x18, 0x19, 0x20, 0x01, 0x28, 0x01, 0x52, 0x15, 0x61, 0x76, 0x65, 0x72, 0x61, 0x67,
+	0x65, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x55, 0x6d, 0x12,
+	0x1d, 0x0a, 0x0a, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x61, 0x72, 0x65, 0x61, 0x18, 0x1a, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x09, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x41, 0x72, 0x65, 0x61, 0x12, 0x1d,
+	0x0a, 0x0a, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x61, 0x72, 0x65, 0x61, 0x18, 0x1b, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x09, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x41, 0x72, 0x65, 0x61, 0x12, 0x38, 0x0a,
+	0x0b, 0x63, 0x61, 0x6c, 0x69, 0x62, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x1c, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x16, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x43,
+	0x61, 0x6c, 0x69, 0x62, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x52, 0x0b, 0x63, 0x61, 0x6c, 0x69,
+	0x62, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x19, 0x0a, 0x08, 0x72, 0x6f, 0x69, 0x5f, 0x66,
+	0x69, 0x6c, 0x65, 0x18, 0x1d, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x72, 0x6f, 0x69, 0x46, 0x69,
+	0x6c, 0x65, 0x12, 0x35, 0x0a, 0x0b, 0x72, 0x6f, 0x69, 0x5f, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74,
+	0x73, 0x18, 0x1e, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d,
+	0x2e, 0x76, 0x31, 0x2e, 0x52, 0x4f, 0x49, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x0a, 0x72,
+	0x6f, 0x69, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x73, 0x12, 0x2b, 0x0a, 0x05, 0x74, 0x69, 0x6c,
+	0x65, 0x73, 0x18, 0x1f, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x15, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75,
+	0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x54, 0x69, 0x6c, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52,
+	0x05, 0x74, 0x69, 0x6c, 0x65, 0x73, 0x12, 0x3c, 0x0a, 0x0d, 0x73, 0x70, 0x61, 0x63, 0x69, 0x6e,
+	0x67, 0x5f, 0x73, 0x74, 0x61, 0x74, 0x73, 0x18, 0x20, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x17, 0x2e,
+	0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x70, 0x61, 0x63, 0x69, 0x6e,
+	0x67, 0x53, 0x74, 0x61, 0x74, 0x73, 0x52, 0x0c, 0x73, 0x70, 0x61, 0x63, 0x69, 0x6e, 0x67, 0x53,
+	0x74, 0x61, 0x74, 0x73, 0x12, 0x1a, 0x0a, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65,
+	0x18, 0x21, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x66, 0x69, 0x6c, 0x65, 0x6e, 0x61, 0x6d, 0x65,
+	0x12, 0x19, 0x0a, 0x08, 0x62, 0x61, 0x74, 0x63, 0x68, 0x5f, 0x69, 0x64, 0x18, 0x22, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x07, 0x62, 0x61, 0x74, 0x63, 0x68, 0x49, 0x64, 0x12, 0x18, 0x0a, 0x07, 0x70,
+	0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65, 0x18, 0x23, 0x20, 0x01, 0x28, 0x09, 0x52, 0x07, 0x70, 0x72,
+	0x6f, 0x66, 0x69, 0x6c, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x5f,
+	0x67, 0x72, 0x6f, 0x75, 0x70, 0x18, 0x24, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x73, 0x61, 0x6d,
+	0x70, 0x6c, 0x65, 0x47, 0x72, 0x6f, 0x75, 0x70, 0x12, 0x21, 0x0a, 0x0c, 0x63, 0x61, 0x6c, 0x6c,
+	0x62, 0x61, 0x63, 0x6b, 0x5f, 0x75, 0x72, 0x6c, 0x18, 0x25, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x63, 0x61, 0x6c, 0x6c, 0x62, 0x61, 0x63, 0x6b, 0x55, 0x72, 0x6c, 0x12, 0x43, 0x0a, 0x08, 0x6d,
+	0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x26, 0x20, 0x03, 0x28, 0x0b, 0x32, 0x27, 0x2e,
+	0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x73,
+	0x69, 0x73, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x2e, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x52, 0x08, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x12, 0x36, 0x0a, 0x0a, 0x70, 0x61, 0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x18, 0x27,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31,
+	0x2e, 0x4d, 0x61, 0x63, 0x72, 0x6f, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x52, 0x0a, 0x70, 0x61,
+	0x72, 0x61, 0x6d, 0x65, 0x74, 0x65, 0x72, 0x73, 0x12, 0x21, 0x0a, 0x0c, 0x66, 0x69, 0x6a, 0x69,
+	0x5f, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x28, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b,
+	0x66, 0x69, 0x6a, 0x69, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x12, 0x3d, 0x0a, 0x18, 0x73,
+	0x68, 0x61, 0x64, 0x6f, 0x77, 0x5f, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x5f, 0x70, 0x65, 0x72,
+	0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x18, 0x29, 0x20, 0x01, 0x28, 0x01, 0x48, 0x00, 0x52,
+	0x16, 0x73, 0x68, 0x61, 0x64, 0x6f, 0x77, 0x50, 0x75, 0x72, 0x69, 0x74, 0x79, 0x50, 0x65, 0x72,
+	0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x88, 0x01, 0x01, 0x12, 0x1a, 0x0a, 0x08, 0x77, 0x61,
+	0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73, 0x18, 0x2a, 0x20, 0x03, 0x28, 0x09, 0x52, 0x08, 0x77, 0x61,
+	0x72, 0x6e, 0x69, 0x6e, 0x67, 0x73, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x76, 0x69, 0x65, 0x77,
+	0x5f, 0x6e, 0x6f, 0x74, 0x65, 0x18, 0x2b, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x65, 0x76,
+	0x69, 0x65, 0x77, 0x4e, 0x6f, 0x74, 0x65, 0x12, 0x1f, 0x0a, 0x0b, 0x72, 0x65, 0x76, 0x69, 0x65,
+	0x77, 0x65, 0x64, 0x5f, 0x62, 0x79, 0x18, 0x2c, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0a, 0x72, 0x65,
+	0x76, 0x69, 0x65, 0x77, 0x65, 0x64, 0x42, 0x79, 0x12, 0x3b, 0x0a, 0x0b, 0x72, 0x65, 0x76, 0x69,
+	0x65, 0x77, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x2d, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e,
+	0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e,
+	0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x0a, 0x72, 0x65, 0x76, 0x69, 0x65,
+	0x77, 0x65, 0x64, 0x41, 0x74, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x6e, 0x75, 0x61, 0x6c, 0x5f,
+	0x6f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x18, 0x2e, 0x20, 0x01, 0x28, 0x08, 0x52, 0x0e,
+	0x6d, 0x61, 0x6e, 0x75, 0x61, 0x6c, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65, 0x12, 0x39,
+	0x0a, 0x16, 0x6d, 0x61, 0x6e, 0x75, 0x61, 0x6c, 0x5f, 0x6f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64,
+	0x65, 0x5f, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x18, 0x2f, 0x20, 0x01, 0x28, 0x01, 0x48, 0x01,
+	0x52, 0x14, 0x6d, 0x61, 0x6e, 0x75, 0x61, 0x6c, 0x4f, 0x76, 0x65, 0x72, 0x72, 0x69, 0x64, 0x65,
+	0x50, 0x75, 0x72, 0x69, 0x74, 0x79, 0x88, 0x01, 0x01, 0x12, 0x27, 0x0a, 0x0f, 0x70, 0x65, 0x72,
+	0x63, 0x65, 0x70, 0x74, 0x75, 0x61, 0x6c, 0x5f, 0x68, 0x61, 0x73, 0x68, 0x18, 0x30, 0x20, 0x01,
+	0x28, 0x09, 0x52, 0x0e, 0x70, 0x65, 0x72, 0x63, 0x65, 0x70, 0x74, 0x75, 0x61, 0x6c, 0x48, 0x61,
+	0x73, 0x68, 0x12, 0x21, 0x0a, 0x0c, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63, 0x61, 0x74, 0x65, 0x5f,
+	0x6f, 0x66, 0x18, 0x31, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x64, 0x75, 0x70, 0x6c, 0x69, 0x63,
+	0x61, 0x74, 0x65, 0x4f, 0x66, 0x12, 0x26, 0x0a, 0x03, 0x72, 0x6f, 0x69, 0x18, 0x32, 0x20, 0x01,
+	0x28, 0x0b, 0x32, 0x14, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x52,
+	0x65, 0x63, 0x74, 0x61, 0x6e, 0x67, 0x6c, 0x65, 0x52, 0x03, 0x72, 0x6f, 0x69, 0x12, 0x27, 0x0a,
+	0x0f, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x5f, 0x73, 0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73,
+	0x18, 0x33, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0e, 0x74, 0x69, 0x6d, 0x65, 0x6f, 0x75, 0x74, 0x53,
+	0x65, 0x63, 0x6f, 0x6e, 0x64, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72,
+	0x65, 0x6d, 0x65, 0x6e, 0x74, 0x5f, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x34, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x0f, 0x6d, 0x65, 0x61, 0x73, 0x75, 0x72, 0x65, 0x6d, 0x65, 0x6e, 0x74, 0x55, 0x6e, 0x69,
+	0x74, 0x12, 0x1c, 0x0a, 0x09, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x18, 0x35,
+	0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x65, 0x73, 0x74, 0x69, 0x6d, 0x61, 0x74, 0x65, 0x64, 0x12,
+	0x21, 0x0a, 0x0c, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x5f, 0x70, 0x61, 0x74, 0x68, 0x18,
+	0x36, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x6f, 0x76, 0x65, 0x72, 0x6c, 0x61, 0x79, 0x50, 0x61,
+	0x74, 0x68, 0x12, 0x29, 0x0a, 0x10, 0x72, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x5f, 0x73, 0x69, 0x67,
+	0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x18, 0x37, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0f, 0x72, 0x65,
+	0x73, 0x75, 0x6c, 0x74, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x12, 0x32, 0x0a,
+	0x15, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x5f, 0x6b, 0x65, 0x79, 0x5f, 0x76,
+	0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x18, 0x38, 0x20, 0x01, 0x28, 0x09, 0x52, 0x13, 0x73, 0x69,
+	0x67, 0x6e, 0x61, 0x74, 0x75, 0x72, 0x65, 0x4b, 0x65, 0x79, 0x56, 0x65, 0x72, 0x73, 0x69, 0x6f,
+	0x6e, 0x12, 0x37, 0x0a, 0x09, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x5f, 0x61, 0x74, 0x18, 0x39,
+	0x20, 0x01, 0x28, 0x0b, 0x32, 0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72,
+	0x6f, 0x74, 0x6f, 0x62, 0x75, 0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70,
+	0x52, 0x08, 0x73, 0x69, 0x67, 0x6e, 0x65, 0x64, 0x41, 0x74, 0x12, 0x42, 0x0a, 0x0f, 0x73, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x18, 0x3a, 0x20,
+	0x01, 0x28, 0x0b, 0x32, 0x19, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x52, 0x0e,
+	0x73, 0x61, 0x6d, 0x70, 0x6c, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1f,
+	0x0a, 0x0b, 0x72, 0x65, 0x74, 0x72, 0x79, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x3b, 0x20,
+	0x01, 0x28, 0x05, 0x52, 0x0a, 0x72, 0x65, 0x74, 0x72, 0x79, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12,
+	0x3f, 0x0a, 0x0e, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x5f, 0x6d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x18, 0x3c, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x18, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d,
+	0x2e, 0x76, 0x31, 0x2e, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74,
+	0x61, 0x52, 0x0d, 0x69, 0x6d, 0x61, 0x67, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61,
+	0x12, 0x33, 0x0a, 0x09, 0x70, 0x65, 0x72, 0x5f, 0x73, 0x6c, 0x69, 0x63, 0x65, 0x18, 0x3d, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x16, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e,
+	0x53, 0x6c, 0x69, 0x63, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x52, 0x08, 0x70, 0x65, 0x72,
+	0x53, 0x6c, 0x69, 0x63, 0x65, 0x12, 0x1d, 0x0a, 0x0a, 0x70, 0x72, 0x6f, 0x66, 0x69, 0x6c, 0x65,
+	0x5f, 0x69, 0x64, 0x18, 0x3e, 0x20, 0x01, 0x28, 0x09, 0x52, 0x09, 0x70, 0x72, 0x6f, 0x66, 0x69,
+	0x6c, 0x65, 0x49, 0x64, 0x12, 0x27, 0x0a, 0x0f, 0x61, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73,
+	0x5f, 0x65, 0x6e, 0x67, 0x69, 0x6e, 0x65, 0x18, 0x3f, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x61,
+	0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x45, 0x6e, 0x67, 0x69, 0x6e, 0x65, 0x12, 0x4b, 0x0a,
+	0x12, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x5f, 0x66, 0x61, 0x63, 0x74,
+	0x6f, 0x72, 0x73, 0x18, 0x40, 0x20, 0x01, 0x28, 0x0b, 0x32, 0x1c, 0x2e, 0x67, 0x79, 0x70, 0x73,
+	0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65,
+	0x46, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x52, 0x11, 0x63, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65,
+	0x6e, 0x63, 0x65, 0x46, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x2c, 0x0a, 0x0f, 0x74, 0x68,
+	0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x5f, 0x6c, 0x6f, 0x77, 0x65, 0x72, 0x18, 0x41, 0x20,
+	0x01, 0x28, 0x01, 0x48, 0x02, 0x52, 0x0e, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64,
+	0x4c, 0x6f, 0x77, 0x65, 0x72, 0x88, 0x01, 0x01, 0x12, 0x2c, 0x0a, 0x0f, 0x74, 0x68, 0x72, 0x65,
+	0x73, 0x68, 0x6f, 0x6c, 0x64, 0x5f, 0x75, 0x70, 0x70, 0x65, 0x72, 0x18, 0x42, 0x20, 0x01, 0x28,
+	0x01, 0x48, 0x03, 0x52, 0x0e, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x55, 0x70,
+	0x70, 0x65, 0x72, 0x88, 0x01, 0x01, 0x1a, 0x3b, 0x0a, 0x0d, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x45, 0x6e, 0x74, 0x72, 0x79, 0x12, 0x10, 0x0a, 0x03, 0x6b, 0x65, 0x79, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x03, 0x6b, 0x65, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x76, 0x61, 0x6c,
+	0x75, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x76, 0x61, 0x6c, 0x75, 0x65, 0x3a,
+	0x02, 0x38, 0x01, 0x42, 0x1b, 0x0a, 0x19, 0x5f, 0x73, 0x68, 0x61, 0x64, 0x6f, 0x77, 0x5f, 0x70,
+	0x75, 0x72, 0x69, 0x74, 0x79, 0x5f, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65,
+	0x42, 0x19, 0x0a, 0x17, 0x5f, 0x6d, 0x61, 0x6e, 0x75, 0x61, 0x6c, 0x5f, 0x6f, 0x76, 0x65, 0x72,
+	0x72, 0x69, 0x64, 0x65, 0x5f, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x42, 0x12, 0x0a, 0x10, 0x5f,
+	0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x5f, 0x6c, 0x6f, 0x77, 0x65, 0x72, 0x42,
+	0x12, 0x0a, 0x10, 0x5f, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x5f, 0x75, 0x70,
+	0x70, 0x65, 0x72, 0x22, 0xaa, 0x01, 0x0a, 0x0b, 0x43, 0x61, 0x6c, 0x69, 0x62, 0x72, 0x61, 0x74,
+	0x69, 0x6f, 0x6e, 0x12, 0x2a, 0x0a, 0x11, 0x70, 0x69, 0x78, 0x65, 0x6c, 0x73, 0x5f, 0x70, 0x65,
+	0x72, 0x5f, 0x6d, 0x69, 0x63, 0x72, 0x6f, 0x6e, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f,
+	0x70, 0x69, 0x78, 0x65, 0x6c, 0x73, 0x50, 0x65, 0x72, 0x4d, 0x69, 0x63, 0x72, 0x6f, 0x6e, 0x12,
+	0x2d, 0x0a, 0x13, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x5f, 0x62, 0x61, 0x72, 0x5f, 0x6c, 0x65, 0x6e,
+	0x67, 0x74, 0x68, 0x5f, 0x75, 0x6d, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x10, 0x73, 0x63,
+	0x61, 0x6c, 0x65, 0x42, 0x61, 0x72, 0x4c, 0x65, 0x6e, 0x67, 0x74, 0x68, 0x55, 0x6d, 0x12, 0x28,
+	0x0a, 0x10, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x5f, 0x62, 0x61, 0x72, 0x5f, 0x70, 0x69, 0x78, 0x65,
+	0x6c, 0x73, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x73, 0x63, 0x61, 0x6c, 0x65, 0x42,
+	0x61, 0x72, 0x50, 0x69, 0x78, 0x65, 0x6c, 0x73, 0x12, 0x16, 0x0a, 0x06, 0x73, 0x6f, 0x75, 0x72,
+	0x63, 0x65, 0x18, 0x04, 0x20, 0x01, 0x28, 0x09, 0x52, 0x06, 0x73, 0x6f, 0x75, 0x72, 0x63, 0x65,
+	0x22, 0x84, 0x02, 0x0a, 0x0d, 0x49, 0x6d, 0x61, 0x67, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61,
+	0x74, 0x61, 0x12, 0x12, 0x0a, 0x04, 0x6d, 0x61, 0x6b, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09,
+	0x52, 0x04, 0x6d, 0x61, 0x6b, 0x65, 0x12, 0x14, 0x0a, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x18,
+	0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6d, 0x6f, 0x64, 0x65, 0x6c, 0x12, 0x37, 0x0a, 0x09,
+	0x64, 0x61, 0x74, 0x65, 0x5f, 0x74, 0x69, 0x6d, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x0b, 0x32,
+	0x1a, 0x2e, 0x67, 0x6f, 0x6f, 0x67, 0x6c, 0x65, 0x2e, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x62, 0x75,
+	0x66, 0x2e, 0x54, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x61, 0x6d, 0x70, 0x52, 0x08, 0x64, 0x61, 0x74,
+	0x65, 0x54, 0x69, 0x6d, 0x65, 0x12, 0x21, 0x0a, 0x0c, 0x78, 0x5f, 0x72, 0x65, 0x73, 0x6f, 0x6c,
+	0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x78, 0x52, 0x65,
+	0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x21, 0x0a, 0x0c, 0x79, 0x5f, 0x72, 0x65,
+	0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b,
+	0x79, 0x52, 0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x12, 0x27, 0x0a, 0x0f, 0x72,
+	0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x09, 0x52, 0x0e, 0x72, 0x65, 0x73, 0x6f, 0x6c, 0x75, 0x74, 0x69, 0x6f, 0x6e,
+	0x55, 0x6e, 0x69, 0x74, 0x12, 0x21, 0x0a, 0x0c, 0x75, 0x73, 0x65, 0x72, 0x5f, 0x63, 0x6f, 0x6d,
+	0x6d, 0x65, 0x6e, 0x74, 0x18, 0x07, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0b, 0x75, 0x73, 0x65, 0x72,
+	0x43, 0x6f, 0x6d, 0x6d, 0x65, 0x6e, 0x74, 0x22, 0xd8, 0x01, 0x0a, 0x0e, 0x53, 0x61, 0x6d, 0x70,
+	0x6c, 0x65, 0x4d, 0x65, 0x74, 0x61, 0x64, 0x61, 0x74, 0x61, 0x12, 0x1b, 0x0a, 0x09, 0x73, 0x61,
+	0x6d, 0x70, 0x6c, 0x65, 0x5f, 0x69, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x08, 0x73,
+	0x61, 0x6d, 0x70, 0x6c, 0x65, 0x49, 0x64, 0x12, 0x23, 0x0a, 0x0d, 0x6f, 0x70, 0x65, 0x72, 0x61,
+	0x74, 0x6f, 0x72, 0x5f, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x0c,
+	0x6f, 0x70, 0x65, 0x72, 0x61, 0x74, 0x6f, 0x72, 0x4e, 0x61, 0x6d, 0x65, 0x12, 0x21, 0x0a, 0x0c,
+	0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6c, 0x61, 0x74, 0x18, 0x03, 0x20, 0x01,
+	0x28, 0x01, 0x52, 0x0b, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4c, 0x61, 0x74, 0x12,
+	0x21, 0x0a, 0x0c, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x5f, 0x6c, 0x6f, 0x6e, 0x18,
+	0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0b, 0x6c, 0x6f, 0x63, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x4c,
+	0x6f, 0x6e, 0x12, 0x28, 0x0a, 0x10, 0x73, 0x61, 0x6d, 0x70, 0x6c, 0x69, 0x6e, 0x67, 0x5f, 0x64,
+	0x65, 0x70, 0x74, 0x68, 0x5f, 0x6d, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x73, 0x61,
+	0x6d, 0x70, 0x6c, 0x69, 0x6e, 0x67, 0x44, 0x65, 0x70, 0x74, 0x68, 0x4d, 0x12, 0x14, 0x0a, 0x05,
+	0x6e, 0x6f, 0x74, 0x65, 0x73, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x05, 0x6e, 0x6f, 0x74,
+	0x65, 0x73, 0x22, 0x55, 0x0a, 0x09, 0x52, 0x65, 0x63, 0x74, 0x61, 0x6e, 0x67, 0x6c, 0x65, 0x12,
+	0x0c, 0x0a, 0x01, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x01, 0x78, 0x12, 0x0c, 0x0a,
+	0x01, 0x79, 0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x01, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x77,
+	0x69, 0x64, 0x74, 0x68, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74,
+	0x68, 0x12, 0x16, 0x0a, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x04, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x22, 0x62, 0x0a, 0x09, 0x52, 0x4f, 0x49,
+	0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18,
+	0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x12, 0x0a, 0x04,
+	0x6e, 0x61, 0x6d, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d, 0x65,
+	0x12, 0x2b, 0x0a, 0x11, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x5f, 0x70, 0x65, 0x72, 0x63, 0x65,
+	0x6e, 0x74, 0x61, 0x67, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x10, 0x70, 0x75, 0x72,
+	0x69, 0x74, 0x79, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x22, 0x77, 0x0a,
+	0x0b, 0x53, 0x6c, 0x69, 0x63, 0x65, 0x52, 0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x14, 0x0a, 0x05,
+	0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x69, 0x6e, 0x64,
+	0x65, 0x78, 0x12, 0x2b, 0x0a, 0x11, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x5f, 0x70, 0x65, 0x72,
+	0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x10, 0x70,
+	0x75, 0x72, 0x69, 0x74, 0x79, 0x50, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x12,
+	0x25, 0x0a, 0x0e, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e,
+	0x74, 0x18, 0x03, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c,
+	0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x22, 0xdf, 0x01, 0x0a, 0x0a, 0x54, 0x69, 0x6c, 0x65, 0x52,
+	0x65, 0x73, 0x75, 0x6c, 0x74, 0x12, 0x14, 0x0a, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x18, 0x01,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x69, 0x6e, 0x64, 0x65, 0x78, 0x12, 0x0c, 0x0a, 0x01, 0x78,
+	0x18, 0x02, 0x20, 0x01, 0x28, 0x05, 0x52, 0x01, 0x78, 0x12, 0x0c, 0x0a, 0x01, 0x79, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x05, 0x52, 0x01, 0x79, 0x12, 0x14, 0x0a, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68,
+	0x18, 0x04, 0x20, 0x01, 0x28, 0x05, 0x52, 0x05, 0x77, 0x69, 0x64, 0x74, 0x68, 0x12, 0x16, 0x0a,
+	0x06, 0x68, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x05, 0x20, 0x01, 0x28, 0x05, 0x52, 0x06, 0x68,
+	0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c,
+	0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x05, 0x52, 0x0d, 0x70,
+	0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x12, 0x1d, 0x0a, 0x0a,
+	0x74, 0x6f, 0x74, 0x61, 0x6c, 0x5f, 0x61, 0x72, 0x65, 0x61, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x09, 0x74, 0x6f, 0x74, 0x61, 0x6c, 0x41, 0x72, 0x65, 0x61, 0x12, 0x2b, 0x0a, 0x11, 0x70,
+	0x75, 0x72, 0x69, 0x74, 0x79, 0x5f, 0x70, 0x65, 0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65,
+	0x18, 0x08, 0x20, 0x01, 0x28, 0x01, 0x52, 0x10, 0x70, 0x75, 0x72, 0x69, 0x74, 0x79, 0x50, 0x65,
+	0x72, 0x63, 0x65, 0x6e, 0x74, 0x61, 0x67, 0x65, 0x22, 0x99, 0x01, 0x0a, 0x0c, 0x53, 0x70, 0x61,
+	0x63, 0x69, 0x6e, 0x67, 0x53, 0x74, 0x61, 0x74, 0x73, 0x12, 0x25, 0x0a, 0x0e, 0x70, 0x61, 0x72,
+	0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x05, 0x52, 0x0d, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74,
+	0x12, 0x12, 0x0a, 0x04, 0x6d, 0x65, 0x61, 0x6e, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04,
+	0x6d, 0x65, 0x61, 0x6e, 0x12, 0x16, 0x0a, 0x06, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x6e, 0x18, 0x03,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x06, 0x6d, 0x65, 0x64, 0x69, 0x61, 0x6e, 0x12, 0x10, 0x0a, 0x03,
+	0x6d, 0x69, 0x6e, 0x18, 0x04, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6d, 0x69, 0x6e, 0x12, 0x10,
+	0x0a, 0x03, 0x6d, 0x61, 0x78, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x03, 0x6d, 0x61, 0x78,
+	0x12, 0x12, 0x0a, 0x04, 0x75, 0x6e, 0x69, 0x74, 0x18, 0x06, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04,
+	0x75, 0x6e, 0x69, 0x74, 0x22, 0x92, 0x01, 0x0a, 0x11, 0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65,
+	0x6e, 0x63, 0x65, 0x46, 0x61, 0x63, 0x74, 0x6f, 0x72, 0x73, 0x12, 0x12, 0x0a, 0x04, 0x62, 0x61,
+	0x73, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x01, 0x52, 0x04, 0x62, 0x61, 0x73, 0x65, 0x12, 0x32,
+	0x0a, 0x15, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x63, 0x6f, 0x75, 0x6e, 0x74,
+	0x5f, 0x62, 0x75, 0x63, 0x6b, 0x65, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28, 0x09, 0x52, 0x13, 0x70,
+	0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x43, 0x6f, 0x75, 0x6e, 0x74, 0x42, 0x75, 0x63, 0x6b,
+	0x65, 0x74, 0x12, 0x35, 0x0a, 0x07, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x73, 0x18, 0x03, 0x20,
+	0x03, 0x28, 0x0b, 0x32, 0x1b, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e,
+	0x43, 0x6f, 0x6e, 0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c,
+	0x52, 0x07, 0x73, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x73, 0x22, 0x5c, 0x0a, 0x10, 0x43, 0x6f, 0x6e,
+	0x66, 0x69, 0x64, 0x65, 0x6e, 0x63, 0x65, 0x53, 0x69, 0x67, 0x6e, 0x61, 0x6c, 0x12, 0x12, 0x0a,
+	0x04, 0x6e, 0x61, 0x6d, 0x65, 0x18, 0x01, 0x20, 0x01, 0x28, 0x09, 0x52, 0x04, 0x6e, 0x61, 0x6d,
+	0x65, 0x12, 0x16, 0x0a, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x18, 0x02, 0x20, 0x01, 0x28,
+	0x01, 0x52, 0x06, 0x77, 0x65, 0x69, 0x67, 0x68, 0x74, 0x12, 0x1c, 0x0a, 0x09, 0x73, 0x61, 0x74,
+	0x69, 0x73, 0x66, 0x69, 0x65, 0x64, 0x18, 0x03, 0x20, 0x01, 0x28, 0x08, 0x52, 0x09, 0x73, 0x61,
+	0x74, 0x69, 0x73, 0x66, 0x69, 0x65, 0x64, 0x22, 0xba, 0x02, 0x0a, 0x0b, 0x4d, 0x61, 0x63, 0x72,
+	0x6f, 0x50, 0x61, 0x72, 0x61, 0x6d, 0x73, 0x12, 0x29, 0x0a, 0x10, 0x74, 0x68, 0x72, 0x65, 0x73,
+	0x68, 0x6f, 0x6c, 0x64, 0x5f, 0x6d, 0x65, 0x74, 0x68, 0x6f, 0x64, 0x18, 0x01, 0x20, 0x01, 0x28,
+	0x09, 0x52, 0x0f, 0x74, 0x68, 0x72, 0x65, 0x73, 0x68, 0x6f, 0x6c, 0x64, 0x4d, 0x65, 0x74, 0x68,
+	0x6f, 0x64, 0x12, 0x2a, 0x0a, 0x11, 0x6d, 0x69, 0x6e, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63,
+	0x6c, 0x65, 0x5f, 0x73, 0x69, 0x7a, 0x65, 0x18, 0x02, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f, 0x6d,
+	0x69, 0x6e, 0x50, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x2a,
+	0x0a, 0x11, 0x6d, 0x61, 0x78, 0x5f, 0x70, 0x61, 0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x5f, 0x73,
+	0x69, 0x7a, 0x65, 0x18, 0x03, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0f, 0x6d, 0x61, 0x78, 0x50, 0x61,
+	0x72, 0x74, 0x69, 0x63, 0x6c, 0x65, 0x53, 0x69, 0x7a, 0x65, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x69,
+	0x6e, 0x5f, 0x63, 0x69, 0x72, 0x63, 0x75, 0x6c, 0x61, 0x72, 0x69, 0x74, 0x79, 0x18, 0x04, 0x20,
+	0x01, 0x28, 0x01, 0x52, 0x0e, 0x6d, 0x69, 0x6e, 0x43, 0x69, 0x72, 0x63, 0x75, 0x6c, 0x61, 0x72,
+	0x69, 0x74, 0x79, 0x12, 0x27, 0x0a, 0x0f, 0x6d, 0x61, 0x78, 0x5f, 0x63, 0x69, 0x72, 0x63, 0x75,
+	0x6c, 0x61, 0x72, 0x69, 0x74, 0x79, 0x18, 0x05, 0x20, 0x01, 0x28, 0x01, 0x52, 0x0e, 0x6d, 0x61,
+	0x78, 0x43, 0x69, 0x72, 0x63, 0x75, 0x6c, 0x61, 0x72, 0x69, 0x74, 0x79, 0x12, 0x25, 0x0a, 0x0e,
+	0x67, 0x61, 0x75, 0x73, 0x73, 0x69, 0x61, 0x6e, 0x5f, 0x73, 0x69, 0x67, 0x6d, 0x61, 0x18, 0x06,
+	0x20, 0x01, 0x28, 0x01, 0x52, 0x0d, 0x67, 0x61, 0x75, 0x73, 0x73, 0x69, 0x61, 0x6e, 0x53, 0x69,
+	0x67, 0x6d, 0x61, 0x12, 0x2f, 0x0a, 0x13, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x73, 0x74, 0x5f,
+	0x73, 0x61, 0x74, 0x75, 0x72, 0x61, 0x74, 0x69, 0x6f, 0x6e, 0x18, 0x07, 0x20, 0x01, 0x28, 0x01,
+	0x52, 0x12, 0x63, 0x6f, 0x6e, 0x74, 0x72, 0x61, 0x73, 0x74, 0x53, 0x61, 0x74, 0x75, 0x72, 0x61,
+	0x74, 0x69, 0x6f, 0x6e, 0x32, 0x9a, 0x01, 0x0a, 0x0e, 0x47, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x41,
+	0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x12, 0x46, 0x0a, 0x0d, 0x41, 0x6e, 0x61, 0x6c, 0x79,
+	0x7a, 0x65, 0x47, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x12, 0x19, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75,
+	0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x71, 0x75,
+	0x65, 0x73, 0x74, 0x1a, 0x1a, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e,
+	0x41, 0x6e, 0x61, 0x6c, 0x79, 0x7a, 0x65, 0x52, 0x65, 0x73, 0x70, 0x6f, 0x6e, 0x73, 0x65, 0x12,
+	0x40, 0x0a, 0x09, 0x47, 0x65, 0x74, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x12, 0x18, 0x2e, 0x67,
+	0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e, 0x76, 0x31, 0x2e, 0x53, 0x74, 0x61, 0x74, 0x75, 0x73, 0x52,
+	0x65, 0x71, 0x75, 0x65, 0x73, 0x74, 0x1a, 0x19, 0x2e, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2e,
+	0x76, 0x31, 0x2e, 0x41, 0x6e, 0x61, 0x6c, 0x79, 0x73, 0x69, 0x73, 0x52, 0x65, 0x73, 0x75, 0x6c,
+	0x74, 0x42, 0x24, 0x5a, 0x22, 0x67, 0x79, 0x70, 0x73, 0x75, 0x6d, 0x2d, 0x61, 0x6e, 0x61, 0x6c,
+	0x79, 0x73, 0x69, 0x73, 0x2d, 0x61, 0x70, 0x69, 0x2f, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x2f, 0x67,
+	0x79, 0x70, 0x73, 0x75, 0x6d, 0x70, 0x62, 0x62, 0x06, 0x70, 0x72, 0x6f, 0x74, 0x6f, 0x33,
+}
+
+var (
+	file_proto_gypsum_analysis_proto_rawDescOnce sync.Once
+	file_proto_gypsum_analysis_proto_rawDescData = file_proto_gypsum_analysis_proto_rawDesc
+)
+
+func file_proto_gypsum_analysis_proto_rawDescGZIP() []byte {
+	file_proto_gypsum_analysis_proto_rawDescOnce.Do(func() {
+		file_proto_gypsum_analysis_proto_rawDescData = protoimpl.X.CompressGZIP(file_proto_gypsum_analysis_proto_rawDescData)
+	})
+	return file_proto_gypsum_analysis_proto_rawDescData
+}
+
+var file_proto_gypsum_analysis_proto_msgTypes = make([]protoimpl.MessageInfo, 17)
+var file_proto_gypsum_analysis_proto_goTypes = []interface{}{
+	(*AnalyzeRequest)(nil),        // 0: gypsum.v1.AnalyzeRequest
+	(*AnalyzeResponse)(nil),       // 1: gypsum.v1.AnalyzeResponse
+	(*StatusRequest)(nil),         // 2: gypsum.v1.StatusRequest
+	(*AnalysisResult)(nil),        // 3: gypsum.v1.AnalysisResult
+	(*Calibration)(nil),           // 4: gypsum.v1.Calibration
+	(*ImageMetadata)(nil),         // 5: gypsum.v1.ImageMetadata
+	(*SampleMetadata)(nil),        // 6: gypsum.v1.SampleMetadata
+	(*Rectangle)(nil),             // 7: gypsum.v1.Rectangle
+	(*ROIResult)(nil),             // 8: gypsum.v1.ROIResult
+	(*SliceResult)(nil),           // 9: gypsum.v1.SliceResult
+	(*TileResult)(nil),            // 10: gypsum.v1.TileResult
+	(*SpacingStats)(nil),          // 11: gypsum.v1.SpacingStats
+	(*ConfidenceFactors)(nil),     // 12: gypsum.v1.ConfidenceFactors
+	(*ConfidenceSignal)(nil),      // 13: gypsum.v1.ConfidenceSignal
+	(*MacroParams)(nil),           // 14: gypsum.v1.MacroParams
+	nil,                           // 15: gypsum.v1.AnalyzeRequest.MetadataEntry
+	nil,                           // 16: gypsum.v1.AnalysisResult.MetadataEntry
+	(*timestamppb.Timestamp)(nil), // 17: google.protobuf.Timestamp
+}
+var file_proto_gypsum_analysis_proto_depIdxs = []int32{
+	15, // 0: gypsum.v1.AnalyzeRequest.metadata:type_name -> gypsum.v1.AnalyzeRequest.MetadataEntry
+	7,  // 1: gypsum.v1.AnalyzeRequest.roi:type_name -> gypsum.v1.Rectangle
+	6,  // 2: gypsum.v1.AnalyzeRequest.sample_metadata:type_name -> gypsum.v1.SampleMetadata
+	17, // 3: gypsum.v1.AnalysisResult.created_at:type_name -> google.protobuf.Timestamp
+	17, // 4: gypsum.v1.AnalysisResult.completed_at:type_name -> google.protobuf.Timestamp
+	4,  // 5: gypsum.v1.AnalysisResult.calibration:type_name -> gypsum.v1.Calibration
+	8,  // 6: gypsum.v1.AnalysisResult.roi_results:type_name -> gypsum.v1.ROIResult
+	10, // 7: gypsum.v1.AnalysisResult.tiles:type_name -> gypsum.v1.TileResult
+	11, // 8: gypsum.v1.AnalysisResult.spacing_stats:type_name -> gypsum.v1.SpacingStats
+	16, // 9: gypsum.v1.AnalysisResult.metadata:type_name -> gypsum.v1.AnalysisResult.MetadataEntry
+	14, // 10: gypsum.v1.AnalysisResult.parameters:type_name -> gypsum.v1.MacroParams
+	17, // 11: gypsum.v1.AnalysisResult.reviewed_at:type_name -> google.protobuf.Timestamp
+	7,  // 12: gypsum.v1.AnalysisResult.roi:type_name -> gypsum.v1.Rectangle
+	17, // 13: gypsum.v1.AnalysisResult.signed_at:type_name -> google.protobuf.Timestamp
+	6,  // 14: gypsum.v1.AnalysisResult.sample_metadata:type_name -> gypsum.v1.SampleMetadata
+	5,  // 15: gypsum.v1.AnalysisResult.image_metadata:type_name -> gypsum.v1.ImageMetadata
+	9,  // 16: gypsum.v1.AnalysisResult.per_slice:type_name -> gypsum.v1.SliceResult
+	12, // 17: gypsum.v1.AnalysisResult.confidence_factors:type_name -> gypsum.v1.ConfidenceFactors
+	17, // 18: gypsum.v1.ImageMetadata.date_time:type_name -> google.protobuf.Timestamp
+	13, // 19: gypsum.v1.ConfidenceFactors.signals:type_name -> gypsum.v1.ConfidenceSignal
+	0,  // 20: gypsum.v1.GypsumAnalysis.AnalyzeGypsum:input_type -> gypsum.v1.AnalyzeRequest
+	2,  // 21: gypsum.v1.GypsumAnalysis.GetStatus:input_type -> gypsum.v1.StatusRequest
+	1,  // 22: gypsum.v1.GypsumAnalysis.AnalyzeGypsum:output_type -> gypsum.v1.AnalyzeResponse
+	3,  // 23: gypsum.v1.GypsumAnalysis.GetStatus:output_type -> gypsum.v1.AnalysisResult
+	22, // [22:24] is the sub-list for method output_type
+	20, // [20:22] is the sub-list for method input_type
+	20, // [20:20] is the sub-list for extension type_name
+	20, // [20:20] is the sub-list for extension extendee
+	0,  // [0:20] is the sub-list for field type_name
+}
+
+func init() { file_proto_gypsum_analysis_proto_init() }
+func file_proto_gypsum_analysis_proto_init() {
+	if File_proto_gypsum_analysis_proto != nil {
+		return
+	}
+	if !protoimpl.UnsafeEnabled {
+		file_proto_gypsum_analysis_proto_msgTypes[0].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AnalyzeRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[1].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AnalyzeResponse); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[2].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*StatusRequest); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[3].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*AnalysisResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[4].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Calibration); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[5].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ImageMetadata); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[6].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SampleMetadata); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[7].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*Rectangle); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[8].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ROIResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[9].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SliceResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[10].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*TileResult); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[11].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*SpacingStats); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[12].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConfidenceFactors); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[13].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*ConfidenceSignal); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+		file_proto_gypsum_analysis_proto_msgTypes[14].Exporter = func(v interface{}, i int) interface{} {
+			switch v := v.(*MacroParams); i {
+			case 0:
+				return &v.state
+			case 1:
+				return &v.sizeCache
+			case 2:
+				return &v.unknownFields
+			default:
+				return nil
+			}
+		}
+	}
+	file_proto_gypsum_analysis_proto_msgTypes[0].OneofWrappers = []interface{}{}
+	file_proto_gypsum_analysis_proto_msgTypes[3].OneofWrappers = []interface{}{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: file_proto_gypsum_analysis_proto_rawDesc,
+			NumEnums:      0,
+			NumMessages:   17,
+			NumExtensions: 0,
+			NumServices:   1,
+		},
+		GoTypes:           file_proto_gypsum_analysis_proto_goTypes,
+		DependencyIndexes: file_proto_gypsum_analysis_proto_depIdxs,
+		MessageInfos:      file_proto_gypsum_analysis_proto_msgTypes,
+	}.Build()
+	File_proto_gypsum_analysis_proto = out.File
+	file_proto_gypsum_analysis_proto_rawDesc = nil
+	file_proto_gypsum_analysis_proto_goTypes = nil
+	file_proto_gypsum_analysis_proto_depIdxs = nil
+}